@@ -22,17 +22,19 @@ func Start() error {
 	defer done()
 	errs, ctx := errgroup.WithContext(ctx)
 
-	sqlite3LibVersion, _, _ := sqlite3.Version()
-
-	log.Printf("using sqlite3 version: %v, database %v", sqlite3LibVersion, config.Configuration.DatabasePath)
-
-	db, err := sql.Open("sqlite3", config.Configuration.DatabasePath)
+	db, err := openDatabase()
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer db.Close()
 
-	database.Init(db)
+	replicaDB, err := openReplicaDatabase()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if replicaDB != nil {
+		defer replicaDB.Close()
+	}
 
 	// mail (push layer) service
 	mailService, err := mail.NewService(
@@ -47,7 +49,8 @@ func Start() error {
 	// mailbox (pull layer) service
 	mailboxService, err := mailbox.NewService(
 		&mailbox.ServiceParams{
-			DB: db,
+			DB:        db,
+			ReplicaDB: replicaDB,
 		})
 	if err != nil {
 		log.Fatal(err)
@@ -74,3 +77,30 @@ func Start() error {
 		return err
 	}
 }
+
+func openDatabase() (*sql.DB, error) {
+	sqlite3LibVersion, _, _ := sqlite3.Version()
+
+	log.Printf("using sqlite3 version: %v, database %v", sqlite3LibVersion, config.Configuration.DatabasePath)
+
+	db, err := database.Connect(config.Configuration.DatabasePath)
+	if err != nil {
+		return nil, err
+	}
+
+	database.Init(db)
+
+	return db, nil
+}
+
+// openReplicaDatabase opens the read replica, if any. Its schema is the one of the primary, it is not
+// initialized here.
+func openReplicaDatabase() (*sql.DB, error) {
+	if len(config.Configuration.ReplicaDatabasePath) == 0 {
+		return nil, nil
+	}
+
+	log.Printf("using the read replica %v", config.Configuration.ReplicaDatabasePath)
+
+	return database.Connect(config.Configuration.ReplicaDatabasePath)
+}
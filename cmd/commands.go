@@ -0,0 +1,29 @@
+package cargomail
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrUnknownCommand = errors.New("unknown command")
+
+var commands = map[string]func(args []string) error{
+	"reindex":         Reindex,
+	"purge-trash":     PurgeTrash,
+	"compact-history": CompactHistory,
+	"gc-blobs":        GcBlobs,
+	"set-quota":       SetQuota,
+	"verify-blobs":    VerifyBlobs,
+	"federation-key":  FederationKey,
+	"set-peer-key":    SetPeerKey,
+}
+
+// Exec runs a one-shot maintenance command, e.g. cargomail reindex --type=contacts
+func Exec(name string, args []string) error {
+	command, ok := commands[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownCommand, name)
+	}
+
+	return command(args)
+}
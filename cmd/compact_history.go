@@ -0,0 +1,64 @@
+package cargomail
+
+import (
+	"cargomail/internal/mailbox/repository"
+	"cargomail/internal/shared/config"
+	"flag"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+const defaultDeviceWindowDays = 90
+
+// CompactHistory drops the tombstones of the deletes older than the trash retention, unless a device of the
+// user was last seen before, e.g. from cron. The devices not seen within --device-days sync from scratch on
+// their next login instead of holding the tombstones back.
+func CompactHistory(args []string) error {
+	flags := flag.NewFlagSet("compact-history", flag.ContinueOnError)
+	days := flags.Int("days", -1, "retention window in days (defaults to trashRetentionDays)")
+	deviceDays := flags.Int("device-days", defaultDeviceWindowDays, "the devices not seen within the days must resync")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	retention := config.TrashRetention()
+	if *days >= 0 {
+		retention = time.Duration(*days) * 24 * time.Hour
+	} else if retention == 0 {
+		log.Print("compact-history: the trash retention is disabled, nothing to do")
+		return nil
+	}
+
+	db, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	repository := repository.NewRepository(db)
+
+	compaction, err := repository.Tombstones.Compact(retention, time.Duration(*deviceDays)*24*time.Hour)
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	var counts []string
+
+	for resource, count := range compaction.Tombstones {
+		total += count
+		counts = append(counts, fmt.Sprintf("%d %s", count, resource))
+	}
+
+	sort.Strings(counts)
+
+	log.Printf("compact-history: %d tombstones removed (%s), %d history marks removed, %d stale devices told to resync",
+		total, strings.Join(counts, ", "), compaction.Marks, compaction.StaleDevices)
+
+	return nil
+}
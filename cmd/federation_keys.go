@@ -0,0 +1,88 @@
+package cargomail
+
+import (
+	"cargomail/internal/mailbox/repository"
+	"cargomail/internal/shared/config"
+	"crypto/ed25519"
+	b64 "encoding/base64"
+	"errors"
+	"flag"
+	"log"
+)
+
+// FederationKey prints the public key of the instance, the peers set it by the set-peer-key, e.g.
+// cargomail federation-key.
+func FederationKey(args []string) error {
+	flags := flag.NewFlagSet("federation-key", flag.ContinueOnError)
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	db, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	repository := repository.NewRepository(db)
+
+	key, err := repository.Federation.InstanceKey()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("federation-key %s: %s", config.Configuration.DomainName, b64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)))
+
+	return nil
+}
+
+// SetPeerKey sets the public key the messages of the peer are verified by, e.g. cargomail set-peer-key
+// --domain=example.org --key=<the federation-key of the peer>. The --delete removes it.
+func SetPeerKey(args []string) error {
+	flags := flag.NewFlagSet("set-peer-key", flag.ContinueOnError)
+	domain := flags.String("domain", "", "the domain of the peer")
+	key := flags.String("key", "", "the base64 Ed25519 public key of the peer")
+	remove := flags.Bool("delete", false, "remove the key of the peer")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if len(*domain) == 0 {
+		return errors.New("missing --domain")
+	}
+
+	if len(*key) == 0 && !*remove {
+		return errors.New("missing --key or --delete")
+	}
+
+	db, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	repository := repository.NewRepository(db)
+
+	if *remove {
+		err = repository.Federation.DeletePeerKey(*domain)
+		if err != nil {
+			return err
+		}
+
+		log.Printf("set-peer-key %s: deleted", *domain)
+		return nil
+	}
+
+	err = repository.Federation.SetPeerKey(*domain, *key)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("set-peer-key %s: set", *domain)
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package cargomail
+
+import (
+	"cargomail/internal/mailbox/repository"
+	"cargomail/internal/mailbox/storage"
+	"flag"
+	"log"
+	"time"
+)
+
+// GcBlobs removes the stored bytes of the blobs and the files no row refers to, e.g. left behind by a crash.
+// The objects younger than --grace are kept, so it is safe to run it while the server is serving traffic.
+func GcBlobs(args []string) error {
+	flags := flag.NewFlagSet("gc-blobs", flag.ContinueOnError)
+	grace := flags.Duration("grace", time.Hour, "minimum age of the unreferenced objects removed")
+	dryRun := flags.Bool("dry-run", false, "report the unreferenced objects only")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	db, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	repo := repository.NewRepository(db)
+
+	blobStore, fileStore, err := storage.NewStores()
+	if err != nil {
+		return err
+	}
+
+	verb := "reclaimed"
+	if *dryRun {
+		verb = "to reclaim"
+	}
+
+	stores := []struct {
+		resource string
+		store    storage.BlobStore
+	}{
+		{repository.BlobsResource, blobStore},
+		{repository.FilesResource, fileStore},
+	}
+
+	for _, s := range stores {
+		garbage, err := storage.CollectGarbage(repo, s.resource, s.store, *grace, *dryRun)
+		if err != nil {
+			return err
+		}
+
+		log.Printf("gc-blobs %s: %d released, %d orphaned, %d bytes %s", s.resource, garbage.Released, garbage.Orphaned, garbage.Bytes, verb)
+	}
+
+	return nil
+}
@@ -3,6 +3,7 @@ package api
 import (
 	"cargomail/cmd/mail/api/helper"
 	"cargomail/internal/mailbox/repository"
+	"cargomail/internal/mailbox/storage"
 	"cargomail/internal/shared/config"
 	"context"
 	"errors"
@@ -12,6 +13,7 @@ import (
 
 type ApiParams struct {
 	Repository repository.Repository
+	Storage    storage.Storage
 }
 
 type Api struct {
@@ -19,15 +21,25 @@ type Api struct {
 	Auth     AuthApi
 	Session  SessionApi
 	User     UserApi
+	ApiKeys  ApiKeysApi
+	Devices  DevicesApi
+	Export   ExportApi
+	AuditLog AuditLogApi
+	Webhooks WebhooksApi
 	Messages MessagesApi
 }
 
 func NewApi(params ApiParams) Api {
 	return Api{
-		Health:   HealthApi{},
+		Health:   HealthApi{useHealthRepository: params.Repository.Health},
 		Auth:     AuthApi{},
-		Session:  SessionApi{useUserRepository: params.Repository.User, useSessionRepository: params.Repository.Session},
-		User:     UserApi{useUserRepository: params.Repository.User},
+		Session:  SessionApi{useUserRepository: params.Repository.User, useSessionRepository: params.Repository.Session, useDeviceRepository: params.Repository.Devices, useRefreshTokenRepository: params.Repository.RefreshTokens, useLoginAttemptRepository: params.Repository.LoginAttempts},
+		User:     UserApi{useUserRepository: params.Repository.User, useSessionRepository: params.Repository.Session, useAccountStorage: params.Storage.Accounts, useBlobRepository: params.Repository.Blobs, useFileRepository: params.Repository.Files},
+		ApiKeys:  ApiKeysApi{useApiKeyRepository: params.Repository.ApiKeys},
+		Devices:  DevicesApi{useDeviceRepository: params.Repository.Devices},
+		Export:   ExportApi{useUserRepository: params.Repository.User, useContactRepository: params.Repository.Contacts, useBlobRepository: params.Repository.Blobs, useFileRepository: params.Repository.Files, useDraftStorage: params.Storage.Drafts, useMessageStorage: params.Storage.Messages, useBlobStorage: params.Storage.Blobs, useFileStorage: params.Storage.Files},
+		AuditLog: AuditLogApi{useAuditLogRepository: params.Repository.AuditLog},
+		Webhooks: WebhooksApi{useWebhookRepository: params.Repository.Webhooks},
 		Messages: MessagesApi{useMessageRepository: params.Repository.Messages},
 	}
 }
@@ -44,9 +56,9 @@ func (api *Api) Authenticate(next http.Handler) http.Handler {
 		if err != nil {
 			switch {
 			case errors.Is(err, http.ErrNoCookie):
-				http.Error(w, "cookie not found", http.StatusBadRequest)
+				helper.ReturnErr(w, repository.ErrMissingSessionCookie, http.StatusBadRequest)
 			default:
-				http.Error(w, "server error", http.StatusInternalServerError)
+				helper.ReturnErr(w, repository.ErrInternalServer, http.StatusInternalServerError)
 			}
 			return
 		}
@@ -78,7 +90,7 @@ func (api *Api) Authenticate(next http.Handler) http.Handler {
 			case errors.Is(err, http.ErrNoCookie):
 				// nothing to do
 			default:
-				http.Error(w, "server error", http.StatusInternalServerError)
+				helper.ReturnErr(w, repository.ErrInternalServer, http.StatusInternalServerError)
 				return
 			}
 		} else {
@@ -87,11 +99,19 @@ func (api *Api) Authenticate(next http.Handler) http.Handler {
 
 		user.DeviceId = &deviceId
 
+		if len(deviceId) > 0 {
+			err = api.Session.useDeviceRepository.Touch(user, deviceId, r.UserAgent())
+			if err != nil {
+				helper.ReturnErr(w, err, http.StatusInternalServerError)
+				return
+			}
+		}
+
 		r = api.contextSetUser(r, user)
 
 		// refresh sessionId/deviceId cookies
 
-		ttl := config.DefaultSessionTTL
+		ttl := config.SessionTTL()
 		sessionCookie.Expires = time.Now().Add(ttl)
 		sessionCookie.Path = "/"
 
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"cargomail/cmd/mail/api/helper"
+	"cargomail/internal/mailbox/repository"
+	"errors"
+	"net/http"
+)
+
+type ApiKeysApi struct {
+	useApiKeyRepository repository.UseApiKeyRepository
+}
+
+type apiKeyInput struct {
+	Name      string                `json:"name"`
+	Scopes    []string              `json:"scopes"`
+	ExpiresAt *repository.Timestamp `json:"expiresAt"`
+}
+
+func (api *ApiKeysApi) Create() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		var input apiKeyInput
+
+		err := helper.Decoder(r.Body).Decode(&input)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		apiKey := &repository.ApiKey{
+			Name:      input.Name,
+			Scopes:    input.Scopes,
+			ExpiresAt: input.ExpiresAt,
+		}
+
+		newApiKey, err := api.useApiKeyRepository.Create(user, apiKey)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrMissingNameField),
+				errors.Is(err, repository.ErrInvalidScope),
+				errors.Is(err, repository.ErrInvalidExpiry):
+				helper.ReturnErr(w, err, http.StatusBadRequest)
+			default:
+				helper.ReturnErr(w, err, http.StatusInternalServerError)
+			}
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusCreated, newApiKey)
+	})
+}
+
+func (api *ApiKeysApi) List() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		apiKeys, err := api.useApiKeyRepository.List(user)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, apiKeys)
+	})
+}
+
+func (api *ApiKeysApi) Revoke() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		var id repository.Id
+
+		err := helper.Decoder(r.Body).Decode(&id)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		if len(id.Id) == 0 {
+			helper.ReturnErr(w, repository.ErrMissingIdField, http.StatusBadRequest)
+			return
+		}
+
+		err = api.useApiKeyRepository.Revoke(user, id.Id)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrApiKeyNotFound):
+				helper.ReturnErr(w, err, http.StatusNotFound)
+			default:
+				helper.ReturnErr(w, err, http.StatusInternalServerError)
+			}
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, map[string]string{"status": "OK"})
+	})
+}
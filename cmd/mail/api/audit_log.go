@@ -0,0 +1,82 @@
+package api
+
+import (
+	"cargomail/cmd/mail/api/helper"
+	"cargomail/internal/mailbox/repository"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type AuditLogApi struct {
+	useAuditLogRepository repository.UseAuditLogRepository
+}
+
+// List lists the audit log of the user, the recent first,
+// e.g. ?resource=contacts&action=delete&since=2023-11-14T22:13:20Z&until=...&limit=50&cursor=...
+func (api *AuditLogApi) List() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		filter, err := auditLogFilter(r)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		auditLogList, err := api.useAuditLogRepository.List(user, filter)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrUnknownResource),
+				errors.Is(err, repository.ErrInvalidAuditAction),
+				errors.Is(err, repository.ErrInvalidCursor),
+				errors.Is(err, repository.ErrInvalidLimit),
+				errors.Is(err, repository.ErrInvalidDateRange):
+				helper.ReturnErr(w, err, http.StatusBadRequest)
+			default:
+				helper.ReturnErr(w, err, http.StatusInternalServerError)
+			}
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, auditLogList)
+	})
+}
+
+func auditLogFilter(r *http.Request) (*repository.AuditLogFilter, error) {
+	query := r.URL.Query()
+
+	filter := &repository.AuditLogFilter{
+		Resource: query.Get("resource"),
+		Action:   query.Get("action"),
+		Cursor:   query.Get("cursor"),
+	}
+
+	if limit := query.Get("limit"); len(limit) > 0 {
+		value, err := strconv.Atoi(limit)
+		if err != nil || value < 1 {
+			return nil, repository.ErrInvalidLimit
+		}
+		filter.Limit = value
+	}
+
+	for param, value := range map[string]**time.Time{
+		"since": &filter.Since,
+		"until": &filter.Until,
+	} {
+		if timestamp := query.Get(param); len(timestamp) > 0 {
+			t, err := repository.ParseTimestamp(timestamp)
+			if err != nil {
+				return nil, err
+			}
+			*value = &t
+		}
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"cargomail/cmd/mail/api/helper"
+	"cargomail/internal/mailbox/repository"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+type DevicesApi struct {
+	useDeviceRepository repository.UseDeviceRepository
+}
+
+func (api *DevicesApi) List() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		devices, err := api.useDeviceRepository.List(user)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, devices)
+	})
+}
+
+// Revoke logs the device out, with ?resync=true it has to sync from scratch on the next login.
+func (api *DevicesApi) Revoke() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		id := strings.TrimPrefix(r.URL.Path, "/api/v1/user/devices/")
+		if len(id) == 0 || strings.Contains(id, "/") {
+			helper.ReturnErr(w, repository.ErrMissingIdField, http.StatusBadRequest)
+			return
+		}
+
+		resync := r.URL.Query().Get("resync") == "true"
+
+		err := api.useDeviceRepository.Revoke(user, id, resync)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrDeviceNotFound):
+				helper.ReturnErr(w, err, http.StatusNotFound)
+			default:
+				helper.ReturnErr(w, err, http.StatusInternalServerError)
+			}
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, map[string]string{"status": "OK"})
+	})
+}
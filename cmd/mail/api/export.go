@@ -0,0 +1,316 @@
+package api
+
+import (
+	"archive/zip"
+	"bufio"
+	"cargomail/cmd/mail/api/helper"
+	mailboxhelper "cargomail/cmd/mailbox/api/helper"
+	"cargomail/internal/mailbox/repository"
+	"cargomail/internal/mailbox/storage"
+	"cargomail/internal/shared/provider"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const exportMessagesPage = 200
+
+type ExportApi struct {
+	useUserRepository    repository.UseUserRepository
+	useContactRepository repository.UseContactRepository
+	useBlobRepository    repository.UseBlobRepository
+	useFileRepository    repository.UseFileRepository
+	useDraftStorage      storage.UseDraftStorage
+	useMessageStorage    storage.UseMessageStorage
+	useBlobStorage       storage.UseBlobStorage
+	useFileStorage       storage.UseFileStorage
+}
+
+// exportManifest lists the entries of the export, it is the last entry of the ZIP.
+type exportManifest struct {
+	Version    int            `json:"version"`
+	Username   string         `json:"username"`
+	ExportedAt time.Time      `json:"exportedAt"`
+	Entries    []*exportEntry `json:"entries"`
+}
+
+type exportEntry struct {
+	Path        string `json:"path"`
+	Items       int    `json:"items,omitempty"` // the records of the JSON arrays and the vCard file
+	Id          string `json:"id,omitempty"`    // of the blob or the file
+	ContentType string `json:"contentType,omitempty"`
+	Size        int64  `json:"size,omitempty"`
+}
+
+// exportWriter writes the entries of the ZIP and records them in the manifest.
+type exportWriter struct {
+	zw       *zip.Writer
+	manifest *exportManifest
+}
+
+func (e *exportWriter) create(entry *exportEntry, modified time.Time) (io.Writer, error) {
+	e.manifest.Entries = append(e.manifest.Entries, entry)
+
+	return e.zw.CreateHeader(&zip.FileHeader{Name: entry.Path, Method: zip.Deflate, Modified: modified})
+}
+
+// jsonArray streams the items as a JSON array, one item per line.
+func (e *exportWriter) jsonArray(path string, fn func(write func(v interface{}) error) error) error {
+	entry := &exportEntry{Path: path, ContentType: "application/json"}
+
+	w, err := e.create(entry, e.manifest.ExportedAt)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+
+	err = fn(func(v interface{}) error {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+
+		separator := ",\n"
+		if entry.Items == 0 {
+			separator = "[\n"
+		}
+
+		entry.Items++
+
+		_, err = bw.WriteString(separator)
+		if err != nil {
+			return err
+		}
+
+		_, err = bw.Write(b)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	closing := "\n]\n"
+	if entry.Items == 0 {
+		closing = "[]\n"
+	}
+
+	_, err = bw.WriteString(closing)
+	if err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+func (e *exportWriter) json(path string, v interface{}) error {
+	w, err := e.create(&exportEntry{Path: path, ContentType: "application/json"}, e.manifest.ExportedAt)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(v)
+}
+
+var exportNameReplacer = strings.NewReplacer("/", "_", `\`, "_")
+
+// exportPath returns the path of the blob or the file in the ZIP, the id keeps the equal names apart.
+func exportPath(folder, id, name string) string {
+	name = exportNameReplacer.Replace(strings.TrimSpace(name))
+	if len(name) == 0 || name == "." || name == ".." {
+		name = "content"
+	}
+
+	return folder + "/" + id + "/" + name
+}
+
+// Export streams all the data of the user as a ZIP: the profile, the contacts (JSON and vCard), the drafts,
+// the messages and the bytes of the blobs and the files, with a manifest. The rows are read list by list
+// and the bytes are copied one object at a time, so the memory stays bounded. Once the ZIP is started an
+// error can only be logged, the client gets a truncated archive.
+func (api *ExportApi) Export() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		profile, err := api.useUserRepository.GetProfile(user.Username)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		exportedAt := time.Now().UTC()
+		filename := fmt.Sprintf("cargomail-%s-%s.zip", user.Username, exportedAt.Format("20060102"))
+
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+		zw := zip.NewWriter(w)
+
+		e := &exportWriter{
+			zw:       zw,
+			manifest: &exportManifest{Version: 1, Username: user.Username, ExportedAt: exportedAt, Entries: []*exportEntry{}},
+		}
+
+		err = api.export(e, user, profile)
+		if err == nil {
+			err = e.json("manifest.json", e.manifest)
+		}
+		if err == nil {
+			err = zw.Close()
+		}
+		if err != nil {
+			provider.Logf(r.Context(), "account export error: %v", err)
+		}
+	})
+}
+
+func (api *ExportApi) export(e *exportWriter, user *repository.User, profile *repository.UserProfile) error {
+	err := e.json("profile.json", profile)
+	if err != nil {
+		return err
+	}
+
+	err = e.jsonArray("contacts.json", func(write func(v interface{}) error) error {
+		return api.useContactRepository.ForEach(user, nil, func(contact *repository.Contact) error {
+			return write(contact)
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	err = api.exportVCards(e, user)
+	if err != nil {
+		return err
+	}
+
+	err = e.jsonArray("drafts.json", func(write func(v interface{}) error) error {
+		draftList, err := api.useDraftStorage.List(user, nil)
+		if err != nil {
+			return err
+		}
+
+		for _, draft := range draftList.Drafts {
+			err = write(draft)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = e.jsonArray("messages.json", func(write func(v interface{}) error) error {
+		options := &repository.ListOptions{Limit: exportMessagesPage}
+
+		for {
+			messageList, err := api.useMessageStorage.List(user, -1, options)
+			if err != nil {
+				return err
+			}
+
+			for _, message := range messageList.Messages {
+				err = write(message)
+				if err != nil {
+					return err
+				}
+			}
+
+			if len(messageList.NextCursor) == 0 {
+				return nil
+			}
+
+			options.Cursor = messageList.NextCursor
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	err = api.exportBlobs(e, user)
+	if err != nil {
+		return err
+	}
+
+	return api.exportFiles(e, user)
+}
+
+func (api *ExportApi) exportVCards(e *exportWriter, user *repository.User) error {
+	entry := &exportEntry{Path: "contacts.vcf", ContentType: "text/vcard"}
+
+	w, err := e.create(entry, e.manifest.ExportedAt)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+
+	err = api.useContactRepository.ForEach(user, nil, func(contact *repository.Contact) error {
+		entry.Items++
+		return mailboxhelper.WriteVCard(bw, contact)
+	})
+	if err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+func (api *ExportApi) exportBlobs(e *exportWriter, user *repository.User) error {
+	blobList, err := api.useBlobRepository.List(user, -1, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, blob := range blobList.Blobs {
+		entry := &exportEntry{Path: exportPath("blobs", blob.Id, blob.Name), Id: blob.Id, ContentType: blob.ContentType, Size: blob.Size}
+
+		w, err := e.create(entry, blob.CreatedAt.Time())
+		if err != nil {
+			return err
+		}
+
+		err = api.useBlobStorage.Load(w, blob)
+		if err != nil {
+			return fmt.Errorf("blob %s: %w", blob.Id, err)
+		}
+	}
+
+	return nil
+}
+
+func (api *ExportApi) exportFiles(e *exportWriter, user *repository.User) error {
+	fileList, err := api.useFileRepository.List(user, -1, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range fileList.Files {
+		entry := &exportEntry{Path: exportPath("files", file.Id, file.Name), Id: file.Id, ContentType: file.ContentType, Size: file.Size}
+
+		w, err := e.create(entry, file.CreatedAt.Time())
+		if err != nil {
+			return err
+		}
+
+		err = api.useFileStorage.Load(w, file)
+		if err != nil {
+			return fmt.Errorf("file %s: %w", file.Id, err)
+		}
+	}
+
+	return nil
+}
@@ -1,15 +1,46 @@
 package api
 
 import (
+	"cargomail/cmd/mail/api/helper"
+	"cargomail/internal/mailbox/repository"
 	"cargomail/internal/shared/config"
 	"net/http"
 )
 
 type HealthApi struct {
+	useHealthRepository repository.UseHealthRepository
 }
 
+type componentHealth struct {
+	Status string `json:"status"`
+	Err    string `json:"error,omitempty"`
+}
+
+type readiness struct {
+	Status     string                      `json:"status"`
+	Components map[string]*componentHealth `json:"components"`
+}
+
+// Healthcheck is the liveness probe, it doesn't touch the dependencies.
 func (api *HealthApi) Healthcheck() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(config.Configuration.DomainName))
 	})
 }
+
+// Ready is the readiness probe, it checks the database answers. The database down fails it with 503.
+func (api *HealthApi) Ready() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ready := &readiness{Status: "ok", Components: map[string]*componentHealth{"database": {Status: "ok"}}}
+		statusCode := http.StatusOK
+
+		err := api.useHealthRepository.Check()
+		if err != nil {
+			ready.Status = "unavailable"
+			ready.Components["database"] = &componentHealth{Status: "down", Err: err.Error()}
+			statusCode = http.StatusServiceUnavailable
+		}
+
+		helper.SetJsonResponse(w, statusCode, ready)
+	})
+}
@@ -2,6 +2,7 @@ package helper
 
 import (
 	"bytes"
+	mailboxhelper "cargomail/cmd/mailbox/api/helper"
 	"encoding/json"
 	"io"
 	"net/http"
@@ -20,15 +21,9 @@ func ToAscii(str string) (string, error) {
 	return result, nil
 }
 
+// ReturnErr writes the error envelope of the API, the codes are those of the mailbox registry.
 func ReturnErr(w http.ResponseWriter, err error, code int) {
-	errorMessage := struct {
-		Err string
-	}{
-		Err: err.Error(),
-	}
-
-	w.WriteHeader(code)
-	json.NewEncoder(w).Encode(errorMessage)
+	mailboxhelper.ReturnErr(w, err, code)
 }
 
 func SetJsonHeader(w http.ResponseWriter) {
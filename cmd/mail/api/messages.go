@@ -22,22 +22,22 @@ func (api *MessagesApi) Post() http.Handler {
 
 		err := helper.Decoder(r.Body).Decode(&message)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
 		if message.Id == "" {
-			http.Error(w, repository.ErrMissingIdField.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, repository.ErrMissingIdField, http.StatusBadRequest)
 			return
 		}
 
 		if message.Payload == nil {
-			http.Error(w, repository.ErrMissingPayloadField.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, repository.ErrMissingPayloadField, http.StatusBadRequest)
 			return
 		}
 
 		if message.Payload.Headers == nil {
-			http.Error(w, repository.ErrMissingHeadersField.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, repository.ErrMissingHeadersField, http.StatusBadRequest)
 			return
 		}
 
@@ -4,6 +4,7 @@ import (
 	"cargomail/cmd/mail/api/helper"
 	"cargomail/internal/mailbox/repository"
 	"cargomail/internal/shared/config"
+	"cargomail/internal/shared/ratelimit"
 	"errors"
 	"net/http"
 	"strings"
@@ -13,8 +14,21 @@ import (
 )
 
 type SessionApi struct {
-	useUserRepository    repository.UseUserRepository
-	useSessionRepository repository.UseSessionRepository
+	useUserRepository         repository.UseUserRepository
+	useSessionRepository      repository.UseSessionRepository
+	useDeviceRepository       repository.UseDeviceRepository
+	useRefreshTokenRepository repository.UseRefreshTokenRepository
+	useLoginAttemptRepository repository.UseLoginAttemptRepository
+}
+
+// sessionResponse is the session with the refresh token the client exchanges for the next session.
+type sessionResponse struct {
+	*repository.Session
+	*repository.NewRefreshToken
+}
+
+type refreshInput struct {
+	RefreshToken string `json:"refreshToken"`
 }
 
 type credentials struct {
@@ -93,30 +107,106 @@ func (api *SessionApi) Login() http.Handler {
 			return
 		}
 
+		ip := ratelimit.ClientIP(r)
+		maxFailures := config.LoginMaxFailures()
+
+		if maxFailures > 0 {
+			wait, err := api.useLoginAttemptRepository.Locked(input.Username, ip)
+			if err != nil {
+				helper.ReturnErr(w, err, http.StatusInternalServerError)
+				return
+			}
+
+			if wait > 0 {
+				w.Header().Set("Retry-After", ratelimit.RetryAfter(wait))
+				helper.ReturnErr(w, repository.ErrAccountLocked, http.StatusTooManyRequests)
+				return
+			}
+		}
+
+		// the unknown username fails as the wrong password does, so the response doesn't tell it exists
 		user, err := api.useUserRepository.GetByUsername(input.Username)
+		if err != nil && !errors.Is(err, repository.ErrUsernameNotFound) {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		match := false
+
+		if user != nil {
+			match, err = user.Password.Matches(input.Password)
+			if err != nil {
+				helper.ReturnErr(w, err, http.StatusInternalServerError)
+				return
+			}
+		} else {
+			match = repository.MismatchPassword(input.Password)
+		}
+
+		if !match {
+			if maxFailures > 0 {
+				wait, err := api.useLoginAttemptRepository.Fail(input.Username, ip, maxFailures, config.LoginMaxUserFailures(), config.LoginLockout())
+				if err != nil {
+					helper.ReturnErr(w, err, http.StatusInternalServerError)
+					return
+				}
+
+				if wait > 0 {
+					w.Header().Set("Retry-After", ratelimit.RetryAfter(wait))
+					helper.ReturnErr(w, repository.ErrAccountLocked, http.StatusTooManyRequests)
+					return
+				}
+			}
+
+			helper.ReturnErr(w, repository.ErrInvalidCredentials, http.StatusForbidden)
+			return
+		}
+
+		if maxFailures > 0 {
+			err = api.useLoginAttemptRepository.Reset(input.Username, ip)
+			if err != nil {
+				helper.ReturnErr(w, err, http.StatusInternalServerError)
+				return
+			}
+		}
+
+		var deviceId string
+
+		deviceIdCookie, err := r.Cookie("deviceId")
 		if err != nil {
 			switch {
-			case errors.Is(err, repository.ErrUsernameNotFound):
-				helper.ReturnErr(w, err, http.StatusForbidden)
+			case errors.Is(err, http.ErrNoCookie):
+				deviceId = strings.Replace(uuid.NewString(), "-", "", -1)
 			default:
-				helper.ReturnErr(w, err, http.StatusInternalServerError)
+				helper.ReturnErr(w, repository.ErrInternalServer, http.StatusInternalServerError)
+				return
 			}
-			return
+		} else {
+			deviceId = deviceIdCookie.Value
+		}
+
+		ttl := config.SessionTTL()
+		session := &repository.Session{
+			UserID:   user.Id,
+			Expiry:   time.Now().Add(ttl),
+			Scope:    repository.ScopeAuthentication,
+			DeviceId: &deviceId,
 		}
 
-		match, err := user.Password.Matches(input.Password)
+		err = api.useSessionRepository.Insert(session)
 		if err != nil {
 			helper.ReturnErr(w, err, http.StatusInternalServerError)
 			return
 		}
 
-		if !match {
-			helper.ReturnErr(w, repository.ErrInvalidCredentials, http.StatusForbidden)
+		err = api.useDeviceRepository.Touch(user, deviceId, r.UserAgent())
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
 			return
 		}
 
-		ttl := config.DefaultSessionTTL
-		session, err := api.useSessionRepository.New(user.Id, ttl, repository.ScopeAuthentication)
+		// the device revoked with resync starts over
+		session.Resync, err = api.useDeviceRepository.TakeResync(user, deviceId)
 		if err != nil {
 			helper.ReturnErr(w, err, http.StatusInternalServerError)
 			return
@@ -137,34 +227,91 @@ func (api *SessionApi) Login() http.Handler {
 
 		http.SetCookie(w, &sessionCookie)
 
-		var deviceId string
+		deviceIdCookie = &http.Cookie{
+			Name:     "deviceId",
+			Value:    deviceId,
+			Path:     "/",
+			HttpOnly: true,
+			Secure:   true,
+			SameSite: config.CookieSameSite(),
+			Expires:  time.Now().AddDate(1, 0, 0), // 1 year
+		}
 
-		deviceIdCookie, err := r.Cookie("deviceId")
+		http.SetCookie(w, deviceIdCookie)
+
+		refreshToken, err := api.useRefreshTokenRepository.Create(user, deviceId, config.RefreshTokenTTL())
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, &sessionResponse{Session: session, NewRefreshToken: refreshToken})
+	})
+}
+
+// Refresh exchanges the refresh token for a new session and a new refresh token, the old token can't
+// be used again.
+func (api *SessionApi) Refresh() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var input refreshInput
+
+		err := helper.Decoder(r.Body).Decode(&input)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		refreshToken, err := api.useRefreshTokenRepository.Rotate(strings.TrimSpace(input.RefreshToken), config.RefreshTokenTTL())
 		if err != nil {
 			switch {
-			case errors.Is(err, http.ErrNoCookie):
-				deviceId = strings.Replace(uuid.NewString(), "-", "", -1)
+			case errors.Is(err, repository.ErrInvalidRefreshToken):
+				helper.ReturnErr(w, err, http.StatusUnauthorized)
 			default:
-				http.Error(w, "server error", http.StatusInternalServerError)
-				return
+				helper.ReturnErr(w, err, http.StatusInternalServerError)
 			}
-		} else {
-			deviceId = deviceIdCookie.Value
+			return
 		}
 
-		deviceIdCookie = &http.Cookie{
-			Name:     "deviceId",
-			Value:    deviceId,
+		user := &repository.User{Id: refreshToken.UserId}
+		deviceId := refreshToken.DeviceId
+
+		session := &repository.Session{
+			UserID:   user.Id,
+			Expiry:   time.Now().Add(config.SessionTTL()),
+			Scope:    repository.ScopeAuthentication,
+			DeviceId: &deviceId,
+		}
+
+		err = api.useSessionRepository.Insert(session)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		err = api.useDeviceRepository.Touch(user, deviceId, r.UserAgent())
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		session.Resync, err = api.useDeviceRepository.TakeResync(user, deviceId)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		sessionCookie := http.Cookie{
+			Name:     "sessionId",
+			Value:    session.Id,
 			Path:     "/",
 			HttpOnly: true,
 			Secure:   true,
 			SameSite: config.CookieSameSite(),
-			Expires:  time.Now().AddDate(1, 0, 0), // 1 year
 		}
 
-		http.SetCookie(w, deviceIdCookie)
+		http.SetCookie(w, &sessionCookie)
 
-		helper.SetJsonResponse(w, http.StatusOK, session)
+		helper.SetJsonResponse(w, http.StatusOK, &sessionResponse{Session: session, NewRefreshToken: refreshToken})
 	})
 }
 
@@ -191,9 +338,9 @@ func (api *SessionApi) Logout() http.Handler {
 		if err != nil {
 			switch {
 			case errors.Is(err, http.ErrNoCookie):
-				http.Error(w, "cookie not found", http.StatusBadRequest)
+				helper.ReturnErr(w, repository.ErrMissingSessionCookie, http.StatusBadRequest)
 			default:
-				http.Error(w, "server error", http.StatusInternalServerError)
+				helper.ReturnErr(w, repository.ErrInternalServer, http.StatusInternalServerError)
 			}
 			return
 		}
@@ -206,6 +353,43 @@ func (api *SessionApi) Logout() http.Handler {
 			return
 		}
 
+		if user.DeviceId != nil && len(*user.DeviceId) > 0 {
+			err = api.useRefreshTokenRepository.Revoke(user, *user.DeviceId)
+			if err != nil {
+				helper.ReturnErr(w, err, http.StatusInternalServerError)
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func (api *SessionApi) LogoutAll() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		err := api.useSessionRepository.RemoveAll(user)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		clearCookie := http.Cookie{
+			Name:     "sessionId",
+			Value:    "",
+			MaxAge:   -1,
+			Path:     "/",
+			HttpOnly: true,
+			Secure:   true,
+			SameSite: config.CookieSameSite(),
+		}
+		http.SetCookie(w, &clearCookie)
+
 		w.WriteHeader(http.StatusOK)
 	})
 }
@@ -3,12 +3,29 @@ package api
 import (
 	"cargomail/cmd/mail/api/helper"
 	"cargomail/internal/mailbox/repository"
+	"cargomail/internal/mailbox/storage"
+	"cargomail/internal/shared/config"
+	"encoding/json"
 	"errors"
 	"net/http"
 )
 
 type UserApi struct {
-	useUserRepository repository.UseUserRepository
+	useUserRepository    repository.UseUserRepository
+	useSessionRepository repository.UseSessionRepository
+	useAccountStorage    storage.UseAccountStorage
+	useBlobRepository    repository.UseBlobRepository
+	useFileRepository    repository.UseFileRepository
+}
+
+type passwordInput struct {
+	OldPassword         string `json:"oldPassword"`
+	NewPassword         string `json:"newPassword"`
+	LogoutOtherSessions bool   `json:"logoutOtherSessions"`
+}
+
+type deleteAccountInput struct {
+	Password string `json:"password"`
 }
 
 func (api *UserApi) Profile() http.Handler {
@@ -21,7 +38,7 @@ func (api *UserApi) Profile() http.Handler {
 		if r.Method == "PUT" {
 			err := helper.Decoder(r.Body).Decode(&user)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
+				helper.ReturnErr(w, err, http.StatusBadRequest)
 				return
 			}
 
@@ -31,6 +48,28 @@ func (api *UserApi) Profile() http.Handler {
 				return
 			}
 
+			helper.SetJsonResponse(w, http.StatusOK, profile)
+		} else if r.Method == "PATCH" {
+			// only the fields present are updated, unlike PUT
+			var patch repository.ProfilePatch
+
+			err := helper.Decoder(r.Body).Decode(&patch)
+			if err != nil {
+				helper.ReturnErr(w, err, http.StatusBadRequest)
+				return
+			}
+
+			profile, err := api.useUserRepository.PatchProfile(user, &patch)
+			if err != nil {
+				switch {
+				case errors.Is(err, repository.ErrUsernameNotFound):
+					helper.ReturnErr(w, err, http.StatusForbidden)
+				default:
+					helper.ReturnErr(w, err, http.StatusInternalServerError)
+				}
+				return
+			}
+
 			helper.SetJsonResponse(w, http.StatusOK, profile)
 		} else if r.Method == "GET" {
 			profile, err := api.useUserRepository.GetProfile(user.Username)
@@ -48,3 +87,181 @@ func (api *UserApi) Profile() http.Handler {
 		}
 	})
 }
+
+// Settings returns the UI settings on GET, the PATCH merges the settings of the body, a null removes the
+// setting.
+func (api *UserApi) Settings() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		var settings *repository.Settings
+		var err error
+
+		if r.Method == "PATCH" {
+			var patch map[string]json.RawMessage
+
+			err = helper.Decoder(r.Body).Decode(&patch)
+			if err != nil {
+				helper.ReturnErr(w, err, http.StatusBadRequest)
+				return
+			}
+
+			settings, err = api.useUserRepository.UpdateSettings(user, patch)
+		} else {
+			settings, err = api.useUserRepository.GetSettings(user)
+		}
+
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrInvalidSetting):
+				helper.ReturnErr(w, err, http.StatusBadRequest)
+			case errors.Is(err, repository.ErrUsernameNotFound):
+				helper.ReturnErr(w, err, http.StatusForbidden)
+			default:
+				helper.ReturnErr(w, err, http.StatusInternalServerError)
+			}
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, settings)
+	})
+}
+
+// Usage returns the storage taken by the blobs and the files of the user, with the quota if any.
+func (api *UserApi) Usage() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		profile, err := api.useUserRepository.GetProfile(user.Username)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrUsernameNotFound):
+				helper.ReturnErr(w, err, http.StatusForbidden)
+			default:
+				helper.ReturnErr(w, err, http.StatusInternalServerError)
+			}
+			return
+		}
+
+		blobs, err := api.useBlobRepository.UsageBytes(user)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		files, err := api.useFileRepository.UsageBytes(user)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, repository.NewStorageUsage(blobs, files, profile.QuotaBytes))
+	})
+}
+
+// ChangePassword sets the new password of the user, the other sessions are logged out on request.
+func (api *UserApi) ChangePassword() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		var input passwordInput
+
+		err := helper.Decoder(r.Body).Decode(&input)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		err = api.useUserRepository.ChangePassword(user, input.OldPassword, input.NewPassword)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrWeakPassword):
+				helper.ReturnErr(w, err, http.StatusBadRequest)
+			case errors.Is(err, repository.ErrInvalidCredentials):
+				helper.ReturnErr(w, err, http.StatusForbidden)
+			default:
+				helper.ReturnErr(w, err, http.StatusInternalServerError)
+			}
+			return
+		}
+
+		if input.LogoutOtherSessions {
+			// the Authenticate middleware checked the cookie
+			sessionCookie, err := r.Cookie("sessionId")
+			if err != nil {
+				helper.ReturnErr(w, repository.ErrMissingSessionCookie, http.StatusBadRequest)
+				return
+			}
+
+			err = api.useSessionRepository.RemoveOthers(user, sessionCookie.Value)
+			if err != nil {
+				helper.ReturnErr(w, err, http.StatusInternalServerError)
+				return
+			}
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, map[string]string{"status": "OK"})
+	})
+}
+
+// DeleteAccount removes the account and all its data, the password is confirmed first.
+func (api *UserApi) DeleteAccount() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		var input deleteAccountInput
+
+		err := helper.Decoder(r.Body).Decode(&input)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		match, err := user.Password.Matches(input.Password)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		if !match {
+			helper.ReturnErr(w, repository.ErrInvalidCredentials, http.StatusForbidden)
+			return
+		}
+
+		err = api.useAccountStorage.Delete(user)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		for _, name := range []string{"sessionId", "deviceId"} {
+			http.SetCookie(w, &http.Cookie{
+				Name:     name,
+				Value:    "",
+				MaxAge:   -1,
+				Path:     "/",
+				HttpOnly: true,
+				Secure:   true,
+				SameSite: config.CookieSameSite(),
+			})
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, map[string]string{"status": "OK"})
+	})
+}
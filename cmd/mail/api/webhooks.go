@@ -0,0 +1,112 @@
+package api
+
+import (
+	"cargomail/cmd/mail/api/helper"
+	"cargomail/internal/mailbox/repository"
+	"errors"
+	"net/http"
+)
+
+type WebhooksApi struct {
+	useWebhookRepository repository.UseWebhookRepository
+}
+
+type webhookInput struct {
+	Url       string   `json:"url"`
+	Resources []string `json:"resources"`
+}
+
+// Create registers the webhook, e.g. {"url": "https://example.com/hook", "resources": ["contacts"]}, the
+// resources left out are all of them. The secret the deliveries are signed by is returned this once.
+func (api *WebhooksApi) Create() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		var input webhookInput
+
+		err := helper.Decoder(r.Body).Decode(&input)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		webhook := &repository.Webhook{
+			Url:       input.Url,
+			Resources: input.Resources,
+		}
+
+		newWebhook, err := api.useWebhookRepository.Create(user, webhook)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrInvalidWebhookUrl),
+				errors.Is(err, repository.ErrUnknownResource):
+				helper.ReturnErr(w, err, http.StatusBadRequest)
+			case errors.Is(err, repository.ErrTooManyWebhooks):
+				helper.ReturnErr(w, err, http.StatusConflict)
+			default:
+				helper.ReturnErr(w, err, http.StatusInternalServerError)
+			}
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusCreated, newWebhook)
+	})
+}
+
+func (api *WebhooksApi) List() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		webhooks, err := api.useWebhookRepository.List(user)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, webhooks)
+	})
+}
+
+func (api *WebhooksApi) Delete() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		var id repository.Id
+
+		err := helper.Decoder(r.Body).Decode(&id)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		if len(id.Id) == 0 {
+			helper.ReturnErr(w, repository.ErrMissingIdField, http.StatusBadRequest)
+			return
+		}
+
+		err = api.useWebhookRepository.Delete(user, id.Id)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrWebhookNotFound):
+				helper.ReturnErr(w, err, http.StatusNotFound)
+			default:
+				helper.ReturnErr(w, err, http.StatusInternalServerError)
+			}
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, map[string]string{"status": "OK"})
+	})
+}
@@ -4,7 +4,9 @@ import (
 	"cargomail/cmd/mail/api"
 	"cargomail/cmd/mail/app"
 	"cargomail/internal/mailbox/repository"
+	"cargomail/internal/mailbox/storage"
 	"cargomail/internal/shared/config"
+	"cargomail/internal/shared/ratelimit"
 	"context"
 	"database/sql"
 	"embed"
@@ -30,12 +32,14 @@ type ServiceParams struct {
 }
 
 type service struct {
-	app app.App
-	api api.Api
+	app        app.App
+	api        api.Api
+	rateLimits *ratelimit.Limits
 }
 
 func NewService(params *ServiceParams) (service, error) {
 	repository := repository.NewRepository(params.DB)
+	storage := storage.NewStorage(repository)
 	return service{
 		app: app.NewApp(
 			app.AppParams{
@@ -45,12 +49,15 @@ func NewService(params *ServiceParams) (service, error) {
 		api: api.NewApi(
 			api.ApiParams{
 				Repository: repository,
+				Storage:    storage,
 			}),
+		rateLimits: ratelimit.NewConfiguredLimits(),
 	}, nil
 }
 
 func (svc *service) Serve(ctx context.Context, errs *errgroup.Group) {
 	mssRouter := NewRouter()
+	mssRouter.rateLimits = svc.rateLimits
 
 	svc.routes(mssRouter)
 
@@ -75,12 +82,17 @@ func (svc *service) Serve(ctx context.Context, errs *errgroup.Group) {
 	mssHttp1ServerTLS := &http.Server{Handler: mssRouter, Addr: config.Configuration.MSSBindTLS}
 
 	mhsRouter := NewRouter()
+	mhsRouter.rateLimits = svc.rateLimits
 
 	svc.routes(mhsRouter)
 
 	mhsHttp1Server := &http.Server{Handler: mhsRouter, Addr: config.Configuration.MHSBind}
 	mhsHttp1ServerTLS := &http.Server{Handler: mhsRouter, Addr: config.Configuration.MHSBindTLS}
 
+	errs.Go(func() error {
+		return svc.rateLimits.Sweep(ctx)
+	})
+
 	errs.Go(func() error {
 		<-ctx.Done()
 		gracefulStop, cancelShutdown := context.WithTimeout(context.Background(), 10*time.Second)
@@ -0,0 +1,28 @@
+package mail
+
+import (
+	"cargomail/cmd/mail/api/helper"
+	"cargomail/internal/mailbox/repository"
+	"cargomail/internal/shared/provider"
+	"net/http"
+	"runtime/debug"
+)
+
+// recoverPanic answers the request whose handler panicked with 500 instead of dropping the connection,
+// the stack is logged with the request id. It is deferred first thing in the router, so the panics of
+// the middleware are caught too.
+func recoverPanic(w http.ResponseWriter, r *http.Request) {
+	err := recover()
+	if err == nil {
+		return
+	}
+
+	// the handler aborted the response on purpose
+	if err == http.ErrAbortHandler {
+		panic(err)
+	}
+
+	provider.Logf(r.Context(), "panic serving %s %s: %v\n%s", r.Method, r.URL.Path, err, debug.Stack())
+
+	helper.ReturnErr(w, repository.ErrInternalServer, http.StatusInternalServerError)
+}
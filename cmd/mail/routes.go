@@ -1,7 +1,11 @@
 package mail
 
 import (
+	"cargomail/cmd/mail/api/helper"
+	"cargomail/internal/mailbox/repository"
 	"cargomail/internal/shared/config"
+	"cargomail/internal/shared/provider"
+	"cargomail/internal/shared/ratelimit"
 	"net/http"
 	"strings"
 )
@@ -13,7 +17,11 @@ type Entry struct {
 }
 
 type Router struct {
-	routes []Entry
+	routes     []Entry
+	rateLimits *ratelimit.Limits
+
+	notFound         http.Handler
+	methodNotAllowed http.Handler
 }
 
 func NewRouter() *Router { return new(Router) }
@@ -28,11 +36,26 @@ func (t *Router) Route(method, path string, handler http.Handler) {
 	t.routes = append(t.routes, e)
 }
 
+// NotFound sets the handler of the requests no route matches, http.NotFound by default.
+func (t *Router) NotFound(handler http.Handler) {
+	t.notFound = handler
+}
+
+// MethodNotAllowed sets the handler of the requests a route matches the path of, but not the method.
+// The Allow header lists the methods of the path. Unset, the request is not found.
+func (t *Router) MethodNotAllowed(handler http.Handler) {
+	t.methodNotAllowed = handler
+}
+
 func (e *Entry) Match(r *http.Request) bool {
 	if r.Method != "OPTIONS" && r.Method != e.Method {
 		return false
 	}
 
+	return e.MatchPath(r)
+}
+
+func (e *Entry) MatchPath(r *http.Request) bool {
 	urlPath := r.URL.Path
 
 	if !strings.HasPrefix(urlPath, "/snippets/") {
@@ -54,10 +77,15 @@ func setupCORS(w *http.ResponseWriter, r *http.Request) {
 	(*w).Header().Set("Access-Control-Allow-Origin", r.Header.Get("Origin"))
 	(*w).Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, PATCH, DELETE, HEAD")
 	(*w).Header().Set("Access-Control-Allow-Credentials", "true")
-	(*w).Header().Set("Access-Control-Allow-Headers", "Original-Subject, Origin, X-Requested-With, Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Warning")
+	(*w).Header().Set("Access-Control-Allow-Headers", "Original-Subject, Origin, X-Requested-With, Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Request-Id, X-Warning")
+	(*w).Header().Set("Access-Control-Expose-Headers", "X-Request-Id")
 }
 
 func (t *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w, r = provider.WithRequestID(w, r)
+	defer provider.LogServerError(w, r)
+	defer recoverPanic(w, r)
+
 	for _, e := range t.routes {
 		match := e.Match(r)
 		if !match {
@@ -69,10 +97,18 @@ func (t *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		if t.rateLimits != nil {
+			if ok, wait := t.rateLimits.Allow(r); !ok {
+				w.Header().Set("Retry-After", ratelimit.RetryAfter(wait))
+				helper.ReturnErr(w, repository.ErrRateLimited, http.StatusTooManyRequests)
+				return
+			}
+		}
+
 		urlPath := r.URL.Path
 
 		if strings.HasSuffix(urlPath, "/upload") {
-			r.Body = http.MaxBytesReader(w, r.Body, config.DefaultMaxUploadSize<<20)
+			r.Body = http.MaxBytesReader(w, r.Body, config.MaxUploadBytes()+config.DefaultMaxBodySize<<20)
 		} else {
 			r.Body = http.MaxBytesReader(w, r.Body, config.DefaultMaxBodySize<<20)
 		}
@@ -87,6 +123,31 @@ func (t *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	t.serveUnmatched(w, r)
+}
+
+func (t *Router) serveUnmatched(w http.ResponseWriter, r *http.Request) {
+	if t.methodNotAllowed != nil {
+		var allowed []string
+
+		for _, e := range t.routes {
+			if e.MatchPath(r) {
+				allowed = append(allowed, e.Method)
+			}
+		}
+
+		if len(allowed) > 0 {
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+			t.methodNotAllowed.ServeHTTP(w, r)
+			return
+		}
+	}
+
+	if t.notFound != nil {
+		t.notFound.ServeHTTP(w, r)
+		return
+	}
+
 	http.NotFound(w, r)
 }
 
@@ -101,6 +162,7 @@ func (svc *service) routes(r *Router) {
 	// Health API
 	r.Route("GET", "/api/v1/health", svc.api.Health.Healthcheck())
 	r.Route("POST", "/api/v1/health", svc.api.Health.Healthcheck())
+	r.Route("GET", "/api/v1/health/ready", svc.api.Health.Ready())
 
 	// Auth API
 	r.Route("GET", "/api/v1/auth/info", svc.api.Auth.Info())
@@ -108,10 +170,28 @@ func (svc *service) routes(r *Router) {
 	r.Route("POST", "/api/v1/auth/register", svc.api.User.Register())
 	r.Route("POST", "/api/v1/auth/authenticate", svc.api.Session.Login())
 	r.Route("POST", "/api/v1/auth/logout", svc.api.Authenticate(svc.api.Session.Logout()))
+	r.Route("POST", "/api/v1/auth/refresh", svc.api.Session.Refresh())
+	r.Route("POST", "/api/v1/auth/logout-all", svc.api.Authenticate(svc.api.Session.LogoutAll()))
 
 	// User API
+	r.Route("DELETE", "/api/v1/user", svc.api.Authenticate(svc.api.User.DeleteAccount()))
 	r.Route("PUT", "/api/v1/user/profile", svc.api.Authenticate(svc.api.User.Profile()))
+	r.Route("PATCH", "/api/v1/user/profile", svc.api.Authenticate(svc.api.User.Profile()))
 	r.Route("GET", "/api/v1/user/profile", svc.api.Authenticate(svc.api.User.Profile()))
+	r.Route("GET", "/api/v1/user/settings", svc.api.Authenticate(svc.api.User.Settings()))
+	r.Route("PATCH", "/api/v1/user/settings", svc.api.Authenticate(svc.api.User.Settings()))
+	r.Route("GET", "/api/v1/user/usage", svc.api.Authenticate(svc.api.User.Usage()))
+	r.Route("GET", "/api/v1/user/export", svc.api.Authenticate(svc.api.Export.Export()))
+	r.Route("POST", "/api/v1/user/password", svc.api.Authenticate(svc.api.User.ChangePassword()))
+	r.Route("POST", "/api/v1/user/apikeys", svc.api.Authenticate(svc.api.ApiKeys.Create()))
+	r.Route("GET", "/api/v1/user/apikeys", svc.api.Authenticate(svc.api.ApiKeys.List()))
+	r.Route("DELETE", "/api/v1/user/apikeys", svc.api.Authenticate(svc.api.ApiKeys.Revoke()))
+	r.Route("POST", "/api/v1/user/webhooks", svc.api.Authenticate(svc.api.Webhooks.Create()))
+	r.Route("GET", "/api/v1/user/webhooks", svc.api.Authenticate(svc.api.Webhooks.List()))
+	r.Route("DELETE", "/api/v1/user/webhooks", svc.api.Authenticate(svc.api.Webhooks.Delete()))
+	r.Route("GET", "/api/v1/user/devices", svc.api.Authenticate(svc.api.Devices.List()))
+	r.Route("DELETE", "/api/v1/user/devices/", svc.api.Authenticate(svc.api.Devices.Revoke()))
+	r.Route("GET", "/api/v1/user/audit-log", svc.api.Authenticate(svc.api.AuditLog.List()))
 
 	// Messages API
 	// r.Route("POST", "/api/v1/messages/post", svc.api.Authenticate(svc.api.Messages.Post()))
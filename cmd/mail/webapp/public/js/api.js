@@ -101,8 +101,9 @@ const api = async (parentId, status, url, options) => {
     }
   } catch (error) {
     let errMessage = "unknown error";
-    if (error != null && "response" in error && error.response != null && error.response.Err) {
-      errMessage = error.response.Err.charAt(0).toUpperCase() + error.response.Err.slice(1);
+    if (error != null && "response" in error && error.response != null && error.response.error) {
+      const message = error.response.error.message;
+      errMessage = message.charAt(0).toUpperCase() + message.slice(1);
     } else if (error != null) {
       errMessage = error.message;
     }
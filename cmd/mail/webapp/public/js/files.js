@@ -356,6 +356,17 @@ export const filesTableRefresh = (data) => {
     }
   }
 
+  for (const file of data.updated) {
+    if (file.folder == 0) {
+      const notFound = filesTable.column(0).data().toArray().indexOf(file.id) === -1; // !!! must be
+      if (notFound) {
+        filesTable.row.add(file);
+      } else {
+        filesTable.row(`#${file.id}`).data(file);
+      }
+    }
+  }
+
   for (const file of data.trashed) {
     if (file.folder == 0) {
       filesTable.row(`#${file.id}`).remove();
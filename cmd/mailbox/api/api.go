@@ -6,9 +6,13 @@ import (
 	"cargomail/internal/mailbox/repository"
 	"cargomail/internal/mailbox/storage"
 	"cargomail/internal/shared/config"
+	"cargomail/internal/shared/ratelimit"
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -16,51 +20,140 @@ type ApiParams struct {
 	Repository repository.Repository
 	Storage    storage.Storage
 	Agent      agent.Agent
+	UserLimits *ratelimit.Limiter // nil = unlimited
+	Events     *EventBroker
 }
 
 type Api struct {
-	Health   HealthApi
-	Blobs    BlobsApi
-	Files    FilesApi
-	Auth     AuthApi
-	Session  SessionApi
-	User     UserApi
-	Contacts ContactsApi
-	Drafts   DraftsApi
-	Messages MessagesApi
-	Threads  ThreadsApi
+	Health      HealthApi
+	Blobs       BlobsApi
+	Files       FilesApi
+	Uploads     UploadsApi
+	Auth        AuthApi
+	Session     SessionApi
+	User        UserApi
+	Contacts    ContactsApi
+	Drafts      DraftsApi
+	Messages    MessagesApi
+	Labels      LabelsApi
+	Threads     ThreadsApi
+	Sync        SyncApi
+	Stream      StreamApi
+	Search      SearchApi
+	Idempotency IdempotencyApi
+	Jmap        JmapApi
+	Federation  FederationApi
+	userLimits  *ratelimit.Limiter
+
+	useEventRepository repository.UseEventRepository
 }
 
 func NewApi(params ApiParams) Api {
 	return Api{
-		Health:   HealthApi{},
-		Blobs:    BlobsApi{useBlobRepository: params.Repository.Blobs, useBlobStorage: params.Storage.Blobs},
-		Files:    FilesApi{useFileRepository: params.Repository.Files, useFileStorage: params.Storage.Files},
-		Auth:     AuthApi{},
-		Session:  SessionApi{useUserRepository: params.Repository.User, useSessionRepository: params.Repository.Session},
-		User:     UserApi{useUserRepository: params.Repository.User},
-		Contacts: ContactsApi{useContactRepository: params.Repository.Contacts},
-		Drafts:   DraftsApi{useDraftRepository: params.Repository.Drafts, useDraftStorage: params.Storage.Drafts, useMessageSubmissionAgent: params.Agent.MessageSubmission},
-		Messages: MessagesApi{useMessageRepository: params.Repository.Messages, useMessageStorage: params.Storage.Messages, useMessageSubmissionAgent: params.Agent.MessageSubmission},
-		Threads:  ThreadsApi{useThreadRepository: params.Repository.Threads},
+		Health:      HealthApi{useHealthRepository: params.Repository.Health, useHealthStorage: params.Storage.Health},
+		Blobs:       BlobsApi{useBlobRepository: params.Repository.Blobs, useBlobStorage: params.Storage.Blobs},
+		Files:       FilesApi{useFileRepository: params.Repository.Files, useFileStorage: params.Storage.Files},
+		Uploads:     UploadsApi{useUploadStorage: params.Storage.Uploads},
+		Auth:        AuthApi{},
+		Session:     SessionApi{useUserRepository: params.Repository.User, useSessionRepository: params.Repository.Session, useApiKeyRepository: params.Repository.ApiKeys, useDeviceRepository: params.Repository.Devices},
+		User:        UserApi{useUserRepository: params.Repository.User},
+		Contacts:    ContactsApi{useContactRepository: params.Repository.Contacts},
+		Drafts:      DraftsApi{useDraftRepository: params.Repository.Drafts, useDraftStorage: params.Storage.Drafts, useMessageSubmissionAgent: params.Agent.MessageSubmission, useSmtpSubmissionAgent: params.Agent.SmtpSubmission},
+		Messages:    MessagesApi{useMessageRepository: params.Repository.Messages, useMessageStorage: params.Storage.Messages, useMessageSubmissionAgent: params.Agent.MessageSubmission},
+		Labels:      LabelsApi{useLabelRepository: params.Repository.Labels},
+		Threads:     ThreadsApi{useThreadRepository: params.Repository.Threads},
+		Sync:        SyncApi{useContactRepository: params.Repository.Contacts, useBlobRepository: params.Repository.Blobs, useFileRepository: params.Repository.Files, useDraftStorage: params.Storage.Drafts, useMessageStorage: params.Storage.Messages, useLabelRepository: params.Repository.Labels, useSavedSearchRepository: params.Repository.SavedSearches},
+		Search:      SearchApi{useContactRepository: params.Repository.Contacts, useBlobRepository: params.Repository.Blobs, useSavedSearchRepository: params.Repository.SavedSearches, useDraftStorage: params.Storage.Drafts, useMessageStorage: params.Storage.Messages},
+		Stream:      StreamApi{useEventRepository: params.Repository.Events, broker: params.Events},
+		Idempotency: IdempotencyApi{useIdempotencyRepository: params.Repository.Idempotency},
+		Jmap:        JmapApi{useMessageRepository: params.Repository.Messages, useDraftRepository: params.Repository.Drafts, useMessageStorage: params.Storage.Messages, useDraftStorage: params.Storage.Drafts, useEventRepository: params.Repository.Events},
+		Federation:  FederationApi{useFederationRepository: params.Repository.Federation},
+		userLimits:  params.UserLimits,
+
+		useEventRepository: params.Repository.Events,
 	}
 }
 
+// contextSetUser sets the user of the request. The request with the X-Read-Primary header, e.g. right
+// after a write, reads from the primary database even when there is a replica.
 func (api *Api) contextSetUser(r *http.Request, user *repository.User) *http.Request {
+	user.ReadPrimary, _ = strconv.ParseBool(r.Header.Get("X-Read-Primary"))
+
 	ctx := context.WithValue(r.Context(), repository.UserContextKey, user)
 	return r.WithContext(ctx)
 }
 
+// middleware
+func (api *Api) RequireScope(scope string, next http.Handler) http.Handler {
+	if !repository.ValidScope(scope) {
+		panic("unknown scope " + scope)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		if !user.HasScope(scope) {
+			w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+scope+`"`)
+			helper.ReturnErr(w, fmt.Errorf("%w: %s required", repository.ErrInsufficientScope, scope), http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allowUser takes a token of the user from the user rate limiter, or answers the request with 429.
+func (api *Api) allowUser(w http.ResponseWriter, user *repository.User) bool {
+	ok, wait := api.userLimits.Allow(strconv.FormatInt(user.Id, 10), time.Now())
+	if !ok {
+		w.Header().Set("Retry-After", ratelimit.RetryAfter(wait))
+		helper.ReturnErr(w, repository.ErrRateLimited, http.StatusTooManyRequests)
+	}
+
+	return ok
+}
+
 // middleware
 func (api *Api) Authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// API keys authenticate without a session
+		if authorization := r.Header.Get("Authorization"); len(authorization) > 0 {
+			key, ok := strings.CutPrefix(authorization, "Bearer ")
+			if !ok {
+				helper.ReturnErr(w, repository.ErrInvalidApiKey, http.StatusUnauthorized)
+				return
+			}
+
+			user, err := api.Session.useApiKeyRepository.GetUserByKey(strings.TrimSpace(key))
+			if err != nil {
+				switch {
+				case errors.Is(err, repository.ErrInvalidApiKey):
+					helper.ReturnErr(w, err, http.StatusUnauthorized)
+				default:
+					helper.ReturnErr(w, err, http.StatusInternalServerError)
+				}
+				return
+			}
+
+			if !api.allowUser(w, user) {
+				return
+			}
+
+			next.ServeHTTP(w, api.contextSetUser(r, user))
+			return
+		}
+
 		sessionCookie, err := r.Cookie("sessionId")
 		if err != nil {
 			switch {
 			case errors.Is(err, http.ErrNoCookie):
-				http.Error(w, "cookie not found", http.StatusBadRequest)
+				helper.ReturnErr(w, repository.ErrMissingSessionCookie, http.StatusBadRequest)
 			default:
-				http.Error(w, "server error", http.StatusInternalServerError)
+				helper.ReturnErr(w, repository.ErrInternalServer, http.StatusInternalServerError)
 			}
 			return
 		}
@@ -92,7 +185,7 @@ func (api *Api) Authenticate(next http.Handler) http.Handler {
 			case errors.Is(err, http.ErrNoCookie):
 				// nothing to do
 			default:
-				http.Error(w, "server error", http.StatusInternalServerError)
+				helper.ReturnErr(w, repository.ErrInternalServer, http.StatusInternalServerError)
 				return
 			}
 		} else {
@@ -101,11 +194,23 @@ func (api *Api) Authenticate(next http.Handler) http.Handler {
 
 		user.DeviceId = &deviceId
 
+		if len(deviceId) > 0 {
+			err = api.Session.useDeviceRepository.Touch(user, deviceId, r.UserAgent())
+			if err != nil {
+				helper.ReturnErr(w, err, http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if !api.allowUser(w, user) {
+			return
+		}
+
 		r = api.contextSetUser(r, user)
 
 		// refresh sessionId/deviceId cookies
 
-		ttl := config.DefaultSessionTTL
+		ttl := config.SessionTTL()
 		sessionCookie.Expires = time.Now().Add(ttl)
 		sessionCookie.Path = "/"
 
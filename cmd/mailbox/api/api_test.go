@@ -0,0 +1,61 @@
+package api
+
+import (
+	"cargomail/internal/mailbox/repository"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequireScope(t *testing.T) {
+	api := &Api{}
+
+	handler := api.RequireScope("contacts:write", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name   string
+		user   *repository.User
+		status int
+	}{
+		{"session", &repository.User{}, http.StatusNoContent},
+		{"key of the scope", &repository.User{Scopes: []string{"contacts:read", "contacts:write"}}, http.StatusNoContent},
+		{"key of another scope", &repository.User{Scopes: []string{"contacts:read"}}, http.StatusForbidden},
+		{"no user", nil, http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/contacts", nil)
+			if tt.user != nil {
+				r = r.WithContext(context.WithValue(r.Context(), repository.UserContextKey, tt.user))
+			}
+
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("got %d, want %d", w.Code, tt.status)
+			}
+
+			if tt.status == http.StatusForbidden &&
+				!strings.Contains(w.Header().Get("WWW-Authenticate"), `scope="contacts:write"`) {
+				t.Errorf("got the WWW-Authenticate %q", w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestRequireUnknownScope(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("the unknown scope accepted")
+		}
+	}()
+
+	(&Api{}).RequireScope("contacts:delete", http.NotFoundHandler())
+}
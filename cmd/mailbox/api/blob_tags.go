@@ -0,0 +1,92 @@
+package api
+
+import (
+	"cargomail/cmd/mailbox/api/helper"
+	"cargomail/internal/mailbox/repository"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+type tagInput struct {
+	Tag string `json:"tag"`
+}
+
+// blobIdFromPath parses the id of the /api/v1/blobs/{id}/{subresource} path
+func blobIdFromPath(path string, subresource string) (string, bool) {
+	id, ok := strings.CutSuffix(strings.TrimPrefix(path, "/api/v1/blobs/"), "/"+subresource)
+	if !ok || len(id) == 0 || strings.Contains(id, "/") {
+		return "", false
+	}
+
+	return id, true
+}
+
+// AddTag serves the POST /api/v1/blobs/{id}/tags, e.g. {"tag": "invoices"}
+func (api *BlobsApi) AddTag() http.Handler {
+	return api.modifyTags(api.useBlobRepository.AddTag)
+}
+
+// RemoveTag serves the DELETE /api/v1/blobs/{id}/tags
+func (api *BlobsApi) RemoveTag() http.Handler {
+	return api.modifyTags(api.useBlobRepository.RemoveTag)
+}
+
+func (api *BlobsApi) modifyTags(modify func(user *repository.User, id string, tag string) (*repository.BlobTags, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		id, ok := blobIdFromPath(r.URL.Path, "tags")
+		if !ok {
+			helper.ReturnErr(w, repository.ErrNotFound, http.StatusNotFound)
+			return
+		}
+
+		var input tagInput
+
+		err := helper.Decoder(r.Body).Decode(&input)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		tags, err := modify(user, id, input.Tag)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrBlobNotFound):
+				helper.ReturnErr(w, err, http.StatusNotFound)
+			case errors.Is(err, repository.ErrMissingTagField),
+				errors.Is(err, repository.ErrBlobWrongTag):
+				helper.ReturnErr(w, err, http.StatusBadRequest)
+			default:
+				helper.ReturnErr(w, err, http.StatusInternalServerError)
+			}
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, tags)
+	})
+}
+
+// ListTags lists the tags of the tagged blobs, the blobs of a tag are listed by the ?tag= of the List.
+func (api *BlobsApi) ListTags() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		tags, err := api.useBlobRepository.ListTags(user)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, tags)
+	})
+}
@@ -9,11 +9,9 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"os"
 	"path"
-	"path/filepath"
-
-	"github.com/google/uuid"
+	"strconv"
+	"strings"
 )
 
 type BlobsApi struct {
@@ -31,13 +29,19 @@ func (api *BlobsApi) Upload() http.Handler {
 
 		err := r.ParseMultipartForm(32 << 20)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			returnMultipartErr(w, err)
 			return
 		}
 
 		uploadedBlobs := []*repository.Blob{}
 
 		files := r.MultipartForm.File["blobs"]
+
+		err = checkUploadSizes(files)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusRequestEntityTooLarge)
+			return
+		}
 		for i := range files {
 			file, err := files[i].Open()
 			if err != nil {
@@ -46,23 +50,16 @@ func (api *BlobsApi) Upload() http.Handler {
 			}
 			defer file.Close()
 
-			blobsPath := filepath.Join(config.Configuration.ResourcesPath, config.Configuration.BlobsFolder)
-
-			if _, err := os.Stat(blobsPath); errors.Is(err, os.ErrNotExist) {
-				err := os.MkdirAll(blobsPath, os.ModePerm)
-				if err != nil {
+			uploadedBlob, err := api.useBlobStorage.Store(user, file, files[i].Filename, files[i].Header.Get("content-type"))
+			if err != nil {
+				switch {
+				case errors.Is(err, repository.ErrQuotaExceeded):
+					helper.ReturnErr(w, err, http.StatusRequestEntityTooLarge)
+				case errors.Is(err, repository.ErrInvalidImage):
+					helper.ReturnErr(w, err, http.StatusBadRequest)
+				default:
 					helper.ReturnErr(w, err, http.StatusInternalServerError)
-					return
 				}
-			}
-
-			uuid := uuid.NewString()
-
-			uploadedBlob := &repository.Blob{}
-
-			uploadedBlob, err = api.useBlobStorage.Store(user, file, blobsPath, uuid, files[i].Filename, files[i].Header.Get("content-type"))
-			if err != nil {
-				helper.ReturnErr(w, err, http.StatusInternalServerError)
 				return
 			}
 
@@ -100,22 +97,49 @@ func (api *BlobsApi) Download() http.Handler {
 			return
 		}
 
-		if r.Method == "HEAD" {
-			w.WriteHeader(http.StatusOK)
-		} else if r.Method == "GET" {
-			blobsPath := filepath.Join(config.Configuration.ResourcesPath, config.Configuration.BlobsFolder)
-			blobPath := filepath.Join(blobsPath, digest)
+		// the content of a digest never changes, so the digest is the strong ETag
+		modTime := blob.CreatedAt.Time()
+		if blob.ModifiedAt != nil {
+			modTime = blob.ModifiedAt.Time()
+		}
+
+		if notModified(w, r, `"`+blob.Digest+`"`, modTime) {
+			return
+		}
 
-			w.Header().Set("Content-Type", blob.ContentType)
-			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q; filename*=UTF-8''%s", digest, digest))
+		w.Header().Set("Content-Type", blob.ContentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q; filename*=UTF-8''%s", digest, digest))
+		w.Header().Set("Accept-Ranges", "bytes")
 
-			blobPath = filepath.Clean(blobPath)
+		// the HEAD and the range requests are served from the seekable content, a whole download
+		// is checked against the digest before it is written
+		if r.Method == "HEAD" || len(r.Header.Get("Range")) > 0 {
+			if config.VerifyDownloads() || r.URL.Query().Get("verify") == "1" {
+				err = api.useBlobStorage.Verify(blob)
+				if err != nil {
+					helper.ReturnErr(w, err, http.StatusInternalServerError)
+					return
+				}
+			}
 
-			err = api.useBlobStorage.Load(w, blob, blobPath)
+			content, err := api.useBlobStorage.Open(blob)
 			if err != nil {
 				helper.ReturnErr(w, err, http.StatusInternalServerError)
 				return
 			}
+			defer content.Close()
+
+			http.ServeContent(w, r, "", modTime, content)
+			return
+		}
+
+		w.Header().Set("Content-Length", strconv.FormatInt(blob.Size, 10))
+
+		err = api.useBlobStorage.Load(w, blob)
+		if err != nil {
+			w.Header().Del("Content-Length")
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
 		}
 	})
 }
@@ -128,17 +152,102 @@ func (api *BlobsApi) List() http.Handler {
 			return
 		}
 
+		options, err := listOptions(r)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
 		var folder repository.Folder
 
-		err := helper.Decoder(r.Body).Decode(&folder)
+		err = helper.Decoder(r.Body).Decode(&folder)
 		if err != nil {
 			if err.Error() != "EOF" {
-				http.Error(w, err.Error(), http.StatusBadRequest)
+				helper.ReturnErr(w, err, http.StatusBadRequest)
+				return
+			}
+		}
+
+		if acceptsNDJSON(r) {
+			streamList(w, r, func(each func(*repository.Blob) error) (*listTrailer, error) {
+				blobList, err := api.useBlobRepository.ListEach(user, folder.Folder, options, each)
+				if err != nil {
+					return nil, err
+				}
+				return &listTrailer{History: blobList.History}, nil
+			})
+			return
+		}
+
+		blobList, err := api.useBlobRepository.List(user, folder.Folder, options)
+		if err != nil {
+			if isListOptionsErr(err) {
+				helper.ReturnErr(w, err, http.StatusBadRequest)
+				return
+			}
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, blobList)
+	})
+}
+
+func (api *BlobsApi) Count() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		count, err := api.useBlobRepository.Count(user)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, count)
+	})
+}
+
+func (api *BlobsApi) Search() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		options, err := listOptions(r)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		blobs, err := api.useBlobRepository.Search(user, r.URL.Query().Get("q"), options)
+		if err != nil {
+			if isListOptionsErr(err) || errors.Is(err, repository.ErrMissingSearchQuery) {
+				helper.ReturnErr(w, err, http.StatusBadRequest)
 				return
 			}
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, blobs)
+	})
+}
+
+func (api *BlobsApi) ListTrashed() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
 		}
 
-		blobList, err := api.useBlobRepository.List(user, folder.Folder)
+		blobList, err := api.useBlobRepository.ListTrashed(user)
 		if err != nil {
 			helper.ReturnErr(w, err, http.StatusInternalServerError)
 			return
@@ -148,6 +257,69 @@ func (api *BlobsApi) List() http.Handler {
 	})
 }
 
+type blobRenameInput struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Update renames the blob, the content is replaced by the upload.
+func (api *BlobsApi) Update() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		var input blobRenameInput
+
+		err := helper.Decoder(r.Body).Decode(&input)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		if len(input.Id) == 0 {
+			helper.ReturnErr(w, repository.ErrMissingIdField, http.StatusBadRequest)
+			return
+		}
+
+		blob, err := api.useBlobRepository.GetById(user, input.Id)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		if len(blob.Id) == 0 {
+			helper.ReturnErr(w, repository.ErrBlobNotFound, http.StatusNotFound)
+			return
+		}
+
+		// the Update keeps the name when none is given, the rename needs one
+		if len(strings.TrimSpace(input.Name)) == 0 {
+			helper.ReturnErr(w, repository.ErrBlobWrongName, http.StatusBadRequest)
+			return
+		}
+
+		blob.Name = input.Name
+
+		blob, err = api.useBlobRepository.Update(user, blob)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrBlobNotFound):
+				helper.ReturnErr(w, err, http.StatusNotFound)
+			case errors.Is(err, repository.ErrBlobWrongName):
+				helper.ReturnErr(w, err, http.StatusBadRequest)
+			default:
+				helper.ReturnErr(w, err, http.StatusInternalServerError)
+			}
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, blob)
+	})
+}
+
 func (api *BlobsApi) Sync() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
@@ -160,13 +332,13 @@ func (api *BlobsApi) Sync() http.Handler {
 
 		err := helper.Decoder(r.Body).Decode(&history)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
 		blobSync, err := api.useBlobRepository.Sync(user, history)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
 			return
 		}
 
@@ -186,19 +358,19 @@ func (api *BlobsApi) Trash() http.Handler {
 
 		err := helper.Decoder(r.Body).Decode(&ids)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
 		if ids.Ids == nil {
-			http.Error(w, repository.ErrMissingIdsField.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, repository.ErrMissingIdsField, http.StatusBadRequest)
 			return
 		}
 
 		// back to body
 		body, err := json.Marshal(ids)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
@@ -206,7 +378,7 @@ func (api *BlobsApi) Trash() http.Handler {
 
 		err = api.useBlobRepository.Trash(user, idsString)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
 			return
 		}
 
@@ -226,19 +398,19 @@ func (api *BlobsApi) Untrash() http.Handler {
 
 		err := helper.Decoder(r.Body).Decode(&ids)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
 		if ids.Ids == nil {
-			http.Error(w, repository.ErrMissingIdsField.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, repository.ErrMissingIdsField, http.StatusBadRequest)
 			return
 		}
 
 		// back to body
 		body, err := json.Marshal(ids)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
@@ -246,7 +418,7 @@ func (api *BlobsApi) Untrash() http.Handler {
 
 		err = api.useBlobRepository.Untrash(user, idsString)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
 			return
 		}
 
@@ -266,37 +438,47 @@ func (api *BlobsApi) Delete() http.Handler {
 
 		err := helper.Decoder(r.Body).Decode(&ids)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
 		if ids.Ids == nil {
-			http.Error(w, repository.ErrMissingIdsField.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, repository.ErrMissingIdsField, http.StatusBadRequest)
 			return
 		}
 
 		// back to body
 		body, err := json.Marshal(ids)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
 		idsString := string(body)
 
-		_, err = api.useBlobRepository.Delete(user, idsString)
+		err = api.useBlobStorage.Delete(user, idsString)
 		if err != nil {
 			helper.ReturnErr(w, err, http.StatusInternalServerError)
 			return
 		}
 
-		//TODO remove if no reference
+		helper.SetJsonResponse(w, http.StatusOK, map[string]string{"status": "OK"})
+	})
+}
 
-		// blobsPath := filepath.Join(config.Configuration.ResourcesPath, config.Configuration.BlobsFolder)
+func (api *BlobsApi) EmptyTrash() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
 
-		// for _, blob := range *blobs {
-		// 	_ = os.Remove(filepath.Join(blobsPath, blob.Digest))
-		// }
+		err := api.useBlobStorage.EmptyTrash(user)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
 
 		helper.SetJsonResponse(w, http.StatusOK, map[string]string{"status": "OK"})
 	})
@@ -0,0 +1,232 @@
+package api
+
+import (
+	"cargomail/cmd/mailbox/api/helper"
+	"cargomail/internal/mailbox/repository"
+	"errors"
+	"net/http"
+)
+
+type collectionBlobsInput struct {
+	CollectionId string   `json:"collectionId"` // empty = the root
+	BlobIds      []string `json:"blobIds"`
+}
+
+func (api *BlobsApi) CreateCollection() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		var collection *repository.Collection
+
+		err := helper.Decoder(r.Body).Decode(&collection)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		if collection == nil {
+			helper.ReturnErr(w, repository.ErrMissingNameField, http.StatusBadRequest)
+			return
+		}
+
+		collection, err = api.useBlobRepository.CreateCollection(user, collection)
+		if err != nil {
+			returnCollectionErr(w, err)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusCreated, collection)
+	})
+}
+
+func (api *BlobsApi) ListCollections() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		collectionList, err := api.useBlobRepository.ListCollections(user)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, collectionList)
+	})
+}
+
+// UpdateCollection renames and moves the collection, e.g. {"id": ..., "name": "2023", "parentId": null}
+func (api *BlobsApi) UpdateCollection() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		var collection *repository.Collection
+
+		err := helper.Decoder(r.Body).Decode(&collection)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		if collection == nil || len(collection.Id) == 0 {
+			helper.ReturnErr(w, repository.ErrMissingIdField, http.StatusBadRequest)
+			return
+		}
+
+		collection, err = api.useBlobRepository.UpdateCollection(user, collection)
+		if err != nil {
+			returnCollectionErr(w, err)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, collection)
+	})
+}
+
+func (api *BlobsApi) DeleteCollection() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		var id repository.Id
+
+		err := helper.Decoder(r.Body).Decode(&id)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		if len(id.Id) == 0 {
+			helper.ReturnErr(w, repository.ErrMissingIdField, http.StatusBadRequest)
+			return
+		}
+
+		err = api.useBlobRepository.DeleteCollection(user, id.Id)
+		if err != nil {
+			returnCollectionErr(w, err)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, map[string]string{"status": "OK"})
+	})
+}
+
+func (api *BlobsApi) SyncCollections() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		var history *repository.History
+
+		err := helper.Decoder(r.Body).Decode(&history)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		collectionHistory, err := api.useBlobRepository.SyncCollections(user, history)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, collectionHistory)
+	})
+}
+
+// MoveToCollection moves the blobs, e.g. {"collectionId": ..., "blobIds": [...]}, the empty collection id
+// moves them to the root.
+func (api *BlobsApi) MoveToCollection() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		var input collectionBlobsInput
+
+		err := helper.Decoder(r.Body).Decode(&input)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		err = api.useBlobRepository.MoveToCollection(user, input.CollectionId, input.BlobIds)
+		if err != nil {
+			returnCollectionErr(w, err)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, map[string]string{"status": "OK"})
+	})
+}
+
+// ListByCollection lists the blobs of the collection, e.g. ?collectionId=...&sort=name&order=asc
+func (api *BlobsApi) ListByCollection() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		collectionId := r.URL.Query().Get("collectionId")
+		if len(collectionId) == 0 {
+			helper.ReturnErr(w, repository.ErrMissingIdField, http.StatusBadRequest)
+			return
+		}
+
+		options, err := listOptions(r)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		blobList, err := api.useBlobRepository.ListByCollection(user, collectionId, options)
+		if err != nil {
+			if isListOptionsErr(err) {
+				helper.ReturnErr(w, err, http.StatusBadRequest)
+				return
+			}
+			returnCollectionErr(w, err)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, blobList)
+	})
+}
+
+func returnCollectionErr(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repository.ErrCollectionNotFound),
+		errors.Is(err, repository.ErrBlobNotFound):
+		helper.ReturnErr(w, err, http.StatusNotFound)
+	case errors.Is(err, repository.ErrCollectionNotEmpty):
+		helper.ReturnErr(w, err, http.StatusConflict)
+	case errors.Is(err, repository.ErrDuplicateCollection),
+		errors.Is(err, repository.ErrMissingNameField),
+		errors.Is(err, repository.ErrCollectionWrongName),
+		errors.Is(err, repository.ErrCollectionCycle),
+		errors.Is(err, repository.ErrMissingIdsField):
+		helper.ReturnErr(w, err, http.StatusBadRequest)
+	default:
+		helper.ReturnErr(w, err, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,239 @@
+package api
+
+import (
+	"cargomail/cmd/mailbox/api/helper"
+	"cargomail/internal/mailbox/repository"
+	"errors"
+	"net/http"
+)
+
+type contactGroupMembersInput struct {
+	GroupId    string   `json:"groupId"`
+	ContactIds []string `json:"contactIds"`
+}
+
+func (api *ContactsApi) CreateGroup() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		var group *repository.ContactGroup
+
+		err := helper.Decoder(r.Body).Decode(&group)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		if group == nil {
+			helper.ReturnErr(w, repository.ErrMissingNameField, http.StatusBadRequest)
+			return
+		}
+
+		group, err = api.useContactRepository.CreateGroup(user, group)
+		if err != nil {
+			returnContactGroupErr(w, err)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusCreated, group)
+	})
+}
+
+func (api *ContactsApi) ListGroups() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		groupList, err := api.useContactRepository.ListGroups(user)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, groupList)
+	})
+}
+
+func (api *ContactsApi) UpdateGroup() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		var group *repository.ContactGroup
+
+		err := helper.Decoder(r.Body).Decode(&group)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		if group == nil || len(group.Id) == 0 {
+			helper.ReturnErr(w, repository.ErrMissingIdField, http.StatusBadRequest)
+			return
+		}
+
+		group, err = api.useContactRepository.UpdateGroup(user, group)
+		if err != nil {
+			returnContactGroupErr(w, err)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, group)
+	})
+}
+
+func (api *ContactsApi) DeleteGroup() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		var id repository.Id
+
+		err := helper.Decoder(r.Body).Decode(&id)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		if len(id.Id) == 0 {
+			helper.ReturnErr(w, repository.ErrMissingIdField, http.StatusBadRequest)
+			return
+		}
+
+		err = api.useContactRepository.DeleteGroup(user, id.Id)
+		if err != nil {
+			returnContactGroupErr(w, err)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, map[string]string{"status": "OK"})
+	})
+}
+
+func (api *ContactsApi) SyncGroups() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		var history *repository.History
+
+		err := helper.Decoder(r.Body).Decode(&history)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		groupHistory, err := api.useContactRepository.SyncGroups(user, history)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, groupHistory)
+	})
+}
+
+func (api *ContactsApi) AssignToGroup() http.Handler {
+	return api.modifyGroupMembers(api.useContactRepository.AssignToGroup)
+}
+
+func (api *ContactsApi) RemoveFromGroup() http.Handler {
+	return api.modifyGroupMembers(api.useContactRepository.RemoveFromGroup)
+}
+
+func (api *ContactsApi) modifyGroupMembers(modify func(user *repository.User, groupId string, contactIds []string) (*repository.ContactGroup, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		var input contactGroupMembersInput
+
+		err := helper.Decoder(r.Body).Decode(&input)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		if len(input.GroupId) == 0 {
+			helper.ReturnErr(w, repository.ErrMissingIdField, http.StatusBadRequest)
+			return
+		}
+
+		group, err := modify(user, input.GroupId, input.ContactIds)
+		if err != nil {
+			returnContactGroupErr(w, err)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, group)
+	})
+}
+
+// ListByGroup lists the contacts of the group, e.g. ?groupId=...&sort=lastName&order=asc
+func (api *ContactsApi) ListByGroup() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		groupId := r.URL.Query().Get("groupId")
+		if len(groupId) == 0 {
+			helper.ReturnErr(w, repository.ErrMissingIdField, http.StatusBadRequest)
+			return
+		}
+
+		options, err := listOptions(r)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		contactList, err := api.useContactRepository.ListByGroup(user, groupId, options)
+		if err != nil {
+			if isListOptionsErr(err) {
+				helper.ReturnErr(w, err, http.StatusBadRequest)
+				return
+			}
+			returnContactGroupErr(w, err)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, contactList)
+	})
+}
+
+func returnContactGroupErr(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repository.ErrContactGroupNotFound),
+		errors.Is(err, repository.ErrContactNotFound):
+		helper.ReturnErr(w, err, http.StatusNotFound)
+	case errors.Is(err, repository.ErrDuplicateContactGroup),
+		errors.Is(err, repository.ErrMissingNameField),
+		errors.Is(err, repository.ErrContactGroupWrongName),
+		errors.Is(err, repository.ErrMissingIdsField):
+		helper.ReturnErr(w, err, http.StatusBadRequest)
+	default:
+		helper.ReturnErr(w, err, http.StatusInternalServerError)
+	}
+}
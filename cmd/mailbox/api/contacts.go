@@ -1,11 +1,15 @@
 package api
 
 import (
+	"bufio"
 	"cargomail/cmd/mailbox/api/helper"
 	"cargomail/internal/mailbox/repository"
+	"cargomail/internal/shared/provider"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 )
 
 type ContactsApi struct {
@@ -24,14 +28,24 @@ func (api *ContactsApi) Create() http.Handler {
 
 		err := helper.Decoder(r.Body).Decode(&contact)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
-		contact, err = api.useContactRepository.Create(user, contact)
+		// the idempotent mode makes the retries safe, the existing contact is returned with 200
+		created := true
+
+		if r.URL.Query().Get("idempotent") == "true" {
+			contact, created, err = api.useContactRepository.CreateOrGet(user, contact)
+		} else {
+			contact, err = api.useContactRepository.Create(user, contact)
+		}
 		if err != nil {
 			switch {
-			case errors.Is(err, repository.ErrDuplicateContact):
+			case errors.Is(err, repository.ErrDuplicateContact),
+				errors.Is(err, repository.ErrInvalidEmailAddress),
+				errors.Is(err, repository.ErrMultiplePrimaryEmails),
+				errors.Is(err, repository.ErrInvalidPhoneNumber):
 				helper.ReturnErr(w, err, http.StatusBadRequest)
 			default:
 				helper.ReturnErr(w, err, http.StatusInternalServerError)
@@ -39,10 +53,41 @@ func (api *ContactsApi) Create() http.Handler {
 			return
 		}
 
+		if !created {
+			helper.SetJsonResponse(w, http.StatusOK, contact)
+			return
+		}
+
 		helper.SetJsonResponse(w, http.StatusCreated, contact)
 	})
 }
 
+func (api *ContactsApi) CreateBatch() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		var contacts []*repository.Contact
+
+		err := helper.Decoder(r.Body).Decode(&contacts)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		results, err := api.useContactRepository.CreateBatch(user, contacts)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, results)
+	})
+}
+
 func (api *ContactsApi) List() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
@@ -51,9 +96,30 @@ func (api *ContactsApi) List() http.Handler {
 			return
 		}
 
-		contactHistory, err := api.useContactRepository.List(user)
+		options, err := listOptions(r)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		if acceptsNDJSON(r) {
+			streamList(w, r, func(each func(*repository.Contact) error) (*listTrailer, error) {
+				contactList, err := api.useContactRepository.ListEach(user, options, each)
+				if err != nil {
+					return nil, err
+				}
+				return &listTrailer{History: contactList.History}, nil
+			})
+			return
+		}
+
+		contactHistory, err := api.useContactRepository.List(user, options)
+		if err != nil {
+			if isListOptionsErr(err) {
+				helper.ReturnErr(w, err, http.StatusBadRequest)
+				return
+			}
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
 			return
 		}
 
@@ -61,6 +127,42 @@ func (api *ContactsApi) List() http.Handler {
 	})
 }
 
+func (api *ContactsApi) Count() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		count, err := api.useContactRepository.Count(user)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, count)
+	})
+}
+
+func (api *ContactsApi) ListTrashed() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		contactList, err := api.useContactRepository.ListTrashed(user)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, contactList)
+	})
+}
+
 func (api *ContactsApi) Sync() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
@@ -73,13 +175,13 @@ func (api *ContactsApi) Sync() http.Handler {
 
 		err := helper.Decoder(r.Body).Decode(&history)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
 		contactHistory, err := api.useContactRepository.Sync(user, history)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
 			return
 		}
 
@@ -99,12 +201,12 @@ func (api *ContactsApi) Update() http.Handler {
 
 		err := helper.Decoder(r.Body).Decode(&contact)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
 		if contact.Id == "" {
-			http.Error(w, repository.ErrMissingIdField.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, repository.ErrMissingIdField, http.StatusBadRequest)
 			return
 		}
 
@@ -113,7 +215,11 @@ func (api *ContactsApi) Update() http.Handler {
 			switch {
 			case errors.Is(err, repository.ErrContactNotFound):
 				helper.ReturnErr(w, err, http.StatusNotFound)
-			case errors.Is(err, repository.ErrDuplicateContact):
+			case errors.Is(err, repository.ErrDuplicateContact),
+				errors.Is(err, repository.ErrInvalidEmailAddress),
+				errors.Is(err, repository.ErrMultiplePrimaryEmails),
+				errors.Is(err, repository.ErrPrimaryEmailMismatch),
+				errors.Is(err, repository.ErrInvalidPhoneNumber):
 				helper.ReturnErr(w, err, http.StatusBadRequest)
 			default:
 				helper.ReturnErr(w, err, http.StatusInternalServerError)
@@ -137,19 +243,19 @@ func (api *ContactsApi) Trash() http.Handler {
 
 		err := helper.Decoder(r.Body).Decode(&ids)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
 		if ids.Ids == nil {
-			http.Error(w, repository.ErrMissingIdsField.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, repository.ErrMissingIdsField, http.StatusBadRequest)
 			return
 		}
 
 		// back to body
 		body, err := json.Marshal(ids)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
@@ -157,7 +263,7 @@ func (api *ContactsApi) Trash() http.Handler {
 
 		err = api.useContactRepository.Trash(user, idsString)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
 			return
 		}
 
@@ -177,19 +283,19 @@ func (api *ContactsApi) Untrash() http.Handler {
 
 		err := helper.Decoder(r.Body).Decode(&ids)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
 		if ids.Ids == nil {
-			http.Error(w, repository.ErrMissingIdsField.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, repository.ErrMissingIdsField, http.StatusBadRequest)
 			return
 		}
 
 		// back to body
 		body, err := json.Marshal(ids)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
@@ -197,7 +303,7 @@ func (api *ContactsApi) Untrash() http.Handler {
 
 		err = api.useContactRepository.Untrash(user, idsString)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
 			return
 		}
 
@@ -217,19 +323,19 @@ func (api *ContactsApi) Delete() http.Handler {
 
 		err := helper.Decoder(r.Body).Decode(&ids)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
 		if ids.Ids == nil {
-			http.Error(w, repository.ErrMissingIdsField.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, repository.ErrMissingIdsField, http.StatusBadRequest)
 			return
 		}
 
 		// back to body
 		body, err := json.Marshal(ids)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
@@ -244,3 +350,252 @@ func (api *ContactsApi) Delete() http.Handler {
 		helper.SetJsonResponse(w, http.StatusOK, map[string]string{"status": "OK"})
 	})
 }
+
+func (api *ContactsApi) EmptyTrash() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		err := api.useContactRepository.EmptyTrash(user)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, map[string]string{"status": "OK"})
+	})
+}
+
+// ExportVCard streams the contacts as a .vcf file, the "ids" query parameter (comma separated) selects
+// the contacts to export.
+func (api *ContactsApi) ExportVCard() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bw := bufio.NewWriter(w)
+
+		api.exportContacts(w, r, "text/vcard; charset=utf-8", "contacts.vcf", func(contact *repository.Contact) error {
+			return helper.WriteVCard(bw, contact)
+		}, bw.Flush)
+	})
+}
+
+// ExportCSV streams the contacts as a .csv file, the "ids" query parameter (comma separated) selects
+// the contacts to export.
+func (api *ContactsApi) ExportCSV() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cw := repository.NewContactCSVWriter(w)
+
+		api.exportContacts(w, r, "text/csv; charset=utf-8", "contacts.csv", cw.Write, cw.Flush)
+	})
+}
+
+// exportContacts streams the contacts row by row, so the error after the first contact can only be logged.
+func (api *ContactsApi) exportContacts(w http.ResponseWriter, r *http.Request, contentType, filename string, write func(contact *repository.Contact) error, flush func() error) {
+	user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+	if !ok {
+		helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+		return
+	}
+
+	var ids []string
+
+	if r.URL.Query().Has("ids") {
+		ids = []string{}
+		for _, id := range strings.Split(r.URL.Query().Get("ids"), ",") {
+			if id = strings.TrimSpace(id); len(id) > 0 {
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	started := false
+
+	start := func() {
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		started = true
+	}
+
+	err := api.useContactRepository.ForEach(user, ids, func(contact *repository.Contact) error {
+		if !started {
+			start()
+		}
+		return write(contact)
+	})
+	if err != nil {
+		if !started {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+		provider.Logf(r.Context(), "contacts export error: %v", err)
+		return
+	}
+
+	if !started {
+		start()
+	}
+
+	err = flush()
+	if err != nil {
+		provider.Logf(r.Context(), "contacts export error: %v", err)
+	}
+}
+
+type contactImportResult struct {
+	Line int `json:"line"`
+	*repository.ContactBatchResult
+}
+
+// ImportCSV creates the contacts of the CSV body, the results are reported per row with the line of the row.
+func (api *ContactsApi) ImportCSV() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		records, err := repository.ParseContactsCSV(r.Body)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		contacts := make([]*repository.Contact, len(records))
+		for i, record := range records {
+			contacts[i] = record.Contact
+		}
+
+		results, err := api.useContactRepository.CreateBatch(user, contacts)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		importResults := make([]*contactImportResult, len(results))
+		for i, result := range results {
+			importResults[i] = &contactImportResult{Line: records[i].Line, ContactBatchResult: result}
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, importResults)
+	})
+}
+
+type contactEmailInput struct {
+	Id      string `json:"id"`
+	Address string `json:"address"`
+	Type    string `json:"type"`
+	Primary bool   `json:"primary"`
+}
+
+func (api *ContactsApi) AddEmailAddress() http.Handler {
+	return api.modifyEmailAddresses(func(user *repository.User, input *contactEmailInput) (*repository.Contact, error) {
+		email := &repository.ContactEmail{
+			Address: input.Address,
+			Type:    input.Type,
+			Primary: input.Primary,
+		}
+		return api.useContactRepository.AddEmailAddress(user, input.Id, email)
+	})
+}
+
+func (api *ContactsApi) RemoveEmailAddress() http.Handler {
+	return api.modifyEmailAddresses(func(user *repository.User, input *contactEmailInput) (*repository.Contact, error) {
+		return api.useContactRepository.RemoveEmailAddress(user, input.Id, input.Address)
+	})
+}
+
+func (api *ContactsApi) SetPrimaryEmailAddress() http.Handler {
+	return api.modifyEmailAddresses(func(user *repository.User, input *contactEmailInput) (*repository.Contact, error) {
+		return api.useContactRepository.SetPrimaryEmailAddress(user, input.Id, input.Address)
+	})
+}
+
+func (api *ContactsApi) modifyEmailAddresses(modify func(user *repository.User, input *contactEmailInput) (*repository.Contact, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		var input contactEmailInput
+
+		err := helper.Decoder(r.Body).Decode(&input)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		if len(input.Id) == 0 {
+			helper.ReturnErr(w, repository.ErrMissingIdField, http.StatusBadRequest)
+			return
+		}
+
+		contact, err := modify(user, &input)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrContactNotFound),
+				errors.Is(err, repository.ErrEmailAddressNotFound):
+				helper.ReturnErr(w, err, http.StatusNotFound)
+			case errors.Is(err, repository.ErrDuplicateContact),
+				errors.Is(err, repository.ErrInvalidEmailAddress),
+				errors.Is(err, repository.ErrMultiplePrimaryEmails),
+				errors.Is(err, repository.ErrLastEmailAddress):
+				helper.ReturnErr(w, err, http.StatusBadRequest)
+			default:
+				helper.ReturnErr(w, err, http.StatusInternalServerError)
+			}
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, contact)
+	})
+}
+
+type contactMergeInput struct {
+	SurvivorId string   `json:"survivorId"`
+	MergedIds  []string `json:"mergedIds"`
+}
+
+func (api *ContactsApi) Merge() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		var input contactMergeInput
+
+		err := helper.Decoder(r.Body).Decode(&input)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		if len(input.SurvivorId) == 0 {
+			helper.ReturnErr(w, repository.ErrMissingIdField, http.StatusBadRequest)
+			return
+		}
+
+		contact, err := api.useContactRepository.Merge(user, input.SurvivorId, input.MergedIds)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrContactNotFound):
+				helper.ReturnErr(w, err, http.StatusNotFound)
+			case errors.Is(err, repository.ErrMissingIdsField),
+				errors.Is(err, repository.ErrInvalidEmailAddress),
+				errors.Is(err, repository.ErrInvalidPhoneNumber):
+				helper.ReturnErr(w, err, http.StatusBadRequest)
+			default:
+				helper.ReturnErr(w, err, http.StatusInternalServerError)
+			}
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, contact)
+	})
+}
@@ -5,16 +5,27 @@ import (
 	"cargomail/internal/mailbox/agent"
 	"cargomail/internal/mailbox/repository"
 	"cargomail/internal/mailbox/storage"
+	"cargomail/internal/shared/config"
+	"cargomail/internal/shared/ratelimit"
 	"encoding/json"
 	"errors"
 	"net/http"
+	"net/mail"
 	"strings"
+	"time"
+
+	"github.com/google/uuid"
 )
 
+// smtpRetryAfter is how long the client waits before it sends the draft again after a temporary failure
+// of the relay.
+const smtpRetryAfter = time.Minute
+
 type DraftsApi struct {
 	useDraftRepository        repository.UseDraftRepository
 	useDraftStorage           storage.UseDraftStorage
 	useMessageSubmissionAgent agent.UseMessageSubmissionAgent
+	useSmtpSubmissionAgent    agent.UseSmtpSubmissionAgent
 }
 
 func (api *DraftsApi) Create() http.Handler {
@@ -29,13 +40,18 @@ func (api *DraftsApi) Create() http.Handler {
 
 		err := helper.Decoder(r.Body).Decode(&draft)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
 		draft, err = api.useDraftStorage.Create(user, draft)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			switch {
+			case errors.Is(err, repository.ErrQuotaExceeded):
+				helper.ReturnErr(w, err, http.StatusRequestEntityTooLarge)
+			default:
+				helper.ReturnErr(w, err, http.StatusInternalServerError)
+			}
 			return
 		}
 
@@ -51,9 +67,89 @@ func (api *DraftsApi) List() http.Handler {
 			return
 		}
 
-		draftList, err := api.useDraftStorage.List(user)
+		options, err := listOptions(r)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		if acceptsNDJSON(r) {
+			streamList(w, r, func(each func(*repository.Draft) error) (*listTrailer, error) {
+				draftList, err := api.useDraftStorage.ListEach(user, options, each)
+				if err != nil {
+					return nil, err
+				}
+				return &listTrailer{History: draftList.History}, nil
+			})
+			return
+		}
+
+		draftList, err := api.useDraftStorage.List(user, options)
+		if err != nil {
+			if isListOptionsErr(err) {
+				helper.ReturnErr(w, err, http.StatusBadRequest)
+				return
+			}
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, draftList)
+	})
+}
+
+func (api *DraftsApi) Search() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		drafts, err := api.useDraftStorage.Search(user, r.URL.Query().Get("q"))
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrMissingSearchQuery):
+				helper.ReturnErr(w, err, http.StatusBadRequest)
+			default:
+				helper.ReturnErr(w, err, http.StatusInternalServerError)
+			}
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, drafts)
+	})
+}
+
+func (api *DraftsApi) Count() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		count, err := api.useDraftRepository.Count(user)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, count)
+	})
+}
+
+func (api *DraftsApi) ListTrashed() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		draftList, err := api.useDraftStorage.ListTrashed(user)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
 			return
 		}
 
@@ -73,13 +169,13 @@ func (api *DraftsApi) Sync() http.Handler {
 
 		err := helper.Decoder(r.Body).Decode(&history)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
 		draftHistory, err := api.useDraftStorage.Sync(user, history)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
 			return
 		}
 
@@ -99,22 +195,22 @@ func (api *DraftsApi) Update() http.Handler {
 
 		err := helper.Decoder(r.Body).Decode(&draft)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
 		if draft.Id == "" {
-			http.Error(w, repository.ErrMissingIdField.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, repository.ErrMissingIdField, http.StatusBadRequest)
 			return
 		}
 
 		if draft.Payload == nil {
-			http.Error(w, repository.ErrMissingPayloadField.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, repository.ErrMissingPayloadField, http.StatusBadRequest)
 			return
 		}
 
 		if draft.Payload.Headers == nil {
-			http.Error(w, repository.ErrMissingHeadersField.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, repository.ErrMissingHeadersField, http.StatusBadRequest)
 			return
 		}
 
@@ -123,6 +219,8 @@ func (api *DraftsApi) Update() http.Handler {
 			switch {
 			case errors.Is(err, repository.ErrDraftNotFound):
 				helper.ReturnErr(w, err, http.StatusNotFound)
+			case errors.Is(err, repository.ErrQuotaExceeded):
+				helper.ReturnErr(w, err, http.StatusRequestEntityTooLarge)
 			default:
 				helper.ReturnErr(w, err, http.StatusInternalServerError)
 			}
@@ -133,6 +231,122 @@ func (api *DraftsApi) Update() http.Handler {
 	})
 }
 
+// ListVersions lists the previous payloads of the draft, e.g. ?id=...
+func (api *DraftsApi) ListVersions() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		id := r.URL.Query().Get("id")
+		if len(id) == 0 {
+			helper.ReturnErr(w, repository.ErrMissingIdField, http.StatusBadRequest)
+			return
+		}
+
+		versions, err := api.useDraftStorage.ListVersions(user, id)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrDraftNotFound):
+				helper.ReturnErr(w, err, http.StatusNotFound)
+			default:
+				helper.ReturnErr(w, err, http.StatusInternalServerError)
+			}
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, versions)
+	})
+}
+
+type draftVersionInput struct {
+	Id        string `json:"id"`
+	VersionId string `json:"versionId"`
+}
+
+func (api *DraftsApi) RestoreVersion() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		var input draftVersionInput
+
+		err := helper.Decoder(r.Body).Decode(&input)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		if len(input.Id) == 0 || len(input.VersionId) == 0 {
+			helper.ReturnErr(w, repository.ErrMissingIdField, http.StatusBadRequest)
+			return
+		}
+
+		draft, err := api.useDraftStorage.RestoreVersion(user, input.Id, input.VersionId)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrDraftNotFound),
+				errors.Is(err, repository.ErrDraftVersionNotFound):
+				helper.ReturnErr(w, err, http.StatusNotFound)
+			default:
+				helper.ReturnErr(w, err, http.StatusInternalServerError)
+			}
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, draft)
+	})
+}
+
+type draftFlagsInput struct {
+	Id      string `json:"id"`
+	Unread  *bool  `json:"unread"`
+	Starred *bool  `json:"starred"`
+}
+
+func (api *DraftsApi) SetFlags() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		var input draftFlagsInput
+
+		err := helper.Decoder(r.Body).Decode(&input)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		if len(input.Id) == 0 {
+			helper.ReturnErr(w, repository.ErrMissingIdField, http.StatusBadRequest)
+			return
+		}
+
+		err = api.useDraftRepository.SetFlags(user, input.Id, input.Unread, input.Starred)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrDraftNotFound):
+				helper.ReturnErr(w, err, http.StatusNotFound)
+			case errors.Is(err, repository.ErrMissingStateField):
+				helper.ReturnErr(w, err, http.StatusBadRequest)
+			default:
+				helper.ReturnErr(w, err, http.StatusInternalServerError)
+			}
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, map[string]string{"status": "OK"})
+	})
+}
+
 func (api *DraftsApi) Trash() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
@@ -145,19 +359,19 @@ func (api *DraftsApi) Trash() http.Handler {
 
 		err := helper.Decoder(r.Body).Decode(&ids)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
 		if ids.Ids == nil {
-			http.Error(w, repository.ErrMissingIdsField.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, repository.ErrMissingIdsField, http.StatusBadRequest)
 			return
 		}
 
 		// back to body
 		body, err := json.Marshal(ids)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
@@ -165,7 +379,7 @@ func (api *DraftsApi) Trash() http.Handler {
 
 		err = api.useDraftRepository.Trash(user, idsString)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
 			return
 		}
 
@@ -185,19 +399,19 @@ func (api *DraftsApi) Untrash() http.Handler {
 
 		err := helper.Decoder(r.Body).Decode(&ids)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
 		if ids.Ids == nil {
-			http.Error(w, repository.ErrMissingIdsField.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, repository.ErrMissingIdsField, http.StatusBadRequest)
 			return
 		}
 
 		// back to body
 		body, err := json.Marshal(ids)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
@@ -205,7 +419,7 @@ func (api *DraftsApi) Untrash() http.Handler {
 
 		err = api.useDraftRepository.Untrash(user, idsString)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
 			return
 		}
 
@@ -225,19 +439,19 @@ func (api *DraftsApi) Delete() http.Handler {
 
 		err := helper.Decoder(r.Body).Decode(&ids)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
 		if ids.Ids == nil {
-			http.Error(w, repository.ErrMissingIdsField.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, repository.ErrMissingIdsField, http.StatusBadRequest)
 			return
 		}
 
 		// back to body
 		body, err := json.Marshal(ids)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
@@ -253,6 +467,54 @@ func (api *DraftsApi) Delete() http.Handler {
 	})
 }
 
+// Discard serves DELETE /api/v1/drafts/{id}/discard
+func (api *DraftsApi) Discard() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		id, ok := draftIdFromPath(r.URL.Path, "discard")
+		if !ok {
+			helper.ReturnErr(w, repository.ErrNotFound, http.StatusNotFound)
+			return
+		}
+
+		err := api.useDraftRepository.Discard(user, id)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrDraftNotFound):
+				helper.ReturnErr(w, err, http.StatusNotFound)
+			default:
+				helper.ReturnErr(w, err, http.StatusInternalServerError)
+			}
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, map[string]string{"status": "OK"})
+	})
+}
+
+func (api *DraftsApi) EmptyTrash() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		err := api.useDraftRepository.EmptyTrash(user)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, map[string]string{"status": "OK"})
+	})
+}
+
 func (api *DraftsApi) Submit() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
@@ -265,25 +527,28 @@ func (api *DraftsApi) Submit() http.Handler {
 
 		err := helper.Decoder(r.Body).Decode(&draft)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
 		if draft.Id == "" {
-			http.Error(w, repository.ErrMissingIdField.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, repository.ErrMissingIdField, http.StatusBadRequest)
 			return
 		}
 
 		if draft.Payload == nil {
-			http.Error(w, repository.ErrMissingPayloadField.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, repository.ErrMissingPayloadField, http.StatusBadRequest)
 			return
 		}
 
 		if draft.Payload.Headers == nil {
-			http.Error(w, repository.ErrMissingHeadersField.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, repository.ErrMissingHeadersField, http.StatusBadRequest)
 			return
 		}
 
+		// the Date of the client is not trusted
+		draft.SetDate(time.Now())
+
 		message, err := api.useDraftRepository.Submit(user, draft)
 		if err != nil {
 			recipientsNotFoundError := &repository.RecipientsNotFoundError{}
@@ -303,7 +568,7 @@ func (api *DraftsApi) Submit() http.Handler {
 	ok:
 		response, err := api.useMessageSubmissionAgent.Post(r.Context(), message)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
 			return
 		}
 
@@ -311,3 +576,281 @@ func (api *DraftsApi) Submit() http.Handler {
 		helper.SetJsonResponse(w, response.StatusCode, message)
 	})
 }
+
+// Send serves the POST /api/v1/drafts/{id}/send. The draft is rendered to a MIME message and sent through the
+// relay to the recipients of the other domains first, then it is submitted, i.e. moved to the sent messages
+// and delivered to the recipients of the domain and queued for the ones of the federation peers. A relay failure keeps the draft, the temporary one is
+// a 503 with the Retry-After the client sends the draft again after, the permanent one a 502.
+func (api *DraftsApi) Send() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		id, ok := draftIdFromPath(r.URL.Path, "send")
+		if !ok {
+			helper.ReturnErr(w, repository.ErrNotFound, http.StatusNotFound)
+			return
+		}
+
+		draft, err := api.useDraftRepository.GetById(user, id)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrDraftNotFound):
+				helper.ReturnErr(w, err, http.StatusNotFound)
+			default:
+				helper.ReturnErr(w, err, http.StatusInternalServerError)
+			}
+			return
+		}
+
+		if draft.Payload == nil || draft.Payload.Headers == nil {
+			helper.ReturnErr(w, repository.ErrMissingHeadersField, http.StatusBadRequest)
+			return
+		}
+
+		recipients, err := repository.SubmissionRecipients(user, draft.Payload.Headers)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		// the relayed message and the sent one share the Message-ID
+		if messageId, _ := draft.Payload.Headers["Message-ID"].(string); len(messageId) == 0 {
+			draft.Payload.Headers["Message-ID"] = "<" + uuid.NewString() + "@" + config.Configuration.DomainName + ">"
+		}
+
+		draft.SetDate(time.Now())
+
+		var relayed []string
+
+		for _, recipient := range recipients {
+			_, domain, _ := strings.Cut(recipient, "@")
+			if strings.EqualFold(domain, config.Configuration.DomainName) {
+				continue
+			}
+
+			// the peer instances are delivered to by the federation sender
+			if _, ok := config.FederationPeer(domain); !ok {
+				relayed = append(relayed, recipient)
+			}
+		}
+
+		if len(relayed) > 0 {
+			message, err := api.useDraftStorage.RenderMIME(user, draft)
+			if err != nil {
+				helper.ReturnErr(w, err, http.StatusInternalServerError)
+				return
+			}
+
+			from, err := mail.ParseAddress(draft.Payload.Headers["From"].(string))
+			if err != nil {
+				helper.ReturnErr(w, repository.ErrInvalidSender, http.StatusBadRequest)
+				return
+			}
+
+			err = api.useSmtpSubmissionAgent.Send(r.Context(), from.Address, relayed, message)
+			if err != nil {
+				switch {
+				case errors.Is(err, repository.ErrSmtpRelayNotConfigured):
+					helper.ReturnErr(w, err, http.StatusServiceUnavailable)
+				case errors.Is(err, repository.ErrSmtpTemporaryFailure):
+					w.Header().Set("Retry-After", ratelimit.RetryAfter(smtpRetryAfter))
+					helper.ReturnErr(w, err, http.StatusServiceUnavailable)
+				case errors.Is(err, repository.ErrSmtpPermanentFailure):
+					helper.ReturnErr(w, err, http.StatusBadGateway)
+				default:
+					helper.ReturnErr(w, err, http.StatusInternalServerError)
+				}
+				return
+			}
+		}
+
+		message, err := api.useDraftRepository.Submit(user, draft)
+		if err != nil {
+			recipientsNotFoundError := &repository.RecipientsNotFoundError{}
+
+			switch {
+			case errors.As(err, &recipientsNotFoundError):
+				// the relayed recipients aren't the users of the domain
+				var notFound []string
+
+				for _, recipient := range recipientsNotFoundError.Recipients {
+					_, domain, _ := strings.Cut(recipient, "@")
+					if strings.EqualFold(domain, config.Configuration.DomainName) {
+						notFound = append(notFound, recipient)
+					}
+				}
+
+				if len(notFound) > 0 {
+					warning := recipientsNotFoundError.Err.Error() + ": " + strings.Join(notFound, ", ")
+					w.Header().Set("X-Warning", warning)
+				}
+
+				message.Folder = 1 // sent
+			case errors.Is(err, repository.ErrDraftNotFound):
+				helper.ReturnErr(w, err, http.StatusNotFound)
+				return
+			default:
+				helper.ReturnErr(w, err, http.StatusInternalServerError)
+				return
+			}
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, message)
+	})
+}
+
+// PostSubresource serves the POST /api/v1/drafts/{id}/attachments and /api/v1/drafts/{id}/send
+func (api *DraftsApi) PostSubresource() http.Handler {
+	return draftSubresources(map[string]http.Handler{
+		"attachments": api.AttachBlob(),
+		"send":        api.Send(),
+	})
+}
+
+// draftIdFromPath parses the id of the /api/v1/drafts/{id}/{subresource} path
+func draftIdFromPath(path string, subresource string) (string, bool) {
+	id, ok := strings.CutSuffix(strings.TrimPrefix(path, "/api/v1/drafts/"), "/"+subresource)
+	if !ok || len(id) == 0 || strings.Contains(id, "/") {
+		return "", false
+	}
+
+	return id, true
+}
+
+// DeleteSubresource serves the DELETE /api/v1/drafts/{id}/discard and /api/v1/drafts/{id}/attachments
+func (api *DraftsApi) DeleteSubresource() http.Handler {
+	return draftSubresources(map[string]http.Handler{
+		"discard":     api.Discard(),
+		"attachments": api.DetachBlob(),
+	})
+}
+
+// draftSubresources serves the /api/v1/drafts/{id}/{subresource} paths of one method by the subresource
+func draftSubresources(handlers map[string]http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for subresource, handler := range handlers {
+			if _, ok := draftIdFromPath(r.URL.Path, subresource); ok {
+				handler.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		helper.ReturnErr(w, repository.ErrNotFound, http.StatusNotFound)
+	})
+}
+
+type draftAttachmentInput struct {
+	Uri string `json:"uri"`
+}
+
+func (api *DraftsApi) ListAttachments() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		id, ok := draftIdFromPath(r.URL.Path, "attachments")
+		if !ok {
+			helper.ReturnErr(w, repository.ErrNotFound, http.StatusNotFound)
+			return
+		}
+
+		attachments, err := api.useDraftRepository.ListAttachments(user, id)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrDraftNotFound):
+				helper.ReturnErr(w, err, http.StatusNotFound)
+			default:
+				helper.ReturnErr(w, err, http.StatusInternalServerError)
+			}
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, attachments)
+	})
+}
+
+func (api *DraftsApi) AttachBlob() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		id, ok := draftIdFromPath(r.URL.Path, "attachments")
+		if !ok {
+			helper.ReturnErr(w, repository.ErrNotFound, http.StatusNotFound)
+			return
+		}
+
+		var input draftAttachmentInput
+
+		err := helper.Decoder(r.Body).Decode(&input)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		attachment, err := api.useDraftRepository.AttachBlob(user, id, input.Uri)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrDraftNotFound),
+				errors.Is(err, repository.ErrBlobNotFound):
+				helper.ReturnErr(w, err, http.StatusNotFound)
+			case errors.Is(err, repository.ErrMissingUriField):
+				helper.ReturnErr(w, err, http.StatusBadRequest)
+			default:
+				helper.ReturnErr(w, err, http.StatusInternalServerError)
+			}
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, attachment)
+	})
+}
+
+func (api *DraftsApi) DetachBlob() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		id, ok := draftIdFromPath(r.URL.Path, "attachments")
+		if !ok {
+			helper.ReturnErr(w, repository.ErrNotFound, http.StatusNotFound)
+			return
+		}
+
+		var input draftAttachmentInput
+
+		err := helper.Decoder(r.Body).Decode(&input)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		err = api.useDraftRepository.DetachBlob(user, id, input.Uri)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrAttachmentNotFound):
+				helper.ReturnErr(w, err, http.StatusNotFound)
+			case errors.Is(err, repository.ErrMissingUriField):
+				helper.ReturnErr(w, err, http.StatusBadRequest)
+			default:
+				helper.ReturnErr(w, err, http.StatusInternalServerError)
+			}
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, map[string]string{"status": "OK"})
+	})
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"bytes"
+	"cargomail/cmd/mailbox/api/helper"
+	"cargomail/internal/mailbox/repository"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// etagWriter sets the ETag of the successful responses only, the errors are not cached.
+type etagWriter struct {
+	http.ResponseWriter
+	etag        string
+	wroteHeader bool
+}
+
+func (w *etagWriter) WriteHeader(statusCode int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+
+		if statusCode == http.StatusOK {
+			w.Header().Set("ETag", w.etag)
+			w.Header().Set("Cache-Control", "private, no-cache")
+		}
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *etagWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+// etagMatch tells whether the If-None-Match header lists the ETag, by the weak comparison.
+func etagMatch(ifNoneMatch, etag string) bool {
+	if len(ifNoneMatch) == 0 {
+		return false
+	}
+
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// notModified answers the conditional GET or HEAD with 304 when the client has the content, by the
+// If-None-Match, or by the If-Modified-Since sent without it. The ETag and Last-Modified are set on the
+// response either way.
+func notModified(w http.ResponseWriter, r *http.Request, etag string, modTime time.Time) bool {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+	w.Header().Set("Cache-Control", "private, no-cache")
+
+	if ifNoneMatch := r.Header.Get("If-None-Match"); len(ifNoneMatch) > 0 {
+		if !etagMatch(ifNoneMatch, etag) {
+			return false
+		}
+	} else {
+		since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+		if err != nil || modTime.Truncate(time.Second).After(since) {
+			return false
+		}
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// middleware
+//
+// ListETag tags the List response by the last history id of the resource, any change of the resource
+// changes it. The request sent with the If-None-Match of the current tag is answered with 304, without
+// listing. The list is a POST, but a safe one. The list streamed as NDJSON is tagged apart.
+func (api *Api) ListETag(resource string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		historyId, err := api.useEventRepository.LastHistoryId(user, resource)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		// the same history lists differently by the query parameters and the body, e.g. the cursor or
+		// the folder
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		hash := sha256.New()
+		fmt.Fprintf(hash, "%d:%s:%d:%s:", user.Id, resource, historyId, r.URL.Query().Encode())
+		if acceptsNDJSON(r) {
+			fmt.Fprint(hash, ndjsonContentType+":")
+		}
+		hash.Write(body)
+
+		etag := `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+
+		if etagMatch(r.Header.Get("If-None-Match"), etag) {
+			w.Header().Set("ETag", etag)
+			w.Header().Set("Cache-Control", "private, no-cache")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		next.ServeHTTP(&etagWriter{ResponseWriter: w, etag: etag}, r)
+	})
+}
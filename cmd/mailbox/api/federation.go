@@ -0,0 +1,108 @@
+package api
+
+import (
+	"cargomail/cmd/mailbox/api/helper"
+	"cargomail/internal/mailbox/repository"
+	"cargomail/internal/shared/config"
+	"crypto/ed25519"
+	b64 "encoding/base64"
+	"errors"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+type FederationApi struct {
+	useFederationRepository repository.UseFederationRepository
+}
+
+// Deliver serves the POST /api/v1/federation/deliver of the peer instances, the body is the
+// repository.FederationEnvelope signed by the key of the instance of the sender, see the
+// helper.SignEnvelope. The response is the receipt of the delivered recipients, 401 if the signature doesn't
+// match the key of the peer, 404 if none of the recipients is found. The 4xx responses are final, the peer
+// retries the others.
+func (api *FederationApi) Deliver() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var envelope *repository.FederationEnvelope
+
+		err := helper.Decoder(r.Body).Decode(&envelope)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		if envelope == nil {
+			helper.ReturnErr(w, repository.ErrMissingPayloadField, http.StatusBadRequest)
+			return
+		}
+
+		sender, err := mail.ParseAddress(envelope.Sender)
+		if err != nil {
+			helper.ReturnErr(w, repository.ErrInvalidSender, http.StatusBadRequest)
+			return
+		}
+
+		_, domain, _ := strings.Cut(sender.Address, "@")
+
+		key, err := api.useFederationRepository.PeerKey(domain)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrPeerKeyNotFound):
+				helper.ReturnErr(w, err, http.StatusUnauthorized)
+			default:
+				helper.ReturnErr(w, err, http.StatusInternalServerError)
+			}
+			return
+		}
+
+		// the envelope is verified before anything is inserted
+		err = helper.VerifyEnvelopeSignature(key, envelope, r.Header.Get("X-Cargomail-Signature"), time.Now())
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusUnauthorized)
+			return
+		}
+
+		receipt, err := api.useFederationRepository.Deliver(envelope)
+		if err != nil {
+			recipientsNotFoundError := &repository.RecipientsNotFoundError{}
+
+			switch {
+			case errors.As(err, &recipientsNotFoundError):
+				helper.ReturnErr(w, err, http.StatusNotFound)
+			case errors.Is(err, repository.ErrUnknownPeer):
+				helper.ReturnErr(w, err, http.StatusForbidden)
+			case errors.Is(err, repository.ErrMissingPayloadField),
+				errors.Is(err, repository.ErrMissingHeadersField),
+				errors.Is(err, repository.ErrMissingMessageId),
+				errors.Is(err, repository.ErrInvalidSender),
+				errors.Is(err, repository.ErrMissingRecipients),
+				errors.Is(err, repository.ErrInvalidRecipients):
+				helper.ReturnErr(w, err, http.StatusBadRequest)
+			default:
+				helper.ReturnErr(w, err, http.StatusInternalServerError)
+			}
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, receipt)
+	})
+}
+
+// Key serves the GET /api/v1/federation/key, the public key the peers verify the messages of the instance
+// by.
+func (api *FederationApi) Key() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, err := api.useFederationRepository.InstanceKey()
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, map[string]string{
+			"domain":    config.Configuration.DomainName,
+			"algorithm": "ed25519",
+			"publicKey": b64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)),
+		})
+	})
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"cargomail/cmd/mailbox/api/helper"
+	"cargomail/internal/mailbox/repository"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// fileUriFromPath parses the uri of the /api/v1/files/{uri}/{subresource} path
+func fileUriFromPath(path string, subresource string) (string, bool) {
+	uri, ok := strings.CutSuffix(strings.TrimPrefix(path, "/api/v1/files/"), "/"+subresource)
+	if !ok || len(uri) == 0 || strings.Contains(uri, "/") {
+		return "", false
+	}
+
+	return uri, true
+}
+
+// AddTag serves the POST /api/v1/files/{uri}/tags, e.g. {"tag": "invoices"}
+func (api *FilesApi) AddTag() http.Handler {
+	return api.modifyTags(api.useFileRepository.AddTag)
+}
+
+// RemoveTag serves the DELETE /api/v1/files/{uri}/tags
+func (api *FilesApi) RemoveTag() http.Handler {
+	return api.modifyTags(api.useFileRepository.RemoveTag)
+}
+
+func (api *FilesApi) modifyTags(modify func(user *repository.User, uri string, tag string) (*repository.FileTags, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		uri, ok := fileUriFromPath(r.URL.Path, "tags")
+		if !ok {
+			helper.ReturnErr(w, repository.ErrNotFound, http.StatusNotFound)
+			return
+		}
+
+		var input tagInput
+
+		err := helper.Decoder(r.Body).Decode(&input)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		tags, err := modify(user, uri, input.Tag)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrFileNotFound):
+				helper.ReturnErr(w, err, http.StatusNotFound)
+			case errors.Is(err, repository.ErrMissingTagField),
+				errors.Is(err, repository.ErrFileWrongTag):
+				helper.ReturnErr(w, err, http.StatusBadRequest)
+			default:
+				helper.ReturnErr(w, err, http.StatusInternalServerError)
+			}
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, tags)
+	})
+}
+
+// ListTags lists the tags of the tagged files, the files of a tag are listed by the ?tag= of the List.
+func (api *FilesApi) ListTags() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		tags, err := api.useFileRepository.ListTags(user)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, tags)
+	})
+}
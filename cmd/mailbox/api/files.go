@@ -10,11 +10,8 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
-	"os"
 	"path"
-	"path/filepath"
-
-	"github.com/google/uuid"
+	"strconv"
 )
 
 type FilesApi struct {
@@ -32,13 +29,19 @@ func (api *FilesApi) Upload() http.Handler {
 
 		err := r.ParseMultipartForm(32 << 20)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			returnMultipartErr(w, err)
 			return
 		}
 
 		uploadedFiles := []*repository.File{}
 
 		files := r.MultipartForm.File["files"]
+
+		err = checkUploadSizes(files)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusRequestEntityTooLarge)
+			return
+		}
 		for i := range files {
 			file, err := files[i].Open()
 			if err != nil {
@@ -47,21 +50,16 @@ func (api *FilesApi) Upload() http.Handler {
 			}
 			defer file.Close()
 
-			filesPath := filepath.Join(config.Configuration.ResourcesPath, config.Configuration.FilesFolder)
-
-			if _, err := os.Stat(filesPath); errors.Is(err, os.ErrNotExist) {
-				err := os.MkdirAll(filesPath, os.ModePerm)
-				if err != nil {
+			uploadedFile, err := api.useFileStorage.Store(user, file, files[i].Filename, files[i].Header.Get("content-type"))
+			if err != nil {
+				switch {
+				case errors.Is(err, repository.ErrQuotaExceeded):
+					helper.ReturnErr(w, err, http.StatusRequestEntityTooLarge)
+				case errors.Is(err, repository.ErrInvalidImage):
+					helper.ReturnErr(w, err, http.StatusBadRequest)
+				default:
 					helper.ReturnErr(w, err, http.StatusInternalServerError)
-					return
 				}
-			}
-
-			uuid := uuid.NewString()
-
-			uploadedFile, err := api.useFileStorage.Store(user, file, filesPath, uuid, files[i].Filename, files[i].Header.Get("content-type"))
-			if err != nil {
-				helper.ReturnErr(w, err, http.StatusInternalServerError)
 				return
 			}
 
@@ -99,34 +97,61 @@ func (api *FilesApi) Download() http.Handler {
 			return
 		}
 
-		if r.Method == "HEAD" {
-			w.WriteHeader(http.StatusOK)
-		} else if r.Method == "GET" {
-			asciiFileName, err := helper.ToAscii(file.Name)
-			if err != nil {
-				helper.ReturnErr(w, err, http.StatusInternalServerError)
-				return
-			}
+		asciiFileName, err := helper.ToAscii(file.Name)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
 
-			urlEncodedFileName, err := url.Parse(file.Name)
-			if err != nil {
-				helper.ReturnErr(w, err, http.StatusInternalServerError)
-				return
-			}
+		urlEncodedFileName, err := url.Parse(file.Name)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		// the content of a digest never changes, so the digest is the strong ETag
+		modTime := file.CreatedAt.Time()
+		if file.ModifiedAt != nil {
+			modTime = file.ModifiedAt.Time()
+		}
 
-			filesPath := filepath.Join(config.Configuration.ResourcesPath, config.Configuration.FilesFolder)
+		if notModified(w, r, `"`+file.Digest+`"`, modTime) {
+			return
+		}
 
-			filePath := filepath.Join(filesPath, digest)
-			w.Header().Set("Content-Type", "application/octet-stream")
-			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q; filename*=UTF-8''%s", asciiFileName, urlEncodedFileName))
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q; filename*=UTF-8''%s", asciiFileName, urlEncodedFileName))
+		w.Header().Set("Accept-Ranges", "bytes")
 
-			filePath = filepath.Clean(filePath)
+		// the HEAD and the range requests are served from the seekable content, a whole download
+		// is checked against the digest before it is written
+		if r.Method == "HEAD" || len(r.Header.Get("Range")) > 0 {
+			if config.VerifyDownloads() || r.URL.Query().Get("verify") == "1" {
+				err = api.useFileStorage.Verify(file)
+				if err != nil {
+					helper.ReturnErr(w, err, http.StatusInternalServerError)
+					return
+				}
+			}
 
-			err = api.useFileStorage.Load(w, file, filePath)
+			content, err := api.useFileStorage.Open(file)
 			if err != nil {
 				helper.ReturnErr(w, err, http.StatusInternalServerError)
 				return
 			}
+			defer content.Close()
+
+			http.ServeContent(w, r, "", modTime, content)
+			return
+		}
+
+		w.Header().Set("Content-Length", strconv.FormatInt(file.Size, 10))
+
+		err = api.useFileStorage.Load(w, file)
+		if err != nil {
+			w.Header().Del("Content-Length")
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
 		}
 	})
 }
@@ -139,17 +164,84 @@ func (api *FilesApi) List() http.Handler {
 			return
 		}
 
+		options, err := listOptions(r)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
 		var folder repository.Folder
 
-		err := helper.Decoder(r.Body).Decode(&folder)
+		err = helper.Decoder(r.Body).Decode(&folder)
 		if err != nil {
 			if err.Error() != "EOF" {
-				http.Error(w, err.Error(), http.StatusBadRequest)
+				helper.ReturnErr(w, err, http.StatusBadRequest)
 				return
 			}
 		}
 
-		fileList, err := api.useFileRepository.List(user, folder.Folder)
+		if acceptsNDJSON(r) {
+			streamList(w, r, func(each func(*repository.File) error) (*listTrailer, error) {
+				fileList, err := api.useFileRepository.ListEach(user, folder.Folder, options, each)
+				if err != nil {
+					return nil, err
+				}
+				return &listTrailer{History: fileList.History}, nil
+			})
+			return
+		}
+
+		fileList, err := api.useFileRepository.List(user, folder.Folder, options)
+		if err != nil {
+			if isListOptionsErr(err) {
+				helper.ReturnErr(w, err, http.StatusBadRequest)
+				return
+			}
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, fileList)
+	})
+}
+
+func (api *FilesApi) Search() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		options, err := listOptions(r)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		files, err := api.useFileRepository.Search(user, r.URL.Query().Get("q"), options)
+		if err != nil {
+			if isListOptionsErr(err) || errors.Is(err, repository.ErrMissingSearchQuery) {
+				helper.ReturnErr(w, err, http.StatusBadRequest)
+				return
+			}
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, files)
+	})
+}
+
+func (api *FilesApi) ListTrashed() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		fileList, err := api.useFileRepository.ListTrashed(user)
 		if err != nil {
 			helper.ReturnErr(w, err, http.StatusInternalServerError)
 			return
@@ -171,13 +263,13 @@ func (api *FilesApi) Sync() http.Handler {
 
 		err := helper.Decoder(r.Body).Decode(&history)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
 		fileSync, err := api.useFileRepository.Sync(user, history)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
 			return
 		}
 
@@ -185,6 +277,50 @@ func (api *FilesApi) Sync() http.Handler {
 	})
 }
 
+type fileRenameInput struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Update renames the file.
+func (api *FilesApi) Update() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		var input fileRenameInput
+
+		err := helper.Decoder(r.Body).Decode(&input)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		if len(input.Id) == 0 {
+			helper.ReturnErr(w, repository.ErrMissingIdField, http.StatusBadRequest)
+			return
+		}
+
+		file, err := api.useFileRepository.Update(user, &repository.File{Id: input.Id, Name: input.Name})
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrFileNotFound):
+				helper.ReturnErr(w, err, http.StatusNotFound)
+			case errors.Is(err, repository.ErrFileWrongName):
+				helper.ReturnErr(w, err, http.StatusBadRequest)
+			default:
+				helper.ReturnErr(w, err, http.StatusInternalServerError)
+			}
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, file)
+	})
+}
+
 func (api *FilesApi) Trash() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
@@ -197,19 +333,19 @@ func (api *FilesApi) Trash() http.Handler {
 
 		err := helper.Decoder(r.Body).Decode(&ids)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
 		if ids.Ids == nil {
-			http.Error(w, repository.ErrMissingIdsField.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, repository.ErrMissingIdsField, http.StatusBadRequest)
 			return
 		}
 
 		// back to body
 		body, err := json.Marshal(ids)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
@@ -217,7 +353,7 @@ func (api *FilesApi) Trash() http.Handler {
 
 		err = api.useFileRepository.Trash(user, idsString)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
 			return
 		}
 
@@ -237,19 +373,19 @@ func (api *FilesApi) Untrash() http.Handler {
 
 		err := helper.Decoder(r.Body).Decode(&ids)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
 		if ids.Ids == nil {
-			http.Error(w, repository.ErrMissingIdsField.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, repository.ErrMissingIdsField, http.StatusBadRequest)
 			return
 		}
 
 		// back to body
 		body, err := json.Marshal(ids)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
@@ -257,7 +393,7 @@ func (api *FilesApi) Untrash() http.Handler {
 
 		err = api.useFileRepository.Untrash(user, idsString)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
 			return
 		}
 
@@ -277,38 +413,30 @@ func (api *FilesApi) Delete() http.Handler {
 
 		err := helper.Decoder(r.Body).Decode(&ids)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
 		if ids.Ids == nil {
-			http.Error(w, repository.ErrMissingIdsField.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, repository.ErrMissingIdsField, http.StatusBadRequest)
 			return
 		}
 
 		// back to body
 		body, err := json.Marshal(ids)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
 		idsString := string(body)
 
-		_, err = api.useFileRepository.Delete(user, idsString)
+		err = api.useFileStorage.Delete(user, idsString)
 		if err != nil {
 			helper.ReturnErr(w, err, http.StatusInternalServerError)
 			return
 		}
 
-		//TODO remove if no reference
-
-		// filesPath := filepath.Join(config.Configuration.ResourcesPath, config.Configuration.FilesFolder)
-
-		// for _, file := range *files {
-		// 	_ = os.Remove(filepath.Join(filesPath, file.Digest))
-		// }
-
 		helper.SetJsonResponse(w, http.StatusOK, map[string]string{"status": "OK"})
 	})
 }
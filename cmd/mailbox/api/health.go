@@ -1,15 +1,59 @@
 package api
 
 import (
+	"cargomail/cmd/mailbox/api/helper"
+	"cargomail/internal/mailbox/repository"
+	"cargomail/internal/mailbox/storage"
 	"cargomail/internal/shared/config"
 	"net/http"
 )
 
 type HealthApi struct {
+	useHealthRepository repository.UseHealthRepository
+	useHealthStorage    storage.UseHealthStorage
 }
 
+type componentHealth struct {
+	Status string `json:"status"`
+	Err    string `json:"error,omitempty"`
+}
+
+type readiness struct {
+	Status     string                      `json:"status"`
+	Components map[string]*componentHealth `json:"components"`
+}
+
+// Healthcheck is the liveness probe, it doesn't touch the dependencies.
 func (api *HealthApi) Healthcheck() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(config.Configuration.DomainName))
 	})
 }
+
+// Ready is the readiness probe, it checks the database answers and the blob storage is writable. Any
+// component down fails it with 503.
+func (api *HealthApi) Ready() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ready := &readiness{Status: "ok", Components: map[string]*componentHealth{}}
+
+		check := func(component string, err error) {
+			if err != nil {
+				ready.Status = "unavailable"
+				ready.Components[component] = &componentHealth{Status: "down", Err: err.Error()}
+				return
+			}
+
+			ready.Components[component] = &componentHealth{Status: "ok"}
+		}
+
+		check("database", api.useHealthRepository.Check())
+		check("storage", api.useHealthStorage.Check())
+
+		statusCode := http.StatusOK
+		if ready.Status != "ok" {
+			statusCode = http.StatusServiceUnavailable
+		}
+
+		helper.SetJsonResponse(w, statusCode, ready)
+	})
+}
@@ -0,0 +1,210 @@
+package helper
+
+import (
+	"cargomail/internal/mailbox/repository"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// ErrorResponse is the envelope of the error responses of the API, the code is stable and meant for the
+// clients to branch on, the message is for humans.
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+type ErrorBody struct {
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// detailedError is the error carrying the details the client acts on, e.g. the id of the conflicting
+// contact to offer a merge with.
+type detailedError interface {
+	Details() map[string]string
+}
+
+// NewErrorResponse wraps the error in the envelope, with the code of the registry.
+func NewErrorResponse(err error, statusCode int) *ErrorResponse {
+	response := &ErrorResponse{
+		Error: ErrorBody{
+			Code:    ErrorCode(err, statusCode),
+			Message: err.Error(),
+		},
+	}
+
+	var detailed detailedError
+	if errors.As(err, &detailed) {
+		response.Error.Details = detailed.Details()
+	}
+
+	return response
+}
+
+// errorCodes is the registry of the machine-readable codes, the first error the returned one wraps wins.
+// The errors not listed get the code of the HTTP status, e.g. bad_request.
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{repository.ErrUsernameAlreadyTaken, "username_taken"},
+	{repository.ErrUsernameNotFound, "username_not_found"},
+	{repository.ErrInvalidCredentials, "invalid_credentials"},
+	{repository.ErrMissingUserContext, "missing_user_context"},
+	{repository.ErrInternalServer, "internal_error"},
+	{repository.ErrRateLimited, "rate_limited"},
+	{repository.ErrServerBusy, "server_busy"},
+	{repository.ErrInvalidOrMissingSession, "invalid_session"},
+	{repository.ErrMissingSessionCookie, "missing_session_cookie"},
+	{repository.ErrUnsupportedEncoding, "unsupported_encoding"},
+	{repository.ErrNotFound, "not_found"},
+	{repository.ErrFailedValidationResponse, "failed_validation"},
+	{repository.ErrContactNotFound, "contact_not_found"},
+	{repository.ErrDuplicateContact, "duplicate_contact"},
+	{repository.ErrInvalidEmailAddress, "invalid_email_address"},
+	{repository.ErrMultiplePrimaryEmails, "multiple_primary_emails"},
+	{repository.ErrPrimaryEmailMismatch, "primary_email_mismatch"},
+	{repository.ErrEmailAddressNotFound, "email_address_not_found"},
+	{repository.ErrLastEmailAddress, "last_email_address"},
+	{repository.ErrInvalidPhoneNumber, "invalid_phone_number"},
+	{repository.ErrContactGroupNotFound, "contact_group_not_found"},
+	{repository.ErrDuplicateContactGroup, "duplicate_contact_group"},
+	{repository.ErrContactGroupWrongName, "invalid_contact_group_name"},
+	{repository.ErrInvalidCSV, "invalid_csv"},
+	{repository.ErrMissingEmailColumn, "missing_email_column"},
+	{repository.ErrTooManyCSVRows, "too_many_csv_rows"},
+	{repository.ErrBlobNotFound, "blob_not_found"},
+	{repository.ErrBlobWrongName, "invalid_blob_name"},
+	{repository.ErrFileNotFound, "file_not_found"},
+	{repository.ErrFileWrongName, "invalid_file_name"},
+	{repository.ErrDraftNotFound, "draft_not_found"},
+	{repository.ErrDraftVersionNotFound, "draft_version_not_found"},
+	{repository.ErrAttachmentNotFound, "attachment_not_found"},
+	{repository.ErrMissingUriField, "missing_uri"},
+	{repository.ErrMissingSender, "missing_sender"},
+	{repository.ErrInvalidSender, "invalid_sender"},
+	{repository.ErrMissingRecipients, "missing_recipients"},
+	{repository.ErrInvalidRecipients, "invalid_recipients"},
+	{repository.ErrRecipientNotFound, "recipient_not_found"},
+	{repository.ErrMessageNotFound, "message_not_found"},
+	{repository.ErrThreadNotFound, "thread_not_found"},
+	{repository.ErrMissingIdsField, "missing_ids"},
+	{repository.ErrMissingIdField, "missing_id"},
+	{repository.ErrMissingDigestField, "missing_digest"},
+	{repository.ErrMissingPayloadField, "missing_payload"},
+	{repository.ErrMissingHeadersField, "missing_headers"},
+	{repository.ErrMissingStateField, "missing_state"},
+	{repository.ErrMissingNameField, "missing_name"},
+	{repository.ErrMissingHashField, "missing_hash"},
+	{repository.ErrMissingSearchQuery, "missing_search_query"},
+	{repository.ErrWrongResourceDigest, "wrong_resource_digest"},
+	{repository.ErrCorruptContent, "corrupt_content"},
+	{repository.ErrInvalidDigest, "invalid_digest"},
+	{repository.ErrEmptyPayload, "empty_payload"},
+	{repository.ErrMissingContentType, "missing_content_type"},
+	{repository.ErrUnknownMessageType, "unknown_message_type"},
+	{repository.ErrUnknownSearchIndex, "unknown_search_index"},
+	{repository.ErrUnknownResource, "unknown_resource"},
+	{repository.ErrQuotaExceeded, "quota_exceeded"},
+	{repository.ErrUploadNotFound, "upload_not_found"},
+	{repository.ErrUploadOffsetMismatch, "upload_offset_mismatch"},
+	{repository.ErrUploadIncomplete, "upload_incomplete"},
+	{repository.ErrUploadTooLarge, "upload_too_large"},
+	{repository.ErrUploadHashMismatch, "upload_hash_mismatch"},
+	{repository.ErrInvalidContentRange, "invalid_content_range"},
+	{repository.ErrInvalidCursor, "invalid_cursor"},
+	{repository.ErrInvalidSort, "invalid_sort"},
+	{repository.ErrInvalidState, "invalid_state"},
+	{repository.ErrInvalidLimit, "invalid_limit"},
+	{repository.ErrInvalidTimestamp, "invalid_timestamp"},
+	{repository.ErrInvalidDateRange, "invalid_date_range"},
+	{repository.ErrInvalidContentType, "invalid_content_type"},
+	{repository.ErrInvalidImage, "invalid_image"},
+	{repository.ErrApiKeyNotFound, "api_key_not_found"},
+	{repository.ErrInvalidApiKey, "invalid_api_key"},
+	{repository.ErrDeviceNotFound, "device_not_found"},
+	{repository.ErrInvalidRefreshToken, "invalid_refresh_token"},
+	{repository.ErrWeakPassword, "weak_password"},
+	{repository.ErrInvalidScope, "invalid_scope"},
+	{repository.ErrInsufficientScope, "insufficient_scope"},
+	{repository.ErrInvalidExpiry, "invalid_expiry"},
+	{repository.ErrInvalidSignature, "invalid_signature"},
+	{repository.ErrSignatureExpired, "signature_expired"},
+	{repository.ErrInvalidIdempotencyKey, "invalid_idempotency_key"},
+	{repository.ErrIdempotencyKeyInFlight, "idempotency_key_in_flight"},
+	{repository.ErrIdempotencyKeyReused, "idempotency_key_reused"},
+	{repository.ErrInvalidAuditAction, "invalid_audit_action"},
+	{repository.ErrAccountLocked, "account_locked"},
+	{repository.ErrInvalidSetting, "invalid_setting"},
+	{repository.ErrInvalidHistoryId, "invalid_history_id"},
+	{repository.ErrTooManyStreams, "too_many_streams"},
+	{repository.ErrWebhookNotFound, "webhook_not_found"},
+	{repository.ErrInvalidWebhookUrl, "invalid_webhook_url"},
+	{repository.ErrTooManyWebhooks, "too_many_webhooks"},
+	{repository.ErrSavedSearchNotFound, "saved_search_not_found"},
+	{repository.ErrDuplicateSavedSearch, "duplicate_saved_search"},
+	{repository.ErrSavedSearchWrongName, "invalid_saved_search_name"},
+	{repository.ErrInvalidSearchQuery, "invalid_search_query"},
+	{repository.ErrTooManySavedSearches, "too_many_saved_searches"},
+	{repository.ErrSmtpRelayNotConfigured, "smtp_relay_not_configured"},
+	{repository.ErrSmtpTemporaryFailure, "smtp_temporary_failure"},
+	{repository.ErrSmtpPermanentFailure, "smtp_permanent_failure"},
+	{repository.ErrUnknownPeer, "unknown_peer"},
+	{repository.ErrMissingMessageId, "missing_message_id"},
+	{repository.ErrPeerKeyNotFound, "peer_key_not_found"},
+	{repository.ErrInvalidPeerKey, "invalid_peer_key"},
+	{repository.ErrMissingTagField, "missing_tag"},
+	{repository.ErrBlobWrongTag, "invalid_blob_tag"},
+	{repository.ErrFileWrongTag, "invalid_file_tag"},
+	{repository.ErrCollectionNotFound, "collection_not_found"},
+	{repository.ErrDuplicateCollection, "duplicate_collection"},
+	{repository.ErrCollectionWrongName, "invalid_collection_name"},
+	{repository.ErrCollectionCycle, "collection_cycle"},
+	{repository.ErrCollectionNotEmpty, "collection_not_empty"},
+	{repository.ErrMissingLabelIdsField, "missing_label_ids"},
+	{repository.ErrConflictingLabelIds, "conflicting_label_ids"},
+	{repository.ErrLabelNotFound, "label_not_found"},
+	{repository.ErrDuplicateLabel, "duplicate_label"},
+	{repository.ErrLabelWrongName, "invalid_label_name"},
+	{repository.ErrLabelWrongColor, "invalid_label_color"},
+	{repository.ErrSystemLabel, "system_label"},
+	{repository.ErrUnresolvedPlaceholder, "unresolved_placeholder"},
+}
+
+// ErrorCode returns the code of the error in the registry, or the one of the status.
+func ErrorCode(err error, statusCode int) string {
+	for _, entry := range errorCodes {
+		if errors.Is(err, entry.err) {
+			return entry.code
+		}
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) || strings.HasPrefix(err.Error(), "json: unknown field") {
+		return "invalid_json"
+	}
+
+	if text := http.StatusText(statusCode); len(text) > 0 {
+		return strings.ReplaceAll(strings.ToLower(text), " ", "_")
+	}
+
+	return "error"
+}
+
+func ReturnErr(w http.ResponseWriter, err error, code int) {
+	// the body cut by the size limit is too large, whichever handler read it
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		code = http.StatusRequestEntityTooLarge
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+
+	json.NewEncoder(w).Encode(NewErrorResponse(err, code))
+}
@@ -20,17 +20,6 @@ func ToAscii(str string) (string, error) {
 	return result, nil
 }
 
-func ReturnErr(w http.ResponseWriter, err error, code int) {
-	errorMessage := struct {
-		Err string
-	}{
-		Err: err.Error(),
-	}
-
-	w.WriteHeader(code)
-	json.NewEncoder(w).Encode(errorMessage)
-}
-
 func SetJsonHeader(w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 }
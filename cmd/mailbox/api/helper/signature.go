@@ -0,0 +1,137 @@
+package helper
+
+import (
+	"bytes"
+	"cargomail/internal/mailbox/repository"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	b64 "encoding/base64"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvelopeSignatureTolerance is how far the time of the federated envelope signature may be off, the older
+// signatures are not accepted so a captured request can't be replayed later.
+const EnvelopeSignatureTolerance = 5 * time.Minute
+
+// SignUrl returns the signed, time-limited query of the download path of the user, i.e. the path opens
+// without a session until the expiry. The signature is the HMAC of the path, the expiry and the user id.
+func SignUrl(key []byte, path string, userId int64, expiresAt time.Time) string {
+	exp := expiresAt.Unix()
+
+	query := url.Values{}
+	query.Set("uid", strconv.FormatInt(userId, 10))
+	query.Set("exp", strconv.FormatInt(exp, 10))
+	query.Set("sig", urlSignature(key, path, userId, exp))
+
+	return path + "?" + query.Encode()
+}
+
+// VerifyUrlSignature checks the signed query of the path and returns the id of the user who signed it.
+func VerifyUrlSignature(key []byte, path string, query url.Values, now time.Time) (int64, error) {
+	userId, err := strconv.ParseInt(query.Get("uid"), 10, 64)
+	if err != nil {
+		return 0, repository.ErrInvalidSignature
+	}
+
+	exp, err := strconv.ParseInt(query.Get("exp"), 10, 64)
+	if err != nil {
+		return 0, repository.ErrInvalidSignature
+	}
+
+	signature := urlSignature(key, path, userId, exp)
+
+	if !hmac.Equal([]byte(signature), []byte(query.Get("sig"))) {
+		return 0, repository.ErrInvalidSignature
+	}
+
+	if now.Unix() >= exp {
+		return 0, repository.ErrSignatureExpired
+	}
+
+	return userId, nil
+}
+
+func urlSignature(key []byte, path string, userId, exp int64) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(path + "\n" + strconv.FormatInt(exp, 10) + "\n" + strconv.FormatInt(userId, 10)))
+
+	return b64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// CanonicalEnvelope returns the signed form of the envelope, its compact JSON without the HTML escaping, the
+// fields in the order of the wire format and the keys of the headers sorted. The receiver signs the envelope
+// it decoded again, so the formatting of the request body doesn't matter.
+func CanonicalEnvelope(envelope *repository.FederationEnvelope) ([]byte, error) {
+	var buf bytes.Buffer
+
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+
+	err := encoder.Encode(envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// SignEnvelope returns the X-Cargomail-Signature of the envelope, t=<unix time>,ed25519=<signature>, the
+// Ed25519 signature of "<unix time>." and the canonical envelope by the key of the instance.
+func SignEnvelope(key ed25519.PrivateKey, envelope *repository.FederationEnvelope, now time.Time) (string, error) {
+	canonical, err := CanonicalEnvelope(envelope)
+	if err != nil {
+		return "", err
+	}
+
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	signature := ed25519.Sign(key, append([]byte(timestamp+"."), canonical...))
+
+	return "t=" + timestamp + ",ed25519=" + b64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// VerifyEnvelopeSignature checks the X-Cargomail-Signature of the envelope by the key of the peer.
+func VerifyEnvelopeSignature(key ed25519.PublicKey, envelope *repository.FederationEnvelope, header string, now time.Time) error {
+	var timestamp, signature string
+
+	for _, field := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+
+		switch name {
+		case "t":
+			timestamp = value
+		case "ed25519":
+			signature = value
+		}
+	}
+
+	t, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return repository.ErrInvalidSignature
+	}
+
+	sig, err := b64.RawURLEncoding.DecodeString(signature)
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return repository.ErrInvalidSignature
+	}
+
+	canonical, err := CanonicalEnvelope(envelope)
+	if err != nil {
+		return repository.ErrInvalidSignature
+	}
+
+	if !ed25519.Verify(key, append([]byte(timestamp+"."), canonical...), sig) {
+		return repository.ErrInvalidSignature
+	}
+
+	skew := now.Sub(time.Unix(t, 0))
+	if skew > EnvelopeSignatureTolerance || skew < -EnvelopeSignatureTolerance {
+		return repository.ErrSignatureExpired
+	}
+
+	return nil
+}
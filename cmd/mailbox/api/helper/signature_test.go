@@ -0,0 +1,68 @@
+package helper
+
+import (
+	"cargomail/internal/mailbox/repository"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+)
+
+func testEnvelope() *repository.FederationEnvelope {
+	return &repository.FederationEnvelope{
+		Sender:     "alice@example.org",
+		Recipients: []string{"bob@example.net"},
+		Payload: &repository.MessagePart{
+			Headers: map[string]interface{}{"From": "alice@example.org", "Message-ID": "<1@example.org>"},
+			Body:    &repository.Body{Data: "Hello Bob"},
+		},
+	}
+}
+
+func TestVerifyEnvelopeSignature(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+
+	signature, err := SignEnvelope(private, testEnvelope(), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := testEnvelope()
+	tampered.Recipients = append(tampered.Recipients, "carol@example.net")
+
+	tests := []struct {
+		name      string
+		key       ed25519.PublicKey
+		envelope  *repository.FederationEnvelope
+		signature string
+		now       time.Time
+		want      error
+	}{
+		{"signed", public, testEnvelope(), signature, now, nil},
+		{"unsigned", public, testEnvelope(), "", now, repository.ErrInvalidSignature},
+		{"no signature", public, testEnvelope(), "t=" + signature[2:12], now, repository.ErrInvalidSignature},
+		{"tampered", public, tampered, signature, now, repository.ErrInvalidSignature},
+		{"key of another peer", other, testEnvelope(), signature, now, repository.ErrInvalidSignature},
+		{"replayed", public, testEnvelope(), signature, now.Add(EnvelopeSignatureTolerance + time.Minute), repository.ErrSignatureExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyEnvelopeSignature(tt.key, tt.envelope, tt.signature, tt.now)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
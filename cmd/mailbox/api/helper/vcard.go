@@ -0,0 +1,136 @@
+package helper
+
+import (
+	"cargomail/internal/mailbox/repository"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// the TYPE values of vCard 4.0 (RFC 6350), the "mobile" of the contacts is the "cell"
+var vCardTelTypes = map[string]string{
+	"mobile": "cell",
+	"cell":   "cell",
+	"home":   "home",
+	"work":   "work",
+	"voice":  "voice",
+	"fax":    "fax",
+	"pager":  "pager",
+	"text":   "text",
+	"video":  "video",
+}
+
+var vCardEmailTypes = map[string]string{
+	"home": "home",
+	"work": "work",
+}
+
+var vCardEscaper = strings.NewReplacer(`\`, `\\`, ",", `\,`, ";", `\;`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+// WriteVCard writes the contact as a vCard 4.0
+func WriteVCard(w io.Writer, contact *repository.Contact) error {
+	var firstName, lastName string
+
+	if contact.FirstName != nil {
+		firstName = *contact.FirstName
+	}
+
+	if contact.LastName != nil {
+		lastName = *contact.LastName
+	}
+
+	fn := strings.TrimSpace(firstName + " " + lastName)
+	if len(fn) == 0 && contact.EmailAddress != nil {
+		fn = *contact.EmailAddress
+	}
+
+	lines := []string{
+		"BEGIN:VCARD",
+		"VERSION:4.0",
+		"UID:urn:uuid:" + uuidFromId(contact.Id),
+		"FN:" + vCardEscaper.Replace(fn),
+		"N:" + vCardEscaper.Replace(lastName) + ";" + vCardEscaper.Replace(firstName) + ";;;",
+	}
+
+	emails := contact.EmailAddresses
+	if emails == nil && contact.EmailAddress != nil {
+		emails = repository.ContactEmails{{Address: *contact.EmailAddress, Primary: true}}
+	}
+
+	for _, email := range emails {
+		params := ""
+		if email.Primary {
+			params += ";PREF=1"
+		}
+		if t, ok := vCardEmailTypes[strings.ToLower(email.Type)]; ok {
+			params += ";TYPE=" + t
+		}
+		lines = append(lines, "EMAIL"+params+":"+vCardEscaper.Replace(email.Address))
+	}
+
+	for _, phone := range contact.PhoneNumbers {
+		params := ";VALUE=uri"
+		if t, ok := vCardTelTypes[strings.ToLower(phone.Type)]; ok {
+			params += ";TYPE=" + t
+		}
+		lines = append(lines, "TEL"+params+":tel:"+phone.Number)
+	}
+
+	if contact.Notes != nil && len(*contact.Notes) > 0 {
+		lines = append(lines, "NOTE:"+vCardEscaper.Replace(*contact.Notes))
+	}
+
+	rev := contact.CreatedAt
+	if contact.ModifiedAt != nil {
+		rev = *contact.ModifiedAt
+	}
+
+	lines = append(lines,
+		"REV:"+time.UnixMilli(int64(rev)).UTC().Format("20060102T150405Z"),
+		"END:VCARD")
+
+	for _, line := range lines {
+		_, err := io.WriteString(w, foldVCardLine(line))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// foldVCardLine folds the line after 75 octets, without splitting the UTF-8 characters
+func foldVCardLine(line string) string {
+	var b strings.Builder
+
+	limit := 75
+
+	for len(line) > limit {
+		i := limit
+		for i > 0 && !utf8.RuneStart(line[i]) {
+			i--
+		}
+
+		b.WriteString(line[:i])
+		b.WriteString("\r\n ")
+		line = line[i:]
+
+		// the leading space of the continuation line counts
+		limit = 74
+	}
+
+	b.WriteString(line)
+	b.WriteString("\r\n")
+
+	return b.String()
+}
+
+// uuidFromId formats the 32 hex digits of the id as an UUID
+func uuidFromId(id string) string {
+	if len(id) != 32 {
+		return id
+	}
+
+	return id[0:8] + "-" + id[8:12] + "-" + id[12:16] + "-" + id[16:20] + "-" + id[20:32]
+}
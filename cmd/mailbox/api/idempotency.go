@@ -0,0 +1,138 @@
+package api
+
+import (
+	"bytes"
+	"cargomail/cmd/mailbox/api/helper"
+	"cargomail/internal/mailbox/repository"
+	"cargomail/internal/shared/config"
+	"cargomail/internal/shared/provider"
+	"net/http"
+	"strconv"
+)
+
+const (
+	maxIdempotencyKeyLength = 255
+	maxIdempotentResponse   = 1 << 20 // the larger responses are not replayed
+)
+
+type IdempotencyApi struct {
+	useIdempotencyRepository repository.UseIdempotencyRepository
+}
+
+// idempotentWriter keeps a copy of the response to replay it.
+type idempotentWriter struct {
+	http.ResponseWriter
+	status   int
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (w *idempotentWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *idempotentWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	if !w.overflow {
+		if w.body.Len()+len(b) > maxIdempotentResponse {
+			w.overflow = true
+			w.body.Reset()
+		} else {
+			w.body.Write(b)
+		}
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+// middleware
+//
+// Idempotent replays the response of the first successful request sent with the same Idempotency-Key,
+// e.g. a client retrying an upload after a network error doesn't create the resource twice. The requests
+// without the header are served as usual.
+func (api *Api) Idempotent(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if len(key) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			helper.ReturnErr(w, repository.ErrInvalidIdempotencyKey, http.StatusBadRequest)
+			return
+		}
+
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		useIdempotencyRepository := api.Idempotency.useIdempotencyRepository
+
+		first, err := useIdempotencyRepository.Reserve(user, key, r.Method, r.URL.Path, config.IdempotencyKeyTTL())
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		if first != nil {
+			switch {
+			case first.Method != r.Method || first.Path != r.URL.Path:
+				helper.ReturnErr(w, repository.ErrIdempotencyKeyReused, http.StatusUnprocessableEntity)
+			case first.Status == 0:
+				helper.ReturnErr(w, repository.ErrIdempotencyKeyInFlight, http.StatusConflict)
+			default:
+				if first.ContentType != nil {
+					w.Header().Set("Content-Type", *first.ContentType)
+				}
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.Header().Set("Content-Length", strconv.Itoa(len(first.Response)))
+				w.WriteHeader(first.Status)
+				w.Write(first.Response)
+			}
+			return
+		}
+
+		recorder := &idempotentWriter{ResponseWriter: w}
+
+		// the key is released if the handler panics too
+		completed := false
+		defer func() {
+			if !completed {
+				err := useIdempotencyRepository.Release(user, key)
+				if err != nil {
+					provider.Logf(r.Context(), "idempotency key release error: %v", err)
+				}
+			}
+		}()
+
+		next.ServeHTTP(recorder, r)
+
+		// only the successful responses are replayed, the failed requests may be retried with the key
+		if recorder.status < 200 || recorder.status >= 300 || recorder.overflow {
+			return
+		}
+
+		contentType := recorder.Header().Get("Content-Type")
+
+		err = useIdempotencyRepository.Complete(user, key, &repository.IdempotentResponse{
+			Status:      recorder.status,
+			ContentType: &contentType,
+			Response:    recorder.body.Bytes(),
+		})
+		if err != nil {
+			provider.Logf(r.Context(), "idempotency key completion error: %v", err)
+			return
+		}
+
+		completed = true
+	})
+}
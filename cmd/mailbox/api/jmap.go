@@ -0,0 +1,425 @@
+package api
+
+import (
+	"cargomail/cmd/mailbox/api/helper"
+	"cargomail/internal/mailbox/repository"
+	"cargomail/internal/mailbox/storage"
+	"cargomail/internal/shared/config"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// The JMAP (RFC 8620, RFC 8621) subset is read-only, the session, the Mailbox/get, the Email/query and the
+// Email/get, over the messages and the drafts.
+const (
+	jmapCoreCapability = "urn:ietf:params:jmap:core"
+	jmapMailCapability = "urn:ietf:params:jmap:mail"
+
+	jmapMaxCallsInRequest = 16
+	jmapMaxObjectsInGet   = 500
+
+	// the session object of a user never changes
+	jmapSessionState = "0"
+)
+
+var errJmapInvalidResultReference = errors.New("invalid result reference")
+
+type JmapApi struct {
+	useMessageRepository repository.UseMessageRepository
+	useDraftRepository   repository.UseDraftRepository
+	useMessageStorage    storage.UseMessageStorage
+	useDraftStorage      storage.UseDraftStorage
+	useEventRepository   repository.UseEventRepository
+}
+
+// jmapInvocation is the [name, arguments, method call id] of a method call or response.
+type jmapInvocation struct {
+	Name   string
+	Args   json.RawMessage
+	CallId string
+}
+
+func (i jmapInvocation) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{i.Name, i.Args, i.CallId})
+}
+
+func (i *jmapInvocation) UnmarshalJSON(data []byte) error {
+	var v []json.RawMessage
+
+	err := json.Unmarshal(data, &v)
+	if err != nil {
+		return err
+	}
+
+	if len(v) != 3 {
+		return errors.New("invocation is not [name, arguments, method call id]")
+	}
+
+	err = json.Unmarshal(v[0], &i.Name)
+	if err != nil {
+		return err
+	}
+
+	i.Args = v[1]
+
+	return json.Unmarshal(v[2], &i.CallId)
+}
+
+type jmapRequest struct {
+	Using       []string          `json:"using"`
+	MethodCalls []jmapInvocation  `json:"methodCalls"`
+	CreatedIds  map[string]string `json:"createdIds,omitempty"`
+}
+
+type jmapResponse struct {
+	MethodResponses []jmapInvocation `json:"methodResponses"`
+	SessionState    string           `json:"sessionState"`
+}
+
+// jmapMethodError is the method level error, e.g. {"type": "unknownMethod"}.
+type jmapMethodError struct {
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+}
+
+func (e *jmapMethodError) Error() string {
+	if len(e.Description) > 0 {
+		return e.Type + ": " + e.Description
+	}
+
+	return e.Type
+}
+
+func jmapInvalidArguments(description string) error {
+	return &jmapMethodError{Type: "invalidArguments", Description: description}
+}
+
+type jmapMethod struct {
+	capability string
+	call       func(api *JmapApi, user *repository.User, args json.RawMessage) (interface{}, error)
+}
+
+var jmapMethods = map[string]jmapMethod{
+	"Core/echo": {jmapCoreCapability, func(api *JmapApi, user *repository.User, args json.RawMessage) (interface{}, error) {
+		return args, nil
+	}},
+	"Mailbox/get": {jmapMailCapability, (*JmapApi).mailboxGet},
+	"Email/query": {jmapMailCapability, (*JmapApi).emailQuery},
+	"Email/get":   {jmapMailCapability, (*JmapApi).emailGet},
+}
+
+// jmapProblem answers the request level errors as the RFC 7807 problem details.
+func jmapProblem(w http.ResponseWriter, problemType, detail string, extra map[string]interface{}) {
+	problem := map[string]interface{}{
+		"type":   "urn:ietf:params:jmap:error:" + problemType,
+		"status": http.StatusBadRequest,
+		"detail": detail,
+	}
+
+	for k, v := range extra {
+		problem[k] = v
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(problem)
+}
+
+func jmapAccountId(user *repository.User) string {
+	return strconv.FormatInt(user.Id, 10)
+}
+
+func jmapBaseUrl(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	return scheme + "://" + r.Host
+}
+
+// Session serves the GET /.well-known/jmap, the session resource of the user.
+func (api *JmapApi) Session() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		accountId := jmapAccountId(user)
+		baseUrl := jmapBaseUrl(r)
+
+		helper.SetJsonResponse(w, http.StatusOK, map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				jmapCoreCapability: map[string]interface{}{
+					"maxSizeUpload":         config.MaxUploadBytes(),
+					"maxConcurrentUpload":   1,
+					"maxSizeRequest":        config.DefaultMaxBodySize << 20,
+					"maxConcurrentRequests": 4,
+					"maxCallsInRequest":     jmapMaxCallsInRequest,
+					"maxObjectsInGet":       jmapMaxObjectsInGet,
+					"maxObjectsInSet":       0,
+					"collationAlgorithms":   []string{},
+				},
+				jmapMailCapability: map[string]interface{}{},
+			},
+			"accounts": map[string]interface{}{
+				accountId: map[string]interface{}{
+					"name":       user.Username + "@" + config.Configuration.DomainName,
+					"isPersonal": true,
+					"isReadOnly": true,
+					"accountCapabilities": map[string]interface{}{
+						jmapCoreCapability: map[string]interface{}{},
+						jmapMailCapability: map[string]interface{}{
+							"maxMailboxesPerEmail":       nil,
+							"maxMailboxDepth":            1,
+							"maxSizeMailboxName":         255,
+							"maxSizeAttachmentsPerEmail": config.MaxUploadBytes(),
+							"emailQuerySortOptions":      []string{"receivedAt"},
+							"mayCreateTopLevelMailbox":   false,
+						},
+					},
+				},
+			},
+			"primaryAccounts": map[string]string{
+				jmapCoreCapability: accountId,
+				jmapMailCapability: accountId,
+			},
+			"username":    user.Username + "@" + config.Configuration.DomainName,
+			"apiUrl":      baseUrl + "/api/v1/jmap",
+			"downloadUrl": baseUrl + "/api/v1/blobs/{blobId}?name={name}&type={type}",
+			// the subset takes no uploads and pushes no state changes
+			"uploadUrl":      "",
+			"eventSourceUrl": "",
+			"state":          jmapSessionState,
+		})
+	})
+}
+
+// Api serves the POST /api/v1/jmap, the method calls are run in order, the arguments of a call may refer
+// to the results of the previous ones.
+func (api *JmapApi) Api() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		var request jmapRequest
+
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			jmapProblem(w, "notRequest", err.Error(), nil)
+			return
+		}
+
+		using := map[string]bool{}
+
+		for _, capability := range request.Using {
+			switch capability {
+			case jmapCoreCapability, jmapMailCapability:
+				using[capability] = true
+			default:
+				jmapProblem(w, "unknownCapability", "unknown capability "+capability, nil)
+				return
+			}
+		}
+
+		if len(request.MethodCalls) > jmapMaxCallsInRequest {
+			jmapProblem(w, "limit", "too many method calls", map[string]interface{}{"limit": "maxCallsInRequest"})
+			return
+		}
+
+		response := &jmapResponse{MethodResponses: []jmapInvocation{}, SessionState: jmapSessionState}
+
+		for _, call := range request.MethodCalls {
+			response.MethodResponses = append(response.MethodResponses, api.call(user, using, call, response.MethodResponses))
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, response)
+	})
+}
+
+func (api *JmapApi) call(user *repository.User, using map[string]bool, call jmapInvocation, responses []jmapInvocation) jmapInvocation {
+	result, err := func() (interface{}, error) {
+		method, ok := jmapMethods[call.Name]
+		if !ok || !using[method.capability] {
+			return nil, &jmapMethodError{Type: "unknownMethod"}
+		}
+
+		args, err := resolveResultReferences(call.Args, responses)
+		if err != nil {
+			return nil, err
+		}
+
+		if method.capability == jmapMailCapability {
+			var account struct {
+				AccountId string `json:"accountId"`
+			}
+
+			err = json.Unmarshal(args, &account)
+			if err != nil {
+				return nil, jmapInvalidArguments(err.Error())
+			}
+
+			if account.AccountId != jmapAccountId(user) {
+				return nil, &jmapMethodError{Type: "accountNotFound"}
+			}
+		}
+
+		return method.call(api, user, args)
+	}()
+
+	if err == nil {
+		args, err := json.Marshal(result)
+		if err == nil {
+			return jmapInvocation{Name: call.Name, Args: args, CallId: call.CallId}
+		}
+	}
+
+	var methodErr *jmapMethodError
+	if !errors.As(err, &methodErr) {
+		log.Printf("jmap %s: %v", call.Name, err)
+		methodErr = &jmapMethodError{Type: "serverFail"}
+	}
+
+	args, _ := json.Marshal(methodErr)
+
+	return jmapInvocation{Name: "error", Args: args, CallId: call.CallId}
+}
+
+// resolveResultReferences replaces the "#name": {"resultOf", "name", "path"} arguments by the values the
+// path points to in the result of the previous call.
+func resolveResultReferences(args json.RawMessage, responses []jmapInvocation) (json.RawMessage, error) {
+	var argsMap map[string]json.RawMessage
+
+	err := json.Unmarshal(args, &argsMap)
+	if err != nil {
+		return nil, jmapInvalidArguments(err.Error())
+	}
+
+	resolved := false
+
+	for key, value := range argsMap {
+		name, ok := strings.CutPrefix(key, "#")
+		if !ok {
+			continue
+		}
+
+		if _, ok := argsMap[name]; ok {
+			return nil, jmapInvalidArguments("both " + name + " and " + key)
+		}
+
+		var ref struct {
+			ResultOf string `json:"resultOf"`
+			Name     string `json:"name"`
+			Path     string `json:"path"`
+		}
+
+		err = json.Unmarshal(value, &ref)
+		if err != nil {
+			return nil, jmapInvalidArguments(err.Error())
+		}
+
+		result, err := resultReference(responses, ref.ResultOf, ref.Name, ref.Path)
+		if err != nil {
+			return nil, &jmapMethodError{Type: "invalidResultReference", Description: err.Error()}
+		}
+
+		argsMap[name], err = json.Marshal(result)
+		if err != nil {
+			return nil, err
+		}
+
+		delete(argsMap, key)
+		resolved = true
+	}
+
+	if !resolved {
+		return args, nil
+	}
+
+	return json.Marshal(argsMap)
+}
+
+func resultReference(responses []jmapInvocation, resultOf, name, path string) (interface{}, error) {
+	for _, response := range responses {
+		if response.CallId != resultOf {
+			continue
+		}
+
+		if response.Name != name {
+			return nil, errJmapInvalidResultReference
+		}
+
+		var result interface{}
+
+		err := json.Unmarshal(response.Args, &result)
+		if err != nil {
+			return nil, err
+		}
+
+		return evaluatePointer(result, path)
+	}
+
+	return nil, errJmapInvalidResultReference
+}
+
+// evaluatePointer evaluates the JSON pointer of RFC 6901 with the "*" of RFC 8620, i.e. the rest of the
+// path is evaluated on each item of the array, the arrays the items result in are flattened.
+func evaluatePointer(value interface{}, path string) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	path, ok := strings.CutPrefix(path, "/")
+	if !ok {
+		return nil, errJmapInvalidResultReference
+	}
+
+	token, rest, found := strings.Cut(path, "/")
+	if found {
+		rest = "/" + rest
+	}
+
+	token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		item, ok := v[token]
+		if !ok {
+			return nil, errJmapInvalidResultReference
+		}
+		return evaluatePointer(item, rest)
+	case []interface{}:
+		if token == "*" {
+			items := []interface{}{}
+			for _, item := range v {
+				result, err := evaluatePointer(item, rest)
+				if err != nil {
+					return nil, err
+				}
+				if results, ok := result.([]interface{}); ok {
+					items = append(items, results...)
+				} else {
+					items = append(items, result)
+				}
+			}
+			return items, nil
+		}
+
+		index, err := strconv.Atoi(token)
+		if err != nil || index < 0 || index >= len(v) {
+			return nil, errJmapInvalidResultReference
+		}
+		return evaluatePointer(v[index], rest)
+	default:
+		return nil, errJmapInvalidResultReference
+	}
+}
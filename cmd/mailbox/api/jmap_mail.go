@@ -0,0 +1,676 @@
+package api
+
+import (
+	"bytes"
+	"cargomail/internal/mailbox/repository"
+	"cargomail/internal/shared/config"
+	"encoding/json"
+	"errors"
+	"net/mail"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const jmapLabelMailboxPrefix = "label-"
+
+// jmapFolderMailboxes are the mailboxes of the folders of the messages, the drafts are of the "drafts".
+var jmapFolderMailboxes = []struct {
+	id     string
+	name   string
+	role   string
+	folder int
+}{
+	{"inbox", "Inbox", "inbox", 2},
+	{"drafts", "Drafts", "drafts", 0},
+	{"sent", "Sent", "sent", 1},
+	{"outbox", "In Progress", "", 3},
+}
+
+func jmapFolderMailbox(folder int) string {
+	for _, mailbox := range jmapFolderMailboxes {
+		if mailbox.folder == folder {
+			return mailbox.id
+		}
+	}
+
+	return ""
+}
+
+// jmapItem is a message or a draft as the Email/query and the Mailbox/get see it, without the payload.
+type jmapItem struct {
+	id         string
+	threadId   string
+	mailboxIds []string
+	keywords   map[string]bool
+	receivedAt time.Time
+}
+
+func newJmapItem(id string, payload *repository.MessagePart, mailbox string, labelIds *string, unread, starred, draft bool, receivedAt repository.Timestamp) *jmapItem {
+	item := &jmapItem{
+		id:         id,
+		threadId:   id,
+		mailboxIds: []string{mailbox},
+		keywords:   map[string]bool{},
+		receivedAt: receivedAt.Time(),
+	}
+
+	// the thread of a message is its X-Thread-ID, a message of no thread is a thread of its own
+	if payload != nil {
+		if threadUid, ok := payload.Headers["X-Thread-ID"].(string); ok && len(threadUid) > 0 {
+			item.threadId = threadUid
+		}
+	}
+
+	if labelIds != nil {
+		var labels []string
+		if json.Unmarshal([]byte(*labelIds), &labels) == nil {
+			for _, label := range labels {
+				item.mailboxIds = append(item.mailboxIds, jmapLabelMailboxPrefix+label)
+			}
+		}
+	}
+
+	if !unread {
+		item.keywords["$seen"] = true
+	}
+	if starred {
+		item.keywords["$flagged"] = true
+	}
+	if draft {
+		item.keywords["$draft"] = true
+	}
+
+	return item
+}
+
+func messageJmapItem(message *repository.Message) *jmapItem {
+	receivedAt := message.CreatedAt
+	if message.ReceivedAt != nil {
+		receivedAt = *message.ReceivedAt
+	}
+
+	return newJmapItem(message.Id, message.Payload, jmapFolderMailbox(int(message.Folder)), message.LabelIds, message.Unread, message.Starred, message.Folder == 0, receivedAt)
+}
+
+func draftJmapItem(draft *repository.Draft) *jmapItem {
+	return newJmapItem(draft.Id, draft.Payload, "drafts", draft.LabelIds, draft.Unread, draft.Starred, true, draft.CreatedAt)
+}
+
+// eachItem passes the messages and, with the drafts:read scope, the drafts to the each, and returns the
+// state of the emails.
+func (api *JmapApi) eachItem(user *repository.User, each func(*jmapItem)) (string, error) {
+	messageList, err := api.useMessageRepository.ListEach(user, -1, nil, func(message *repository.Message) error {
+		each(messageJmapItem(message))
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var draftHistory int64
+
+	if user.HasScope("drafts:read") {
+		draftList, err := api.useDraftRepository.ListEach(user, nil, func(draft *repository.Draft) error {
+			each(draftJmapItem(draft))
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+
+		draftHistory = draftList.History
+	}
+
+	return jmapState(messageList.History, draftHistory), nil
+}
+
+// jmapState is the state of the emails and the mailboxes, i.e. of the messages and the drafts.
+func jmapState(messageHistory, draftHistory int64) string {
+	return strconv.FormatInt(messageHistory, 10) + "-" + strconv.FormatInt(draftHistory, 10)
+}
+
+func (api *JmapApi) state(user *repository.User) (string, error) {
+	messageHistory, err := api.useEventRepository.LastHistoryId(user, "messages")
+	if err != nil {
+		return "", err
+	}
+
+	var draftHistory int64
+
+	if user.HasScope("drafts:read") {
+		draftHistory, err = api.useEventRepository.LastHistoryId(user, "drafts")
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return jmapState(messageHistory, draftHistory), nil
+}
+
+type jmapGetArgs struct {
+	AccountId  string    `json:"accountId"`
+	Ids        *[]string `json:"ids"`
+	Properties *[]string `json:"properties"`
+}
+
+type jmapGetResponse struct {
+	AccountId string                   `json:"accountId"`
+	State     string                   `json:"state"`
+	List      []map[string]interface{} `json:"list"`
+	NotFound  []string                 `json:"notFound"`
+}
+
+func decodeJmapArgs(args json.RawMessage, v interface{}) error {
+	err := json.Unmarshal(args, v)
+	if err != nil {
+		return jmapInvalidArguments(err.Error())
+	}
+
+	return nil
+}
+
+// selectProperties returns the properties of the object asked for, all of them by default, the id always.
+func selectProperties(object map[string]interface{}, properties *[]string) (map[string]interface{}, error) {
+	if properties == nil {
+		return object, nil
+	}
+
+	selected := map[string]interface{}{"id": object["id"]}
+
+	for _, property := range *properties {
+		value, ok := object[property]
+		if !ok {
+			return nil, jmapInvalidArguments("unknown property " + property)
+		}
+		selected[property] = value
+	}
+
+	return selected, nil
+}
+
+// mailboxGet is the Mailbox/get, the mailboxes of the folders and of the labels of the emails.
+func (api *JmapApi) mailboxGet(user *repository.User, args json.RawMessage) (interface{}, error) {
+	var getArgs jmapGetArgs
+
+	err := decodeJmapArgs(args, &getArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	type counts struct {
+		emails, unreadEmails   int
+		threads, unreadThreads map[string]bool
+	}
+
+	mailboxCounts := map[string]*counts{}
+	labels := []string{}
+
+	state, err := api.eachItem(user, func(item *jmapItem) {
+		for _, mailboxId := range item.mailboxIds {
+			c, ok := mailboxCounts[mailboxId]
+			if !ok {
+				c = &counts{threads: map[string]bool{}, unreadThreads: map[string]bool{}}
+				mailboxCounts[mailboxId] = c
+
+				if strings.HasPrefix(mailboxId, jmapLabelMailboxPrefix) {
+					labels = append(labels, mailboxId)
+				}
+			}
+
+			c.emails++
+			c.threads[item.threadId] = true
+
+			if !item.keywords["$seen"] {
+				c.unreadEmails++
+				c.unreadThreads[item.threadId] = true
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(labels)
+
+	mailboxes := map[string]map[string]interface{}{}
+	order := []string{}
+
+	add := func(id, name string, role interface{}, sortOrder int) {
+		c, ok := mailboxCounts[id]
+		if !ok {
+			c = &counts{}
+		}
+
+		mailboxes[id] = map[string]interface{}{
+			"id":            id,
+			"name":          name,
+			"parentId":      nil,
+			"role":          role,
+			"sortOrder":     sortOrder,
+			"totalEmails":   c.emails,
+			"unreadEmails":  c.unreadEmails,
+			"totalThreads":  len(c.threads),
+			"unreadThreads": len(c.unreadThreads),
+			"myRights": map[string]bool{
+				"mayReadItems":   true,
+				"mayAddItems":    false,
+				"mayRemoveItems": false,
+				"maySetSeen":     false,
+				"maySetKeywords": false,
+				"mayCreateChild": false,
+				"mayRename":      false,
+				"mayDelete":      false,
+				"maySubmit":      false,
+			},
+			"isSubscribed": true,
+		}
+		order = append(order, id)
+	}
+
+	for i, mailbox := range jmapFolderMailboxes {
+		if mailbox.id == "drafts" && !user.HasScope("drafts:read") {
+			continue
+		}
+
+		var role interface{}
+		if len(mailbox.role) > 0 {
+			role = mailbox.role
+		}
+
+		add(mailbox.id, mailbox.name, role, i)
+	}
+
+	for _, label := range labels {
+		add(label, strings.TrimPrefix(label, jmapLabelMailboxPrefix), nil, len(jmapFolderMailboxes))
+	}
+
+	response := &jmapGetResponse{
+		AccountId: jmapAccountId(user),
+		State:     state,
+		List:      []map[string]interface{}{},
+		NotFound:  []string{},
+	}
+
+	ids := order
+	if getArgs.Ids != nil {
+		ids = *getArgs.Ids
+	}
+
+	for _, id := range ids {
+		mailbox, ok := mailboxes[id]
+		if !ok {
+			response.NotFound = append(response.NotFound, id)
+			continue
+		}
+
+		mailbox, err = selectProperties(mailbox, getArgs.Properties)
+		if err != nil {
+			return nil, err
+		}
+
+		response.List = append(response.List, mailbox)
+	}
+
+	return response, nil
+}
+
+type jmapEmailFilter struct {
+	InMailbox  *string    `json:"inMailbox"`
+	HasKeyword *string    `json:"hasKeyword"`
+	NotKeyword *string    `json:"notKeyword"`
+	After      *time.Time `json:"after"`
+	Before     *time.Time `json:"before"`
+}
+
+func (f *jmapEmailFilter) match(item *jmapItem) bool {
+	if f == nil {
+		return true
+	}
+
+	if f.InMailbox != nil {
+		in := false
+		for _, mailboxId := range item.mailboxIds {
+			if mailboxId == *f.InMailbox {
+				in = true
+				break
+			}
+		}
+		if !in {
+			return false
+		}
+	}
+
+	if f.HasKeyword != nil && !item.keywords[*f.HasKeyword] {
+		return false
+	}
+
+	if f.NotKeyword != nil && item.keywords[*f.NotKeyword] {
+		return false
+	}
+
+	if f.After != nil && item.receivedAt.Before(*f.After) {
+		return false
+	}
+
+	if f.Before != nil && !item.receivedAt.Before(*f.Before) {
+		return false
+	}
+
+	return true
+}
+
+type jmapEmailQueryArgs struct {
+	AccountId string          `json:"accountId"`
+	Filter    json.RawMessage `json:"filter"`
+	Sort      []struct {
+		Property    string `json:"property"`
+		IsAscending *bool  `json:"isAscending"`
+	} `json:"sort"`
+	Position        int     `json:"position"`
+	Anchor          *string `json:"anchor"`
+	Limit           *int    `json:"limit"`
+	CalculateTotal  bool    `json:"calculateTotal"`
+	CollapseThreads bool    `json:"collapseThreads"`
+}
+
+type jmapEmailQueryResponse struct {
+	AccountId           string   `json:"accountId"`
+	QueryState          string   `json:"queryState"`
+	CanCalculateChanges bool     `json:"canCalculateChanges"`
+	Position            int      `json:"position"`
+	Ids                 []string `json:"ids"`
+	Total               *int     `json:"total,omitempty"`
+	Limit               *int     `json:"limit,omitempty"`
+}
+
+// emailQuery is the Email/query, the filter is a condition of the inMailbox, hasKeyword, notKeyword, after
+// and before, the emails are sorted by the receivedAt, the recent first by default.
+func (api *JmapApi) emailQuery(user *repository.User, args json.RawMessage) (interface{}, error) {
+	var queryArgs jmapEmailQueryArgs
+
+	err := decodeJmapArgs(args, &queryArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	var filter *jmapEmailFilter
+
+	if len(queryArgs.Filter) > 0 && string(queryArgs.Filter) != "null" {
+		dec := json.NewDecoder(bytes.NewReader(queryArgs.Filter))
+		dec.DisallowUnknownFields()
+
+		err = dec.Decode(&filter)
+		if err != nil {
+			return nil, &jmapMethodError{Type: "unsupportedFilter", Description: err.Error()}
+		}
+	}
+
+	ascending := false
+
+	for _, comparator := range queryArgs.Sort {
+		if comparator.Property != "receivedAt" {
+			return nil, &jmapMethodError{Type: "unsupportedSort", Description: comparator.Property}
+		}
+		ascending = comparator.IsAscending == nil || *comparator.IsAscending
+	}
+
+	if queryArgs.Anchor != nil {
+		return nil, jmapInvalidArguments("anchor is not supported")
+	}
+
+	limit := repository.MaxListLimit
+	if queryArgs.Limit != nil {
+		if *queryArgs.Limit < 0 {
+			return nil, jmapInvalidArguments("negative limit")
+		}
+		if *queryArgs.Limit < limit {
+			limit = *queryArgs.Limit
+		}
+	}
+
+	items := []*jmapItem{}
+	threads := map[string]bool{}
+
+	state, err := api.eachItem(user, func(item *jmapItem) {
+		if filter.match(item) {
+			items = append(items, item)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		if ascending {
+			return items[i].receivedAt.Before(items[j].receivedAt)
+		}
+		return items[j].receivedAt.Before(items[i].receivedAt)
+	})
+
+	if queryArgs.CollapseThreads {
+		collapsed := items[:0]
+		for _, item := range items {
+			if !threads[item.threadId] {
+				threads[item.threadId] = true
+				collapsed = append(collapsed, item)
+			}
+		}
+		items = collapsed
+	}
+
+	total := len(items)
+
+	position := queryArgs.Position
+	if position < 0 {
+		position += total
+		if position < 0 {
+			position = 0
+		}
+	}
+	if position > total {
+		position = total
+	}
+
+	end := position + limit
+	if end > total {
+		end = total
+	}
+
+	response := &jmapEmailQueryResponse{
+		AccountId:  jmapAccountId(user),
+		QueryState: state,
+		Position:   position,
+		Ids:        []string{},
+	}
+
+	for _, item := range items[position:end] {
+		response.Ids = append(response.Ids, item.id)
+	}
+
+	if queryArgs.CalculateTotal {
+		response.Total = &total
+	}
+
+	if queryArgs.Limit != nil && limit < *queryArgs.Limit {
+		response.Limit = &limit
+	}
+
+	return response, nil
+}
+
+type jmapEmailAddress struct {
+	Name  *string `json:"name"`
+	Email string  `json:"email"`
+}
+
+// jmapAddresses parses the addresses of the header, e.g. "Alice <alice@example.com>, bob@example.com".
+func jmapAddresses(headers map[string]interface{}, name string) []jmapEmailAddress {
+	value, ok := headers[name].(string)
+	if !ok || len(value) == 0 {
+		return nil
+	}
+
+	list, err := mail.ParseAddressList(value)
+	if err != nil {
+		return []jmapEmailAddress{{Email: value}}
+	}
+
+	addresses := []jmapEmailAddress{}
+
+	for _, address := range list {
+		emailAddress := jmapEmailAddress{Email: address.Address}
+		if len(address.Name) > 0 {
+			emailAddress.Name = &address.Name
+		}
+		addresses = append(addresses, emailAddress)
+	}
+
+	return addresses
+}
+
+func jmapUTCDate(t repository.Timestamp) string {
+	return t.Time().UTC().Format(time.RFC3339)
+}
+
+func jmapEmail(item *jmapItem, payload *repository.MessagePart, sentAt *repository.Timestamp) map[string]interface{} {
+	mailboxIds := map[string]bool{}
+	for _, mailboxId := range item.mailboxIds {
+		mailboxIds[mailboxId] = true
+	}
+
+	email := map[string]interface{}{
+		"id":         item.id,
+		"threadId":   item.threadId,
+		"mailboxIds": mailboxIds,
+		"keywords":   item.keywords,
+		"receivedAt": item.receivedAt.UTC().Format(time.RFC3339),
+		"sentAt":     nil,
+		"subject":    nil,
+		"from":       nil,
+		"sender":     nil,
+		"to":         nil,
+		"cc":         nil,
+		"bcc":        nil,
+		"replyTo":    nil,
+		"messageId":  nil,
+		"inReplyTo":  nil,
+		"preview":    payload.Snippet(config.SnippetHtmlFirst(), config.SnippetLength()),
+	}
+
+	if sentAt != nil {
+		email["sentAt"] = jmapUTCDate(*sentAt)
+	}
+
+	if payload == nil {
+		return email
+	}
+
+	if subject, ok := payload.Headers["Subject"].(string); ok {
+		email["subject"] = subject
+	}
+
+	for property, header := range map[string]string{
+		"from":    "From",
+		"sender":  "Sender",
+		"to":      "To",
+		"cc":      "Cc",
+		"bcc":     "Bcc",
+		"replyTo": "Reply-To",
+	} {
+		if addresses := jmapAddresses(payload.Headers, header); addresses != nil {
+			email[property] = addresses
+		}
+	}
+
+	for property, header := range map[string]string{
+		"messageId": "Message-ID",
+		"inReplyTo": "In-Reply-To",
+	} {
+		if value, ok := payload.Headers[header].(string); ok && len(value) > 0 {
+			email[property] = []string{value}
+		}
+	}
+
+	return email
+}
+
+// getEmail returns the email of the message or the draft of the id.
+func (api *JmapApi) getEmail(user *repository.User, id string) (map[string]interface{}, error) {
+	message, err := api.useMessageStorage.GetById(user, id)
+	if err == nil {
+		return jmapEmail(messageJmapItem(message), message.Payload, message.SentAt), nil
+	}
+	if !errors.Is(err, repository.ErrMessageNotFound) || !user.HasScope("drafts:read") {
+		return nil, err
+	}
+
+	draft, err := api.useDraftStorage.GetById(user, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return jmapEmail(draftJmapItem(draft), draft.Payload, nil), nil
+}
+
+// emailGet is the Email/get, the emails are of the header properties, the body is in the preview only.
+func (api *JmapApi) emailGet(user *repository.User, args json.RawMessage) (interface{}, error) {
+	var getArgs jmapGetArgs
+
+	err := decodeJmapArgs(args, &getArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := api.state(user)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+
+	if getArgs.Ids != nil {
+		ids = *getArgs.Ids
+	} else {
+		ids = []string{}
+
+		_, err = api.eachItem(user, func(item *jmapItem) {
+			ids = append(ids, item.id)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(ids) > jmapMaxObjectsInGet {
+		return nil, &jmapMethodError{Type: "requestTooLarge"}
+	}
+
+	response := &jmapGetResponse{
+		AccountId: jmapAccountId(user),
+		State:     state,
+		List:      []map[string]interface{}{},
+		NotFound:  []string{},
+	}
+
+	for _, id := range ids {
+		email, err := api.getEmail(user, id)
+		if err != nil {
+			if errors.Is(err, repository.ErrMessageNotFound) || errors.Is(err, repository.ErrDraftNotFound) {
+				response.NotFound = append(response.NotFound, id)
+				continue
+			}
+			return nil, err
+		}
+
+		email, err = selectProperties(email, getArgs.Properties)
+		if err != nil {
+			return nil, err
+		}
+
+		response.List = append(response.List, email)
+	}
+
+	return response, nil
+}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"cargomail/cmd/mailbox/api/helper"
+	"cargomail/internal/mailbox/repository"
+	"errors"
+	"net/http"
+)
+
+type LabelsApi struct {
+	useLabelRepository repository.UseLabelRepository
+}
+
+// Create creates the label, e.g. {"name": "Receipts", "color": "#1a73e8"}
+func (api *LabelsApi) Create() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		var label *repository.Label
+
+		err := helper.Decoder(r.Body).Decode(&label)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		if label == nil {
+			helper.ReturnErr(w, repository.ErrMissingNameField, http.StatusBadRequest)
+			return
+		}
+
+		label, err = api.useLabelRepository.Create(user, label)
+		if err != nil {
+			returnLabelErr(w, err)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusCreated, label)
+	})
+}
+
+func (api *LabelsApi) List() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		labelList, err := api.useLabelRepository.List(user)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, labelList)
+	})
+}
+
+func (api *LabelsApi) Update() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		var label *repository.Label
+
+		err := helper.Decoder(r.Body).Decode(&label)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		if label == nil || len(label.Id) == 0 {
+			helper.ReturnErr(w, repository.ErrMissingIdField, http.StatusBadRequest)
+			return
+		}
+
+		label, err = api.useLabelRepository.Update(user, label)
+		if err != nil {
+			returnLabelErr(w, err)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, label)
+	})
+}
+
+func (api *LabelsApi) Delete() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		var id repository.Id
+
+		err := helper.Decoder(r.Body).Decode(&id)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		if len(id.Id) == 0 {
+			helper.ReturnErr(w, repository.ErrMissingIdField, http.StatusBadRequest)
+			return
+		}
+
+		err = api.useLabelRepository.Delete(user, id.Id)
+		if err != nil {
+			returnLabelErr(w, err)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, map[string]string{"status": "OK"})
+	})
+}
+
+func (api *LabelsApi) Sync() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		var history *repository.History
+
+		err := helper.Decoder(r.Body).Decode(&history)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		labelHistory, err := api.useLabelRepository.Sync(user, history)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, labelHistory)
+	})
+}
+
+func returnLabelErr(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repository.ErrLabelNotFound):
+		helper.ReturnErr(w, err, http.StatusNotFound)
+	case errors.Is(err, repository.ErrSystemLabel):
+		helper.ReturnErr(w, err, http.StatusForbidden)
+	case errors.Is(err, repository.ErrDuplicateLabel),
+		errors.Is(err, repository.ErrMissingNameField),
+		errors.Is(err, repository.ErrLabelWrongName),
+		errors.Is(err, repository.ErrLabelWrongColor):
+		helper.ReturnErr(w, err, http.StatusBadRequest)
+	default:
+		helper.ReturnErr(w, err, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"cargomail/cmd/mailbox/api/helper"
+	"cargomail/internal/mailbox/repository"
+	"cargomail/internal/shared/provider"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const ndjsonContentType = "application/x-ndjson"
+
+// listTrailer is the last line of the list streamed as NDJSON, the history the list is of and the cursor
+// of the next page.
+type listTrailer struct {
+	History    int64  `json:"lastHistoryId"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// listOptions parses the paging, sorting and filtering query parameters of the List handlers,
+// e.g. ?limit=50&cursor=...&sort=createdAt&order=desc&state=unread&labelId=...&createdAfter=2023-11-14T22:13:20Z&contentType=image/
+// The repeated ?tag= intersect, e.g. ?tag=invoices&tag=2023.
+func listOptions(r *http.Request) (*repository.ListOptions, error) {
+	query := r.URL.Query()
+
+	options := &repository.ListOptions{
+		Cursor:  query.Get("cursor"),
+		Sort:    query.Get("sort"),
+		Order:   query.Get("order"),
+		State:   query.Get("state"),
+		LabelId: query.Get("labelId"),
+
+		ContentType: query.Get("contentType"),
+		Tags:        query["tag"],
+	}
+
+	if limit := query.Get("limit"); len(limit) > 0 {
+		value, err := strconv.Atoi(limit)
+		if err != nil {
+			return nil, repository.ErrInvalidLimit
+		}
+		options.Limit = value
+	}
+
+	for param, value := range map[string]**time.Time{
+		"createdAfter":  &options.CreatedAfter,
+		"createdBefore": &options.CreatedBefore,
+		"modifiedAfter": &options.ModifiedAfter,
+	} {
+		if timestamp := query.Get(param); len(timestamp) > 0 {
+			t, err := parseTimestamp(timestamp)
+			if err != nil {
+				return nil, err
+			}
+			*value = t
+		}
+	}
+
+	return options, nil
+}
+
+// parseTimestamp parses the timestamp in the format the Timestamp type serializes, i.e. RFC3339, or
+// as the unix milliseconds
+func parseTimestamp(timestamp string) (*time.Time, error) {
+	t, err := repository.ParseTimestamp(timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// isListOptionsErr tells whether the List error is caused by the invalid query parameters
+func isListOptionsErr(err error) bool {
+	return errors.Is(err, repository.ErrInvalidCursor) ||
+		errors.Is(err, repository.ErrInvalidSort) ||
+		errors.Is(err, repository.ErrInvalidState) ||
+		errors.Is(err, repository.ErrInvalidLimit) ||
+		errors.Is(err, repository.ErrInvalidTimestamp) ||
+		errors.Is(err, repository.ErrInvalidDateRange) ||
+		errors.Is(err, repository.ErrInvalidContentType)
+}
+
+// acceptsNDJSON tells whether the client asked for the list streamed as NDJSON, i.e. the Accept header
+// lists the application/x-ndjson.
+func acceptsNDJSON(r *http.Request) bool {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err == nil && mediaType == ndjsonContentType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// streamList streams the list as NDJSON, an item per line as the list reads it from the rows, and the
+// trailer last, so the client processes the items before the list is done. The status is sent with the
+// first line, an error before it is answered as usual, an error after it ends the stream with the line
+// of the error envelope instead of the trailer.
+func streamList[T any](w http.ResponseWriter, r *http.Request, list func(each func(*T) error) (*listTrailer, error)) {
+	enc := json.NewEncoder(w)
+
+	started := false
+	start := func() {
+		if !started {
+			w.Header().Set("Content-Type", ndjsonContentType)
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
+	}
+
+	trailer, err := list(func(item *T) error {
+		start()
+		return enc.Encode(item)
+	})
+	if err != nil {
+		if !started {
+			if isListOptionsErr(err) {
+				helper.ReturnErr(w, err, http.StatusBadRequest)
+				return
+			}
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		provider.Logf(r.Context(), "list stream error: %v", err)
+		enc.Encode(helper.NewErrorResponse(err, http.StatusInternalServerError))
+		return
+	}
+
+	start()
+	enc.Encode(trailer)
+}
@@ -6,7 +6,9 @@ import (
 	"cargomail/internal/mailbox/repository"
 	"cargomail/internal/mailbox/storage"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strings"
 )
 
 type MessagesApi struct {
@@ -23,19 +25,40 @@ func (api *MessagesApi) List() http.Handler {
 			return
 		}
 
+		options, err := listOptions(r)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
 		var folder repository.Folder
 
-		err := helper.Decoder(r.Body).Decode(&folder)
+		err = helper.Decoder(r.Body).Decode(&folder)
 		if err != nil {
 			if err.Error() != "EOF" {
-				http.Error(w, err.Error(), http.StatusBadRequest)
+				helper.ReturnErr(w, err, http.StatusBadRequest)
 				return
 			}
 		}
 
-		messageHistory, err := api.useMessageStorage.List(user, folder.Folder)
+		if acceptsNDJSON(r) {
+			streamList(w, r, func(each func(*repository.Message) error) (*listTrailer, error) {
+				messageList, err := api.useMessageStorage.ListEach(user, folder.Folder, options, each)
+				if err != nil {
+					return nil, err
+				}
+				return &listTrailer{History: messageList.History, NextCursor: messageList.NextCursor}, nil
+			})
+			return
+		}
+
+		messageHistory, err := api.useMessageStorage.List(user, folder.Folder, options)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			if isListOptionsErr(err) {
+				helper.ReturnErr(w, err, http.StatusBadRequest)
+				return
+			}
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
 			return
 		}
 
@@ -43,6 +66,73 @@ func (api *MessagesApi) List() http.Handler {
 	})
 }
 
+func (api *MessagesApi) ListTrashed() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		messageList, err := api.useMessageStorage.ListTrashed(user)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, messageList)
+	})
+}
+
+// Counts counts the drafts and the messages by label, e.g. {"inbox": {"total": 12, "unread": 3}, "all": ...}
+func (api *MessagesApi) Counts() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		counts, err := api.useMessageRepository.Counts(user)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, counts)
+	})
+}
+
+// Get serves the GET /api/v1/messages/{id}
+func (api *MessagesApi) Get() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		id := strings.TrimPrefix(r.URL.Path, "/api/v1/messages/")
+		if len(id) == 0 || strings.Contains(id, "/") {
+			helper.ReturnErr(w, repository.ErrMissingIdField, http.StatusBadRequest)
+			return
+		}
+
+		message, err := api.useMessageStorage.GetById(user, id)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrMessageNotFound):
+				helper.ReturnErr(w, err, http.StatusNotFound)
+			default:
+				helper.ReturnErr(w, err, http.StatusInternalServerError)
+			}
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, message)
+	})
+}
+
 func (api *MessagesApi) Sync() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
@@ -55,13 +145,13 @@ func (api *MessagesApi) Sync() http.Handler {
 
 		err := helper.Decoder(r.Body).Decode(&history)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
 		messageHistory, err := api.useMessageStorage.Sync(user, history)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
 			return
 		}
 
@@ -81,25 +171,124 @@ func (api *MessagesApi) Update() http.Handler {
 
 		err := helper.Decoder(r.Body).Decode(&state)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
 		if state.Ids == nil {
-			http.Error(w, repository.ErrMissingIdsField.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, repository.ErrMissingIdsField, http.StatusBadRequest)
 			return
 		}
 
 		// back to body
 		_, err = json.Marshal(state.Ids)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
 		err = api.useMessageRepository.Update(user, &state)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, map[string]string{"status": "OK"})
+	})
+}
+
+// Read marks the messages of the ids read, e.g. {"ids": [...]}
+func (api *MessagesApi) Read() http.Handler {
+	return api.setUnread(false)
+}
+
+func (api *MessagesApi) Unread() http.Handler {
+	return api.setUnread(true)
+}
+
+func (api *MessagesApi) setUnread(unread bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		var ids repository.Ids
+
+		err := helper.Decoder(r.Body).Decode(&ids)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		if ids.Ids == nil {
+			helper.ReturnErr(w, repository.ErrMissingIdsField, http.StatusBadRequest)
+			return
+		}
+
+		// back to body
+		body, err := json.Marshal(ids)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		err = api.useMessageRepository.SetUnread(user, string(body), unread)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, map[string]string{"status": "OK"})
+	})
+}
+
+type modifyLabelsInput struct {
+	Ids            []string `json:"ids"`
+	AddLabelIds    []string `json:"addLabelIds"`
+	RemoveLabelIds []string `json:"removeLabelIds"`
+}
+
+// Modify adds and removes the labels of the messages at once, e.g. {"ids": [...], "addLabelIds": [...],
+// "removeLabelIds": [...]}
+func (api *MessagesApi) Modify() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		var input modifyLabelsInput
+
+		err := helper.Decoder(r.Body).Decode(&input)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		if input.Ids == nil {
+			helper.ReturnErr(w, repository.ErrMissingIdsField, http.StatusBadRequest)
+			return
+		}
+
+		// back to body
+		body, err := json.Marshal(repository.Ids{Ids: input.Ids})
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		err = api.useMessageRepository.ModifyLabels(user, string(body), input.AddLabelIds, input.RemoveLabelIds)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrMissingLabelIdsField),
+				errors.Is(err, repository.ErrConflictingLabelIds):
+				helper.ReturnErr(w, err, http.StatusBadRequest)
+			default:
+				helper.ReturnErr(w, err, http.StatusInternalServerError)
+			}
 			return
 		}
 
@@ -119,19 +308,19 @@ func (api *MessagesApi) Trash() http.Handler {
 
 		err := helper.Decoder(r.Body).Decode(&ids)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
 		if ids.Ids == nil {
-			http.Error(w, repository.ErrMissingIdsField.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, repository.ErrMissingIdsField, http.StatusBadRequest)
 			return
 		}
 
 		// back to body
 		body, err := json.Marshal(ids)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
@@ -139,7 +328,7 @@ func (api *MessagesApi) Trash() http.Handler {
 
 		err = api.useMessageRepository.Trash(user, idsString)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
 			return
 		}
 
@@ -159,19 +348,19 @@ func (api *MessagesApi) Untrash() http.Handler {
 
 		err := helper.Decoder(r.Body).Decode(&ids)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
 		if ids.Ids == nil {
-			http.Error(w, repository.ErrMissingIdsField.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, repository.ErrMissingIdsField, http.StatusBadRequest)
 			return
 		}
 
 		// back to body
 		body, err := json.Marshal(ids)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
@@ -179,7 +368,7 @@ func (api *MessagesApi) Untrash() http.Handler {
 
 		err = api.useMessageRepository.Untrash(user, idsString)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
 			return
 		}
 
@@ -199,19 +388,19 @@ func (api *MessagesApi) Delete() http.Handler {
 
 		err := helper.Decoder(r.Body).Decode(&ids)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
 		if ids.Ids == nil {
-			http.Error(w, repository.ErrMissingIdsField.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, repository.ErrMissingIdsField, http.StatusBadRequest)
 			return
 		}
 
 		// back to body
 		body, err := json.Marshal(ids)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
@@ -239,28 +428,28 @@ func (api *MessagesApi) Submit() http.Handler {
 
 		err := helper.Decoder(r.Body).Decode(&message)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, err, http.StatusBadRequest)
 			return
 		}
 
 		if message.Id == "" {
-			http.Error(w, repository.ErrMissingIdField.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, repository.ErrMissingIdField, http.StatusBadRequest)
 			return
 		}
 
 		if message.Payload == nil {
-			http.Error(w, repository.ErrMissingPayloadField.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, repository.ErrMissingPayloadField, http.StatusBadRequest)
 			return
 		}
 
 		if message.Payload.Headers == nil {
-			http.Error(w, repository.ErrMissingHeadersField.Error(), http.StatusBadRequest)
+			helper.ReturnErr(w, repository.ErrMissingHeadersField, http.StatusBadRequest)
 			return
 		}
 
 		response, err := api.useMessageSubmissionAgent.Post(r.Context(), message)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
 			return
 		}
 
@@ -0,0 +1,195 @@
+package api
+
+import (
+	"cargomail/cmd/mailbox/api/helper"
+	"cargomail/internal/mailbox/repository"
+	"errors"
+	"net/http"
+)
+
+func (api *SearchApi) CreateSaved() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		var search *repository.SavedSearch
+
+		err := helper.Decoder(r.Body).Decode(&search)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		if search == nil {
+			helper.ReturnErr(w, repository.ErrMissingNameField, http.StatusBadRequest)
+			return
+		}
+
+		search, err = api.useSavedSearchRepository.Create(user, search)
+		if err != nil {
+			returnSavedSearchErr(w, err)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusCreated, search)
+	})
+}
+
+func (api *SearchApi) ListSaved() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		searchList, err := api.useSavedSearchRepository.List(user)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, searchList)
+	})
+}
+
+func (api *SearchApi) UpdateSaved() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		var search *repository.SavedSearch
+
+		err := helper.Decoder(r.Body).Decode(&search)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		if search == nil || len(search.Id) == 0 {
+			helper.ReturnErr(w, repository.ErrMissingIdField, http.StatusBadRequest)
+			return
+		}
+
+		search, err = api.useSavedSearchRepository.Update(user, search)
+		if err != nil {
+			returnSavedSearchErr(w, err)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, search)
+	})
+}
+
+func (api *SearchApi) DeleteSaved() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		var id repository.Id
+
+		err := helper.Decoder(r.Body).Decode(&id)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		if len(id.Id) == 0 {
+			helper.ReturnErr(w, repository.ErrMissingIdField, http.StatusBadRequest)
+			return
+		}
+
+		err = api.useSavedSearchRepository.Delete(user, id.Id)
+		if err != nil {
+			returnSavedSearchErr(w, err)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, map[string]string{"status": "OK"})
+	})
+}
+
+func (api *SearchApi) SyncSaved() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		var history *repository.History
+
+		err := helper.Decoder(r.Body).Decode(&history)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		searchHistory, err := api.useSavedSearchRepository.Sync(user, history)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, searchHistory)
+	})
+}
+
+// RunSaved runs the saved search, e.g. ?id=...&limit=5, as the global search does over its resources.
+// The scopes of the resources are checked on each run, not when the search is saved.
+func (api *SearchApi) RunSaved() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		id := r.URL.Query().Get("id")
+		if len(id) == 0 {
+			helper.ReturnErr(w, repository.ErrMissingIdField, http.StatusBadRequest)
+			return
+		}
+
+		limit, err := searchLimit(r)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		search, err := api.useSavedSearchRepository.Get(user, id)
+		if err != nil {
+			returnSavedSearchErr(w, err)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, api.search(r, user, search.Query, limit, search.Resources))
+	})
+}
+
+func returnSavedSearchErr(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repository.ErrSavedSearchNotFound):
+		helper.ReturnErr(w, err, http.StatusNotFound)
+	case errors.Is(err, repository.ErrTooManySavedSearches):
+		helper.ReturnErr(w, err, http.StatusConflict)
+	case errors.Is(err, repository.ErrDuplicateSavedSearch),
+		errors.Is(err, repository.ErrMissingNameField),
+		errors.Is(err, repository.ErrSavedSearchWrongName),
+		errors.Is(err, repository.ErrMissingSearchQuery),
+		errors.Is(err, repository.ErrInvalidSearchQuery),
+		errors.Is(err, repository.ErrUnknownResource):
+		helper.ReturnErr(w, err, http.StatusBadRequest)
+	default:
+		helper.ReturnErr(w, err, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"cargomail/cmd/mailbox/api/helper"
+	"cargomail/internal/mailbox/repository"
+	"cargomail/internal/mailbox/storage"
+	"cargomail/internal/shared/provider"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	defaultSearchLimit = 10
+	maxSearchLimit     = 50
+)
+
+type SearchApi struct {
+	useContactRepository     repository.UseContactRepository
+	useBlobRepository        repository.UseBlobRepository
+	useSavedSearchRepository repository.UseSavedSearchRepository
+	useDraftStorage          storage.UseDraftStorage
+	useMessageStorage        storage.UseMessageStorage
+}
+
+// searchResource is the search of a resource of the global search, with the scope it requires.
+type searchResource struct {
+	scope  string
+	search func(user *repository.User, q string, limit int) (interface{}, error)
+}
+
+func (api *SearchApi) resources() map[string]searchResource {
+	return map[string]searchResource{
+		"contacts": {"contacts:read", func(user *repository.User, q string, limit int) (interface{}, error) {
+			return api.useContactRepository.Search(user, q, limit)
+		}},
+		"messages": {"messages:read", func(user *repository.User, q string, limit int) (interface{}, error) {
+			return api.useMessageStorage.Search(user, q, limit)
+		}},
+		"drafts": {"drafts:read", func(user *repository.User, q string, limit int) (interface{}, error) {
+			drafts, err := api.useDraftStorage.Search(user, q)
+			if err != nil {
+				return nil, err
+			}
+			if len(drafts) > limit {
+				drafts = drafts[:limit]
+			}
+			return drafts, nil
+		}},
+		// the blobs are not full-text indexed, the newest matches come first
+		"blobs": {"blobs:read", func(user *repository.User, q string, limit int) (interface{}, error) {
+			blobs, err := api.useBlobRepository.Search(user, q, nil)
+			if err != nil {
+				return nil, err
+			}
+			if len(blobs) > limit {
+				blobs = blobs[:limit]
+			}
+			return blobs, nil
+		}},
+	}
+}
+
+// Search searches the contacts, messages, drafts and blobs at once, e.g. ?q=invoice&limit=5, for the
+// omnibox of the UI. The results are grouped by the resource, the most relevant first, at most limit
+// of each (10 by default, 50 at most). The resources are searched concurrently. A resource failing, or
+// out of the scopes of the API key, doesn't fail the others, its result is the error envelope instead.
+func (api *SearchApi) Search() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		q := r.URL.Query().Get("q")
+		if len(strings.Fields(q)) == 0 {
+			helper.ReturnErr(w, repository.ErrMissingSearchQuery, http.StatusBadRequest)
+			return
+		}
+
+		limit, err := searchLimit(r)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, api.search(r, user, q, limit, nil))
+	})
+}
+
+func searchLimit(r *http.Request) (int, error) {
+	value := r.URL.Query().Get("limit")
+	if len(value) == 0 {
+		return defaultSearchLimit, nil
+	}
+
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > maxSearchLimit {
+		return 0, repository.ErrInvalidLimit
+	}
+
+	return limit, nil
+}
+
+// search searches the named resources concurrently, all of them if none are named.
+func (api *SearchApi) search(r *http.Request, user *repository.User, q string, limit int, names []string) map[string]interface{} {
+	resources := api.resources()
+
+	if names != nil {
+		named := make(map[string]searchResource, len(names))
+		for _, name := range names {
+			if resource, ok := resources[name]; ok {
+				named[name] = resource
+			}
+		}
+		resources = named
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	results := make(map[string]interface{}, len(resources))
+
+	for name, resource := range resources {
+		wg.Add(1)
+
+		go func(name string, resource searchResource) {
+			defer wg.Done()
+
+			var result interface{}
+
+			if !user.HasScope(resource.scope) {
+				result = resourceError(fmt.Errorf("%w: %s required", repository.ErrInsufficientScope, resource.scope), http.StatusForbidden)
+			} else {
+				var err error
+
+				result, err = resource.search(user, q, limit)
+				if err != nil {
+					provider.Logf(r.Context(), "%s search error: %v", name, err)
+					result = resourceError(err, http.StatusInternalServerError)
+				}
+			}
+
+			mu.Lock()
+			results[name] = result
+			mu.Unlock()
+		}(name, resource)
+	}
+
+	wg.Wait()
+
+	return results
+}
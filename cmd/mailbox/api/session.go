@@ -7,4 +7,6 @@ import (
 type SessionApi struct {
 	useUserRepository    repository.UseUserRepository
 	useSessionRepository repository.UseSessionRepository
+	useApiKeyRepository  repository.UseApiKeyRepository
+	useDeviceRepository  repository.UseDeviceRepository
 }
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"cargomail/cmd/mailbox/api/helper"
+	"cargomail/internal/mailbox/repository"
+	"cargomail/internal/shared/config"
+	"errors"
+	"net/http"
+	"time"
+)
+
+type signUrlInput struct {
+	Digest    string                `json:"digest"`
+	ExpiresAt *repository.Timestamp `json:"expiresAt"`
+}
+
+type signedUrl struct {
+	Url       string               `json:"url"`
+	ExpiresAt repository.Timestamp `json:"expiresAt"`
+}
+
+// SignUrl returns the signed download URL of the blob, it opens without a session until the expiry.
+func (api *BlobsApi) SignUrl() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		input, expiresAt, ok := decodeSignUrlInput(w, r)
+		if !ok {
+			return
+		}
+
+		blob, err := api.useBlobRepository.GetByDigest(user, input.Digest)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		if len(blob.Digest) == 0 {
+			helper.ReturnErr(w, repository.ErrBlobNotFound, http.StatusNotFound)
+			return
+		}
+
+		setSignedUrlResponse(w, user, "/api/v1/blobs/"+blob.Digest, expiresAt)
+	})
+}
+
+// SignUrl returns the signed download URL of the file, it opens without a session until the expiry.
+func (api *FilesApi) SignUrl() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		input, expiresAt, ok := decodeSignUrlInput(w, r)
+		if !ok {
+			return
+		}
+
+		file, err := api.useFileRepository.GetByDigest(user, input.Digest)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		if len(file.Name) == 0 {
+			helper.ReturnErr(w, repository.ErrFileNotFound, http.StatusNotFound)
+			return
+		}
+
+		setSignedUrlResponse(w, user, "/api/v1/files/"+file.Digest, expiresAt)
+	})
+}
+
+// decodeSignUrlInput returns the input and its expiry, an hour by default and a week at most.
+func decodeSignUrlInput(w http.ResponseWriter, r *http.Request) (*signUrlInput, time.Time, bool) {
+	var input signUrlInput
+
+	err := helper.Decoder(r.Body).Decode(&input)
+	if err != nil {
+		helper.ReturnErr(w, err, http.StatusBadRequest)
+		return nil, time.Time{}, false
+	}
+
+	if len(input.Digest) == 0 {
+		helper.ReturnErr(w, repository.ErrMissingDigestField, http.StatusBadRequest)
+		return nil, time.Time{}, false
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(config.DefaultSignedUrlTTL)
+
+	if input.ExpiresAt != nil {
+		expiresAt = input.ExpiresAt.Time()
+
+		if !expiresAt.After(now) || expiresAt.After(now.Add(config.MaxSignedUrlTTL)) {
+			helper.ReturnErr(w, repository.ErrInvalidExpiry, http.StatusBadRequest)
+			return nil, time.Time{}, false
+		}
+	}
+
+	return &input, expiresAt, true
+}
+
+func setSignedUrlResponse(w http.ResponseWriter, user *repository.User, path string, expiresAt time.Time) {
+	helper.SetJsonResponse(w, http.StatusOK, &signedUrl{
+		Url:       helper.SignUrl(config.UrlSigningKey(), path, user.Id, expiresAt),
+		ExpiresAt: repository.Timestamp(expiresAt.Unix() * 1000),
+	})
+}
+
+// middleware
+//
+// AuthenticateSigned lets the request with a valid signed URL in with the read scope of the resource
+// only, the other requests are authenticated as usual.
+func (api *Api) AuthenticateSigned(scope string, next http.Handler) http.Handler {
+	authenticated := api.Authenticate(next)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		if !query.Has("sig") {
+			authenticated.ServeHTTP(w, r)
+			return
+		}
+
+		userId, err := helper.VerifyUrlSignature(config.UrlSigningKey(), r.URL.Path, query, time.Now())
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrInvalidSignature),
+				errors.Is(err, repository.ErrSignatureExpired):
+				helper.ReturnErr(w, err, http.StatusForbidden)
+			default:
+				helper.ReturnErr(w, err, http.StatusInternalServerError)
+			}
+			return
+		}
+
+		user := &repository.User{Id: userId, Scopes: []string{scope}}
+
+		next.ServeHTTP(w, api.contextSetUser(r, user))
+	})
+}
@@ -0,0 +1,296 @@
+package api
+
+import (
+	"cargomail/cmd/mailbox/api/helper"
+	"cargomail/internal/mailbox/repository"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	streamHeartbeatInterval = 30 * time.Second
+	streamRetry             = 5 * time.Second
+	maxStreamsPerUser       = 5
+)
+
+// streamScopes are the scopes the changes of the resources are streamed with, the labels are of the messages.
+var streamScopes = map[string]string{
+	"blobs":         "blobs:read",
+	"files":         "files:read",
+	"drafts":        "drafts:read",
+	"messages":      "messages:read",
+	"labels":        "messages:read",
+	"contacts":      "contacts:read",
+	"contactGroups": "contacts:read",
+	"savedSearches": "searches:read",
+	"collections":   "blobs:read",
+}
+
+// EventBroker fans the change notifications out to the event streams of their users, in process. It is
+// fed by the event dispatcher, so a change is streamed once its transaction committed.
+type EventBroker struct {
+	mu          sync.Mutex
+	subscribers map[int64]map[*subscriber]bool // by user id
+	done        chan struct{}
+	closed      bool
+}
+
+func NewEventBroker() *EventBroker {
+	return &EventBroker{
+		subscribers: map[int64]map[*subscriber]bool{},
+		done:        make(chan struct{}),
+	}
+}
+
+// subscriber is an event stream. The changes not written yet are merged to the latest history id of
+// each resource, so a slow client never holds the dispatcher back.
+type subscriber struct {
+	user    *repository.User
+	types   map[string]bool // the resources streamed, nil = all
+	mu      sync.Mutex
+	pending map[string]int64
+	notify  chan struct{}
+}
+
+// streamEvent tells the client the resource changed up to the history id, i.e. it is time to sync.
+type streamEvent struct {
+	Resource  string `json:"resource"`
+	HistoryId int64  `json:"historyId"`
+}
+
+func (s *subscriber) push(resource string, historyId int64) {
+	scope, ok := streamScopes[resource]
+	if !ok || !s.user.HasScope(scope) {
+		return
+	}
+
+	if s.types != nil && !s.types[resource] {
+		return
+	}
+
+	s.mu.Lock()
+	if historyId > s.pending[resource] {
+		s.pending[resource] = historyId
+	}
+	s.mu.Unlock()
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+// take returns the pending changes, by resource.
+func (s *subscriber) take() []*streamEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events := make([]*streamEvent, 0, len(s.pending))
+	for resource, historyId := range s.pending {
+		events = append(events, &streamEvent{Resource: resource, HistoryId: historyId})
+	}
+
+	sort.Slice(events, func(i, j int) bool { return events[i].Resource < events[j].Resource })
+
+	s.pending = map[string]int64{}
+
+	return events
+}
+
+func (b *EventBroker) subscribe(user *repository.User, types map[string]bool) (*subscriber, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, repository.ErrServerBusy
+	}
+
+	if len(b.subscribers[user.Id]) >= maxStreamsPerUser {
+		return nil, repository.ErrTooManyStreams
+	}
+
+	s := &subscriber{
+		user:    user,
+		types:   types,
+		pending: map[string]int64{},
+		notify:  make(chan struct{}, 1),
+	}
+
+	if b.subscribers[user.Id] == nil {
+		b.subscribers[user.Id] = map[*subscriber]bool{}
+	}
+	b.subscribers[user.Id][s] = true
+
+	return s, nil
+}
+
+func (b *EventBroker) unsubscribe(s *subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.subscribers[s.user.Id], s)
+	if len(b.subscribers[s.user.Id]) == 0 {
+		delete(b.subscribers, s.user.Id)
+	}
+}
+
+// Publish notifies the event streams of the user of the event, it never blocks.
+func (b *EventBroker) Publish(event *repository.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for s := range b.subscribers[event.UserId] {
+		s.push(event.Resource, event.HistoryId)
+	}
+}
+
+// Close ends the event streams, e.g. on the shutdown, the clients reconnect to another instance.
+func (b *EventBroker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.closed {
+		b.closed = true
+		close(b.done)
+	}
+}
+
+type StreamApi struct {
+	useEventRepository repository.UseEventRepository
+	broker             *EventBroker
+}
+
+// Stream is the Server-Sent Events stream of the changes of the user, e.g. ?contacts=12&blobs=3 with the
+// history ids the client synced up to. The resources changed since are sent right away, then a "change"
+// event is sent whenever a resource changes, e.g. data: {"resource": "contacts", "historyId": 13}. The
+// event carries no data, the client syncs the resource. A comment is sent as the heartbeat. The
+// ?types=contacts,contactGroups streams the changes of the listed resources only, all of them by default.
+func (api *StreamApi) Stream() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		query := r.URL.Query()
+
+		var types map[string]bool
+
+		if query.Has("types") {
+			types = map[string]bool{}
+
+			for _, resource := range strings.Split(query.Get("types"), ",") {
+				resource = strings.TrimSpace(resource)
+				if _, ok := streamScopes[resource]; !ok {
+					helper.ReturnErr(w, fmt.Errorf("%w: %s", repository.ErrUnknownResource, resource), http.StatusBadRequest)
+					return
+				}
+
+				types[resource] = true
+			}
+
+			query.Del("types")
+		}
+
+		synced := map[string]int64{}
+
+		for resource, values := range query {
+			if _, ok := streamScopes[resource]; !ok {
+				helper.ReturnErr(w, fmt.Errorf("%w: %s", repository.ErrUnknownResource, resource), http.StatusBadRequest)
+				return
+			}
+
+			historyId, err := strconv.ParseInt(values[0], 10, 64)
+			if err != nil || historyId < 0 {
+				helper.ReturnErr(w, fmt.Errorf("%w: %s", repository.ErrInvalidHistoryId, resource), http.StatusBadRequest)
+				return
+			}
+
+			synced[resource] = historyId
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrInternalServer, http.StatusInternalServerError)
+			return
+		}
+
+		s, err := api.broker.subscribe(user, types)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrTooManyStreams):
+				helper.ReturnErr(w, err, http.StatusTooManyRequests)
+			default:
+				helper.ReturnErr(w, err, http.StatusServiceUnavailable)
+			}
+			return
+		}
+		defer api.broker.unsubscribe(s)
+
+		// subscribed first, so no change is missed between the read of the history ids and the stream
+		historyIds, err := api.useEventRepository.LastHistoryIds(user)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		for resource, historyId := range synced {
+			if historyIds[resource] > historyId {
+				s.push(resource, historyIds[resource])
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no") // the proxies must not buffer the stream
+		w.WriteHeader(http.StatusOK)
+
+		_, err = fmt.Fprintf(w, "retry: %d\n\n", streamRetry.Milliseconds())
+		if err != nil {
+			return
+		}
+		flusher.Flush()
+
+		heartbeat := time.NewTicker(streamHeartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-api.broker.done:
+				return
+			case <-heartbeat.C:
+				_, err = fmt.Fprint(w, ": heartbeat\n\n")
+			case <-s.notify:
+				for _, event := range s.take() {
+					var data []byte
+
+					data, err = json.Marshal(event)
+					if err != nil {
+						break
+					}
+
+					_, err = fmt.Fprintf(w, "event: change\ndata: %s\n\n", data)
+					if err != nil {
+						break
+					}
+				}
+			}
+
+			if err != nil {
+				// the stream broke, e.g. the client is gone
+				return
+			}
+			flusher.Flush()
+		}
+	})
+}
@@ -0,0 +1,196 @@
+package api
+
+import (
+	"bufio"
+	"cargomail/internal/mailbox/repository"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testEvents returns the last history ids of the resources, the rest of the repository is not used.
+type testEvents struct {
+	repository.UseEventRepository
+	historyIds map[string]int64
+}
+
+func (e *testEvents) LastHistoryIds(user *repository.User) (map[string]int64, error) {
+	return e.historyIds, nil
+}
+
+// openStream opens the event stream of the user on the query, it returns the "data" lines of the events.
+func openStream(t *testing.T, broker *EventBroker, user *repository.User, historyIds map[string]int64, query string) (*http.Response, <-chan string) {
+	t.Helper()
+
+	api := &StreamApi{useEventRepository: &testEvents{historyIds: historyIds}, broker: broker}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		api.Stream().ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), repository.UserContextKey, user)))
+	}))
+	t.Cleanup(server.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/v1/sync/stream"+query, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+
+	data := make(chan string)
+
+	go func() {
+		defer close(data)
+
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if line, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+				data <- line
+			}
+		}
+	}()
+
+	return resp, data
+}
+
+// nextEvent returns the data of the next event, or "" once the stream ended.
+func nextEvent(t *testing.T, data <-chan string) string {
+	t.Helper()
+
+	select {
+	case line := <-data:
+		return line
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event streamed")
+		return ""
+	}
+}
+
+func TestStream(t *testing.T) {
+	broker := NewEventBroker()
+	alice := &repository.User{Id: 1}
+
+	resp, data := openStream(t, broker, alice, map[string]int64{"contacts": 3, "blobs": 5}, "?contacts=1&blobs=5")
+
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("got the status %d of %q, want %d of text/event-stream", resp.StatusCode, resp.Header.Get("Content-Type"), http.StatusOK)
+	}
+
+	// the changes since the synced history ids are sent right away, the stream is subscribed by then
+	if got := nextEvent(t, data); got != `{"resource":"contacts","historyId":3}` {
+		t.Errorf("got the event %s, want the one of the contacts", got)
+	}
+
+	// the events of the other users are not streamed
+	broker.Publish(&repository.Event{UserId: 2, Resource: "files", HistoryId: 9})
+	broker.Publish(&repository.Event{UserId: alice.Id, Resource: "messages", HistoryId: 7})
+
+	if got := nextEvent(t, data); got != `{"resource":"messages","historyId":7}` {
+		t.Errorf("got the event %s, want the one of the messages", got)
+	}
+
+	broker.Close()
+
+	if got, ok := <-data; ok {
+		t.Errorf("got the event %s after the close", got)
+	}
+}
+
+func TestStreamTypes(t *testing.T) {
+	broker := NewEventBroker()
+	alice := &repository.User{Id: 1}
+
+	_, data := openStream(t, broker, alice, map[string]int64{"contacts": 3, "blobs": 5}, "?types=contacts,drafts&contacts=1&blobs=1")
+
+	// the blobs changed since too, but are not streamed
+	if got := nextEvent(t, data); got != `{"resource":"contacts","historyId":3}` {
+		t.Errorf("got the event %s, want the one of the contacts", got)
+	}
+
+	broker.Publish(&repository.Event{UserId: alice.Id, Resource: "blobs", HistoryId: 6})
+	broker.Publish(&repository.Event{UserId: alice.Id, Resource: "drafts", HistoryId: 2})
+
+	if got := nextEvent(t, data); got != `{"resource":"drafts","historyId":2}` {
+		t.Errorf("got the event %s, want the one of the drafts", got)
+	}
+}
+
+func TestStreamInvalidQuery(t *testing.T) {
+	api := &StreamApi{useEventRepository: &testEvents{}, broker: NewEventBroker()}
+
+	for _, query := range []string{"?unknown=1", "?contacts=x", "?contacts=-1", "?types=contacts,unknown", "?types="} {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/sync/stream"+query, nil)
+		r = r.WithContext(context.WithValue(r.Context(), repository.UserContextKey, &repository.User{Id: 1}))
+
+		w := httptest.NewRecorder()
+
+		api.Stream().ServeHTTP(w, r)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: got the status %d, want %d", query, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestSubscriberMergesChanges(t *testing.T) {
+	broker := NewEventBroker()
+
+	// an API key of the contacts only
+	s, err := broker.subscribe(&repository.User{Id: 1, Scopes: []string{"contacts:read"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, event := range []*repository.Event{
+		{UserId: 1, Resource: "contacts", HistoryId: 4},
+		{UserId: 1, Resource: "contactGroups", HistoryId: 2},
+		{UserId: 1, Resource: "contacts", HistoryId: 3},
+		{UserId: 1, Resource: "messages", HistoryId: 8},
+	} {
+		broker.Publish(event)
+	}
+
+	events := s.take()
+
+	if len(events) != 2 || events[0].Resource != "contactGroups" || events[1].Resource != "contacts" || events[1].HistoryId != 4 {
+		t.Errorf("got the events %+v, want the contactGroups of 2 and the contacts of 4", events)
+	}
+
+	if events = s.take(); len(events) != 0 {
+		t.Errorf("got the events %+v taken twice", events)
+	}
+}
+
+func TestSubscribeLimit(t *testing.T) {
+	broker := NewEventBroker()
+	alice := &repository.User{Id: 1}
+
+	for i := 0; i < maxStreamsPerUser; i++ {
+		_, err := broker.subscribe(alice, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := broker.subscribe(alice, nil)
+	if !errors.Is(err, repository.ErrTooManyStreams) {
+		t.Errorf("got %v, want %v", err, repository.ErrTooManyStreams)
+	}
+
+	broker.Close()
+
+	_, err = broker.subscribe(&repository.User{Id: 2}, nil)
+	if !errors.Is(err, repository.ErrServerBusy) {
+		t.Errorf("got %v after the close, want %v", err, repository.ErrServerBusy)
+	}
+}
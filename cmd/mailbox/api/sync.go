@@ -0,0 +1,135 @@
+package api
+
+import (
+	"cargomail/cmd/mailbox/api/helper"
+	"cargomail/internal/mailbox/repository"
+	"cargomail/internal/mailbox/storage"
+	"cargomail/internal/shared/provider"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+type SyncApi struct {
+	useContactRepository repository.UseContactRepository
+	useBlobRepository    repository.UseBlobRepository
+	useFileRepository    repository.UseFileRepository
+	useDraftStorage      storage.UseDraftStorage
+	useMessageStorage    storage.UseMessageStorage
+	useLabelRepository   repository.UseLabelRepository
+
+	useSavedSearchRepository repository.UseSavedSearchRepository
+}
+
+// syncResource is the Sync of a resource of the combined sync, with the scope it requires.
+type syncResource struct {
+	scope string
+	sync  func(user *repository.User, history *repository.History) (interface{}, error)
+}
+
+func (api *SyncApi) resources() map[string]syncResource {
+	return map[string]syncResource{
+		"contacts": {"contacts:read", func(user *repository.User, history *repository.History) (interface{}, error) {
+			return api.useContactRepository.Sync(user, history)
+		}},
+		"contactGroups": {"contacts:read", func(user *repository.User, history *repository.History) (interface{}, error) {
+			return api.useContactRepository.SyncGroups(user, history)
+		}},
+		"blobs": {"blobs:read", func(user *repository.User, history *repository.History) (interface{}, error) {
+			return api.useBlobRepository.Sync(user, history)
+		}},
+		"collections": {"blobs:read", func(user *repository.User, history *repository.History) (interface{}, error) {
+			return api.useBlobRepository.SyncCollections(user, history)
+		}},
+		"files": {"files:read", func(user *repository.User, history *repository.History) (interface{}, error) {
+			return api.useFileRepository.Sync(user, history)
+		}},
+		"drafts": {"drafts:read", func(user *repository.User, history *repository.History) (interface{}, error) {
+			return api.useDraftStorage.Sync(user, history)
+		}},
+		"messages": {"messages:read", func(user *repository.User, history *repository.History) (interface{}, error) {
+			return api.useMessageStorage.Sync(user, history)
+		}},
+		"labels": {"messages:read", func(user *repository.User, history *repository.History) (interface{}, error) {
+			return api.useLabelRepository.Sync(user, history)
+		}},
+		"savedSearches": {"searches:read", func(user *repository.User, history *repository.History) (interface{}, error) {
+			return api.useSavedSearchRepository.Sync(user, history)
+		}},
+	}
+}
+
+// Sync syncs the resources of the body at once, e.g. {"contacts": {"historyId": 12}, "blobs": {"historyId": 3}}.
+// The resources are synced concurrently, each as its own sync endpoint does. A resource failing doesn't fail
+// the others, its result is the error envelope instead, e.g. {"blobs": {"error": {"code": ..., "message": ...}}}.
+func (api *SyncApi) Sync() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		var histories map[string]*repository.History
+
+		err := helper.Decoder(r.Body).Decode(&histories)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		resources := api.resources()
+
+		for name := range histories {
+			if _, ok := resources[name]; !ok {
+				helper.ReturnErr(w, fmt.Errorf("%w: %s", repository.ErrUnknownResource, name), http.StatusBadRequest)
+				return
+			}
+		}
+
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+
+		results := make(map[string]interface{}, len(histories))
+
+		for name, history := range histories {
+			if history == nil {
+				history = &repository.History{}
+			}
+
+			wg.Add(1)
+
+			go func(name string, resource syncResource, history *repository.History) {
+				defer wg.Done()
+
+				var result interface{}
+
+				if !user.HasScope(resource.scope) {
+					result = resourceError(fmt.Errorf("%w: %s required", repository.ErrInsufficientScope, resource.scope), http.StatusForbidden)
+				} else {
+					var err error
+
+					result, err = resource.sync(user, history)
+					if err != nil {
+						provider.Logf(r.Context(), "%s sync error: %v", name, err)
+						result = resourceError(err, http.StatusInternalServerError)
+					}
+				}
+
+				mu.Lock()
+				results[name] = result
+				mu.Unlock()
+			}(name, resources[name], history)
+		}
+
+		wg.Wait()
+
+		helper.SetJsonResponse(w, http.StatusOK, results)
+	})
+}
+
+// resourceError is the result of a resource failed in the combined sync or search, in the envelope of
+// the error responses.
+func resourceError(err error, statusCode int) *helper.ErrorResponse {
+	return helper.NewErrorResponse(err, statusCode)
+}
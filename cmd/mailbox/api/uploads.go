@@ -0,0 +1,271 @@
+package api
+
+import (
+	"cargomail/cmd/mailbox/api/helper"
+	"cargomail/internal/mailbox/repository"
+	"cargomail/internal/mailbox/storage"
+	"cargomail/internal/shared/config"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// UploadsApi serves the resumable uploads of the blobs and the files. The client registers the upload,
+// sends the chunks in order with the Content-Range header, e.g. "bytes 0-1048575/5242880", and finalizes
+// it with the sha256 of the content (base64url, no padding). After a dropped connection the client
+// resumes from the offset of the upload.
+type UploadsApi struct {
+	useUploadStorage storage.UseUploadStorage
+}
+
+type uploadInitInput struct {
+	Name        string  `json:"name"`
+	ContentType string  `json:"contentType"`
+	Size        *int64  `json:"size"`
+	Hash        *string `json:"hash"`
+}
+
+type uploadFinalizeInput struct {
+	Hash *string `json:"hash"`
+}
+
+func (api *UploadsApi) Init(resource string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		var input uploadInitInput
+
+		err := helper.Decoder(r.Body).Decode(&input)
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		if len(input.Name) == 0 {
+			helper.ReturnErr(w, repository.ErrMissingNameField, http.StatusBadRequest)
+			return
+		}
+
+		if input.Size == nil || *input.Size < 0 {
+			helper.ReturnErr(w, repository.ErrInvalidContentRange, http.StatusBadRequest)
+			return
+		}
+
+		if len(input.ContentType) == 0 {
+			input.ContentType = "application/octet-stream"
+		}
+
+		if max := config.MaxUploadBytesFor(input.ContentType); *input.Size > max {
+			helper.ReturnErr(w, fmt.Errorf("%w: %d bytes allowed", repository.ErrUploadTooLarge, max), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		upload := &repository.Upload{
+			Resource:    resource,
+			Name:        input.Name,
+			ContentType: input.ContentType,
+			Size:        *input.Size,
+			Hash:        input.Hash,
+		}
+
+		upload, err = api.useUploadStorage.Init(user, upload)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrQuotaExceeded):
+				helper.ReturnErr(w, err, http.StatusRequestEntityTooLarge)
+			default:
+				helper.ReturnErr(w, err, http.StatusInternalServerError)
+			}
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusCreated, upload)
+	})
+}
+
+func (api *UploadsApi) Status(resource string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		upload, err := api.useUploadStorage.Get(user, resource, path.Base(r.URL.Path))
+		if err != nil {
+			returnUploadErr(w, err)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, upload)
+	})
+}
+
+func (api *UploadsApi) Append(resource string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		start, end, size, err := parseContentRange(r.Header.Get("Content-Range"))
+		if err != nil {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		upload, err := api.useUploadStorage.Append(user, resource, path.Base(r.URL.Path), start, end, size, r.Body)
+		if err != nil {
+			returnUploadErr(w, err)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, upload)
+	})
+}
+
+func (api *UploadsApi) Finalize(resource string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		// the hash may have been sent on init already
+		var input uploadFinalizeInput
+
+		err := helper.Decoder(r.Body).Decode(&input)
+		if err != nil && !errors.Is(err, io.EOF) {
+			helper.ReturnErr(w, err, http.StatusBadRequest)
+			return
+		}
+
+		id := path.Base(r.URL.Path)
+
+		var uploaded interface{}
+
+		switch resource {
+		case repository.BlobsResource:
+			uploaded, err = api.useUploadStorage.FinalizeBlob(user, id, input.Hash)
+		case repository.FilesResource:
+			uploaded, err = api.useUploadStorage.FinalizeFile(user, id, input.Hash)
+		default:
+			err = repository.ErrUnknownResource
+		}
+		if err != nil {
+			returnUploadErr(w, err)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusCreated, uploaded)
+	})
+}
+
+func (api *UploadsApi) Abort(resource string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(repository.UserContextKey).(*repository.User)
+		if !ok {
+			helper.ReturnErr(w, repository.ErrMissingUserContext, http.StatusInternalServerError)
+			return
+		}
+
+		err := api.useUploadStorage.Abort(user, resource, path.Base(r.URL.Path))
+		if err != nil {
+			returnUploadErr(w, err)
+			return
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, map[string]string{"status": "OK"})
+	})
+}
+
+func returnUploadErr(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repository.ErrUploadNotFound):
+		helper.ReturnErr(w, err, http.StatusNotFound)
+	case errors.Is(err, repository.ErrUploadOffsetMismatch),
+		errors.Is(err, repository.ErrUploadIncomplete):
+		helper.ReturnErr(w, err, http.StatusConflict)
+	case errors.Is(err, repository.ErrInvalidContentRange),
+		errors.Is(err, repository.ErrMissingHashField),
+		errors.Is(err, repository.ErrInvalidImage):
+		helper.ReturnErr(w, err, http.StatusBadRequest)
+	case errors.Is(err, repository.ErrUploadHashMismatch):
+		helper.ReturnErr(w, err, http.StatusUnprocessableEntity)
+	case errors.Is(err, repository.ErrQuotaExceeded):
+		helper.ReturnErr(w, err, http.StatusRequestEntityTooLarge)
+	default:
+		helper.ReturnErr(w, err, http.StatusInternalServerError)
+	}
+}
+
+// checkUploadSizes rejects the upload if any part is over the limit of its content type, before any part
+// is stored.
+func checkUploadSizes(files []*multipart.FileHeader) error {
+	for i := range files {
+		if max := config.MaxUploadBytesFor(files[i].Header.Get("Content-Type")); files[i].Size > max {
+			return fmt.Errorf("%w: %s over %d bytes", repository.ErrUploadTooLarge, files[i].Filename, max)
+		}
+	}
+
+	return nil
+}
+
+// returnMultipartErr returns 413 if the body was cut by the size limit, the spooled parts are removed
+// by the multipart reader then.
+func returnMultipartErr(w http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+
+	if errors.As(err, &maxBytesErr) {
+		helper.ReturnErr(w, fmt.Errorf("%w: %d bytes allowed", repository.ErrUploadTooLarge, config.MaxUploadBytes()), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	helper.ReturnErr(w, err, http.StatusBadRequest)
+}
+
+// parseContentRange parses the "bytes start-end/size" range of a chunk.
+func parseContentRange(contentRange string) (int64, int64, int64, error) {
+	rangeSpec, ok := strings.CutPrefix(strings.TrimSpace(contentRange), "bytes ")
+	if !ok {
+		return 0, 0, 0, repository.ErrInvalidContentRange
+	}
+
+	bounds, total, ok := strings.Cut(rangeSpec, "/")
+	if !ok {
+		return 0, 0, 0, repository.ErrInvalidContentRange
+	}
+
+	first, last, ok := strings.Cut(bounds, "-")
+	if !ok {
+		return 0, 0, 0, repository.ErrInvalidContentRange
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, 0, repository.ErrInvalidContentRange
+	}
+
+	end, err := strconv.ParseInt(last, 10, 64)
+	if err != nil || end < start {
+		return 0, 0, 0, repository.ErrInvalidContentRange
+	}
+
+	size, err := strconv.ParseInt(total, 10, 64)
+	if err != nil || end >= size {
+		return 0, 0, 0, repository.ErrInvalidContentRange
+	}
+
+	return start, end, size, nil
+}
@@ -0,0 +1,49 @@
+package mailbox
+
+import (
+	"cargomail/cmd/mailbox/api/helper"
+	"cargomail/internal/mailbox/repository"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+type gzipBody struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+func (b *gzipBody) Close() error {
+	b.Reader.Close()
+	return b.body.Close()
+}
+
+// decodeBody transparently decompresses the gzip encoded request body, the decompressed body is capped
+// at maxBytes to stop the decompression bombs. It returns false if the response has been written.
+func decodeBody(w http.ResponseWriter, r *http.Request, maxBytes int64) bool {
+	contentEncoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+
+	switch contentEncoding {
+	case "", "identity":
+		return true
+	case "gzip", "x-gzip":
+	default:
+		helper.ReturnErr(w, fmt.Errorf("%w: %s", repository.ErrUnsupportedEncoding, contentEncoding), http.StatusUnsupportedMediaType)
+		return false
+	}
+
+	reader, err := gzip.NewReader(r.Body)
+	if err != nil {
+		helper.ReturnErr(w, err, http.StatusBadRequest)
+		return false
+	}
+
+	r.Body = http.MaxBytesReader(w, &gzipBody{Reader: reader, body: r.Body}, maxBytes)
+	r.Header.Del("Content-Encoding")
+	r.Header.Del("Content-Length")
+	r.ContentLength = -1
+
+	return true
+}
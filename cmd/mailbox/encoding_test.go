@@ -0,0 +1,115 @@
+package mailbox
+
+import (
+	"bytes"
+	"cargomail/cmd/mailbox/api/helper"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// jsonHandler decodes the body as the API handlers do, it echoes the decoded name.
+var jsonHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name string `json:"name"`
+	}
+
+	err := helper.Decoder(r.Body).Decode(&input)
+	if err != nil {
+		helper.ReturnErr(w, err, http.StatusBadRequest)
+		return
+	}
+
+	w.Write([]byte(input.Name))
+})
+
+// serveEncoded serves the request of the body as the router does, with the body capped at maxBytes.
+func serveEncoded(contentEncoding string, body []byte, maxBytes int64) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/contacts", bytes.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Content-Encoding", contentEncoding)
+
+	w := httptest.NewRecorder()
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+
+	if decodeBody(w, r, maxBytes) {
+		jsonHandler.ServeHTTP(w, r)
+	}
+
+	return w
+}
+
+func gzipped(t *testing.T, data string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	zw := gzip.NewWriter(&buf)
+
+	_, err := zw.Write([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = zw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestDecodeBody(t *testing.T) {
+	for _, contentEncoding := range []string{"gzip", "x-gzip", " GZIP "} {
+		w := serveEncoded(contentEncoding, gzipped(t, `{"name":"Alice"}`), 1<<20)
+
+		if w.Code != http.StatusOK || w.Body.String() != "Alice" {
+			t.Errorf("%q: got the status %d of %q, want %d of \"Alice\"", contentEncoding, w.Code, w.Body.String(), http.StatusOK)
+		}
+	}
+
+	// the body not encoded is read as is
+	for _, contentEncoding := range []string{"", "identity"} {
+		w := serveEncoded(contentEncoding, []byte(`{"name":"Bob"}`), 1<<20)
+
+		if w.Code != http.StatusOK || w.Body.String() != "Bob" {
+			t.Errorf("%q: got the status %d of %q, want %d of \"Bob\"", contentEncoding, w.Code, w.Body.String(), http.StatusOK)
+		}
+	}
+
+	w := serveEncoded("gzip", []byte(`{"name":"Carol"}`), 1<<20)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("got the status %d of the body not gzipped, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestDecodeBodyUnsupportedEncoding(t *testing.T) {
+	for _, contentEncoding := range []string{"br", "deflate", "gzip, br"} {
+		w := serveEncoded(contentEncoding, []byte(`{"name":"Alice"}`), 1<<20)
+
+		if w.Code != http.StatusUnsupportedMediaType || !strings.Contains(w.Body.String(), `"unsupported_encoding"`) {
+			t.Errorf("%q: got the status %d of %s, want %d", contentEncoding, w.Code, w.Body.String(), http.StatusUnsupportedMediaType)
+		}
+	}
+}
+
+func TestDecodeBodyBomb(t *testing.T) {
+	// 64MB inflated from well under the cap
+	bomb := gzipped(t, `{"name":"`+strings.Repeat("a", 64<<20)+`"}`)
+
+	var maxBytes int64 = 1 << 20
+
+	if int64(len(bomb)) >= maxBytes {
+		t.Fatalf("got the bomb of %d bytes, want it under the cap", len(bomb))
+	}
+
+	w := serveEncoded("gzip", bomb, maxBytes)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("got the status %d, want %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+}
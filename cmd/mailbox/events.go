@@ -0,0 +1,109 @@
+package mailbox
+
+import (
+	"cargomail/cmd/mailbox/api"
+	"cargomail/internal/mailbox/repository"
+	"context"
+	"log"
+	"time"
+)
+
+const (
+	eventDispatchInterval = time.Second
+	eventBatchSize        = 100
+	eventRetention        = 24 * time.Hour
+	eventPurgeInterval    = time.Hour
+)
+
+// eventSink receives the change notifications of the outbox, e.g. the event stream subscribers or
+// the webhooks. An event may be delivered more than once, e.g. after a restart.
+type eventSink interface {
+	deliver(event *repository.Event) error
+}
+
+// streamSink notifies the event streams of the users, the streams still open are told only.
+type streamSink struct {
+	broker *api.EventBroker
+}
+
+func (s *streamSink) deliver(event *repository.Event) error {
+	s.broker.Publish(event)
+	return nil
+}
+
+// dispatchEvents delivers the events of the outbox to the sinks until the context is cancelled. An event
+// is marked as sent only when all the sinks accepted it, the undelivered ones are retried on the next tick.
+func (svc *service) dispatchEvents(ctx context.Context) error {
+	ticker := time.NewTicker(eventDispatchInterval)
+	defer ticker.Stop()
+
+	var lastPurge time.Time
+
+	for {
+		for {
+			dispatched, err := svc.dispatchEventBatch()
+			if err != nil {
+				// try again on the next tick
+				log.Printf("event dispatcher error: %v", err)
+				break
+			}
+			if dispatched < eventBatchSize {
+				break
+			}
+		}
+
+		if time.Since(lastPurge) > eventPurgeInterval {
+			_, err := svc.repository.Events.Purge(eventRetention)
+			if err != nil {
+				log.Printf("event dispatcher error: %v", err)
+			} else {
+				lastPurge = time.Now()
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatchEventBatch delivers a batch of the pending events in order and returns the number of the
+// delivered ones.
+func (svc *service) dispatchEventBatch() (int, error) {
+	events, err := svc.repository.Events.Pending(eventBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var lastId int64
+	dispatched := 0
+
+	for _, event := range events {
+		if err = svc.deliverEvent(event); err != nil {
+			break
+		}
+
+		lastId = event.Id
+		dispatched++
+	}
+
+	if dispatched > 0 {
+		if markErr := svc.repository.Events.MarkSent(lastId); markErr != nil {
+			return 0, markErr
+		}
+	}
+
+	return dispatched, err
+}
+
+func (svc *service) deliverEvent(event *repository.Event) error {
+	for _, sink := range svc.eventSinks {
+		if err := sink.deliver(event); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,154 @@
+package mailbox
+
+import (
+	"cargomail/internal/mailbox/repository"
+	"cargomail/internal/shared/database"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// newTestService returns the service of a new database with the sinks, and a user of it.
+func newTestService(t *testing.T, sinks ...eventSink) (*service, *repository.User) {
+	t.Helper()
+
+	db, err := database.Connect(filepath.Join(t.TempDir(), "cargomail.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	database.Init(db)
+
+	user := &repository.User{Username: "alice"}
+
+	err = db.QueryRow(`INSERT INTO "User" ("username", "passwordHash") VALUES ($1, '-') RETURNING "id";`, user.Username).
+		Scan(&user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deviceId := "0123456789abcdef0123456789abcdef"
+	user.DeviceId = &deviceId
+
+	svc := &service{
+		repository: repository.NewRepositoryWithTimeouts(db, repository.Timeouts{Read: 5 * time.Second, Write: 5 * time.Second}),
+		eventSinks: sinks,
+	}
+
+	// the events of the seeded system labels are out of the way
+	_, err = db.Exec(`UPDATE "Event" SET "sentAt" = CURRENT_TIMESTAMP;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return svc, user
+}
+
+// testSink records the delivered events, it fails the delivery of the event of the failId once.
+type testSink struct {
+	failId    int64
+	delivered []int64
+}
+
+func (s *testSink) deliver(event *repository.Event) error {
+	if event.Id == s.failId {
+		s.failId = 0
+		return errors.New("sink unavailable")
+	}
+
+	s.delivered = append(s.delivered, event.Id)
+	return nil
+}
+
+func createLabels(t *testing.T, svc *service, user *repository.User, names ...string) {
+	t.Helper()
+
+	for _, name := range names {
+		_, err := svc.repository.Labels.Create(user, &repository.Label{Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDispatchEventsRedelivers(t *testing.T) {
+	sink := &testSink{}
+	svc, user := newTestService(t, sink)
+
+	createLabels(t, svc, user, "Work", "Home", "Travel")
+
+	events, err := svc.repository.Events.Pending(eventBatchSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 3 {
+		t.Fatalf("got %d pending events, want 3", len(events))
+	}
+
+	sink.failId = events[1].Id
+
+	dispatched, err := svc.dispatchEventBatch()
+	if err == nil || dispatched != 1 {
+		t.Fatalf("got %d dispatched and %v, want 1 and the error of the sink", dispatched, err)
+	}
+
+	// the undispatched events are left pending, in their order
+	dispatched, err = svc.dispatchEventBatch()
+	if err != nil || dispatched != 2 {
+		t.Fatalf("got %d dispatched and %v on the retry, want 2", dispatched, err)
+	}
+
+	want := []int64{events[0].Id, events[1].Id, events[2].Id}
+
+	if len(sink.delivered) != len(want) {
+		t.Fatalf("got the events %v delivered, want %v", sink.delivered, want)
+	}
+
+	for i := range want {
+		if sink.delivered[i] != want[i] {
+			t.Fatalf("got the events %v delivered, want %v", sink.delivered, want)
+		}
+	}
+
+	pending, err := svc.repository.Events.Pending(eventBatchSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pending) != 0 {
+		t.Errorf("got %d events pending after the retry, want 0", len(pending))
+	}
+}
+
+func TestMarkSentKeepsLaterEvents(t *testing.T) {
+	svc, user := newTestService(t)
+
+	createLabels(t, svc, user, "Work", "Home")
+
+	events, err := svc.repository.Events.Pending(eventBatchSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// committed after the batch was read
+	createLabels(t, svc, user, "Travel")
+
+	err = svc.repository.Events.MarkSent(events[len(events)-1].Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err := svc.repository.Events.Pending(eventBatchSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pending) != 1 || pending[0].Id <= events[len(events)-1].Id {
+		t.Errorf("got %d events pending after the batch, want the one committed after it", len(pending))
+	}
+}
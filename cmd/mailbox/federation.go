@@ -0,0 +1,184 @@
+package mailbox
+
+import (
+	"bytes"
+	"cargomail/cmd/mailbox/api/helper"
+	"cargomail/internal/mailbox/repository"
+	"cargomail/internal/shared/config"
+	"context"
+	"crypto/ed25519"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	federationSendInterval   = 5 * time.Second
+	federationBatchSize      = 50
+	federationConcurrency    = 8
+	federationTimeout        = 30 * time.Second
+	federationMaxAttempts    = 20 // about 4 days
+	federationInitialBackoff = time.Minute
+	federationMaxBackoff     = 6 * time.Hour
+)
+
+// errFederationRejected is the 4xx response of the peer, the delivery is not retried.
+var errFederationRejected = errors.New("federation peer rejected the message")
+
+// federationClient doesn't follow the redirects, the peers are trusted by their certificates but in the
+// dev stage.
+func federationClient() *http.Client {
+	return &http.Client{
+		Timeout: federationTimeout,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: config.DevStage()},
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// sendFederated delivers the due messages to the peer instances until the context is cancelled. A failed
+// delivery is retried with an exponential backoff, and given up after federationMaxAttempts.
+func (svc *service) sendFederated(ctx context.Context) error {
+	client := federationClient()
+
+	ticker := time.NewTicker(federationSendInterval)
+	defer ticker.Stop()
+
+	for {
+		deliveries, err := svc.repository.Federation.Due(federationBatchSize)
+		if err != nil {
+			// try again on the next tick
+			log.Printf("federation sender error: %v", err)
+		}
+
+		var key ed25519.PrivateKey
+
+		if len(deliveries) > 0 {
+			key, err = svc.repository.Federation.InstanceKey()
+			if err != nil {
+				log.Printf("federation sender error: %v", err)
+				deliveries = nil
+			}
+		}
+
+		var wg sync.WaitGroup
+		slots := make(chan struct{}, federationConcurrency)
+
+		for _, delivery := range deliveries {
+			wg.Add(1)
+			slots <- struct{}{}
+
+			go func(delivery *repository.FederationDelivery) {
+				defer wg.Done()
+				defer func() { <-slots }()
+
+				svc.sendFederatedDelivery(ctx, client, key, delivery)
+			}(delivery)
+		}
+
+		wg.Wait()
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (svc *service) sendFederatedDelivery(ctx context.Context, client *http.Client, key ed25519.PrivateKey, delivery *repository.FederationDelivery) {
+	// the content of the message is sent with it
+	envelope, err := svc.storage.Federation.Envelope(delivery)
+	if err == nil {
+		err = postFederated(ctx, client, key, delivery.Domain, envelope)
+	}
+
+	// the file of an attachment deleted meanwhile is not coming back
+	if err == nil || errors.Is(err, errFederationRejected) || errors.Is(err, repository.ErrFileNotFound) ||
+		delivery.Attempts+1 >= federationMaxAttempts {
+		if err != nil {
+			log.Printf("federation delivery to %s of %v gave up after %d attempts: %v", delivery.Domain, delivery.Envelope.Recipients, delivery.Attempts+1, err)
+		}
+
+		err = svc.repository.Federation.Done(delivery.Id)
+		if err != nil {
+			log.Printf("federation sender error: %v", err)
+		}
+		return
+	}
+
+	err = svc.repository.Federation.Retry(delivery.Id, err.Error(), backoff(delivery.Attempts, federationInitialBackoff, federationMaxBackoff))
+	if err != nil {
+		log.Printf("federation sender error: %v", err)
+	}
+}
+
+// postFederated posts the envelope signed by the key of the instance to the instance of the domain, any
+// 2xx status accepts it.
+func postFederated(ctx context.Context, client *http.Client, key ed25519.PrivateKey, domain string, envelope *repository.FederationEnvelope) error {
+	baseUrl, ok := config.FederationPeer(domain)
+	if !ok {
+		return fmt.Errorf("%w: %s is no longer a peer", errFederationRejected, domain)
+	}
+
+	body, err := helper.CanonicalEnvelope(envelope)
+	if err != nil {
+		return err
+	}
+
+	signature, err := helper.SignEnvelope(key, envelope, time.Now())
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseUrl+"/api/v1/federation/deliver", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("User-Agent", "cargomail-federation")
+	req.Header.Set("X-Cargomail-Signature", signature)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var receipt repository.FederationReceipt
+
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&receipt)
+
+		if len(receipt.NotFound) > 0 {
+			log.Printf("federation delivery to %s, recipients not found: %v", domain, receipt.NotFound)
+		}
+
+		return nil
+	}
+
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	// the rejected signature (e.g. the peer is yet to set the key of the instance), the request timeout and
+	// the rate limit are worth a retry, the other 4xx are not
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return fmt.Errorf("federation peer responded %s", resp.Status)
+	}
+
+	if resp.StatusCode >= 400 && resp.StatusCode <= 499 {
+		return fmt.Errorf("%w: %s", errFederationRejected, resp.Status)
+	}
+
+	return fmt.Errorf("federation peer responded %s", resp.Status)
+}
@@ -0,0 +1,57 @@
+package mailbox
+
+import (
+	"cargomail/cmd/mailbox/rpc"
+	"cargomail/internal/shared/config"
+	"context"
+	"log"
+	"net"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// serveGrpc serves the gRPC services next to the REST API over the TLS of the MDS certificate, if the
+// mdsGrpcBindTLS is set.
+func (svc *service) serveGrpc(ctx context.Context, errs *errgroup.Group) {
+	bind := config.Configuration.MDSGrpcBindTLS
+	if len(bind) == 0 {
+		return
+	}
+
+	errs.Go(func() error {
+		creds, err := credentials.NewServerTLSFromFile(config.Configuration.MDSServerCertPath, config.Configuration.MDSServerKeyPath)
+		if err != nil {
+			return err
+		}
+
+		server := rpc.NewServer(
+			rpc.ServerParams{
+				Repository: svc.repository,
+				Storage:    svc.storage,
+				UserLimits: svc.userLimits,
+			},
+			grpc.Creds(creds))
+
+		listener, err := net.Listen("tcp", bind)
+		if err != nil {
+			return err
+		}
+
+		go func() {
+			<-ctx.Done()
+
+			// the streamed downloads would hold the graceful stop
+			timer := time.AfterFunc(10*time.Second, server.Stop)
+			server.GracefulStop()
+			timer.Stop()
+
+			log.Print("grpc MDS shutdown gracefully")
+		}()
+
+		log.Printf("grpc MDS is listening on %s", bind)
+		return server.Serve(listener)
+	})
+}
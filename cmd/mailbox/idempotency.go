@@ -0,0 +1,35 @@
+package mailbox
+
+import (
+	"cargomail/internal/shared/config"
+	"context"
+	"log"
+	"time"
+)
+
+const idempotencySweepInterval = time.Hour
+
+// expireIdempotencyKeys forgets the idempotency keys past their TTL periodically until the context is
+// cancelled.
+func (svc *service) expireIdempotencyKeys(ctx context.Context) error {
+	ttl := config.IdempotencyKeyTTL()
+
+	ticker := time.NewTicker(idempotencySweepInterval)
+	defer ticker.Stop()
+
+	for {
+		expired, err := svc.repository.Idempotency.Expire(ttl)
+		if err != nil {
+			// try again on the next tick
+			log.Printf("idempotency key sweeper error: %v", err)
+		} else if expired > 0 {
+			log.Printf("idempotency key sweeper removed %d expired keys", expired)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
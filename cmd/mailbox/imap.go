@@ -0,0 +1,51 @@
+package mailbox
+
+import (
+	"cargomail/cmd/mailbox/imap"
+	"cargomail/internal/shared/config"
+	"context"
+	"crypto/tls"
+	"log"
+	"net"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// serveImap serves the messages to the legacy IMAP clients over the TLS of the MDS certificate, if the
+// mdsImapBindTLS is set.
+func (svc *service) serveImap(ctx context.Context, errs *errgroup.Group) {
+	bind := config.Configuration.MDSImapBindTLS
+	if len(bind) == 0 {
+		return
+	}
+
+	errs.Go(func() error {
+		cert, err := tls.LoadX509KeyPair(config.Configuration.MDSServerCertPath, config.Configuration.MDSServerKeyPath)
+		if err != nil {
+			return err
+		}
+
+		server := imap.NewServer(
+			imap.ServerParams{
+				Repository: svc.repository,
+				Storage:    svc.storage,
+			},
+			&tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12})
+
+		listener, err := net.Listen("tcp", bind)
+		if err != nil {
+			return err
+		}
+
+		go func() {
+			<-ctx.Done()
+
+			server.Shutdown()
+
+			log.Print("imap MDS shutdown gracefully")
+		}()
+
+		log.Printf("imap MDS is listening on %s", bind)
+		return server.Serve(listener)
+	})
+}
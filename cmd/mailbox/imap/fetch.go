@@ -0,0 +1,705 @@
+package imap
+
+import (
+	"bufio"
+	"bytes"
+	"cargomail/internal/mailbox/repository"
+	"errors"
+	"mime"
+	"net/mail"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var errInvalidFetchItem = errors.New("invalid fetch item")
+
+// fetchItem is an item of the FETCH, the body ones are of the section and the partial range.
+type fetchItem struct {
+	name    string // e.g. FLAGS, BODY[] or BODYSTRUCTURE
+	section string // of the BODY[section]
+	peek    bool
+	partial []int // the origin and the length of the <origin.length>
+}
+
+var fetchMacros = map[string][]string{
+	"ALL":  {"FLAGS", "INTERNALDATE", "RFC822.SIZE", "ENVELOPE"},
+	"FAST": {"FLAGS", "INTERNALDATE", "RFC822.SIZE"},
+	"FULL": {"FLAGS", "INTERNALDATE", "RFC822.SIZE", "ENVELOPE", "BODY"},
+}
+
+func parseFetchItems(arg interface{}) ([]*fetchItem, error) {
+	var names []string
+
+	switch arg := arg.(type) {
+	case atom:
+		if macro, ok := fetchMacros[strings.ToUpper(string(arg))]; ok {
+			names = macro
+		} else {
+			names = []string{string(arg)}
+		}
+	case list:
+		for _, a := range arg {
+			name, ok := a.(atom)
+			if !ok {
+				return nil, errInvalidFetchItem
+			}
+			names = append(names, string(name))
+		}
+	default:
+		return nil, errInvalidFetchItem
+	}
+
+	items := make([]*fetchItem, 0, len(names))
+
+	for _, name := range names {
+		item, err := parseFetchItem(name)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+func parseFetchItem(name string) (*fetchItem, error) {
+	open := strings.IndexByte(name, '[')
+	if open < 0 {
+		switch upper := strings.ToUpper(name); upper {
+		case "UID", "FLAGS", "INTERNALDATE", "RFC822.SIZE", "ENVELOPE", "BODY", "BODYSTRUCTURE":
+			return &fetchItem{name: upper}, nil
+		case "RFC822":
+			return &fetchItem{name: "RFC822"}, nil
+		case "RFC822.HEADER":
+			return &fetchItem{name: "RFC822.HEADER", section: "HEADER", peek: true}, nil
+		case "RFC822.TEXT":
+			return &fetchItem{name: "RFC822.TEXT", section: "TEXT"}, nil
+		}
+
+		return nil, errInvalidFetchItem
+	}
+
+	item := &fetchItem{}
+
+	switch strings.ToUpper(name[:open]) {
+	case "BODY":
+	case "BODY.PEEK":
+		item.peek = true
+	default:
+		return nil, errInvalidFetchItem
+	}
+
+	end := strings.LastIndexByte(name, ']')
+	if end < open {
+		return nil, errInvalidFetchItem
+	}
+
+	item.section = name[open+1 : end]
+
+	if partial := name[end+1:]; len(partial) > 0 {
+		spec, ok := strings.CutPrefix(partial, "<")
+		spec, ok2 := strings.CutSuffix(spec, ">")
+		origin, length, ok3 := strings.Cut(spec, ".")
+		if !ok || !ok2 || !ok3 {
+			return nil, errInvalidFetchItem
+		}
+
+		o, err := strconv.Atoi(origin)
+		if err != nil || o < 0 {
+			return nil, errInvalidFetchItem
+		}
+
+		l, err := strconv.Atoi(length)
+		if err != nil || l <= 0 {
+			return nil, errInvalidFetchItem
+		}
+
+		item.partial = []int{o, l}
+	}
+
+	item.name = "BODY[" + item.section + "]"
+
+	return item, nil
+}
+
+func (c *conn) fetch(args list, uid bool) error {
+	if len(args) != 2 {
+		return badArguments
+	}
+
+	s, _ := argString(args[0])
+
+	set, err := parseSeqSet(s)
+	if err != nil {
+		return bad(err.Error())
+	}
+
+	items, err := parseFetchItems(args[1])
+	if err != nil {
+		return bad(err.Error())
+	}
+
+	if uid && !hasUidItem(items) {
+		items = append([]*fetchItem{{name: "UID"}}, items...)
+	}
+
+	seqs, messages := c.selected.messagesOf(set, uid)
+
+	for i, m := range messages {
+		response, err := c.fetchMessage(m, items)
+		if err != nil {
+			return err
+		}
+
+		c.writeString("* " + strconv.Itoa(seqs[i]) + " FETCH (" + response + ")\r\n")
+	}
+
+	return nil
+}
+
+func hasUidItem(items []*fetchItem) bool {
+	for _, item := range items {
+		if item.name == "UID" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *conn) fetchMessage(m *message, items []*fetchItem) (string, error) {
+	var raw []byte
+	var root *part
+
+	// the message is rendered for the items of its content only
+	rendered := func() (*part, error) {
+		if root != nil {
+			return root, nil
+		}
+
+		var err error
+
+		raw, err = c.server.params.Storage.Messages.RenderMIME(c.user, m.message)
+		if err != nil {
+			return nil, err
+		}
+
+		root = parsePart(raw)
+
+		return root, nil
+	}
+
+	var responses []string
+
+	seen := false
+	flags := false
+
+	for _, item := range items {
+		switch item.name {
+		case "UID":
+			responses = append(responses, "UID "+strconv.FormatUint(uint64(m.uid), 10))
+		case "FLAGS":
+			flags = true
+			responses = append(responses, "FLAGS "+m.flags())
+		case "INTERNALDATE":
+			responses = append(responses, `INTERNALDATE "`+m.internalDate.Format("02-Jan-2006 15:04:05 -0700")+`"`)
+		case "RFC822.SIZE":
+			if _, err := rendered(); err != nil {
+				return "", err
+			}
+			responses = append(responses, "RFC822.SIZE "+strconv.Itoa(len(raw)))
+		case "ENVELOPE":
+			p, err := rendered()
+			if err != nil {
+				return "", err
+			}
+			responses = append(responses, "ENVELOPE "+envelope(p.fields))
+		case "BODY", "BODYSTRUCTURE":
+			p, err := rendered()
+			if err != nil {
+				return "", err
+			}
+			responses = append(responses, item.name+" "+p.structure(item.name == "BODYSTRUCTURE"))
+		default:
+			p, err := rendered()
+			if err != nil {
+				return "", err
+			}
+
+			var data []byte
+
+			if item.name == "RFC822" {
+				data = raw
+			} else {
+				data, err = p.section(item.section, raw)
+				if err != nil {
+					return "", err
+				}
+			}
+
+			name := item.name
+
+			if item.partial != nil {
+				origin, length := item.partial[0], item.partial[1]
+
+				if origin > len(data) {
+					origin = len(data)
+				}
+				if origin+length > len(data) {
+					length = len(data) - origin
+				}
+
+				data = data[origin : origin+length]
+				name += "<" + strconv.Itoa(item.partial[0]) + ">"
+			}
+
+			responses = append(responses, name+" {"+strconv.Itoa(len(data))+"}\r\n"+string(data))
+
+			// the fetch of the content sets the \Seen flag
+			if !item.peek {
+				seen = true
+			}
+		}
+	}
+
+	if seen && m.message.Unread && !c.selected.readOnly {
+		err := c.setFlags([]*message{m}, boolPtr(false), nil)
+		if err != nil {
+			return "", err
+		}
+
+		if !flags {
+			responses = append(responses, "FLAGS "+m.flags())
+		}
+	}
+
+	return strings.Join(responses, " "), nil
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+// setFlags sets the unread and the starred flags of the messages, the nil ones are left intact.
+func (c *conn) setFlags(messages []*message, unread, starred *bool) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	state := &repository.State{Unread: unread, Starred: starred}
+
+	for _, m := range messages {
+		state.Ids = append(state.Ids, m.message.Id)
+	}
+
+	err := c.server.params.Repository.Messages.Update(c.user, state)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range messages {
+		if unread != nil {
+			m.message.Unread = *unread
+		}
+		if starred != nil {
+			m.message.Starred = *starred
+		}
+	}
+
+	return nil
+}
+
+// store serves the STORE of the \Seen and the \Flagged flags, the unread and the starred of the messages,
+// the other flags aren't kept.
+func (c *conn) store(args list, uid bool) error {
+	if len(args) != 3 {
+		return badArguments
+	}
+
+	if c.selected.readOnly {
+		return no("mailbox is read-only")
+	}
+
+	s, _ := argString(args[0])
+
+	set, err := parseSeqSet(s)
+	if err != nil {
+		return bad(err.Error())
+	}
+
+	action, _ := argString(args[1])
+	action = strings.ToUpper(action)
+
+	silent := strings.HasSuffix(action, ".SILENT")
+	action = strings.TrimSuffix(action, ".SILENT")
+
+	var flagList list
+
+	switch arg := args[2].(type) {
+	case list:
+		flagList = arg
+	case atom:
+		flagList = list{arg}
+	default:
+		return badArguments
+	}
+
+	var seenFlag, flaggedFlag bool
+
+	for _, flag := range flagList {
+		f, _ := argString(flag)
+
+		switch strings.ToLower(f) {
+		case `\seen`:
+			seenFlag = true
+		case `\flagged`:
+			flaggedFlag = true
+		}
+	}
+
+	var unread, starred *bool
+
+	switch action {
+	case "FLAGS":
+		unread, starred = boolPtr(!seenFlag), boolPtr(flaggedFlag)
+	case "+FLAGS", "-FLAGS":
+		add := action == "+FLAGS"
+
+		if seenFlag {
+			unread = boolPtr(!add)
+		}
+		if flaggedFlag {
+			starred = boolPtr(add)
+		}
+	default:
+		return bad("invalid store action")
+	}
+
+	seqs, messages := c.selected.messagesOf(set, uid)
+
+	if unread != nil || starred != nil {
+		err = c.setFlags(messages, unread, starred)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !silent {
+		for i, m := range messages {
+			response := "FLAGS " + m.flags()
+			if uid {
+				response = "UID " + strconv.FormatUint(uint64(m.uid), 10) + " " + response
+			}
+
+			c.writeString("* " + strconv.Itoa(seqs[i]) + " FETCH (" + response + ")\r\n")
+		}
+	}
+
+	return nil
+}
+
+// part is a part of the rendered message, the multipart ones have the parts of their own.
+type part struct {
+	header    []byte // with the blank line that ends it
+	body      []byte
+	fields    textproto.MIMEHeader
+	mediaType string
+	params    map[string]string
+	parts     []*part
+}
+
+func parsePart(raw []byte) *part {
+	p := &part{}
+
+	if i := bytes.Index(raw, []byte("\r\n\r\n")); i >= 0 {
+		p.header, p.body = raw[:i+4], raw[i+4:]
+	} else if bytes.HasPrefix(raw, []byte("\r\n")) {
+		p.header, p.body = raw[:2], raw[2:]
+	} else {
+		p.header = raw
+	}
+
+	p.fields, _ = textproto.NewReader(bufio.NewReader(bytes.NewReader(p.header))).ReadMIMEHeader()
+
+	mediaType, params, err := mime.ParseMediaType(p.fields.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{"charset": "us-ascii"}
+	}
+
+	p.mediaType, p.params = mediaType, params
+
+	if boundary := params["boundary"]; strings.HasPrefix(mediaType, "multipart/") && len(boundary) > 0 {
+		for _, body := range splitMultipart(p.body, boundary) {
+			p.parts = append(p.parts, parsePart(body))
+		}
+	}
+
+	return p
+}
+
+// splitMultipart returns the body parts between the boundary delimiters.
+func splitMultipart(body []byte, boundary string) [][]byte {
+	delimiter := []byte("\r\n--" + boundary)
+
+	// the first delimiter may start the body
+	b := append([]byte("\r\n"), body...)
+
+	var parts [][]byte
+
+	i := bytes.Index(b, delimiter)
+
+	for i >= 0 {
+		rest := b[i+len(delimiter):]
+		if bytes.HasPrefix(rest, []byte("--")) {
+			break
+		}
+
+		eol := bytes.Index(rest, []byte("\r\n"))
+		if eol < 0 {
+			break
+		}
+
+		rest = rest[eol+2:]
+
+		next := bytes.Index(rest, delimiter)
+		if next < 0 {
+			parts = append(parts, rest)
+			break
+		}
+
+		parts = append(parts, rest[:next])
+		b = rest[next:]
+		i = 0
+	}
+
+	return parts
+}
+
+// section returns the content of the BODY[section], e.g. HEADER, 1.2 or 2.MIME.
+func (p *part) section(section string, raw []byte) ([]byte, error) {
+	upper := strings.ToUpper(section)
+
+	switch {
+	case len(section) == 0:
+		return raw, nil
+	case upper == "HEADER":
+		return p.header, nil
+	case upper == "TEXT":
+		return p.body, nil
+	case strings.HasPrefix(upper, "HEADER.FIELDS"):
+		not := strings.HasPrefix(upper, "HEADER.FIELDS.NOT")
+
+		open, end := strings.IndexByte(section, '('), strings.LastIndexByte(section, ')')
+		if open < 0 || end < open {
+			return nil, bad("invalid section")
+		}
+
+		names := map[string]bool{}
+		for _, name := range strings.Fields(section[open+1 : end]) {
+			names[textproto.CanonicalMIMEHeaderKey(strings.Trim(name, `"`))] = true
+		}
+
+		return filterHeader(p.header, names, not), nil
+	}
+
+	number, rest, _ := strings.Cut(section, ".")
+
+	n, err := strconv.Atoi(number)
+	if err != nil || n <= 0 {
+		return nil, bad("invalid section")
+	}
+
+	var child *part
+
+	switch {
+	case n <= len(p.parts):
+		child = p.parts[n-1]
+	case n == 1 && len(p.parts) == 0:
+		// the single part message is its part 1
+		child = &part{header: p.header, body: p.body, fields: p.fields}
+	default:
+		return []byte{}, nil
+	}
+
+	switch strings.ToUpper(rest) {
+	case "":
+		return child.body, nil
+	case "MIME":
+		return child.header, nil
+	}
+
+	if len(child.parts) == 0 {
+		return []byte{}, nil
+	}
+
+	return child.section(rest, child.body)
+}
+
+// filterHeader returns the fields of the names, or with the not the fields of the other names, of the header.
+func filterHeader(header []byte, names map[string]bool, not bool) []byte {
+	var filtered bytes.Buffer
+
+	keep := false
+
+	for _, line := range strings.SplitAfter(string(header), "\r\n") {
+		if len(line) == 0 || line == "\r\n" {
+			continue
+		}
+
+		if line[0] != ' ' && line[0] != '\t' {
+			name, _, _ := strings.Cut(line, ":")
+			keep = names[textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(name))] != not
+		}
+
+		if keep {
+			filtered.WriteString(line)
+		}
+	}
+
+	filtered.WriteString("\r\n")
+
+	return filtered.Bytes()
+}
+
+// structure returns the BODY, or with the extended the BODYSTRUCTURE, of the part (RFC 3501, 7.4.2).
+func (p *part) structure(extended bool) string {
+	var sb strings.Builder
+
+	sb.WriteString("(")
+
+	mediaType, subtype, _ := strings.Cut(p.mediaType, "/")
+
+	if len(p.parts) > 0 {
+		for _, child := range p.parts {
+			sb.WriteString(child.structure(extended))
+		}
+
+		sb.WriteString(" " + quote(strings.ToUpper(subtype)))
+
+		if extended {
+			sb.WriteString(" " + bodyParams(p.params) + " " + disposition(p.fields.Get("Content-Disposition")) + " NIL NIL")
+		}
+	} else {
+		encoding := strings.ToUpper(p.fields.Get("Content-Transfer-Encoding"))
+		if len(encoding) == 0 {
+			encoding = "7BIT"
+		}
+
+		sb.WriteString(quote(strings.ToUpper(mediaType)) + " " + quote(strings.ToUpper(subtype)) + " " + bodyParams(p.params))
+		sb.WriteString(" " + nstring(p.fields.Get("Content-Id")) + " " + nstring(p.fields.Get("Content-Description")))
+		sb.WriteString(" " + quote(encoding) + " " + strconv.Itoa(len(p.body)))
+
+		if strings.EqualFold(mediaType, "text") {
+			// the last line may lack the CRLF
+			lines := bytes.Count(p.body, []byte("\n"))
+			if len(p.body) > 0 && p.body[len(p.body)-1] != '\n' {
+				lines++
+			}
+
+			sb.WriteString(" " + strconv.Itoa(lines))
+		}
+
+		if extended {
+			sb.WriteString(" NIL " + disposition(p.fields.Get("Content-Disposition")) + " NIL NIL")
+		}
+	}
+
+	sb.WriteString(")")
+
+	return sb.String()
+}
+
+func bodyParams(params map[string]string) string {
+	var keys []string
+
+	for k := range params {
+		keys = append(keys, k)
+	}
+
+	if len(keys) == 0 {
+		return "NIL"
+	}
+
+	sort.Strings(keys)
+
+	values := make([]string, 0, 2*len(keys))
+	for _, k := range keys {
+		values = append(values, quote(strings.ToUpper(k)), quote(params[k]))
+	}
+
+	return "(" + strings.Join(values, " ") + ")"
+}
+
+func disposition(value string) string {
+	if len(value) == 0 {
+		return "NIL"
+	}
+
+	dispositionType, params, err := mime.ParseMediaType(value)
+	if err != nil {
+		return "NIL"
+	}
+
+	return "(" + quote(strings.ToUpper(dispositionType)) + " " + bodyParams(params) + ")"
+}
+
+// envelope returns the ENVELOPE of the message header (RFC 3501, 7.4.2).
+func envelope(fields textproto.MIMEHeader) string {
+	from := addressList(fields.Get("From"))
+
+	sender := addressList(fields.Get("Sender"))
+	if sender == "NIL" {
+		sender = from
+	}
+
+	replyTo := addressList(fields.Get("Reply-To"))
+	if replyTo == "NIL" {
+		replyTo = from
+	}
+
+	return "(" + strings.Join([]string{
+		nstring(fields.Get("Date")),
+		nstring(fields.Get("Subject")),
+		from,
+		sender,
+		replyTo,
+		addressList(fields.Get("To")),
+		addressList(fields.Get("Cc")),
+		addressList(fields.Get("Bcc")),
+		nstring(fields.Get("In-Reply-To")),
+		nstring(fields.Get("Message-Id")),
+	}, " ") + ")"
+}
+
+func addressList(value string) string {
+	if len(value) == 0 {
+		return "NIL"
+	}
+
+	addresses, err := mail.ParseAddressList(value)
+	if err != nil || len(addresses) == 0 {
+		return "NIL"
+	}
+
+	var sb strings.Builder
+
+	sb.WriteString("(")
+
+	for _, address := range addresses {
+		mailbox, host, _ := strings.Cut(address.Address, "@")
+
+		name := address.Name
+		if len(name) > 0 {
+			name = mime.QEncoding.Encode("utf-8", name)
+		}
+
+		sb.WriteString("(" + nstring(name) + " NIL " + nstring(mailbox) + " " + nstring(host) + ")")
+	}
+
+	sb.WriteString(")")
+
+	return sb.String()
+}
@@ -0,0 +1,658 @@
+// Package imap serves the messages of the mailbox to the legacy IMAP clients (RFC 3501), e.g. Thunderbird or
+// Apple Mail. The INBOX and the Sent mailboxes are the folders of the messages, the Labels/<label> ones their
+// labels. It is read-mostly, the \Seen and the \Flagged flags are the unread and the starred of the messages,
+// the messages can't be appended, copied or expunged.
+package imap
+
+import (
+	"bufio"
+	"cargomail/internal/mailbox/repository"
+	"cargomail/internal/mailbox/storage"
+	"cargomail/internal/shared/config"
+	"crypto/tls"
+	"errors"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	capabilities = "IMAP4rev1 LITERAL+ UNSELECT SPECIAL-USE"
+	// autologout is how long an idle connection is kept, at least 30 minutes by the RFC
+	autologout   = 30 * time.Minute
+	writeTimeout = time.Minute
+)
+
+type ServerParams struct {
+	Repository repository.Repository
+	Storage    storage.Storage
+}
+
+type Server struct {
+	params    ServerParams
+	tlsConfig *tls.Config
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[*conn]bool
+	closed   bool
+}
+
+// NewServer returns the IMAP server of the TLS config, the clients log in by the username and the password
+// of the user as they do to the webmail.
+func NewServer(params ServerParams, tlsConfig *tls.Config) *Server {
+	return &Server{
+		params:    params,
+		tlsConfig: tlsConfig,
+		conns:     map[*conn]bool{},
+	}
+}
+
+// Serve serves the connections of the listener over the implicit TLS until the Shutdown.
+func (s *Server) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return listener.Close()
+	}
+	s.listener = tls.NewListener(listener, s.tlsConfig)
+	s.mu.Unlock()
+
+	for {
+		netConn, err := s.listener.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+
+			if closed {
+				return nil
+			}
+
+			return err
+		}
+
+		c := &conn{
+			server:  s,
+			netConn: netConn,
+			w:       bufio.NewWriter(netConn),
+		}
+
+		if host, _, err := net.SplitHostPort(netConn.RemoteAddr().String()); err == nil {
+			c.ip = host
+		}
+
+		c.parser = &parser{r: bufio.NewReader(netConn), continuation: c.continuation}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			netConn.Close()
+			return nil
+		}
+		s.conns[c] = true
+		s.mu.Unlock()
+
+		go c.serve()
+	}
+}
+
+// Shutdown stops accepting the connections and closes the open ones.
+func (s *Server) Shutdown() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+
+	if s.listener != nil {
+		s.listener.Close()
+	}
+
+	for c := range s.conns {
+		c.netConn.Close()
+	}
+}
+
+func (s *Server) forget(c *conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.conns, c)
+}
+
+// response is the tagged NO or BAD of a command.
+type response struct {
+	status string
+	text   string
+}
+
+func (r *response) Error() string {
+	return r.status + " " + r.text
+}
+
+func no(text string) error {
+	return &response{"NO", text}
+}
+
+func bad(text string) error {
+	return &response{"BAD", text}
+}
+
+var badArguments = bad("invalid arguments")
+
+type conn struct {
+	server   *Server
+	netConn  net.Conn
+	parser   *parser
+	w        *bufio.Writer
+	ip       string
+	user     *repository.User
+	selected *selected
+}
+
+func (c *conn) writeString(s string) {
+	c.w.WriteString(s)
+}
+
+func (c *conn) flush() error {
+	c.netConn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	return c.w.Flush()
+}
+
+func (c *conn) continuation() error {
+	c.writeString("+ Ready for literal data\r\n")
+	return c.flush()
+}
+
+func (c *conn) serve() {
+	defer func() {
+		c.netConn.Close()
+		c.server.forget(c)
+	}()
+
+	c.writeString("* OK [CAPABILITY " + capabilities + "] " + config.Configuration.DomainName + " IMAP4rev1 ready\r\n")
+	if c.flush() != nil {
+		return
+	}
+
+	for {
+		c.netConn.SetReadDeadline(time.Now().Add(autologout))
+
+		args, err := c.parser.readLine()
+		if err != nil {
+			var syntaxErr syntaxError
+			if !errors.As(err, &syntaxErr) {
+				// the connection is closed or idle
+				return
+			}
+
+			if errors.Is(err, errLineTooLong) || errors.Is(err, errLiteralTooLong) {
+				c.writeString("* BYE " + err.Error() + "\r\n")
+				c.flush()
+				return
+			}
+
+			if c.parser.skipLine() != nil {
+				return
+			}
+
+			tag := "*"
+			if len(args) > 0 {
+				if a, ok := args[0].(atom); ok {
+					tag = string(a)
+				}
+			}
+
+			c.writeString(tag + " BAD " + err.Error() + "\r\n")
+			if c.flush() != nil {
+				return
+			}
+
+			continue
+		}
+
+		if len(args) == 0 {
+			continue
+		}
+
+		tag, ok := args[0].(atom)
+		if !ok || len(args) < 2 {
+			c.writeString("* BAD missing tag or command\r\n")
+			if c.flush() != nil {
+				return
+			}
+			continue
+		}
+
+		command, ok := args[1].(atom)
+		if !ok {
+			c.writeString(string(tag) + " BAD missing command\r\n")
+			if c.flush() != nil {
+				return
+			}
+			continue
+		}
+
+		logout := c.respond(string(tag), strings.ToUpper(string(command)), args[2:])
+
+		if c.flush() != nil || logout {
+			return
+		}
+	}
+}
+
+// respond writes the responses of the command, it tells whether the client logged out.
+func (c *conn) respond(tag, command string, args list) bool {
+	text, err := c.handle(command, args)
+	if err != nil {
+		var r *response
+		if !errors.As(err, &r) {
+			log.Printf("imap %s error: %v", command, err)
+			r = &response{"NO", "[SERVERBUG] " + repository.ErrInternalServer.Error()}
+		}
+
+		c.writeString(tag + " " + r.status + " " + r.text + "\r\n")
+
+		return false
+	}
+
+	if len(text) == 0 {
+		text = command + " completed"
+	}
+
+	if command == "LOGOUT" {
+		c.writeString("* BYE logging out\r\n")
+	}
+
+	c.writeString(tag + " OK " + text + "\r\n")
+
+	return command == "LOGOUT"
+}
+
+func (c *conn) handle(command string, args list) (string, error) {
+	switch command {
+	case "CAPABILITY":
+		c.writeString("* CAPABILITY " + capabilities + "\r\n")
+		return "", nil
+	case "NOOP", "CHECK":
+		if c.selected != nil {
+			return "", c.refresh()
+		}
+		return "", nil
+	case "LOGOUT":
+		return "", nil
+	case "STARTTLS":
+		return "", bad("already in TLS")
+	case "AUTHENTICATE":
+		return "", no("[CANNOT] use the LOGIN")
+	case "LOGIN":
+		if c.user != nil {
+			return "", bad("already logged in")
+		}
+		return c.login(args)
+	}
+
+	if c.user == nil {
+		return "", bad("log in first")
+	}
+
+	switch command {
+	case "SELECT", "EXAMINE":
+		return c.selectMailbox(args, command == "EXAMINE")
+	case "LIST", "LSUB":
+		return "", c.list(args, command)
+	case "STATUS":
+		return "", c.status(args)
+	case "SUBSCRIBE", "UNSUBSCRIBE":
+		// all the mailboxes are subscribed
+		return "", nil
+	case "CREATE", "DELETE", "RENAME", "APPEND":
+		return "", no("[CANNOT] the mailboxes are read-only")
+	}
+
+	if c.selected == nil {
+		return "", bad("select a mailbox first")
+	}
+
+	switch command {
+	case "CLOSE", "UNSELECT":
+		c.selected = nil
+		return "", nil
+	case "EXPUNGE":
+		// no message can be flagged as \Deleted
+		return "", nil
+	case "FETCH":
+		return "", c.fetch(args, false)
+	case "STORE":
+		return "", c.store(args, false)
+	case "SEARCH":
+		return "", c.search(args, false)
+	case "COPY", "MOVE":
+		return "", no("[CANNOT] the mailboxes are read-only")
+	case "UID":
+		if len(args) == 0 {
+			return "", badArguments
+		}
+
+		subcommand, _ := argString(args[0])
+
+		switch strings.ToUpper(subcommand) {
+		case "FETCH":
+			return "UID FETCH completed", c.fetch(args[1:], true)
+		case "STORE":
+			return "UID STORE completed", c.store(args[1:], true)
+		case "SEARCH":
+			return "UID SEARCH completed", c.search(args[1:], true)
+		case "COPY", "MOVE":
+			return "", no("[CANNOT] the mailboxes are read-only")
+		case "EXPUNGE":
+			return "UID EXPUNGE completed", nil
+		}
+	}
+
+	return "", bad("unknown command")
+}
+
+// login authenticates the user like the webmail does, the failures lock the username out of the client IP.
+func (c *conn) login(args list) (string, error) {
+	if len(args) != 2 {
+		return "", badArguments
+	}
+
+	username, ok1 := argString(args[0])
+	password, ok2 := argString(args[1])
+	if !ok1 || !ok2 {
+		return "", badArguments
+	}
+
+	// the username may be the email address
+	if name, domain, ok := strings.Cut(username, "@"); ok && strings.EqualFold(domain, config.Configuration.DomainName) {
+		username = name
+	}
+
+	repo := c.server.params.Repository
+	maxFailures := config.LoginMaxFailures()
+
+	if maxFailures > 0 {
+		wait, err := repo.LoginAttempts.Locked(username, c.ip)
+		if err != nil {
+			return "", err
+		}
+
+		if wait > 0 {
+			return "", no("[UNAVAILABLE] " + repository.ErrAccountLocked.Error())
+		}
+	}
+
+	user, err := repo.User.GetByUsername(username)
+	if err != nil && !errors.Is(err, repository.ErrUsernameNotFound) {
+		return "", err
+	}
+
+	match := false
+
+	if user != nil {
+		match, err = user.Password.Matches(password)
+		if err != nil {
+			return "", err
+		}
+	} else {
+		match = repository.MismatchPassword(password)
+	}
+
+	if !match {
+		if maxFailures > 0 {
+			wait, err := repo.LoginAttempts.Fail(username, c.ip, maxFailures, config.LoginMaxUserFailures(), config.LoginLockout())
+			if err != nil {
+				return "", err
+			}
+
+			if wait > 0 {
+				return "", no("[UNAVAILABLE] " + repository.ErrAccountLocked.Error())
+			}
+		}
+
+		return "", no("[AUTHENTICATIONFAILED] " + repository.ErrInvalidCredentials.Error())
+	}
+
+	if maxFailures > 0 {
+		err = repo.LoginAttempts.Reset(username, c.ip)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	c.user = user
+
+	return "[CAPABILITY " + capabilities + "] LOGIN completed", nil
+}
+
+func mailboxArg(arg interface{}) (string, error) {
+	name, ok := argString(arg)
+	if !ok {
+		return "", badArguments
+	}
+
+	name, err := decodeMailbox(name)
+	if err != nil {
+		return "", bad(err.Error())
+	}
+
+	return name, nil
+}
+
+func (c *conn) selectMailbox(args list, readOnly bool) (string, error) {
+	// the failed SELECT deselects the mailbox too
+	c.selected = nil
+
+	if len(args) != 1 {
+		return "", badArguments
+	}
+
+	name, err := mailboxArg(args[0])
+	if err != nil {
+		return "", err
+	}
+
+	mailbox, err := resolveMailbox(name)
+	if err != nil {
+		return "", no("[NONEXISTENT] " + err.Error())
+	}
+
+	messages, err := c.load(mailbox)
+	if err != nil {
+		return "", err
+	}
+
+	if mailbox.folder == -1 && len(messages) == 0 {
+		return "", no("[NONEXISTENT] " + errNoSuchMailbox.Error())
+	}
+
+	s := &selected{mailbox: mailbox, readOnly: readOnly, messages: messages}
+
+	c.writeString(`* FLAGS (\Seen \Flagged)` + "\r\n")
+	if readOnly {
+		c.writeString(`* OK [PERMANENTFLAGS ()] read-only` + "\r\n")
+	} else {
+		c.writeString(`* OK [PERMANENTFLAGS (\Seen \Flagged)] the other flags aren't kept` + "\r\n")
+	}
+	c.writeString("* " + strconv.Itoa(len(messages)) + " EXISTS\r\n")
+	c.writeString("* 0 RECENT\r\n")
+
+	if count, first := s.unseen(); count > 0 {
+		c.writeString("* OK [UNSEEN " + strconv.Itoa(first) + "] first unseen\r\n")
+	}
+
+	c.writeString("* OK [UIDVALIDITY " + strconv.Itoa(uidValidity) + "] UIDs valid\r\n")
+	c.writeString("* OK [UIDNEXT " + strconv.FormatUint(uint64(s.uidNext()), 10) + "] predicted next UID\r\n")
+
+	c.selected = s
+
+	command := "SELECT"
+	access := "[READ-WRITE]"
+
+	if readOnly {
+		command = "EXAMINE"
+		access = "[READ-ONLY]"
+	}
+
+	return access + " " + command + " completed", nil
+}
+
+func (c *conn) list(args list, command string) error {
+	if len(args) != 2 {
+		return badArguments
+	}
+
+	reference, err := mailboxArg(args[0])
+	if err != nil {
+		return err
+	}
+
+	pattern, err := mailboxArg(args[1])
+	if err != nil {
+		return err
+	}
+
+	if len(pattern) == 0 {
+		c.writeString("* " + command + ` (\Noselect) "` + delimiter + `" ""` + "\r\n")
+		return nil
+	}
+
+	pattern = listPattern(reference, pattern)
+
+	mailboxes, err := c.mailboxes()
+	if err != nil {
+		return err
+	}
+
+	for _, mailbox := range mailboxes {
+		match := matchMailbox(pattern, mailbox.name)
+
+		// the INBOX is case-insensitive
+		if !match && mailbox.name == "INBOX" {
+			match = matchMailbox(strings.ToUpper(pattern), mailbox.name)
+		}
+
+		if match {
+			c.writeString("* " + command + " (" + mailbox.attributes + `) "` + delimiter + `" ` + quote(encodeMailbox(mailbox.name)) + "\r\n")
+		}
+	}
+
+	return nil
+}
+
+func (c *conn) status(args list) error {
+	if len(args) != 2 {
+		return badArguments
+	}
+
+	name, err := mailboxArg(args[0])
+	if err != nil {
+		return err
+	}
+
+	items, ok := args[1].(list)
+	if !ok {
+		return badArguments
+	}
+
+	mailbox, err := resolveMailbox(name)
+	if err != nil {
+		return no("[NONEXISTENT] " + err.Error())
+	}
+
+	messages, err := c.load(mailbox)
+	if err != nil {
+		return err
+	}
+
+	if mailbox.folder == -1 && len(messages) == 0 {
+		return no("[NONEXISTENT] " + errNoSuchMailbox.Error())
+	}
+
+	s := &selected{mailbox: mailbox, messages: messages}
+
+	var values []string
+
+	for _, item := range items {
+		name, _ := argString(item)
+
+		switch upper := strings.ToUpper(name); upper {
+		case "MESSAGES":
+			values = append(values, upper+" "+strconv.Itoa(len(messages)))
+		case "RECENT":
+			values = append(values, upper+" 0")
+		case "UIDNEXT":
+			values = append(values, upper+" "+strconv.FormatUint(uint64(s.uidNext()), 10))
+		case "UIDVALIDITY":
+			values = append(values, upper+" "+strconv.Itoa(uidValidity))
+		case "UNSEEN":
+			count, _ := s.unseen()
+			values = append(values, upper+" "+strconv.Itoa(count))
+		default:
+			return bad("invalid status item")
+		}
+	}
+
+	c.writeString("* STATUS " + quote(encodeMailbox(mailbox.name)) + " (" + strings.Join(values, " ") + ")\r\n")
+
+	return nil
+}
+
+// refresh reloads the selected mailbox, and tells the client the messages gone, the flags changed and the
+// messages added since. The added messages are appended in the order of their UIDs, so a message labeled
+// after a greater UID was seen shows on the next SELECT.
+func (c *conn) refresh() error {
+	messages, err := c.load(c.selected.mailbox)
+	if err != nil {
+		return err
+	}
+
+	current := make(map[uint32]*message, len(messages))
+	for _, m := range messages {
+		current[m.uid] = m
+	}
+
+	old := c.selected.messages
+
+	// the sequence numbers of the later messages shift as the earlier are expunged
+	for i := len(old) - 1; i >= 0; i-- {
+		if _, ok := current[old[i].uid]; !ok {
+			c.writeString("* " + strconv.Itoa(i+1) + " EXPUNGE\r\n")
+			old = append(old[:i], old[i+1:]...)
+		}
+	}
+
+	var last uint32
+
+	for i, m := range old {
+		update := current[m.uid]
+
+		if update.message.Unread != m.message.Unread || update.message.Starred != m.message.Starred {
+			c.writeString("* " + strconv.Itoa(i+1) + " FETCH (FLAGS " + update.flags() + ")\r\n")
+		}
+
+		old[i] = update
+		last = m.uid
+	}
+
+	exists := len(old)
+
+	for _, m := range messages {
+		if m.uid > last {
+			old = append(old, m)
+		}
+	}
+
+	if len(old) > exists {
+		c.writeString("* " + strconv.Itoa(len(old)) + " EXISTS\r\n")
+	}
+
+	c.selected.messages = old
+
+	return nil
+}
@@ -0,0 +1,423 @@
+package imap
+
+import (
+	"cargomail/internal/mailbox/repository"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const (
+	delimiter   = "/"
+	labelsRoot  = "Labels"
+	uidValidity = 1 // the UIDs are the rowids of the messages, they never change
+)
+
+var errNoSuchMailbox = errors.New("no such mailbox")
+
+// folderMailboxes are the mailboxes of the folders of the messages, the drafts aren't messages and the
+// in progress ones aren't delivered yet.
+var folderMailboxes = []struct {
+	name       string
+	attributes string
+	folder     int
+}{
+	{"INBOX", `\HasNoChildren`, 2},
+	{"Sent", `\Sent \HasNoChildren`, 1},
+}
+
+// mailboxInfo is a mailbox of the LIST, the labels are the mailboxes under the Labels.
+type mailboxInfo struct {
+	name       string
+	attributes string
+}
+
+// mailboxRef tells the messages of a mailbox, of the folder or, with the folder -1, of the label.
+type mailboxRef struct {
+	name   string
+	folder int
+	label  string
+}
+
+// message is a message of the selected mailbox, the placeholder payload is rendered on fetch.
+type message struct {
+	uid          uint32
+	message      *repository.Message
+	internalDate time.Time
+}
+
+func (m *message) flags() string {
+	flags := []string{}
+
+	if !m.message.Unread {
+		flags = append(flags, `\Seen`)
+	}
+	if m.message.Starred {
+		flags = append(flags, `\Flagged`)
+	}
+
+	return "(" + strings.Join(flags, " ") + ")"
+}
+
+// selected is the snapshot of the selected mailbox, the messages are in the order of their UIDs, the sequence
+// number of a message is its index + 1.
+type selected struct {
+	mailbox  mailboxRef
+	readOnly bool
+	messages []*message
+}
+
+func (s *selected) uidNext() uint32 {
+	if len(s.messages) == 0 {
+		return 1
+	}
+
+	return s.messages[len(s.messages)-1].uid + 1
+}
+
+func (s *selected) unseen() (count int, first int) {
+	for i, m := range s.messages {
+		if m.message.Unread {
+			if count == 0 {
+				first = i + 1
+			}
+			count++
+		}
+	}
+
+	return count, first
+}
+
+// labels returns the labels of the messages.
+func (c *conn) labels() ([]string, error) {
+	seen := map[string]bool{}
+
+	_, err := c.server.params.Repository.Messages.ListEach(c.user, -1, nil, func(message *repository.Message) error {
+		for _, label := range messageLabels(message) {
+			seen[label] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	labels := make([]string, 0, len(seen))
+	for label := range seen {
+		labels = append(labels, label)
+	}
+
+	sort.Strings(labels)
+
+	return labels, nil
+}
+
+func messageLabels(message *repository.Message) []string {
+	var labels []string
+
+	if message.LabelIds != nil {
+		json.Unmarshal([]byte(*message.LabelIds), &labels)
+	}
+
+	return labels
+}
+
+// mailboxes returns the mailboxes of the LIST, the names are decoded.
+func (c *conn) mailboxes() ([]mailboxInfo, error) {
+	mailboxes := []mailboxInfo{}
+
+	for _, mailbox := range folderMailboxes {
+		mailboxes = append(mailboxes, mailboxInfo{mailbox.name, mailbox.attributes})
+	}
+
+	labels, err := c.labels()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(labels) > 0 {
+		mailboxes = append(mailboxes, mailboxInfo{labelsRoot, `\Noselect \HasChildren`})
+	}
+
+	for _, label := range labels {
+		mailboxes = append(mailboxes, mailboxInfo{labelsRoot + delimiter + label, `\HasNoChildren`})
+	}
+
+	return mailboxes, nil
+}
+
+// resolveMailbox returns the mailbox of the decoded name, the INBOX is case-insensitive.
+func resolveMailbox(name string) (mailboxRef, error) {
+	for _, mailbox := range folderMailboxes {
+		if mailbox.name == name || (mailbox.name == "INBOX" && strings.EqualFold(name, "INBOX")) {
+			return mailboxRef{name: mailbox.name, folder: mailbox.folder}, nil
+		}
+	}
+
+	if label, ok := strings.CutPrefix(name, labelsRoot+delimiter); ok && len(label) > 0 {
+		return mailboxRef{name: name, folder: -1, label: label}, nil
+	}
+
+	return mailboxRef{}, errNoSuchMailbox
+}
+
+// load returns the snapshot of the messages of the mailbox.
+func (c *conn) load(mailbox mailboxRef) ([]*message, error) {
+	uids, err := c.server.params.Repository.Messages.RowIds(c.user)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := []*message{}
+
+	options := &repository.ListOptions{LabelId: mailbox.label}
+
+	_, err = c.server.params.Repository.Messages.ListEach(c.user, mailbox.folder, options, func(m *repository.Message) error {
+		uid, ok := uids[m.Id]
+		if !ok {
+			// inserted after the rowids were read
+			return nil
+		}
+
+		internalDate := m.CreatedAt.Time()
+		if m.ReceivedAt != nil {
+			internalDate = m.ReceivedAt.Time()
+		}
+
+		messages = append(messages, &message{uid: uint32(uid), message: m, internalDate: internalDate})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].uid < messages[j].uid
+	})
+
+	return messages, nil
+}
+
+// matchMailbox tells whether the name matches the LIST pattern, the * matches any characters, the % any but
+// the delimiter.
+func matchMailbox(pattern, name string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+
+	switch pattern[0] {
+	case '*':
+		for i := 0; i <= len(name); i++ {
+			if matchMailbox(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	case '%':
+		for i := 0; i <= len(name); i++ {
+			if matchMailbox(pattern[1:], name[i:]) {
+				return true
+			}
+			if i < len(name) && name[i] == delimiter[0] {
+				return false
+			}
+		}
+		return false
+	}
+
+	if len(name) == 0 || pattern[0] != name[0] {
+		return false
+	}
+
+	return matchMailbox(pattern[1:], name[1:])
+}
+
+// listPattern joins the reference and the pattern of the LIST.
+func listPattern(reference, pattern string) string {
+	if len(reference) == 0 || strings.HasPrefix(pattern, delimiter) {
+		return pattern
+	}
+
+	return path.Join(reference, pattern)
+}
+
+// seqRange is a range of the sequence numbers or the UIDs, the zero is the * i.e. the greatest one in use.
+type seqRange struct {
+	from, to uint32
+}
+
+type seqSet []seqRange
+
+func parseSeqSet(s string) (seqSet, error) {
+	set := seqSet{}
+
+	for _, r := range strings.Split(s, ",") {
+		from, to, isRange := strings.Cut(r, ":")
+
+		start, err := parseSeqNumber(from)
+		if err != nil {
+			return nil, err
+		}
+
+		end := start
+		if isRange {
+			end, err = parseSeqNumber(to)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		set = append(set, seqRange{start, end})
+	}
+
+	return set, nil
+}
+
+func parseSeqNumber(s string) (uint32, error) {
+	if s == "*" {
+		return 0, nil
+	}
+
+	n, err := strconv.ParseUint(s, 10, 32)
+	if err != nil || n == 0 {
+		return 0, errors.New("invalid sequence set")
+	}
+
+	return uint32(n), nil
+}
+
+// contains tells whether the set contains the n, the max is the number the * stands for.
+func (set seqSet) contains(n, max uint32) bool {
+	for _, r := range set {
+		from, to := r.from, r.to
+		if from == 0 {
+			from = max
+		}
+		if to == 0 {
+			to = max
+		}
+		if from > to {
+			from, to = to, from
+		}
+		if n >= from && n <= to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// messagesOf returns the sequence numbers and the messages of the set, of the UIDs or of the sequence numbers.
+func (s *selected) messagesOf(set seqSet, uid bool) ([]int, []*message) {
+	var seqs []int
+	var messages []*message
+
+	for i, m := range s.messages {
+		ok := false
+
+		if uid {
+			ok = set.contains(m.uid, s.messages[len(s.messages)-1].uid)
+		} else {
+			ok = set.contains(uint32(i+1), uint32(len(s.messages)))
+		}
+
+		if ok {
+			seqs = append(seqs, i+1)
+			messages = append(messages, m)
+		}
+	}
+
+	return seqs, messages
+}
+
+// encodeMailbox encodes the name in the modified UTF-7 of the mailbox names (RFC 3501, 5.1.3).
+func encodeMailbox(name string) string {
+	var sb strings.Builder
+
+	var shifted []rune
+
+	flush := func() {
+		if len(shifted) == 0 {
+			return
+		}
+
+		units := utf16.Encode(shifted)
+		buf := make([]byte, 0, 2*len(units))
+		for _, u := range units {
+			buf = append(buf, byte(u>>8), byte(u))
+		}
+
+		sb.WriteString("&" + strings.ReplaceAll(base64.RawStdEncoding.EncodeToString(buf), "/", ",") + "-")
+		shifted = nil
+	}
+
+	for _, r := range name {
+		if r >= 0x20 && r <= 0x7e {
+			flush()
+
+			if r == '&' {
+				sb.WriteString("&-")
+			} else {
+				sb.WriteRune(r)
+			}
+		} else {
+			shifted = append(shifted, r)
+		}
+	}
+
+	flush()
+
+	return sb.String()
+}
+
+// decodeMailbox decodes the name from the modified UTF-7.
+func decodeMailbox(name string) (string, error) {
+	var sb strings.Builder
+
+	for len(name) > 0 {
+		i := strings.IndexByte(name, '&')
+		if i < 0 {
+			sb.WriteString(name)
+			break
+		}
+
+		sb.WriteString(name[:i])
+		name = name[i+1:]
+
+		j := strings.IndexByte(name, '-')
+		if j < 0 {
+			return "", errors.New("invalid mailbox name")
+		}
+
+		if j == 0 {
+			sb.WriteByte('&')
+		} else {
+			buf, err := base64.RawStdEncoding.DecodeString(strings.ReplaceAll(name[:j], ",", "/"))
+			if err != nil || len(buf)%2 != 0 {
+				return "", errors.New("invalid mailbox name")
+			}
+
+			units := make([]uint16, len(buf)/2)
+			for k := range units {
+				units[k] = uint16(buf[2*k])<<8 | uint16(buf[2*k+1])
+			}
+
+			sb.WriteString(string(utf16.Decode(units)))
+		}
+
+		name = name[j+1:]
+	}
+
+	if !utf8.ValidString(sb.String()) {
+		return "", errors.New("invalid mailbox name")
+	}
+
+	return sb.String(), nil
+}
@@ -0,0 +1,281 @@
+package imap
+
+import (
+	"bufio"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxLineLength    = 64 * 1024
+	maxLiteralLength = 64 * 1024
+)
+
+// syntaxError is a bad command line, unlike the errors of the connection the client is told about it.
+type syntaxError string
+
+func (e syntaxError) Error() string {
+	return string(e)
+}
+
+var (
+	errLineTooLong    = syntaxError("command line too long")
+	errLiteralTooLong = syntaxError("literal too long")
+	errUnbalanced     = syntaxError("unbalanced parentheses")
+	errInvalidLiteral = syntaxError("invalid literal")
+)
+
+// atom is an unquoted argument, e.g. a command, a flag or a fetch item, unlike a quoted string or a literal
+// it may be the NIL.
+type atom string
+
+// list is a parenthesized list of the arguments.
+type list []interface{}
+
+// parser reads the arguments of a command line, the continuation is asked for before a synchronizing literal
+// is read.
+type parser struct {
+	r            *bufio.Reader
+	continuation func() error
+	read         int
+	last         byte // the last byte read, the rest of a bad line is skipped unless it is the LF
+}
+
+func (p *parser) readByte() (byte, error) {
+	p.read++
+	if p.read > maxLineLength {
+		return 0, errLineTooLong
+	}
+
+	b, err := p.r.ReadByte()
+	p.last = b
+
+	return b, err
+}
+
+func (p *parser) unreadByte() {
+	p.r.UnreadByte()
+	p.read--
+	p.last = 0
+}
+
+// skipLine skips the rest of the line a bad command was read from.
+func (p *parser) skipLine() error {
+	if p.last == '\n' {
+		return nil
+	}
+
+	_, err := p.r.ReadString('\n')
+
+	return err
+}
+
+// readLine reads the arguments up to the end of the line.
+func (p *parser) readLine() (list, error) {
+	p.read = 0
+	p.last = 0
+
+	args, end, err := p.readList()
+	if err == nil && end != '\n' {
+		err = errUnbalanced
+	}
+
+	return args, err
+}
+
+// readList reads the arguments up to the closing parenthesis or the end of the line, it returns which one.
+func (p *parser) readList() (list, byte, error) {
+	args := list{}
+
+	for {
+		b, err := p.readByte()
+		if err != nil {
+			return args, 0, err
+		}
+
+		switch b {
+		case ' ', '\r':
+		case '\n', ')':
+			return args, b, nil
+		case '(':
+			nested, end, err := p.readList()
+			if err != nil {
+				return args, 0, err
+			}
+
+			if end != ')' {
+				return args, 0, errUnbalanced
+			}
+
+			args = append(args, nested)
+		case '"':
+			s, err := p.readQuoted()
+			if err != nil {
+				return args, 0, err
+			}
+
+			args = append(args, s)
+		case '{':
+			s, err := p.readLiteral()
+			if err != nil {
+				return args, 0, err
+			}
+
+			args = append(args, s)
+		default:
+			p.unreadByte()
+
+			a, err := p.readAtom()
+			if err != nil {
+				return args, 0, err
+			}
+
+			args = append(args, a)
+		}
+	}
+}
+
+func (p *parser) readQuoted() (string, error) {
+	var sb strings.Builder
+
+	for {
+		b, err := p.readByte()
+		if err != nil {
+			return "", err
+		}
+
+		switch b {
+		case '"':
+			return sb.String(), nil
+		case '\\':
+			b, err = p.readByte()
+			if err != nil {
+				return "", err
+			}
+		case '\r', '\n':
+			return "", syntaxError("unterminated quoted string")
+		}
+
+		sb.WriteByte(b)
+	}
+}
+
+// readLiteral reads the {n} or the non-synchronizing {n+} literal (RFC 7888) of the n bytes.
+func (p *parser) readLiteral() (string, error) {
+	var spec strings.Builder
+
+	for {
+		b, err := p.readByte()
+		if err != nil {
+			return "", err
+		}
+
+		if b == '}' {
+			break
+		}
+
+		spec.WriteByte(b)
+	}
+
+	size, nonSync := strings.CutSuffix(spec.String(), "+")
+
+	n, err := strconv.Atoi(size)
+	if err != nil || n < 0 {
+		return "", errInvalidLiteral
+	}
+
+	if n > maxLiteralLength {
+		return "", errLiteralTooLong
+	}
+
+	b, err := p.readByte()
+	if err == nil && b == '\r' {
+		b, err = p.readByte()
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	if b != '\n' {
+		return "", errInvalidLiteral
+	}
+
+	if !nonSync {
+		err = p.continuation()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	buf := make([]byte, n)
+
+	for i := range buf {
+		buf[i], err = p.r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return string(buf), nil
+}
+
+// readAtom reads an atom, the bracketed section of a fetch item, e.g. BODY[HEADER.FIELDS (FROM TO)], is a part
+// of it.
+func (p *parser) readAtom() (atom, error) {
+	var sb strings.Builder
+
+	brackets := 0
+
+	for {
+		b, err := p.readByte()
+		if err != nil {
+			return "", err
+		}
+
+		switch {
+		case b == '[':
+			brackets++
+		case b == ']' && brackets > 0:
+			brackets--
+		case brackets > 0 && b != '\r' && b != '\n':
+		case b == ' ' || b == '(' || b == ')' || b == '\r' || b == '\n':
+			p.unreadByte()
+			return atom(sb.String()), nil
+		}
+
+		sb.WriteByte(b)
+	}
+}
+
+// argString returns the atom, the quoted string or the literal argument as a string.
+func argString(arg interface{}) (string, bool) {
+	switch arg := arg.(type) {
+	case atom:
+		return string(arg), true
+	case string:
+		return arg, true
+	}
+
+	return "", false
+}
+
+// quote formats the string as a quoted string, or as a literal if it isn't a 7-bit text of a line.
+func quote(s string) string {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\r' || s[i] == '\n' || s[i] >= 0x80 || s[i] == 0 {
+			return "{" + strconv.Itoa(len(s)) + "}\r\n" + s
+		}
+	}
+
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// nstring formats the string as the NIL if it is empty.
+func nstring(s string) string {
+	if len(s) == 0 {
+		return "NIL"
+	}
+
+	return quote(s)
+}
@@ -0,0 +1,366 @@
+package imap
+
+import (
+	"cargomail/internal/mailbox/repository"
+	"errors"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errInvalidSearch = errors.New("invalid search criteria")
+
+// criterion tells whether the message of the sequence number matches.
+type criterion func(seq int, m *message) (bool, error)
+
+// search serves the SEARCH (RFC 3501, 6.4.4). The headers are matched as the substrings of the payload
+// headers, the BODY and the TEXT by the full-text search of the messages, i.e. by the words.
+func (c *conn) search(args list, uid bool) error {
+	if len(args) >= 2 {
+		if key, _ := args[0].(atom); strings.EqualFold(string(key), "CHARSET") {
+			charset, _ := argString(args[1])
+			if !strings.EqualFold(charset, "UTF-8") && !strings.EqualFold(charset, "US-ASCII") {
+				return no("[BADCHARSET (UTF-8 US-ASCII)] unsupported charset")
+			}
+
+			args = args[2:]
+		}
+	}
+
+	if len(args) == 0 {
+		return badArguments
+	}
+
+	s := &searcher{conn: c, texts: map[string]map[string]bool{}, sizes: map[uint32]int{}}
+
+	match, err := s.parseAll(&args)
+	if err != nil {
+		return bad(err.Error())
+	}
+
+	var results []string
+
+	for i, m := range c.selected.messages {
+		ok, err := match(i+1, m)
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			if uid {
+				results = append(results, strconv.FormatUint(uint64(m.uid), 10))
+			} else {
+				results = append(results, strconv.Itoa(i+1))
+			}
+		}
+	}
+
+	c.writeString(strings.TrimSpace("* SEARCH "+strings.Join(results, " ")) + "\r\n")
+
+	return nil
+}
+
+// searcher parses the search keys, the full-text matches and the sizes of the messages are read once.
+type searcher struct {
+	conn  *conn
+	texts map[string]map[string]bool // the ids of the messages of the text
+	sizes map[uint32]int
+}
+
+// parseAll parses the keys up to the end of the args, they all have to match.
+func (s *searcher) parseAll(args *list) (criterion, error) {
+	var criteria []criterion
+
+	for len(*args) > 0 {
+		c, err := s.parse(args)
+		if err != nil {
+			return nil, err
+		}
+
+		criteria = append(criteria, c)
+	}
+
+	return func(seq int, m *message) (bool, error) {
+		for _, c := range criteria {
+			ok, err := c(seq, m)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+
+		return true, nil
+	}, nil
+}
+
+func (s *searcher) next(args *list) (string, error) {
+	if len(*args) == 0 {
+		return "", errInvalidSearch
+	}
+
+	arg, ok := argString((*args)[0])
+	if !ok {
+		return "", errInvalidSearch
+	}
+
+	*args = (*args)[1:]
+
+	return arg, nil
+}
+
+func (s *searcher) nextDate(args *list) (time.Time, error) {
+	arg, err := s.next(args)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	date, err := time.Parse("2-Jan-2006", arg)
+	if err != nil {
+		return time.Time{}, errInvalidSearch
+	}
+
+	return date, nil
+}
+
+func constant(b bool) criterion {
+	return func(int, *message) (bool, error) {
+		return b, nil
+	}
+}
+
+// parse parses the next key of the args.
+func (s *searcher) parse(args *list) (criterion, error) {
+	if len(*args) == 0 {
+		return nil, errInvalidSearch
+	}
+
+	if nested, ok := (*args)[0].(list); ok {
+		*args = (*args)[1:]
+		return s.parseAll(&nested)
+	}
+
+	key, err := s.next(args)
+	if err != nil {
+		return nil, err
+	}
+
+	switch upper := strings.ToUpper(key); upper {
+	case "ALL", "OLD", "UNANSWERED", "UNDELETED", "UNDRAFT", "UNKEYWORD":
+		if upper == "UNKEYWORD" {
+			if _, err := s.next(args); err != nil {
+				return nil, err
+			}
+		}
+		return constant(true), nil
+	case "ANSWERED", "DELETED", "DRAFT", "NEW", "RECENT", "KEYWORD":
+		if upper == "KEYWORD" {
+			if _, err := s.next(args); err != nil {
+				return nil, err
+			}
+		}
+		return constant(false), nil
+	case "SEEN", "UNSEEN":
+		return func(_ int, m *message) (bool, error) {
+			return m.message.Unread == (upper == "UNSEEN"), nil
+		}, nil
+	case "FLAGGED", "UNFLAGGED":
+		return func(_ int, m *message) (bool, error) {
+			return m.message.Starred == (upper == "FLAGGED"), nil
+		}, nil
+	case "FROM", "TO", "CC", "BCC", "SUBJECT":
+		value, err := s.next(args)
+		if err != nil {
+			return nil, err
+		}
+		return headerContains(textproto.CanonicalMIMEHeaderKey(upper), value), nil
+	case "HEADER":
+		name, err := s.next(args)
+		if err != nil {
+			return nil, err
+		}
+		value, err := s.next(args)
+		if err != nil {
+			return nil, err
+		}
+		return headerContains(name, value), nil
+	case "BODY", "TEXT":
+		text, err := s.next(args)
+		if err != nil {
+			return nil, err
+		}
+		return s.textMatches(text), nil
+	case "BEFORE", "ON", "SINCE":
+		date, err := s.nextDate(args)
+		if err != nil {
+			return nil, err
+		}
+		return func(_ int, m *message) (bool, error) {
+			return compareDate(m.internalDate, date, upper), nil
+		}, nil
+	case "SENTBEFORE", "SENTON", "SENTSINCE":
+		date, err := s.nextDate(args)
+		if err != nil {
+			return nil, err
+		}
+		return func(_ int, m *message) (bool, error) {
+			sent, ok := sentDate(m)
+			return ok && compareDate(sent, date, strings.TrimPrefix(upper, "SENT")), nil
+		}, nil
+	case "LARGER", "SMALLER":
+		arg, err := s.next(args)
+		if err != nil {
+			return nil, err
+		}
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			return nil, errInvalidSearch
+		}
+		return func(_ int, m *message) (bool, error) {
+			size, err := s.size(m)
+			if upper == "LARGER" {
+				return size > n, err
+			}
+			return size < n, err
+		}, nil
+	case "NOT":
+		c, err := s.parse(args)
+		if err != nil {
+			return nil, err
+		}
+		return func(seq int, m *message) (bool, error) {
+			ok, err := c(seq, m)
+			return !ok, err
+		}, nil
+	case "OR":
+		c1, err := s.parse(args)
+		if err != nil {
+			return nil, err
+		}
+		c2, err := s.parse(args)
+		if err != nil {
+			return nil, err
+		}
+		return func(seq int, m *message) (bool, error) {
+			ok, err := c1(seq, m)
+			if err != nil || ok {
+				return ok, err
+			}
+			return c2(seq, m)
+		}, nil
+	case "UID":
+		arg, err := s.next(args)
+		if err != nil {
+			return nil, err
+		}
+		set, err := parseSeqSet(arg)
+		if err != nil {
+			return nil, err
+		}
+		return func(_ int, m *message) (bool, error) {
+			return set.contains(m.uid, s.conn.selected.uidNext()-1), nil
+		}, nil
+	default:
+		set, err := parseSeqSet(key)
+		if err != nil {
+			return nil, errInvalidSearch
+		}
+		return func(seq int, _ *message) (bool, error) {
+			return set.contains(uint32(seq), uint32(len(s.conn.selected.messages))), nil
+		}, nil
+	}
+}
+
+func headerContains(name, value string) criterion {
+	value = strings.ToLower(value)
+
+	return func(_ int, m *message) (bool, error) {
+		if m.message.Payload == nil {
+			return false, nil
+		}
+
+		for k, v := range m.message.Payload.Headers {
+			if !strings.EqualFold(k, name) {
+				continue
+			}
+
+			if s, ok := v.(string); ok && strings.Contains(strings.ToLower(s), value) {
+				return true, nil
+			}
+		}
+
+		return false, nil
+	}
+}
+
+// textMatches matches the messages the full-text search finds for the text.
+func (s *searcher) textMatches(text string) criterion {
+	return func(_ int, m *message) (bool, error) {
+		ids, ok := s.texts[text]
+		if !ok {
+			ids = map[string]bool{}
+
+			messages, err := s.conn.server.params.Repository.Messages.Search(s.conn.user, text, 0)
+			if err != nil && !errors.Is(err, repository.ErrMissingSearchQuery) {
+				return false, err
+			}
+
+			for _, message := range messages {
+				ids[message.Id] = true
+			}
+
+			s.texts[text] = ids
+		}
+
+		return ids[m.message.Id], nil
+	}
+}
+
+func (s *searcher) size(m *message) (int, error) {
+	if size, ok := s.sizes[m.uid]; ok {
+		return size, nil
+	}
+
+	raw, err := s.conn.server.params.Storage.Messages.RenderMIME(s.conn.user, m.message)
+	if err != nil {
+		return 0, err
+	}
+
+	s.sizes[m.uid] = len(raw)
+
+	return len(raw), nil
+}
+
+// compareDate compares the days of the dates, the time and the time zone are disregarded.
+func compareDate(t, date time.Time, how string) bool {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+
+	switch how {
+	case "BEFORE":
+		return day.Before(date)
+	case "ON":
+		return day.Equal(date)
+	default:
+		return !day.Before(date)
+	}
+}
+
+// sentDate returns the Date header of the message, the messages submitted before the RFC 5322 format have
+// it in the Unix date format.
+func sentDate(m *message) (time.Time, bool) {
+	if m.message.Payload == nil {
+		return time.Time{}, false
+	}
+
+	value, _ := m.message.Payload.Headers["Date"].(string)
+
+	if date, err := mail.ParseDate(value); err == nil {
+		return date, true
+	}
+
+	if date, err := time.Parse(time.UnixDate, value); err == nil {
+		return date, true
+	}
+
+	return time.Time{}, false
+}
@@ -0,0 +1,133 @@
+package mailbox
+
+import (
+	"cargomail/cmd/mailbox/api/helper"
+	"cargomail/internal/mailbox/repository"
+	"cargomail/internal/shared/config"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// the health checks and metrics are served even when the limit is reached, the event streams would
+// hold their slots for as long as they are open
+var limiterExemptPaths = map[string]bool{
+	"/api/v1/health":       true,
+	"/api/v1/health/ready": true,
+	"/api/v1/metrics":      true,
+	"/api/v1/sync/stream":  true,
+}
+
+// limiter bounds the number of requests served at once, regardless of their rate, e.g. when all
+// the clients sync at the same time after an outage. It is shared by the routers of the service.
+type limiter struct {
+	slots    chan struct{} // nil = no limit
+	max      int
+	queue    bool
+	wait     time.Duration
+	inflight atomic.Int64
+	rejected atomic.Int64
+}
+
+type limiterMetrics struct {
+	Inflight    int64  `json:"inflightRequests"`
+	MaxInflight int    `json:"maxInflightRequests"`
+	Rejected    int64  `json:"rejectedRequests"`
+	Mode        string `json:"inflightLimitMode"`
+}
+
+func newLimiter(max int, queue bool, wait time.Duration) *limiter {
+	l := &limiter{
+		max:   max,
+		queue: queue,
+		wait:  wait,
+	}
+
+	if max > 0 {
+		l.slots = make(chan struct{}, max)
+	}
+
+	return l
+}
+
+// acquire takes a slot, waiting for one in the queue mode. The caller must release the acquired slot.
+func (l *limiter) acquire(r *http.Request) bool {
+	if l.slots == nil {
+		l.inflight.Add(1)
+		return true
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		l.inflight.Add(1)
+		return true
+	default:
+	}
+
+	if l.queue {
+		timer := time.NewTimer(l.wait)
+		defer timer.Stop()
+
+		select {
+		case l.slots <- struct{}{}:
+			l.inflight.Add(1)
+			return true
+		case <-timer.C:
+		case <-r.Context().Done():
+		}
+	}
+
+	l.rejected.Add(1)
+	return false
+}
+
+func (l *limiter) release() {
+	l.inflight.Add(-1)
+
+	if l.slots != nil {
+		<-l.slots
+	}
+}
+
+// serve runs the handler within a slot, or rejects the request with 503 when there is none
+func (l *limiter) serve(w http.ResponseWriter, r *http.Request, handler http.Handler) {
+	if limiterExemptPaths[r.URL.Path] {
+		handler.ServeHTTP(w, r)
+		return
+	}
+
+	if !l.acquire(r) {
+		retryAfter := int(l.wait.Seconds())
+		if retryAfter < 1 {
+			retryAfter = 1
+		}
+
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		helper.ReturnErr(w, repository.ErrServerBusy, http.StatusServiceUnavailable)
+		return
+	}
+	defer l.release()
+
+	handler.ServeHTTP(w, r)
+}
+
+func (l *limiter) Metrics() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mode := "reject"
+		if l.queue {
+			mode = "queue"
+		}
+
+		helper.SetJsonResponse(w, http.StatusOK, &limiterMetrics{
+			Inflight:    l.inflight.Load(),
+			MaxInflight: l.max,
+			Rejected:    l.rejected.Load(),
+			Mode:        mode,
+		})
+	})
+}
+
+func newConfiguredLimiter() *limiter {
+	return newLimiter(config.MaxInflight(), config.InflightQueue(), config.DefaultInflightWait)
+}
@@ -0,0 +1,130 @@
+package mailbox
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// blockingHandler holds the requests until the release is closed, it signals each request it serves.
+func blockingHandler(started chan<- struct{}, release <-chan struct{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func TestLimiterRejects(t *testing.T) {
+	l := newLimiter(1, false, 2*time.Second)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := blockingHandler(started, release)
+
+	done := make(chan int)
+
+	go func() {
+		w := httptest.NewRecorder()
+		l.serve(w, httptest.NewRequest(http.MethodGet, "/api/v1/messages", nil), handler)
+		done <- w.Code
+	}()
+
+	<-started
+
+	w := httptest.NewRecorder()
+	l.serve(w, httptest.NewRequest(http.MethodGet, "/api/v1/messages", nil), handler)
+
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "2" {
+		t.Errorf("got the status %d of Retry-After %q, want %d of \"2\"", w.Code, w.Header().Get("Retry-After"), http.StatusServiceUnavailable)
+	}
+
+	// the exempt paths are served over the limit
+	w = httptest.NewRecorder()
+	l.serve(w, httptest.NewRequest(http.MethodGet, "/api/v1/health", nil), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("got the status %d of the health check, want %d", w.Code, http.StatusOK)
+	}
+
+	close(release)
+
+	if code := <-done; code != http.StatusOK {
+		t.Errorf("got the status %d of the first request, want %d", code, http.StatusOK)
+	}
+
+	if l.inflight.Load() != 0 || l.rejected.Load() != 1 {
+		t.Errorf("got %d requests in flight and %d rejected, want 0 and 1", l.inflight.Load(), l.rejected.Load())
+	}
+}
+
+func TestLimiterQueues(t *testing.T) {
+	l := newLimiter(1, true, 5*time.Second)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := blockingHandler(started, release)
+
+	done := make(chan int)
+
+	for i := 0; i < 2; i++ {
+		go func() {
+			w := httptest.NewRecorder()
+			l.serve(w, httptest.NewRequest(http.MethodGet, "/api/v1/messages", nil), handler)
+			done <- w.Code
+		}()
+	}
+
+	<-started
+
+	// the second request waits for the slot of the first one
+	select {
+	case <-started:
+		t.Fatal("both requests served at once")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	release <- struct{}{}
+	<-started
+	close(release)
+
+	for i := 0; i < 2; i++ {
+		if code := <-done; code != http.StatusOK {
+			t.Errorf("got the status %d, want %d", code, http.StatusOK)
+		}
+	}
+
+	if l.rejected.Load() != 0 {
+		t.Errorf("got %d rejected requests, want 0", l.rejected.Load())
+	}
+}
+
+func TestLimiterQueueTimeout(t *testing.T) {
+	l := newLimiter(1, true, 50*time.Millisecond)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := blockingHandler(started, release)
+
+	done := make(chan struct{})
+
+	go func() {
+		l.serve(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/messages", nil), handler)
+		close(done)
+	}()
+
+	<-started
+
+	w := httptest.NewRecorder()
+	l.serve(w, httptest.NewRequest(http.MethodGet, "/api/v1/messages", nil), handler)
+
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "1" {
+		t.Errorf("got the status %d of Retry-After %q, want %d of \"1\"", w.Code, w.Header().Get("Retry-After"), http.StatusServiceUnavailable)
+	}
+
+	close(release)
+	<-done
+}
@@ -6,6 +6,7 @@ import (
 	"cargomail/internal/mailbox/repository"
 	"cargomail/internal/mailbox/storage"
 	"cargomail/internal/shared/config"
+	"cargomail/internal/shared/ratelimit"
 	"context"
 	"database/sql"
 	"log"
@@ -16,17 +17,27 @@ import (
 )
 
 type ServiceParams struct {
-	DB *sql.DB
+	DB        *sql.DB
+	ReplicaDB *sql.DB // nil = no replica
 }
 
 type service struct {
-	api api.Api
+	api        api.Api
+	repository repository.Repository
+	storage    storage.Storage
+	limiter    *limiter
+	rateLimits *ratelimit.Limits
+	userLimits *ratelimit.Limiter
+	events     *api.EventBroker
+	eventSinks []eventSink
 }
 
 func NewService(params *ServiceParams) (service, error) {
-	repository := repository.NewRepository(params.DB)
+	repository := repository.NewRepositoryWithReplica(params.DB, params.ReplicaDB)
 	storage := storage.NewStorage(repository)
 	agent := agent.NewAgent(repository)
+	userLimits := ratelimit.NewLimiter(config.UserRateLimit())
+	events := api.NewEventBroker()
 
 	return service{
 		api: api.NewApi(
@@ -34,12 +45,23 @@ func NewService(params *ServiceParams) (service, error) {
 				Repository: repository,
 				Storage:    storage,
 				Agent:      agent,
+				UserLimits: userLimits,
+				Events:     events,
 			}),
+		repository: repository,
+		storage:    storage,
+		limiter:    newConfiguredLimiter(),
+		rateLimits: ratelimit.NewConfiguredLimits(),
+		userLimits: userLimits,
+		events:     events,
+		eventSinks: []eventSink{&streamSink{broker: events}, &webhookSink{useWebhookRepository: repository.Webhooks}},
 	}, nil
 }
 
 func (svc *service) Serve(ctx context.Context, errs *errgroup.Group) {
 	router := NewRouter()
+	router.limiter = svc.limiter
+	router.rateLimits = svc.rateLimits
 
 	svc.routes(router)
 
@@ -48,6 +70,8 @@ func (svc *service) Serve(ctx context.Context, errs *errgroup.Group) {
 	// http2.ConfigureServer(http1Server, &http2.Server{})
 
 	rhsRouter := NewRouter()
+	rhsRouter.limiter = svc.limiter
+	rhsRouter.rateLimits = svc.rateLimits
 
 	svc.routes(rhsRouter)
 
@@ -107,6 +131,48 @@ func (svc *service) Serve(ctx context.Context, errs *errgroup.Group) {
 		return nil
 	})
 
+	svc.serveGrpc(ctx, errs)
+	svc.serveImap(ctx, errs)
+
+	errs.Go(func() error {
+		return svc.sweepTrash(ctx)
+	})
+
+	errs.Go(func() error {
+		return svc.expireUploads(ctx)
+	})
+
+	errs.Go(func() error {
+		return svc.expireIdempotencyKeys(ctx)
+	})
+
+	errs.Go(func() error {
+		return svc.rateLimits.Sweep(ctx)
+	})
+
+	errs.Go(func() error {
+		return svc.userLimits.Sweep(ctx)
+	})
+
+	errs.Go(func() error {
+		return svc.dispatchEvents(ctx)
+	})
+
+	errs.Go(func() error {
+		return svc.sendWebhooks(ctx)
+	})
+
+	errs.Go(func() error {
+		return svc.sendFederated(ctx)
+	})
+
+	errs.Go(func() error {
+		<-ctx.Done()
+		// the event streams would hold the graceful shutdown of the servers
+		svc.events.Close()
+		return nil
+	})
+
 	errs.Go(func() error {
 		log.Printf("http MDS is listening on http://%s", mdsHttp1Server.Addr)
 		return mdsHttp1Server.ListenAndServe()
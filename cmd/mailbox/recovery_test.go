@@ -0,0 +1,48 @@
+package mailbox
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// recovered serves the handler as the router does, with the recovery deferred first
+func recovered(handler http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer recoverPanic(w, r)
+
+		handler(w, r)
+	})
+}
+
+func TestRecoverPanic(t *testing.T) {
+	handler := recovered(func(w http.ResponseWriter, r *http.Request) {
+		var user map[string]string
+		user["id"] = "1"
+	})
+
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/messages", nil))
+
+	if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), `"internal_error"`) {
+		t.Errorf("got the status %d of %s, want %d of internal_error", w.Code, w.Body.String(), http.StatusInternalServerError)
+	}
+}
+
+func TestRecoverPanicAbortHandler(t *testing.T) {
+	handler := recovered(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+
+	defer func() {
+		if err := recover(); err != http.ErrAbortHandler {
+			t.Errorf("got the panic %v, want %v", err, http.ErrAbortHandler)
+		}
+	}()
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/messages", nil))
+
+	t.Error("the aborted handler recovered")
+}
@@ -1,7 +1,11 @@
 package mailbox
 
 import (
+	"cargomail/cmd/mailbox/api/helper"
+	"cargomail/internal/mailbox/repository"
 	"cargomail/internal/shared/config"
+	"cargomail/internal/shared/provider"
+	"cargomail/internal/shared/ratelimit"
 	"net/http"
 	"strings"
 )
@@ -13,7 +17,12 @@ type Entry struct {
 }
 
 type Router struct {
-	routes []Entry
+	routes     []Entry
+	rateLimits *ratelimit.Limits
+	limiter    *limiter
+
+	notFound         http.Handler
+	methodNotAllowed http.Handler
 }
 
 func NewRouter() *Router { return new(Router) }
@@ -28,11 +37,26 @@ func (t *Router) Route(method, path string, handler http.Handler) {
 	t.routes = append(t.routes, e)
 }
 
+// NotFound sets the handler of the requests no route matches, http.NotFound by default.
+func (t *Router) NotFound(handler http.Handler) {
+	t.notFound = handler
+}
+
+// MethodNotAllowed sets the handler of the requests a route matches the path of, but not the method.
+// The Allow header lists the methods of the path. Unset, the request is not found.
+func (t *Router) MethodNotAllowed(handler http.Handler) {
+	t.methodNotAllowed = handler
+}
+
 func (e *Entry) Match(r *http.Request) bool {
 	if r.Method != "OPTIONS" && r.Method != e.Method {
 		return false
 	}
 
+	return e.MatchPath(r)
+}
+
+func (e *Entry) MatchPath(r *http.Request) bool {
 	urlPath := r.URL.Path
 
 	if !strings.HasPrefix(urlPath, "/snippets/") {
@@ -54,10 +78,15 @@ func setupCORS(w *http.ResponseWriter, r *http.Request) {
 	(*w).Header().Set("Access-Control-Allow-Origin", r.Header.Get("Origin"))
 	(*w).Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, PATCH, DELETE, HEAD")
 	(*w).Header().Set("Access-Control-Allow-Credentials", "true")
-	(*w).Header().Set("Access-Control-Allow-Headers", "Original-Subject, Origin, X-Requested-With, Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Warning")
+	(*w).Header().Set("Access-Control-Allow-Headers", "Original-Subject, Origin, X-Requested-With, Accept, Content-Type, Content-Length, Content-Encoding, Content-Range, Accept-Encoding, X-CSRF-Token, Authorization, Idempotency-Key, X-Request-Id, X-Warning")
+	(*w).Header().Set("Access-Control-Expose-Headers", "X-Request-Id")
 }
 
 func (t *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w, r = provider.WithRequestID(w, r)
+	defer provider.LogServerError(w, r)
+	defer recoverPanic(w, r)
+
 	for _, e := range t.routes {
 		match := e.Match(r)
 		if !match {
@@ -69,12 +98,34 @@ func (t *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		if t.rateLimits != nil {
+			if ok, wait := t.rateLimits.Allow(r); !ok {
+				w.Header().Set("Retry-After", ratelimit.RetryAfter(wait))
+				helper.ReturnErr(w, repository.ErrRateLimited, http.StatusTooManyRequests)
+				return
+			}
+		}
+
 		urlPath := r.URL.Path
 
-		if strings.HasSuffix(urlPath, "/upload") {
-			r.Body = http.MaxBytesReader(w, r.Body, config.DefaultMaxUploadSize<<20)
-		} else {
-			r.Body = http.MaxBytesReader(w, r.Body, config.DefaultMaxBodySize<<20)
+		var maxBytes int64 = config.DefaultMaxBodySize << 20
+
+		// the chunks of the resumable uploads are sent to /uploads/{id}
+		// the multipart framing of an upload of the max size is let through, the handlers check the
+		// sizes of the uploaded parts
+		if strings.HasSuffix(urlPath, "/upload") || strings.Contains(urlPath, "/uploads/") {
+			maxBytes = config.MaxUploadBytes() + config.DefaultMaxBodySize<<20
+		}
+
+		// the federated message carries the content of its blobs and files, in base64
+		if urlPath == "/api/v1/federation/deliver" {
+			maxBytes = config.MaxUploadBytes()*4/3 + config.DefaultMaxBodySize<<20
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+
+		if !decodeBody(w, r, maxBytes) {
+			return
 		}
 
 		if !config.DevStage() {
@@ -83,10 +134,40 @@ func (t *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
+		if t.limiter != nil {
+			t.limiter.serve(w, r, e.Handler)
+			return
+		}
+
 		e.Handler.ServeHTTP(w, r)
 		return
 	}
 
+	t.serveUnmatched(w, r)
+}
+
+func (t *Router) serveUnmatched(w http.ResponseWriter, r *http.Request) {
+	if t.methodNotAllowed != nil {
+		var allowed []string
+
+		for _, e := range t.routes {
+			if e.MatchPath(r) {
+				allowed = append(allowed, e.Method)
+			}
+		}
+
+		if len(allowed) > 0 {
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+			t.methodNotAllowed.ServeHTTP(w, r)
+			return
+		}
+	}
+
+	if t.notFound != nil {
+		t.notFound.ServeHTTP(w, r)
+		return
+	}
+
 	http.NotFound(w, r)
 }
 
@@ -94,58 +175,158 @@ func (svc *service) routes(r *Router) {
 	// Health API
 	r.Route("GET", "/api/v1/health", svc.api.Health.Healthcheck())
 	r.Route("POST", "/api/v1/health", svc.api.Health.Healthcheck())
+	r.Route("GET", "/api/v1/health/ready", svc.api.Health.Ready())
+	r.Route("GET", "/api/v1/metrics", svc.limiter.Metrics())
+
+	// Sync API
+	r.Route("POST", "/api/v1/sync", svc.api.Authenticate(svc.api.Sync.Sync()))
+	r.Route("GET", "/api/v1/sync/stream", svc.api.Authenticate(svc.api.Stream.Stream()))
+
+	// Search API
+	r.Route("GET", "/api/v1/search", svc.api.Authenticate(svc.api.Search.Search()))
+	r.Route("POST", "/api/v1/searches", svc.api.Authenticate(svc.api.RequireScope("searches:write", svc.api.Idempotent(svc.api.Search.CreateSaved()))))
+	r.Route("GET", "/api/v1/searches", svc.api.Authenticate(svc.api.RequireScope("searches:read", svc.api.Search.ListSaved())))
+	r.Route("PUT", "/api/v1/searches", svc.api.Authenticate(svc.api.RequireScope("searches:write", svc.api.Search.UpdateSaved())))
+	r.Route("DELETE", "/api/v1/searches", svc.api.Authenticate(svc.api.RequireScope("searches:write", svc.api.Search.DeleteSaved())))
+	r.Route("POST", "/api/v1/searches/sync", svc.api.Authenticate(svc.api.RequireScope("searches:read", svc.api.Search.SyncSaved())))
+	r.Route("GET", "/api/v1/searches/run", svc.api.Authenticate(svc.api.RequireScope("searches:read", svc.api.Search.RunSaved())))
 
 	// Contacts API
-	r.Route("POST", "/api/v1/contacts", svc.api.Authenticate(svc.api.Contacts.Create()))
-	r.Route("POST", "/api/v1/contacts/list", svc.api.Authenticate(svc.api.Contacts.List()))
-	r.Route("POST", "/api/v1/contacts/sync", svc.api.Authenticate(svc.api.Contacts.Sync()))
-	r.Route("PUT", "/api/v1/contacts", svc.api.Authenticate(svc.api.Contacts.Update()))
-	r.Route("POST", "/api/v1/contacts/trash", svc.api.Authenticate(svc.api.Contacts.Trash()))
-	r.Route("POST", "/api/v1/contacts/untrash", svc.api.Authenticate(svc.api.Contacts.Untrash()))
-	r.Route("DELETE", "/api/v1/contacts/delete", svc.api.Authenticate(svc.api.Contacts.Delete()))
+	r.Route("POST", "/api/v1/contacts", svc.api.Authenticate(svc.api.RequireScope("contacts:write", svc.api.Idempotent(svc.api.Contacts.Create()))))
+	r.Route("POST", "/api/v1/contacts/batch", svc.api.Authenticate(svc.api.RequireScope("contacts:write", svc.api.Idempotent(svc.api.Contacts.CreateBatch()))))
+	r.Route("POST", "/api/v1/contacts/list", svc.api.Authenticate(svc.api.RequireScope("contacts:read", svc.api.ListETag("contacts", svc.api.Contacts.List()))))
+	r.Route("GET", "/api/v1/contacts/count", svc.api.Authenticate(svc.api.RequireScope("contacts:read", svc.api.Contacts.Count())))
+	r.Route("POST", "/api/v1/contacts/sync", svc.api.Authenticate(svc.api.RequireScope("contacts:read", svc.api.Contacts.Sync())))
+	r.Route("GET", "/api/v1/contacts/export/vcard", svc.api.Authenticate(svc.api.RequireScope("contacts:read", svc.api.Contacts.ExportVCard())))
+	r.Route("GET", "/api/v1/contacts/export/csv", svc.api.Authenticate(svc.api.RequireScope("contacts:read", svc.api.Contacts.ExportCSV())))
+	r.Route("POST", "/api/v1/contacts/import/csv", svc.api.Authenticate(svc.api.RequireScope("contacts:write", svc.api.Contacts.ImportCSV())))
+	r.Route("GET", "/api/v1/contacts/trash", svc.api.Authenticate(svc.api.RequireScope("contacts:read", svc.api.Contacts.ListTrashed())))
+	r.Route("PUT", "/api/v1/contacts", svc.api.Authenticate(svc.api.RequireScope("contacts:write", svc.api.Contacts.Update())))
+	r.Route("POST", "/api/v1/contacts/trash", svc.api.Authenticate(svc.api.RequireScope("contacts:write", svc.api.Contacts.Trash())))
+	r.Route("POST", "/api/v1/contacts/untrash", svc.api.Authenticate(svc.api.RequireScope("contacts:write", svc.api.Contacts.Untrash())))
+	r.Route("DELETE", "/api/v1/contacts/delete", svc.api.Authenticate(svc.api.RequireScope("contacts:write", svc.api.Contacts.Delete())))
+	r.Route("DELETE", "/api/v1/contacts/trash", svc.api.Authenticate(svc.api.RequireScope("contacts:write", svc.api.Contacts.EmptyTrash())))
+	r.Route("POST", "/api/v1/contacts/emails", svc.api.Authenticate(svc.api.RequireScope("contacts:write", svc.api.Contacts.AddEmailAddress())))
+	r.Route("DELETE", "/api/v1/contacts/emails", svc.api.Authenticate(svc.api.RequireScope("contacts:write", svc.api.Contacts.RemoveEmailAddress())))
+	r.Route("POST", "/api/v1/contacts/groups", svc.api.Authenticate(svc.api.RequireScope("contacts:write", svc.api.Idempotent(svc.api.Contacts.CreateGroup()))))
+	r.Route("GET", "/api/v1/contacts/groups", svc.api.Authenticate(svc.api.RequireScope("contacts:read", svc.api.Contacts.ListGroups())))
+	r.Route("PUT", "/api/v1/contacts/groups", svc.api.Authenticate(svc.api.RequireScope("contacts:write", svc.api.Contacts.UpdateGroup())))
+	r.Route("DELETE", "/api/v1/contacts/groups", svc.api.Authenticate(svc.api.RequireScope("contacts:write", svc.api.Contacts.DeleteGroup())))
+	r.Route("POST", "/api/v1/contacts/groups/sync", svc.api.Authenticate(svc.api.RequireScope("contacts:read", svc.api.Contacts.SyncGroups())))
+	r.Route("POST", "/api/v1/contacts/groups/members", svc.api.Authenticate(svc.api.RequireScope("contacts:write", svc.api.Contacts.AssignToGroup())))
+	r.Route("DELETE", "/api/v1/contacts/groups/members", svc.api.Authenticate(svc.api.RequireScope("contacts:write", svc.api.Contacts.RemoveFromGroup())))
+	r.Route("GET", "/api/v1/contacts/groups/contacts", svc.api.Authenticate(svc.api.RequireScope("contacts:read", svc.api.Contacts.ListByGroup())))
+	r.Route("POST", "/api/v1/contacts/merge", svc.api.Authenticate(svc.api.RequireScope("contacts:write", svc.api.Contacts.Merge())))
+	r.Route("POST", "/api/v1/contacts/emails/primary", svc.api.Authenticate(svc.api.RequireScope("contacts:write", svc.api.Contacts.SetPrimaryEmailAddress())))
 
 	// Files API
-	r.Route("POST", "/api/v1/files/upload", svc.api.Authenticate(svc.api.Files.Upload()))
-	r.Route("POST", "/api/v1/files/list", svc.api.Authenticate(svc.api.Files.List()))
-	r.Route("POST", "/api/v1/files/sync", svc.api.Authenticate(svc.api.Files.Sync()))
-	r.Route("HEAD", "/api/v1/files/", svc.api.Authenticate(svc.api.Files.Download()))
-	r.Route("GET", "/api/v1/files/", svc.api.Authenticate(svc.api.Files.Download()))
-	r.Route("POST", "/api/v1/files/trash", svc.api.Authenticate(svc.api.Files.Trash()))
-	r.Route("POST", "/api/v1/files/untrash", svc.api.Authenticate(svc.api.Files.Untrash()))
-	r.Route("DELETE", "/api/v1/files/delete", svc.api.Authenticate(svc.api.Files.Delete()))
+	r.Route("POST", "/api/v1/files/upload", svc.api.Authenticate(svc.api.RequireScope("files:write", svc.api.Idempotent(svc.api.Files.Upload()))))
+	r.Route("POST", "/api/v1/files/list", svc.api.Authenticate(svc.api.RequireScope("files:read", svc.api.ListETag("files", svc.api.Files.List()))))
+	r.Route("POST", "/api/v1/files/sync", svc.api.Authenticate(svc.api.RequireScope("files:read", svc.api.Files.Sync())))
+	r.Route("GET", "/api/v1/files/trash", svc.api.Authenticate(svc.api.RequireScope("files:read", svc.api.Files.ListTrashed())))
+	r.Route("GET", "/api/v1/files/search", svc.api.Authenticate(svc.api.RequireScope("files:read", svc.api.Files.Search())))
+	r.Route("POST", "/api/v1/files/sign", svc.api.Authenticate(svc.api.RequireScope("files:read", svc.api.Files.SignUrl())))
+	r.Route("POST", "/api/v1/files/uploads", svc.api.Authenticate(svc.api.RequireScope("files:write", svc.api.Idempotent(svc.api.Uploads.Init(repository.FilesResource)))))
+	r.Route("GET", "/api/v1/files/uploads/", svc.api.Authenticate(svc.api.RequireScope("files:write", svc.api.Uploads.Status(repository.FilesResource))))
+	r.Route("PATCH", "/api/v1/files/uploads/", svc.api.Authenticate(svc.api.RequireScope("files:write", svc.api.Uploads.Append(repository.FilesResource))))
+	r.Route("PUT", "/api/v1/files/uploads/", svc.api.Authenticate(svc.api.RequireScope("files:write", svc.api.Uploads.Finalize(repository.FilesResource))))
+	r.Route("DELETE", "/api/v1/files/uploads/", svc.api.Authenticate(svc.api.RequireScope("files:write", svc.api.Uploads.Abort(repository.FilesResource))))
+	r.Route("GET", "/api/v1/files/tags", svc.api.Authenticate(svc.api.RequireScope("files:read", svc.api.Files.ListTags())))
+	r.Route("HEAD", "/api/v1/files/", svc.api.AuthenticateSigned("files:read", svc.api.RequireScope("files:read", svc.api.Files.Download())))
+	r.Route("GET", "/api/v1/files/", svc.api.AuthenticateSigned("files:read", svc.api.RequireScope("files:read", svc.api.Files.Download())))
+	r.Route("PUT", "/api/v1/files", svc.api.Authenticate(svc.api.RequireScope("files:write", svc.api.Files.Update())))
+	r.Route("POST", "/api/v1/files/trash", svc.api.Authenticate(svc.api.RequireScope("files:write", svc.api.Files.Trash())))
+	r.Route("POST", "/api/v1/files/untrash", svc.api.Authenticate(svc.api.RequireScope("files:write", svc.api.Files.Untrash())))
+	r.Route("DELETE", "/api/v1/files/delete", svc.api.Authenticate(svc.api.RequireScope("files:write", svc.api.Files.Delete())))
+	r.Route("POST", "/api/v1/files/", svc.api.Authenticate(svc.api.RequireScope("files:write", svc.api.Files.AddTag())))
+	r.Route("DELETE", "/api/v1/files/", svc.api.Authenticate(svc.api.RequireScope("files:write", svc.api.Files.RemoveTag())))
 
 	// Blobs API
-	r.Route("POST", "/api/v1/blobs/upload", svc.api.Authenticate(svc.api.Blobs.Upload()))
-	r.Route("POST", "/api/v1/blobs/list", svc.api.Authenticate(svc.api.Blobs.List()))
-	r.Route("POST", "/api/v1/blobs/sync", svc.api.Authenticate(svc.api.Blobs.Sync()))
-	r.Route("HEAD", "/api/v1/blobs/", svc.api.Authenticate(svc.api.Blobs.Download()))
-	r.Route("GET", "/api/v1/blobs/", svc.api.Authenticate(svc.api.Blobs.Download()))
-	r.Route("POST", "/api/v1/blobs/trash", svc.api.Authenticate(svc.api.Blobs.Trash()))
-	r.Route("POST", "/api/v1/blobs/untrash", svc.api.Authenticate(svc.api.Blobs.Untrash()))
-	r.Route("DELETE", "/api/v1/blobs/delete", svc.api.Authenticate(svc.api.Blobs.Delete()))
+	r.Route("POST", "/api/v1/blobs/upload", svc.api.Authenticate(svc.api.RequireScope("blobs:write", svc.api.Idempotent(svc.api.Blobs.Upload()))))
+	r.Route("POST", "/api/v1/blobs/list", svc.api.Authenticate(svc.api.RequireScope("blobs:read", svc.api.ListETag("blobs", svc.api.Blobs.List()))))
+	r.Route("GET", "/api/v1/blobs/count", svc.api.Authenticate(svc.api.RequireScope("blobs:read", svc.api.Blobs.Count())))
+	r.Route("POST", "/api/v1/blobs/sync", svc.api.Authenticate(svc.api.RequireScope("blobs:read", svc.api.Blobs.Sync())))
+	r.Route("GET", "/api/v1/blobs/trash", svc.api.Authenticate(svc.api.RequireScope("blobs:read", svc.api.Blobs.ListTrashed())))
+	r.Route("GET", "/api/v1/blobs/search", svc.api.Authenticate(svc.api.RequireScope("blobs:read", svc.api.Blobs.Search())))
+	r.Route("GET", "/api/v1/blobs/tags", svc.api.Authenticate(svc.api.RequireScope("blobs:read", svc.api.Blobs.ListTags())))
+	r.Route("POST", "/api/v1/blobs/collections", svc.api.Authenticate(svc.api.RequireScope("blobs:write", svc.api.Idempotent(svc.api.Blobs.CreateCollection()))))
+	r.Route("GET", "/api/v1/blobs/collections", svc.api.Authenticate(svc.api.RequireScope("blobs:read", svc.api.Blobs.ListCollections())))
+	r.Route("PUT", "/api/v1/blobs/collections", svc.api.Authenticate(svc.api.RequireScope("blobs:write", svc.api.Blobs.UpdateCollection())))
+	r.Route("DELETE", "/api/v1/blobs/collections", svc.api.Authenticate(svc.api.RequireScope("blobs:write", svc.api.Blobs.DeleteCollection())))
+	r.Route("POST", "/api/v1/blobs/collections/sync", svc.api.Authenticate(svc.api.RequireScope("blobs:read", svc.api.Blobs.SyncCollections())))
+	r.Route("POST", "/api/v1/blobs/collections/blobs", svc.api.Authenticate(svc.api.RequireScope("blobs:write", svc.api.Blobs.MoveToCollection())))
+	r.Route("GET", "/api/v1/blobs/collections/blobs", svc.api.Authenticate(svc.api.RequireScope("blobs:read", svc.api.Blobs.ListByCollection())))
+	r.Route("POST", "/api/v1/blobs/sign", svc.api.Authenticate(svc.api.RequireScope("blobs:read", svc.api.Blobs.SignUrl())))
+	r.Route("POST", "/api/v1/blobs/uploads", svc.api.Authenticate(svc.api.RequireScope("blobs:write", svc.api.Idempotent(svc.api.Uploads.Init(repository.BlobsResource)))))
+	r.Route("GET", "/api/v1/blobs/uploads/", svc.api.Authenticate(svc.api.RequireScope("blobs:write", svc.api.Uploads.Status(repository.BlobsResource))))
+	r.Route("PATCH", "/api/v1/blobs/uploads/", svc.api.Authenticate(svc.api.RequireScope("blobs:write", svc.api.Uploads.Append(repository.BlobsResource))))
+	r.Route("PUT", "/api/v1/blobs/uploads/", svc.api.Authenticate(svc.api.RequireScope("blobs:write", svc.api.Uploads.Finalize(repository.BlobsResource))))
+	r.Route("DELETE", "/api/v1/blobs/uploads/", svc.api.Authenticate(svc.api.RequireScope("blobs:write", svc.api.Uploads.Abort(repository.BlobsResource))))
+	r.Route("HEAD", "/api/v1/blobs/", svc.api.AuthenticateSigned("blobs:read", svc.api.RequireScope("blobs:read", svc.api.Blobs.Download())))
+	r.Route("GET", "/api/v1/blobs/", svc.api.AuthenticateSigned("blobs:read", svc.api.RequireScope("blobs:read", svc.api.Blobs.Download())))
+	r.Route("PUT", "/api/v1/blobs", svc.api.Authenticate(svc.api.RequireScope("blobs:write", svc.api.Blobs.Update())))
+	r.Route("POST", "/api/v1/blobs/trash", svc.api.Authenticate(svc.api.RequireScope("blobs:write", svc.api.Blobs.Trash())))
+	r.Route("POST", "/api/v1/blobs/untrash", svc.api.Authenticate(svc.api.RequireScope("blobs:write", svc.api.Blobs.Untrash())))
+	r.Route("DELETE", "/api/v1/blobs/delete", svc.api.Authenticate(svc.api.RequireScope("blobs:write", svc.api.Blobs.Delete())))
+	r.Route("DELETE", "/api/v1/blobs/trash", svc.api.Authenticate(svc.api.RequireScope("blobs:write", svc.api.Blobs.EmptyTrash())))
+	r.Route("POST", "/api/v1/blobs/", svc.api.Authenticate(svc.api.RequireScope("blobs:write", svc.api.Blobs.AddTag())))
+	r.Route("DELETE", "/api/v1/blobs/", svc.api.Authenticate(svc.api.RequireScope("blobs:write", svc.api.Blobs.RemoveTag())))
 
 	// Drafts API
-	r.Route("POST", "/api/v1/drafts", svc.api.Authenticate(svc.api.Drafts.Create()))
-	r.Route("POST", "/api/v1/drafts/list", svc.api.Authenticate(svc.api.Drafts.List()))
-	r.Route("POST", "/api/v1/drafts/sync", svc.api.Authenticate(svc.api.Drafts.Sync()))
-	r.Route("PUT", "/api/v1/drafts", svc.api.Authenticate(svc.api.Drafts.Update()))
-	r.Route("POST", "/api/v1/drafts/trash", svc.api.Authenticate(svc.api.Drafts.Trash()))
-	r.Route("POST", "/api/v1/drafts/untrash", svc.api.Authenticate(svc.api.Drafts.Untrash()))
-	r.Route("DELETE", "/api/v1/drafts/delete", svc.api.Authenticate(svc.api.Drafts.Delete()))
-	r.Route("POST", "/api/v1/drafts/submit", svc.api.Authenticate(svc.api.Drafts.Submit()))
+	r.Route("POST", "/api/v1/drafts", svc.api.Authenticate(svc.api.RequireScope("drafts:write", svc.api.Idempotent(svc.api.Drafts.Create()))))
+	r.Route("POST", "/api/v1/drafts/list", svc.api.Authenticate(svc.api.RequireScope("drafts:read", svc.api.ListETag("drafts", svc.api.Drafts.List()))))
+	r.Route("GET", "/api/v1/drafts/search", svc.api.Authenticate(svc.api.RequireScope("drafts:read", svc.api.Drafts.Search())))
+	r.Route("GET", "/api/v1/drafts/count", svc.api.Authenticate(svc.api.RequireScope("drafts:read", svc.api.Drafts.Count())))
+	r.Route("POST", "/api/v1/drafts/sync", svc.api.Authenticate(svc.api.RequireScope("drafts:read", svc.api.Drafts.Sync())))
+	r.Route("GET", "/api/v1/drafts/trash", svc.api.Authenticate(svc.api.RequireScope("drafts:read", svc.api.Drafts.ListTrashed())))
+	r.Route("PUT", "/api/v1/drafts", svc.api.Authenticate(svc.api.RequireScope("drafts:write", svc.api.Drafts.Update())))
+	r.Route("GET", "/api/v1/drafts/versions", svc.api.Authenticate(svc.api.RequireScope("drafts:read", svc.api.Drafts.ListVersions())))
+	r.Route("POST", "/api/v1/drafts/versions/restore", svc.api.Authenticate(svc.api.RequireScope("drafts:write", svc.api.Drafts.RestoreVersion())))
+	r.Route("POST", "/api/v1/drafts/flags", svc.api.Authenticate(svc.api.RequireScope("drafts:write", svc.api.Drafts.SetFlags())))
+	r.Route("POST", "/api/v1/drafts/trash", svc.api.Authenticate(svc.api.RequireScope("drafts:write", svc.api.Drafts.Trash())))
+	r.Route("POST", "/api/v1/drafts/untrash", svc.api.Authenticate(svc.api.RequireScope("drafts:write", svc.api.Drafts.Untrash())))
+	r.Route("DELETE", "/api/v1/drafts/delete", svc.api.Authenticate(svc.api.RequireScope("drafts:write", svc.api.Drafts.Delete())))
+	r.Route("DELETE", "/api/v1/drafts/trash", svc.api.Authenticate(svc.api.RequireScope("drafts:write", svc.api.Drafts.EmptyTrash())))
+	r.Route("DELETE", "/api/v1/drafts/", svc.api.Authenticate(svc.api.RequireScope("drafts:write", svc.api.Drafts.DeleteSubresource())))
+	r.Route("POST", "/api/v1/drafts/submit", svc.api.Authenticate(svc.api.RequireScope("drafts:write", svc.api.Drafts.Submit())))
+	r.Route("GET", "/api/v1/drafts/", svc.api.Authenticate(svc.api.RequireScope("drafts:read", svc.api.Drafts.ListAttachments())))
+	r.Route("POST", "/api/v1/drafts/", svc.api.Authenticate(svc.api.RequireScope("drafts:write", svc.api.Drafts.PostSubresource())))
 
 	// Messages API
-	r.Route("POST", "/api/v1/messages/list", svc.api.Authenticate(svc.api.Messages.List()))
-	r.Route("POST", "/api/v1/messages/sync", svc.api.Authenticate(svc.api.Messages.Sync()))
-	r.Route("PATCH", "/api/v1/messages", svc.api.Authenticate(svc.api.Messages.Update()))
-	r.Route("POST", "/api/v1/messages/trash", svc.api.Authenticate(svc.api.Messages.Trash()))
-	r.Route("POST", "/api/v1/messages/untrash", svc.api.Authenticate(svc.api.Messages.Untrash()))
-	r.Route("DELETE", "/api/v1/messages/delete", svc.api.Authenticate(svc.api.Messages.Delete()))
-	r.Route("POST", "/api/v1/messages/submit", svc.api.Authenticate(svc.api.Messages.Submit()))
+	r.Route("POST", "/api/v1/messages/list", svc.api.Authenticate(svc.api.RequireScope("messages:read", svc.api.Messages.List())))
+	r.Route("POST", "/api/v1/messages/sync", svc.api.Authenticate(svc.api.RequireScope("messages:read", svc.api.Messages.Sync())))
+	r.Route("GET", "/api/v1/messages/trash", svc.api.Authenticate(svc.api.RequireScope("messages:read", svc.api.Messages.ListTrashed())))
+	r.Route("GET", "/api/v1/messages/counts", svc.api.Authenticate(svc.api.RequireScope("messages:read", svc.api.Messages.Counts())))
+	r.Route("PATCH", "/api/v1/messages", svc.api.Authenticate(svc.api.RequireScope("messages:write", svc.api.Messages.Update())))
+	r.Route("POST", "/api/v1/messages/read", svc.api.Authenticate(svc.api.RequireScope("messages:write", svc.api.Messages.Read())))
+	r.Route("POST", "/api/v1/messages/unread", svc.api.Authenticate(svc.api.RequireScope("messages:write", svc.api.Messages.Unread())))
+	r.Route("POST", "/api/v1/messages/modify", svc.api.Authenticate(svc.api.RequireScope("messages:write", svc.api.Messages.Modify())))
+	r.Route("POST", "/api/v1/messages/trash", svc.api.Authenticate(svc.api.RequireScope("messages:write", svc.api.Messages.Trash())))
+	r.Route("POST", "/api/v1/messages/untrash", svc.api.Authenticate(svc.api.RequireScope("messages:write", svc.api.Messages.Untrash())))
+	r.Route("DELETE", "/api/v1/messages/delete", svc.api.Authenticate(svc.api.RequireScope("messages:write", svc.api.Messages.Delete())))
+	r.Route("POST", "/api/v1/messages/submit", svc.api.Authenticate(svc.api.RequireScope("messages:write", svc.api.Messages.Submit())))
+	r.Route("GET", "/api/v1/messages/", svc.api.Authenticate(svc.api.RequireScope("messages:read", svc.api.Messages.Get())))
+
+	// Labels API
+	r.Route("POST", "/api/v1/labels", svc.api.Authenticate(svc.api.RequireScope("messages:write", svc.api.Idempotent(svc.api.Labels.Create()))))
+	r.Route("GET", "/api/v1/labels", svc.api.Authenticate(svc.api.RequireScope("messages:read", svc.api.Labels.List())))
+	r.Route("PUT", "/api/v1/labels", svc.api.Authenticate(svc.api.RequireScope("messages:write", svc.api.Labels.Update())))
+	r.Route("DELETE", "/api/v1/labels", svc.api.Authenticate(svc.api.RequireScope("messages:write", svc.api.Labels.Delete())))
+	r.Route("POST", "/api/v1/labels/sync", svc.api.Authenticate(svc.api.RequireScope("messages:read", svc.api.Labels.Sync())))
+
+	// JMAP API
+	r.Route("GET", "/.well-known/jmap", svc.api.Authenticate(svc.api.RequireScope("messages:read", svc.api.Jmap.Session())))
+	r.Route("POST", "/api/v1/jmap", svc.api.Authenticate(svc.api.RequireScope("messages:read", svc.api.Jmap.Api())))
+
+	// Federation API
+	r.Route("POST", "/api/v1/federation/deliver", svc.api.Federation.Deliver())
+	r.Route("GET", "/api/v1/federation/key", svc.api.Federation.Key())
 
 	// Threads API
-	r.Route("POST", "/api/v1/threads/list", svc.api.Authenticate(svc.api.Threads.List()))
-	r.Route("POST", "/api/v1/threads/trash", svc.api.Authenticate(svc.api.Threads.Trash()))
-	r.Route("POST", "/api/v1/threads/untrash", svc.api.Authenticate(svc.api.Threads.Untrash()))
-	r.Route("DELETE", "/api/v1/threads/delete", svc.api.Authenticate(svc.api.Threads.Delete()))
+	r.Route("POST", "/api/v1/threads/list", svc.api.Authenticate(svc.api.RequireScope("threads:read", svc.api.Threads.List())))
+	r.Route("GET", "/api/v1/threads", svc.api.Authenticate(svc.api.RequireScope("threads:read", svc.api.Threads.ListThreads())))
+	r.Route("GET", "/api/v1/threads/get", svc.api.Authenticate(svc.api.RequireScope("threads:read", svc.api.Threads.GetThread())))
+	r.Route("POST", "/api/v1/threads/trash", svc.api.Authenticate(svc.api.RequireScope("threads:write", svc.api.Threads.Trash())))
+	r.Route("POST", "/api/v1/threads/untrash", svc.api.Authenticate(svc.api.RequireScope("threads:write", svc.api.Threads.Untrash())))
+	r.Route("DELETE", "/api/v1/threads/delete", svc.api.Authenticate(svc.api.RequireScope("threads:write", svc.api.Threads.Delete())))
 }
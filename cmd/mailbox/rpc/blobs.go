@@ -0,0 +1,242 @@
+package rpc
+
+import (
+	"bufio"
+	"cargomail/cmd/mailbox/rpc/mailboxpb"
+	"cargomail/internal/mailbox/repository"
+	"cargomail/internal/mailbox/storage"
+	"cargomail/internal/shared/config"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// downloadChunkSize is the size of the chunks the content is downloaded by, well under the 4MB message
+// limit of gRPC.
+const downloadChunkSize = 64 << 10
+
+var errMissingBlobInfo = errors.New("the first message of the upload is not the blob info")
+
+type blobsServer struct {
+	mailboxpb.UnimplementedBlobsServer
+	useBlobRepository repository.UseBlobRepository
+	useBlobStorage    storage.UseBlobStorage
+}
+
+func (s *blobsServer) List(ctx context.Context, req *mailboxpb.ListRequest) (*mailboxpb.BlobList, error) {
+	user, err := contextUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	blobList, err := s.useBlobRepository.List(user, int(req.GetFolder()), listOptionsFromPb(req))
+	if err != nil {
+		return nil, rpcError(err)
+	}
+
+	return &mailboxpb.BlobList{
+		LastHistoryId: blobList.History,
+		Blobs:         blobsToPb(blobList.Blobs),
+	}, nil
+}
+
+func (s *blobsServer) Sync(ctx context.Context, req *mailboxpb.SyncRequest) (*mailboxpb.BlobSync, error) {
+	user, err := contextUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	blobSync, err := s.useBlobRepository.Sync(user, historyFromPb(req))
+	if err != nil {
+		return nil, rpcError(err)
+	}
+
+	pb := &mailboxpb.BlobSync{
+		LastHistoryId: blobSync.History,
+		NextPollAfter: int32(blobSync.NextPollAfter),
+		Inserted:      blobsToPb(blobSync.BlobsInserted),
+		Updated:       blobsToPb(blobSync.BlobsUpdated),
+		Trashed:       blobsToPb(blobSync.BlobsTrashed),
+	}
+
+	for _, deleted := range blobSync.BlobsDeleted {
+		pb.Deleted = append(pb.Deleted, &mailboxpb.Deleted{Id: deleted.Id})
+	}
+
+	return pb, nil
+}
+
+// Upload stores the content streamed by the chunks after the blob info, up to the upload limit of its
+// content type.
+func (s *blobsServer) Upload(stream mailboxpb.Blobs_UploadServer) error {
+	user, err := contextUser(stream.Context())
+	if err != nil {
+		return err
+	}
+
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+
+	info := req.GetInfo()
+	if info == nil {
+		return status.Error(codes.InvalidArgument, errMissingBlobInfo.Error())
+	}
+
+	max := config.MaxUploadBytesFor(info.GetContentType())
+
+	content, chunks := io.Pipe()
+	defer content.Close()
+
+	go func() {
+		var size int64
+
+		for {
+			req, err := stream.Recv()
+			if err == io.EOF {
+				chunks.Close()
+				return
+			}
+			if err != nil {
+				chunks.CloseWithError(err)
+				return
+			}
+
+			size += int64(len(req.GetChunk()))
+			if size > max {
+				chunks.CloseWithError(fmt.Errorf("%w: %s over %d bytes", repository.ErrUploadTooLarge, info.GetName(), max))
+				return
+			}
+
+			_, err = chunks.Write(req.GetChunk())
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	blob, err := s.useBlobStorage.Store(user, content, info.GetName(), info.GetContentType())
+	if err != nil {
+		return rpcError(err)
+	}
+
+	return stream.SendAndClose(blobToPb(blob))
+}
+
+// chunkSender sends the content written to it as the chunks of the download.
+type chunkSender struct {
+	stream mailboxpb.Blobs_DownloadServer
+}
+
+func (w *chunkSender) Write(p []byte) (int, error) {
+	written := 0
+
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > downloadChunkSize {
+			chunk = chunk[:downloadChunkSize]
+		}
+
+		err := w.stream.Send(&mailboxpb.DownloadBlobResponse{
+			Data: &mailboxpb.DownloadBlobResponse_Chunk{Chunk: chunk},
+		})
+		if err != nil {
+			return written, err
+		}
+
+		written += len(chunk)
+		p = p[len(chunk):]
+	}
+
+	return written, nil
+}
+
+// Download streams the blob of the digest, then its content by the chunks.
+func (s *blobsServer) Download(req *mailboxpb.DownloadBlobRequest, stream mailboxpb.Blobs_DownloadServer) error {
+	user, err := contextUser(stream.Context())
+	if err != nil {
+		return err
+	}
+
+	if len(req.GetDigest()) == 0 {
+		return rpcError(repository.ErrMissingDigestField)
+	}
+
+	blob, err := s.useBlobRepository.GetByDigest(user, req.GetDigest())
+	if err != nil {
+		return rpcError(err)
+	}
+
+	if len(blob.Digest) == 0 {
+		return rpcError(repository.ErrBlobNotFound)
+	}
+
+	err = stream.Send(&mailboxpb.DownloadBlobResponse{
+		Data: &mailboxpb.DownloadBlobResponse_Blob{Blob: blobToPb(blob)},
+	})
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriterSize(&chunkSender{stream: stream}, downloadChunkSize)
+
+	err = s.useBlobStorage.Load(w, blob)
+	if err != nil {
+		return rpcError(err)
+	}
+
+	return w.Flush()
+}
+
+func (s *blobsServer) Rename(ctx context.Context, req *mailboxpb.RenameBlobRequest) (*mailboxpb.Blob, error) {
+	user, err := contextUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(req.GetId()) == 0 {
+		return nil, rpcError(repository.ErrMissingIdField)
+	}
+
+	blob, err := s.useBlobRepository.GetById(user, req.GetId())
+	if err != nil {
+		return nil, rpcError(err)
+	}
+
+	if len(blob.Id) == 0 {
+		return nil, rpcError(repository.ErrBlobNotFound)
+	}
+
+	if len(strings.TrimSpace(req.GetName())) == 0 {
+		return nil, rpcError(repository.ErrBlobWrongName)
+	}
+
+	blob.Name = req.GetName()
+
+	blob, err = s.useBlobRepository.Update(user, blob)
+	if err != nil {
+		return nil, rpcError(err)
+	}
+
+	return blobToPb(blob), nil
+}
+
+func (s *blobsServer) Trash(ctx context.Context, req *mailboxpb.IdsRequest) (*emptypb.Empty, error) {
+	return modify(ctx, req, s.useBlobRepository.Trash)
+}
+
+func (s *blobsServer) Untrash(ctx context.Context, req *mailboxpb.IdsRequest) (*emptypb.Empty, error) {
+	return modify(ctx, req, s.useBlobRepository.Untrash)
+}
+
+// Delete deletes the blobs, and their content no other blob shares.
+func (s *blobsServer) Delete(ctx context.Context, req *mailboxpb.IdsRequest) (*emptypb.Empty, error) {
+	return modify(ctx, req, s.useBlobStorage.Delete)
+}
@@ -0,0 +1,101 @@
+package rpc
+
+import (
+	"cargomail/cmd/mailbox/rpc/mailboxpb"
+	"cargomail/internal/mailbox/repository"
+	"context"
+
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type contactsServer struct {
+	mailboxpb.UnimplementedContactsServer
+	useContactRepository repository.UseContactRepository
+}
+
+func (s *contactsServer) List(ctx context.Context, req *mailboxpb.ListRequest) (*mailboxpb.ContactList, error) {
+	user, err := contextUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	contactList, err := s.useContactRepository.List(user, listOptionsFromPb(req))
+	if err != nil {
+		return nil, rpcError(err)
+	}
+
+	return &mailboxpb.ContactList{
+		LastHistoryId: contactList.History,
+		Contacts:      contactsToPb(contactList.Contacts),
+	}, nil
+}
+
+func (s *contactsServer) Sync(ctx context.Context, req *mailboxpb.SyncRequest) (*mailboxpb.ContactSync, error) {
+	user, err := contextUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	contactSync, err := s.useContactRepository.Sync(user, historyFromPb(req))
+	if err != nil {
+		return nil, rpcError(err)
+	}
+
+	pb := &mailboxpb.ContactSync{
+		LastHistoryId: contactSync.History,
+		NextPollAfter: int32(contactSync.NextPollAfter),
+		Inserted:      contactsToPb(contactSync.ContactsInserted),
+		Updated:       contactsToPb(contactSync.ContactsUpdated),
+		Trashed:       contactsToPb(contactSync.ContactsTrashed),
+	}
+
+	for _, deleted := range contactSync.ContactsDeleted {
+		pb.Deleted = append(pb.Deleted, &mailboxpb.Deleted{Id: deleted.Id})
+	}
+
+	return pb, nil
+}
+
+func (s *contactsServer) Create(ctx context.Context, req *mailboxpb.Contact) (*mailboxpb.Contact, error) {
+	user, err := contextUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	contact, err := s.useContactRepository.Create(user, contactFromPb(req))
+	if err != nil {
+		return nil, rpcError(err)
+	}
+
+	return contactToPb(contact), nil
+}
+
+func (s *contactsServer) Update(ctx context.Context, req *mailboxpb.Contact) (*mailboxpb.Contact, error) {
+	user, err := contextUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(req.GetId()) == 0 {
+		return nil, rpcError(repository.ErrMissingIdField)
+	}
+
+	contact, err := s.useContactRepository.Update(user, contactFromPb(req))
+	if err != nil {
+		return nil, rpcError(err)
+	}
+
+	return contactToPb(contact), nil
+}
+
+func (s *contactsServer) Trash(ctx context.Context, req *mailboxpb.IdsRequest) (*emptypb.Empty, error) {
+	return modify(ctx, req, s.useContactRepository.Trash)
+}
+
+func (s *contactsServer) Untrash(ctx context.Context, req *mailboxpb.IdsRequest) (*emptypb.Empty, error) {
+	return modify(ctx, req, s.useContactRepository.Untrash)
+}
+
+func (s *contactsServer) Delete(ctx context.Context, req *mailboxpb.IdsRequest) (*emptypb.Empty, error) {
+	return modify(ctx, req, s.useContactRepository.Delete)
+}
@@ -0,0 +1,262 @@
+package rpc
+
+import (
+	"cargomail/cmd/mailbox/rpc/mailboxpb"
+	"cargomail/internal/mailbox/repository"
+	"encoding/json"
+	"time"
+
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func timestampToPb(t repository.Timestamp) *timestamppb.Timestamp {
+	return timestamppb.New(t.Time())
+}
+
+func optionalTimestampToPb(t *repository.Timestamp) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+
+	return timestampToPb(*t)
+}
+
+func timeFromPb(t *timestamppb.Timestamp) *time.Time {
+	if t == nil {
+		return nil
+	}
+
+	value := t.AsTime()
+
+	return &value
+}
+
+func listOptionsFromPb(req *mailboxpb.ListRequest) *repository.ListOptions {
+	return &repository.ListOptions{
+		Sort:        req.GetSort(),
+		Order:       req.GetOrder(),
+		ContentType: req.GetContentType(),
+
+		CreatedAfter:  timeFromPb(req.GetCreatedAfter()),
+		CreatedBefore: timeFromPb(req.GetCreatedBefore()),
+		ModifiedAfter: timeFromPb(req.GetModifiedAfter()),
+	}
+}
+
+func historyFromPb(req *mailboxpb.SyncRequest) *repository.History {
+	history := &repository.History{
+		Id:           req.GetHistoryId(),
+		IgnoreDevice: req.GetIgnoreDevice(),
+	}
+
+	if req.GetSince() != nil {
+		since := repository.Timestamp(req.GetSince().AsTime().UnixMilli())
+		history.Since = &since
+	}
+
+	return history
+}
+
+// idsFromPb returns the ids as the JSON the Trash, Untrash and Delete of the repositories take.
+func idsFromPb(req *mailboxpb.IdsRequest) (string, error) {
+	if len(req.GetIds()) == 0 {
+		return "", repository.ErrMissingIdsField
+	}
+
+	body, err := json.Marshal(&repository.Ids{Ids: req.GetIds()})
+	if err != nil {
+		return "", err
+	}
+
+	return string(body), nil
+}
+
+// contacts
+
+func contactToPb(contact *repository.Contact) *mailboxpb.Contact {
+	pb := &mailboxpb.Contact{
+		Id:           contact.Id,
+		EmailAddress: contact.EmailAddress,
+		FirstName:    contact.FirstName,
+		LastName:     contact.LastName,
+		CreatedAt:    timestampToPb(contact.CreatedAt),
+		ModifiedAt:   optionalTimestampToPb(contact.ModifiedAt),
+		Notes:        contact.Notes,
+	}
+
+	for _, email := range contact.EmailAddresses {
+		pb.EmailAddresses = append(pb.EmailAddresses, &mailboxpb.ContactEmail{
+			Address: email.Address,
+			Type:    email.Type,
+			Primary: email.Primary,
+		})
+	}
+
+	for _, phone := range contact.PhoneNumbers {
+		pb.PhoneNumbers = append(pb.PhoneNumbers, &mailboxpb.ContactPhone{
+			Number: phone.Number,
+			Type:   phone.Type,
+		})
+	}
+
+	return pb
+}
+
+func contactFromPb(pb *mailboxpb.Contact) *repository.Contact {
+	contact := &repository.Contact{
+		Id:           pb.GetId(),
+		EmailAddress: pb.EmailAddress,
+		FirstName:    pb.FirstName,
+		LastName:     pb.LastName,
+		Notes:        pb.Notes,
+	}
+
+	for _, email := range pb.GetEmailAddresses() {
+		contact.EmailAddresses = append(contact.EmailAddresses, &repository.ContactEmail{
+			Address: email.GetAddress(),
+			Type:    email.GetType(),
+			Primary: email.GetPrimary(),
+		})
+	}
+
+	for _, phone := range pb.GetPhoneNumbers() {
+		contact.PhoneNumbers = append(contact.PhoneNumbers, &repository.ContactPhone{
+			Number: phone.GetNumber(),
+			Type:   phone.GetType(),
+		})
+	}
+
+	return contact
+}
+
+func contactsToPb(contacts []*repository.Contact) []*mailboxpb.Contact {
+	pbs := make([]*mailboxpb.Contact, 0, len(contacts))
+
+	for _, contact := range contacts {
+		pbs = append(pbs, contactToPb(contact))
+	}
+
+	return pbs
+}
+
+// drafts
+
+func messagePartToPb(part *repository.MessagePart) (*mailboxpb.MessagePart, error) {
+	if part == nil {
+		return nil, nil
+	}
+
+	pb := &mailboxpb.MessagePart{}
+
+	if part.Headers != nil {
+		headers, err := structpb.NewStruct(part.Headers)
+		if err != nil {
+			return nil, err
+		}
+		pb.Headers = headers
+	}
+
+	if part.Body != nil {
+		pb.Body = &mailboxpb.Body{Data: part.Body.Data}
+	}
+
+	for _, child := range part.Parts {
+		childPb, err := messagePartToPb(child)
+		if err != nil {
+			return nil, err
+		}
+		pb.Parts = append(pb.Parts, childPb)
+	}
+
+	return pb, nil
+}
+
+func messagePartFromPb(pb *mailboxpb.MessagePart) *repository.MessagePart {
+	if pb == nil {
+		return nil
+	}
+
+	part := &repository.MessagePart{}
+
+	if pb.GetHeaders() != nil {
+		part.Headers = pb.GetHeaders().AsMap()
+	}
+
+	if pb.GetBody() != nil {
+		part.Body = &repository.Body{Data: pb.GetBody().GetData()}
+	}
+
+	for _, child := range pb.GetParts() {
+		part.Parts = append(part.Parts, messagePartFromPb(child))
+	}
+
+	return part
+}
+
+func draftToPb(draft *repository.Draft) (*mailboxpb.Draft, error) {
+	payload, err := messagePartToPb(draft.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mailboxpb.Draft{
+		Id:         draft.Id,
+		Unread:     draft.Unread,
+		Starred:    draft.Starred,
+		Payload:    payload,
+		LabelIds:   draft.LabelIds,
+		CreatedAt:  timestampToPb(draft.CreatedAt),
+		ModifiedAt: optionalTimestampToPb(draft.ModifiedAt),
+	}, nil
+}
+
+func draftFromPb(pb *mailboxpb.Draft) *repository.Draft {
+	return &repository.Draft{
+		Id:       pb.GetId(),
+		Unread:   pb.GetUnread(),
+		Starred:  pb.GetStarred(),
+		Payload:  messagePartFromPb(pb.GetPayload()),
+		LabelIds: pb.LabelIds,
+	}
+}
+
+func draftsToPb(drafts []*repository.Draft) ([]*mailboxpb.Draft, error) {
+	pbs := make([]*mailboxpb.Draft, 0, len(drafts))
+
+	for _, draft := range drafts {
+		pb, err := draftToPb(draft)
+		if err != nil {
+			return nil, err
+		}
+		pbs = append(pbs, pb)
+	}
+
+	return pbs, nil
+}
+
+// blobs
+
+func blobToPb(blob *repository.Blob) *mailboxpb.Blob {
+	return &mailboxpb.Blob{
+		Id:          blob.Id,
+		Folder:      int32(blob.Folder),
+		Digest:      blob.Digest,
+		Name:        blob.Name,
+		Snippet:     blob.Snippet,
+		Size:        blob.Size,
+		ContentType: blob.ContentType,
+		CreatedAt:   timestampToPb(blob.CreatedAt),
+		ModifiedAt:  optionalTimestampToPb(blob.ModifiedAt),
+	}
+}
+
+func blobsToPb(blobs []*repository.Blob) []*mailboxpb.Blob {
+	pbs := make([]*mailboxpb.Blob, 0, len(blobs))
+
+	for _, blob := range blobs {
+		pbs = append(pbs, blobToPb(blob))
+	}
+
+	return pbs
+}
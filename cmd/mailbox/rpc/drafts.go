@@ -0,0 +1,134 @@
+package rpc
+
+import (
+	"cargomail/cmd/mailbox/rpc/mailboxpb"
+	"cargomail/internal/mailbox/repository"
+	"cargomail/internal/mailbox/storage"
+	"context"
+
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type draftsServer struct {
+	mailboxpb.UnimplementedDraftsServer
+	useDraftRepository repository.UseDraftRepository
+	useDraftStorage    storage.UseDraftStorage
+}
+
+func (s *draftsServer) List(ctx context.Context, req *mailboxpb.ListRequest) (*mailboxpb.DraftList, error) {
+	user, err := contextUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	draftList, err := s.useDraftStorage.List(user, listOptionsFromPb(req))
+	if err != nil {
+		return nil, rpcError(err)
+	}
+
+	drafts, err := draftsToPb(draftList.Drafts)
+	if err != nil {
+		return nil, rpcError(err)
+	}
+
+	return &mailboxpb.DraftList{
+		LastHistoryId: draftList.History,
+		Drafts:        drafts,
+	}, nil
+}
+
+func (s *draftsServer) Sync(ctx context.Context, req *mailboxpb.SyncRequest) (*mailboxpb.DraftSync, error) {
+	user, err := contextUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	draftSync, err := s.useDraftStorage.Sync(user, historyFromPb(req))
+	if err != nil {
+		return nil, rpcError(err)
+	}
+
+	pb := &mailboxpb.DraftSync{
+		LastHistoryId: draftSync.History,
+		NextPollAfter: int32(draftSync.NextPollAfter),
+	}
+
+	for _, changed := range []struct {
+		drafts []*repository.Draft
+		pbs    *[]*mailboxpb.Draft
+	}{
+		{draftSync.DraftsInserted, &pb.Inserted},
+		{draftSync.DraftsUpdated, &pb.Updated},
+		{draftSync.DraftsTrashed, &pb.Trashed},
+	} {
+		*changed.pbs, err = draftsToPb(changed.drafts)
+		if err != nil {
+			return nil, rpcError(err)
+		}
+	}
+
+	for _, deleted := range draftSync.DraftsDeleted {
+		pb.Deleted = append(pb.Deleted, &mailboxpb.Deleted{Id: deleted.Id})
+	}
+
+	return pb, nil
+}
+
+func (s *draftsServer) Create(ctx context.Context, req *mailboxpb.Draft) (*mailboxpb.Draft, error) {
+	user, err := contextUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	draft, err := s.useDraftStorage.Create(user, draftFromPb(req))
+	if err != nil {
+		return nil, rpcError(err)
+	}
+
+	pb, err := draftToPb(draft)
+	if err != nil {
+		return nil, rpcError(err)
+	}
+
+	return pb, nil
+}
+
+func (s *draftsServer) Update(ctx context.Context, req *mailboxpb.Draft) (*mailboxpb.Draft, error) {
+	user, err := contextUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case len(req.GetId()) == 0:
+		return nil, rpcError(repository.ErrMissingIdField)
+	case req.GetPayload() == nil:
+		return nil, rpcError(repository.ErrMissingPayloadField)
+	case req.GetPayload().GetHeaders() == nil:
+		return nil, rpcError(repository.ErrMissingHeadersField)
+	}
+
+	draft, err := s.useDraftStorage.Update(user, draftFromPb(req))
+	if err != nil {
+		return nil, rpcError(err)
+	}
+
+	pb, err := draftToPb(draft)
+	if err != nil {
+		return nil, rpcError(err)
+	}
+
+	return pb, nil
+}
+
+func (s *draftsServer) Trash(ctx context.Context, req *mailboxpb.IdsRequest) (*emptypb.Empty, error) {
+	return modify(ctx, req, s.useDraftRepository.Trash)
+}
+
+func (s *draftsServer) Untrash(ctx context.Context, req *mailboxpb.IdsRequest) (*emptypb.Empty, error) {
+	return modify(ctx, req, s.useDraftRepository.Untrash)
+}
+
+func (s *draftsServer) Delete(ctx context.Context, req *mailboxpb.IdsRequest) (*emptypb.Empty, error) {
+	return modify(ctx, req, s.useDraftRepository.Delete)
+}
@@ -0,0 +1,2347 @@
+// The gRPC surface of the mailbox, next to the REST one. The messages mirror the structs of the
+// repository, the nullable fields are optional. The calls are authenticated by the API key sent as the
+// "authorization: Bearer <key>" metadata, and limited to its scopes like the REST calls are.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.31.0
+// 	protoc        (unknown)
+// source: mailboxpb/mailbox.proto
+
+package mailboxpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// ListRequest are the paging, sorting and filtering parameters of the lists, the folder applies to the
+// blobs only.
+type ListRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Folder        int32                  `protobuf:"varint,1,opt,name=folder,proto3" json:"folder,omitempty"`
+	Sort          string                 `protobuf:"bytes,2,opt,name=sort,proto3" json:"sort,omitempty"`
+	Order         string                 `protobuf:"bytes,3,opt,name=order,proto3" json:"order,omitempty"`
+	CreatedAfter  *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=created_after,json=createdAfter,proto3" json:"created_after,omitempty"`
+	CreatedBefore *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=created_before,json=createdBefore,proto3" json:"created_before,omitempty"`
+	ModifiedAfter *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=modified_after,json=modifiedAfter,proto3" json:"modified_after,omitempty"`
+	ContentType   string                 `protobuf:"bytes,7,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+}
+
+func (x *ListRequest) Reset() {
+	*x = ListRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_mailboxpb_mailbox_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRequest) ProtoMessage() {}
+
+func (x *ListRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_mailboxpb_mailbox_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRequest.ProtoReflect.Descriptor instead.
+func (*ListRequest) Descriptor() ([]byte, []int) {
+	return file_mailboxpb_mailbox_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ListRequest) GetFolder() int32 {
+	if x != nil {
+		return x.Folder
+	}
+	return 0
+}
+
+func (x *ListRequest) GetSort() string {
+	if x != nil {
+		return x.Sort
+	}
+	return ""
+}
+
+func (x *ListRequest) GetOrder() string {
+	if x != nil {
+		return x.Order
+	}
+	return ""
+}
+
+func (x *ListRequest) GetCreatedAfter() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAfter
+	}
+	return nil
+}
+
+func (x *ListRequest) GetCreatedBefore() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedBefore
+	}
+	return nil
+}
+
+func (x *ListRequest) GetModifiedAfter() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ModifiedAfter
+	}
+	return nil
+}
+
+func (x *ListRequest) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+type SyncRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	HistoryId    int64                  `protobuf:"varint,1,opt,name=history_id,json=historyId,proto3" json:"history_id,omitempty"`
+	IgnoreDevice bool                   `protobuf:"varint,2,opt,name=ignore_device,json=ignoreDevice,proto3" json:"ignore_device,omitempty"`
+	Since        *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=since,proto3" json:"since,omitempty"`
+}
+
+func (x *SyncRequest) Reset() {
+	*x = SyncRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_mailboxpb_mailbox_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SyncRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SyncRequest) ProtoMessage() {}
+
+func (x *SyncRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_mailboxpb_mailbox_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SyncRequest.ProtoReflect.Descriptor instead.
+func (*SyncRequest) Descriptor() ([]byte, []int) {
+	return file_mailboxpb_mailbox_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *SyncRequest) GetHistoryId() int64 {
+	if x != nil {
+		return x.HistoryId
+	}
+	return 0
+}
+
+func (x *SyncRequest) GetIgnoreDevice() bool {
+	if x != nil {
+		return x.IgnoreDevice
+	}
+	return false
+}
+
+func (x *SyncRequest) GetSince() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Since
+	}
+	return nil
+}
+
+type IdsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Ids []string `protobuf:"bytes,1,rep,name=ids,proto3" json:"ids,omitempty"`
+}
+
+func (x *IdsRequest) Reset() {
+	*x = IdsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_mailboxpb_mailbox_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *IdsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IdsRequest) ProtoMessage() {}
+
+func (x *IdsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_mailboxpb_mailbox_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IdsRequest.ProtoReflect.Descriptor instead.
+func (*IdsRequest) Descriptor() ([]byte, []int) {
+	return file_mailboxpb_mailbox_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *IdsRequest) GetIds() []string {
+	if x != nil {
+		return x.Ids
+	}
+	return nil
+}
+
+type Deleted struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+}
+
+func (x *Deleted) Reset() {
+	*x = Deleted{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_mailboxpb_mailbox_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Deleted) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Deleted) ProtoMessage() {}
+
+func (x *Deleted) ProtoReflect() protoreflect.Message {
+	mi := &file_mailboxpb_mailbox_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Deleted.ProtoReflect.Descriptor instead.
+func (*Deleted) Descriptor() ([]byte, []int) {
+	return file_mailboxpb_mailbox_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Deleted) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type ContactEmail struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Address string `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+	Type    string `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Primary bool   `protobuf:"varint,3,opt,name=primary,proto3" json:"primary,omitempty"`
+}
+
+func (x *ContactEmail) Reset() {
+	*x = ContactEmail{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_mailboxpb_mailbox_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ContactEmail) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ContactEmail) ProtoMessage() {}
+
+func (x *ContactEmail) ProtoReflect() protoreflect.Message {
+	mi := &file_mailboxpb_mailbox_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ContactEmail.ProtoReflect.Descriptor instead.
+func (*ContactEmail) Descriptor() ([]byte, []int) {
+	return file_mailboxpb_mailbox_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ContactEmail) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *ContactEmail) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *ContactEmail) GetPrimary() bool {
+	if x != nil {
+		return x.Primary
+	}
+	return false
+}
+
+type ContactPhone struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Number string `protobuf:"bytes,1,opt,name=number,proto3" json:"number,omitempty"`
+	Type   string `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+}
+
+func (x *ContactPhone) Reset() {
+	*x = ContactPhone{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_mailboxpb_mailbox_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ContactPhone) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ContactPhone) ProtoMessage() {}
+
+func (x *ContactPhone) ProtoReflect() protoreflect.Message {
+	mi := &file_mailboxpb_mailbox_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ContactPhone.ProtoReflect.Descriptor instead.
+func (*ContactPhone) Descriptor() ([]byte, []int) {
+	return file_mailboxpb_mailbox_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ContactPhone) GetNumber() string {
+	if x != nil {
+		return x.Number
+	}
+	return ""
+}
+
+func (x *ContactPhone) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+type Contact struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id             string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	EmailAddress   *string                `protobuf:"bytes,2,opt,name=email_address,json=emailAddress,proto3,oneof" json:"email_address,omitempty"`
+	FirstName      *string                `protobuf:"bytes,3,opt,name=first_name,json=firstName,proto3,oneof" json:"first_name,omitempty"`
+	LastName       *string                `protobuf:"bytes,4,opt,name=last_name,json=lastName,proto3,oneof" json:"last_name,omitempty"`
+	CreatedAt      *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	ModifiedAt     *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=modified_at,json=modifiedAt,proto3" json:"modified_at,omitempty"`
+	EmailAddresses []*ContactEmail        `protobuf:"bytes,7,rep,name=email_addresses,json=emailAddresses,proto3" json:"email_addresses,omitempty"`
+	PhoneNumbers   []*ContactPhone        `protobuf:"bytes,8,rep,name=phone_numbers,json=phoneNumbers,proto3" json:"phone_numbers,omitempty"`
+	Notes          *string                `protobuf:"bytes,9,opt,name=notes,proto3,oneof" json:"notes,omitempty"`
+}
+
+func (x *Contact) Reset() {
+	*x = Contact{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_mailboxpb_mailbox_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Contact) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Contact) ProtoMessage() {}
+
+func (x *Contact) ProtoReflect() protoreflect.Message {
+	mi := &file_mailboxpb_mailbox_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Contact.ProtoReflect.Descriptor instead.
+func (*Contact) Descriptor() ([]byte, []int) {
+	return file_mailboxpb_mailbox_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Contact) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Contact) GetEmailAddress() string {
+	if x != nil && x.EmailAddress != nil {
+		return *x.EmailAddress
+	}
+	return ""
+}
+
+func (x *Contact) GetFirstName() string {
+	if x != nil && x.FirstName != nil {
+		return *x.FirstName
+	}
+	return ""
+}
+
+func (x *Contact) GetLastName() string {
+	if x != nil && x.LastName != nil {
+		return *x.LastName
+	}
+	return ""
+}
+
+func (x *Contact) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Contact) GetModifiedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ModifiedAt
+	}
+	return nil
+}
+
+func (x *Contact) GetEmailAddresses() []*ContactEmail {
+	if x != nil {
+		return x.EmailAddresses
+	}
+	return nil
+}
+
+func (x *Contact) GetPhoneNumbers() []*ContactPhone {
+	if x != nil {
+		return x.PhoneNumbers
+	}
+	return nil
+}
+
+func (x *Contact) GetNotes() string {
+	if x != nil && x.Notes != nil {
+		return *x.Notes
+	}
+	return ""
+}
+
+type ContactList struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	LastHistoryId int64      `protobuf:"varint,1,opt,name=last_history_id,json=lastHistoryId,proto3" json:"last_history_id,omitempty"`
+	Contacts      []*Contact `protobuf:"bytes,2,rep,name=contacts,proto3" json:"contacts,omitempty"`
+}
+
+func (x *ContactList) Reset() {
+	*x = ContactList{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_mailboxpb_mailbox_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ContactList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ContactList) ProtoMessage() {}
+
+func (x *ContactList) ProtoReflect() protoreflect.Message {
+	mi := &file_mailboxpb_mailbox_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ContactList.ProtoReflect.Descriptor instead.
+func (*ContactList) Descriptor() ([]byte, []int) {
+	return file_mailboxpb_mailbox_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ContactList) GetLastHistoryId() int64 {
+	if x != nil {
+		return x.LastHistoryId
+	}
+	return 0
+}
+
+func (x *ContactList) GetContacts() []*Contact {
+	if x != nil {
+		return x.Contacts
+	}
+	return nil
+}
+
+type ContactSync struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	LastHistoryId int64      `protobuf:"varint,1,opt,name=last_history_id,json=lastHistoryId,proto3" json:"last_history_id,omitempty"`
+	NextPollAfter int32      `protobuf:"varint,2,opt,name=next_poll_after,json=nextPollAfter,proto3" json:"next_poll_after,omitempty"`
+	Inserted      []*Contact `protobuf:"bytes,3,rep,name=inserted,proto3" json:"inserted,omitempty"`
+	Updated       []*Contact `protobuf:"bytes,4,rep,name=updated,proto3" json:"updated,omitempty"`
+	Trashed       []*Contact `protobuf:"bytes,5,rep,name=trashed,proto3" json:"trashed,omitempty"`
+	Deleted       []*Deleted `protobuf:"bytes,6,rep,name=deleted,proto3" json:"deleted,omitempty"`
+}
+
+func (x *ContactSync) Reset() {
+	*x = ContactSync{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_mailboxpb_mailbox_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ContactSync) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ContactSync) ProtoMessage() {}
+
+func (x *ContactSync) ProtoReflect() protoreflect.Message {
+	mi := &file_mailboxpb_mailbox_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ContactSync.ProtoReflect.Descriptor instead.
+func (*ContactSync) Descriptor() ([]byte, []int) {
+	return file_mailboxpb_mailbox_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ContactSync) GetLastHistoryId() int64 {
+	if x != nil {
+		return x.LastHistoryId
+	}
+	return 0
+}
+
+func (x *ContactSync) GetNextPollAfter() int32 {
+	if x != nil {
+		return x.NextPollAfter
+	}
+	return 0
+}
+
+func (x *ContactSync) GetInserted() []*Contact {
+	if x != nil {
+		return x.Inserted
+	}
+	return nil
+}
+
+func (x *ContactSync) GetUpdated() []*Contact {
+	if x != nil {
+		return x.Updated
+	}
+	return nil
+}
+
+func (x *ContactSync) GetTrashed() []*Contact {
+	if x != nil {
+		return x.Trashed
+	}
+	return nil
+}
+
+func (x *ContactSync) GetDeleted() []*Deleted {
+	if x != nil {
+		return x.Deleted
+	}
+	return nil
+}
+
+type Body struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Data string `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+}
+
+func (x *Body) Reset() {
+	*x = Body{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_mailboxpb_mailbox_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Body) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Body) ProtoMessage() {}
+
+func (x *Body) ProtoReflect() protoreflect.Message {
+	mi := &file_mailboxpb_mailbox_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Body.ProtoReflect.Descriptor instead.
+func (*Body) Descriptor() ([]byte, []int) {
+	return file_mailboxpb_mailbox_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Body) GetData() string {
+	if x != nil {
+		return x.Data
+	}
+	return ""
+}
+
+// MessagePart is the MIME part of the draft, the headers are the JSON of the REST payload.
+type MessagePart struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Headers *structpb.Struct `protobuf:"bytes,1,opt,name=headers,proto3" json:"headers,omitempty"`
+	Body    *Body            `protobuf:"bytes,2,opt,name=body,proto3" json:"body,omitempty"`
+	Parts   []*MessagePart   `protobuf:"bytes,3,rep,name=parts,proto3" json:"parts,omitempty"`
+}
+
+func (x *MessagePart) Reset() {
+	*x = MessagePart{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_mailboxpb_mailbox_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MessagePart) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MessagePart) ProtoMessage() {}
+
+func (x *MessagePart) ProtoReflect() protoreflect.Message {
+	mi := &file_mailboxpb_mailbox_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MessagePart.ProtoReflect.Descriptor instead.
+func (*MessagePart) Descriptor() ([]byte, []int) {
+	return file_mailboxpb_mailbox_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *MessagePart) GetHeaders() *structpb.Struct {
+	if x != nil {
+		return x.Headers
+	}
+	return nil
+}
+
+func (x *MessagePart) GetBody() *Body {
+	if x != nil {
+		return x.Body
+	}
+	return nil
+}
+
+func (x *MessagePart) GetParts() []*MessagePart {
+	if x != nil {
+		return x.Parts
+	}
+	return nil
+}
+
+type Draft struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id         string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Unread     bool                   `protobuf:"varint,2,opt,name=unread,proto3" json:"unread,omitempty"`
+	Starred    bool                   `protobuf:"varint,3,opt,name=starred,proto3" json:"starred,omitempty"`
+	Payload    *MessagePart           `protobuf:"bytes,4,opt,name=payload,proto3" json:"payload,omitempty"`
+	LabelIds   *string                `protobuf:"bytes,5,opt,name=label_ids,json=labelIds,proto3,oneof" json:"label_ids,omitempty"`
+	CreatedAt  *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	ModifiedAt *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=modified_at,json=modifiedAt,proto3" json:"modified_at,omitempty"`
+}
+
+func (x *Draft) Reset() {
+	*x = Draft{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_mailboxpb_mailbox_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Draft) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Draft) ProtoMessage() {}
+
+func (x *Draft) ProtoReflect() protoreflect.Message {
+	mi := &file_mailboxpb_mailbox_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Draft.ProtoReflect.Descriptor instead.
+func (*Draft) Descriptor() ([]byte, []int) {
+	return file_mailboxpb_mailbox_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *Draft) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Draft) GetUnread() bool {
+	if x != nil {
+		return x.Unread
+	}
+	return false
+}
+
+func (x *Draft) GetStarred() bool {
+	if x != nil {
+		return x.Starred
+	}
+	return false
+}
+
+func (x *Draft) GetPayload() *MessagePart {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *Draft) GetLabelIds() string {
+	if x != nil && x.LabelIds != nil {
+		return *x.LabelIds
+	}
+	return ""
+}
+
+func (x *Draft) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Draft) GetModifiedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ModifiedAt
+	}
+	return nil
+}
+
+type DraftList struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	LastHistoryId int64    `protobuf:"varint,1,opt,name=last_history_id,json=lastHistoryId,proto3" json:"last_history_id,omitempty"`
+	Drafts        []*Draft `protobuf:"bytes,2,rep,name=drafts,proto3" json:"drafts,omitempty"`
+}
+
+func (x *DraftList) Reset() {
+	*x = DraftList{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_mailboxpb_mailbox_proto_msgTypes[12]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DraftList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DraftList) ProtoMessage() {}
+
+func (x *DraftList) ProtoReflect() protoreflect.Message {
+	mi := &file_mailboxpb_mailbox_proto_msgTypes[12]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DraftList.ProtoReflect.Descriptor instead.
+func (*DraftList) Descriptor() ([]byte, []int) {
+	return file_mailboxpb_mailbox_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *DraftList) GetLastHistoryId() int64 {
+	if x != nil {
+		return x.LastHistoryId
+	}
+	return 0
+}
+
+func (x *DraftList) GetDrafts() []*Draft {
+	if x != nil {
+		return x.Drafts
+	}
+	return nil
+}
+
+type DraftSync struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	LastHistoryId int64      `protobuf:"varint,1,opt,name=last_history_id,json=lastHistoryId,proto3" json:"last_history_id,omitempty"`
+	NextPollAfter int32      `protobuf:"varint,2,opt,name=next_poll_after,json=nextPollAfter,proto3" json:"next_poll_after,omitempty"`
+	Inserted      []*Draft   `protobuf:"bytes,3,rep,name=inserted,proto3" json:"inserted,omitempty"`
+	Updated       []*Draft   `protobuf:"bytes,4,rep,name=updated,proto3" json:"updated,omitempty"`
+	Trashed       []*Draft   `protobuf:"bytes,5,rep,name=trashed,proto3" json:"trashed,omitempty"`
+	Deleted       []*Deleted `protobuf:"bytes,6,rep,name=deleted,proto3" json:"deleted,omitempty"`
+}
+
+func (x *DraftSync) Reset() {
+	*x = DraftSync{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_mailboxpb_mailbox_proto_msgTypes[13]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DraftSync) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DraftSync) ProtoMessage() {}
+
+func (x *DraftSync) ProtoReflect() protoreflect.Message {
+	mi := &file_mailboxpb_mailbox_proto_msgTypes[13]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DraftSync.ProtoReflect.Descriptor instead.
+func (*DraftSync) Descriptor() ([]byte, []int) {
+	return file_mailboxpb_mailbox_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *DraftSync) GetLastHistoryId() int64 {
+	if x != nil {
+		return x.LastHistoryId
+	}
+	return 0
+}
+
+func (x *DraftSync) GetNextPollAfter() int32 {
+	if x != nil {
+		return x.NextPollAfter
+	}
+	return 0
+}
+
+func (x *DraftSync) GetInserted() []*Draft {
+	if x != nil {
+		return x.Inserted
+	}
+	return nil
+}
+
+func (x *DraftSync) GetUpdated() []*Draft {
+	if x != nil {
+		return x.Updated
+	}
+	return nil
+}
+
+func (x *DraftSync) GetTrashed() []*Draft {
+	if x != nil {
+		return x.Trashed
+	}
+	return nil
+}
+
+func (x *DraftSync) GetDeleted() []*Deleted {
+	if x != nil {
+		return x.Deleted
+	}
+	return nil
+}
+
+type Blob struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id          string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Folder      int32                  `protobuf:"varint,2,opt,name=folder,proto3" json:"folder,omitempty"`
+	Digest      string                 `protobuf:"bytes,3,opt,name=digest,proto3" json:"digest,omitempty"`
+	Name        string                 `protobuf:"bytes,4,opt,name=name,proto3" json:"name,omitempty"`
+	Snippet     *string                `protobuf:"bytes,5,opt,name=snippet,proto3,oneof" json:"snippet,omitempty"`
+	Size        int64                  `protobuf:"varint,6,opt,name=size,proto3" json:"size,omitempty"`
+	ContentType string                 `protobuf:"bytes,7,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	CreatedAt   *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	ModifiedAt  *timestamppb.Timestamp `protobuf:"bytes,9,opt,name=modified_at,json=modifiedAt,proto3" json:"modified_at,omitempty"`
+}
+
+func (x *Blob) Reset() {
+	*x = Blob{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_mailboxpb_mailbox_proto_msgTypes[14]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Blob) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Blob) ProtoMessage() {}
+
+func (x *Blob) ProtoReflect() protoreflect.Message {
+	mi := &file_mailboxpb_mailbox_proto_msgTypes[14]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Blob.ProtoReflect.Descriptor instead.
+func (*Blob) Descriptor() ([]byte, []int) {
+	return file_mailboxpb_mailbox_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *Blob) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Blob) GetFolder() int32 {
+	if x != nil {
+		return x.Folder
+	}
+	return 0
+}
+
+func (x *Blob) GetDigest() string {
+	if x != nil {
+		return x.Digest
+	}
+	return ""
+}
+
+func (x *Blob) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Blob) GetSnippet() string {
+	if x != nil && x.Snippet != nil {
+		return *x.Snippet
+	}
+	return ""
+}
+
+func (x *Blob) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *Blob) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+func (x *Blob) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Blob) GetModifiedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ModifiedAt
+	}
+	return nil
+}
+
+type BlobList struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	LastHistoryId int64   `protobuf:"varint,1,opt,name=last_history_id,json=lastHistoryId,proto3" json:"last_history_id,omitempty"`
+	Blobs         []*Blob `protobuf:"bytes,2,rep,name=blobs,proto3" json:"blobs,omitempty"`
+}
+
+func (x *BlobList) Reset() {
+	*x = BlobList{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_mailboxpb_mailbox_proto_msgTypes[15]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BlobList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BlobList) ProtoMessage() {}
+
+func (x *BlobList) ProtoReflect() protoreflect.Message {
+	mi := &file_mailboxpb_mailbox_proto_msgTypes[15]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BlobList.ProtoReflect.Descriptor instead.
+func (*BlobList) Descriptor() ([]byte, []int) {
+	return file_mailboxpb_mailbox_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *BlobList) GetLastHistoryId() int64 {
+	if x != nil {
+		return x.LastHistoryId
+	}
+	return 0
+}
+
+func (x *BlobList) GetBlobs() []*Blob {
+	if x != nil {
+		return x.Blobs
+	}
+	return nil
+}
+
+type BlobSync struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	LastHistoryId int64      `protobuf:"varint,1,opt,name=last_history_id,json=lastHistoryId,proto3" json:"last_history_id,omitempty"`
+	NextPollAfter int32      `protobuf:"varint,2,opt,name=next_poll_after,json=nextPollAfter,proto3" json:"next_poll_after,omitempty"`
+	Inserted      []*Blob    `protobuf:"bytes,3,rep,name=inserted,proto3" json:"inserted,omitempty"`
+	Updated       []*Blob    `protobuf:"bytes,4,rep,name=updated,proto3" json:"updated,omitempty"`
+	Trashed       []*Blob    `protobuf:"bytes,5,rep,name=trashed,proto3" json:"trashed,omitempty"`
+	Deleted       []*Deleted `protobuf:"bytes,6,rep,name=deleted,proto3" json:"deleted,omitempty"`
+}
+
+func (x *BlobSync) Reset() {
+	*x = BlobSync{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_mailboxpb_mailbox_proto_msgTypes[16]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BlobSync) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BlobSync) ProtoMessage() {}
+
+func (x *BlobSync) ProtoReflect() protoreflect.Message {
+	mi := &file_mailboxpb_mailbox_proto_msgTypes[16]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BlobSync.ProtoReflect.Descriptor instead.
+func (*BlobSync) Descriptor() ([]byte, []int) {
+	return file_mailboxpb_mailbox_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *BlobSync) GetLastHistoryId() int64 {
+	if x != nil {
+		return x.LastHistoryId
+	}
+	return 0
+}
+
+func (x *BlobSync) GetNextPollAfter() int32 {
+	if x != nil {
+		return x.NextPollAfter
+	}
+	return 0
+}
+
+func (x *BlobSync) GetInserted() []*Blob {
+	if x != nil {
+		return x.Inserted
+	}
+	return nil
+}
+
+func (x *BlobSync) GetUpdated() []*Blob {
+	if x != nil {
+		return x.Updated
+	}
+	return nil
+}
+
+func (x *BlobSync) GetTrashed() []*Blob {
+	if x != nil {
+		return x.Trashed
+	}
+	return nil
+}
+
+func (x *BlobSync) GetDeleted() []*Deleted {
+	if x != nil {
+		return x.Deleted
+	}
+	return nil
+}
+
+type BlobInfo struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name        string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	ContentType string `protobuf:"bytes,2,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+}
+
+func (x *BlobInfo) Reset() {
+	*x = BlobInfo{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_mailboxpb_mailbox_proto_msgTypes[17]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BlobInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BlobInfo) ProtoMessage() {}
+
+func (x *BlobInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_mailboxpb_mailbox_proto_msgTypes[17]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BlobInfo.ProtoReflect.Descriptor instead.
+func (*BlobInfo) Descriptor() ([]byte, []int) {
+	return file_mailboxpb_mailbox_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *BlobInfo) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *BlobInfo) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+// UploadBlobRequest is the info of the blob first, the chunks of its content then.
+type UploadBlobRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Types that are assignable to Data:
+	//	*UploadBlobRequest_Info
+	//	*UploadBlobRequest_Chunk
+	Data isUploadBlobRequest_Data `protobuf_oneof:"data"`
+}
+
+func (x *UploadBlobRequest) Reset() {
+	*x = UploadBlobRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_mailboxpb_mailbox_proto_msgTypes[18]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *UploadBlobRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UploadBlobRequest) ProtoMessage() {}
+
+func (x *UploadBlobRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_mailboxpb_mailbox_proto_msgTypes[18]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UploadBlobRequest.ProtoReflect.Descriptor instead.
+func (*UploadBlobRequest) Descriptor() ([]byte, []int) {
+	return file_mailboxpb_mailbox_proto_rawDescGZIP(), []int{18}
+}
+
+func (m *UploadBlobRequest) GetData() isUploadBlobRequest_Data {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func (x *UploadBlobRequest) GetInfo() *BlobInfo {
+	if x, ok := x.GetData().(*UploadBlobRequest_Info); ok {
+		return x.Info
+	}
+	return nil
+}
+
+func (x *UploadBlobRequest) GetChunk() []byte {
+	if x, ok := x.GetData().(*UploadBlobRequest_Chunk); ok {
+		return x.Chunk
+	}
+	return nil
+}
+
+type isUploadBlobRequest_Data interface {
+	isUploadBlobRequest_Data()
+}
+
+type UploadBlobRequest_Info struct {
+	Info *BlobInfo `protobuf:"bytes,1,opt,name=info,proto3,oneof"`
+}
+
+type UploadBlobRequest_Chunk struct {
+	Chunk []byte `protobuf:"bytes,2,opt,name=chunk,proto3,oneof"`
+}
+
+func (*UploadBlobRequest_Info) isUploadBlobRequest_Data() {}
+
+func (*UploadBlobRequest_Chunk) isUploadBlobRequest_Data() {}
+
+type DownloadBlobRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Digest string `protobuf:"bytes,1,opt,name=digest,proto3" json:"digest,omitempty"`
+}
+
+func (x *DownloadBlobRequest) Reset() {
+	*x = DownloadBlobRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_mailboxpb_mailbox_proto_msgTypes[19]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DownloadBlobRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DownloadBlobRequest) ProtoMessage() {}
+
+func (x *DownloadBlobRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_mailboxpb_mailbox_proto_msgTypes[19]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DownloadBlobRequest.ProtoReflect.Descriptor instead.
+func (*DownloadBlobRequest) Descriptor() ([]byte, []int) {
+	return file_mailboxpb_mailbox_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *DownloadBlobRequest) GetDigest() string {
+	if x != nil {
+		return x.Digest
+	}
+	return ""
+}
+
+// DownloadBlobResponse is the blob first, the chunks of its content then.
+type DownloadBlobResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Types that are assignable to Data:
+	//	*DownloadBlobResponse_Blob
+	//	*DownloadBlobResponse_Chunk
+	Data isDownloadBlobResponse_Data `protobuf_oneof:"data"`
+}
+
+func (x *DownloadBlobResponse) Reset() {
+	*x = DownloadBlobResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_mailboxpb_mailbox_proto_msgTypes[20]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DownloadBlobResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DownloadBlobResponse) ProtoMessage() {}
+
+func (x *DownloadBlobResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_mailboxpb_mailbox_proto_msgTypes[20]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DownloadBlobResponse.ProtoReflect.Descriptor instead.
+func (*DownloadBlobResponse) Descriptor() ([]byte, []int) {
+	return file_mailboxpb_mailbox_proto_rawDescGZIP(), []int{20}
+}
+
+func (m *DownloadBlobResponse) GetData() isDownloadBlobResponse_Data {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func (x *DownloadBlobResponse) GetBlob() *Blob {
+	if x, ok := x.GetData().(*DownloadBlobResponse_Blob); ok {
+		return x.Blob
+	}
+	return nil
+}
+
+func (x *DownloadBlobResponse) GetChunk() []byte {
+	if x, ok := x.GetData().(*DownloadBlobResponse_Chunk); ok {
+		return x.Chunk
+	}
+	return nil
+}
+
+type isDownloadBlobResponse_Data interface {
+	isDownloadBlobResponse_Data()
+}
+
+type DownloadBlobResponse_Blob struct {
+	Blob *Blob `protobuf:"bytes,1,opt,name=blob,proto3,oneof"`
+}
+
+type DownloadBlobResponse_Chunk struct {
+	Chunk []byte `protobuf:"bytes,2,opt,name=chunk,proto3,oneof"`
+}
+
+func (*DownloadBlobResponse_Blob) isDownloadBlobResponse_Data() {}
+
+func (*DownloadBlobResponse_Chunk) isDownloadBlobResponse_Data() {}
+
+type RenameBlobRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id   string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name string `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+}
+
+func (x *RenameBlobRequest) Reset() {
+	*x = RenameBlobRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_mailboxpb_mailbox_proto_msgTypes[21]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RenameBlobRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RenameBlobRequest) ProtoMessage() {}
+
+func (x *RenameBlobRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_mailboxpb_mailbox_proto_msgTypes[21]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RenameBlobRequest.ProtoReflect.Descriptor instead.
+func (*RenameBlobRequest) Descriptor() ([]byte, []int) {
+	return file_mailboxpb_mailbox_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *RenameBlobRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *RenameBlobRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+var File_mailboxpb_mailbox_proto protoreflect.FileDescriptor
+
+var file_mailboxpb_mailbox_proto_rawDesc = []byte{
+	0x0a, 0x17, 0x6d, 0x61, 0x69, 0x6c, 0x62, 0x6f, 0x78, 0x70, 0x62, 0x2f, 0x6d, 0x61, 0x69, 0x6c,
+	0x62, 0x6f, 0x78, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x14, 0x63, 0x61, 0x72, 0x67, 0x6f,
+	0x6d, 0x61, 0x69, 0x6c, 0x2e, 0x6d, 0x61, 0x69, 0x6c, 0x62, 0x6f, 0x78, 0x2e, 0x76, 0x31, 0x1a,
+	0x1b, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66,
+	0x2f, 0x65, 0x6d, 0x70, 0x74, 0x79, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x1c, 0x67, 0x6f,
+	0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x73, 0x74,
+	0x72, 0x75, 0x63, 0x74, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x1f, 0x67, 0x6f, 0x6f, 0x67,
+	0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x74, 0x69, 0x6d, 0x65,
+	0x73, 0x74, 0x61, 0x6d, 0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0xb9, 0x02, 0x0a, 0x0b,
+	0x4c, 0x69, 0x73, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x66,
+	0x6f, 0x6c, 0x64, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x06, 0x66, 0x6f, 0x6c,
+	0x64, 0x65, 0x72, 0x12, 0x12, 0x0a, 0x04, 0x73, 0x6f, 0x72, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x04, 0x73, 0x6f, 0x72, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x6f, 0x72, 0x64, 0x65, 0x72,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x12, 0x3f, 0x0a,
+	0x0d, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x64, 0x5f, 0x61, 0x66, 0x74, 0x65, 0x72, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70,
+	0x52, 0x0c, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x64, 0x41, 0x66, 0x74, 0x65, 0x72, 0x12, 0x41,
+	0x0a, 0x0e, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x64, 0x5f, 0x62, 0x65, 0x66, 0x6f, 0x72, 0x65,
+	0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61,
+	0x6d, 0x70, 0x52, 0x0d, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x64, 0x42, 0x65, 0x66, 0x6f, 0x72,
+	0x65, 0x12, 0x41, 0x0a, 0x0e, 0x6d, 0x6f, 0x64, 0x69, 0x66, 0x69, 0x65, 0x64, 0x5f, 0x61, 0x66,
+	0x74, 0x65, 0x72, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67,
+	0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65,
+	0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x0d, 0x6d, 0x6f, 0x64, 0x69, 0x66, 0x69, 0x65, 0x64, 0x41,
+	0x66, 0x74, 0x65, 0x72, 0x12, 0x21, 0x0a, 0x0c, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x5f,
+	0x74, 0x79, 0x70, 0x65, 0x18, 0x07, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x63, 0x6f, 0x6e, 0x74,
+	0x65, 0x6e, 0x74, 0x54, 0x79, 0x70, 0x65, 0x22, 0x83, 0x01, 0x0a, 0x0b, 0x53, 0x79, 0x6e, 0x63,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1d, 0x0a, 0x0a, 0x68, 0x69, 0x73, 0x74, 0x6f,
+	0x72, 0x79, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09, 0x68, 0x69, 0x73,
+	0x74, 0x6f, 0x72, 0x79, 0x49, 0x64, 0x12, 0x23, 0x0a, 0x0d, 0x69, 0x67, 0x6e, 0x6f, 0x72, 0x65,
+	0x5f, 0x64, 0x65, 0x76, 0x69, 0x63, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0c, 0x69,
+	0x67, 0x6e, 0x6f, 0x72, 0x65, 0x44, 0x65, 0x76, 0x69, 0x63, 0x65, 0x12, 0x30, 0x0a, 0x05, 0x73,
+	0x69, 0x6e, 0x63, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f,
+	0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d,
+	0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x05, 0x73, 0x69, 0x6e, 0x63, 0x65, 0x22, 0x1e, 0x0a,
+	0x0a, 0x49, 0x64, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x69,
+	0x64, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x03, 0x69, 0x64, 0x73, 0x22, 0x19, 0x0a,
+	0x07, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x64, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x22, 0x56, 0x0a, 0x0c, 0x43, 0x6f, 0x6e, 0x74,
+	0x61, 0x63, 0x74, 0x45, 0x6d, 0x61, 0x69, 0x6c, 0x12, 0x18, 0x0a, 0x07, 0x61, 0x64, 0x64, 0x72,
+	0x65, 0x73, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x61, 0x64, 0x64, 0x72, 0x65,
+	0x73, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x70, 0x72, 0x69, 0x6d, 0x61, 0x72,
+	0x79, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x70, 0x72, 0x69, 0x6d, 0x61, 0x72, 0x79,
+	0x22, 0x3a, 0x0a, 0x0c, 0x43, 0x6f, 0x6e, 0x74, 0x61, 0x63, 0x74, 0x50, 0x68, 0x6f, 0x6e, 0x65,
+	0x12, 0x16, 0x0a, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x22, 0xeb, 0x03, 0x0a,
+	0x07, 0x43, 0x6f, 0x6e, 0x74, 0x61, 0x63, 0x74, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x28, 0x0a, 0x0d, 0x65, 0x6d, 0x61, 0x69,
+	0x6c, 0x5f, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x48,
+	0x00, 0x52, 0x0c, 0x65, 0x6d, 0x61, 0x69, 0x6c, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x88,
+	0x01, 0x01, 0x12, 0x22, 0x0a, 0x0a, 0x66, 0x69, 0x72, 0x73, 0x74, 0x5f, 0x6e, 0x61, 0x6d, 0x65,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x48, 0x01, 0x52, 0x09, 0x66, 0x69, 0x72, 0x73, 0x74, 0x4e,
+	0x61, 0x6d, 0x65, 0x88, 0x01, 0x01, 0x12, 0x20, 0x0a, 0x09, 0x6c, 0x61, 0x73, 0x74, 0x5f, 0x6e,
+	0x61, 0x6d, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x48, 0x02, 0x52, 0x08, 0x6c, 0x61, 0x73,
+	0x74, 0x4e, 0x61, 0x6d, 0x65, 0x88, 0x01, 0x01, 0x12, 0x39, 0x0a, 0x0a, 0x63, 0x72, 0x65, 0x61,
+	0x74, 0x65, 0x64, 0x5f, 0x61, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67,
+	0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54,
+	0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x09, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65,
+	0x64, 0x41, 0x74, 0x12, 0x3b, 0x0a, 0x0b, 0x6d, 0x6f, 0x64, 0x69, 0x66, 0x69, 0x65, 0x64, 0x5f,
+	0x61, 0x74, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c,
+	0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73,
+	0x74, 0x61, 0x6d, 0x70, 0x52, 0x0a, 0x6d, 0x6f, 0x64, 0x69, 0x66, 0x69, 0x65, 0x64, 0x41, 0x74,
+	0x12, 0x4b, 0x0a, 0x0f, 0x65, 0x6d, 0x61, 0x69, 0x6c, 0x5f, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73,
+	0x73, 0x65, 0x73, 0x18, 0x07, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x22, 0x2e, 0x63, 0x61, 0x72, 0x67,
+	0x6f, 0x6d, 0x61, 0x69, 0x6c, 0x2e, 0x6d, 0x61, 0x69, 0x6c, 0x62, 0x6f, 0x78, 0x2e, 0x76, 0x31,
+	0x2e, 0x43, 0x6f, 0x6e, 0x74, 0x61, 0x63, 0x74, 0x45, 0x6d, 0x61, 0x69, 0x6c, 0x52, 0x0e, 0x65,
+	0x6d, 0x61, 0x69, 0x6c, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x65, 0x73, 0x12, 0x47, 0x0a,
+	0x0d, 0x70, 0x68, 0x6f, 0x6e, 0x65, 0x5f, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x73, 0x18, 0x08,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x22, 0x2e, 0x63, 0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69, 0x6c,
+	0x2e, 0x6d, 0x61, 0x69, 0x6c, 0x62, 0x6f, 0x78, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6f, 0x6e, 0x74,
+	0x61, 0x63, 0x74, 0x50, 0x68, 0x6f, 0x6e, 0x65, 0x52, 0x0c, 0x70, 0x68, 0x6f, 0x6e, 0x65, 0x4e,
+	0x75, 0x6d, 0x62, 0x65, 0x72, 0x73, 0x12, 0x19, 0x0a, 0x05, 0x6e, 0x6f, 0x74, 0x65, 0x73, 0x18,
+	0x09, 0x20, 0x01, 0x28, 0x09, 0x48, 0x03, 0x52, 0x05, 0x6e, 0x6f, 0x74, 0x65, 0x73, 0x88, 0x01,
+	0x01, 0x42, 0x10, 0x0a, 0x0e, 0x5f, 0x65, 0x6d, 0x61, 0x69, 0x6c, 0x5f, 0x61, 0x64, 0x64, 0x72,
+	0x65, 0x73, 0x73, 0x42, 0x0d, 0x0a, 0x0b, 0x5f, 0x66, 0x69, 0x72, 0x73, 0x74, 0x5f, 0x6e, 0x61,
+	0x6d, 0x65, 0x42, 0x0c, 0x0a, 0x0a, 0x5f, 0x6c, 0x61, 0x73, 0x74, 0x5f, 0x6e, 0x61, 0x6d, 0x65,
+	0x42, 0x08, 0x0a, 0x06, 0x5f, 0x6e, 0x6f, 0x74, 0x65, 0x73, 0x22, 0x70, 0x0a, 0x0b, 0x43, 0x6f,
+	0x6e, 0x74, 0x61, 0x63, 0x74, 0x4c, 0x69, 0x73, 0x74, 0x12, 0x26, 0x0a, 0x0f, 0x6c, 0x61, 0x73,
+	0x74, 0x5f, 0x68, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x03, 0x52, 0x0d, 0x6c, 0x61, 0x73, 0x74, 0x48, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x49,
+	0x64, 0x12, 0x39, 0x0a, 0x08, 0x63, 0x6f, 0x6e, 0x74, 0x61, 0x63, 0x74, 0x73, 0x18, 0x02, 0x20,
+	0x03, 0x28, 0x0b, 0x32, 0x1d, 0x2e, 0x63, 0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69, 0x6c, 0x2e,
+	0x6d, 0x61, 0x69, 0x6c, 0x62, 0x6f, 0x78, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6f, 0x6e, 0x74, 0x61,
+	0x63, 0x74, 0x52, 0x08, 0x63, 0x6f, 0x6e, 0x74, 0x61, 0x63, 0x74, 0x73, 0x22, 0xc3, 0x02, 0x0a,
+	0x0b, 0x43, 0x6f, 0x6e, 0x74, 0x61, 0x63, 0x74, 0x53, 0x79, 0x6e, 0x63, 0x12, 0x26, 0x0a, 0x0f,
+	0x6c, 0x61, 0x73, 0x74, 0x5f, 0x68, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x5f, 0x69, 0x64, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0d, 0x6c, 0x61, 0x73, 0x74, 0x48, 0x69, 0x73, 0x74, 0x6f,
+	0x72, 0x79, 0x49, 0x64, 0x12, 0x26, 0x0a, 0x0f, 0x6e, 0x65, 0x78, 0x74, 0x5f, 0x70, 0x6f, 0x6c,
+	0x6c, 0x5f, 0x61, 0x66, 0x74, 0x65, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0d, 0x6e,
+	0x65, 0x78, 0x74, 0x50, 0x6f, 0x6c, 0x6c, 0x41, 0x66, 0x74, 0x65, 0x72, 0x12, 0x39, 0x0a, 0x08,
+	0x69, 0x6e, 0x73, 0x65, 0x72, 0x74, 0x65, 0x64, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1d,
+	0x2e, 0x63, 0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69, 0x6c, 0x2e, 0x6d, 0x61, 0x69, 0x6c, 0x62,
+	0x6f, 0x78, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6f, 0x6e, 0x74, 0x61, 0x63, 0x74, 0x52, 0x08, 0x69,
+	0x6e, 0x73, 0x65, 0x72, 0x74, 0x65, 0x64, 0x12, 0x37, 0x0a, 0x07, 0x75, 0x70, 0x64, 0x61, 0x74,
+	0x65, 0x64, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1d, 0x2e, 0x63, 0x61, 0x72, 0x67, 0x6f,
+	0x6d, 0x61, 0x69, 0x6c, 0x2e, 0x6d, 0x61, 0x69, 0x6c, 0x62, 0x6f, 0x78, 0x2e, 0x76, 0x31, 0x2e,
+	0x43, 0x6f, 0x6e, 0x74, 0x61, 0x63, 0x74, 0x52, 0x07, 0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x64,
+	0x12, 0x37, 0x0a, 0x07, 0x74, 0x72, 0x61, 0x73, 0x68, 0x65, 0x64, 0x18, 0x05, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x1d, 0x2e, 0x63, 0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69, 0x6c, 0x2e, 0x6d, 0x61,
+	0x69, 0x6c, 0x62, 0x6f, 0x78, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6f, 0x6e, 0x74, 0x61, 0x63, 0x74,
+	0x52, 0x07, 0x74, 0x72, 0x61, 0x73, 0x68, 0x65, 0x64, 0x12, 0x37, 0x0a, 0x07, 0x64, 0x65, 0x6c,
+	0x65, 0x74, 0x65, 0x64, 0x18, 0x06, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1d, 0x2e, 0x63, 0x61, 0x72,
+	0x67, 0x6f, 0x6d, 0x61, 0x69, 0x6c, 0x2e, 0x6d, 0x61, 0x69, 0x6c, 0x62, 0x6f, 0x78, 0x2e, 0x76,
+	0x31, 0x2e, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x64, 0x52, 0x07, 0x64, 0x65, 0x6c, 0x65, 0x74,
+	0x65, 0x64, 0x22, 0x1a, 0x0a, 0x04, 0x42, 0x6f, 0x64, 0x79, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61,
+	0x74, 0x61, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x22, 0xa9,
+	0x01, 0x0a, 0x0b, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x50, 0x61, 0x72, 0x74, 0x12, 0x31,
+	0x0a, 0x07, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x17, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75,
+	0x66, 0x2e, 0x53, 0x74, 0x72, 0x75, 0x63, 0x74, 0x52, 0x07, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72,
+	0x73, 0x12, 0x2e, 0x0a, 0x04, 0x62, 0x6f, 0x64, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x1a, 0x2e, 0x63, 0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69, 0x6c, 0x2e, 0x6d, 0x61, 0x69, 0x6c,
+	0x62, 0x6f, 0x78, 0x2e, 0x76, 0x31, 0x2e, 0x42, 0x6f, 0x64, 0x79, 0x52, 0x04, 0x62, 0x6f, 0x64,
+	0x79, 0x12, 0x37, 0x0a, 0x05, 0x70, 0x61, 0x72, 0x74, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x21, 0x2e, 0x63, 0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69, 0x6c, 0x2e, 0x6d, 0x61, 0x69,
+	0x6c, 0x62, 0x6f, 0x78, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x50,
+	0x61, 0x72, 0x74, 0x52, 0x05, 0x70, 0x61, 0x72, 0x74, 0x73, 0x22, 0xae, 0x02, 0x0a, 0x05, 0x44,
+	0x72, 0x61, 0x66, 0x74, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x02, 0x69, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x75, 0x6e, 0x72, 0x65, 0x61, 0x64, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x08, 0x52, 0x06, 0x75, 0x6e, 0x72, 0x65, 0x61, 0x64, 0x12, 0x18, 0x0a, 0x07,
+	0x73, 0x74, 0x61, 0x72, 0x72, 0x65, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x73,
+	0x74, 0x61, 0x72, 0x72, 0x65, 0x64, 0x12, 0x3b, 0x0a, 0x07, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61,
+	0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x21, 0x2e, 0x63, 0x61, 0x72, 0x67, 0x6f, 0x6d,
+	0x61, 0x69, 0x6c, 0x2e, 0x6d, 0x61, 0x69, 0x6c, 0x62, 0x6f, 0x78, 0x2e, 0x76, 0x31, 0x2e, 0x4d,
+	0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x50, 0x61, 0x72, 0x74, 0x52, 0x07, 0x70, 0x61, 0x79, 0x6c,
+	0x6f, 0x61, 0x64, 0x12, 0x20, 0x0a, 0x09, 0x6c, 0x61, 0x62, 0x65, 0x6c, 0x5f, 0x69, 0x64, 0x73,
+	0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x48, 0x00, 0x52, 0x08, 0x6c, 0x61, 0x62, 0x65, 0x6c, 0x49,
+	0x64, 0x73, 0x88, 0x01, 0x01, 0x12, 0x39, 0x0a, 0x0a, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x64,
+	0x5f, 0x61, 0x74, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67,
+	0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65,
+	0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x09, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x64, 0x41, 0x74,
+	0x12, 0x3b, 0x0a, 0x0b, 0x6d, 0x6f, 0x64, 0x69, 0x66, 0x69, 0x65, 0x64, 0x5f, 0x61, 0x74, 0x18,
+	0x07, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d,
+	0x70, 0x52, 0x0a, 0x6d, 0x6f, 0x64, 0x69, 0x66, 0x69, 0x65, 0x64, 0x41, 0x74, 0x42, 0x0c, 0x0a,
+	0x0a, 0x5f, 0x6c, 0x61, 0x62, 0x65, 0x6c, 0x5f, 0x69, 0x64, 0x73, 0x22, 0x68, 0x0a, 0x09, 0x44,
+	0x72, 0x61, 0x66, 0x74, 0x4c, 0x69, 0x73, 0x74, 0x12, 0x26, 0x0a, 0x0f, 0x6c, 0x61, 0x73, 0x74,
+	0x5f, 0x68, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x03, 0x52, 0x0d, 0x6c, 0x61, 0x73, 0x74, 0x48, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x49, 0x64,
+	0x12, 0x33, 0x0a, 0x06, 0x64, 0x72, 0x61, 0x66, 0x74, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x1b, 0x2e, 0x63, 0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69, 0x6c, 0x2e, 0x6d, 0x61, 0x69,
+	0x6c, 0x62, 0x6f, 0x78, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x72, 0x61, 0x66, 0x74, 0x52, 0x06, 0x64,
+	0x72, 0x61, 0x66, 0x74, 0x73, 0x22, 0xbb, 0x02, 0x0a, 0x09, 0x44, 0x72, 0x61, 0x66, 0x74, 0x53,
+	0x79, 0x6e, 0x63, 0x12, 0x26, 0x0a, 0x0f, 0x6c, 0x61, 0x73, 0x74, 0x5f, 0x68, 0x69, 0x73, 0x74,
+	0x6f, 0x72, 0x79, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0d, 0x6c, 0x61,
+	0x73, 0x74, 0x48, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x49, 0x64, 0x12, 0x26, 0x0a, 0x0f, 0x6e,
+	0x65, 0x78, 0x74, 0x5f, 0x70, 0x6f, 0x6c, 0x6c, 0x5f, 0x61, 0x66, 0x74, 0x65, 0x72, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x05, 0x52, 0x0d, 0x6e, 0x65, 0x78, 0x74, 0x50, 0x6f, 0x6c, 0x6c, 0x41, 0x66,
+	0x74, 0x65, 0x72, 0x12, 0x37, 0x0a, 0x08, 0x69, 0x6e, 0x73, 0x65, 0x72, 0x74, 0x65, 0x64, 0x18,
+	0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x63, 0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69,
+	0x6c, 0x2e, 0x6d, 0x61, 0x69, 0x6c, 0x62, 0x6f, 0x78, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x72, 0x61,
+	0x66, 0x74, 0x52, 0x08, 0x69, 0x6e, 0x73, 0x65, 0x72, 0x74, 0x65, 0x64, 0x12, 0x35, 0x0a, 0x07,
+	0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x64, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1b, 0x2e,
+	0x63, 0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69, 0x6c, 0x2e, 0x6d, 0x61, 0x69, 0x6c, 0x62, 0x6f,
+	0x78, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x72, 0x61, 0x66, 0x74, 0x52, 0x07, 0x75, 0x70, 0x64, 0x61,
+	0x74, 0x65, 0x64, 0x12, 0x35, 0x0a, 0x07, 0x74, 0x72, 0x61, 0x73, 0x68, 0x65, 0x64, 0x18, 0x05,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x63, 0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69, 0x6c,
+	0x2e, 0x6d, 0x61, 0x69, 0x6c, 0x62, 0x6f, 0x78, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x72, 0x61, 0x66,
+	0x74, 0x52, 0x07, 0x74, 0x72, 0x61, 0x73, 0x68, 0x65, 0x64, 0x12, 0x37, 0x0a, 0x07, 0x64, 0x65,
+	0x6c, 0x65, 0x74, 0x65, 0x64, 0x18, 0x06, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1d, 0x2e, 0x63, 0x61,
+	0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69, 0x6c, 0x2e, 0x6d, 0x61, 0x69, 0x6c, 0x62, 0x6f, 0x78, 0x2e,
+	0x76, 0x31, 0x2e, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x64, 0x52, 0x07, 0x64, 0x65, 0x6c, 0x65,
+	0x74, 0x65, 0x64, 0x22, 0xb4, 0x02, 0x0a, 0x04, 0x42, 0x6c, 0x6f, 0x62, 0x12, 0x0e, 0x0a, 0x02,
+	0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x16, 0x0a, 0x06,
+	0x66, 0x6f, 0x6c, 0x64, 0x65, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x06, 0x66, 0x6f,
+	0x6c, 0x64, 0x65, 0x72, 0x12, 0x16, 0x0a, 0x06, 0x64, 0x69, 0x67, 0x65, 0x73, 0x74, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x64, 0x69, 0x67, 0x65, 0x73, 0x74, 0x12, 0x12, 0x0a, 0x04,
+	0x6e, 0x61, 0x6d, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65,
+	0x12, 0x1d, 0x0a, 0x07, 0x73, 0x6e, 0x69, 0x70, 0x70, 0x65, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28,
+	0x09, 0x48, 0x00, 0x52, 0x07, 0x73, 0x6e, 0x69, 0x70, 0x70, 0x65, 0x74, 0x88, 0x01, 0x01, 0x12,
+	0x12, 0x0a, 0x04, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x03, 0x52, 0x04, 0x73,
+	0x69, 0x7a, 0x65, 0x12, 0x21, 0x0a, 0x0c, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x5f, 0x74,
+	0x79, 0x70, 0x65, 0x18, 0x07, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x63, 0x6f, 0x6e, 0x74, 0x65,
+	0x6e, 0x74, 0x54, 0x79, 0x70, 0x65, 0x12, 0x39, 0x0a, 0x0a, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65,
+	0x64, 0x5f, 0x61, 0x74, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f,
+	0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d,
+	0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x09, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x64, 0x41,
+	0x74, 0x12, 0x3b, 0x0a, 0x0b, 0x6d, 0x6f, 0x64, 0x69, 0x66, 0x69, 0x65, 0x64, 0x5f, 0x61, 0x74,
+	0x18, 0x09, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61,
+	0x6d, 0x70, 0x52, 0x0a, 0x6d, 0x6f, 0x64, 0x69, 0x66, 0x69, 0x65, 0x64, 0x41, 0x74, 0x42, 0x0a,
+	0x0a, 0x08, 0x5f, 0x73, 0x6e, 0x69, 0x70, 0x70, 0x65, 0x74, 0x22, 0x64, 0x0a, 0x08, 0x42, 0x6c,
+	0x6f, 0x62, 0x4c, 0x69, 0x73, 0x74, 0x12, 0x26, 0x0a, 0x0f, 0x6c, 0x61, 0x73, 0x74, 0x5f, 0x68,
+	0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52,
+	0x0d, 0x6c, 0x61, 0x73, 0x74, 0x48, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x49, 0x64, 0x12, 0x30,
+	0x0a, 0x05, 0x62, 0x6c, 0x6f, 0x62, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1a, 0x2e,
+	0x63, 0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69, 0x6c, 0x2e, 0x6d, 0x61, 0x69, 0x6c, 0x62, 0x6f,
+	0x78, 0x2e, 0x76, 0x31, 0x2e, 0x42, 0x6c, 0x6f, 0x62, 0x52, 0x05, 0x62, 0x6c, 0x6f, 0x62, 0x73,
+	0x22, 0xb7, 0x02, 0x0a, 0x08, 0x42, 0x6c, 0x6f, 0x62, 0x53, 0x79, 0x6e, 0x63, 0x12, 0x26, 0x0a,
+	0x0f, 0x6c, 0x61, 0x73, 0x74, 0x5f, 0x68, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x5f, 0x69, 0x64,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0d, 0x6c, 0x61, 0x73, 0x74, 0x48, 0x69, 0x73, 0x74,
+	0x6f, 0x72, 0x79, 0x49, 0x64, 0x12, 0x26, 0x0a, 0x0f, 0x6e, 0x65, 0x78, 0x74, 0x5f, 0x70, 0x6f,
+	0x6c, 0x6c, 0x5f, 0x61, 0x66, 0x74, 0x65, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0d,
+	0x6e, 0x65, 0x78, 0x74, 0x50, 0x6f, 0x6c, 0x6c, 0x41, 0x66, 0x74, 0x65, 0x72, 0x12, 0x36, 0x0a,
+	0x08, 0x69, 0x6e, 0x73, 0x65, 0x72, 0x74, 0x65, 0x64, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x1a, 0x2e, 0x63, 0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69, 0x6c, 0x2e, 0x6d, 0x61, 0x69, 0x6c,
+	0x62, 0x6f, 0x78, 0x2e, 0x76, 0x31, 0x2e, 0x42, 0x6c, 0x6f, 0x62, 0x52, 0x08, 0x69, 0x6e, 0x73,
+	0x65, 0x72, 0x74, 0x65, 0x64, 0x12, 0x34, 0x0a, 0x07, 0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x64,
+	0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x63, 0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61,
+	0x69, 0x6c, 0x2e, 0x6d, 0x61, 0x69, 0x6c, 0x62, 0x6f, 0x78, 0x2e, 0x76, 0x31, 0x2e, 0x42, 0x6c,
+	0x6f, 0x62, 0x52, 0x07, 0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x64, 0x12, 0x34, 0x0a, 0x07, 0x74,
+	0x72, 0x61, 0x73, 0x68, 0x65, 0x64, 0x18, 0x05, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x63,
+	0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69, 0x6c, 0x2e, 0x6d, 0x61, 0x69, 0x6c, 0x62, 0x6f, 0x78,
+	0x2e, 0x76, 0x31, 0x2e, 0x42, 0x6c, 0x6f, 0x62, 0x52, 0x07, 0x74, 0x72, 0x61, 0x73, 0x68, 0x65,
+	0x64, 0x12, 0x37, 0x0a, 0x07, 0x64, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x64, 0x18, 0x06, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x1d, 0x2e, 0x63, 0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69, 0x6c, 0x2e, 0x6d,
+	0x61, 0x69, 0x6c, 0x62, 0x6f, 0x78, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65,
+	0x64, 0x52, 0x07, 0x64, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x64, 0x22, 0x41, 0x0a, 0x08, 0x42, 0x6c,
+	0x6f, 0x62, 0x49, 0x6e, 0x66, 0x6f, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x21, 0x0a, 0x0c, 0x63, 0x6f,
+	0x6e, 0x74, 0x65, 0x6e, 0x74, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x0b, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x54, 0x79, 0x70, 0x65, 0x22, 0x69, 0x0a,
+	0x11, 0x55, 0x70, 0x6c, 0x6f, 0x61, 0x64, 0x42, 0x6c, 0x6f, 0x62, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x12, 0x34, 0x0a, 0x04, 0x69, 0x6e, 0x66, 0x6f, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x1e, 0x2e, 0x63, 0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69, 0x6c, 0x2e, 0x6d, 0x61, 0x69,
+	0x6c, 0x62, 0x6f, 0x78, 0x2e, 0x76, 0x31, 0x2e, 0x42, 0x6c, 0x6f, 0x62, 0x49, 0x6e, 0x66, 0x6f,
+	0x48, 0x00, 0x52, 0x04, 0x69, 0x6e, 0x66, 0x6f, 0x12, 0x16, 0x0a, 0x05, 0x63, 0x68, 0x75, 0x6e,
+	0x6b, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x48, 0x00, 0x52, 0x05, 0x63, 0x68, 0x75, 0x6e, 0x6b,
+	0x42, 0x06, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x22, 0x2d, 0x0a, 0x13, 0x44, 0x6f, 0x77, 0x6e,
+	0x6c, 0x6f, 0x61, 0x64, 0x42, 0x6c, 0x6f, 0x62, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12,
+	0x16, 0x0a, 0x06, 0x64, 0x69, 0x67, 0x65, 0x73, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x06, 0x64, 0x69, 0x67, 0x65, 0x73, 0x74, 0x22, 0x68, 0x0a, 0x14, 0x44, 0x6f, 0x77, 0x6e, 0x6c,
+	0x6f, 0x61, 0x64, 0x42, 0x6c, 0x6f, 0x62, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12,
+	0x30, 0x0a, 0x04, 0x62, 0x6c, 0x6f, 0x62, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e,
+	0x63, 0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69, 0x6c, 0x2e, 0x6d, 0x61, 0x69, 0x6c, 0x62, 0x6f,
+	0x78, 0x2e, 0x76, 0x31, 0x2e, 0x42, 0x6c, 0x6f, 0x62, 0x48, 0x00, 0x52, 0x04, 0x62, 0x6c, 0x6f,
+	0x62, 0x12, 0x16, 0x0a, 0x05, 0x63, 0x68, 0x75, 0x6e, 0x6b, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c,
+	0x48, 0x00, 0x52, 0x05, 0x63, 0x68, 0x75, 0x6e, 0x6b, 0x42, 0x06, 0x0a, 0x04, 0x64, 0x61, 0x74,
+	0x61, 0x22, 0x37, 0x0a, 0x11, 0x52, 0x65, 0x6e, 0x61, 0x6d, 0x65, 0x42, 0x6c, 0x6f, 0x62, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x32, 0x82, 0x04, 0x0a, 0x08, 0x43,
+	0x6f, 0x6e, 0x74, 0x61, 0x63, 0x74, 0x73, 0x12, 0x4c, 0x0a, 0x04, 0x4c, 0x69, 0x73, 0x74, 0x12,
+	0x21, 0x2e, 0x63, 0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69, 0x6c, 0x2e, 0x6d, 0x61, 0x69, 0x6c,
+	0x62, 0x6f, 0x78, 0x2e, 0x76, 0x31, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x1a, 0x21, 0x2e, 0x63, 0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69, 0x6c, 0x2e, 0x6d,
+	0x61, 0x69, 0x6c, 0x62, 0x6f, 0x78, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6f, 0x6e, 0x74, 0x61, 0x63,
+	0x74, 0x4c, 0x69, 0x73, 0x74, 0x12, 0x4c, 0x0a, 0x04, 0x53, 0x79, 0x6e, 0x63, 0x12, 0x21, 0x2e,
+	0x63, 0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69, 0x6c, 0x2e, 0x6d, 0x61, 0x69, 0x6c, 0x62, 0x6f,
+	0x78, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x79, 0x6e, 0x63, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x1a, 0x21, 0x2e, 0x63, 0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69, 0x6c, 0x2e, 0x6d, 0x61, 0x69,
+	0x6c, 0x62, 0x6f, 0x78, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6f, 0x6e, 0x74, 0x61, 0x63, 0x74, 0x53,
+	0x79, 0x6e, 0x63, 0x12, 0x46, 0x0a, 0x06, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x12, 0x1d, 0x2e,
+	0x63, 0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69, 0x6c, 0x2e, 0x6d, 0x61, 0x69, 0x6c, 0x62, 0x6f,
+	0x78, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6f, 0x6e, 0x74, 0x61, 0x63, 0x74, 0x1a, 0x1d, 0x2e, 0x63,
+	0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69, 0x6c, 0x2e, 0x6d, 0x61, 0x69, 0x6c, 0x62, 0x6f, 0x78,
+	0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6f, 0x6e, 0x74, 0x61, 0x63, 0x74, 0x12, 0x46, 0x0a, 0x06, 0x55,
+	0x70, 0x64, 0x61, 0x74, 0x65, 0x12, 0x1d, 0x2e, 0x63, 0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69,
+	0x6c, 0x2e, 0x6d, 0x61, 0x69, 0x6c, 0x62, 0x6f, 0x78, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6f, 0x6e,
+	0x74, 0x61, 0x63, 0x74, 0x1a, 0x1d, 0x2e, 0x63, 0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69, 0x6c,
+	0x2e, 0x6d, 0x61, 0x69, 0x6c, 0x62, 0x6f, 0x78, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6f, 0x6e, 0x74,
+	0x61, 0x63, 0x74, 0x12, 0x41, 0x0a, 0x05, 0x54, 0x72, 0x61, 0x73, 0x68, 0x12, 0x20, 0x2e, 0x63,
+	0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69, 0x6c, 0x2e, 0x6d, 0x61, 0x69, 0x6c, 0x62, 0x6f, 0x78,
+	0x2e, 0x76, 0x31, 0x2e, 0x49, 0x64, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x16,
+	0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66,
+	0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x12, 0x43, 0x0a, 0x07, 0x55, 0x6e, 0x74, 0x72, 0x61, 0x73,
+	0x68, 0x12, 0x20, 0x2e, 0x63, 0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69, 0x6c, 0x2e, 0x6d, 0x61,
+	0x69, 0x6c, 0x62, 0x6f, 0x78, 0x2e, 0x76, 0x31, 0x2e, 0x49, 0x64, 0x73, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x1a, 0x16, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x12, 0x42, 0x0a, 0x06, 0x44,
+	0x65, 0x6c, 0x65, 0x74, 0x65, 0x12, 0x20, 0x2e, 0x63, 0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69,
+	0x6c, 0x2e, 0x6d, 0x61, 0x69, 0x6c, 0x62, 0x6f, 0x78, 0x2e, 0x76, 0x31, 0x2e, 0x49, 0x64, 0x73,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x16, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65,
+	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x32,
+	0xf4, 0x03, 0x0a, 0x06, 0x44, 0x72, 0x61, 0x66, 0x74, 0x73, 0x12, 0x4a, 0x0a, 0x04, 0x4c, 0x69,
+	0x73, 0x74, 0x12, 0x21, 0x2e, 0x63, 0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69, 0x6c, 0x2e, 0x6d,
+	0x61, 0x69, 0x6c, 0x62, 0x6f, 0x78, 0x2e, 0x76, 0x31, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1f, 0x2e, 0x63, 0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69,
+	0x6c, 0x2e, 0x6d, 0x61, 0x69, 0x6c, 0x62, 0x6f, 0x78, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x72, 0x61,
+	0x66, 0x74, 0x4c, 0x69, 0x73, 0x74, 0x12, 0x4a, 0x0a, 0x04, 0x53, 0x79, 0x6e, 0x63, 0x12, 0x21,
+	0x2e, 0x63, 0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69, 0x6c, 0x2e, 0x6d, 0x61, 0x69, 0x6c, 0x62,
+	0x6f, 0x78, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x79, 0x6e, 0x63, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x1a, 0x1f, 0x2e, 0x63, 0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69, 0x6c, 0x2e, 0x6d, 0x61,
+	0x69, 0x6c, 0x62, 0x6f, 0x78, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x72, 0x61, 0x66, 0x74, 0x53, 0x79,
+	0x6e, 0x63, 0x12, 0x42, 0x0a, 0x06, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x12, 0x1b, 0x2e, 0x63,
+	0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69, 0x6c, 0x2e, 0x6d, 0x61, 0x69, 0x6c, 0x62, 0x6f, 0x78,
+	0x2e, 0x76, 0x31, 0x2e, 0x44, 0x72, 0x61, 0x66, 0x74, 0x1a, 0x1b, 0x2e, 0x63, 0x61, 0x72, 0x67,
+	0x6f, 0x6d, 0x61, 0x69, 0x6c, 0x2e, 0x6d, 0x61, 0x69, 0x6c, 0x62, 0x6f, 0x78, 0x2e, 0x76, 0x31,
+	0x2e, 0x44, 0x72, 0x61, 0x66, 0x74, 0x12, 0x42, 0x0a, 0x06, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65,
+	0x12, 0x1b, 0x2e, 0x63, 0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69, 0x6c, 0x2e, 0x6d, 0x61, 0x69,
+	0x6c, 0x62, 0x6f, 0x78, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x72, 0x61, 0x66, 0x74, 0x1a, 0x1b, 0x2e,
+	0x63, 0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69, 0x6c, 0x2e, 0x6d, 0x61, 0x69, 0x6c, 0x62, 0x6f,
+	0x78, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x72, 0x61, 0x66, 0x74, 0x12, 0x41, 0x0a, 0x05, 0x54, 0x72,
+	0x61, 0x73, 0x68, 0x12, 0x20, 0x2e, 0x63, 0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69, 0x6c, 0x2e,
+	0x6d, 0x61, 0x69, 0x6c, 0x62, 0x6f, 0x78, 0x2e, 0x76, 0x31, 0x2e, 0x49, 0x64, 0x73, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x16, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x12, 0x43, 0x0a,
+	0x07, 0x55, 0x6e, 0x74, 0x72, 0x61, 0x73, 0x68, 0x12, 0x20, 0x2e, 0x63, 0x61, 0x72, 0x67, 0x6f,
+	0x6d, 0x61, 0x69, 0x6c, 0x2e, 0x6d, 0x61, 0x69, 0x6c, 0x62, 0x6f, 0x78, 0x2e, 0x76, 0x31, 0x2e,
+	0x49, 0x64, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x16, 0x2e, 0x67, 0x6f, 0x6f,
+	0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x45, 0x6d, 0x70,
+	0x74, 0x79, 0x12, 0x42, 0x0a, 0x06, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x12, 0x20, 0x2e, 0x63,
+	0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69, 0x6c, 0x2e, 0x6d, 0x61, 0x69, 0x6c, 0x62, 0x6f, 0x78,
+	0x2e, 0x76, 0x31, 0x2e, 0x49, 0x64, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x16,
+	0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66,
+	0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x32, 0xee, 0x04, 0x0a, 0x05, 0x42, 0x6c, 0x6f, 0x62, 0x73,
+	0x12, 0x49, 0x0a, 0x04, 0x4c, 0x69, 0x73, 0x74, 0x12, 0x21, 0x2e, 0x63, 0x61, 0x72, 0x67, 0x6f,
+	0x6d, 0x61, 0x69, 0x6c, 0x2e, 0x6d, 0x61, 0x69, 0x6c, 0x62, 0x6f, 0x78, 0x2e, 0x76, 0x31, 0x2e,
+	0x4c, 0x69, 0x73, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1e, 0x2e, 0x63, 0x61,
+	0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69, 0x6c, 0x2e, 0x6d, 0x61, 0x69, 0x6c, 0x62, 0x6f, 0x78, 0x2e,
+	0x76, 0x31, 0x2e, 0x42, 0x6c, 0x6f, 0x62, 0x4c, 0x69, 0x73, 0x74, 0x12, 0x49, 0x0a, 0x04, 0x53,
+	0x79, 0x6e, 0x63, 0x12, 0x21, 0x2e, 0x63, 0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69, 0x6c, 0x2e,
+	0x6d, 0x61, 0x69, 0x6c, 0x62, 0x6f, 0x78, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x79, 0x6e, 0x63, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1e, 0x2e, 0x63, 0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61,
+	0x69, 0x6c, 0x2e, 0x6d, 0x61, 0x69, 0x6c, 0x62, 0x6f, 0x78, 0x2e, 0x76, 0x31, 0x2e, 0x42, 0x6c,
+	0x6f, 0x62, 0x53, 0x79, 0x6e, 0x63, 0x12, 0x4f, 0x0a, 0x06, 0x55, 0x70, 0x6c, 0x6f, 0x61, 0x64,
+	0x12, 0x27, 0x2e, 0x63, 0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69, 0x6c, 0x2e, 0x6d, 0x61, 0x69,
+	0x6c, 0x62, 0x6f, 0x78, 0x2e, 0x76, 0x31, 0x2e, 0x55, 0x70, 0x6c, 0x6f, 0x61, 0x64, 0x42, 0x6c,
+	0x6f, 0x62, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1a, 0x2e, 0x63, 0x61, 0x72, 0x67,
+	0x6f, 0x6d, 0x61, 0x69, 0x6c, 0x2e, 0x6d, 0x61, 0x69, 0x6c, 0x62, 0x6f, 0x78, 0x2e, 0x76, 0x31,
+	0x2e, 0x42, 0x6c, 0x6f, 0x62, 0x28, 0x01, 0x12, 0x63, 0x0a, 0x08, 0x44, 0x6f, 0x77, 0x6e, 0x6c,
+	0x6f, 0x61, 0x64, 0x12, 0x29, 0x2e, 0x63, 0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69, 0x6c, 0x2e,
+	0x6d, 0x61, 0x69, 0x6c, 0x62, 0x6f, 0x78, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x6f, 0x77, 0x6e, 0x6c,
+	0x6f, 0x61, 0x64, 0x42, 0x6c, 0x6f, 0x62, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x2a,
+	0x2e, 0x63, 0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69, 0x6c, 0x2e, 0x6d, 0x61, 0x69, 0x6c, 0x62,
+	0x6f, 0x78, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x6f, 0x77, 0x6e, 0x6c, 0x6f, 0x61, 0x64, 0x42, 0x6c,
+	0x6f, 0x62, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x30, 0x01, 0x12, 0x4d, 0x0a, 0x06,
+	0x52, 0x65, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x27, 0x2e, 0x63, 0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61,
+	0x69, 0x6c, 0x2e, 0x6d, 0x61, 0x69, 0x6c, 0x62, 0x6f, 0x78, 0x2e, 0x76, 0x31, 0x2e, 0x52, 0x65,
+	0x6e, 0x61, 0x6d, 0x65, 0x42, 0x6c, 0x6f, 0x62, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a,
+	0x1a, 0x2e, 0x63, 0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69, 0x6c, 0x2e, 0x6d, 0x61, 0x69, 0x6c,
+	0x62, 0x6f, 0x78, 0x2e, 0x76, 0x31, 0x2e, 0x42, 0x6c, 0x6f, 0x62, 0x12, 0x41, 0x0a, 0x05, 0x54,
+	0x72, 0x61, 0x73, 0x68, 0x12, 0x20, 0x2e, 0x63, 0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69, 0x6c,
+	0x2e, 0x6d, 0x61, 0x69, 0x6c, 0x62, 0x6f, 0x78, 0x2e, 0x76, 0x31, 0x2e, 0x49, 0x64, 0x73, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x16, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x12, 0x43,
+	0x0a, 0x07, 0x55, 0x6e, 0x74, 0x72, 0x61, 0x73, 0x68, 0x12, 0x20, 0x2e, 0x63, 0x61, 0x72, 0x67,
+	0x6f, 0x6d, 0x61, 0x69, 0x6c, 0x2e, 0x6d, 0x61, 0x69, 0x6c, 0x62, 0x6f, 0x78, 0x2e, 0x76, 0x31,
+	0x2e, 0x49, 0x64, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x16, 0x2e, 0x67, 0x6f,
+	0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x45, 0x6d,
+	0x70, 0x74, 0x79, 0x12, 0x42, 0x0a, 0x06, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x12, 0x20, 0x2e,
+	0x63, 0x61, 0x72, 0x67, 0x6f, 0x6d, 0x61, 0x69, 0x6c, 0x2e, 0x6d, 0x61, 0x69, 0x6c, 0x62, 0x6f,
+	0x78, 0x2e, 0x76, 0x31, 0x2e, 0x49, 0x64, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a,
+	0x16, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75,
+	0x66, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x42, 0x2f, 0x5a, 0x2d, 0x63, 0x61, 0x72, 0x67, 0x6f,
+	0x6d, 0x61, 0x69, 0x6c, 0x2f, 0x63, 0x6d, 0x64, 0x2f, 0x6d, 0x61, 0x69, 0x6c, 0x62, 0x6f, 0x78,
+	0x2f, 0x72, 0x70, 0x63, 0x2f, 0x6d, 0x61, 0x69, 0x6c, 0x62, 0x6f, 0x78, 0x70, 0x62, 0x3b, 0x6d,
+	0x61, 0x69, 0x6c, 0x62, 0x6f, 0x78, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_mailboxpb_mailbox_proto_rawDescOnce sync.Once
+	file_mailboxpb_mailbox_proto_rawDescData = file_mailboxpb_mailbox_proto_rawDesc
+)
+
+func file_mailboxpb_mailbox_proto_rawDescGZIP() []byte {
+	file_mailboxpb_mailbox_proto_rawDescOnce.Do(func() {
+		file_mailboxpb_mailbox_proto_rawDescData = protoimpl.X.CompressGZIP(file_mailboxpb_mailbox_proto_rawDescData)
+	})
+	return file_mailboxpb_mailbox_proto_rawDescData
+}
+
+var file_mailboxpb_mailbox_proto_msgTypes = make([]protoimpl.MessageInfo, 22)
+var file_mailboxpb_mailbox_proto_goTypes = []interface{}{
+	(*ListRequest)(nil),           // 0: cargomail.mailbox.v1.ListRequest
+	(*SyncRequest)(nil),           // 1: cargomail.mailbox.v1.SyncRequest
+	(*IdsRequest)(nil),            // 2: cargomail.mailbox.v1.IdsRequest
+	(*Deleted)(nil),               // 3: cargomail.mailbox.v1.Deleted
+	(*ContactEmail)(nil),          // 4: cargomail.mailbox.v1.ContactEmail
+	(*ContactPhone)(nil),          // 5: cargomail.mailbox.v1.ContactPhone
+	(*Contact)(nil),               // 6: cargomail.mailbox.v1.Contact
+	(*ContactList)(nil),           // 7: cargomail.mailbox.v1.ContactList
+	(*ContactSync)(nil),           // 8: cargomail.mailbox.v1.ContactSync
+	(*Body)(nil),                  // 9: cargomail.mailbox.v1.Body
+	(*MessagePart)(nil),           // 10: cargomail.mailbox.v1.MessagePart
+	(*Draft)(nil),                 // 11: cargomail.mailbox.v1.Draft
+	(*DraftList)(nil),             // 12: cargomail.mailbox.v1.DraftList
+	(*DraftSync)(nil),             // 13: cargomail.mailbox.v1.DraftSync
+	(*Blob)(nil),                  // 14: cargomail.mailbox.v1.Blob
+	(*BlobList)(nil),              // 15: cargomail.mailbox.v1.BlobList
+	(*BlobSync)(nil),              // 16: cargomail.mailbox.v1.BlobSync
+	(*BlobInfo)(nil),              // 17: cargomail.mailbox.v1.BlobInfo
+	(*UploadBlobRequest)(nil),     // 18: cargomail.mailbox.v1.UploadBlobRequest
+	(*DownloadBlobRequest)(nil),   // 19: cargomail.mailbox.v1.DownloadBlobRequest
+	(*DownloadBlobResponse)(nil),  // 20: cargomail.mailbox.v1.DownloadBlobResponse
+	(*RenameBlobRequest)(nil),     // 21: cargomail.mailbox.v1.RenameBlobRequest
+	(*timestamppb.Timestamp)(nil), // 22: google.protobuf.Timestamp
+	(*structpb.Struct)(nil),       // 23: google.protobuf.Struct
+	(*emptypb.Empty)(nil),         // 24: google.protobuf.Empty
+}
+var file_mailboxpb_mailbox_proto_depIdxs = []int32{
+	22, // 0: cargomail.mailbox.v1.ListRequest.created_after:type_name -> google.protobuf.Timestamp
+	22, // 1: cargomail.mailbox.v1.ListRequest.created_before:type_name -> google.protobuf.Timestamp
+	22, // 2: cargomail.mailbox.v1.ListRequest.modified_after:type_name -> google.protobuf.Timestamp
+	22, // 3: cargomail.mailbox.v1.SyncRequest.since:type_name -> google.protobuf.Timestamp
+	22, // 4: cargomail.mailbox.v1.Contact.created_at:type_name -> google.protobuf.Timestamp
+	22, // 5: cargomail.mailbox.v1.Contact.modified_at:type_name -> google.protobuf.Timestamp
+	4,  // 6: cargomail.mailbox.v1.Contact.email_addresses:type_name -> cargomail.mailbox.v1.ContactEmail
+	5,  // 7: cargomail.mailbox.v1.Contact.phone_numbers:type_name -> cargomail.mailbox.v1.ContactPhone
+	6,  // 8: cargomail.mailbox.v1.ContactList.contacts:type_name -> cargomail.mailbox.v1.Contact
+	6,  // 9: cargomail.mailbox.v1.ContactSync.inserted:type_name -> cargomail.mailbox.v1.Contact
+	6,  // 10: cargomail.mailbox.v1.ContactSync.updated:type_name -> cargomail.mailbox.v1.Contact
+	6,  // 11: cargomail.mailbox.v1.ContactSync.trashed:type_name -> cargomail.mailbox.v1.Contact
+	3,  // 12: cargomail.mailbox.v1.ContactSync.deleted:type_name -> cargomail.mailbox.v1.Deleted
+	23, // 13: cargomail.mailbox.v1.MessagePart.headers:type_name -> google.protobuf.Struct
+	9,  // 14: cargomail.mailbox.v1.MessagePart.body:type_name -> cargomail.mailbox.v1.Body
+	10, // 15: cargomail.mailbox.v1.MessagePart.parts:type_name -> cargomail.mailbox.v1.MessagePart
+	10, // 16: cargomail.mailbox.v1.Draft.payload:type_name -> cargomail.mailbox.v1.MessagePart
+	22, // 17: cargomail.mailbox.v1.Draft.created_at:type_name -> google.protobuf.Timestamp
+	22, // 18: cargomail.mailbox.v1.Draft.modified_at:type_name -> google.protobuf.Timestamp
+	11, // 19: cargomail.mailbox.v1.DraftList.drafts:type_name -> cargomail.mailbox.v1.Draft
+	11, // 20: cargomail.mailbox.v1.DraftSync.inserted:type_name -> cargomail.mailbox.v1.Draft
+	11, // 21: cargomail.mailbox.v1.DraftSync.updated:type_name -> cargomail.mailbox.v1.Draft
+	11, // 22: cargomail.mailbox.v1.DraftSync.trashed:type_name -> cargomail.mailbox.v1.Draft
+	3,  // 23: cargomail.mailbox.v1.DraftSync.deleted:type_name -> cargomail.mailbox.v1.Deleted
+	22, // 24: cargomail.mailbox.v1.Blob.created_at:type_name -> google.protobuf.Timestamp
+	22, // 25: cargomail.mailbox.v1.Blob.modified_at:type_name -> google.protobuf.Timestamp
+	14, // 26: cargomail.mailbox.v1.BlobList.blobs:type_name -> cargomail.mailbox.v1.Blob
+	14, // 27: cargomail.mailbox.v1.BlobSync.inserted:type_name -> cargomail.mailbox.v1.Blob
+	14, // 28: cargomail.mailbox.v1.BlobSync.updated:type_name -> cargomail.mailbox.v1.Blob
+	14, // 29: cargomail.mailbox.v1.BlobSync.trashed:type_name -> cargomail.mailbox.v1.Blob
+	3,  // 30: cargomail.mailbox.v1.BlobSync.deleted:type_name -> cargomail.mailbox.v1.Deleted
+	17, // 31: cargomail.mailbox.v1.UploadBlobRequest.info:type_name -> cargomail.mailbox.v1.BlobInfo
+	14, // 32: cargomail.mailbox.v1.DownloadBlobResponse.blob:type_name -> cargomail.mailbox.v1.Blob
+	0,  // 33: cargomail.mailbox.v1.Contacts.List:input_type -> cargomail.mailbox.v1.ListRequest
+	1,  // 34: cargomail.mailbox.v1.Contacts.Sync:input_type -> cargomail.mailbox.v1.SyncRequest
+	6,  // 35: cargomail.mailbox.v1.Contacts.Create:input_type -> cargomail.mailbox.v1.Contact
+	6,  // 36: cargomail.mailbox.v1.Contacts.Update:input_type -> cargomail.mailbox.v1.Contact
+	2,  // 37: cargomail.mailbox.v1.Contacts.Trash:input_type -> cargomail.mailbox.v1.IdsRequest
+	2,  // 38: cargomail.mailbox.v1.Contacts.Untrash:input_type -> cargomail.mailbox.v1.IdsRequest
+	2,  // 39: cargomail.mailbox.v1.Contacts.Delete:input_type -> cargomail.mailbox.v1.IdsRequest
+	0,  // 40: cargomail.mailbox.v1.Drafts.List:input_type -> cargomail.mailbox.v1.ListRequest
+	1,  // 41: cargomail.mailbox.v1.Drafts.Sync:input_type -> cargomail.mailbox.v1.SyncRequest
+	11, // 42: cargomail.mailbox.v1.Drafts.Create:input_type -> cargomail.mailbox.v1.Draft
+	11, // 43: cargomail.mailbox.v1.Drafts.Update:input_type -> cargomail.mailbox.v1.Draft
+	2,  // 44: cargomail.mailbox.v1.Drafts.Trash:input_type -> cargomail.mailbox.v1.IdsRequest
+	2,  // 45: cargomail.mailbox.v1.Drafts.Untrash:input_type -> cargomail.mailbox.v1.IdsRequest
+	2,  // 46: cargomail.mailbox.v1.Drafts.Delete:input_type -> cargomail.mailbox.v1.IdsRequest
+	0,  // 47: cargomail.mailbox.v1.Blobs.List:input_type -> cargomail.mailbox.v1.ListRequest
+	1,  // 48: cargomail.mailbox.v1.Blobs.Sync:input_type -> cargomail.mailbox.v1.SyncRequest
+	18, // 49: cargomail.mailbox.v1.Blobs.Upload:input_type -> cargomail.mailbox.v1.UploadBlobRequest
+	19, // 50: cargomail.mailbox.v1.Blobs.Download:input_type -> cargomail.mailbox.v1.DownloadBlobRequest
+	21, // 51: cargomail.mailbox.v1.Blobs.Rename:input_type -> cargomail.mailbox.v1.RenameBlobRequest
+	2,  // 52: cargomail.mailbox.v1.Blobs.Trash:input_type -> cargomail.mailbox.v1.IdsRequest
+	2,  // 53: cargomail.mailbox.v1.Blobs.Untrash:input_type -> cargomail.mailbox.v1.IdsRequest
+	2,  // 54: cargomail.mailbox.v1.Blobs.Delete:input_type -> cargomail.mailbox.v1.IdsRequest
+	7,  // 55: cargomail.mailbox.v1.Contacts.List:output_type -> cargomail.mailbox.v1.ContactList
+	8,  // 56: cargomail.mailbox.v1.Contacts.Sync:output_type -> cargomail.mailbox.v1.ContactSync
+	6,  // 57: cargomail.mailbox.v1.Contacts.Create:output_type -> cargomail.mailbox.v1.Contact
+	6,  // 58: cargomail.mailbox.v1.Contacts.Update:output_type -> cargomail.mailbox.v1.Contact
+	24, // 59: cargomail.mailbox.v1.Contacts.Trash:output_type -> google.protobuf.Empty
+	24, // 60: cargomail.mailbox.v1.Contacts.Untrash:output_type -> google.protobuf.Empty
+	24, // 61: cargomail.mailbox.v1.Contacts.Delete:output_type -> google.protobuf.Empty
+	12, // 62: cargomail.mailbox.v1.Drafts.List:output_type -> cargomail.mailbox.v1.DraftList
+	13, // 63: cargomail.mailbox.v1.Drafts.Sync:output_type -> cargomail.mailbox.v1.DraftSync
+	11, // 64: cargomail.mailbox.v1.Drafts.Create:output_type -> cargomail.mailbox.v1.Draft
+	11, // 65: cargomail.mailbox.v1.Drafts.Update:output_type -> cargomail.mailbox.v1.Draft
+	24, // 66: cargomail.mailbox.v1.Drafts.Trash:output_type -> google.protobuf.Empty
+	24, // 67: cargomail.mailbox.v1.Drafts.Untrash:output_type -> google.protobuf.Empty
+	24, // 68: cargomail.mailbox.v1.Drafts.Delete:output_type -> google.protobuf.Empty
+	15, // 69: cargomail.mailbox.v1.Blobs.List:output_type -> cargomail.mailbox.v1.BlobList
+	16, // 70: cargomail.mailbox.v1.Blobs.Sync:output_type -> cargomail.mailbox.v1.BlobSync
+	14, // 71: cargomail.mailbox.v1.Blobs.Upload:output_type -> cargomail.mailbox.v1.Blob
+	20, // 72: cargomail.mailbox.v1.Blobs.Download:output_type -> cargomail.mailbox.v1.DownloadBlobResponse
+	14, // 73: cargomail.mailbox.v1.Blobs.Rename:output_type -> cargomail.mailbox.v1.Blob
+	24, // 74: cargomail.mailbox.v1.Blobs.Trash:output_type -> google.protobuf.Empty
+	24, // 75: cargomail.mailbox.v1.Blobs.Untrash:output_type -> google.protobuf.Empty
+	24, // 76: cargomail.mailbox.v1.Blobs.Delete:output_type -> google.protobuf.Empty
+	55, // [55:77] is the sub-list for method output_type
+	33, // [33:55] is the sub-list for method input_type
+	33, // [33:33] is the sub-list for extension type_name
+	33, // [33:33] is the sub-list for extension extendee
+	0,  // [0:33] is the sub-list for field type_name
+}
+
+func init() { file_mailboxpb_mailbox_proto_init() }
+func file_mailboxpb_mailbox_proto_init() {
+	if File_mailboxpb_mailbox_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_mailboxpb_mailbox_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ListRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_mailboxpb_mailbox_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SyncRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_mailboxpb_mailbox_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*IdsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_mailboxpb_mailbox_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Deleted); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_mailboxpb_mailbox_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ContactEmail); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_mailboxpb_mailbox_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ContactPhone); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_mailboxpb_mailbox_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Contact); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_mailboxpb_mailbox_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ContactList); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_mailboxpb_mailbox_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ContactSync); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_mailboxpb_mailbox_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Body); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_mailboxpb_mailbox_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MessagePart); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_mailboxpb_mailbox_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Draft); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_mailboxpb_mailbox_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DraftList); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_mailboxpb_mailbox_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DraftSync); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_mailboxpb_mailbox_proto_msgTypes[14].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Blob); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_mailboxpb_mailbox_proto_msgTypes[15].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BlobList); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_mailboxpb_mailbox_proto_msgTypes[16].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BlobSync); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_mailboxpb_mailbox_proto_msgTypes[17].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BlobInfo); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_mailboxpb_mailbox_proto_msgTypes[18].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*UploadBlobRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_mailboxpb_mailbox_proto_msgTypes[19].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DownloadBlobRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_mailboxpb_mailbox_proto_msgTypes[20].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DownloadBlobResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_mailboxpb_mailbox_proto_msgTypes[21].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RenameBlobRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_mailboxpb_mailbox_proto_msgTypes[6].OneofWrappers = []interface{}{}
+	file_mailboxpb_mailbox_proto_msgTypes[11].OneofWrappers = []interface{}{}
+	file_mailboxpb_mailbox_proto_msgTypes[14].OneofWrappers = []interface{}{}
+	file_mailboxpb_mailbox_proto_msgTypes[18].OneofWrappers = []interface{}{
+		(*UploadBlobRequest_Info)(nil),
+		(*UploadBlobRequest_Chunk)(nil),
+	}
+	file_mailboxpb_mailbox_proto_msgTypes[20].OneofWrappers = []interface{}{
+		(*DownloadBlobResponse_Blob)(nil),
+		(*DownloadBlobResponse_Chunk)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_mailboxpb_mailbox_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   22,
+			NumExtensions: 0,
+			NumServices:   3,
+		},
+		GoTypes:           file_mailboxpb_mailbox_proto_goTypes,
+		DependencyIndexes: file_mailboxpb_mailbox_proto_depIdxs,
+		MessageInfos:      file_mailboxpb_mailbox_proto_msgTypes,
+	}.Build()
+	File_mailboxpb_mailbox_proto = out.File
+	file_mailboxpb_mailbox_proto_rawDesc = nil
+	file_mailboxpb_mailbox_proto_goTypes = nil
+	file_mailboxpb_mailbox_proto_depIdxs = nil
+}
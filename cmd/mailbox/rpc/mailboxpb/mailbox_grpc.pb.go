@@ -0,0 +1,1059 @@
+// The gRPC surface of the mailbox, next to the REST one. The messages mirror the structs of the
+// repository, the nullable fields are optional. The calls are authenticated by the API key sent as the
+// "authorization: Bearer <key>" metadata, and limited to its scopes like the REST calls are.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: mailboxpb/mailbox.proto
+
+package mailboxpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Contacts_List_FullMethodName    = "/cargomail.mailbox.v1.Contacts/List"
+	Contacts_Sync_FullMethodName    = "/cargomail.mailbox.v1.Contacts/Sync"
+	Contacts_Create_FullMethodName  = "/cargomail.mailbox.v1.Contacts/Create"
+	Contacts_Update_FullMethodName  = "/cargomail.mailbox.v1.Contacts/Update"
+	Contacts_Trash_FullMethodName   = "/cargomail.mailbox.v1.Contacts/Trash"
+	Contacts_Untrash_FullMethodName = "/cargomail.mailbox.v1.Contacts/Untrash"
+	Contacts_Delete_FullMethodName  = "/cargomail.mailbox.v1.Contacts/Delete"
+)
+
+// ContactsClient is the client API for Contacts service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ContactsClient interface {
+	List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ContactList, error)
+	Sync(ctx context.Context, in *SyncRequest, opts ...grpc.CallOption) (*ContactSync, error)
+	Create(ctx context.Context, in *Contact, opts ...grpc.CallOption) (*Contact, error)
+	Update(ctx context.Context, in *Contact, opts ...grpc.CallOption) (*Contact, error)
+	Trash(ctx context.Context, in *IdsRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	Untrash(ctx context.Context, in *IdsRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	Delete(ctx context.Context, in *IdsRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+}
+
+type contactsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewContactsClient(cc grpc.ClientConnInterface) ContactsClient {
+	return &contactsClient{cc}
+}
+
+func (c *contactsClient) List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ContactList, error) {
+	out := new(ContactList)
+	err := c.cc.Invoke(ctx, Contacts_List_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *contactsClient) Sync(ctx context.Context, in *SyncRequest, opts ...grpc.CallOption) (*ContactSync, error) {
+	out := new(ContactSync)
+	err := c.cc.Invoke(ctx, Contacts_Sync_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *contactsClient) Create(ctx context.Context, in *Contact, opts ...grpc.CallOption) (*Contact, error) {
+	out := new(Contact)
+	err := c.cc.Invoke(ctx, Contacts_Create_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *contactsClient) Update(ctx context.Context, in *Contact, opts ...grpc.CallOption) (*Contact, error) {
+	out := new(Contact)
+	err := c.cc.Invoke(ctx, Contacts_Update_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *contactsClient) Trash(ctx context.Context, in *IdsRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Contacts_Trash_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *contactsClient) Untrash(ctx context.Context, in *IdsRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Contacts_Untrash_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *contactsClient) Delete(ctx context.Context, in *IdsRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Contacts_Delete_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ContactsServer is the server API for Contacts service.
+// All implementations must embed UnimplementedContactsServer
+// for forward compatibility
+type ContactsServer interface {
+	List(context.Context, *ListRequest) (*ContactList, error)
+	Sync(context.Context, *SyncRequest) (*ContactSync, error)
+	Create(context.Context, *Contact) (*Contact, error)
+	Update(context.Context, *Contact) (*Contact, error)
+	Trash(context.Context, *IdsRequest) (*emptypb.Empty, error)
+	Untrash(context.Context, *IdsRequest) (*emptypb.Empty, error)
+	Delete(context.Context, *IdsRequest) (*emptypb.Empty, error)
+	mustEmbedUnimplementedContactsServer()
+}
+
+// UnimplementedContactsServer must be embedded to have forward compatible implementations.
+type UnimplementedContactsServer struct {
+}
+
+func (UnimplementedContactsServer) List(context.Context, *ListRequest) (*ContactList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method List not implemented")
+}
+func (UnimplementedContactsServer) Sync(context.Context, *SyncRequest) (*ContactSync, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Sync not implemented")
+}
+func (UnimplementedContactsServer) Create(context.Context, *Contact) (*Contact, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Create not implemented")
+}
+func (UnimplementedContactsServer) Update(context.Context, *Contact) (*Contact, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Update not implemented")
+}
+func (UnimplementedContactsServer) Trash(context.Context, *IdsRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Trash not implemented")
+}
+func (UnimplementedContactsServer) Untrash(context.Context, *IdsRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Untrash not implemented")
+}
+func (UnimplementedContactsServer) Delete(context.Context, *IdsRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedContactsServer) mustEmbedUnimplementedContactsServer() {}
+
+// UnsafeContactsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ContactsServer will
+// result in compilation errors.
+type UnsafeContactsServer interface {
+	mustEmbedUnimplementedContactsServer()
+}
+
+func RegisterContactsServer(s grpc.ServiceRegistrar, srv ContactsServer) {
+	s.RegisterService(&Contacts_ServiceDesc, srv)
+}
+
+func _Contacts_List_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ContactsServer).List(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Contacts_List_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ContactsServer).List(ctx, req.(*ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Contacts_Sync_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SyncRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ContactsServer).Sync(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Contacts_Sync_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ContactsServer).Sync(ctx, req.(*SyncRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Contacts_Create_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Contact)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ContactsServer).Create(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Contacts_Create_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ContactsServer).Create(ctx, req.(*Contact))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Contacts_Update_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Contact)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ContactsServer).Update(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Contacts_Update_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ContactsServer).Update(ctx, req.(*Contact))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Contacts_Trash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IdsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ContactsServer).Trash(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Contacts_Trash_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ContactsServer).Trash(ctx, req.(*IdsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Contacts_Untrash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IdsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ContactsServer).Untrash(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Contacts_Untrash_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ContactsServer).Untrash(ctx, req.(*IdsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Contacts_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IdsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ContactsServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Contacts_Delete_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ContactsServer).Delete(ctx, req.(*IdsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Contacts_ServiceDesc is the grpc.ServiceDesc for Contacts service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Contacts_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "cargomail.mailbox.v1.Contacts",
+	HandlerType: (*ContactsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "List",
+			Handler:    _Contacts_List_Handler,
+		},
+		{
+			MethodName: "Sync",
+			Handler:    _Contacts_Sync_Handler,
+		},
+		{
+			MethodName: "Create",
+			Handler:    _Contacts_Create_Handler,
+		},
+		{
+			MethodName: "Update",
+			Handler:    _Contacts_Update_Handler,
+		},
+		{
+			MethodName: "Trash",
+			Handler:    _Contacts_Trash_Handler,
+		},
+		{
+			MethodName: "Untrash",
+			Handler:    _Contacts_Untrash_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _Contacts_Delete_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "mailboxpb/mailbox.proto",
+}
+
+const (
+	Drafts_List_FullMethodName    = "/cargomail.mailbox.v1.Drafts/List"
+	Drafts_Sync_FullMethodName    = "/cargomail.mailbox.v1.Drafts/Sync"
+	Drafts_Create_FullMethodName  = "/cargomail.mailbox.v1.Drafts/Create"
+	Drafts_Update_FullMethodName  = "/cargomail.mailbox.v1.Drafts/Update"
+	Drafts_Trash_FullMethodName   = "/cargomail.mailbox.v1.Drafts/Trash"
+	Drafts_Untrash_FullMethodName = "/cargomail.mailbox.v1.Drafts/Untrash"
+	Drafts_Delete_FullMethodName  = "/cargomail.mailbox.v1.Drafts/Delete"
+)
+
+// DraftsClient is the client API for Drafts service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type DraftsClient interface {
+	List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*DraftList, error)
+	Sync(ctx context.Context, in *SyncRequest, opts ...grpc.CallOption) (*DraftSync, error)
+	Create(ctx context.Context, in *Draft, opts ...grpc.CallOption) (*Draft, error)
+	Update(ctx context.Context, in *Draft, opts ...grpc.CallOption) (*Draft, error)
+	Trash(ctx context.Context, in *IdsRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	Untrash(ctx context.Context, in *IdsRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	Delete(ctx context.Context, in *IdsRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+}
+
+type draftsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDraftsClient(cc grpc.ClientConnInterface) DraftsClient {
+	return &draftsClient{cc}
+}
+
+func (c *draftsClient) List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*DraftList, error) {
+	out := new(DraftList)
+	err := c.cc.Invoke(ctx, Drafts_List_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *draftsClient) Sync(ctx context.Context, in *SyncRequest, opts ...grpc.CallOption) (*DraftSync, error) {
+	out := new(DraftSync)
+	err := c.cc.Invoke(ctx, Drafts_Sync_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *draftsClient) Create(ctx context.Context, in *Draft, opts ...grpc.CallOption) (*Draft, error) {
+	out := new(Draft)
+	err := c.cc.Invoke(ctx, Drafts_Create_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *draftsClient) Update(ctx context.Context, in *Draft, opts ...grpc.CallOption) (*Draft, error) {
+	out := new(Draft)
+	err := c.cc.Invoke(ctx, Drafts_Update_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *draftsClient) Trash(ctx context.Context, in *IdsRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Drafts_Trash_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *draftsClient) Untrash(ctx context.Context, in *IdsRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Drafts_Untrash_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *draftsClient) Delete(ctx context.Context, in *IdsRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Drafts_Delete_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DraftsServer is the server API for Drafts service.
+// All implementations must embed UnimplementedDraftsServer
+// for forward compatibility
+type DraftsServer interface {
+	List(context.Context, *ListRequest) (*DraftList, error)
+	Sync(context.Context, *SyncRequest) (*DraftSync, error)
+	Create(context.Context, *Draft) (*Draft, error)
+	Update(context.Context, *Draft) (*Draft, error)
+	Trash(context.Context, *IdsRequest) (*emptypb.Empty, error)
+	Untrash(context.Context, *IdsRequest) (*emptypb.Empty, error)
+	Delete(context.Context, *IdsRequest) (*emptypb.Empty, error)
+	mustEmbedUnimplementedDraftsServer()
+}
+
+// UnimplementedDraftsServer must be embedded to have forward compatible implementations.
+type UnimplementedDraftsServer struct {
+}
+
+func (UnimplementedDraftsServer) List(context.Context, *ListRequest) (*DraftList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method List not implemented")
+}
+func (UnimplementedDraftsServer) Sync(context.Context, *SyncRequest) (*DraftSync, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Sync not implemented")
+}
+func (UnimplementedDraftsServer) Create(context.Context, *Draft) (*Draft, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Create not implemented")
+}
+func (UnimplementedDraftsServer) Update(context.Context, *Draft) (*Draft, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Update not implemented")
+}
+func (UnimplementedDraftsServer) Trash(context.Context, *IdsRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Trash not implemented")
+}
+func (UnimplementedDraftsServer) Untrash(context.Context, *IdsRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Untrash not implemented")
+}
+func (UnimplementedDraftsServer) Delete(context.Context, *IdsRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedDraftsServer) mustEmbedUnimplementedDraftsServer() {}
+
+// UnsafeDraftsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DraftsServer will
+// result in compilation errors.
+type UnsafeDraftsServer interface {
+	mustEmbedUnimplementedDraftsServer()
+}
+
+func RegisterDraftsServer(s grpc.ServiceRegistrar, srv DraftsServer) {
+	s.RegisterService(&Drafts_ServiceDesc, srv)
+}
+
+func _Drafts_List_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DraftsServer).List(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Drafts_List_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DraftsServer).List(ctx, req.(*ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Drafts_Sync_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SyncRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DraftsServer).Sync(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Drafts_Sync_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DraftsServer).Sync(ctx, req.(*SyncRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Drafts_Create_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Draft)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DraftsServer).Create(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Drafts_Create_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DraftsServer).Create(ctx, req.(*Draft))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Drafts_Update_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Draft)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DraftsServer).Update(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Drafts_Update_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DraftsServer).Update(ctx, req.(*Draft))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Drafts_Trash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IdsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DraftsServer).Trash(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Drafts_Trash_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DraftsServer).Trash(ctx, req.(*IdsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Drafts_Untrash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IdsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DraftsServer).Untrash(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Drafts_Untrash_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DraftsServer).Untrash(ctx, req.(*IdsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Drafts_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IdsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DraftsServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Drafts_Delete_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DraftsServer).Delete(ctx, req.(*IdsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Drafts_ServiceDesc is the grpc.ServiceDesc for Drafts service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Drafts_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "cargomail.mailbox.v1.Drafts",
+	HandlerType: (*DraftsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "List",
+			Handler:    _Drafts_List_Handler,
+		},
+		{
+			MethodName: "Sync",
+			Handler:    _Drafts_Sync_Handler,
+		},
+		{
+			MethodName: "Create",
+			Handler:    _Drafts_Create_Handler,
+		},
+		{
+			MethodName: "Update",
+			Handler:    _Drafts_Update_Handler,
+		},
+		{
+			MethodName: "Trash",
+			Handler:    _Drafts_Trash_Handler,
+		},
+		{
+			MethodName: "Untrash",
+			Handler:    _Drafts_Untrash_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _Drafts_Delete_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "mailboxpb/mailbox.proto",
+}
+
+const (
+	Blobs_List_FullMethodName     = "/cargomail.mailbox.v1.Blobs/List"
+	Blobs_Sync_FullMethodName     = "/cargomail.mailbox.v1.Blobs/Sync"
+	Blobs_Upload_FullMethodName   = "/cargomail.mailbox.v1.Blobs/Upload"
+	Blobs_Download_FullMethodName = "/cargomail.mailbox.v1.Blobs/Download"
+	Blobs_Rename_FullMethodName   = "/cargomail.mailbox.v1.Blobs/Rename"
+	Blobs_Trash_FullMethodName    = "/cargomail.mailbox.v1.Blobs/Trash"
+	Blobs_Untrash_FullMethodName  = "/cargomail.mailbox.v1.Blobs/Untrash"
+	Blobs_Delete_FullMethodName   = "/cargomail.mailbox.v1.Blobs/Delete"
+)
+
+// BlobsClient is the client API for Blobs service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type BlobsClient interface {
+	List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*BlobList, error)
+	Sync(ctx context.Context, in *SyncRequest, opts ...grpc.CallOption) (*BlobSync, error)
+	Upload(ctx context.Context, opts ...grpc.CallOption) (Blobs_UploadClient, error)
+	Download(ctx context.Context, in *DownloadBlobRequest, opts ...grpc.CallOption) (Blobs_DownloadClient, error)
+	Rename(ctx context.Context, in *RenameBlobRequest, opts ...grpc.CallOption) (*Blob, error)
+	Trash(ctx context.Context, in *IdsRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	Untrash(ctx context.Context, in *IdsRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	Delete(ctx context.Context, in *IdsRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+}
+
+type blobsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewBlobsClient(cc grpc.ClientConnInterface) BlobsClient {
+	return &blobsClient{cc}
+}
+
+func (c *blobsClient) List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*BlobList, error) {
+	out := new(BlobList)
+	err := c.cc.Invoke(ctx, Blobs_List_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *blobsClient) Sync(ctx context.Context, in *SyncRequest, opts ...grpc.CallOption) (*BlobSync, error) {
+	out := new(BlobSync)
+	err := c.cc.Invoke(ctx, Blobs_Sync_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *blobsClient) Upload(ctx context.Context, opts ...grpc.CallOption) (Blobs_UploadClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Blobs_ServiceDesc.Streams[0], Blobs_Upload_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &blobsUploadClient{stream}
+	return x, nil
+}
+
+type Blobs_UploadClient interface {
+	Send(*UploadBlobRequest) error
+	CloseAndRecv() (*Blob, error)
+	grpc.ClientStream
+}
+
+type blobsUploadClient struct {
+	grpc.ClientStream
+}
+
+func (x *blobsUploadClient) Send(m *UploadBlobRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *blobsUploadClient) CloseAndRecv() (*Blob, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(Blob)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *blobsClient) Download(ctx context.Context, in *DownloadBlobRequest, opts ...grpc.CallOption) (Blobs_DownloadClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Blobs_ServiceDesc.Streams[1], Blobs_Download_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &blobsDownloadClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Blobs_DownloadClient interface {
+	Recv() (*DownloadBlobResponse, error)
+	grpc.ClientStream
+}
+
+type blobsDownloadClient struct {
+	grpc.ClientStream
+}
+
+func (x *blobsDownloadClient) Recv() (*DownloadBlobResponse, error) {
+	m := new(DownloadBlobResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *blobsClient) Rename(ctx context.Context, in *RenameBlobRequest, opts ...grpc.CallOption) (*Blob, error) {
+	out := new(Blob)
+	err := c.cc.Invoke(ctx, Blobs_Rename_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *blobsClient) Trash(ctx context.Context, in *IdsRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Blobs_Trash_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *blobsClient) Untrash(ctx context.Context, in *IdsRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Blobs_Untrash_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *blobsClient) Delete(ctx context.Context, in *IdsRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Blobs_Delete_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// BlobsServer is the server API for Blobs service.
+// All implementations must embed UnimplementedBlobsServer
+// for forward compatibility
+type BlobsServer interface {
+	List(context.Context, *ListRequest) (*BlobList, error)
+	Sync(context.Context, *SyncRequest) (*BlobSync, error)
+	Upload(Blobs_UploadServer) error
+	Download(*DownloadBlobRequest, Blobs_DownloadServer) error
+	Rename(context.Context, *RenameBlobRequest) (*Blob, error)
+	Trash(context.Context, *IdsRequest) (*emptypb.Empty, error)
+	Untrash(context.Context, *IdsRequest) (*emptypb.Empty, error)
+	Delete(context.Context, *IdsRequest) (*emptypb.Empty, error)
+	mustEmbedUnimplementedBlobsServer()
+}
+
+// UnimplementedBlobsServer must be embedded to have forward compatible implementations.
+type UnimplementedBlobsServer struct {
+}
+
+func (UnimplementedBlobsServer) List(context.Context, *ListRequest) (*BlobList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method List not implemented")
+}
+func (UnimplementedBlobsServer) Sync(context.Context, *SyncRequest) (*BlobSync, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Sync not implemented")
+}
+func (UnimplementedBlobsServer) Upload(Blobs_UploadServer) error {
+	return status.Errorf(codes.Unimplemented, "method Upload not implemented")
+}
+func (UnimplementedBlobsServer) Download(*DownloadBlobRequest, Blobs_DownloadServer) error {
+	return status.Errorf(codes.Unimplemented, "method Download not implemented")
+}
+func (UnimplementedBlobsServer) Rename(context.Context, *RenameBlobRequest) (*Blob, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Rename not implemented")
+}
+func (UnimplementedBlobsServer) Trash(context.Context, *IdsRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Trash not implemented")
+}
+func (UnimplementedBlobsServer) Untrash(context.Context, *IdsRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Untrash not implemented")
+}
+func (UnimplementedBlobsServer) Delete(context.Context, *IdsRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedBlobsServer) mustEmbedUnimplementedBlobsServer() {}
+
+// UnsafeBlobsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to BlobsServer will
+// result in compilation errors.
+type UnsafeBlobsServer interface {
+	mustEmbedUnimplementedBlobsServer()
+}
+
+func RegisterBlobsServer(s grpc.ServiceRegistrar, srv BlobsServer) {
+	s.RegisterService(&Blobs_ServiceDesc, srv)
+}
+
+func _Blobs_List_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BlobsServer).List(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Blobs_List_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BlobsServer).List(ctx, req.(*ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Blobs_Sync_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SyncRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BlobsServer).Sync(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Blobs_Sync_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BlobsServer).Sync(ctx, req.(*SyncRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Blobs_Upload_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(BlobsServer).Upload(&blobsUploadServer{stream})
+}
+
+type Blobs_UploadServer interface {
+	SendAndClose(*Blob) error
+	Recv() (*UploadBlobRequest, error)
+	grpc.ServerStream
+}
+
+type blobsUploadServer struct {
+	grpc.ServerStream
+}
+
+func (x *blobsUploadServer) SendAndClose(m *Blob) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *blobsUploadServer) Recv() (*UploadBlobRequest, error) {
+	m := new(UploadBlobRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Blobs_Download_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DownloadBlobRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(BlobsServer).Download(m, &blobsDownloadServer{stream})
+}
+
+type Blobs_DownloadServer interface {
+	Send(*DownloadBlobResponse) error
+	grpc.ServerStream
+}
+
+type blobsDownloadServer struct {
+	grpc.ServerStream
+}
+
+func (x *blobsDownloadServer) Send(m *DownloadBlobResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Blobs_Rename_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RenameBlobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BlobsServer).Rename(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Blobs_Rename_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BlobsServer).Rename(ctx, req.(*RenameBlobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Blobs_Trash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IdsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BlobsServer).Trash(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Blobs_Trash_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BlobsServer).Trash(ctx, req.(*IdsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Blobs_Untrash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IdsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BlobsServer).Untrash(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Blobs_Untrash_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BlobsServer).Untrash(ctx, req.(*IdsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Blobs_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IdsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BlobsServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Blobs_Delete_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BlobsServer).Delete(ctx, req.(*IdsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Blobs_ServiceDesc is the grpc.ServiceDesc for Blobs service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Blobs_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "cargomail.mailbox.v1.Blobs",
+	HandlerType: (*BlobsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "List",
+			Handler:    _Blobs_List_Handler,
+		},
+		{
+			MethodName: "Sync",
+			Handler:    _Blobs_Sync_Handler,
+		},
+		{
+			MethodName: "Rename",
+			Handler:    _Blobs_Rename_Handler,
+		},
+		{
+			MethodName: "Trash",
+			Handler:    _Blobs_Trash_Handler,
+		},
+		{
+			MethodName: "Untrash",
+			Handler:    _Blobs_Untrash_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _Blobs_Delete_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Upload",
+			Handler:       _Blobs_Upload_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Download",
+			Handler:       _Blobs_Download_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "mailboxpb/mailbox.proto",
+}
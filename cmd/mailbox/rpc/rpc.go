@@ -0,0 +1,247 @@
+// Package rpc serves the gRPC surface of the mailbox next to the REST API. The services call the same
+// repository and storage methods the REST handlers do, and authenticate by the API keys the same way.
+package rpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative mailboxpb/mailbox.proto
+
+import (
+	"cargomail/cmd/mailbox/rpc/mailboxpb"
+	"cargomail/internal/mailbox/repository"
+	"cargomail/internal/mailbox/storage"
+	"cargomail/internal/shared/ratelimit"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type ServerParams struct {
+	Repository repository.Repository
+	Storage    storage.Storage
+	UserLimits *ratelimit.Limiter
+}
+
+// NewServer returns the gRPC server of the contacts, drafts and blobs services, the options are e.g.
+// the TLS credentials.
+func NewServer(params ServerParams, opts ...grpc.ServerOption) *grpc.Server {
+	auth := &authenticator{
+		useApiKeyRepository: params.Repository.ApiKeys,
+		userLimits:          params.UserLimits,
+	}
+
+	opts = append(opts,
+		grpc.UnaryInterceptor(auth.unary),
+		grpc.StreamInterceptor(auth.stream),
+	)
+
+	server := grpc.NewServer(opts...)
+
+	mailboxpb.RegisterContactsServer(server, &contactsServer{
+		useContactRepository: params.Repository.Contacts,
+	})
+	mailboxpb.RegisterDraftsServer(server, &draftsServer{
+		useDraftRepository: params.Repository.Drafts,
+		useDraftStorage:    params.Storage.Drafts,
+	})
+	mailboxpb.RegisterBlobsServer(server, &blobsServer{
+		useBlobRepository: params.Repository.Blobs,
+		useBlobStorage:    params.Storage.Blobs,
+	})
+
+	return server
+}
+
+// serviceScopes are the scopes of the services, the read ones are of the readMethods.
+var serviceScopes = map[string]string{
+	"cargomail.mailbox.v1.Contacts": "contacts",
+	"cargomail.mailbox.v1.Drafts":   "drafts",
+	"cargomail.mailbox.v1.Blobs":    "blobs",
+}
+
+var readMethods = map[string]bool{
+	"List":     true,
+	"Sync":     true,
+	"Download": true,
+}
+
+// methodScope returns the scope the full method, e.g. /cargomail.mailbox.v1.Contacts/List, requires.
+func methodScope(fullMethod string) (string, bool) {
+	service, method, ok := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	if !ok {
+		return "", false
+	}
+
+	scope, ok := serviceScopes[service]
+	if !ok {
+		return "", false
+	}
+
+	if readMethods[method] {
+		return scope + ":read", true
+	}
+
+	return scope + ":write", true
+}
+
+// authenticator resolves the API key of the "authorization: Bearer <key>" metadata to its user, like the
+// Authenticate middleware does, the sessions are of the browser and not accepted. The "x-read-primary"
+// metadata reads from the primary database, as the X-Read-Primary header does.
+type authenticator struct {
+	useApiKeyRepository repository.UseApiKeyRepository
+	userLimits          *ratelimit.Limiter
+}
+
+func (a *authenticator) authenticate(ctx context.Context, fullMethod string) (context.Context, error) {
+	scope, ok := methodScope(fullMethod)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, fullMethod)
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	authorization := md.Get("authorization")
+	if len(authorization) == 0 {
+		return nil, status.Error(codes.Unauthenticated, repository.ErrInvalidApiKey.Error())
+	}
+
+	key, ok := strings.CutPrefix(authorization[0], "Bearer ")
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, repository.ErrInvalidApiKey.Error())
+	}
+
+	user, err := a.useApiKeyRepository.GetUserByKey(strings.TrimSpace(key))
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrInvalidApiKey):
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		default:
+			return nil, rpcError(err)
+		}
+	}
+
+	if ok, wait := a.userLimits.Allow(strconv.FormatInt(user.Id, 10), time.Now()); !ok {
+		grpc.SetHeader(ctx, metadata.Pairs("retry-after", ratelimit.RetryAfter(wait)))
+		return nil, status.Error(codes.ResourceExhausted, repository.ErrRateLimited.Error())
+	}
+
+	if !user.HasScope(scope) {
+		return nil, status.Error(codes.PermissionDenied, fmt.Errorf("%w: %s required", repository.ErrInsufficientScope, scope).Error())
+	}
+
+	if readPrimary := md.Get("x-read-primary"); len(readPrimary) > 0 {
+		user.ReadPrimary, _ = strconv.ParseBool(readPrimary[0])
+	}
+
+	return context.WithValue(ctx, repository.UserContextKey, user), nil
+}
+
+func (a *authenticator) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := a.authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+// authenticatedStream is the stream of the authenticated user.
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+func (a *authenticator) stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := a.authenticate(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+
+	return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+}
+
+// modify trashes, untrashes or deletes the items of the ids by the fn.
+func modify(ctx context.Context, req *mailboxpb.IdsRequest, fn func(user *repository.User, ids string) error) (*emptypb.Empty, error) {
+	user, err := contextUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := idsFromPb(req)
+	if err != nil {
+		return nil, rpcError(err)
+	}
+
+	err = fn(user, ids)
+	if err != nil {
+		return nil, rpcError(err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func contextUser(ctx context.Context) (*repository.User, error) {
+	user, ok := ctx.Value(repository.UserContextKey).(*repository.User)
+	if !ok {
+		return nil, status.Error(codes.Internal, repository.ErrMissingUserContext.Error())
+	}
+
+	return user, nil
+}
+
+// errorStatusCodes map the errors of the repository to the status codes, as the REST handlers map them to
+// the HTTP ones. The errors not listed are internal.
+var errorStatusCodes = []struct {
+	err  error
+	code codes.Code
+}{
+	{repository.ErrContactNotFound, codes.NotFound},
+	{repository.ErrDraftNotFound, codes.NotFound},
+	{repository.ErrBlobNotFound, codes.NotFound},
+	{repository.ErrDuplicateContact, codes.AlreadyExists},
+	{repository.ErrInvalidEmailAddress, codes.InvalidArgument},
+	{repository.ErrMultiplePrimaryEmails, codes.InvalidArgument},
+	{repository.ErrPrimaryEmailMismatch, codes.InvalidArgument},
+	{repository.ErrInvalidPhoneNumber, codes.InvalidArgument},
+	{repository.ErrBlobWrongName, codes.InvalidArgument},
+	{repository.ErrInvalidImage, codes.InvalidArgument},
+	{repository.ErrMissingIdField, codes.InvalidArgument},
+	{repository.ErrMissingIdsField, codes.InvalidArgument},
+	{repository.ErrMissingPayloadField, codes.InvalidArgument},
+	{repository.ErrMissingHeadersField, codes.InvalidArgument},
+	{repository.ErrMissingDigestField, codes.InvalidArgument},
+	{repository.ErrInvalidSort, codes.InvalidArgument},
+	{repository.ErrInvalidDateRange, codes.InvalidArgument},
+	{repository.ErrInvalidContentType, codes.InvalidArgument},
+	{repository.ErrInvalidHistoryId, codes.InvalidArgument},
+	{repository.ErrQuotaExceeded, codes.ResourceExhausted},
+	{repository.ErrUploadTooLarge, codes.ResourceExhausted},
+}
+
+func rpcError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	for _, errorStatusCode := range errorStatusCodes {
+		if errors.Is(err, errorStatusCode.err) {
+			return status.Error(errorStatusCode.code, err.Error())
+		}
+	}
+
+	log.Printf("rpc error: %v", err)
+
+	return status.Error(codes.Internal, repository.ErrInternalServer.Error())
+}
@@ -0,0 +1,38 @@
+package mailbox
+
+import (
+	"cargomail/internal/shared/config"
+	"context"
+	"log"
+	"time"
+)
+
+const trashSweepInterval = time.Hour
+
+// sweepTrash purges the expired trash periodically until the context is cancelled.
+func (svc *service) sweepTrash(ctx context.Context) error {
+	retention := config.TrashRetention()
+	if retention == 0 {
+		log.Print("trash sweeper disabled, the trashRetentionDays is not set")
+		return nil
+	}
+
+	ticker := time.NewTicker(trashSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		purge, err := svc.storage.Trash.Purge(retention)
+		if err != nil {
+			// try again on the next tick
+			log.Printf("trash sweeper error: %v", err)
+		} else if purge.Blobs+purge.Contacts+purge.Drafts > 0 {
+			log.Printf("trash sweeper purged %d blobs, %d contacts, %d drafts", purge.Blobs, purge.Contacts, purge.Drafts)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package mailbox
+
+import (
+	"cargomail/internal/shared/config"
+	"context"
+	"log"
+	"time"
+)
+
+const uploadSweepInterval = time.Hour
+
+// expireUploads removes the abandoned resumable uploads periodically until the context is cancelled.
+func (svc *service) expireUploads(ctx context.Context) error {
+	expiry := config.UploadExpiry()
+
+	ticker := time.NewTicker(uploadSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		expired, err := svc.storage.Uploads.Expire(expiry)
+		if err != nil {
+			// try again on the next tick
+			log.Printf("upload sweeper error: %v", err)
+		} else if expired > 0 {
+			log.Printf("upload sweeper removed %d expired uploads", expired)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
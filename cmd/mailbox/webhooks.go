@@ -0,0 +1,204 @@
+package mailbox
+
+import (
+	"bytes"
+	"cargomail/internal/mailbox/repository"
+	"cargomail/internal/shared/config"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	webhookSendInterval   = 5 * time.Second
+	webhookBatchSize      = 50
+	webhookConcurrency    = 8
+	webhookTimeout        = 10 * time.Second
+	webhookMaxAttempts    = 10
+	webhookInitialBackoff = 30 * time.Second
+	webhookMaxBackoff     = 6 * time.Hour
+)
+
+var errWebhookAddress = errors.New("webhook address not allowed")
+
+// webhookSink schedules the deliveries of the events to the webhooks, they are sent by sendWebhooks so a
+// slow receiver doesn't hold the other sinks back.
+type webhookSink struct {
+	useWebhookRepository repository.UseWebhookRepository
+}
+
+func (s *webhookSink) deliver(event *repository.Event) error {
+	return s.useWebhookRepository.Enqueue(event)
+}
+
+// webhookClient doesn't follow the redirects, and doesn't connect to the loopback or the private
+// addresses, so a webhook can't probe the internal network. Both are allowed in the dev stage.
+func webhookClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: webhookTimeout,
+		Control: func(network, address string, c syscall.RawConn) error {
+			if config.DevStage() {
+				return nil
+			}
+
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			ip := net.ParseIP(host)
+			if ip == nil || !ip.IsGlobalUnicast() || ip.IsPrivate() {
+				return fmt.Errorf("%w: %s", errWebhookAddress, host)
+			}
+
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout:   webhookTimeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext, Proxy: nil},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// sendWebhooks posts the due deliveries to the webhooks until the context is cancelled. A failed delivery
+// is retried with an exponential backoff, and given up after webhookMaxAttempts.
+func (svc *service) sendWebhooks(ctx context.Context) error {
+	client := webhookClient()
+
+	ticker := time.NewTicker(webhookSendInterval)
+	defer ticker.Stop()
+
+	for {
+		deliveries, err := svc.repository.Webhooks.Due(webhookBatchSize)
+		if err != nil {
+			// try again on the next tick
+			log.Printf("webhook sender error: %v", err)
+		}
+
+		var wg sync.WaitGroup
+		slots := make(chan struct{}, webhookConcurrency)
+
+		for _, delivery := range deliveries {
+			wg.Add(1)
+			slots <- struct{}{}
+
+			go func(delivery *repository.WebhookDelivery) {
+				defer wg.Done()
+				defer func() { <-slots }()
+
+				svc.sendWebhook(ctx, client, delivery)
+			}(delivery)
+		}
+
+		wg.Wait()
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (svc *service) sendWebhook(ctx context.Context, client *http.Client, delivery *repository.WebhookDelivery) {
+	err := postWebhook(ctx, client, delivery)
+	if err == nil {
+		err = svc.repository.Webhooks.Done(delivery.Id)
+		if err != nil {
+			log.Printf("webhook sender error: %v", err)
+		}
+		return
+	}
+
+	if delivery.Attempts+1 >= webhookMaxAttempts {
+		log.Printf("webhook %s gave up the event %d after %d attempts: %v", delivery.WebhookId, delivery.Event.Id, delivery.Attempts+1, err)
+
+		err = svc.repository.Webhooks.Done(delivery.Id)
+		if err != nil {
+			log.Printf("webhook sender error: %v", err)
+		}
+		return
+	}
+
+	err = svc.repository.Webhooks.Retry(delivery.Id, err.Error(), webhookBackoff(delivery.Attempts))
+	if err != nil {
+		log.Printf("webhook sender error: %v", err)
+	}
+}
+
+// webhookBackoff is the delay after the failed attempts, doubled by each one, e.g. 30s, 1m, 2m...
+func webhookBackoff(attempts int) time.Duration {
+	return backoff(attempts, webhookInitialBackoff, webhookMaxBackoff)
+}
+
+// backoff doubles the initial delay by each of the failed attempts, up to the max.
+func backoff(attempts int, initial, max time.Duration) time.Duration {
+	delay := initial
+
+	for i := 0; i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+
+	if delay > max {
+		delay = max
+	}
+
+	return delay
+}
+
+// postWebhook posts the event, any 2xx status accepts it. The body is signed by the secret of the webhook,
+// the X-Cargomail-Signature is t=<unix time>,v1=<hex HMAC-SHA256 of "<unix time>.<body>">, so the receiver
+// tells the request comes from the mailbox and rejects the replays of the old ones.
+func postWebhook(ctx context.Context, client *http.Client, delivery *repository.WebhookDelivery) error {
+	body, err := json.Marshal(delivery.Event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	mac := hmac.New(sha256.New, []byte(delivery.Secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("User-Agent", "cargomail-webhook")
+	req.Header.Set("X-Cargomail-Event", delivery.Event.Type)
+	req.Header.Set("X-Cargomail-Delivery", strconv.FormatInt(delivery.Id, 10))
+	req.Header.Set("X-Cargomail-Signature", "t="+timestamp+",v1="+hex.EncodeToString(mac.Sum(nil)))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+
+	return nil
+}
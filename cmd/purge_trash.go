@@ -0,0 +1,53 @@
+package cargomail
+
+import (
+	"cargomail/internal/mailbox/repository"
+	"cargomail/internal/mailbox/storage"
+	"cargomail/internal/shared/config"
+	"flag"
+	"log"
+	"time"
+)
+
+// PurgeTrash runs the trash sweeper once, e.g. from cron. The --days flag overrides the configured retention,
+// --days=0 purges the whole trash.
+func PurgeTrash(args []string) error {
+	flags := flag.NewFlagSet("purge-trash", flag.ContinueOnError)
+	days := flags.Int("days", -1, "retention window in days (defaults to trashRetentionDays)")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	retention := config.TrashRetention()
+	if *days >= 0 {
+		retention = time.Duration(*days) * 24 * time.Hour
+	} else if retention == 0 {
+		log.Print("purge-trash: the trash retention is disabled, nothing to do")
+		return nil
+	}
+
+	db, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	repo := repository.NewRepository(db)
+
+	blobStore, fileStore, err := storage.NewStores()
+	if err != nil {
+		return err
+	}
+
+	// the stored bytes of the purged blobs are removed too
+	purge, err := storage.NewStorageWithStores(repo, blobStore, fileStore).Trash.Purge(retention)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("purge-trash: %d blobs, %d contacts, %d drafts purged", purge.Blobs, purge.Contacts, purge.Drafts)
+
+	return nil
+}
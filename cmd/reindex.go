@@ -0,0 +1,60 @@
+package cargomail
+
+import (
+	"cargomail/internal/mailbox/repository"
+	"flag"
+	"log"
+	"time"
+)
+
+// Reindex rebuilds the search index of the given type (contacts|messages|drafts) from the source rows.
+// The rebuild is batched, so it is safe to run it while the server is serving traffic.
+func Reindex(args []string) error {
+	flags := flag.NewFlagSet("reindex", flag.ContinueOnError)
+	index := flags.String("type", "", "search index to rebuild (contacts|messages|drafts)")
+	batchSize := flags.Int("batch", 1000, "number of rows rebuilt per transaction")
+	dryRun := flags.Bool("dry-run", false, "report the index staleness only")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	db, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	repository := repository.NewRepository(db)
+
+	staleness, err := repository.Search.Staleness(*index)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("reindex %s: rows %d, indexed %d, missing %d, orphaned %d, outdated %d",
+		staleness.Index, staleness.Rows, staleness.Indexed, staleness.Missing, staleness.Orphaned, staleness.Outdated)
+
+	if *dryRun {
+		if staleness.Stale() {
+			log.Printf("reindex %s: the index is stale", staleness.Index)
+		} else {
+			log.Printf("reindex %s: the index is up to date", staleness.Index)
+		}
+		return nil
+	}
+
+	start := time.Now()
+
+	done, err := repository.Search.Reindex(*index, *batchSize, func(done, total int64) {
+		log.Printf("reindex %s: %d/%d rows", *index, done, total)
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("reindex %s: %d rows reindexed in %v", *index, done, time.Since(start).Round(time.Millisecond))
+
+	return nil
+}
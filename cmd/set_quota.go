@@ -0,0 +1,56 @@
+package cargomail
+
+import (
+	"cargomail/internal/mailbox/repository"
+	"errors"
+	"flag"
+	"log"
+)
+
+// SetQuota sets the storage quota of a user, e.g. cargomail set-quota --username=bob --bytes=10737418240.
+// The --bytes=0 lifts the limit, --reset puts the user back on the configured quotaBytes.
+func SetQuota(args []string) error {
+	flags := flag.NewFlagSet("set-quota", flag.ContinueOnError)
+	username := flags.String("username", "", "the user")
+	bytes := flags.Int64("bytes", -1, "the quota in bytes, 0 = unlimited")
+	reset := flags.Bool("reset", false, "use the configured quota")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if len(*username) == 0 {
+		return errors.New("missing --username")
+	}
+
+	var quota *int64
+	if !*reset {
+		if *bytes < 0 {
+			return errors.New("missing --bytes or --reset")
+		}
+		quota = bytes
+	}
+
+	db, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	repository := repository.NewRepository(db)
+
+	err = repository.User.SetQuota(*username, quota)
+	if err != nil {
+		return err
+	}
+
+	profile, err := repository.User.GetProfile(*username)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("set-quota %s: %d bytes", profile.Username, profile.QuotaBytes)
+
+	return nil
+}
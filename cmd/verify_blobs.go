@@ -0,0 +1,75 @@
+package cargomail
+
+import (
+	"cargomail/internal/mailbox/repository"
+	"cargomail/internal/mailbox/storage"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+)
+
+var ErrCorruptContents = errors.New("corrupt or missing contents found")
+
+// VerifyBlobs re-hashes the stored bytes of the blobs and the files and reports the ones that don't match
+// their digest, e.g. the bit-rot of the backing store. It fails if any content is corrupt or missing.
+func VerifyBlobs(args []string) error {
+	flags := flag.NewFlagSet("verify-blobs", flag.ContinueOnError)
+	resource := flags.String("type", "", "contents to verify (blobs|files), both by default")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if len(*resource) > 0 && *resource != repository.BlobsResource && *resource != repository.FilesResource {
+		return fmt.Errorf("%w: %s", repository.ErrUnknownResource, *resource)
+	}
+
+	db, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	repo := repository.NewRepository(db)
+
+	blobStore, fileStore, err := storage.NewStores()
+	if err != nil {
+		return err
+	}
+
+	stores := []struct {
+		resource string
+		store    storage.BlobStore
+	}{
+		{repository.BlobsResource, blobStore},
+		{repository.FilesResource, fileStore},
+	}
+
+	var damaged int64
+
+	for _, s := range stores {
+		if len(*resource) > 0 && *resource != s.resource {
+			continue
+		}
+
+		verification, err := storage.VerifyContents(repo, s.resource, s.store, func(content *repository.StoredContent, err error) {
+			log.Printf("verify-blobs %s: %s (%d bytes, %d rows): %v", s.resource, content.Digest, content.Size, content.Rows, err)
+		})
+		if err != nil {
+			return err
+		}
+
+		log.Printf("verify-blobs %s: %d checked, %d corrupt, %d missing, %d failed",
+			s.resource, verification.Checked, verification.Corrupt, verification.Missing, verification.Failed)
+
+		damaged += verification.Corrupt + verification.Missing
+	}
+
+	if damaged > 0 {
+		return fmt.Errorf("%w: %d", ErrCorruptContents, damaged)
+	}
+
+	return nil
+}
@@ -14,6 +14,7 @@ import (
 type Agent struct {
 	MessageSubmission UseMessageSubmissionAgent
 	ResourceFetch     UseResourceFetchAgent
+	SmtpSubmission    UseSmtpSubmissionAgent
 }
 
 func NewAgent(repository repository.Repository) Agent {
@@ -60,5 +61,6 @@ func NewAgent(repository repository.Repository) Agent {
 	return Agent{
 		MessageSubmission: &MessageSubmissionAgent{repository, httpClient, dohClient},
 		ResourceFetch:     &ResourceFetchAgent{repository},
+		SmtpSubmission:    &SmtpSubmissionAgent{},
 	}
 }
@@ -0,0 +1,143 @@
+package agent
+
+import (
+	"bytes"
+	"cargomail/internal/mailbox/repository"
+	"cargomail/internal/shared/config"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/smtp"
+	"net/textproto"
+)
+
+type UseSmtpSubmissionAgent interface {
+	Send(ctx context.Context, from string, recipients []string, message []byte) error
+}
+
+// SmtpSubmissionAgent sends the messages through the relay of the config. The 4xx replies and the network
+// errors are the temporary failures the client may retry, the 5xx replies the permanent ones.
+type SmtpSubmissionAgent struct{}
+
+func (a *SmtpSubmissionAgent) Send(ctx context.Context, from string, recipients []string, message []byte) error {
+	addr := config.SmtpRelayAddr()
+	if len(addr) == 0 {
+		return repository.ErrSmtpRelayNotConfigured
+	}
+
+	timeout := config.SmtpRelayTimeout()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := a.send(ctx, addr, from, recipients, message)
+	if err != nil {
+		return smtpError(err)
+	}
+
+	return nil
+}
+
+func (a *SmtpSubmissionAgent) send(ctx context.Context, addr, from string, recipients []string, message []byte) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+
+	tlsConfig := &tls.Config{ServerName: host}
+
+	dialer := &net.Dialer{}
+
+	var conn net.Conn
+
+	if config.SmtpRelayTLS() == "tls" {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// the context bounds the whole conversation
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	err = c.Hello(config.Configuration.DomainName)
+	if err != nil {
+		return err
+	}
+
+	if config.SmtpRelayTLS() == "starttls" {
+		err = c.StartTLS(tlsConfig)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(config.Configuration.SmtpRelayUsername) > 0 {
+		auth := smtp.PlainAuth("", config.Configuration.SmtpRelayUsername, config.Configuration.SmtpRelayPassword, host)
+
+		err = c.Auth(auth)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = c.Mail(from)
+	if err != nil {
+		return err
+	}
+
+	for _, recipient := range recipients {
+		err = c.Rcpt(recipient)
+		if err != nil {
+			return err
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+
+	_, err = bytes.NewReader(message).WriteTo(w)
+	if err != nil {
+		return err
+	}
+
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+// smtpError wraps the error of the relay in the temporary or the permanent failure.
+func smtpError(err error) error {
+	var protocolErr *textproto.Error
+	if errors.As(err, &protocolErr) && protocolErr.Code >= 500 {
+		return fmt.Errorf("%w: %v", repository.ErrSmtpPermanentFailure, err)
+	}
+
+	var netErr net.Error
+	if errors.As(err, &protocolErr) || errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %v", repository.ErrSmtpTemporaryFailure, err)
+	}
+
+	// e.g. the TLS or the auth not supported by the relay
+	return fmt.Errorf("%w: %v", repository.ErrSmtpPermanentFailure, err)
+}
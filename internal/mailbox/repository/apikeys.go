@@ -0,0 +1,293 @@
+package repository
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	b64 "encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+type UseApiKeyRepository interface {
+	Create(user *User, apiKey *ApiKey) (*NewApiKey, error)
+	List(user *User) ([]*ApiKey, error)
+	Revoke(user *User, id string) error
+	GetUserByKey(key string) (*User, error)
+}
+
+type ApiKeyRepository struct {
+	db       *sql.DB
+	timeouts Timeouts
+}
+
+const ApiKeyPrefix = "cmk_"
+
+// ApiKeyScopes are the scopes an API key may be restricted to, a key without scopes has them all.
+var ApiKeyScopes = []string{
+	"contacts:read", "contacts:write",
+	"files:read", "files:write",
+	"blobs:read", "blobs:write",
+	"drafts:read", "drafts:write",
+	"messages:read", "messages:write",
+	"threads:read", "threads:write",
+	"searches:read", "searches:write",
+}
+
+type ApiKey struct {
+	Id         string     `json:"id"`
+	UserId     int64      `json:"-"`
+	Name       string     `json:"name"`
+	KeyHash    string     `json:"-"`
+	Scopes     ScopeList  `json:"scopes"`
+	DeviceId   string     `json:"-"`
+	ExpiresAt  *Timestamp `json:"expiresAt"`
+	LastUsedAt *Timestamp `json:"lastUsedAt"`
+	CreatedAt  Timestamp  `json:"createdAt"`
+}
+
+// NewApiKey is the only place the plaintext key is ever returned.
+type NewApiKey struct {
+	*ApiKey
+	Key string `json:"key"`
+}
+
+type ScopeList []string
+
+func (s ScopeList) Value() (driver.Value, error) {
+	if s == nil {
+		return nil, nil
+	}
+
+	return json.Marshal(s)
+}
+
+func (s *ScopeList) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*s = nil
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), s)
+	case []byte:
+		return json.Unmarshal(v, s)
+	default:
+		return errors.New("type assertion failed")
+	}
+}
+
+func (k *ApiKey) Scan() []interface{} {
+	return scanColumns(k)
+}
+
+func ValidScope(scope string) bool {
+	for _, apiKeyScope := range ApiKeyScopes {
+		if scope == apiKeyScope {
+			return true
+		}
+	}
+	return false
+}
+
+func validScopes(scopes []string) bool {
+	for _, scope := range scopes {
+		if !ValidScope(scope) {
+			return false
+		}
+	}
+	return true
+}
+
+func hashApiKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func generateApiKey() (string, error) {
+	b := make([]byte, 32)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return ApiKeyPrefix + b64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Create generates the key and stores its hash only, the plaintext key can't be retrieved later.
+func (r *ApiKeyRepository) Create(user *User, apiKey *ApiKey) (*NewApiKey, error) {
+	apiKey.Name = strings.TrimSpace(apiKey.Name)
+	if len(apiKey.Name) == 0 {
+		return nil, ErrMissingNameField
+	}
+
+	if !validScopes(apiKey.Scopes) {
+		return nil, ErrInvalidScope
+	}
+
+	// an empty list of scopes would make an useless key
+	if apiKey.Scopes != nil && len(apiKey.Scopes) == 0 {
+		return nil, ErrInvalidScope
+	}
+
+	var expiresAt *string
+
+	if apiKey.ExpiresAt != nil {
+		t := time.UnixMilli(int64(*apiKey.ExpiresAt))
+		if !t.After(time.Now()) {
+			return nil, ErrInvalidExpiry
+		}
+
+		ts := sqliteTimestamp(&t)
+		expiresAt = &ts
+	}
+
+	key, err := generateApiKey()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	query := `
+		INSERT
+			INTO "ApiKey" ("userId", "name", "keyHash", "scopes", "expiresAt")
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING * ;`
+
+	args := []interface{}{user.Id, apiKey.Name, hashApiKey(key), apiKey.Scopes, expiresAt}
+
+	err = r.db.QueryRowContext(ctx, query, args...).Scan(apiKey.Scan()...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &NewApiKey{ApiKey: apiKey, Key: key}, nil
+}
+
+func (r *ApiKeyRepository) List(user *User) ([]*ApiKey, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	query := `
+		SELECT *
+			FROM "ApiKey"
+			WHERE "userId" = $1
+			ORDER BY "createdAt" DESC;`
+
+	rows, err := r.db.QueryContext(ctx, query, user.Id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	apiKeys := []*ApiKey{}
+
+	for rows.Next() {
+		var apiKey ApiKey
+
+		err := rows.Scan(apiKey.Scan()...)
+		if err != nil {
+			return nil, err
+		}
+
+		apiKeys = append(apiKeys, &apiKey)
+	}
+
+	return apiKeys, rows.Err()
+}
+
+func (r *ApiKeyRepository) Revoke(user *User, id string) error {
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	query := `
+		DELETE
+			FROM "ApiKey"
+			WHERE "userId" = $1 AND
+			"id" = $2;`
+
+	args := []interface{}{user.Id, id}
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrApiKeyNotFound
+	}
+
+	return nil
+}
+
+// GetUserByKey resolves the key to its owner. The user gets the scopes of the key and the device id
+// of the key, so the sync of the key does not clash with the devices of the user.
+func (r *ApiKeyRepository) GetUserByKey(key string) (*User, error) {
+	if !strings.HasPrefix(key, ApiKeyPrefix) {
+		return nil, ErrInvalidApiKey
+	}
+
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	query := `
+		SELECT "User"."id", "User"."username", "User"."passwordHash", "User"."firstName", "User"."lastName", "User"."createdAt",
+			"ApiKey"."id", "ApiKey"."scopes", "ApiKey"."deviceId"
+			FROM "User"
+			INNER JOIN "ApiKey"
+			ON "User"."id" = "ApiKey"."userId"
+			WHERE "ApiKey"."keyHash" = $1 AND
+			("ApiKey"."expiresAt" IS NULL OR "ApiKey"."expiresAt" > CURRENT_TIMESTAMP);`
+
+	var user User
+	var apiKeyId, deviceId string
+	var scopes ScopeList
+
+	err := r.db.QueryRowContext(ctx, query, hashApiKey(key)).Scan(
+		&user.Id,
+		&user.Username,
+		&user.Password.hash,
+		&user.FirstName,
+		&user.LastName,
+		&user.CreatedAt,
+		&apiKeyId,
+		&scopes,
+		&deviceId,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrInvalidApiKey
+		default:
+			return nil, err
+		}
+	}
+
+	user.Scopes = scopes
+	user.DeviceId = &deviceId
+
+	// 300 = 5 minutes
+	query = `
+		UPDATE "ApiKey"
+			SET "lastUsedAt" = CURRENT_TIMESTAMP
+			WHERE "id" = $1 AND
+			("lastUsedAt" IS NULL OR ROUND((JULIANDAY(CURRENT_TIMESTAMP) - JULIANDAY("lastUsedAt")) * 86400) > 300);`
+
+	_, err = r.db.ExecContext(ctx, query, apiKeyId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
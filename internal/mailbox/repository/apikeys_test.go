@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApiKey(t *testing.T) {
+	repository, db := newTestRepository(t)
+
+	alice := newTestUser(t, repository, "alice")
+	bob := newTestUser(t, repository, "bob")
+
+	newApiKey, err := repository.ApiKeys.Create(alice, &ApiKey{Name: " cli ", Scopes: ScopeList{"contacts:read"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(newApiKey.Key, ApiKeyPrefix) || newApiKey.Name != "cli" {
+		t.Errorf("got the key %q named %q", newApiKey.Key, newApiKey.Name)
+	}
+
+	// the plaintext key is not stored
+	var stored int
+
+	err = db.QueryRow(`SELECT count(*) FROM "ApiKey" WHERE "keyHash" = $1;`, newApiKey.Key).Scan(&stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stored != 0 {
+		t.Error("the plaintext key stored")
+	}
+
+	user, err := repository.ApiKeys.GetUserByKey(newApiKey.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if user.Id != alice.Id || !user.HasScope("contacts:read") || user.HasScope("contacts:write") {
+		t.Errorf("got the user %d of the scopes %v", user.Id, user.Scopes)
+	}
+
+	if user.DeviceId == nil || *user.DeviceId != newApiKey.DeviceId || *user.DeviceId == *alice.DeviceId {
+		t.Errorf("got the device id %v of the key, want the one of the key", user.DeviceId)
+	}
+
+	apiKeys, err := repository.ApiKeys.List(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(apiKeys) != 1 || apiKeys[0].Id != newApiKey.Id {
+		t.Errorf("got %d keys listed, want the created one", len(apiKeys))
+	}
+
+	// the key of another user is not found
+	err = repository.ApiKeys.Revoke(bob, newApiKey.Id)
+	if !errors.Is(err, ErrApiKeyNotFound) {
+		t.Errorf("got %v revoking the key of another user, want %v", err, ErrApiKeyNotFound)
+	}
+
+	err = repository.ApiKeys.Revoke(alice, newApiKey.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = repository.ApiKeys.GetUserByKey(newApiKey.Key)
+	if !errors.Is(err, ErrInvalidApiKey) {
+		t.Errorf("got %v for the revoked key, want %v", err, ErrInvalidApiKey)
+	}
+}
+
+func TestApiKeyExpired(t *testing.T) {
+	repository, db := newTestRepository(t)
+
+	alice := newTestUser(t, repository, "alice")
+
+	expiresAt := Timestamp(time.Now().Add(time.Hour).UnixMilli())
+
+	newApiKey, err := repository.ApiKeys.Create(alice, &ApiKey{Name: "cli", ExpiresAt: &expiresAt})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.Exec(`UPDATE "ApiKey" SET "expiresAt" = datetime('now', '-1 minute') WHERE "id" = $1;`, newApiKey.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = repository.ApiKeys.GetUserByKey(newApiKey.Key)
+	if !errors.Is(err, ErrInvalidApiKey) {
+		t.Errorf("got %v for the expired key, want %v", err, ErrInvalidApiKey)
+	}
+
+	_, err = repository.ApiKeys.GetUserByKey("cmk_unknown")
+	if !errors.Is(err, ErrInvalidApiKey) {
+		t.Errorf("got %v for an unknown key, want %v", err, ErrInvalidApiKey)
+	}
+}
+
+func TestCreateApiKeyInvalid(t *testing.T) {
+	repository, _ := newTestRepository(t)
+
+	alice := newTestUser(t, repository, "alice")
+
+	expired := Timestamp(time.Now().Add(-time.Hour).UnixMilli())
+
+	tests := []struct {
+		name   string
+		apiKey *ApiKey
+		want   error
+	}{
+		{"no name", &ApiKey{Name: " "}, ErrMissingNameField},
+		{"unknown scope", &ApiKey{Name: "cli", Scopes: ScopeList{"contacts:delete"}}, ErrInvalidScope},
+		{"no scopes", &ApiKey{Name: "cli", Scopes: ScopeList{}}, ErrInvalidScope},
+		{"expired", &ApiKey{Name: "cli", ExpiresAt: &expired}, ErrInvalidExpiry},
+	}
+
+	for _, tt := range tests {
+		_, err := repository.ApiKeys.Create(alice, tt.apiKey)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
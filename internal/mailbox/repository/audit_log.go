@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+)
+
+type UseAuditLogRepository interface {
+	List(user *User, filter *AuditLogFilter) (*AuditLogList, error)
+}
+
+// AuditLogRepository reads the audit log, the entries are written by the triggers of the resource tables
+// in the transaction of the change, see audit_triggers.sql.
+type AuditLogRepository struct {
+	db       *sql.DB
+	timeouts Timeouts
+}
+
+const DefaultAuditLogLimit = 100
+
+var auditLogResources = map[string]bool{
+	"blobs":         true,
+	"files":         true,
+	"drafts":        true,
+	"messages":      true,
+	"labels":        true,
+	"contacts":      true,
+	"contactGroups": true,
+}
+
+var auditLogActions = map[string]bool{
+	"create":  true,
+	"update":  true,
+	"trash":   true,
+	"untrash": true,
+	"delete":  true,
+}
+
+type AuditEntry struct {
+	Id         int64     `json:"-"`
+	UserId     int64     `json:"-"`
+	DeviceId   *string   `json:"deviceId"`
+	Action     string    `json:"action"`
+	Resource   string    `json:"resource"`
+	ResourceId string    `json:"resourceId"`
+	HistoryId  int64     `json:"historyId"`
+	CreatedAt  Timestamp `json:"createdAt"`
+}
+
+func (e *AuditEntry) Scan() []interface{} {
+	return scanColumns(e)
+}
+
+// AuditLogFilter narrows the audit log, the empty fields match all, the bounds of the time range are
+// inclusive. The zero limit means the default one.
+type AuditLogFilter struct {
+	Resource string
+	Action   string
+	Since    *time.Time
+	Until    *time.Time
+	Limit    int
+	Cursor   string
+}
+
+type AuditLogList struct {
+	Entries    []*AuditEntry `json:"entries"`
+	NextCursor string        `json:"nextCursor,omitempty"`
+}
+
+// List lists the entries of the user, the recent first.
+func (r *AuditLogRepository) List(user *User, filter *AuditLogFilter) (*AuditLogList, error) {
+	if filter == nil {
+		filter = &AuditLogFilter{}
+	}
+
+	if len(filter.Resource) > 0 && !auditLogResources[filter.Resource] {
+		return nil, ErrUnknownResource
+	}
+
+	if len(filter.Action) > 0 && !auditLogActions[filter.Action] {
+		return nil, ErrInvalidAuditAction
+	}
+
+	options := &ListOptions{
+		Limit:         filter.Limit,
+		Cursor:        filter.Cursor,
+		CreatedAfter:  filter.Since,
+		CreatedBefore: filter.Until,
+	}
+
+	if options.Limit == 0 {
+		options.Limit = DefaultAuditLogLimit
+	}
+
+	q := newListQuery(user.Id)
+
+	if len(filter.Resource) > 0 {
+		q.and(`"resource" = ` + q.arg(filter.Resource))
+	}
+
+	if len(filter.Action) > 0 {
+		q.and(`"action" = ` + q.arg(filter.Action))
+	}
+
+	err := options.dateRange(q)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := options.page(q, `"createdAt"`, true)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	query := `
+		SELECT CAST("createdAt" AS TEXT), "rowid", *
+			FROM "AuditLog"
+			WHERE "userId" = $1` + q.whereClause() + page + `;`
+
+	rows, err := r.db.QueryContext(ctx, query, q.args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	auditLogList := &AuditLogList{
+		Entries: []*AuditEntry{},
+	}
+
+	var cursor string
+
+	for rows.Next() {
+		var entry AuditEntry
+		var sortKey string
+		var rowId int64
+
+		// the extra row tells there is a next page
+		if len(auditLogList.Entries) == options.Limit {
+			auditLogList.NextCursor = cursor
+			break
+		}
+
+		err := rows.Scan(append([]interface{}{&sortKey, &rowId}, entry.Scan()...)...)
+		if err != nil {
+			return nil, err
+		}
+
+		auditLogList.Entries = append(auditLogList.Entries, &entry)
+		cursor = encodeCursor(sortKey, rowId)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return auditLogList, nil
+}
@@ -0,0 +1,220 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"time"
+)
+
+// The resources of the stored contents, i.e. the stores the digests point into.
+const (
+	BlobsResource = "blobs"
+	FilesResource = "files"
+)
+
+type UseBlobContentRepository interface {
+	Release(resource string) ([]string, error)
+	Unreferenced(resource string) ([]string, error)
+	Referenced(resource string) (map[string]bool, error)
+	Stored(resource string) ([]*StoredContent, error)
+}
+
+// BlobContentRepository keeps one row per distinct content (by the hash of the plain bytes) of the blobs
+// and the files. The rows referencing the content are counted by the triggers of the Blob and the File
+// tables, so a content is shared by the equal uploads and released with the last one.
+type BlobContentRepository struct {
+	db       *sql.DB
+	timeouts Timeouts
+}
+
+// StoredContent is a distinct content of the rows of a resource, i.e. the stored bytes to be verified.
+type StoredContent struct {
+	Digest   string
+	Metadata *BlobMetadata
+	Size     int64
+	Rows     int64 // referencing the content
+}
+
+var contentTables = map[string]string{
+	BlobsResource: `"Blob"`,
+	FilesResource: `"File"`,
+}
+
+type contentMetadata interface {
+	driver.Valuer
+	sql.Scanner
+}
+
+// acquireContent returns the digest of the already stored content of the hash and its metadata, or
+// registers the digest and the metadata of the new upload. It runs in the transaction of the insert,
+// whose trigger takes the reference.
+func acquireContent(ctx context.Context, tx *sql.Tx, resource, hash, digest string, metadata contentMetadata) (string, error) {
+	if len(hash) == 0 {
+		return digest, nil
+	}
+
+	query := `
+		SELECT "digest", "metadata"
+			FROM "BlobContent"
+			WHERE "resource" = $1 AND
+			"hash" = $2 ;`
+
+	var storedDigest string
+
+	err := tx.QueryRowContext(ctx, query, resource, hash).Scan(&storedDigest, metadata)
+	if err == nil {
+		return storedDigest, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+
+	query = `
+		INSERT INTO
+			"BlobContent" ("resource", "hash", "digest", "metadata")
+			VALUES ($1, $2, $3, $4) ;`
+
+	_, err = tx.ExecContext(ctx, query, resource, hash, digest, metadata)
+	if err != nil {
+		return "", err
+	}
+
+	return digest, nil
+}
+
+// Release forgets the contents no longer referenced and returns their digests, the caller removes the
+// stored bytes.
+func (r *BlobContentRepository) Release(resource string) ([]string, error) {
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	query := `
+		DELETE
+			FROM "BlobContent"
+			WHERE "resource" = $1 AND
+			"refcount" < 1
+			RETURNING "digest" ;`
+
+	return queryDigests(ctx, r.db, query, resource)
+}
+
+// Unreferenced returns the digests Release would forget, e.g. for a dry run.
+func (r *BlobContentRepository) Unreferenced(resource string) ([]string, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	query := `
+		SELECT "digest"
+			FROM "BlobContent"
+			WHERE "resource" = $1 AND
+			"refcount" < 1 ;`
+
+	return queryDigests(ctx, r.db, query, resource)
+}
+
+// Referenced returns the digests of the stored bytes in use, i.e. of the rows of the resource (the trashed
+// ones included) and of the registered contents. A content is counted whatever its refcount, as a released
+// one is no longer handed out, while a zero refcount may be taken again until it is released.
+func (r *BlobContentRepository) Referenced(resource string) (map[string]bool, error) {
+	table, ok := contentTables[resource]
+	if !ok {
+		return nil, ErrUnknownResource
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT "digest"
+			FROM ` + table + `
+		UNION
+		SELECT "digest"
+			FROM "BlobContent"
+			WHERE "resource" = $1 ;`
+
+	digests, err := queryDigests(ctx, r.db, query, resource)
+	if err != nil {
+		return nil, err
+	}
+
+	referenced := make(map[string]bool, len(digests))
+	for _, digest := range digests {
+		referenced[digest] = true
+	}
+
+	return referenced, nil
+}
+
+// Stored returns the distinct contents of the rows of the resource, the trashed ones included.
+func (r *BlobContentRepository) Stored(resource string) ([]*StoredContent, error) {
+	table, ok := contentTables[resource]
+	if !ok {
+		return nil, ErrUnknownResource
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT "digest", min("metadata"), max("size"), count(*)
+			FROM ` + table + `
+			WHERE "metadata" IS NOT NULL
+			GROUP BY "digest"
+			ORDER BY "digest" ;`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	contents := []*StoredContent{}
+
+	for rows.Next() {
+		content := &StoredContent{Metadata: &BlobMetadata{}}
+
+		err := rows.Scan(&content.Digest, content.Metadata, &content.Size, &content.Rows)
+		if err != nil {
+			return nil, err
+		}
+
+		contents = append(contents, content)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return contents, nil
+}
+
+func queryDigests(ctx context.Context, db *sql.DB, query string, args ...interface{}) ([]string, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	digests := []string{}
+
+	for rows.Next() {
+		var digest string
+
+		err := rows.Scan(&digest)
+		if err != nil {
+			return nil, err
+		}
+
+		digests = append(digests, digest)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return digests, nil
+}
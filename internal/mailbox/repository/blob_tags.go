@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"unicode"
+)
+
+const maxBlobTagLength = 64
+
+// BlobTags are the tags of the blob, the tags are per user and case-insensitive, the first spelling
+// of a tag is kept.
+type BlobTags struct {
+	Id   string  `json:"id"` // of the blob
+	Tags TagList `json:"tags"`
+}
+
+type TagList []string
+
+func (l *TagList) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*l = TagList{}
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), l)
+	case []byte:
+		return json.Unmarshal(v, l)
+	default:
+		return errors.New("type assertion failed")
+	}
+}
+
+func (t *BlobTags) Scan() []interface{} {
+	return scanColumns(t)
+}
+
+func blobTag(tag string) (string, error) {
+	tag = strings.TrimSpace(tag)
+
+	if len(tag) == 0 {
+		return "", ErrMissingTagField
+	}
+
+	if len(tag) > maxBlobTagLength || strings.IndexFunc(tag, unicode.IsControl) >= 0 {
+		return "", ErrBlobWrongTag
+	}
+
+	return tag, nil
+}
+
+func getBlobTags(ctx context.Context, tx *sql.Tx, user *User, id string) (*BlobTags, error) {
+	query := `
+		SELECT "id",
+			(SELECT json_group_array("tag")
+				FROM (SELECT "tag"
+					FROM "BlobTag"
+					WHERE "blobId" = "Blob"."id"
+					ORDER BY "tag"))
+			FROM "Blob"
+			WHERE "userId" = $1 AND
+			"id" = $2 AND
+			"lastStmt" < 2;`
+
+	tags := &BlobTags{}
+
+	err := tx.QueryRowContext(ctx, query, user.Id, id).Scan(tags.Scan()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrBlobNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return tags, nil
+}
+
+// AddTag tags the blob, the tag the blob has already is skipped.
+func (r *BlobRepository) AddTag(user *User, id string, tag string) (*BlobTags, error) {
+	query := `
+		INSERT OR IGNORE
+			INTO "BlobTag" ("blobId", "userId", "tag")
+			VALUES ($1, $2, $3);`
+
+	return r.modifyTags(user, id, tag, query)
+}
+
+func (r *BlobRepository) RemoveTag(user *User, id string, tag string) (*BlobTags, error) {
+	query := `
+		DELETE
+			FROM "BlobTag"
+			WHERE "blobId" = $1 AND
+			"userId" = $2 AND
+			"tag" = $3;`
+
+	return r.modifyTags(user, id, tag, query)
+}
+
+// modifyTags runs the tag statement and attributes the change of the blob to the device of the user, so
+// the change is not synced back to it.
+func (r *BlobRepository) modifyTags(user *User, id string, tag string, stmt string) (*BlobTags, error) {
+	tag, err := blobTag(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// the trashed blobs can't be tagged
+	_, err = getBlobTags(ctx, tx, user, id)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := tx.ExecContext(ctx, stmt, id, user.Id, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if affected > 0 {
+		query := `
+			UPDATE "Blob"
+				SET "deviceId" = $1
+				WHERE "userId" = $2 AND
+				"id" = $3;`
+
+		_, err = tx.ExecContext(ctx, query, user.DeviceId, user.Id, id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tags, err := getBlobTags(ctx, tx, user, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// ListTags lists the tags of the tagged blobs, the trashed ones aside.
+func (r *BlobRepository) ListTags(user *User) ([]*BlobTags, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	query := `
+		SELECT "blobId", json_group_array("tag")
+			FROM (SELECT "BlobTag"."blobId", "BlobTag"."tag"
+				FROM "BlobTag"
+				INNER JOIN "Blob" ON "Blob"."id" = "BlobTag"."blobId"
+				WHERE "BlobTag"."userId" = $1 AND
+				"Blob"."lastStmt" < 2
+				ORDER BY "BlobTag"."blobId", "BlobTag"."tag")
+			GROUP BY "blobId";`
+
+	rows, err := reader(r.db, r.replica, user).QueryContext(ctx, query, user.Id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tagList := []*BlobTags{}
+
+	for rows.Next() {
+		tags := &BlobTags{}
+
+		err := rows.Scan(tags.Scan()...)
+		if err != nil {
+			return nil, err
+		}
+
+		tagList = append(tagList, tags)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tagList, nil
+}
+
+// ListByTag lists the blobs of the tag like the List does, the tags of the options are intersected.
+func (r *BlobRepository) ListByTag(user *User, folder int, tag string, options *ListOptions) (*BlobList, error) {
+	tag, err := blobTag(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	tagged := ListOptions{}
+	if options != nil {
+		tagged = *options
+	}
+
+	tagged.Tags = append([]string{tag}, tagged.Tags...)
+
+	return r.List(user, folder, &tagged)
+}
@@ -6,31 +6,50 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
-	"reflect"
-	"time"
+	"strings"
 )
 
 type UseBlobRepository interface {
 	Create(user *User, blob *Blob) (*Blob, error)
-	List(user *User, folder int) (*BlobList, error)
+	List(user *User, folder int, options *ListOptions) (*BlobList, error)
+	ListEach(user *User, folder int, options *ListOptions, each func(*Blob) error) (*BlobList, error)
+	ListTrashed(user *User) (*BlobList, error)
+	Search(user *User, q string, options *ListOptions) ([]*Blob, error)
+	Count(user *User) (*Count, error)
+	UsageBytes(user *User) (*Usage, error)
 	Sync(user *User, history *History) (*BlobSync, error)
 	Update(user *User, blob *Blob) (*Blob, error)
 	Trash(user *User, ids string) error
 	Untrash(user *User, ids string) error
 	Delete(user *User, ids string) ([]*Blob, error)
+	EmptyTrash(user *User) error
 	CleanAndCreate(user *User, blobs []*Blob, ids string) ([]*Blob, []*Blob, error)
 	GetById(user *User, id string) (*Blob, error)
 	GetByDigest(user *User, digest string) (*Blob, error)
+	AddTag(user *User, id string, tag string) (*BlobTags, error)
+	RemoveTag(user *User, id string, tag string) (*BlobTags, error)
+	ListTags(user *User) ([]*BlobTags, error)
+	ListByTag(user *User, folder int, tag string, options *ListOptions) (*BlobList, error)
+	CreateCollection(user *User, collection *Collection) (*Collection, error)
+	ListCollections(user *User) (*CollectionList, error)
+	UpdateCollection(user *User, collection *Collection) (*Collection, error)
+	DeleteCollection(user *User, id string) error
+	SyncCollections(user *User, history *History) (*CollectionSync, error)
+	MoveToCollection(user *User, collectionId string, blobIds []string) error
+	ListByCollection(user *User, id string, options *ListOptions) (*BlobList, error)
 }
 
 type BlobRepository struct {
-	db *sql.DB
+	db       *sql.DB
+	replica  *sql.DB // the List, Sync, Count and Search, nil = the db
+	timeouts Timeouts
 }
 
 type BlobMetadata struct {
 	Salt string `json:"salt"`
 	Key  string `json:"key,omitempty"`
 	Iv   string `json:"iv,omitempty"`
+	Hash string `json:"hash,omitempty"` // of the plain content, shared by the equal uploads
 }
 
 type Blob struct {
@@ -53,6 +72,12 @@ type Blob struct {
 	DeviceId    *string       `json:"-"`
 }
 
+var blobSortExprs = map[string]string{
+	"createdAt":  `"createdAt"`,
+	"modifiedAt": `coalesce("modifiedAt", "createdAt")`,
+	"name":       `"name" COLLATE NOCASE`,
+}
+
 type BlobDeleted struct {
 	Id        string  `json:"id"`
 	UserId    int64   `json:"-"`
@@ -67,6 +92,7 @@ type BlobList struct {
 
 type BlobSync struct {
 	History       int64          `json:"lastHistoryId"`
+	NextPollAfter int            `json:"nextPollAfter"`
 	BlobsInserted []*Blob        `json:"inserted"`
 	BlobsUpdated  []*Blob        `json:"updated"`
 	BlobsTrashed  []*Blob        `json:"trashed"`
@@ -87,31 +113,36 @@ func (v *BlobMetadata) Scan(value interface{}) error {
 }
 
 func (b *Blob) Scan() []interface{} {
-	s := reflect.ValueOf(b).Elem()
-	numCols := s.NumField()
-	columns := make([]interface{}, numCols)
-	for i := 0; i < numCols; i++ {
-		field := s.Field(i)
-		columns[i] = field.Addr().Interface()
-	}
-	return columns
+	return scanColumns(b)
 }
 
 func (b *BlobDeleted) Scan() []interface{} {
-	s := reflect.ValueOf(b).Elem()
-	numCols := s.NumField()
-	columns := make([]interface{}, numCols)
-	for i := 0; i < numCols; i++ {
-		field := s.Field(i)
-		columns[i] = field.Addr().Interface()
-	}
-	return columns
+	return scanColumns(b)
 }
 
 func (r BlobRepository) Create(user *User, blob *Blob) (*Blob, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := r.timeouts.write()
 	defer cancel()
 
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	err = checkQuota(ctx, tx, user.Id, blob.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	// an equal content stored already is shared
+	if blob.Metadata != nil {
+		blob.Digest, err = acquireContent(ctx, tx, BlobsResource, blob.Metadata.Hash, blob.Digest, blob.Metadata)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	query := `
 		INSERT INTO
 			"Blob" ("userId", "draftId", "deviceId", "folder", "digest", "name", "snippet", "path", "contentType", "size", "metadata")
@@ -124,33 +155,153 @@ func (r BlobRepository) Create(user *User, blob *Blob) (*Blob, error) {
 
 	args := []interface{}{user.Id, blob.DraftId, prefixedDeviceId, folder, blob.Digest, blob.Name, blob.Snippet, blob.Path, blob.ContentType, blob.Size, blob.Metadata}
 
-	err := r.db.QueryRowContext(ctx, query, args...).Scan(blob.Scan()...)
+	err = tx.QueryRowContext(ctx, query, args...).Scan(blob.Scan()...)
 	if err != nil {
 		return nil, err
 	}
 
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
 	return blob, nil
 }
 
-func (r BlobRepository) List(user *User, folder int) (*BlobList, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (r BlobRepository) List(user *User, folder int, options *ListOptions) (*BlobList, error) {
+	return r.list(user, options, newListQuery(user.Id, folder))
+}
+
+// ListEach lists the blobs like the List does, but passes each one to the each as it is read instead of
+// collecting them, the returned list holds the history only.
+func (r BlobRepository) ListEach(user *User, folder int, options *ListOptions, each func(*Blob) error) (*BlobList, error) {
+	return r.listEach(user, options, newListQuery(user.Id, folder), each)
+}
+
+// list lists the blobs matching the conditions of the query, the query holds the user id and the folder as
+// its first args
+func (r BlobRepository) list(user *User, options *ListOptions, q *listQuery) (*BlobList, error) {
+	blobs := []*Blob{}
+
+	blobList, err := r.listEach(user, options, q, func(blob *Blob) error {
+		blobs = append(blobs, blob)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	blobList.Blobs = blobs
+
+	return blobList, nil
+}
+
+func (r BlobRepository) listEach(user *User, options *ListOptions, q *listQuery, each func(*Blob) error) (*BlobList, error) {
+	ctx, cancel := r.timeouts.read()
 	defer cancel()
 
-	tx, err := r.db.BeginTx(ctx, nil)
+	tx, err := reader(r.db, r.replica, user).BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
 
+	if options == nil {
+		options = &ListOptions{}
+	}
+
+	orderBy, err := options.orderBy(blobSortExprs, "createdAt", "desc")
+	if err != nil {
+		return nil, err
+	}
+
+	err = options.dateRange(q)
+	if err != nil {
+		return nil, err
+	}
+
+	err = options.contentType(q)
+	if err != nil {
+		return nil, err
+	}
+
+	options.tags(q, "Blob")
+
 	// blob
 	query := `
 		SELECT *
 			FROM "Blob"
 			WHERE "userId" = $1 AND
-			CASE WHEN $2 == -1 THEN "folder" > $2 ELSE "folder" == $2 END AND			
-			"lastStmt" < 2;`
+			CASE WHEN $2 == -1 THEN "folder" > $2 ELSE "folder" == $2 END AND
+			"lastStmt" < 2` + q.whereClause() + orderBy + `;`
+
+	rows, err := tx.QueryContext(ctx, query, q.args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	blobList := &BlobList{}
 
-	args := []interface{}{user.Id, folder}
+	for rows.Next() {
+		var blob Blob
+
+		err := rows.Scan(blob.Scan()...)
+		if err != nil {
+			return nil, err
+		}
+
+		err = each(&blob)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// history
+	query = `
+		SELECT "lastHistoryId"
+			FROM "BlobHistorySeq"
+			WHERE "userId" = $1 ;`
+
+	args := []interface{}{user.Id}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&blobList.History)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return blobList, nil
+}
+
+// Search matches the terms of the query against the name and the snippet of the blobs. Only the sort
+// and the content type of the options apply.
+// ListTrashed lists the trashed blobs, the recently trashed first.
+func (r BlobRepository) ListTrashed(user *User) (*BlobList, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	tx, err := reader(r.db, r.replica, user).BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT *
+			FROM "Blob"
+			WHERE "userId" = $1 AND
+			"lastStmt" = 2
+			ORDER BY coalesce("modifiedAt", "createdAt") DESC, "rowid" DESC;`
+
+	args := []interface{}{user.Id}
 
 	rows, err := tx.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -184,8 +335,6 @@ func (r BlobRepository) List(user *User, folder int) (*BlobList, error) {
 			FROM "BlobHistorySeq"
 			WHERE "userId" = $1 ;`
 
-	args = []interface{}{user.Id}
-
 	err = tx.QueryRowContext(ctx, query, args...).Scan(&blobList.History)
 	if err != nil {
 		return nil, err
@@ -198,11 +347,126 @@ func (r BlobRepository) List(user *User, folder int) (*BlobList, error) {
 	return blobList, nil
 }
 
+func (r BlobRepository) Search(user *User, q string, options *ListOptions) ([]*Blob, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	if options == nil {
+		options = &ListOptions{}
+	}
+
+	orderBy, err := options.orderBy(blobSortExprs, "createdAt", "desc")
+	if err != nil {
+		return nil, err
+	}
+
+	lq := newListQuery(user.Id)
+
+	if !likeTerms(lq, q, `"name"`, `"snippet"`) {
+		return nil, ErrMissingSearchQuery
+	}
+
+	err = options.contentType(lq)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT *
+			FROM "Blob"
+			WHERE "userId" = $1 AND
+			"lastStmt" < 2` + lq.whereClause() + orderBy + `;`
+
+	rows, err := reader(r.db, r.replica, user).QueryContext(ctx, query, lq.args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	blobs := []*Blob{}
+
+	for rows.Next() {
+		var blob Blob
+
+		err := rows.Scan(blob.Scan()...)
+		if err != nil {
+			return nil, err
+		}
+
+		blobs = append(blobs, &blob)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return blobs, nil
+}
+
+func (r *BlobRepository) Count(user *User) (*Count, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	query := `
+		SELECT COUNT(*) FILTER (WHERE "lastStmt" < 2),
+			COUNT(*) FILTER (WHERE "lastStmt" = 2)
+			FROM "Blob"
+			WHERE "userId" = $1;`
+
+	args := []interface{}{user.Id}
+
+	count := &Count{}
+
+	err := reader(r.db, r.replica, user).QueryRowContext(ctx, query, args...).Scan(&count.Count, &count.Trashed)
+	if err != nil {
+		return nil, err
+	}
+
+	return count, nil
+}
+
+// UsageBytes returns the number and the size of the blobs, the live and the trashed ones apart.
+func (r *BlobRepository) UsageBytes(user *User) (*Usage, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	query := `
+		SELECT COUNT(*) FILTER (WHERE "lastStmt" < 2),
+			COUNT(*) FILTER (WHERE "lastStmt" = 2),
+			coalesce(sum("size") FILTER (WHERE "lastStmt" < 2), 0),
+			coalesce(sum("size") FILTER (WHERE "lastStmt" = 2), 0)
+			FROM "Blob"
+			WHERE "userId" = $1;`
+
+	args := []interface{}{user.Id}
+
+	usage := &Usage{}
+
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(&usage.Count, &usage.Trashed, &usage.Bytes, &usage.TrashedBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return usage, nil
+}
+
 func (r *BlobRepository) Sync(user *User, history *History) (*BlobSync, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := r.timeouts.read()
 	defer cancel()
 
-	tx, err := r.db.BeginTx(ctx, nil)
+	var sync *BlobSync
+
+	err := retryBusy(ctx, func() (err error) {
+		sync, err = r.trySync(ctx, user, history)
+		return err
+	})
+
+	return sync, err
+}
+
+func (r *BlobRepository) trySync(ctx context.Context, user *User, history *History) (*BlobSync, error) {
+	tx, err := reader(r.db, r.replica, user).BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -221,10 +485,11 @@ func (r *BlobRepository) Sync(user *User, history *History) (*BlobSync, error) {
 			WHERE "userId" = $1 AND
 				("deviceId" <> $2 OR "deviceId" IS NULL) AND
 				"lastStmt" = 0 AND
-				"historyId" > $3
+				"historyId" > $3 AND
+				coalesce("modifiedAt", "createdAt") >= $4
 			ORDER BY "createdAt" DESC;`
 
-	args := []interface{}{user.Id, deviceId, history.Id}
+	args := []interface{}{user.Id, deviceId, history.Id, history.since()}
 
 	rows, err := tx.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -263,10 +528,11 @@ func (r *BlobRepository) Sync(user *User, history *History) (*BlobSync, error) {
 			WHERE "userId" = $1 AND
 				"lastStmt" = 1 AND
 				("deviceId" <> $2 OR "deviceId" IS NULL) AND
-				"historyId" > $3
+				"historyId" > $3 AND
+				coalesce("modifiedAt", "createdAt") >= $4
 			ORDER BY "createdAt" DESC;`
 
-	args = []interface{}{user.Id, deviceId, history.Id}
+	args = []interface{}{user.Id, deviceId, history.Id, history.since()}
 
 	rows, err = tx.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -298,10 +564,11 @@ func (r *BlobRepository) Sync(user *User, history *History) (*BlobSync, error) {
 			WHERE "userId" = $1 AND
 			("deviceId" <> $2 OR "deviceId" IS NULL) AND
 			"lastStmt" = 2 AND
-				"historyId" > $3
+				"historyId" > $3 AND
+				coalesce("modifiedAt", "createdAt") >= $4
 			ORDER BY "createdAt" DESC;`
 
-	args = []interface{}{user.Id, deviceId, history.Id}
+	args = []interface{}{user.Id, deviceId, history.Id, history.since()}
 
 	rows, err = tx.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -372,6 +639,11 @@ func (r *BlobRepository) Sync(user *User, history *History) (*BlobSync, error) {
 		return nil, err
 	}
 
+	blobSync.NextPollAfter, err = nextPollAfter(ctx, tx, "Blob", user.Id)
+	if err != nil {
+		return nil, err
+	}
+
 	if err = tx.Commit(); err != nil {
 		return nil, err
 	}
@@ -380,7 +652,7 @@ func (r *BlobRepository) Sync(user *User, history *History) (*BlobSync, error) {
 }
 
 func (r BlobRepository) Update(user *User, blob *Blob) (*Blob, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := r.timeouts.write()
 	defer cancel()
 
 	tx, err := r.db.BeginTx(ctx, nil)
@@ -389,20 +661,27 @@ func (r BlobRepository) Update(user *User, blob *Blob) (*Blob, error) {
 	}
 	defer tx.Rollback()
 
+	// the content replacement of an unnamed blob keeps the name
+	blob.Name = strings.TrimSpace(blob.Name)
+	if len(blob.Name) > 255 {
+		return nil, ErrBlobWrongName
+	}
+
 	query := `
 		UPDATE "Blob"
 			SET "digest" = $1,
-				"snippet" = $2,
-				"size" = $3,
-				"deviceId" = $4
-			WHERE "userId" = $5 AND
-			      "id" = $6 AND
+				"name" = coalesce(nullif($2, ''), "name"),
+				"snippet" = $3,
+				"size" = $4,
+				"deviceId" = $5
+			WHERE "userId" = $6 AND
+			      "id" = $7 AND
 				  "lastStmt" <> 2
 			RETURNING id ;`
 
 	prefixedDeviceId := getPrefixedDeviceId(user.DeviceId)
 
-	args := []interface{}{blob.Digest, blob.Snippet, blob.Size, prefixedDeviceId, user.Id, blob.Id}
+	args := []interface{}{blob.Digest, blob.Name, blob.Snippet, blob.Size, prefixedDeviceId, user.Id, blob.Id}
 
 	err = tx.QueryRowContext(ctx, query, args...).Scan(&blob.Id)
 	if err != nil {
@@ -436,16 +715,22 @@ func (r BlobRepository) Update(user *User, blob *Blob) (*Blob, error) {
 }
 
 func (r *BlobRepository) Trash(user *User, ids string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := r.timeouts.write()
 	defer cancel()
 
+	return retryBusy(ctx, func() error {
+		return r.tryTrash(ctx, user, ids)
+	})
+}
+
+func (r *BlobRepository) tryTrash(ctx context.Context, user *User, ids string) error {
 	if len(ids) > 0 {
 		query := `
 		UPDATE "Blob"
 			SET "lastStmt" = 2,
 				"deviceId" = $1
 			WHERE "userId" = $2 AND
-			"id" IN (SELECT value FROM json_each($3, '$.ids'));`
+			"id" IN (` + jsonIds("$3") + `);`
 
 		prefixedDeviceId := getPrefixedDeviceId(user.DeviceId)
 
@@ -461,16 +746,22 @@ func (r *BlobRepository) Trash(user *User, ids string) error {
 }
 
 func (r *BlobRepository) Untrash(user *User, ids string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := r.timeouts.write()
 	defer cancel()
 
+	return retryBusy(ctx, func() error {
+		return r.tryUntrash(ctx, user, ids)
+	})
+}
+
+func (r *BlobRepository) tryUntrash(ctx context.Context, user *User, ids string) error {
 	if len(ids) > 0 {
 		query := `
 		UPDATE "Blob"
 			SET "lastStmt" = 0,
 				"deviceId" = $1
 			WHERE "userId" = $2 AND
-			"id" IN (SELECT value FROM json_each($3, '$.ids'));`
+			"id" IN (` + jsonIds("$3") + `);`
 
 		prefixedDeviceId := getPrefixedDeviceId(user.DeviceId)
 
@@ -486,9 +777,20 @@ func (r *BlobRepository) Untrash(user *User, ids string) error {
 }
 
 func (r BlobRepository) Delete(user *User, ids string) ([]*Blob, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := r.timeouts.write()
 	defer cancel()
 
+	var deleted []*Blob
+
+	err := retryBusy(ctx, func() (err error) {
+		deleted, err = r.tryDelete(ctx, user, ids)
+		return err
+	})
+
+	return deleted, err
+}
+
+func (r BlobRepository) tryDelete(ctx context.Context, user *User, ids string) ([]*Blob, error) {
 	blobs := []*Blob{}
 
 	if len(ids) > 0 {
@@ -502,7 +804,7 @@ func (r BlobRepository) Delete(user *User, ids string) ([]*Blob, error) {
 		DELETE
 			FROM "Blob"
 			WHERE "userId" = $1 AND
-			"id" IN (SELECT value FROM json_each($2, '$.ids'))
+			"id" IN (` + jsonIds("$2") + `)
 			RETURNING * ;`
 
 		blob := Blob{}
@@ -523,7 +825,7 @@ func (r BlobRepository) Delete(user *User, ids string) ([]*Blob, error) {
 		UPDATE "BlobDeleted"
 			SET "deviceId" = $1
 			WHERE "userId" = $2 AND
-			"id" IN (SELECT value FROM json_each($3, '$.ids'));`
+			"id" IN (` + jsonIds("$3") + `);`
 
 		args = []interface{}{user.DeviceId, user.Id, ids}
 
@@ -541,7 +843,7 @@ func (r BlobRepository) Delete(user *User, ids string) ([]*Blob, error) {
 }
 
 func (r BlobRepository) CleanAndCreate(user *User, blobs []*Blob, draftId string) ([]*Blob, []*Blob, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := r.timeouts.write()
 	defer cancel()
 
 	tx, err := r.db.BeginTx(ctx, nil)
@@ -596,6 +898,16 @@ func (r BlobRepository) CleanAndCreate(user *User, blobs []*Blob, draftId string
 			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 			RETURNING * ;`
 
+	var size int64
+	for i := range blobs {
+		size += blobs[i].Size
+	}
+
+	err = checkQuota(ctx, tx, user.Id, size)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	prefixedDeviceId := getPrefixedDeviceId(user.DeviceId)
 
 	folder := 0
@@ -609,6 +921,13 @@ func (r BlobRepository) CleanAndCreate(user *User, blobs []*Blob, draftId string
 			blobDraftId = nil
 		}
 
+		if blobs[i].Metadata != nil {
+			blobs[i].Digest, err = acquireContent(ctx, tx, BlobsResource, blobs[i].Metadata.Hash, blobs[i].Digest, blobs[i].Metadata)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+
 		args := []interface{}{user.Id, blobDraftId, prefixedDeviceId, folder, blobs[i].Digest, blobs[i].Name, blobs[i].Snippet, blobs[i].Path, blobs[i].ContentType, blobs[i].Size, blobs[i].Metadata}
 
 		err = tx.QueryRowContext(ctx, query, args...).Scan(blob.Scan()...)
@@ -628,7 +947,7 @@ func (r BlobRepository) CleanAndCreate(user *User, blobs []*Blob, draftId string
 }
 
 func (r BlobRepository) GetById(user *User, id string) (*Blob, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := r.timeouts.write()
 	defer cancel()
 
 	query := `
@@ -654,7 +973,7 @@ func (r BlobRepository) GetById(user *User, id string) (*Blob, error) {
 }
 
 func (r BlobRepository) GetByDigest(user *User, digest string) (*Blob, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := r.timeouts.write()
 	defer cancel()
 
 	query := `
@@ -679,3 +998,61 @@ func (r BlobRepository) GetByDigest(user *User, digest string) (*Blob, error) {
 
 	return blob, nil
 }
+
+func (r BlobRepository) EmptyTrash(user *User) error {
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+	SELECT json_object('ids', json_group_array("id"))
+		FROM "Blob"
+		WHERE "userId" = $1 AND
+		"lastStmt" = 2;`
+
+	args := []interface{}{user.Id}
+
+	var ids string
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&ids)
+	if err != nil {
+		return err
+	}
+
+	query = `
+	DELETE
+		FROM "Blob"
+		WHERE "userId" = $1 AND
+		"id" IN (` + jsonIds("$2") + `);`
+
+	args = []interface{}{user.Id, ids}
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	query = `
+	UPDATE "BlobDeleted"
+		SET "deviceId" = $1
+		WHERE "userId" = $2 AND
+		"id" IN (` + jsonIds("$3") + `);`
+
+	args = []interface{}{user.DeviceId, user.Id, ids}
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestUpdateBlobName(t *testing.T) {
+	repository, _ := newTestRepository(t)
+
+	alice := newTestUser(t, repository, "alice")
+
+	blob, err := repository.Blobs.Create(alice, &Blob{Digest: "digest", Name: "notes.txt", ContentType: "text/plain", Size: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the content replacement without a name keeps it
+	blob, err = repository.Blobs.Update(alice, &Blob{Id: blob.Id, Digest: "replaced", Size: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if blob.Name != "notes.txt" || blob.Digest != "replaced" || blob.Size != 8 {
+		t.Errorf("got the blob %+v, want the replaced content of notes.txt", blob)
+	}
+
+	blob.Name = "minutes.txt"
+
+	blob, err = repository.Blobs.Update(alice, blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if blob.Name != "minutes.txt" || blob.Digest != "replaced" {
+		t.Errorf("got the blob %+v, want the renamed minutes.txt", blob)
+	}
+
+	// an unnamed blob keeps being updated
+	unnamed, err := repository.Blobs.Create(alice, &Blob{Digest: "unnamed", ContentType: "text/plain", Size: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unnamed.Size = 7
+
+	_, err = repository.Blobs.Update(alice, unnamed)
+	if err != nil {
+		t.Errorf("got %v for the unnamed blob", err)
+	}
+
+	blob.Name = strings.Repeat("a", 256)
+
+	_, err = repository.Blobs.Update(alice, blob)
+	if !errors.Is(err, ErrBlobWrongName) {
+		t.Errorf("got %v for the long name, want %v", err, ErrBlobWrongName)
+	}
+}
@@ -0,0 +1,661 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// Collection is a folder of the blobs, the collections nest by the parent, the root ones have none. A blob
+// is in one collection at most. The collections have their own history, the moves of the blobs are synced
+// as the updates of the collections.
+type Collection struct {
+	Id         string     `json:"id"`
+	UserId     int64      `json:"-"`
+	ParentId   *string    `json:"parentId"`
+	Name       string     `json:"name"`
+	CreatedAt  Timestamp  `json:"createdAt"`
+	ModifiedAt *Timestamp `json:"modifiedAt"`
+	TimelineId int64      `json:"-"`
+	HistoryId  int64      `json:"-"`
+	LastStmt   int        `json:"-"`
+	DeviceId   *string    `json:"-"`
+
+	BlobIds BlobIdList `json:"blobIds"` // selected from the "CollectionBlob"
+}
+
+type BlobIdList []string
+
+func (l *BlobIdList) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*l = BlobIdList{}
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), l)
+	case []byte:
+		return json.Unmarshal(v, l)
+	default:
+		return errors.New("type assertion failed")
+	}
+}
+
+type CollectionDeleted struct {
+	Id        string  `json:"id"`
+	UserId    int64   `json:"-"`
+	HistoryId int64   `json:"-"`
+	DeviceId  *string `json:"-"`
+}
+
+type CollectionList struct {
+	History     int64         `json:"lastHistoryId"`
+	Collections []*Collection `json:"collections"`
+}
+
+type CollectionSync struct {
+	History             int64                `json:"lastHistoryId"`
+	NextPollAfter       int                  `json:"nextPollAfter"`
+	CollectionsInserted []*Collection        `json:"inserted"`
+	CollectionsUpdated  []*Collection        `json:"updated"`
+	CollectionsDeleted  []*CollectionDeleted `json:"deleted"`
+}
+
+// the columns of the collection followed by its blobs, the trashed ones included, they are back in the
+// collection once untrashed
+const selectCollection = `
+		SELECT *,
+			(SELECT json_group_array("blobId")
+				FROM (SELECT "blobId"
+					FROM "CollectionBlob"
+					WHERE "collectionId" = "Collection"."id"
+					ORDER BY "createdAt", "rowid"))
+			FROM "Collection"`
+
+func (c *Collection) Scan() []interface{} {
+	return scanColumns(c)
+}
+
+func (c *CollectionDeleted) Scan() []interface{} {
+	return scanColumns(c)
+}
+
+func collectionName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+
+	if len(name) == 0 {
+		return "", ErrMissingNameField
+	}
+
+	if len(name) > 255 || strings.ContainsAny(name, "/\\") {
+		return "", ErrCollectionWrongName
+	}
+
+	return name, nil
+}
+
+// the names are unique among the siblings, the index is on an expression, so its columns are not named
+func isDuplicateCollection(err error) bool {
+	return strings.HasPrefix(err.Error(), `UNIQUE constraint failed: index 'IdxCollectionName'`)
+}
+
+func getCollection(ctx context.Context, tx *sql.Tx, user *User, id string) (*Collection, error) {
+	query := selectCollection + `
+			WHERE "userId" = $1 AND
+			"id" = $2;`
+
+	collection := &Collection{}
+
+	err := tx.QueryRowContext(ctx, query, user.Id, id).Scan(collection.Scan()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrCollectionNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return collection, nil
+}
+
+// checkParent checks the parent exists and, for the collection being moved, that the parent is neither
+// the collection nor one of its descendants.
+func checkParent(ctx context.Context, tx *sql.Tx, user *User, id string, parentId *string) error {
+	if parentId == nil {
+		return nil
+	}
+
+	_, err := getCollection(ctx, tx, user, *parentId)
+	if err != nil {
+		return err
+	}
+
+	if len(id) == 0 {
+		return nil
+	}
+
+	// the ancestors of the parent, the parent included
+	query := `
+		WITH RECURSIVE "Ancestor" ("id") AS (
+			SELECT $1
+			UNION
+			SELECT "Collection"."parentId"
+				FROM "Collection"
+				INNER JOIN "Ancestor" ON "Ancestor"."id" = "Collection"."id"
+				WHERE "Collection"."userId" = $2 AND
+				"Collection"."parentId" IS NOT NULL)
+		SELECT count(*)
+			FROM "Ancestor"
+			WHERE "id" = $3;`
+
+	var cycle int
+
+	err = tx.QueryRowContext(ctx, query, *parentId, user.Id, id).Scan(&cycle)
+	if err != nil {
+		return err
+	}
+
+	if cycle > 0 {
+		return ErrCollectionCycle
+	}
+
+	return nil
+}
+
+func (r *BlobRepository) CreateCollection(user *User, collection *Collection) (*Collection, error) {
+	name, err := collectionName(collection.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	err = checkParent(ctx, tx, user, "", collection.ParentId)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT
+			INTO "Collection" ("userId", "deviceId", "parentId", "name")
+			VALUES ($1, $2, $3, $4)
+			RETURNING "id";`
+
+	var id string
+
+	err = tx.QueryRowContext(ctx, query, user.Id, user.DeviceId, collection.ParentId, name).Scan(&id)
+	if err != nil {
+		switch {
+		case isDuplicateCollection(err):
+			return nil, ErrDuplicateCollection
+		default:
+			return nil, err
+		}
+	}
+
+	// the history is set by the insert trigger
+	collection, err = getCollection(ctx, tx, user, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return collection, nil
+}
+
+// ListCollections lists all the collections of the user, the tree is built by the client from the parents.
+func (r *BlobRepository) ListCollections(user *User) (*CollectionList, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := selectCollection + `
+			WHERE "userId" = $1
+			ORDER BY "name" COLLATE NOCASE;`
+
+	args := []interface{}{user.Id}
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	collectionList := &CollectionList{
+		Collections: []*Collection{},
+	}
+
+	for rows.Next() {
+		var collection Collection
+
+		err := rows.Scan(collection.Scan()...)
+		if err != nil {
+			return nil, err
+		}
+
+		collectionList.Collections = append(collectionList.Collections, &collection)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// history
+	query = `
+		SELECT "lastHistoryId"
+			FROM "CollectionHistorySeq"
+			WHERE "userId" = $1 ;`
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&collectionList.History)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return collectionList, nil
+}
+
+// UpdateCollection renames the collection and sets its parent, the nil parent moves it to the root.
+func (r *BlobRepository) UpdateCollection(user *User, collection *Collection) (*Collection, error) {
+	name, err := collectionName(collection.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = getCollection(ctx, tx, user, collection.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkParent(ctx, tx, user, collection.Id, collection.ParentId)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		UPDATE "Collection"
+			SET "name" = $1,
+				"parentId" = $2,
+				"deviceId" = $3
+			WHERE "userId" = $4 AND
+			"id" = $5;`
+
+	args := []interface{}{name, collection.ParentId, user.DeviceId, user.Id, collection.Id}
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		switch {
+		case isDuplicateCollection(err):
+			return nil, ErrDuplicateCollection
+		default:
+			return nil, err
+		}
+	}
+
+	// the history is set by the update trigger
+	collection, err = getCollection(ctx, tx, user, collection.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return collection, nil
+}
+
+// DeleteCollection deletes the empty collection, the one with the collections or the blobs in it is
+// refused. The trashed blobs don't count, they are moved to the root.
+func (r *BlobRepository) DeleteCollection(user *User, id string) error {
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = getCollection(ctx, tx, user, id)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		SELECT EXISTS (SELECT 1
+				FROM "Collection"
+				WHERE "userId" = $1 AND
+				"parentId" = $2) OR
+			EXISTS (SELECT 1
+				FROM "CollectionBlob"
+				INNER JOIN "Blob" ON "Blob"."id" = "CollectionBlob"."blobId"
+				WHERE "CollectionBlob"."userId" = $1 AND
+				"CollectionBlob"."collectionId" = $2 AND
+				"Blob"."lastStmt" < 2);`
+
+	var notEmpty bool
+
+	err = tx.QueryRowContext(ctx, query, user.Id, id).Scan(&notEmpty)
+	if err != nil {
+		return err
+	}
+
+	if notEmpty {
+		return ErrCollectionNotEmpty
+	}
+
+	query = `
+		DELETE
+			FROM "Collection"
+			WHERE "userId" = $1 AND
+			"id" = $2;`
+
+	_, err = tx.ExecContext(ctx, query, user.Id, id)
+	if err != nil {
+		return err
+	}
+
+	query = `
+		UPDATE "CollectionDeleted"
+			SET "deviceId" = $1
+			WHERE "userId" = $2 AND
+			"id" = $3;`
+
+	_, err = tx.ExecContext(ctx, query, user.DeviceId, user.Id, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// MoveToCollection moves the blobs to the collection, the empty collection id moves them to the root. The
+// change of the collections is attributed to the device of the user, so it is not synced back to it.
+func (r *BlobRepository) MoveToCollection(user *User, collectionId string, blobIds []string) error {
+	if len(blobIds) == 0 {
+		return ErrMissingIdsField
+	}
+
+	idsJson, err := json.Marshal(blobIds)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if len(collectionId) > 0 {
+		_, err = getCollection(ctx, tx, user, collectionId)
+		if err != nil {
+			return err
+		}
+	}
+
+	query := `
+		SELECT count(*)
+			FROM "Blob"
+			WHERE "userId" = $1 AND
+			"id" IN (SELECT value FROM json_each($2)) AND
+			"lastStmt" < 2;`
+
+	var found int
+
+	err = tx.QueryRowContext(ctx, query, user.Id, string(idsJson)).Scan(&found)
+	if err != nil {
+		return err
+	}
+
+	distinct := map[string]bool{}
+	for _, id := range blobIds {
+		distinct[id] = true
+	}
+
+	if found != len(distinct) {
+		return ErrBlobNotFound
+	}
+
+	// the collections the blobs leave
+	query = `
+		SELECT json_group_array(DISTINCT "collectionId")
+			FROM "CollectionBlob"
+			WHERE "userId" = $1 AND
+			"blobId" IN (SELECT value FROM json_each($2)) AND
+			"collectionId" <> $3;`
+
+	var touched string
+
+	err = tx.QueryRowContext(ctx, query, user.Id, string(idsJson), collectionId).Scan(&touched)
+	if err != nil {
+		return err
+	}
+
+	var args []interface{}
+
+	if len(collectionId) == 0 {
+		query = `
+			DELETE
+				FROM "CollectionBlob"
+				WHERE "userId" = $1 AND
+				"blobId" IN (SELECT value FROM json_each($2));`
+
+		args = []interface{}{user.Id, string(idsJson)}
+	} else {
+		query = `
+			INSERT
+				INTO "CollectionBlob" ("blobId", "collectionId", "userId")
+				SELECT "id", $1, "userId"
+					FROM "Blob"
+					WHERE "userId" = $2 AND
+					"id" IN (SELECT value FROM json_each($3))
+				ON CONFLICT ("blobId") DO UPDATE
+					SET "collectionId" = excluded."collectionId",
+						"createdAt" = CURRENT_TIMESTAMP
+					WHERE "collectionId" <> excluded."collectionId";`
+
+		args = []interface{}{collectionId, user.Id, string(idsJson)}
+	}
+
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected > 0 {
+		query = `
+			UPDATE "Collection"
+				SET "deviceId" = $1
+				WHERE "userId" = $2 AND
+				("id" IN (SELECT value FROM json_each($3)) OR "id" = $4);`
+
+		_, err = tx.ExecContext(ctx, query, user.DeviceId, user.Id, touched, collectionId)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ListByCollection lists the blobs of the collection like the List does, of all the folders.
+func (r *BlobRepository) ListByCollection(user *User, id string, options *ListOptions) (*BlobList, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	query := `
+		SELECT 1
+			FROM "Collection"
+			WHERE "userId" = $1 AND
+			"id" = $2;`
+
+	var exists int
+
+	err := r.db.QueryRowContext(ctx, query, user.Id, id).Scan(&exists)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrCollectionNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	q := newListQuery(user.Id, -1)
+	q.and(`"id" IN (SELECT "blobId" FROM "CollectionBlob" WHERE "collectionId" = ` + q.arg(id) + `)`)
+
+	return r.list(user, options, q)
+}
+
+func (r *BlobRepository) SyncCollections(user *User, history *History) (*CollectionSync, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var deviceId string
+
+	if !history.IgnoreDevice {
+		deviceId = *user.DeviceId
+	}
+
+	collectionSync := &CollectionSync{
+		CollectionsInserted: []*Collection{},
+		CollectionsUpdated:  []*Collection{},
+		CollectionsDeleted:  []*CollectionDeleted{},
+	}
+
+	// inserted and updated rows
+	query := selectCollection + `
+			WHERE "userId" = $1 AND
+				("deviceId" <> $2 OR "deviceId" IS NULL) AND
+				"historyId" > $3 AND
+				coalesce("modifiedAt", "createdAt") >= $4
+			ORDER BY "createdAt" DESC;`
+
+	args := []interface{}{user.Id, deviceId, history.Id, history.since()}
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var collection Collection
+
+		err := rows.Scan(collection.Scan()...)
+		if err != nil {
+			return nil, err
+		}
+
+		if collection.LastStmt == 0 {
+			collectionSync.CollectionsInserted = append(collectionSync.CollectionsInserted, &collection)
+		} else {
+			collectionSync.CollectionsUpdated = append(collectionSync.CollectionsUpdated, &collection)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// deleted rows
+	query = `
+		SELECT *
+			FROM "CollectionDeleted"
+			WHERE "userId" = $1 AND
+			("deviceId" <> $2 OR "deviceId" IS NULL) AND
+			"historyId" > $3;`
+
+	args = []interface{}{user.Id, deviceId, history.Id}
+
+	rows, err = tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var collectionDeleted CollectionDeleted
+
+		err := rows.Scan(collectionDeleted.Scan()...)
+		if err != nil {
+			return nil, err
+		}
+
+		collectionSync.CollectionsDeleted = append(collectionSync.CollectionsDeleted, &collectionDeleted)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// history
+	query = `
+		SELECT "lastHistoryId"
+			FROM "CollectionHistorySeq"
+			WHERE "userId" = $1 ;`
+
+	err = tx.QueryRowContext(ctx, query, user.Id).Scan(&collectionSync.History)
+	if err != nil {
+		return nil, err
+	}
+
+	collectionSync.NextPollAfter, err = nextPollAfter(ctx, tx, "Collection", user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return collectionSync, nil
+}
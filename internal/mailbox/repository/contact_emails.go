@@ -0,0 +1,369 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"net/mail"
+	"strings"
+	"unicode"
+)
+
+// ContactEmail is one of the email addresses of a contact. The primary one is mirrored into the
+// "emailAddress" column, which is unique per user and indexed.
+type ContactEmail struct {
+	Address string `json:"address"`
+	Type    string `json:"type,omitempty"` // e.g. work, home
+	Primary bool   `json:"primary"`
+}
+
+type ContactEmails []*ContactEmail
+
+func (e ContactEmails) Value() (driver.Value, error) {
+	if e == nil {
+		return nil, nil
+	}
+
+	return json.Marshal(e)
+}
+
+func (e *ContactEmails) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*e = nil
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), e)
+	case []byte:
+		return json.Unmarshal(v, e)
+	default:
+		return errors.New("type assertion failed")
+	}
+}
+
+// normalizeEmailAddress trims and lowercases the address, so the addresses differing by the case only
+// collide on the unique index. The domain is case-insensitive, and so is the local part in practice.
+func normalizeEmailAddress(address string) string {
+	return strings.ToLower(strings.TrimSpace(address))
+}
+
+// validEmailAddress accepts the plain addr-spec of the RFC 5322, i.e. a dot-atom local part, e.g. with
+// the plus-addressing, at a domain name of two labels at least. The quoted local parts, the comments and
+// the domain literals are rejected. The labels may be of any letters, so the IDN domains are accepted
+// without the punycode. It is shared by every write of the contacts, including the imports.
+func validEmailAddress(address string) bool {
+	if len(address) > 254 {
+		return false
+	}
+
+	parsed, err := mail.ParseAddress(address)
+	if err != nil || parsed.Address != address || len(parsed.Name) > 0 {
+		return false
+	}
+
+	at := strings.LastIndex(address, "@")
+	local, domain := address[:at], address[at+1:]
+
+	if len(local) > 64 || strings.HasPrefix(local, `"`) {
+		return false
+	}
+
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 {
+		return false
+	}
+
+	for _, label := range labels {
+		if !validDomainLabel(label) {
+			return false
+		}
+	}
+
+	// the top-level domain is never numeric, e.g. a@1.2.3.4
+	return strings.IndexFunc(labels[len(labels)-1], unicode.IsLetter) >= 0
+}
+
+func validDomainLabel(label string) bool {
+	if len(label) == 0 || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+		return false
+	}
+
+	for _, r := range label {
+		if r != '-' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// syncEmailAddresses normalizes and validates the email addresses, drops the duplicates and makes sure
+// exactly one of them is the primary, which is then copied to the "emailAddress". A contact without the
+// list gets a list of its "emailAddress".
+func (c *Contact) syncEmailAddresses() error {
+	if len(c.EmailAddresses) == 0 {
+		// a contact may be of a name only, but not of nothing
+		if empty(c.EmailAddress) {
+			if empty(c.FirstName) && empty(c.LastName) {
+				return ErrInvalidEmailAddress
+			}
+
+			c.EmailAddress = nil
+			c.EmailAddresses = nil
+			return nil
+		}
+
+		c.EmailAddresses = ContactEmails{{Address: *c.EmailAddress, Primary: true}}
+	}
+
+	emails := ContactEmails{}
+	index := map[string]int{}
+	primary := -1
+
+	for _, email := range c.EmailAddresses {
+		if email == nil {
+			continue
+		}
+
+		email.Address = normalizeEmailAddress(email.Address)
+		if !validEmailAddress(email.Address) {
+			return ErrInvalidEmailAddress
+		}
+
+		i, seen := index[email.Address]
+		if !seen {
+			i = len(emails)
+			index[email.Address] = i
+			emails = append(emails, &ContactEmail{Address: email.Address, Type: email.Type})
+		}
+
+		if email.Primary {
+			if primary >= 0 && primary != i {
+				return ErrMultiplePrimaryEmails
+			}
+			primary = i
+		}
+	}
+
+	if len(emails) == 0 {
+		return ErrInvalidEmailAddress
+	}
+
+	if primary < 0 {
+		primary = 0
+	}
+
+	emails[primary].Primary = true
+
+	c.EmailAddresses = emails
+	c.EmailAddress = &emails[primary].Address
+
+	return nil
+}
+
+// checkDuplicateContact returns the DuplicateContactError when another live contact of the user has the
+// primary email address of the contact. The addresses stored before the normalization are compared by
+// their lowercase.
+func checkDuplicateContact(ctx context.Context, tx *sql.Tx, user *User, contact *Contact) error {
+	if contact.EmailAddress == nil {
+		return nil
+	}
+
+	query := `
+		SELECT "id"
+			FROM "Contact"
+			WHERE "userId" = $1 AND
+			lower("emailAddress") = $2 AND
+			"id" <> $3 AND
+			"lastStmt" < 2
+			LIMIT 1;`
+
+	var id string
+
+	err := tx.QueryRowContext(ctx, query, user.Id, *contact.EmailAddress, contact.Id).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil
+		default:
+			return err
+		}
+	}
+
+	return &DuplicateContactError{ContactId: id}
+}
+
+func (r *ContactRepository) AddEmailAddress(user *User, id string, email *ContactEmail) (*Contact, error) {
+	return r.modifyEmailAddresses(user, id, func(contact *Contact) error {
+		// an existing address is updated
+		for _, e := range contact.EmailAddresses {
+			if strings.EqualFold(e.Address, strings.TrimSpace(email.Address)) {
+				if len(email.Type) > 0 {
+					e.Type = email.Type
+				}
+				if email.Primary {
+					return setPrimaryEmail(contact, e.Address)
+				}
+				return nil
+			}
+		}
+
+		if email.Primary {
+			for _, e := range contact.EmailAddresses {
+				e.Primary = false
+			}
+		}
+
+		contact.EmailAddresses = append(contact.EmailAddresses, email)
+
+		return nil
+	})
+}
+
+// RemoveEmailAddress removes the email address, the next one becomes the primary when the primary is
+// removed. The last email address can't be removed.
+func (r *ContactRepository) RemoveEmailAddress(user *User, id string, address string) (*Contact, error) {
+	return r.modifyEmailAddresses(user, id, func(contact *Contact) error {
+		emails := ContactEmails{}
+
+		for _, e := range contact.EmailAddresses {
+			if !strings.EqualFold(e.Address, strings.TrimSpace(address)) {
+				emails = append(emails, e)
+			}
+		}
+
+		if len(emails) == len(contact.EmailAddresses) {
+			return ErrEmailAddressNotFound
+		}
+
+		if len(emails) == 0 {
+			return ErrLastEmailAddress
+		}
+
+		contact.EmailAddresses = emails
+
+		return nil
+	})
+}
+
+func (r *ContactRepository) SetPrimaryEmailAddress(user *User, id string, address string) (*Contact, error) {
+	return r.modifyEmailAddresses(user, id, func(contact *Contact) error {
+		return setPrimaryEmail(contact, address)
+	})
+}
+
+func setPrimaryEmail(contact *Contact, address string) error {
+	found := false
+
+	for _, e := range contact.EmailAddresses {
+		e.Primary = strings.EqualFold(e.Address, strings.TrimSpace(address))
+		found = found || e.Primary
+	}
+
+	if !found {
+		return ErrEmailAddressNotFound
+	}
+
+	return nil
+}
+
+// modifyEmailAddresses changes the list of the email addresses of the contact and keeps the primary one
+// in sync, in one transaction.
+func (r *ContactRepository) modifyEmailAddresses(user *User, id string, modify func(contact *Contact) error) (*Contact, error) {
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT *
+			FROM "Contact"
+			WHERE "userId" = $1 AND
+			"id" = $2 AND
+			"lastStmt" <> 2;`
+
+	args := []interface{}{user.Id, id}
+
+	contact := &Contact{}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(contact.Scan()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrContactNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	// the contacts created before the list was introduced
+	err = contact.syncEmailAddresses()
+	if err != nil {
+		return nil, err
+	}
+
+	err = modify(contact)
+	if err != nil {
+		return nil, err
+	}
+
+	err = contact.syncEmailAddresses()
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkDuplicateContact(ctx, tx, user, contact)
+	if err != nil {
+		return nil, err
+	}
+
+	query = `
+		UPDATE "Contact"
+			SET "emailAddress" = $1,
+				"emailAddresses" = $2,
+				"deviceId" = $3
+			WHERE "userId" = $4 AND
+			"id" = $5;`
+
+	prefixedDeviceId := getPrefixedDeviceId(user.DeviceId)
+
+	args = []interface{}{contact.EmailAddress, contact.EmailAddresses, prefixedDeviceId, user.Id, id}
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		switch {
+		case strings.HasPrefix(err.Error(), `UNIQUE constraint failed: Contact.`):
+			return nil, ErrDuplicateContact
+		case strings.HasPrefix(err.Error(), `CHECK constraint failed: emailAddress`):
+			return nil, ErrInvalidEmailAddress
+		default:
+			return nil, err
+		}
+	}
+
+	// the history is set by the update trigger
+	query = `
+		SELECT *
+			FROM "Contact"
+			WHERE "userId" = $1 AND
+			"id" = $2;`
+
+	args = []interface{}{user.Id, id}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(contact.Scan()...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return contact, nil
+}
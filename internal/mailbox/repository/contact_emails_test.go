@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidEmailAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		valid   bool
+	}{
+		{"carol@example.com", true},
+		{"carol+news@mail.example.co.uk", true},
+		{"carol.smith@xn--bcher-kva.example", true},
+		{"carol@bücher.example", true},
+		{"carol@example", false},
+		{"carol@-example.com", false},
+		{"carol@example..com", false},
+		{"carol@1.2.3.4", false},
+		{"carol@[192.0.2.1]", false},
+		{`"carol smith"@example.com`, false},
+		{"Carol <carol@example.com>", false},
+		{"carol@example.com (Carol)", false},
+		{"carol", false},
+		{strings.Repeat("c", 65) + "@example.com", false},
+		{"carol@" + strings.Repeat("e", 64) + ".com", false},
+	}
+
+	for _, tt := range tests {
+		if valid := validEmailAddress(tt.address); valid != tt.valid {
+			t.Errorf("%s: got %t, want %t", tt.address, valid, tt.valid)
+		}
+	}
+}
+
+func TestNameOnlyContact(t *testing.T) {
+	repository, _ := newTestRepository(t)
+	user := newTestUser(t, repository, "alice")
+
+	firstName, blank := "Dave", " "
+
+	// the contacts of a name only don't collide on the email address
+	for i := 0; i < 2; i++ {
+		contact, err := repository.Contacts.Create(user, &Contact{FirstName: &firstName, EmailAddress: &blank})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if contact.EmailAddress != nil || contact.EmailAddresses != nil {
+			t.Errorf("got %v of %v, want no email address", contact.EmailAddress, contact.EmailAddresses)
+		}
+	}
+
+	// a contact of nothing, or of an invalid address
+	invalid := "dave@example"
+
+	for _, contact := range []*Contact{{}, {EmailAddress: &blank}, {FirstName: &firstName, EmailAddress: &invalid}} {
+		_, err := repository.Contacts.Create(user, contact)
+		if !errors.Is(err, ErrInvalidEmailAddress) {
+			t.Errorf("got %v, want ErrInvalidEmailAddress", err)
+		}
+	}
+}
@@ -0,0 +1,572 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// ContactGroup organizes the contacts, e.g. "Family", "Work". The groups have their own history, the
+// membership changes are synced as the updates of the group.
+type ContactGroup struct {
+	Id         string     `json:"id"`
+	UserId     int64      `json:"-"`
+	Name       string     `json:"name"`
+	CreatedAt  Timestamp  `json:"createdAt"`
+	ModifiedAt *Timestamp `json:"modifiedAt"`
+	TimelineId int64      `json:"-"`
+	HistoryId  int64      `json:"-"`
+	LastStmt   int        `json:"-"`
+	DeviceId   *string    `json:"-"`
+
+	ContactIds ContactIdList `json:"contactIds"` // selected from the "ContactGroupMember"
+}
+
+type ContactIdList []string
+
+func (l *ContactIdList) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*l = ContactIdList{}
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), l)
+	case []byte:
+		return json.Unmarshal(v, l)
+	default:
+		return errors.New("type assertion failed")
+	}
+}
+
+type ContactGroupDeleted struct {
+	Id        string  `json:"id"`
+	UserId    int64   `json:"-"`
+	HistoryId int64   `json:"-"`
+	DeviceId  *string `json:"-"`
+}
+
+type ContactGroupList struct {
+	History int64           `json:"lastHistoryId"`
+	Groups  []*ContactGroup `json:"groups"`
+}
+
+type ContactGroupSync struct {
+	History        int64                  `json:"lastHistoryId"`
+	NextPollAfter  int                    `json:"nextPollAfter"`
+	GroupsInserted []*ContactGroup        `json:"inserted"`
+	GroupsUpdated  []*ContactGroup        `json:"updated"`
+	GroupsDeleted  []*ContactGroupDeleted `json:"deleted"`
+}
+
+// the columns of the group followed by its members
+const selectContactGroup = `
+		SELECT *,
+			(SELECT json_group_array("contactId")
+				FROM (SELECT "contactId"
+					FROM "ContactGroupMember"
+					WHERE "groupId" = "ContactGroup"."id"
+					ORDER BY "createdAt", "rowid"))
+			FROM "ContactGroup"`
+
+func (g *ContactGroup) Scan() []interface{} {
+	return scanColumns(g)
+}
+
+func (g *ContactGroupDeleted) Scan() []interface{} {
+	return scanColumns(g)
+}
+
+func contactGroupName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+
+	if len(name) == 0 {
+		return "", ErrMissingNameField
+	}
+
+	if len(name) > 255 {
+		return "", ErrContactGroupWrongName
+	}
+
+	return name, nil
+}
+
+func getContactGroup(ctx context.Context, tx *sql.Tx, user *User, id string) (*ContactGroup, error) {
+	query := selectContactGroup + `
+			WHERE "userId" = $1 AND
+			"id" = $2;`
+
+	group := &ContactGroup{}
+
+	err := tx.QueryRowContext(ctx, query, user.Id, id).Scan(group.Scan()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrContactGroupNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return group, nil
+}
+
+func (r *ContactRepository) CreateGroup(user *User, group *ContactGroup) (*ContactGroup, error) {
+	name, err := contactGroupName(group.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT
+			INTO "ContactGroup" ("userId", "deviceId", "name")
+			VALUES ($1, $2, $3)
+			RETURNING "id";`
+
+	var id string
+
+	err = tx.QueryRowContext(ctx, query, user.Id, user.DeviceId, name).Scan(&id)
+	if err != nil {
+		switch {
+		case strings.HasPrefix(err.Error(), `UNIQUE constraint failed: ContactGroup.`):
+			return nil, ErrDuplicateContactGroup
+		default:
+			return nil, err
+		}
+	}
+
+	// the history is set by the insert trigger
+	group, err = getContactGroup(ctx, tx, user, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return group, nil
+}
+
+func (r *ContactRepository) ListGroups(user *User) (*ContactGroupList, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := selectContactGroup + `
+			WHERE "userId" = $1
+			ORDER BY "name" COLLATE NOCASE;`
+
+	args := []interface{}{user.Id}
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	groupList := &ContactGroupList{
+		Groups: []*ContactGroup{},
+	}
+
+	for rows.Next() {
+		var group ContactGroup
+
+		err := rows.Scan(group.Scan()...)
+		if err != nil {
+			return nil, err
+		}
+
+		groupList.Groups = append(groupList.Groups, &group)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// history
+	query = `
+		SELECT "lastHistoryId"
+			FROM "ContactGroupHistorySeq"
+			WHERE "userId" = $1 ;`
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&groupList.History)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return groupList, nil
+}
+
+// UpdateGroup renames the group
+func (r *ContactRepository) UpdateGroup(user *User, group *ContactGroup) (*ContactGroup, error) {
+	name, err := contactGroupName(group.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE "ContactGroup"
+			SET "name" = $1,
+				"deviceId" = $2
+			WHERE "userId" = $3 AND
+			"id" = $4;`
+
+	args := []interface{}{name, user.DeviceId, user.Id, group.Id}
+
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		switch {
+		case strings.HasPrefix(err.Error(), `UNIQUE constraint failed: ContactGroup.`):
+			return nil, ErrDuplicateContactGroup
+		default:
+			return nil, err
+		}
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if affected == 0 {
+		return nil, ErrContactGroupNotFound
+	}
+
+	// the history is set by the update trigger
+	group, err = getContactGroup(ctx, tx, user, group.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return group, nil
+}
+
+// DeleteGroup deletes the group, the contacts of the group are kept.
+func (r *ContactRepository) DeleteGroup(user *User, id string) error {
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		DELETE
+			FROM "ContactGroup"
+			WHERE "userId" = $1 AND
+			"id" = $2;`
+
+	result, err := tx.ExecContext(ctx, query, user.Id, id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrContactGroupNotFound
+	}
+
+	query = `
+		UPDATE "ContactGroupDeleted"
+			SET "deviceId" = $1
+			WHERE "userId" = $2 AND
+			"id" = $3;`
+
+	_, err = tx.ExecContext(ctx, query, user.DeviceId, user.Id, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// AssignToGroup adds the contacts to the group, the contacts already in the group are skipped.
+func (r *ContactRepository) AssignToGroup(user *User, groupId string, contactIds []string) (*ContactGroup, error) {
+	query := `
+		INSERT OR IGNORE
+			INTO "ContactGroupMember" ("groupId", "contactId", "userId")
+			SELECT $1, "id", "userId"
+				FROM "Contact"
+				WHERE "userId" = $2 AND
+				"id" IN (SELECT value FROM json_each($3)) AND
+				"lastStmt" < 2;`
+
+	return r.modifyGroupMembers(user, groupId, contactIds, query)
+}
+
+func (r *ContactRepository) RemoveFromGroup(user *User, groupId string, contactIds []string) (*ContactGroup, error) {
+	query := `
+		DELETE
+			FROM "ContactGroupMember"
+			WHERE "groupId" = $1 AND
+			"userId" = $2 AND
+			"contactId" IN (SELECT value FROM json_each($3));`
+
+	return r.modifyGroupMembers(user, groupId, contactIds, query)
+}
+
+// modifyGroupMembers runs the membership statement and attributes the change of the group to the device
+// of the user, so the change is not synced back to it.
+func (r *ContactRepository) modifyGroupMembers(user *User, groupId string, contactIds []string, stmt string) (*ContactGroup, error) {
+	if len(contactIds) == 0 {
+		return nil, ErrMissingIdsField
+	}
+
+	idsJson, err := json.Marshal(contactIds)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = getContactGroup(ctx, tx, user, groupId)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT count(*)
+			FROM "Contact"
+			WHERE "userId" = $1 AND
+			"id" IN (SELECT value FROM json_each($2)) AND
+			"lastStmt" < 2;`
+
+	var found int
+
+	err = tx.QueryRowContext(ctx, query, user.Id, string(idsJson)).Scan(&found)
+	if err != nil {
+		return nil, err
+	}
+
+	distinct := map[string]bool{}
+	for _, id := range contactIds {
+		distinct[id] = true
+	}
+
+	if found != len(distinct) {
+		return nil, ErrContactNotFound
+	}
+
+	result, err := tx.ExecContext(ctx, stmt, groupId, user.Id, string(idsJson))
+	if err != nil {
+		return nil, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if affected > 0 {
+		query = `
+			UPDATE "ContactGroup"
+				SET "deviceId" = $1
+				WHERE "userId" = $2 AND
+				"id" = $3;`
+
+		_, err = tx.ExecContext(ctx, query, user.DeviceId, user.Id, groupId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	group, err := getContactGroup(ctx, tx, user, groupId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return group, nil
+}
+
+// ListByGroup lists the contacts of the group like the List does.
+func (r *ContactRepository) ListByGroup(user *User, groupId string, options *ListOptions) (*ContactList, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	query := `
+		SELECT 1
+			FROM "ContactGroup"
+			WHERE "userId" = $1 AND
+			"id" = $2;`
+
+	var exists int
+
+	err := r.db.QueryRowContext(ctx, query, user.Id, groupId).Scan(&exists)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrContactGroupNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	q := newListQuery(user.Id)
+	q.and(`"id" IN (SELECT "contactId" FROM "ContactGroupMember" WHERE "groupId" = ` + q.arg(groupId) + `)`)
+
+	return r.list(user, options, q)
+}
+
+func (r *ContactRepository) SyncGroups(user *User, history *History) (*ContactGroupSync, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var deviceId string
+
+	if !history.IgnoreDevice {
+		deviceId = *user.DeviceId
+	}
+
+	groupSync := &ContactGroupSync{
+		GroupsInserted: []*ContactGroup{},
+		GroupsUpdated:  []*ContactGroup{},
+		GroupsDeleted:  []*ContactGroupDeleted{},
+	}
+
+	// inserted and updated rows
+	query := selectContactGroup + `
+			WHERE "userId" = $1 AND
+				("deviceId" <> $2 OR "deviceId" IS NULL) AND
+				"historyId" > $3 AND
+				coalesce("modifiedAt", "createdAt") >= $4
+			ORDER BY "createdAt" DESC;`
+
+	args := []interface{}{user.Id, deviceId, history.Id, history.since()}
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var group ContactGroup
+
+		err := rows.Scan(group.Scan()...)
+		if err != nil {
+			return nil, err
+		}
+
+		if group.LastStmt == 0 {
+			groupSync.GroupsInserted = append(groupSync.GroupsInserted, &group)
+		} else {
+			groupSync.GroupsUpdated = append(groupSync.GroupsUpdated, &group)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// deleted rows
+	query = `
+		SELECT *
+			FROM "ContactGroupDeleted"
+			WHERE "userId" = $1 AND
+			("deviceId" <> $2 OR "deviceId" IS NULL) AND
+			"historyId" > $3;`
+
+	args = []interface{}{user.Id, deviceId, history.Id}
+
+	rows, err = tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var groupDeleted ContactGroupDeleted
+
+		err := rows.Scan(groupDeleted.Scan()...)
+		if err != nil {
+			return nil, err
+		}
+
+		groupSync.GroupsDeleted = append(groupSync.GroupsDeleted, &groupDeleted)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// history
+	query = `
+		SELECT "lastHistoryId"
+			FROM "ContactGroupHistorySeq"
+			WHERE "userId" = $1 ;`
+
+	err = tx.QueryRowContext(ctx, query, user.Id).Scan(&groupSync.History)
+	if err != nil {
+		return nil, err
+	}
+
+	groupSync.NextPollAfter, err = nextPollAfter(ctx, tx, "ContactGroup", user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return groupSync, nil
+}
@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// Merge copies the fields of the merged contacts the survivor does not have into the survivor, and adds
+// their email addresses and phone numbers. The merged contacts are trashed, not deleted, so the merge can
+// be undone by untrashing them.
+func (r *ContactRepository) Merge(user *User, survivorId string, mergedIds []string) (*Contact, error) {
+	ids := []string{}
+
+	for _, id := range mergedIds {
+		if id != survivorId {
+			ids = append(ids, id)
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil, ErrMissingIdsField
+	}
+
+	idsJson, err := json.Marshal(&Ids{Ids: ids})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT *
+			FROM "Contact"
+			WHERE "userId" = $1 AND
+			"id" = $2 AND
+			"lastStmt" <> 2;`
+
+	survivor := &Contact{}
+
+	err = tx.QueryRowContext(ctx, query, user.Id, survivorId).Scan(survivor.Scan()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrContactNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	// the contacts created before the list was introduced
+	err = survivor.syncEmailAddresses()
+	if err != nil {
+		return nil, err
+	}
+
+	query = `
+		SELECT *
+			FROM "Contact"
+			WHERE "userId" = $1 AND
+			"id" IN (` + jsonIds("$2") + `) AND
+			"lastStmt" <> 2
+			ORDER BY "createdAt", "rowid";`
+
+	rows, err := tx.QueryContext(ctx, query, user.Id, string(idsJson))
+	if err != nil {
+		return nil, err
+	}
+
+	merged := []*Contact{}
+
+	for rows.Next() {
+		var contact Contact
+
+		err := rows.Scan(contact.Scan()...)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		merged = append(merged, &contact)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(merged) != len(ids) {
+		return nil, ErrContactNotFound
+	}
+
+	for _, contact := range merged {
+		survivor.mergeFrom(contact)
+	}
+
+	err = survivor.syncEmailAddresses()
+	if err != nil {
+		return nil, err
+	}
+
+	err = survivor.normalizePhoneNumbers()
+	if err != nil {
+		return nil, err
+	}
+
+	prefixedDeviceId := getPrefixedDeviceId(user.DeviceId)
+
+	// trashed first, so the survivor may take over their email addresses
+	query = `
+		UPDATE "Contact"
+			SET "lastStmt" = 2,
+			"deviceId" = $1
+			WHERE "userId" = $2 AND
+			"id" IN (` + jsonIds("$3") + `);`
+
+	_, err = tx.ExecContext(ctx, query, prefixedDeviceId, user.Id, string(idsJson))
+	if err != nil {
+		return nil, err
+	}
+
+	// the history is bumped by the update trigger
+	query = `
+		UPDATE "Contact"
+			SET "emailAddress" = $1,
+				"firstName" = $2,
+				"lastName" = $3,
+				"deviceId" = $4,
+				"emailAddresses" = $5,
+				"phoneNumbers" = $6,
+				"notes" = $7
+			WHERE "userId" = $8 AND
+			"id" = $9;`
+
+	args := []interface{}{survivor.EmailAddress, survivor.FirstName, survivor.LastName, prefixedDeviceId, survivor.EmailAddresses, survivor.PhoneNumbers, survivor.Notes, user.Id, survivor.Id}
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	query = `
+		SELECT *
+			FROM "Contact"
+			WHERE "userId" = $1 AND
+			"id" = $2;`
+
+	err = tx.QueryRowContext(ctx, query, user.Id, survivor.Id).Scan(survivor.Scan()...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return survivor, nil
+}
+
+func empty(value *string) bool {
+	return value == nil || len(strings.TrimSpace(*value)) == 0
+}
+
+// mergeFrom fills the empty fields of the contact from the other one and adds the email addresses and
+// phone numbers of the other one, the primary email address of the contact is kept.
+func (c *Contact) mergeFrom(other *Contact) {
+	if empty(c.FirstName) && !empty(other.FirstName) {
+		c.FirstName = other.FirstName
+	}
+
+	if empty(c.LastName) && !empty(other.LastName) {
+		c.LastName = other.LastName
+	}
+
+	if empty(c.Notes) && !empty(other.Notes) {
+		c.Notes = other.Notes
+	}
+
+	emails := other.EmailAddresses
+	if emails == nil && other.EmailAddress != nil {
+		emails = ContactEmails{{Address: *other.EmailAddress}}
+	}
+
+	for _, email := range emails {
+		// the duplicates are dropped by the syncEmailAddresses
+		c.EmailAddresses = append(c.EmailAddresses, &ContactEmail{Address: email.Address, Type: email.Type})
+	}
+
+	// the duplicates are dropped by the normalizePhoneNumbers
+	c.PhoneNumbers = append(c.PhoneNumbers, other.PhoneNumbers...)
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+type ContactPhone struct {
+	Number string `json:"number"`         // E.164, e.g. +14155552671
+	Type   string `json:"type,omitempty"` // e.g. mobile, work, home
+}
+
+type ContactPhones []*ContactPhone
+
+func (p ContactPhones) Value() (driver.Value, error) {
+	if p == nil {
+		return nil, nil
+	}
+
+	return json.Marshal(p)
+}
+
+func (p *ContactPhones) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*p = nil
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), p)
+	case []byte:
+		return json.Unmarshal(v, p)
+	default:
+		return errors.New("type assertion failed")
+	}
+}
+
+// normalizePhoneNumber returns the number in the E.164 format. The separators are dropped and the "00"
+// international prefix is replaced by "+", the numbers without the country code are not accepted.
+func normalizePhoneNumber(number string) (string, bool) {
+	number = strings.TrimSpace(number)
+
+	if strings.HasPrefix(number, "00") {
+		number = "+" + number[2:]
+	}
+
+	if !strings.HasPrefix(number, "+") {
+		return "", false
+	}
+
+	digits := []byte{}
+
+	for _, c := range []byte(number[1:]) {
+		switch {
+		case c >= '0' && c <= '9':
+			digits = append(digits, c)
+		case c == ' ' || c == '-' || c == '.' || c == '(' || c == ')':
+		default:
+			return "", false
+		}
+	}
+
+	// the country code does not start with 0, the whole number has up to 15 digits
+	if len(digits) < 7 || len(digits) > 15 || digits[0] == '0' {
+		return "", false
+	}
+
+	return "+" + string(digits), true
+}
+
+// normalizePhoneNumbers normalizes the phone numbers of the contact and drops the duplicates, the type
+// of the first occurrence is kept.
+func (c *Contact) normalizePhoneNumbers() error {
+	if c.PhoneNumbers == nil {
+		return nil
+	}
+
+	phones := ContactPhones{}
+	seen := map[string]bool{}
+
+	for _, phone := range c.PhoneNumbers {
+		if phone == nil {
+			continue
+		}
+
+		number, ok := normalizePhoneNumber(phone.Number)
+		if !ok {
+			return ErrInvalidPhoneNumber
+		}
+
+		if seen[number] {
+			continue
+		}
+		seen[number] = true
+
+		phones = append(phones, &ContactPhone{Number: number, Type: strings.TrimSpace(phone.Type)})
+	}
+
+	c.PhoneNumbers = phones
+
+	return nil
+}
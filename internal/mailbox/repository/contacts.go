@@ -3,23 +3,46 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
-	"reflect"
+	"strings"
 	"time"
 )
 
 type UseContactRepository interface {
 	Create(user *User, contact *Contact) (*Contact, error)
-	List(user *User) (*ContactList, error)
+	CreateOrGet(user *User, contact *Contact) (*Contact, bool, error)
+	CreateBatch(user *User, contacts []*Contact) ([]*ContactBatchResult, error)
+	List(user *User, options *ListOptions) (*ContactList, error)
+	ListEach(user *User, options *ListOptions, each func(*Contact) error) (*ContactList, error)
+	ListTrashed(user *User) (*ContactList, error)
+	Search(user *User, q string, limit int) ([]*Contact, error)
+	ForEach(user *User, ids []string, fn func(contact *Contact) error) error
+	Count(user *User) (*Count, error)
 	Sync(user *User, history *History) (*ContactSync, error)
 	Update(user *User, contact *Contact) (*Contact, error)
 	Trash(user *User, ids string) error
 	Untrash(user *User, ids string) error
 	Delete(user *User, ids string) error
+	EmptyTrash(user *User) error
+	AddEmailAddress(user *User, id string, email *ContactEmail) (*Contact, error)
+	RemoveEmailAddress(user *User, id string, address string) (*Contact, error)
+	SetPrimaryEmailAddress(user *User, id string, address string) (*Contact, error)
+	Merge(user *User, survivorId string, mergedIds []string) (*Contact, error)
+	CreateGroup(user *User, group *ContactGroup) (*ContactGroup, error)
+	ListGroups(user *User) (*ContactGroupList, error)
+	UpdateGroup(user *User, group *ContactGroup) (*ContactGroup, error)
+	DeleteGroup(user *User, id string) error
+	SyncGroups(user *User, history *History) (*ContactGroupSync, error)
+	AssignToGroup(user *User, groupId string, contactIds []string) (*ContactGroup, error)
+	RemoveFromGroup(user *User, groupId string, contactIds []string) (*ContactGroup, error)
+	ListByGroup(user *User, groupId string, options *ListOptions) (*ContactList, error)
 }
 
 type ContactRepository struct {
-	db *sql.DB
+	db       *sql.DB
+	replica  *sql.DB // the List, Sync, Count and Search, nil = the db
+	timeouts Timeouts
 }
 
 type Contact struct {
@@ -34,6 +57,17 @@ type Contact struct {
 	HistoryId    int64      `json:"-"`
 	LastStmt     int        `json:"-"`
 	DeviceId     *string    `json:"-"`
+
+	EmailAddresses ContactEmails `json:"emailAddresses"`
+	PhoneNumbers   ContactPhones `json:"phoneNumbers"`
+	Notes          *string       `json:"notes"`
+}
+
+var contactSortExprs = map[string]string{
+	"createdAt":  `"createdAt"`,
+	"modifiedAt": `coalesce("modifiedAt", "createdAt")`,
+	"name":       `(coalesce("firstName", '') || ' ' || coalesce("lastName", '')) COLLATE NOCASE`,
+	"lastName":   `coalesce("lastName", '') COLLATE NOCASE`,
 }
 
 type ContactDeleted struct {
@@ -43,6 +77,11 @@ type ContactDeleted struct {
 	DeviceId  *string `json:"-"`
 }
 
+type ContactBatchResult struct {
+	Contact *Contact `json:"contact,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
 type ContactList struct {
 	History  int64      `json:"lastHistoryId"`
 	Contacts []*Contact `json:"contacts"`
@@ -50,6 +89,7 @@ type ContactList struct {
 
 type ContactSync struct {
 	History          int64             `json:"lastHistoryId"`
+	NextPollAfter    int               `json:"nextPollAfter"`
 	ContactsInserted []*Contact        `json:"inserted"`
 	ContactsUpdated  []*Contact        `json:"updated"`
 	ContactsTrashed  []*Contact        `json:"trashed"`
@@ -57,47 +97,44 @@ type ContactSync struct {
 }
 
 func (c *Contact) Scan() []interface{} {
-	s := reflect.ValueOf(c).Elem()
-	numCols := s.NumField()
-	columns := make([]interface{}, numCols)
-	for i := 0; i < numCols; i++ {
-		field := s.Field(i)
-		columns[i] = field.Addr().Interface()
-	}
-	return columns
+	return scanColumns(c)
 }
 
 func (c *ContactDeleted) Scan() []interface{} {
-	s := reflect.ValueOf(c).Elem()
-	numCols := s.NumField()
-	columns := make([]interface{}, numCols)
-	for i := 0; i < numCols; i++ {
-		field := s.Field(i)
-		columns[i] = field.Addr().Interface()
-	}
-	return columns
+	return scanColumns(c)
 }
 
 func (r *ContactRepository) Create(user *User, contact *Contact) (*Contact, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := r.timeouts.write()
 	defer cancel()
 
+	err := contact.syncEmailAddresses()
+	if err != nil {
+		return nil, err
+	}
+
+	err = contact.normalizePhoneNumbers()
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
 		INSERT
-			INTO "Contact" ("userId", "deviceId", "emailAddress", "firstName", "lastName")
-			VALUES ($1, $2, $3, $4, $5)
+			INTO "Contact" ("userId", "deviceId", "emailAddress", "firstName", "lastName", "emailAddresses", "phoneNumbers", "notes")
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 			RETURNING * ;`
 
 	prefixedDeviceId := getPrefixedDeviceId(user.DeviceId)
 
-	args := []interface{}{user.Id, prefixedDeviceId, contact.EmailAddress, contact.FirstName, contact.LastName}
+	args := []interface{}{user.Id, prefixedDeviceId, contact.EmailAddress, contact.FirstName, contact.LastName, contact.EmailAddresses, contact.PhoneNumbers, contact.Notes}
 
-	err := r.db.QueryRowContext(ctx, query, args...).Scan(contact.Scan()...)
+	err = r.db.QueryRowContext(ctx, query, args...).Scan(contact.Scan()...)
 	if err != nil {
 		switch {
-		case err.Error() == `UNIQUE constraint failed: Contact.emailAddress`:
+		case strings.HasPrefix(err.Error(), `UNIQUE constraint failed: Contact.`):
 			return nil, ErrDuplicateContact
-		case err.Error() == `CHECK constraint failed: emailAddress`:
+		case strings.HasPrefix(err.Error(), `CHECK constraint failed: emailAddress`),
+			strings.HasPrefix(err.Error(), `NOT NULL constraint failed: Contact.emailAddress`):
 			return nil, ErrInvalidEmailAddress
 		default:
 			return nil, err
@@ -107,8 +144,51 @@ func (r *ContactRepository) Create(user *User, contact *Contact) (*Contact, erro
 	return contact, nil
 }
 
-func (r *ContactRepository) List(user *User) (*ContactList, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+// CreateOrGet is the idempotent Create, the existing contact with the same primary email address is
+// returned instead of the ErrDuplicateContact. The flag tells whether the contact was created.
+func (r *ContactRepository) CreateOrGet(user *User, contact *Contact) (*Contact, bool, error) {
+	created, err := r.Create(user, contact)
+	if err == nil {
+		return created, true, nil
+	}
+
+	if !errors.Is(err, ErrDuplicateContact) {
+		return nil, false, err
+	}
+
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	// the same condition as the unique index
+	query := `
+		SELECT *
+			FROM "Contact"
+			WHERE "userId" = $1 AND
+			"emailAddress" = $2 AND
+			"lastStmt" < 2;`
+
+	args := []interface{}{user.Id, contact.EmailAddress}
+
+	existing := &Contact{}
+
+	err = r.db.QueryRowContext(ctx, query, args...).Scan(existing.Scan()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			// trashed in the meantime
+			return nil, false, ErrDuplicateContact
+		default:
+			return nil, false, err
+		}
+	}
+
+	return existing, false, nil
+}
+
+// CreateBatch inserts the contacts in one transaction. The duplicate and invalid contacts are reported
+// per item, in the order of the request, and do not fail the whole batch.
+func (r *ContactRepository) CreateBatch(user *User, contacts []*Contact) ([]*ContactBatchResult, error) {
+	ctx, cancel := r.timeouts.write()
 	defer cancel()
 
 	tx, err := r.db.BeginTx(ctx, nil)
@@ -117,12 +197,186 @@ func (r *ContactRepository) List(user *User) (*ContactList, error) {
 	}
 	defer tx.Rollback()
 
+	query := `
+		INSERT
+			INTO "Contact" ("userId", "deviceId", "emailAddress", "firstName", "lastName", "emailAddresses", "phoneNumbers", "notes")
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			RETURNING * ;`
+
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	prefixedDeviceId := getPrefixedDeviceId(user.DeviceId)
+
+	results := make([]*ContactBatchResult, 0, len(contacts))
+
+	for _, contact := range contacts {
+		if contact == nil {
+			results = append(results, &ContactBatchResult{Error: ErrInvalidEmailAddress.Error()})
+			continue
+		}
+
+		err = contact.syncEmailAddresses()
+		if err == nil {
+			err = contact.normalizePhoneNumbers()
+		}
+		if err != nil {
+			results = append(results, &ContactBatchResult{Error: err.Error()})
+			continue
+		}
+
+		args := []interface{}{user.Id, prefixedDeviceId, contact.EmailAddress, contact.FirstName, contact.LastName, contact.EmailAddresses, contact.PhoneNumbers, contact.Notes}
+
+		// a failed statement does not abort the transaction
+		err = stmt.QueryRowContext(ctx, args...).Scan(contact.Scan()...)
+		if err != nil {
+			switch {
+			case strings.HasPrefix(err.Error(), `UNIQUE constraint failed: Contact.`):
+				results = append(results, &ContactBatchResult{Error: ErrDuplicateContact.Error()})
+			case strings.HasPrefix(err.Error(), `CHECK constraint failed: emailAddress`),
+				strings.HasPrefix(err.Error(), `NOT NULL constraint failed: Contact.emailAddress`):
+				results = append(results, &ContactBatchResult{Error: ErrInvalidEmailAddress.Error()})
+			default:
+				return nil, err
+			}
+			continue
+		}
+
+		results = append(results, &ContactBatchResult{Contact: contact})
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func (r *ContactRepository) List(user *User, options *ListOptions) (*ContactList, error) {
+	return r.list(user, options, newListQuery(user.Id))
+}
+
+// ListEach lists the contacts like the List does, but passes each one to the each as it is read instead
+// of collecting them, the returned list holds the history only.
+func (r *ContactRepository) ListEach(user *User, options *ListOptions, each func(*Contact) error) (*ContactList, error) {
+	return r.listEach(user, options, newListQuery(user.Id), each)
+}
+
+// list lists the contacts matching the conditions of the query, the query holds the user id as its first arg
+func (r *ContactRepository) list(user *User, options *ListOptions, q *listQuery) (*ContactList, error) {
+	contacts := []*Contact{}
+
+	contactList, err := r.listEach(user, options, q, func(contact *Contact) error {
+		contacts = append(contacts, contact)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	contactList.Contacts = contacts
+
+	return contactList, nil
+}
+
+func (r *ContactRepository) listEach(user *User, options *ListOptions, q *listQuery, each func(*Contact) error) (*ContactList, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	tx, err := reader(r.db, r.replica, user).BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if options == nil {
+		options = &ListOptions{}
+	}
+
+	orderBy, err := options.orderBy(contactSortExprs, "createdAt", "desc")
+	if err != nil {
+		return nil, err
+	}
+
+	err = options.dateRange(q)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
 		SELECT *
 			FROM "Contact"
 			WHERE "userId" = $1 AND
-			"lastStmt" < 2
-			ORDER BY "createdAt" DESC;`
+			"lastStmt" < 2` + q.whereClause() + orderBy + `;`
+
+	rows, err := tx.QueryContext(ctx, query, q.args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	contactList := &ContactList{}
+
+	for rows.Next() {
+		var contact Contact
+
+		err := rows.Scan(contact.Scan()...)
+
+		if err != nil {
+			return nil, err
+		}
+
+		err = each(&contact)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// history
+	query = `
+	SELECT "lastHistoryId"
+	   FROM "ContactHistorySeq"
+	   WHERE "userId" = $1 ;`
+
+	args := []interface{}{user.Id}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&contactList.History)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return contactList, nil
+}
+
+// ListTrashed lists the trashed contacts, the recently trashed first.
+func (r *ContactRepository) ListTrashed(user *User) (*ContactList, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	tx, err := reader(r.db, r.replica, user).BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT *
+			FROM "Contact"
+			WHERE "userId" = $1 AND
+			"lastStmt" = 2
+			ORDER BY coalesce("modifiedAt", "createdAt") DESC, "rowid" DESC;`
 
 	args := []interface{}{user.Id}
 
@@ -141,7 +395,6 @@ func (r *ContactRepository) List(user *User) (*ContactList, error) {
 		var contact Contact
 
 		err := rows.Scan(contact.Scan()...)
-
 		if err != nil {
 			return nil, err
 		}
@@ -155,11 +408,9 @@ func (r *ContactRepository) List(user *User) (*ContactList, error) {
 
 	// history
 	query = `
-	SELECT "lastHistoryId"
-	   FROM "ContactHistorySeq"
-	   WHERE "userId" = $1 ;`
-
-	args = []interface{}{user.Id}
+		SELECT "lastHistoryId"
+			FROM "ContactHistorySeq"
+			WHERE "userId" = $1 ;`
 
 	err = tx.QueryRowContext(ctx, query, args...).Scan(&contactList.History)
 	if err != nil {
@@ -173,11 +424,92 @@ func (r *ContactRepository) List(user *User) (*ContactList, error) {
 	return contactList, nil
 }
 
+// ForEach calls the fn for every contact not trashed, or for the contacts of the ids only, ordered by the
+// name. The rows are read one by one, so the large address books are not loaded into the memory at once.
+func (r *ContactRepository) ForEach(user *User, ids []string, fn func(contact *Contact) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	q := newListQuery(user.Id)
+
+	if ids != nil {
+		idsJson, err := json.Marshal(ids)
+		if err != nil {
+			return err
+		}
+
+		q.and(`"id" IN (SELECT value FROM json_each(` + q.arg(string(idsJson)) + `))`)
+	}
+
+	query := `
+		SELECT *
+			FROM "Contact"
+			WHERE "userId" = $1 AND
+			"lastStmt" < 2` + q.whereClause() + `
+			ORDER BY "lastName" COLLATE NOCASE, "firstName" COLLATE NOCASE, "emailAddress";`
+
+	rows, err := r.db.QueryContext(ctx, query, q.args...)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var contact Contact
+
+		err := rows.Scan(contact.Scan()...)
+		if err != nil {
+			return err
+		}
+
+		err = fn(&contact)
+		if err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (r *ContactRepository) Count(user *User) (*Count, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	query := `
+		SELECT COUNT(*) FILTER (WHERE "lastStmt" < 2),
+			COUNT(*) FILTER (WHERE "lastStmt" = 2)
+			FROM "Contact"
+			WHERE "userId" = $1;`
+
+	args := []interface{}{user.Id}
+
+	count := &Count{}
+
+	err := reader(r.db, r.replica, user).QueryRowContext(ctx, query, args...).Scan(&count.Count, &count.Trashed)
+	if err != nil {
+		return nil, err
+	}
+
+	return count, nil
+}
+
 func (r *ContactRepository) Sync(user *User, history *History) (*ContactSync, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := r.timeouts.read()
 	defer cancel()
 
-	tx, err := r.db.BeginTx(ctx, nil)
+	var sync *ContactSync
+
+	err := retryBusy(ctx, func() (err error) {
+		sync, err = r.trySync(ctx, user, history)
+		return err
+	})
+
+	return sync, err
+}
+
+func (r *ContactRepository) trySync(ctx context.Context, user *User, history *History) (*ContactSync, error) {
+	tx, err := reader(r.db, r.replica, user).BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -196,10 +528,11 @@ func (r *ContactRepository) Sync(user *User, history *History) (*ContactSync, er
 			WHERE "userId" = $1 AND
 				"lastStmt" = 0 AND
 				("deviceId" <> $2 OR "deviceId" IS NULL) AND
-				"historyId" > $3
+				"historyId" > $3 AND
+				coalesce("modifiedAt", "createdAt") >= $4
 			ORDER BY "createdAt" DESC;`
 
-	args := []interface{}{user.Id, deviceId, history.Id}
+	args := []interface{}{user.Id, deviceId, history.Id, history.since()}
 
 	rows, err := tx.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -238,10 +571,11 @@ func (r *ContactRepository) Sync(user *User, history *History) (*ContactSync, er
 			WHERE "userId" = $1 AND
 				"lastStmt" = 1 AND
 				("deviceId" <> $2 OR "deviceId" IS NULL) AND
-				"historyId" > $3
+				"historyId" > $3 AND
+				coalesce("modifiedAt", "createdAt") >= $4
 			ORDER BY "createdAt" DESC;`
 
-	args = []interface{}{user.Id, deviceId, history.Id}
+	args = []interface{}{user.Id, deviceId, history.Id, history.since()}
 
 	rows, err = tx.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -273,10 +607,11 @@ func (r *ContactRepository) Sync(user *User, history *History) (*ContactSync, er
 			WHERE "userId" = $1 AND
 				"lastStmt" = 2 AND
 				("deviceId" <> $2 OR "deviceId" IS NULL) AND
-				"historyId" > $3
+				"historyId" > $3 AND
+				coalesce("modifiedAt", "createdAt") >= $4
 			ORDER BY "createdAt" DESC;`
 
-	args = []interface{}{user.Id, deviceId, history.Id}
+	args = []interface{}{user.Id, deviceId, history.Id, history.since()}
 
 	rows, err = tx.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -347,6 +682,11 @@ func (r *ContactRepository) Sync(user *User, history *History) (*ContactSync, er
 		return nil, err
 	}
 
+	contactSync.NextPollAfter, err = nextPollAfter(ctx, tx, "Contact", user.Id)
+	if err != nil {
+		return nil, err
+	}
+
 	if err = tx.Commit(); err != nil {
 		return nil, err
 	}
@@ -355,7 +695,7 @@ func (r *ContactRepository) Sync(user *User, history *History) (*ContactSync, er
 }
 
 func (r *ContactRepository) Update(user *User, contact *Contact) (*Contact, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := r.timeouts.write()
 	defer cancel()
 
 	tx, err := r.db.BeginTx(ctx, nil)
@@ -364,29 +704,78 @@ func (r *ContactRepository) Update(user *User, contact *Contact) (*Contact, erro
 	}
 	defer tx.Rollback()
 
+	// without the list, the secondary email addresses are kept and the primary one is replaced
+	if contact.EmailAddresses == nil && contact.EmailAddress != nil {
+		query := `
+			SELECT "emailAddresses"
+				FROM "Contact"
+				WHERE "userId" = $1 AND
+				"id" = $2;`
+
+		args := []interface{}{user.Id, contact.Id}
+
+		err = tx.QueryRowContext(ctx, query, args...).Scan(&contact.EmailAddresses)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+
+		for _, email := range contact.EmailAddresses {
+			if email.Primary {
+				email.Address = *contact.EmailAddress
+			}
+		}
+	}
+
+	emailAddress := contact.EmailAddress
+
+	err = contact.syncEmailAddresses()
+	if err != nil {
+		return nil, err
+	}
+
+	// with the list, the "emailAddress" is the primary one of the list, it isn't changed on its own
+	if !empty(emailAddress) && normalizeEmailAddress(*emailAddress) != *contact.EmailAddress {
+		return nil, ErrPrimaryEmailMismatch
+	}
+
+	err = contact.normalizePhoneNumbers()
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkDuplicateContact(ctx, tx, user, contact)
+	if err != nil {
+		return nil, err
+	}
+
+	// without the list, the phone numbers are kept, an empty list removes them
 	query := `
 		UPDATE "Contact"
 			SET "emailAddress" = $1,
 			    "firstName" = $2,
 				"lastName" = $3,
-				"deviceId" = $4
-			WHERE "userId" = $5 AND
-			      "id" = $6 AND
+				"deviceId" = $4,
+				"emailAddresses" = $5,
+				"phoneNumbers" = coalesce($6, "phoneNumbers"),
+				"notes" = $7
+			WHERE "userId" = $8 AND
+			      "id" = $9 AND
 				  "lastStmt" <> 2
 			RETURNING id ;`
 
 	prefixedDeviceId := getPrefixedDeviceId(user.DeviceId)
 
-	args := []interface{}{contact.EmailAddress, contact.FirstName, contact.LastName, prefixedDeviceId, user.Id, contact.Id}
+	args := []interface{}{contact.EmailAddress, contact.FirstName, contact.LastName, prefixedDeviceId, contact.EmailAddresses, contact.PhoneNumbers, contact.Notes, user.Id, contact.Id}
 
 	err = tx.QueryRowContext(ctx, query, args...).Scan(&contact.Id)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
 			return nil, ErrContactNotFound
-		case err.Error() == `UNIQUE constraint failed: Contact.emailAddress`:
+		case strings.HasPrefix(err.Error(), `UNIQUE constraint failed: Contact.`):
 			return nil, ErrDuplicateContact
-		case err.Error() == `CHECK constraint failed: emailAddress`:
+		case strings.HasPrefix(err.Error(), `CHECK constraint failed: emailAddress`),
+			strings.HasPrefix(err.Error(), `NOT NULL constraint failed: Contact.emailAddress`):
 			return nil, ErrInvalidEmailAddress
 		default:
 			return nil, err
@@ -415,16 +804,22 @@ func (r *ContactRepository) Update(user *User, contact *Contact) (*Contact, erro
 }
 
 func (r *ContactRepository) Trash(user *User, ids string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := r.timeouts.write()
 	defer cancel()
 
+	return retryBusy(ctx, func() error {
+		return r.tryTrash(ctx, user, ids)
+	})
+}
+
+func (r *ContactRepository) tryTrash(ctx context.Context, user *User, ids string) error {
 	if len(ids) > 0 {
 		query := `
 		UPDATE "Contact"
 			SET "lastStmt" = 2,
 			"deviceId" = $1
 			WHERE "userId" = $2 AND
-			"id" IN (SELECT value FROM json_each($3, '$.ids'));`
+			"id" IN (` + jsonIds("$3") + `);`
 
 		prefixedDeviceId := getPrefixedDeviceId(user.DeviceId)
 
@@ -440,16 +835,22 @@ func (r *ContactRepository) Trash(user *User, ids string) error {
 }
 
 func (r *ContactRepository) Untrash(user *User, ids string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := r.timeouts.write()
 	defer cancel()
 
+	return retryBusy(ctx, func() error {
+		return r.tryUntrash(ctx, user, ids)
+	})
+}
+
+func (r *ContactRepository) tryUntrash(ctx context.Context, user *User, ids string) error {
 	if len(ids) > 0 {
 		query := `
 		UPDATE "Contact"
 			SET "lastStmt" = 0,
 			"deviceId" = $1
 			WHERE "userId" = $2 AND
-			"id" IN (SELECT value FROM json_each($3, '$.ids'));`
+			"id" IN (` + jsonIds("$3") + `);`
 
 		prefixedDeviceId := getPrefixedDeviceId(user.DeviceId)
 
@@ -465,9 +866,15 @@ func (r *ContactRepository) Untrash(user *User, ids string) error {
 }
 
 func (r ContactRepository) Delete(user *User, ids string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := r.timeouts.write()
 	defer cancel()
 
+	return retryBusy(ctx, func() error {
+		return r.tryDelete(ctx, user, ids)
+	})
+}
+
+func (r ContactRepository) tryDelete(ctx context.Context, user *User, ids string) error {
 	if len(ids) > 0 {
 		tx, err := r.db.BeginTx(ctx, nil)
 		if err != nil {
@@ -479,7 +886,7 @@ func (r ContactRepository) Delete(user *User, ids string) error {
 		DELETE
 			FROM "Contact"
 			WHERE "userId" = $1 AND
-			"id" IN (SELECT value FROM json_each($2, '$.ids'));`
+			"id" IN (` + jsonIds("$2") + `);`
 
 		args := []interface{}{user.Id, ids}
 
@@ -492,7 +899,7 @@ func (r ContactRepository) Delete(user *User, ids string) error {
 		UPDATE "ContactDeleted"
 			SET "deviceId" = $1
 			WHERE "userId" = $2 AND
-			"id" IN (SELECT value FROM json_each($3, '$.ids'));`
+			"id" IN (` + jsonIds("$3") + `);`
 
 		args = []interface{}{user.DeviceId, user.Id, ids}
 
@@ -508,3 +915,119 @@ func (r ContactRepository) Delete(user *User, ids string) error {
 
 	return nil
 }
+
+func (r ContactRepository) EmptyTrash(user *User) error {
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+	SELECT json_object('ids', json_group_array("id"))
+		FROM "Contact"
+		WHERE "userId" = $1 AND
+		"lastStmt" = 2;`
+
+	args := []interface{}{user.Id}
+
+	var ids string
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&ids)
+	if err != nil {
+		return err
+	}
+
+	query = `
+	DELETE
+		FROM "Contact"
+		WHERE "userId" = $1 AND
+		"id" IN (` + jsonIds("$2") + `);`
+
+	args = []interface{}{user.Id, ids}
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	query = `
+	UPDATE "ContactDeleted"
+		SET "deviceId" = $1
+		WHERE "userId" = $2 AND
+		"id" IN (` + jsonIds("$3") + `);`
+
+	args = []interface{}{user.DeviceId, user.Id, ids}
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Search finds the contacts by the full-text index, the most relevant first. Zero limit is no limit.
+func (r *ContactRepository) Search(user *User, q string, limit int) ([]*Contact, error) {
+	match := matchQuery(q)
+	if len(match) == 0 {
+		return nil, ErrMissingSearchQuery
+	}
+
+	if limit < 0 || limit > MaxListLimit {
+		return nil, ErrInvalidLimit
+	}
+
+	if limit == 0 {
+		limit = -1 // no limit in SQLite
+	}
+
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	query := `
+		SELECT "Contact".*
+			FROM "Contact"
+			INNER JOIN "ContactSearch"
+			ON "ContactSearch"."docid" = "Contact"."rowid"
+			WHERE "ContactSearch"."text" MATCH $1 AND
+			"Contact"."userId" = $2 AND
+			"Contact"."lastStmt" < 2
+			ORDER BY ` + searchRank("ContactSearch") + ` DESC, "Contact"."createdAt" DESC
+			LIMIT $3;`
+
+	args := []interface{}{match, user.Id, limit}
+
+	rows, err := reader(r.db, r.replica, user).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	contacts := []*Contact{}
+
+	for rows.Next() {
+		var contact Contact
+
+		err := rows.Scan(contact.Scan()...)
+		if err != nil {
+			return nil, err
+		}
+
+		contacts = append(contacts, &contact)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return contacts, nil
+}
@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+const MaxCSVImportRows = 10000
+
+// ContactCSVRecord is a contact parsed from a CSV row, the Line is the line of the row in the file.
+type ContactCSVRecord struct {
+	Line    int
+	Contact *Contact
+}
+
+// e.g. "E-mail Address", "E-mail 2 Address" (Outlook), "E-mail 1 - Value" (Google), "Email"
+var matchEmailHeader = regexp.MustCompile(`^(primary)?email\d*(address|value)?$`)
+
+var contactCSVHeaders = map[string]string{
+	"firstname":  "firstName",
+	"first":      "firstName",
+	"givenname":  "firstName",
+	"lastname":   "lastName",
+	"last":       "lastName",
+	"familyname": "lastName",
+	"surname":    "lastName",
+	"notes":      "notes",
+	"note":       "notes",
+}
+
+// normalizeCSVHeader keeps the lowercase letters and digits only, e.g. "E-mail Address" -> "emailaddress"
+func normalizeCSVHeader(header string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, header)
+}
+
+// ParseContactsCSV maps the columns of the CSV exports of the common mail clients to the contacts by the
+// header row. The first email address of a row is the primary one, the empty cells are skipped.
+// The rows are not validated, the invalid ones are reported by the CreateBatch.
+func ParseContactsCSV(r io.Reader) ([]*ContactCSVRecord, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, ErrInvalidCSV
+	}
+
+	columns := map[string]int{}
+	emailColumns := []int{}
+
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\uFEFF")
+		}
+
+		normalized := normalizeCSVHeader(name)
+
+		if matchEmailHeader.MatchString(normalized) {
+			emailColumns = append(emailColumns, i)
+			continue
+		}
+
+		if field, ok := contactCSVHeaders[normalized]; ok {
+			if _, seen := columns[field]; !seen {
+				columns[field] = i
+			}
+		}
+	}
+
+	if len(emailColumns) == 0 {
+		return nil, ErrMissingEmailColumn
+	}
+
+	cell := func(row []string, i int) string {
+		if i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	optional := func(row []string, field string) *string {
+		i, ok := columns[field]
+		if !ok {
+			return nil
+		}
+		if value := cell(row, i); len(value) > 0 {
+			return &value
+		}
+		return nil
+	}
+
+	records := []*ContactCSVRecord{}
+
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, ErrInvalidCSV
+		}
+
+		line, _ := reader.FieldPos(0)
+
+		contact := &Contact{
+			FirstName: optional(row, "firstName"),
+			LastName:  optional(row, "lastName"),
+			Notes:     optional(row, "notes"),
+		}
+
+		for _, i := range emailColumns {
+			if address := cell(row, i); len(address) > 0 {
+				contact.EmailAddresses = append(contact.EmailAddresses, &ContactEmail{Address: address})
+			}
+		}
+
+		// the blank rows
+		if contact.EmailAddresses == nil && contact.FirstName == nil && contact.LastName == nil && contact.Notes == nil {
+			continue
+		}
+
+		if len(records) == MaxCSVImportRows {
+			return nil, ErrTooManyCSVRows
+		}
+
+		records = append(records, &ContactCSVRecord{Line: line, Contact: contact})
+	}
+
+	return records, nil
+}
+
+var contactCSVHeader = []string{"First Name", "Last Name", "E-mail Address", "E-mail 2 Address", "E-mail 3 Address", "Notes"}
+
+// ContactCSVWriter writes the contacts in the columns of the Outlook export, which the common mail
+// clients import. The header is written before the first row.
+type ContactCSVWriter struct {
+	w      *csv.Writer
+	header bool
+}
+
+func NewContactCSVWriter(w io.Writer) *ContactCSVWriter {
+	return &ContactCSVWriter{w: csv.NewWriter(w)}
+}
+
+// csvText prevents the spreadsheets from evaluating the cell as a formula
+func csvText(value *string) string {
+	if value == nil {
+		return ""
+	}
+
+	if len(*value) > 0 && strings.ContainsRune("=+-@\t\r", rune((*value)[0])) {
+		return "'" + *value
+	}
+
+	return *value
+}
+
+func (c *ContactCSVWriter) writeHeader() error {
+	if c.header {
+		return nil
+	}
+	c.header = true
+
+	return c.w.Write(contactCSVHeader)
+}
+
+func (c *ContactCSVWriter) Write(contact *Contact) error {
+	err := c.writeHeader()
+	if err != nil {
+		return err
+	}
+
+	emails := []string{}
+
+	if contact.EmailAddress != nil {
+		emails = append(emails, *contact.EmailAddress)
+	}
+
+	for _, email := range contact.EmailAddresses {
+		if !email.Primary {
+			emails = append(emails, email.Address)
+		}
+	}
+
+	for len(emails) < 3 {
+		emails = append(emails, "")
+	}
+
+	return c.w.Write([]string{
+		csvText(contact.FirstName),
+		csvText(contact.LastName),
+		emails[0],
+		emails[1],
+		emails[2],
+		csvText(contact.Notes),
+	})
+}
+
+// Flush writes the header of an empty export and the buffered rows.
+func (c *ContactCSVWriter) Flush() error {
+	err := c.writeHeader()
+	if err != nil {
+		return err
+	}
+
+	c.w.Flush()
+
+	return c.w.Error()
+}
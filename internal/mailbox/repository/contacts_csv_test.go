@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// csvContact is the parsed contact in short, the absent fields are empty.
+type csvContact struct {
+	Line      int
+	FirstName string
+	LastName  string
+	Notes     string
+	Emails    []string
+}
+
+func csvContactOf(record *ContactCSVRecord) csvContact {
+	value := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+
+	c := csvContact{
+		Line:      record.Line,
+		FirstName: value(record.Contact.FirstName),
+		LastName:  value(record.Contact.LastName),
+		Notes:     value(record.Contact.Notes),
+	}
+
+	for _, email := range record.Contact.EmailAddresses {
+		c.Emails = append(c.Emails, email.Address)
+	}
+
+	return c
+}
+
+func TestParseContactsCSV(t *testing.T) {
+	tests := []struct {
+		name     string
+		csv      string
+		contacts []csvContact
+	}{
+		{
+			"outlook",
+			"First Name,Middle Name,Last Name,E-mail Address,E-mail 2 Address,E-mail 3 Address,Notes\n" +
+				"Ann,M.,Lee,ann@example.org,ann@work.example.org,,met at the conference\n",
+			[]csvContact{{2, "Ann", "Lee", "met at the conference", []string{"ann@example.org", "ann@work.example.org"}}},
+		},
+		{
+			"google",
+			"Given Name,Family Name,E-mail 1 - Type,E-mail 1 - Value,E-mail 2 - Value\n" +
+				"Bob,Roe,* Home,bob@example.org,\n",
+			[]csvContact{{2, "Bob", "Roe", "", []string{"bob@example.org"}}},
+		},
+		{
+			"the first of the repeated columns",
+			"Email,First,Surname,Last Name\n" +
+				"carol@example.org,Carol,Doe,Smith\n",
+			[]csvContact{{2, "Carol", "Doe", "", []string{"carol@example.org"}}},
+		},
+		{
+			"quoted fields",
+			"First Name,Last Name,E-mail Address,Notes\n" +
+				`"Lee, Ann","O""Brien", ann@example.org ,"the first line` + "\n" + `the second line, after the comma"` + "\n" +
+				`Dave,"Smith",dave@example.org,""` + "\n",
+			[]csvContact{
+				{2, "Lee, Ann", `O"Brien`, "the first line\nthe second line, after the comma", []string{"ann@example.org"}},
+				{4, "Dave", "Smith", "", []string{"dave@example.org"}},
+			},
+		},
+		{
+			"bom",
+			"\uFEFFE-mail Address,First Name\r\n" +
+				"erin@example.org,Erin\r\n",
+			[]csvContact{{2, "Erin", "", "", []string{"erin@example.org"}}},
+		},
+		{
+			"empty rows",
+			"First Name,E-mail Address\n" +
+				",\n" +
+				"\n" +
+				" , \n" +
+				"Frank,frank@example.org\n" +
+				"Grace\n",
+			[]csvContact{
+				{5, "Frank", "", "", []string{"frank@example.org"}},
+				{6, "Grace", "", "", nil},
+			},
+		},
+		{
+			"header only",
+			"First Name,E-mail Address\n",
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		records, err := ParseContactsCSV(strings.NewReader(tt.csv))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+
+		var contacts []csvContact
+		for _, record := range records {
+			contacts = append(contacts, csvContactOf(record))
+		}
+
+		if !reflect.DeepEqual(contacts, tt.contacts) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, contacts, tt.contacts)
+		}
+	}
+}
+
+func TestParseContactsCSVInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		csv  string
+		err  error
+	}{
+		{"empty", "", ErrInvalidCSV},
+		{"no email column", "Name,Phone\nAnn,+420123456789\n", ErrMissingEmailColumn},
+		{"too many rows", "E-mail Address\n" + strings.Repeat("ann@example.org\n", MaxCSVImportRows+1), ErrTooManyCSVRows},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseContactsCSV(strings.NewReader(tt.csv))
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestImportContactsCSV(t *testing.T) {
+	repository, _ := newTestRepository(t)
+	user := newTestUser(t, repository, "alice")
+
+	records, err := ParseContactsCSV(strings.NewReader(
+		"First Name,E-mail Address,E-mail 2 Address\n" +
+			"Ann,ann@example.org,\n" +
+			"Ann again,ANN@example.org,\n" +
+			"Bob,bob@example,\n" +
+			"Carol,carol@example.org,not an address\n" +
+			"Dave,dave@example.org,dave@example.org\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contacts := []*Contact{}
+	for _, record := range records {
+		contacts = append(contacts, record.Contact)
+	}
+
+	results, err := repository.Contacts.CreateBatch(user, contacts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the rows are reported one by one, the invalid ones don't stop the import
+	want := []string{"", ErrDuplicateContact.Error(), ErrInvalidEmailAddress.Error(), ErrInvalidEmailAddress.Error(), ""}
+
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(results), len(want))
+	}
+
+	for i, result := range results {
+		if result.Error != want[i] {
+			t.Errorf("line %d: got the error %q, want %q", records[i].Line, result.Error, want[i])
+		}
+	}
+
+	// the address repeated in the row is the one address
+	if dave := results[4].Contact; dave == nil || len(dave.EmailAddresses) != 1 {
+		t.Errorf("got the contact %+v, want the one address of dave", dave)
+	}
+}
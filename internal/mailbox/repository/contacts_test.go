@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+)
+
+func newTestContact(t *testing.T, repository Repository, user *User, emailAddress string) *Contact {
+	t.Helper()
+
+	contact, err := repository.Contacts.Create(user, &Contact{EmailAddress: &emailAddress})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return contact
+}
+
+func TestContactUpdatePrimaryEmailAddress(t *testing.T) {
+	repository, _ := newTestRepository(t)
+	user := newTestUser(t, repository, "alice")
+
+	carol := newTestContact(t, repository, user, "carol@example.com")
+	newTestContact(t, repository, user, "dave@example.com")
+
+	// as the GET returns it, with the list
+	changed := "carol2@example.com"
+	carol.EmailAddress = &changed
+
+	_, err := repository.Contacts.Update(user, carol)
+	if !errors.Is(err, ErrPrimaryEmailMismatch) {
+		t.Fatalf("got %v, want ErrPrimaryEmailMismatch", err)
+	}
+
+	duplicate := "dave@example.com"
+	carol.EmailAddress = &duplicate
+
+	_, err = repository.Contacts.Update(user, carol)
+	if !errors.Is(err, ErrPrimaryEmailMismatch) {
+		t.Fatalf("got %v, want ErrPrimaryEmailMismatch", err)
+	}
+
+	// without the list, the primary one is replaced
+	carol.EmailAddress = &changed
+	carol.EmailAddresses = nil
+
+	updated, err := repository.Contacts.Update(user, carol)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *updated.EmailAddress != changed || len(updated.EmailAddresses) != 1 || updated.EmailAddresses[0].Address != changed {
+		t.Errorf("got %q of %v, want %q", *updated.EmailAddress, updated.EmailAddresses, changed)
+	}
+
+	updated.EmailAddress = &duplicate
+	updated.EmailAddresses = nil
+
+	_, err = repository.Contacts.Update(user, updated)
+	if !errors.Is(err, ErrDuplicateContact) {
+		t.Fatalf("got %v, want ErrDuplicateContact", err)
+	}
+
+	// the list of the same primary, in another case
+	mixedCase := "Carol2@Example.com"
+	updated.EmailAddress = &mixedCase
+	updated.EmailAddresses = ContactEmails{{Address: changed, Primary: true}, {Address: "carol@example.org"}}
+
+	updated, err = repository.Contacts.Update(user, updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(updated.EmailAddresses) != 2 {
+		t.Errorf("got %d email addresses, want 2", len(updated.EmailAddresses))
+	}
+}
+
+func TestContactEmailAddressNormalized(t *testing.T) {
+	repository, _ := newTestRepository(t)
+	user := newTestUser(t, repository, "alice")
+
+	carol := newTestContact(t, repository, user, " Carol@Example.COM ")
+
+	if *carol.EmailAddress != "carol@example.com" || carol.EmailAddresses[0].Address != "carol@example.com" {
+		t.Errorf("got %q of %v, want carol@example.com", *carol.EmailAddress, carol.EmailAddresses)
+	}
+
+	// the addresses differing by the case only are the same contact
+	mixedCase := "CAROL@example.com"
+
+	_, err := repository.Contacts.Create(user, &Contact{EmailAddress: &mixedCase})
+	if !errors.Is(err, ErrDuplicateContact) {
+		t.Errorf("got %v, want ErrDuplicateContact", err)
+	}
+
+	existing, created, err := repository.Contacts.CreateOrGet(user, &Contact{EmailAddress: &mixedCase})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if created || existing.Id != carol.Id {
+		t.Errorf("got the contact %s created %t, want the existing %s", existing.Id, created, carol.Id)
+	}
+
+	// and the same address of the list
+	dave, err := repository.Contacts.Create(user, &Contact{EmailAddresses: ContactEmails{
+		{Address: "Dave@Example.com", Type: "work"},
+		{Address: "dave@example.com", Primary: true},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(dave.EmailAddresses) != 1 || *dave.EmailAddress != "dave@example.com" || dave.EmailAddresses[0].Type != "work" {
+		t.Errorf("got %q of %v, want the primary dave@example.com of work", *dave.EmailAddress, dave.EmailAddresses)
+	}
+
+	dave, err = repository.Contacts.AddEmailAddress(user, dave.Id, &ContactEmail{Address: "DAVE@example.com ", Type: "home"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(dave.EmailAddresses) != 1 || dave.EmailAddresses[0].Type != "home" {
+		t.Errorf("got %v, want the existing address of home", dave.EmailAddresses)
+	}
+}
+
+func TestContactSearchSecondaryEmailAddress(t *testing.T) {
+	repository, _ := newTestRepository(t)
+	user := newTestUser(t, repository, "alice")
+
+	carol := newTestContact(t, repository, user, "carol@example.com")
+	newTestContact(t, repository, user, "dave@example.com")
+
+	// the contact of the other user is not found
+	bob := newTestUser(t, repository, "bob")
+	other := newTestContact(t, repository, bob, "erin@example.com")
+	other.EmailAddresses = ContactEmails{{Address: "erin@example.com", Primary: true}, {Address: "carol@work.example.org"}}
+
+	_, err := repository.Contacts.Update(bob, other)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	carol.EmailAddresses = ContactEmails{{Address: "carol@example.com", Primary: true}, {Address: "carol@work.example.org"}}
+
+	carol, err = repository.Contacts.Update(user, carol)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, q := range []string{"carol@work.example.org", "work.example"} {
+		contacts, err := repository.Contacts.Search(user, q, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(contacts) != 1 || contacts[0].Id != carol.Id {
+			t.Errorf("%s: got %d contacts, want carol", q, len(contacts))
+		}
+	}
+
+	// the removed address is not found
+	carol.EmailAddresses = ContactEmails{{Address: "carol@example.com", Primary: true}}
+
+	_, err = repository.Contacts.Update(user, carol)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contacts, err := repository.Contacts.Search(user, "work.example", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(contacts) != 0 {
+		t.Errorf("got %d contacts of the removed address, want 0", len(contacts))
+	}
+}
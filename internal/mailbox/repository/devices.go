@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+)
+
+type UseDeviceRepository interface {
+	Touch(user *User, id, userAgent string) error
+	List(user *User) ([]*Device, error)
+	Revoke(user *User, id string, resync bool) error
+	TakeResync(user *User, id string) (bool, error)
+}
+
+// DeviceRepository keeps the devices the user is logged in on, i.e. the deviceId cookies the sync is
+// scoped by.
+type DeviceRepository struct {
+	db       *sql.DB
+	timeouts Timeouts
+}
+
+type Device struct {
+	UserId     int64     `json:"-"`
+	Id         string    `json:"id"`
+	UserAgent  *string   `json:"userAgent"`
+	Resync     bool      `json:"resync"`
+	CreatedAt  Timestamp `json:"createdAt"`
+	LastSeenAt Timestamp `json:"lastSeenAt"`
+}
+
+func (d *Device) Scan() []interface{} {
+	return scanColumns(d)
+}
+
+// Touch records the device seen, the row is written at most once a minute per device.
+func (r *DeviceRepository) Touch(user *User, id, userAgent string) error {
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	query := `
+		INSERT INTO
+			"Device" ("userId", "id", "userAgent")
+			VALUES ($1, $2, $3)
+			ON CONFLICT ("userId", "id") DO UPDATE
+			SET "userAgent" = excluded."userAgent",
+			"lastSeenAt" = CURRENT_TIMESTAMP
+			WHERE "Device"."lastSeenAt" <= datetime('now', '-60 seconds') OR
+			"Device"."userAgent" IS NOT excluded."userAgent" ;`
+
+	_, err := r.db.ExecContext(ctx, query, user.Id, id, userAgent)
+
+	return err
+}
+
+func (r *DeviceRepository) List(user *User) ([]*Device, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	query := `
+		SELECT *
+			FROM "Device"
+			WHERE "userId" = $1
+			ORDER BY "lastSeenAt" DESC ;`
+
+	rows, err := r.db.QueryContext(ctx, query, user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	devices := []*Device{}
+
+	for rows.Next() {
+		device := &Device{}
+
+		err := rows.Scan(device.Scan()...)
+		if err != nil {
+			return nil, err
+		}
+
+		devices = append(devices, device)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return devices, nil
+}
+
+// Revoke logs the device out, i.e. removes its sessions and revokes its refresh tokens. With resync the device is kept and told to
+// sync from scratch on the next login, otherwise it is forgotten.
+func (r *DeviceRepository) Revoke(user *User, id string, resync bool) error {
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		DELETE
+			FROM "Device"
+			WHERE "userId" = $1 AND
+			"id" = $2 ;`
+
+	if resync {
+		query = `
+			UPDATE "Device"
+				SET "resync" = TRUE
+				WHERE "userId" = $1 AND
+				"id" = $2 ;`
+	}
+
+	result, err := tx.ExecContext(ctx, query, user.Id, id)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return ErrDeviceNotFound
+	}
+
+	query = `
+		DELETE
+			FROM "Session"
+			WHERE "userId" = $1 AND
+			"deviceId" = $2 ;`
+
+	_, err = tx.ExecContext(ctx, query, user.Id, id)
+	if err != nil {
+		return err
+	}
+
+	err = revokeRefreshTokens(ctx, tx, user.Id, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// TakeResync tells whether the device was told to sync from scratch, the flag is cleared.
+func (r *DeviceRepository) TakeResync(user *User, id string) (bool, error) {
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	query := `
+		UPDATE "Device"
+			SET "resync" = FALSE
+			WHERE "userId" = $1 AND
+			"id" = $2 AND
+			"resync"
+			RETURNING "id" ;`
+
+	err := r.db.QueryRowContext(ctx, query, user.Id, id).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
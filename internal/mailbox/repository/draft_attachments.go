@@ -0,0 +1,215 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+// DraftAttachment links the draft to a blob or a file of the same user by its content-addressed uri,
+// i.e. the digest the payload refers to in the Content-ID. The links are deleted with the draft, so
+// the blobs referenced by the deleted drafts only can be reclaimed.
+type DraftAttachment struct {
+	Id         string    `json:"id"`
+	DraftId    string    `json:"draftId"`
+	UserId     int64     `json:"-"`
+	Uri        string    `json:"uri"`
+	Resource   string    `json:"resource"` // blobs, files
+	ResourceId string    `json:"resourceId"`
+	CreatedAt  Timestamp `json:"createdAt"`
+}
+
+func (a *DraftAttachment) Scan() []interface{} {
+	return scanColumns(a)
+}
+
+func draftExists(ctx context.Context, tx *sql.Tx, user *User, id string) error {
+	query := `
+		SELECT 1
+			FROM "Draft"
+			WHERE "userId" = $1 AND
+			"id" = $2 AND
+			"lastStmt" <> 2;`
+
+	var exists int
+
+	err := tx.QueryRowContext(ctx, query, user.Id, id).Scan(&exists)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrDraftNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AttachBlob links the blob, or the file, of the uri to the draft. The attachment of the same uri is
+// returned when the uri is attached already.
+func (r *DraftRepository) AttachBlob(user *User, id string, uri string) (*DraftAttachment, error) {
+	uri = strings.TrimSpace(uri)
+	if len(uri) == 0 {
+		return nil, ErrMissingUriField
+	}
+
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	err = draftExists(ctx, tx, user, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// the blob must belong to the user, the files are the attachments, the blobs are the bodies
+	query := `
+		SELECT 'files', "id"
+			FROM "File"
+			WHERE "userId" = $1 AND
+			"digest" = $2 AND
+			"lastStmt" < 2
+		UNION ALL
+		SELECT 'blobs', "id"
+			FROM "Blob"
+			WHERE "userId" = $1 AND
+			"digest" = $2 AND
+			"lastStmt" < 2
+		LIMIT 1;`
+
+	var resource, resourceId string
+
+	err = tx.QueryRowContext(ctx, query, user.Id, uri).Scan(&resource, &resourceId)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrBlobNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	query = `
+		INSERT OR IGNORE
+			INTO "DraftAttachment" ("draftId", "userId", "uri", "resource", "resourceId")
+			VALUES ($1, $2, $3, $4, $5);`
+
+	args := []interface{}{id, user.Id, uri, resource, resourceId}
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	query = `
+		SELECT *
+			FROM "DraftAttachment"
+			WHERE "draftId" = $1 AND
+			"uri" = $2;`
+
+	attachment := &DraftAttachment{}
+
+	err = tx.QueryRowContext(ctx, query, id, uri).Scan(attachment.Scan()...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return attachment, nil
+}
+
+// DetachBlob unlinks the uri from the draft, the blob itself is kept.
+func (r *DraftRepository) DetachBlob(user *User, id string, uri string) error {
+	if len(uri) == 0 {
+		return ErrMissingUriField
+	}
+
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	query := `
+		DELETE
+			FROM "DraftAttachment"
+			WHERE "userId" = $1 AND
+			"draftId" = $2 AND
+			"uri" = $3;`
+
+	result, err := r.db.ExecContext(ctx, query, user.Id, id, strings.TrimSpace(uri))
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrAttachmentNotFound
+	}
+
+	return nil
+}
+
+func (r *DraftRepository) ListAttachments(user *User, id string) ([]*DraftAttachment, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	err = draftExists(ctx, tx, user, id)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT *
+			FROM "DraftAttachment"
+			WHERE "userId" = $1 AND
+			"draftId" = $2
+			ORDER BY "createdAt", "rowid";`
+
+	rows, err := tx.QueryContext(ctx, query, user.Id, id)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	attachments := []*DraftAttachment{}
+
+	for rows.Next() {
+		var attachment DraftAttachment
+
+		err := rows.Scan(attachment.Scan()...)
+		if err != nil {
+			return nil, err
+		}
+
+		attachments = append(attachments, &attachment)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return attachments, nil
+}
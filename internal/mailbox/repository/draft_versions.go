@@ -0,0 +1,212 @@
+package repository
+
+import (
+	"cargomail/internal/shared/config"
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// DraftVersion is a previous payload of the draft, saved by every update. The versions are append-only,
+// the restore saves the current payload as a new version too.
+type DraftVersion struct {
+	Id         string       `json:"id"`
+	DraftId    string       `json:"draftId"`
+	UserId     int64        `json:"-"`
+	Payload    *MessagePart `json:"payload,omitempty"`
+	SearchText *string      `json:"-"`
+	CreatedAt  Timestamp    `json:"createdAt"`
+}
+
+func (v *DraftVersion) Scan() []interface{} {
+	return scanColumns(v)
+}
+
+// saveDraftVersion saves the current payload of the draft as a version and drops the versions over
+// the limit, the oldest first. It runs in the transaction of the update.
+func saveDraftVersion(ctx context.Context, tx *sql.Tx, user *User, id string) error {
+	limit := config.DraftVersions()
+	if limit == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT
+			INTO "DraftVersion" ("draftId", "userId", "payload", "searchText")
+			SELECT "id", "userId", "payload", "searchText"
+				FROM "Draft"
+				WHERE "userId" = $1 AND
+				"id" = $2 AND
+				"lastStmt" <> 2;`
+
+	_, err := tx.ExecContext(ctx, query, user.Id, id)
+	if err != nil {
+		return err
+	}
+
+	query = `
+		DELETE
+			FROM "DraftVersion"
+			WHERE "draftId" = $1 AND
+			"rowid" NOT IN (SELECT "rowid"
+				FROM "DraftVersion"
+				WHERE "draftId" = $1
+				ORDER BY "rowid" DESC
+				LIMIT $2);`
+
+	_, err = tx.ExecContext(ctx, query, id, limit)
+
+	return err
+}
+
+// ListVersions lists the versions of the draft, the most recent first.
+func (r *DraftRepository) ListVersions(user *User, id string) ([]*DraftVersion, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT 1
+			FROM "Draft"
+			WHERE "userId" = $1 AND
+			"id" = $2 AND
+			"lastStmt" <> 2;`
+
+	var exists int
+
+	err = tx.QueryRowContext(ctx, query, user.Id, id).Scan(&exists)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrDraftNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	query = `
+		SELECT *
+			FROM "DraftVersion"
+			WHERE "userId" = $1 AND
+			"draftId" = $2
+			ORDER BY "rowid" DESC;`
+
+	rows, err := tx.QueryContext(ctx, query, user.Id, id)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	versions := []*DraftVersion{}
+
+	for rows.Next() {
+		var version DraftVersion
+
+		err := rows.Scan(version.Scan()...)
+		if err != nil {
+			return nil, err
+		}
+
+		versions = append(versions, &version)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return versions, nil
+}
+
+// RestoreVersion makes the payload of the version the current one, the replaced payload is saved as
+// a new version, so nothing is lost.
+func (r *DraftRepository) RestoreVersion(user *User, id string, versionId string) (*Draft, error) {
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT *
+			FROM "DraftVersion"
+			WHERE "userId" = $1 AND
+			"draftId" = $2 AND
+			"id" = $3;`
+
+	version := &DraftVersion{}
+
+	err = tx.QueryRowContext(ctx, query, user.Id, id, versionId).Scan(version.Scan()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrDraftVersionNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	err = saveDraftVersion(ctx, tx, user, id)
+	if err != nil {
+		return nil, err
+	}
+
+	query = `
+		UPDATE "Draft"
+			SET "payload" = $1,
+				"searchText" = $2,
+				"deviceId" = $3
+			WHERE "userId" = $4 AND
+			"id" = $5 AND
+			"lastStmt" <> 2;`
+
+	prefixedDeviceId := getPrefixedDeviceId(user.DeviceId)
+
+	args := []interface{}{version.Payload, version.SearchText, prefixedDeviceId, user.Id, id}
+
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if affected == 0 {
+		return nil, ErrDraftNotFound
+	}
+
+	// the history is set by the update trigger
+	query = `
+		SELECT *
+			FROM "Draft"
+			WHERE "userId" = $1 AND
+			"id" = $2;`
+
+	draft := &Draft{}
+
+	err = tx.QueryRowContext(ctx, query, user.Id, id).Scan(draft.Scan()...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return draft, nil
+}
@@ -1,12 +1,13 @@
 package repository
 
 import (
+	"bytes"
 	"cargomail/internal/shared/config"
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"net/mail"
-	"reflect"
 	"strings"
 	"time"
 
@@ -15,18 +16,32 @@ import (
 
 type UseDraftRepository interface {
 	Create(user *User, draft *Draft) (*Draft, error)
-	List(user *User) (*DraftList, error)
+	List(user *User, options *ListOptions) (*DraftList, error)
+	ListEach(user *User, options *ListOptions, each func(*Draft) error) (*DraftList, error)
+	ListTrashed(user *User) (*DraftList, error)
+	Search(user *User, q string) ([]*Draft, error)
+	Count(user *User) (*Count, error)
 	Sync(user *User, history *History) (*DraftSync, error)
 	Update(user *User, draft *Draft) (*Draft, error)
+	SetFlags(user *User, id string, unread, starred *bool) error
+	ListVersions(user *User, id string) ([]*DraftVersion, error)
+	RestoreVersion(user *User, id string, versionId string) (*Draft, error)
+	AttachBlob(user *User, id string, uri string) (*DraftAttachment, error)
+	DetachBlob(user *User, id string, uri string) error
+	ListAttachments(user *User, id string) ([]*DraftAttachment, error)
 	Trash(user *User, ids string) error
 	Untrash(user *User, ids string) error
 	Delete(user *User, ids string) error
+	Discard(user *User, id string) error
+	EmptyTrash(user *User) error
 	GetById(user *User, id string) (*Draft, error)
 	Submit(user *User, draft *Draft) (*Message, error)
 }
 
 type DraftRepository struct {
-	db *sql.DB
+	db       *sql.DB
+	replica  *sql.DB // the List, Sync, Count and Search, nil = the db
+	timeouts Timeouts
 }
 
 // type Attachment struct {
@@ -55,6 +70,12 @@ type Draft struct {
 	HistoryId  int64      `json:"-"`
 	LastStmt   int        `json:"-"`
 	DeviceId   *string    `json:"-"`
+	SearchText *string    `json:"-"`
+}
+
+var draftSortExprs = map[string]string{
+	"createdAt":  `"createdAt"`,
+	"modifiedAt": `coalesce("modifiedAt", "createdAt")`,
 }
 
 type DraftDeleted struct {
@@ -71,6 +92,7 @@ type DraftList struct {
 
 type DraftSync struct {
 	History        int64           `json:"lastHistoryId"`
+	NextPollAfter  int             `json:"nextPollAfter"`
 	DraftsInserted []*Draft        `json:"inserted"`
 	DraftsUpdated  []*Draft        `json:"updated"`
 	DraftsTrashed  []*Draft        `json:"trashed"`
@@ -91,29 +113,45 @@ type DraftSync struct {
 // }
 
 func (d *Draft) Scan() []interface{} {
-	s := reflect.ValueOf(d).Elem()
-	numCols := s.NumField()
-	columns := make([]interface{}, numCols)
-	for i := 0; i < numCols; i++ {
-		field := s.Field(i)
-		columns[i] = field.Addr().Interface()
+	return scanColumns(d)
+}
+
+// MarshalJSON adds the snippet generated from the payload.
+func (c Draft) MarshalJSON() ([]byte, error) {
+	type draft Draft
+
+	return json.Marshal(struct {
+		draft
+		Snippet string `json:"snippet"`
+	}{
+		draft:   draft(c),
+		Snippet: c.Payload.Snippet(config.SnippetHtmlFirst(), config.SnippetLength()),
+	})
+}
+
+// UnmarshalJSON accepts the read-only snippet back, the other unknown fields are rejected.
+func (c *Draft) UnmarshalJSON(data []byte) error {
+	type draft Draft
+
+	v := struct {
+		*draft
+		Snippet string `json:"snippet"`
+	}{
+		draft: (*draft)(c),
 	}
-	return columns
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	return dec.Decode(&v)
 }
 
 func (c *DraftDeleted) Scan() []interface{} {
-	s := reflect.ValueOf(c).Elem()
-	numCols := s.NumField()
-	columns := make([]interface{}, numCols)
-	for i := 0; i < numCols; i++ {
-		field := s.Field(i)
-		columns[i] = field.Addr().Interface()
-	}
-	return columns
+	return scanColumns(c)
 }
 
 func (r *DraftRepository) Create(user *User, draft *Draft) (*Draft, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := r.timeouts.write()
 	defer cancel()
 
 	query := `
@@ -121,11 +159,13 @@ func (r *DraftRepository) Create(user *User, draft *Draft) (*Draft, error) {
 			INTO "Draft" ("userId",
 				 "deviceId",
 				 "unread",
-				 "payload")
+				 "payload",
+				 "searchText")
 			VALUES ($1,
 					$2,
 					$3,
-					$4)
+					$4,
+					$5)
 			RETURNING * ;`
 
 	prefixedDeviceId := getPrefixedDeviceId(user.DeviceId)
@@ -135,7 +175,8 @@ func (r *DraftRepository) Create(user *User, draft *Draft) (*Draft, error) {
 	args := []interface{}{user.Id,
 		prefixedDeviceId,
 		unread,
-		draft.Payload}
+		draft.Payload,
+		draft.SearchText}
 
 	err := r.db.QueryRowContext(ctx, query, args...).Scan(draft.Scan()...)
 	if err != nil {
@@ -145,22 +186,123 @@ func (r *DraftRepository) Create(user *User, draft *Draft) (*Draft, error) {
 	return draft, nil
 }
 
-func (r *DraftRepository) List(user *User) (*DraftList, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (r *DraftRepository) List(user *User, options *ListOptions) (*DraftList, error) {
+	drafts := []*Draft{}
+
+	draftList, err := r.ListEach(user, options, func(draft *Draft) error {
+		drafts = append(drafts, draft)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	draftList.Drafts = drafts
+
+	return draftList, nil
+}
+
+// ListEach lists the drafts like the List does, but passes each one to the each as it is read instead of
+// collecting them, the returned list holds the history only.
+func (r *DraftRepository) ListEach(user *User, options *ListOptions, each func(*Draft) error) (*DraftList, error) {
+	ctx, cancel := r.timeouts.read()
 	defer cancel()
 
-	tx, err := r.db.BeginTx(ctx, nil)
+	tx, err := reader(r.db, r.replica, user).BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
 
+	if options == nil {
+		options = &ListOptions{}
+	}
+
+	// the recently edited drafts first
+	orderBy, err := options.orderBy(draftSortExprs, "modifiedAt", "desc")
+	if err != nil {
+		return nil, err
+	}
+
+	q := newListQuery(user.Id)
+
+	err = options.dateRange(q)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
 		SELECT *
 			FROM "Draft"
 			WHERE "userId" = $1 AND
-			"lastStmt" < 2
-			ORDER BY CASE WHEN "modifiedAt" IS NOT NULL THEN "modifiedAt" ELSE "createdAt" END DESC;`
+			"lastStmt" < 2` + q.whereClause() + orderBy + `;`
+
+	rows, err := tx.QueryContext(ctx, query, q.args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	draftList := &DraftList{}
+
+	for rows.Next() {
+		var draft Draft
+
+		err := rows.Scan(draft.Scan()...)
+
+		if err != nil {
+			return nil, err
+		}
+
+		err = each(&draft)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// history
+	query = `
+	SELECT "lastHistoryId"
+	   FROM "DraftHistorySeq"
+	   WHERE "userId" = $1 ;`
+
+	args := []interface{}{user.Id}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&draftList.History)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return draftList, nil
+}
+
+// Search matches the query against the subject, recipients and body text of the drafts.
+// ListTrashed lists the trashed drafts, the recently trashed first.
+func (r *DraftRepository) ListTrashed(user *User) (*DraftList, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	tx, err := reader(r.db, r.replica, user).BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT *
+			FROM "Draft"
+			WHERE "userId" = $1 AND
+			"lastStmt" = 2
+			ORDER BY coalesce("modifiedAt", "createdAt") DESC, "rowid" DESC;`
 
 	args := []interface{}{user.Id}
 
@@ -179,7 +321,6 @@ func (r *DraftRepository) List(user *User) (*DraftList, error) {
 		var draft Draft
 
 		err := rows.Scan(draft.Scan()...)
-
 		if err != nil {
 			return nil, err
 		}
@@ -193,11 +334,9 @@ func (r *DraftRepository) List(user *User) (*DraftList, error) {
 
 	// history
 	query = `
-	SELECT "lastHistoryId"
-	   FROM "DraftHistorySeq"
-	   WHERE "userId" = $1 ;`
-
-	args = []interface{}{user.Id}
+		SELECT "lastHistoryId"
+			FROM "DraftHistorySeq"
+			WHERE "userId" = $1 ;`
 
 	err = tx.QueryRowContext(ctx, query, args...).Scan(&draftList.History)
 	if err != nil {
@@ -211,11 +350,93 @@ func (r *DraftRepository) List(user *User) (*DraftList, error) {
 	return draftList, nil
 }
 
+// Search finds the drafts by the full-text index, the most relevant first.
+func (r *DraftRepository) Search(user *User, q string) ([]*Draft, error) {
+	match := matchQuery(q)
+	if len(match) == 0 {
+		return nil, ErrMissingSearchQuery
+	}
+
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	query := `
+		SELECT "Draft".*
+			FROM "Draft"
+			INNER JOIN "DraftSearch"
+			ON "DraftSearch"."docid" = "Draft"."rowid"
+			WHERE "DraftSearch"."text" MATCH $1 AND
+			"Draft"."userId" = $2 AND
+			"Draft"."lastStmt" < 2
+			ORDER BY ` + searchRank("DraftSearch") + ` DESC, "Draft"."createdAt" DESC;`
+
+	args := []interface{}{match, user.Id}
+
+	rows, err := reader(r.db, r.replica, user).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	drafts := []*Draft{}
+
+	for rows.Next() {
+		var draft Draft
+
+		err := rows.Scan(draft.Scan()...)
+		if err != nil {
+			return nil, err
+		}
+
+		drafts = append(drafts, &draft)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return drafts, nil
+}
+
+func (r *DraftRepository) Count(user *User) (*Count, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	query := `
+		SELECT COUNT(*) FILTER (WHERE "lastStmt" < 2),
+			COUNT(*) FILTER (WHERE "lastStmt" = 2)
+			FROM "Draft"
+			WHERE "userId" = $1;`
+
+	args := []interface{}{user.Id}
+
+	count := &Count{}
+
+	err := reader(r.db, r.replica, user).QueryRowContext(ctx, query, args...).Scan(&count.Count, &count.Trashed)
+	if err != nil {
+		return nil, err
+	}
+
+	return count, nil
+}
+
 func (r *DraftRepository) Sync(user *User, history *History) (*DraftSync, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := r.timeouts.read()
 	defer cancel()
 
-	tx, err := r.db.BeginTx(ctx, nil)
+	var sync *DraftSync
+
+	err := retryBusy(ctx, func() (err error) {
+		sync, err = r.trySync(ctx, user, history)
+		return err
+	})
+
+	return sync, err
+}
+
+func (r *DraftRepository) trySync(ctx context.Context, user *User, history *History) (*DraftSync, error) {
+	tx, err := reader(r.db, r.replica, user).BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -234,10 +455,11 @@ func (r *DraftRepository) Sync(user *User, history *History) (*DraftSync, error)
 			WHERE "userId" = $1 AND
 				"lastStmt" = 0 AND
 				("deviceId" <> $2 OR "deviceId" IS NULL) AND
-				"historyId" > $3
+				"historyId" > $3 AND
+				coalesce("modifiedAt", "createdAt") >= $4
 			ORDER BY "createdAt" DESC;`
 
-	args := []interface{}{user.Id, deviceId, history.Id}
+	args := []interface{}{user.Id, deviceId, history.Id, history.since()}
 
 	rows, err := tx.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -276,10 +498,11 @@ func (r *DraftRepository) Sync(user *User, history *History) (*DraftSync, error)
 			WHERE "userId" = $1 AND
 				"lastStmt" = 1 AND
 				("deviceId" <> $2 OR "deviceId" IS NULL) AND
-				"historyId" > $3
+				"historyId" > $3 AND
+				coalesce("modifiedAt", "createdAt") >= $4
 			ORDER BY "createdAt" DESC;`
 
-	args = []interface{}{user.Id, deviceId, history.Id}
+	args = []interface{}{user.Id, deviceId, history.Id, history.since()}
 
 	rows, err = tx.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -311,10 +534,11 @@ func (r *DraftRepository) Sync(user *User, history *History) (*DraftSync, error)
 			WHERE "userId" = $1 AND
 				"lastStmt" = 2 AND
 				("deviceId" <> $2 OR "deviceId" IS NULL) AND
-				"historyId" > $3
+				"historyId" > $3 AND
+				coalesce("modifiedAt", "createdAt") >= $4
 			ORDER BY "createdAt" DESC;`
 
-	args = []interface{}{user.Id, deviceId, history.Id}
+	args = []interface{}{user.Id, deviceId, history.Id, history.since()}
 
 	rows, err = tx.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -385,6 +609,11 @@ func (r *DraftRepository) Sync(user *User, history *History) (*DraftSync, error)
 		return nil, err
 	}
 
+	draftSync.NextPollAfter, err = nextPollAfter(ctx, tx, "Draft", user.Id)
+	if err != nil {
+		return nil, err
+	}
+
 	if err = tx.Commit(); err != nil {
 		return nil, err
 	}
@@ -393,7 +622,7 @@ func (r *DraftRepository) Sync(user *User, history *History) (*DraftSync, error)
 }
 
 func (r *DraftRepository) Update(user *User, draft *Draft) (*Draft, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := r.timeouts.write()
 	defer cancel()
 
 	tx, err := r.db.BeginTx(ctx, nil)
@@ -402,18 +631,24 @@ func (r *DraftRepository) Update(user *User, draft *Draft) (*Draft, error) {
 	}
 	defer tx.Rollback()
 
+	err = saveDraftVersion(ctx, tx, user, draft.Id)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
 		UPDATE "Draft"
 			SET "payload" = $1,
-				"deviceId" = $2
-			WHERE "userId" = $3 AND
-			      "id" = $4 AND
+				"searchText" = $2,
+				"deviceId" = $3
+			WHERE "userId" = $4 AND
+			      "id" = $5 AND
 				  "lastStmt" <> 2
 			RETURNING id ;`
 
 	prefixedDeviceId := getPrefixedDeviceId(user.DeviceId)
 
-	args := []interface{}{draft.Payload, prefixedDeviceId, user.Id, draft.Id}
+	args := []interface{}{draft.Payload, draft.SearchText, prefixedDeviceId, user.Id, draft.Id}
 
 	err = tx.QueryRowContext(ctx, query, args...).Scan(&draft.Id)
 	if err != nil {
@@ -446,17 +681,63 @@ func (r *DraftRepository) Update(user *User, draft *Draft) (*Draft, error) {
 	return draft, nil
 }
 
+// SetFlags updates the unread and starred flags only, the nil flag is left unchanged, so the client does
+// not need to send the whole payload.
+func (r *DraftRepository) SetFlags(user *User, id string, unread, starred *bool) error {
+	if unread == nil && starred == nil {
+		return ErrMissingStateField
+	}
+
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	query := `
+		UPDATE "Draft"
+			SET "unread" = coalesce($1, "unread"),
+				"starred" = coalesce($2, "starred"),
+				"deviceId" = $3
+			WHERE "userId" = $4 AND
+			"id" = $5 AND
+			"lastStmt" <> 2;`
+
+	prefixedDeviceId := getPrefixedDeviceId(user.DeviceId)
+
+	args := []interface{}{unread, starred, prefixedDeviceId, user.Id, id}
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrDraftNotFound
+	}
+
+	return nil
+}
+
 func (r *DraftRepository) Trash(user *User, ids string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := r.timeouts.write()
 	defer cancel()
 
+	return retryBusy(ctx, func() error {
+		return r.tryTrash(ctx, user, ids)
+	})
+}
+
+func (r *DraftRepository) tryTrash(ctx context.Context, user *User, ids string) error {
 	if len(ids) > 0 {
 		query := `
 		UPDATE Draft
 			SET "lastStmt" = 2,
 			"deviceId" = $1
 			WHERE "userId" = $2 AND
-			"id" IN (SELECT value FROM json_each($3, '$.ids'));`
+			"id" IN (` + jsonIds("$3") + `);`
 
 		prefixedDeviceId := getPrefixedDeviceId(user.DeviceId)
 
@@ -472,16 +753,22 @@ func (r *DraftRepository) Trash(user *User, ids string) error {
 }
 
 func (r *DraftRepository) Untrash(user *User, ids string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := r.timeouts.write()
 	defer cancel()
 
+	return retryBusy(ctx, func() error {
+		return r.tryUntrash(ctx, user, ids)
+	})
+}
+
+func (r *DraftRepository) tryUntrash(ctx context.Context, user *User, ids string) error {
 	if len(ids) > 0 {
 		query := `
 		UPDATE "Draft"
 			SET "lastStmt" = 0,
 			"deviceId" = $1
 			WHERE "userId" = $2 AND
-			"id" IN (SELECT value FROM json_each($3, '$.ids'));`
+			"id" IN (` + jsonIds("$3") + `);`
 
 		prefixedDeviceId := getPrefixedDeviceId(user.DeviceId)
 
@@ -497,9 +784,15 @@ func (r *DraftRepository) Untrash(user *User, ids string) error {
 }
 
 func (r DraftRepository) Delete(user *User, ids string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := r.timeouts.write()
 	defer cancel()
 
+	return retryBusy(ctx, func() error {
+		return r.tryDelete(ctx, user, ids)
+	})
+}
+
+func (r DraftRepository) tryDelete(ctx context.Context, user *User, ids string) error {
 	if len(ids) > 0 {
 		tx, err := r.db.BeginTx(ctx, nil)
 		if err != nil {
@@ -511,7 +804,7 @@ func (r DraftRepository) Delete(user *User, ids string) error {
 		DELETE
 			FROM "Draft"
 			WHERE "userId" = $1 AND
-			"id" IN (SELECT value FROM json_each($2, '$.ids'));`
+			"id" IN (` + jsonIds("$2") + `);`
 
 		args := []interface{}{user.Id, ids}
 
@@ -524,7 +817,7 @@ func (r DraftRepository) Delete(user *User, ids string) error {
 		UPDATE "DraftDeleted"
 			SET "deviceId" = $1
 			WHERE "userId" = $2 AND
-			"id" IN (SELECT value FROM json_each($3, '$.ids'));`
+			"id" IN (` + jsonIds("$3") + `);`
 
 		args = []interface{}{user.DeviceId, user.Id, ids}
 
@@ -541,8 +834,60 @@ func (r DraftRepository) Delete(user *User, ids string) error {
 	return nil
 }
 
+// Discard deletes the draft of an abandoned compose without moving it to the trash first. The trashed
+// drafts are deleted by Delete or EmptyTrash.
+func (r DraftRepository) Discard(user *User, id string) error {
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		DELETE
+			FROM "Draft"
+			WHERE "userId" = $1 AND
+			"id" = $2 AND
+			"lastStmt" <> 2;`
+
+	args := []interface{}{user.Id, id}
+
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrDraftNotFound
+	}
+
+	// the tombstone is inserted by the delete trigger
+	query = `
+		UPDATE "DraftDeleted"
+			SET "deviceId" = $1
+			WHERE "userId" = $2 AND
+			"id" = $3;`
+
+	args = []interface{}{user.DeviceId, user.Id, id}
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
 func (r DraftRepository) GetById(user *User, id string) (*Draft, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := r.timeouts.write()
 	defer cancel()
 
 	query := `
@@ -607,14 +952,10 @@ func validRecipients(str string) (recipients []string, ok bool) {
 	return recipients, len(s) > 1
 }
 
-func (r DraftRepository) Submit(user *User, draft *Draft) (*Message, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	t := time.Now()
-	draft.Payload.Headers["Date"] = t.Format(time.UnixDate)
-
-	if val, ok := draft.Payload.Headers["From"].(string); ok {
+// SubmissionRecipients checks the sender of the headers is the user, and returns the addresses of the To,
+// Cc and Bcc recipients.
+func SubmissionRecipients(user *User, headers map[string]interface{}) ([]string, error) {
+	if val, ok := headers["From"].(string); ok {
 		if len(val) == 0 {
 			return nil, ErrMissingSender
 		}
@@ -628,7 +969,7 @@ func (r DraftRepository) Submit(user *User, draft *Draft) (*Message, error) {
 
 	var recipients []string
 
-	if val, ok := draft.Payload.Headers["To"].(string); ok {
+	if val, ok := headers["To"].(string); ok {
 		if len(val) == 0 {
 			return nil, ErrMissingRecipients
 		}
@@ -644,7 +985,7 @@ func (r DraftRepository) Submit(user *User, draft *Draft) (*Message, error) {
 		return nil, ErrMissingRecipients
 	}
 
-	if val, ok := draft.Payload.Headers["Cc"].(string); ok {
+	if val, ok := headers["Cc"].(string); ok {
 		if val, ok := validRecipients(val); ok {
 			if ok {
 				recipients = append(recipients, val...)
@@ -654,7 +995,7 @@ func (r DraftRepository) Submit(user *User, draft *Draft) (*Message, error) {
 		}
 	}
 
-	if val, ok := draft.Payload.Headers["Bcc"].(string); ok {
+	if val, ok := headers["Bcc"].(string); ok {
 		if val, ok := validRecipients(val); ok {
 			if ok {
 				recipients = append(recipients, val...)
@@ -664,8 +1005,35 @@ func (r DraftRepository) Submit(user *User, draft *Draft) (*Message, error) {
 		}
 	}
 
+	return recipients, nil
+}
+
+// SetDate sets the Date header of the draft to the time, in the date-time format of the RFC 5322.
+func (d *Draft) SetDate(t time.Time) {
+	d.Payload.Headers["Date"] = t.Format(time.RFC1123Z)
+}
+
+func (r DraftRepository) Submit(user *User, draft *Draft) (*Message, error) {
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	// keep the Date the draft was sent through the relay with
+	if val, ok := draft.Payload.Headers["Date"].(string); !ok || len(val) == 0 {
+		draft.SetDate(time.Now())
+	}
+
+	recipients, err := SubmissionRecipients(user, draft.Payload.Headers)
+	if err != nil {
+		return nil, err
+	}
+
 	messageIdValue := "<" + uuid.NewString() + "@" + config.Configuration.DomainName + ">"
 
+	// keep the Message-ID the draft was sent through the relay with
+	if val, ok := draft.Payload.Headers["Message-ID"].(string); ok && len(val) > 0 {
+		messageIdValue = val
+	}
+
 	var threadIdValue string
 
 	if val, ok := draft.Payload.Headers["X-Thread-ID"].(string); ok {
@@ -858,8 +1226,18 @@ func (r DraftRepository) Submit(user *User, draft *Draft) (*Message, error) {
 
 	var recipientsNotFound []string
 
+	// the recipients of the peer instances by their domains
+	federated := map[string][]string{}
+
 	// simple send
 	for _, recipient := range recipients {
+		if _, domain, _ := strings.Cut(recipient, "@"); !strings.EqualFold(domain, config.Configuration.DomainName) {
+			if _, ok := config.FederationPeer(domain); ok {
+				federated[strings.ToLower(domain)] = append(federated[strings.ToLower(domain)], recipient)
+				continue
+			}
+		}
+
 		message := &Message{}
 
 		query = `
@@ -953,6 +1331,11 @@ func (r DraftRepository) Submit(user *User, draft *Draft) (*Message, error) {
 		}
 	}
 
+	err = enqueueFederated(ctx, tx, user, draft.Payload, federated)
+	if err != nil {
+		return nil, err
+	}
+
 	query = `
 	UPDATE "Blob"
 		SET "draftId" = NULL,
@@ -1008,3 +1391,61 @@ func (r DraftRepository) Submit(user *User, draft *Draft) (*Message, error) {
 
 	return returnMessage, nil
 }
+
+func (r DraftRepository) EmptyTrash(user *User) error {
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+	SELECT json_object('ids', json_group_array("id"))
+		FROM "Draft"
+		WHERE "userId" = $1 AND
+		"lastStmt" = 2;`
+
+	args := []interface{}{user.Id}
+
+	var ids string
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&ids)
+	if err != nil {
+		return err
+	}
+
+	query = `
+	DELETE
+		FROM "Draft"
+		WHERE "userId" = $1 AND
+		"id" IN (` + jsonIds("$2") + `);`
+
+	args = []interface{}{user.Id, ids}
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	query = `
+	UPDATE "DraftDeleted"
+		SET "deviceId" = $1
+		WHERE "userId" = $2 AND
+		"id" IN (` + jsonIds("$3") + `);`
+
+	args = []interface{}{user.DeviceId, user.Id, ids}
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"cargomail/internal/shared/config"
+	"errors"
+	"net/mail"
+	"testing"
+	"time"
+)
+
+func TestDiscardDraft(t *testing.T) {
+	repository, _ := newTestRepository(t)
+
+	alice := newTestUser(t, repository, "alice")
+
+	draft, err := repository.Drafts.Create(alice, &Draft{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = repository.Drafts.Discard(alice, draft.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = repository.Drafts.GetById(alice, draft.Id)
+	if !errors.Is(err, ErrDraftNotFound) {
+		t.Errorf("got %v after the discard, want %v", err, ErrDraftNotFound)
+	}
+
+	// the draft is not in the trash, the other devices sync its deletion
+	trashed, err := repository.Drafts.ListTrashed(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(trashed.Drafts) != 0 {
+		t.Errorf("got %d trashed drafts, want 0", len(trashed.Drafts))
+	}
+
+	sync, err := repository.Drafts.Sync(alice, &History{IgnoreDevice: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sync.DraftsDeleted) != 1 || sync.DraftsDeleted[0].Id != draft.Id {
+		t.Errorf("got the deleted drafts %v, want %s", sync.DraftsDeleted, draft.Id)
+	}
+
+	sync, err = repository.Drafts.Sync(alice, &History{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sync.DraftsDeleted) != 0 {
+		t.Errorf("got %d deleted drafts on the discarding device, want 0", len(sync.DraftsDeleted))
+	}
+}
+
+func TestDiscardDraftNotFound(t *testing.T) {
+	repository, _ := newTestRepository(t)
+
+	alice := newTestUser(t, repository, "alice")
+	bob := newTestUser(t, repository, "bob")
+
+	trashed, err := repository.Drafts.Create(alice, &Draft{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = repository.Drafts.Trash(alice, `{"ids":["`+trashed.Id+`"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := repository.Drafts.Create(bob, &Draft{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the trashed drafts are deleted by the Delete, the drafts of the other users are not seen
+	for _, id := range []string{trashed.Id, other.Id, "00000000000000000000000000000000"} {
+		err = repository.Drafts.Discard(alice, id)
+		if !errors.Is(err, ErrDraftNotFound) {
+			t.Errorf("got %v for the draft %s, want %v", err, id, ErrDraftNotFound)
+		}
+	}
+
+	_, err = repository.Drafts.GetById(bob, other.Id)
+	if err != nil {
+		t.Errorf("the draft of the other user discarded: %v", err)
+	}
+}
+
+func TestSubmitDate(t *testing.T) {
+	repository, _ := newTestRepository(t)
+
+	alice := newTestUser(t, repository, "alice")
+	newTestUser(t, repository, "bob")
+
+	domainName := config.Configuration.DomainName
+	config.Configuration.DomainName = "example.org"
+	t.Cleanup(func() { config.Configuration.DomainName = domainName })
+
+	relayedAt := time.Date(2024, 1, 2, 11, 0, 0, 0, time.FixedZone("CET", 3600))
+
+	for _, relayed := range []bool{false, true} {
+		draft, err := repository.Drafts.Create(alice, &Draft{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		draft.Payload = &MessagePart{Headers: map[string]interface{}{
+			"From": "Alice <alice@example.org>",
+			"To":   "Bob <bob@example.org>",
+		}}
+
+		if relayed {
+			draft.SetDate(relayedAt)
+		}
+
+		message, err := repository.Drafts.Submit(alice, draft)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		value, _ := message.Payload.Headers["Date"].(string)
+
+		date, err := mail.ParseDate(value)
+		if err != nil {
+			t.Errorf("got the Date %q: %v", value, err)
+			continue
+		}
+
+		// the relayed message and the stored one are of the same Date
+		if relayed && !date.Equal(relayedAt) {
+			t.Errorf("got the Date %q, want the relayed %q", value, relayedAt.Format(time.RFC1123Z))
+		}
+
+		if !relayed && time.Since(date) > time.Minute {
+			t.Errorf("got the Date %q, want the current time", value)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+type UseEventRepository interface {
+	Pending(limit int) ([]*Event, error)
+	MarkSent(lastId int64) error
+	Purge(retention time.Duration) (int64, error)
+	LastHistoryId(user *User, resource string) (int64, error)
+	LastHistoryIds(user *User) (map[string]int64, error)
+}
+
+type EventRepository struct {
+	db       *sql.DB
+	replica  *sql.DB // the LastHistoryId, nil = the db
+	timeouts Timeouts
+}
+
+// Event is a change notification of the outbox, written by the triggers in the transaction of the change.
+type Event struct {
+	Id         int64      `json:"id"`
+	UserId     int64      `json:"-"`
+	Resource   string     `json:"resource"`
+	ResourceId string     `json:"resourceId"`
+	HistoryId  int64      `json:"historyId"`
+	Type       string     `json:"type"`
+	CreatedAt  Timestamp  `json:"createdAt"`
+	SentAt     *Timestamp `json:"-"`
+}
+
+func (e *Event) Scan() []interface{} {
+	return scanColumns(e)
+}
+
+// Pending returns the events not dispatched yet, in the order they were written.
+func (r *EventRepository) Pending(limit int) ([]*Event, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	query := `
+		SELECT *
+			FROM "Event"
+			WHERE "sentAt" IS NULL
+			ORDER BY "id"
+			LIMIT $1;`
+
+	rows, err := r.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*Event{}
+
+	for rows.Next() {
+		var event Event
+
+		err := rows.Scan(event.Scan()...)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, &event)
+	}
+
+	return events, rows.Err()
+}
+
+// MarkSent marks the pending events up to the lastId of the dispatched batch as dispatched. The ids are
+// never reused and the writes are serialized, so an event committed after the batch was read has a greater
+// id and is left pending.
+func (r *EventRepository) MarkSent(lastId int64) error {
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	query := `
+		UPDATE "Event"
+			SET "sentAt" = CURRENT_TIMESTAMP
+			WHERE "sentAt" IS NULL AND
+			"id" <= $1;`
+
+	_, err := r.db.ExecContext(ctx, query, lastId)
+
+	return err
+}
+
+// Purge deletes the events dispatched longer than the retention ago.
+func (r *EventRepository) Purge(retention time.Duration) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	modifier := fmt.Sprintf("-%d seconds", int64(retention.Seconds()))
+
+	query := `
+		DELETE
+			FROM "Event"
+			WHERE "sentAt" IS NOT NULL AND
+			"sentAt" <= datetime('now', $1);`
+
+	result, err := r.db.ExecContext(ctx, query, modifier)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// LastHistoryId returns the current history id of the resource of the user, it changes with any change
+// of the resource. It is read where the List of the resource reads, so the ETag of the list doesn't tag a
+// list of the lagging replica by the history of the primary.
+func (r *EventRepository) LastHistoryId(user *User, resource string) (int64, error) {
+	table, ok := tombstoneTables[resource]
+	if !ok {
+		return 0, ErrUnknownResource
+	}
+
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	query := `
+		SELECT "lastHistoryId"
+			FROM "` + table + `HistorySeq"
+			WHERE "userId" = $1;`
+
+	var historyId int64
+
+	err := reader(r.db, r.replica, user).QueryRowContext(ctx, query, user.Id).Scan(&historyId)
+	if err != nil {
+		return 0, err
+	}
+
+	return historyId, nil
+}
+
+// LastHistoryIds returns the current history id of each resource of the user, e.g. for the event stream
+// to tell the resources changed since the client synced.
+func (r *EventRepository) LastHistoryIds(user *User) (map[string]int64, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	resources := make([]string, 0, len(tombstoneTables))
+	for resource := range tombstoneTables {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+
+	selects := make([]string, len(resources))
+	for i, resource := range resources {
+		selects[i] = `
+		SELECT '` + resource + `', "lastHistoryId"
+			FROM "` + tombstoneTables[resource] + `HistorySeq"
+			WHERE "userId" = $1`
+	}
+
+	rows, err := r.db.QueryContext(ctx, strings.Join(selects, `
+		UNION ALL`)+";", user.Id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	historyIds := make(map[string]int64, len(resources))
+
+	for rows.Next() {
+		var resource string
+		var historyId int64
+
+		err := rows.Scan(&resource, &historyId)
+		if err != nil {
+			return nil, err
+		}
+
+		historyIds[resource] = historyId
+	}
+
+	return historyIds, rows.Err()
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLastHistoryIdOfReplica(t *testing.T) {
+	primary, db := newTestRepository(t)
+	_, replica := newTestRepository(t)
+
+	alice := newTestUser(t, primary, "alice")
+
+	// the replica lags behind the label created on the primary
+	_, err := replica.Exec(`INSERT INTO "User" ("id", "username", "passwordHash") VALUES ($1, $2, '-');`, alice.Id, alice.Username)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = primary.Labels.Create(alice, &Label{Name: "Work"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repository := newRepository(db, replica, Timeouts{Read: 5 * time.Second, Write: 5 * time.Second})
+
+	historyId, err := repository.Events.LastHistoryId(alice, "labels")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	labelList, err := repository.Labels.List(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if historyId != labelList.History {
+		t.Errorf("got the history id %d of the list of history %d", historyId, labelList.History)
+	}
+
+	alice.ReadPrimary = true
+
+	current, err := repository.Events.LastHistoryId(alice, "labels")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if current <= historyId {
+		t.Errorf("got the history id %d of the primary, want it after %d", current, historyId)
+	}
+}
@@ -0,0 +1,438 @@
+package repository
+
+import (
+	"cargomail/internal/shared/config"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"database/sql"
+	b64 "encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+type UseFederationRepository interface {
+	Deliver(envelope *FederationEnvelope) (*FederationReceipt, error)
+	Due(limit int) ([]*FederationDelivery, error)
+	Retry(id int64, lastError string, delay time.Duration) error
+	Done(id int64) error
+	InstanceKey() (ed25519.PrivateKey, error)
+	PeerKey(domain string) (ed25519.PublicKey, error)
+	SetPeerKey(domain string, key string) error
+	DeletePeerKey(domain string) error
+}
+
+// FederationRepository keeps the sent messages to be delivered to the peer instances, and delivers the
+// messages of the peers to the inboxes of the users.
+type FederationRepository struct {
+	db       *sql.DB
+	timeouts Timeouts
+}
+
+const maxFederatedRecipients = 100
+
+// FederationEnvelope is the wire format of the server-to-server delivery, the JSON the sending instance
+// POSTs to the /api/v1/federation/deliver of the instance of the recipients:
+//
+//	{
+//	  "sender": "alice@example.org",
+//	  "recipients": ["bob@example.net"],
+//	  "payload": {"headers": {"From": "Alice <alice@example.org>", "To": "Bob <bob@example.net>", ...}, "parts": [...]}
+//	}
+//
+// The payload is the MessagePart of the sent Message but the Bcc header, its placeholder parts resolved to
+// their content in base64, the receiving instance can't load the blobs and the files. The recipients are the
+// ones of the domain of the receiving instance, the sender is the address of the From header. The Message-ID
+// header is required, a message delivered again, e.g. after a timed out attempt, is delivered once.
+type FederationEnvelope struct {
+	Sender     string       `json:"sender"`
+	Recipients []string     `json:"recipients"`
+	Payload    *MessagePart `json:"payload"`
+}
+
+// FederationReceipt is the response of the delivery, the recipients not found are not retried.
+type FederationReceipt struct {
+	Delivered []string `json:"delivered"`
+	NotFound  []string `json:"notFound,omitempty"`
+}
+
+// FederationDelivery is a message of the user to be delivered to the instance of the domain. The envelope
+// is the one of the submission, its placeholder parts are resolved by the storage when it is sent.
+type FederationDelivery struct {
+	Id       int64
+	UserId   int64
+	Domain   string
+	Attempts int
+	Envelope *FederationEnvelope
+}
+
+// federatedPayload returns the payload the peers get, the Bcc recipients are not disclosed to them.
+func federatedPayload(payload *MessagePart) *MessagePart {
+	headers := make(map[string]interface{}, len(payload.Headers))
+
+	for k, v := range payload.Headers {
+		if !strings.EqualFold(k, "Bcc") {
+			headers[k] = v
+		}
+	}
+
+	return &MessagePart{Headers: headers, Body: payload.Body, Parts: payload.Parts}
+}
+
+// enqueueFederated schedules the delivery of the submitted message to the recipients of the peer domains,
+// one a domain, in the transaction of the submission.
+func enqueueFederated(ctx context.Context, tx *sql.Tx, user *User, payload *MessagePart, recipients map[string][]string) error {
+	sender := user.Username + "@" + config.Configuration.DomainName
+
+	for domain, domainRecipients := range recipients {
+		envelope, err := json.Marshal(&FederationEnvelope{
+			Sender:     sender,
+			Recipients: domainRecipients,
+			Payload:    federatedPayload(payload),
+		})
+		if err != nil {
+			return err
+		}
+
+		query := `
+			INSERT
+				INTO "FederationDelivery" ("userId", "domain", "envelope")
+				VALUES ($1, $2, $3);`
+
+		_, err = tx.ExecContext(ctx, query, user.Id, domain, string(envelope))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Deliver puts the message of the peer instance to the inboxes of the recipients. The sender has to be
+// of the domain of a peer and to be the From of the message, the recipients of this domain.
+func (r *FederationRepository) Deliver(envelope *FederationEnvelope) (*FederationReceipt, error) {
+	if envelope.Payload == nil {
+		return nil, ErrMissingPayloadField
+	}
+
+	if envelope.Payload.Headers == nil {
+		return nil, ErrMissingHeadersField
+	}
+
+	sender, err := mail.ParseAddress(envelope.Sender)
+	if err != nil {
+		return nil, ErrInvalidSender
+	}
+
+	_, senderDomain, _ := strings.Cut(sender.Address, "@")
+	if strings.EqualFold(senderDomain, config.Configuration.DomainName) {
+		return nil, ErrInvalidSender
+	}
+
+	if _, ok := config.FederationPeer(senderDomain); !ok {
+		return nil, ErrUnknownPeer
+	}
+
+	from, _ := envelope.Payload.Headers["From"].(string)
+
+	fromAddress, err := mail.ParseAddress(from)
+	if err != nil || !strings.EqualFold(fromAddress.Address, sender.Address) {
+		return nil, ErrInvalidSender
+	}
+
+	messageId, _ := envelope.Payload.Headers["Message-ID"].(string)
+	if len(messageId) == 0 {
+		return nil, ErrMissingMessageId
+	}
+
+	if len(envelope.Recipients) == 0 {
+		return nil, ErrMissingRecipients
+	}
+
+	if len(envelope.Recipients) > maxFederatedRecipients {
+		return nil, ErrInvalidRecipients
+	}
+
+	var usernames []string
+
+	for _, recipient := range envelope.Recipients {
+		address, err := mail.ParseAddress(recipient)
+		if err != nil {
+			return nil, ErrInvalidRecipients
+		}
+
+		username, domain, _ := strings.Cut(address.Address, "@")
+		if !strings.EqualFold(domain, config.Configuration.DomainName) {
+			return nil, ErrInvalidRecipients
+		}
+
+		usernames = append(usernames, username)
+	}
+
+	payload := federatedPayload(envelope.Payload)
+
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	receipt := &FederationReceipt{Delivered: []string{}}
+
+	for i, username := range usernames {
+		var userId int64
+
+		query := `
+			SELECT "id"
+				FROM "User"
+				WHERE "username" = $1;`
+
+		err := tx.QueryRowContext(ctx, query, username).Scan(&userId)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				receipt.NotFound = append(receipt.NotFound, envelope.Recipients[i])
+				continue
+			}
+			return nil, err
+		}
+
+		// the message delivered again, e.g. after the sender timed out, is kept once
+		query = `
+			INSERT
+				INTO "Message" ("userId",
+					"deviceId",
+					"unread",
+					"folder",
+					"payload",
+					"receivedAt")
+				SELECT $1, NULL, TRUE, 2, $2, CURRENT_TIMESTAMP
+					WHERE NOT EXISTS (SELECT 1
+						FROM "Message"
+						WHERE "userId" = $1 AND
+						"folder" = 2 AND
+						"payload"->>'$.headers."Message-ID"' = $3);`
+
+		_, err = tx.ExecContext(ctx, query, userId, payload, messageId)
+		if err != nil {
+			return nil, err
+		}
+
+		receipt.Delivered = append(receipt.Delivered, envelope.Recipients[i])
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	if len(receipt.Delivered) == 0 {
+		return receipt, &RecipientsNotFoundError{
+			Recipients: receipt.NotFound,
+			Err:        ErrRecipientNotFound,
+		}
+	}
+
+	return receipt, nil
+}
+
+// Due returns the deliveries to be attempted now, the oldest first.
+func (r *FederationRepository) Due(limit int) ([]*FederationDelivery, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	query := `
+		SELECT "id", "userId", "domain", "attempts", "envelope"
+			FROM "FederationDelivery"
+			WHERE "nextAttemptAt" <= CURRENT_TIMESTAMP
+			ORDER BY "id"
+			LIMIT $1;`
+
+	rows, err := r.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []*FederationDelivery{}
+
+	for rows.Next() {
+		delivery := &FederationDelivery{}
+
+		var envelope string
+
+		err := rows.Scan(&delivery.Id, &delivery.UserId, &delivery.Domain, &delivery.Attempts, &envelope)
+		if err != nil {
+			return nil, err
+		}
+
+		err = json.Unmarshal([]byte(envelope), &delivery.Envelope)
+		if err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// Retry counts the failed attempt and postpones the next one by the delay.
+func (r *FederationRepository) Retry(id int64, lastError string, delay time.Duration) error {
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	nextAttemptAt := time.Now().Add(delay)
+
+	query := `
+		UPDATE "FederationDelivery"
+			SET "attempts" = "attempts" + 1,
+			"lastError" = $1,
+			"nextAttemptAt" = $2
+			WHERE "id" = $3;`
+
+	_, err := r.db.ExecContext(ctx, query, lastError, sqliteTimestamp(&nextAttemptAt), id)
+
+	return err
+}
+
+// Done removes the delivery, either delivered or given up on.
+func (r *FederationRepository) Done(id int64) error {
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	query := `
+		DELETE
+			FROM "FederationDelivery"
+			WHERE "id" = $1;`
+
+	_, err := r.db.ExecContext(ctx, query, id)
+
+	return err
+}
+
+// InstanceKey returns the key the instance signs the federated messages with, it is created on the first call.
+func (r *FederationRepository) InstanceKey() (ed25519.PrivateKey, error) {
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	// the key of a concurrent first call wins as well
+	query := `
+		INSERT OR IGNORE
+			INTO "FederationInstanceKey" ("id", "privateKey")
+			VALUES (1, $1);`
+
+	_, err = r.db.ExecContext(ctx, query, b64.StdEncoding.EncodeToString(key.Seed()))
+	if err != nil {
+		return nil, err
+	}
+
+	query = `
+		SELECT "privateKey"
+			FROM "FederationInstanceKey"
+			WHERE "id" = 1;`
+
+	var seed string
+
+	err = r.db.QueryRowContext(ctx, query).Scan(&seed)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := b64.StdEncoding.DecodeString(seed)
+	if err != nil || len(raw) != ed25519.SeedSize {
+		return nil, errors.New("corrupt federation instance key")
+	}
+
+	return ed25519.NewKeyFromSeed(raw), nil
+}
+
+// PeerKey returns the public key the messages of the peer of the domain are verified by.
+func (r *FederationRepository) PeerKey(domain string) (ed25519.PublicKey, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	query := `
+		SELECT "publicKey"
+			FROM "FederationPeerKey"
+			WHERE "domain" = $1;`
+
+	var key string
+
+	err := r.db.QueryRowContext(ctx, query, strings.ToLower(domain)).Scan(&key)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrPeerKeyNotFound
+		}
+		return nil, err
+	}
+
+	return parsePeerKey(key)
+}
+
+func parsePeerKey(key string) (ed25519.PublicKey, error) {
+	raw, err := b64.StdEncoding.DecodeString(key)
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return nil, ErrInvalidPeerKey
+	}
+
+	return ed25519.PublicKey(raw), nil
+}
+
+// SetPeerKey sets the base64 public key of the peer of the domain, replacing the previous one.
+func (r *FederationRepository) SetPeerKey(domain string, key string) error {
+	_, err := parsePeerKey(key)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	query := `
+		INSERT
+			INTO "FederationPeerKey" ("domain", "publicKey")
+			VALUES ($1, $2)
+			ON CONFLICT ("domain") DO UPDATE
+				SET "publicKey" = excluded."publicKey",
+				"modifiedAt" = CURRENT_TIMESTAMP;`
+
+	_, err = r.db.ExecContext(ctx, query, strings.ToLower(domain), key)
+
+	return err
+}
+
+func (r *FederationRepository) DeletePeerKey(domain string) error {
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	query := `
+		DELETE
+			FROM "FederationPeerKey"
+			WHERE "domain" = $1;`
+
+	result, err := r.db.ExecContext(ctx, query, strings.ToLower(domain))
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrPeerKeyNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,202 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// FileTags are the tags of the files of the uri, the tags are per user and case-insensitive, the first
+// spelling of a tag is kept. The files of an equal content share the uri, and so the tags.
+type FileTags struct {
+	Uri  string  `json:"uri"`
+	Tags TagList `json:"tags"`
+}
+
+func (t *FileTags) Scan() []interface{} {
+	return scanColumns(t)
+}
+
+func fileTag(tag string) (string, error) {
+	tag, err := blobTag(tag)
+	if errors.Is(err, ErrBlobWrongTag) {
+		return "", ErrFileWrongTag
+	}
+
+	return tag, err
+}
+
+func getFileTags(ctx context.Context, tx *sql.Tx, user *User, uri string) (*FileTags, error) {
+	query := `
+		SELECT "digest",
+			(SELECT json_group_array("tag")
+				FROM (SELECT DISTINCT "FileTag"."tag"
+					FROM "FileTag"
+					INNER JOIN "File" AS "Tagged" ON "Tagged"."id" = "FileTag"."fileId"
+					WHERE "Tagged"."userId" = $1 AND
+					"Tagged"."digest" = $2 AND
+					"Tagged"."lastStmt" < 2
+					ORDER BY "FileTag"."tag"))
+			FROM "File"
+			WHERE "userId" = $1 AND
+			"digest" = $2 AND
+			"lastStmt" < 2
+			LIMIT 1;`
+
+	tags := &FileTags{}
+
+	err := tx.QueryRowContext(ctx, query, user.Id, uri).Scan(tags.Scan()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrFileNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return tags, nil
+}
+
+// AddTag tags the files of the uri, the tag a file has already is skipped.
+func (r *FileRepository) AddTag(user *User, uri string, tag string) (*FileTags, error) {
+	query := `
+		INSERT OR IGNORE
+			INTO "FileTag" ("fileId", "userId", "tag")
+			SELECT "id", "userId", $1
+				FROM "File"
+				WHERE "digest" = $2 AND
+				"userId" = $3 AND
+				"lastStmt" < 2;`
+
+	return r.modifyTags(user, uri, tag, query)
+}
+
+func (r *FileRepository) RemoveTag(user *User, uri string, tag string) (*FileTags, error) {
+	query := `
+		DELETE
+			FROM "FileTag"
+			WHERE "tag" = $1 AND
+			"fileId" IN (SELECT "id" FROM "File" WHERE "digest" = $2 AND "userId" = $3) AND
+			"userId" = $3;`
+
+	return r.modifyTags(user, uri, tag, query)
+}
+
+// modifyTags runs the tag statement and attributes the change of the files to the device of the user, so
+// the change is not synced back to it.
+func (r *FileRepository) modifyTags(user *User, uri string, tag string, stmt string) (*FileTags, error) {
+	tag, err := fileTag(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// the trashed files can't be tagged
+	_, err = getFileTags(ctx, tx, user, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	// the $N parameters are bound in the order of their first appearance in the statement
+	result, err := tx.ExecContext(ctx, stmt, tag, uri, user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if affected > 0 {
+		query := `
+			UPDATE "File"
+				SET "deviceId" = $1
+				WHERE "userId" = $2 AND
+				"digest" = $3 AND
+				"lastStmt" < 2;`
+
+		_, err = tx.ExecContext(ctx, query, user.DeviceId, user.Id, uri)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tags, err := getFileTags(ctx, tx, user, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// ListTags lists the tags of the tagged files by the uri, the trashed ones aside.
+func (r *FileRepository) ListTags(user *User) ([]*FileTags, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	query := `
+		SELECT "digest", json_group_array("tag")
+			FROM (SELECT DISTINCT "File"."digest", "FileTag"."tag"
+				FROM "FileTag"
+				INNER JOIN "File" ON "File"."id" = "FileTag"."fileId"
+				WHERE "FileTag"."userId" = $1 AND
+				"File"."lastStmt" < 2
+				ORDER BY "File"."digest", "FileTag"."tag")
+			GROUP BY "digest";`
+
+	rows, err := reader(r.db, r.replica, user).QueryContext(ctx, query, user.Id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tagList := []*FileTags{}
+
+	for rows.Next() {
+		tags := &FileTags{}
+
+		err := rows.Scan(tags.Scan()...)
+		if err != nil {
+			return nil, err
+		}
+
+		tagList = append(tagList, tags)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tagList, nil
+}
+
+// ListByTag lists the files of the tag like the List does, the tags of the options are intersected.
+func (r *FileRepository) ListByTag(user *User, folder int, tag string, options *ListOptions) (*FileList, error) {
+	tag, err := fileTag(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	tagged := ListOptions{}
+	if options != nil {
+		tagged = *options
+	}
+
+	tagged.Tags = append([]string{tag}, tagged.Tags...)
+
+	return r.List(user, folder, &tagged)
+}
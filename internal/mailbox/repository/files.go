@@ -6,29 +6,40 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
-	"reflect"
-	"time"
+	"strings"
 )
 
 type UseFileRepository interface {
 	Create(user *User, file *File) (*File, error)
-	List(user *User, folder int) (*FileList, error)
+	List(user *User, folder int, options *ListOptions) (*FileList, error)
+	ListEach(user *User, folder int, options *ListOptions, each func(*File) error) (*FileList, error)
+	ListTrashed(user *User) (*FileList, error)
+	Search(user *User, q string, options *ListOptions) ([]*File, error)
 	Sync(user *User, history *History) (*FileSync, error)
+	Update(user *User, file *File) (*File, error)
 	Trash(user *User, ids string) error
 	Untrash(user *User, ids string) error
 	Delete(user *User, ids string) ([]*File, error)
 	GetById(user *User, id string) (*File, error)
 	GetByDigest(user *User, digest string) (*File, error)
+	UsageBytes(user *User) (*Usage, error)
+	AddTag(user *User, uri string, tag string) (*FileTags, error)
+	RemoveTag(user *User, uri string, tag string) (*FileTags, error)
+	ListTags(user *User) ([]*FileTags, error)
+	ListByTag(user *User, folder int, tag string, options *ListOptions) (*FileList, error)
 }
 
 type FileRepository struct {
-	db *sql.DB
+	db       *sql.DB
+	replica  *sql.DB // the List, Sync, Count and Search, nil = the db
+	timeouts Timeouts
 }
 
 type FileMetadata struct {
 	Salt string `json:"salt"`
 	Key  string `json:"key,omitempty"`
 	Iv   string `json:"iv,omitempty"`
+	Hash string `json:"hash,omitempty"` // of the plain content, shared by the equal uploads
 }
 
 type File struct {
@@ -49,6 +60,12 @@ type File struct {
 	DeviceId    *string       `json:"-"`
 }
 
+var fileSortExprs = map[string]string{
+	"createdAt":  `"createdAt"`,
+	"modifiedAt": `coalesce("modifiedAt", "createdAt")`,
+	"name":       `"name" COLLATE NOCASE`,
+}
+
 type FileDeleted struct {
 	Id        string  `json:"id"`
 	UserId    int64   `json:"-"`
@@ -63,7 +80,9 @@ type FileList struct {
 
 type FileSync struct {
 	History       int64          `json:"lastHistoryId"`
+	NextPollAfter int            `json:"nextPollAfter"`
 	FilesInserted []*File        `json:"inserted"`
+	FilesUpdated  []*File        `json:"updated"`
 	FilesTrashed  []*File        `json:"trashed"`
 	FilesDeleted  []*FileDeleted `json:"deleted"`
 }
@@ -82,31 +101,36 @@ func (v *FileMetadata) Scan(value interface{}) error {
 }
 
 func (f *File) Scan() []interface{} {
-	s := reflect.ValueOf(f).Elem()
-	numCols := s.NumField()
-	columns := make([]interface{}, numCols)
-	for i := 0; i < numCols; i++ {
-		field := s.Field(i)
-		columns[i] = field.Addr().Interface()
-	}
-	return columns
+	return scanColumns(f)
 }
 
 func (f *FileDeleted) Scan() []interface{} {
-	s := reflect.ValueOf(f).Elem()
-	numCols := s.NumField()
-	columns := make([]interface{}, numCols)
-	for i := 0; i < numCols; i++ {
-		field := s.Field(i)
-		columns[i] = field.Addr().Interface()
-	}
-	return columns
+	return scanColumns(f)
 }
 
 func (r FileRepository) Create(user *User, file *File) (*File, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := r.timeouts.write()
 	defer cancel()
 
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	err = checkQuota(ctx, tx, user.Id, file.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	// an equal content stored already is shared
+	if file.Metadata != nil {
+		file.Digest, err = acquireContent(ctx, tx, FilesResource, file.Metadata.Hash, file.Digest, file.Metadata)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	query := `
 		INSERT INTO
 			"File" ("userId", "deviceId", "folder", "digest", "name", "path", "contentType", "size", "metadata")
@@ -119,34 +143,165 @@ func (r FileRepository) Create(user *User, file *File) (*File, error) {
 
 	args := []interface{}{user.Id, prefixedDeviceId, folder, file.Digest, file.Name, file.Path, file.ContentType, file.Size, file.Metadata}
 
-	err := r.db.QueryRowContext(ctx, query, args...).Scan(file.Scan()...)
+	err = tx.QueryRowContext(ctx, query, args...).Scan(file.Scan()...)
 	if err != nil {
 		return nil, err
 	}
 
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
 	return file, nil
 }
 
-func (r FileRepository) List(user *User, folder int) (*FileList, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+// UsageBytes returns the number and the size of the files, the live and the trashed ones apart.
+func (r FileRepository) UsageBytes(user *User) (*Usage, error) {
+	ctx, cancel := r.timeouts.read()
 	defer cancel()
 
-	tx, err := r.db.BeginTx(ctx, nil)
+	query := `
+		SELECT COUNT(*) FILTER (WHERE "lastStmt" < 2),
+			COUNT(*) FILTER (WHERE "lastStmt" = 2),
+			coalesce(sum("size") FILTER (WHERE "lastStmt" < 2), 0),
+			coalesce(sum("size") FILTER (WHERE "lastStmt" = 2), 0)
+			FROM "File"
+			WHERE "userId" = $1;`
+
+	args := []interface{}{user.Id}
+
+	usage := &Usage{}
+
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(&usage.Count, &usage.Trashed, &usage.Bytes, &usage.TrashedBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return usage, nil
+}
+
+func (r FileRepository) List(user *User, folder int, options *ListOptions) (*FileList, error) {
+	files := []*File{}
+
+	fileList, err := r.ListEach(user, folder, options, func(file *File) error {
+		files = append(files, file)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	fileList.Files = files
+
+	return fileList, nil
+}
+
+// ListEach lists the files like the List does, but passes each one to the each as it is read instead of
+// collecting them, the returned list holds the history only.
+func (r FileRepository) ListEach(user *User, folder int, options *ListOptions, each func(*File) error) (*FileList, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	tx, err := reader(r.db, r.replica, user).BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
 
+	if options == nil {
+		options = &ListOptions{}
+	}
+
+	orderBy, err := options.orderBy(fileSortExprs, "createdAt", "desc")
+	if err != nil {
+		return nil, err
+	}
+
+	q := newListQuery(user.Id, folder)
+
+	err = options.contentType(q)
+	if err != nil {
+		return nil, err
+	}
+
+	options.tags(q, "File")
+
 	// files
 	query := `
 		SELECT *
 			FROM "File"
 			WHERE "userId" = $1 AND
-			CASE WHEN $2 == -1 THEN "folder" > $2 ELSE "folder" == $2 END AND			
-			"lastStmt" < 2
-			ORDER BY "createdAt" DESC;`
+			CASE WHEN $2 == -1 THEN "folder" > $2 ELSE "folder" == $2 END AND
+			"lastStmt" < 2` + q.whereClause() + orderBy + `;`
+
+	rows, err := tx.QueryContext(ctx, query, q.args...)
+	if err != nil {
+		return nil, err
+	}
 
-	args := []interface{}{user.Id, folder}
+	defer rows.Close()
+
+	fileList := &FileList{}
+
+	for rows.Next() {
+		var file File
+
+		err := rows.Scan(file.Scan()...)
+		if err != nil {
+			return nil, err
+		}
+
+		err = each(&file)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// history
+	query = `
+		SELECT lastHistoryId
+		   FROM fileHistorySeq
+		   WHERE userId = $1 ;`
+
+	args := []interface{}{user.Id}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&fileList.History)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return fileList, nil
+}
+
+// Search matches the terms of the query against the name of the files. Only the sort and the content
+// type of the options apply.
+// ListTrashed lists the trashed files, the recently trashed first.
+func (r FileRepository) ListTrashed(user *User) (*FileList, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	tx, err := reader(r.db, r.replica, user).BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT *
+			FROM "File"
+			WHERE "userId" = $1 AND
+			"lastStmt" = 2
+			ORDER BY coalesce("modifiedAt", "createdAt") DESC, "rowid" DESC;`
+
+	args := []interface{}{user.Id}
 
 	rows, err := tx.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -176,11 +331,9 @@ func (r FileRepository) List(user *User, folder int) (*FileList, error) {
 
 	// history
 	query = `
-		SELECT lastHistoryId
-		   FROM fileHistorySeq
-		   WHERE userId = $1 ;`
-
-	args = []interface{}{user.Id}
+		SELECT "lastHistoryId"
+			FROM "FileHistorySeq"
+			WHERE "userId" = $1 ;`
 
 	err = tx.QueryRowContext(ctx, query, args...).Scan(&fileList.History)
 	if err != nil {
@@ -194,11 +347,79 @@ func (r FileRepository) List(user *User, folder int) (*FileList, error) {
 	return fileList, nil
 }
 
+func (r FileRepository) Search(user *User, q string, options *ListOptions) ([]*File, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	if options == nil {
+		options = &ListOptions{}
+	}
+
+	orderBy, err := options.orderBy(fileSortExprs, "createdAt", "desc")
+	if err != nil {
+		return nil, err
+	}
+
+	lq := newListQuery(user.Id)
+
+	if !likeTerms(lq, q, `"name"`) {
+		return nil, ErrMissingSearchQuery
+	}
+
+	err = options.contentType(lq)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT *
+			FROM "File"
+			WHERE "userId" = $1 AND
+			"lastStmt" < 2` + lq.whereClause() + orderBy + `;`
+
+	rows, err := reader(r.db, r.replica, user).QueryContext(ctx, query, lq.args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	files := []*File{}
+
+	for rows.Next() {
+		var file File
+
+		err := rows.Scan(file.Scan()...)
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, &file)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
 func (r *FileRepository) Sync(user *User, history *History) (*FileSync, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := r.timeouts.read()
 	defer cancel()
 
-	tx, err := r.db.BeginTx(ctx, nil)
+	var sync *FileSync
+
+	err := retryBusy(ctx, func() (err error) {
+		sync, err = r.trySync(ctx, user, history)
+		return err
+	})
+
+	return sync, err
+}
+
+func (r *FileRepository) trySync(ctx context.Context, user *User, history *History) (*FileSync, error) {
+	tx, err := reader(r.db, r.replica, user).BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -217,10 +438,11 @@ func (r *FileRepository) Sync(user *User, history *History) (*FileSync, error) {
 			WHERE "userId" = $1 AND
 				("deviceId" <> $2 OR "deviceId" IS NULL) AND
 				"lastStmt" = 0 AND
-				"historyId" > $3
+				"historyId" > $3 AND
+				coalesce("modifiedAt", "createdAt") >= $4
 			ORDER BY "createdAt" DESC;`
 
-	args := []interface{}{user.Id, deviceId, history.Id}
+	args := []interface{}{user.Id, deviceId, history.Id, history.since()}
 
 	rows, err := tx.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -231,6 +453,7 @@ func (r *FileRepository) Sync(user *User, history *History) (*FileSync, error) {
 
 	fileSync := &FileSync{
 		FilesInserted: []*File{},
+		FilesUpdated:  []*File{},
 		FilesTrashed:  []*File{},
 		FilesDeleted:  []*FileDeleted{},
 	}
@@ -251,6 +474,42 @@ func (r *FileRepository) Sync(user *User, history *History) (*FileSync, error) {
 		return nil, err
 	}
 
+	// updated rows
+	query = `
+		SELECT *
+			FROM "File"
+			WHERE "userId" = $1 AND
+				"lastStmt" = 1 AND
+				("deviceId" <> $2 OR "deviceId" IS NULL) AND
+				"historyId" > $3 AND
+				coalesce("modifiedAt", "createdAt") >= $4
+			ORDER BY "createdAt" DESC;`
+
+	args = []interface{}{user.Id, deviceId, history.Id, history.since()}
+
+	rows, err = tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var file File
+
+		err := rows.Scan(file.Scan()...)
+
+		if err != nil {
+			return nil, err
+		}
+
+		fileSync.FilesUpdated = append(fileSync.FilesUpdated, &file)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
 	// trashed rows
 	query = `
 		SELECT *
@@ -258,10 +517,11 @@ func (r *FileRepository) Sync(user *User, history *History) (*FileSync, error) {
 			WHERE "userId" = $1 AND
 			("deviceId" <> $2 OR "deviceId" IS NULL) AND
 			"lastStmt" = 2 AND
-				"historyId" > $3
+				"historyId" > $3 AND
+				coalesce("modifiedAt", "createdAt") >= $4
 			ORDER BY "createdAt" DESC;`
 
-	args = []interface{}{user.Id, deviceId, history.Id}
+	args = []interface{}{user.Id, deviceId, history.Id, history.since()}
 
 	rows, err = tx.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -332,6 +592,11 @@ func (r *FileRepository) Sync(user *User, history *History) (*FileSync, error) {
 		return nil, err
 	}
 
+	fileSync.NextPollAfter, err = nextPollAfter(ctx, tx, "File", user.Id)
+	if err != nil {
+		return nil, err
+	}
+
 	if err = tx.Commit(); err != nil {
 		return nil, err
 	}
@@ -339,17 +604,90 @@ func (r *FileRepository) Sync(user *User, history *History) (*FileSync, error) {
 	return fileSync, nil
 }
 
+// Update renames the file, the content of a file is not replaced.
+func (r *FileRepository) Update(user *User, file *File) (*File, error) {
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	file.Name = strings.TrimSpace(file.Name)
+	if len(file.Name) == 0 || len(file.Name) > 255 {
+		return nil, ErrFileWrongName
+	}
+
+	err := retryBusy(ctx, func() error {
+		return r.tryUpdate(ctx, user, file)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
+
+func (r *FileRepository) tryUpdate(ctx context.Context, user *User, file *File) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE "File"
+			SET "name" = $1,
+				"deviceId" = $2
+			WHERE "userId" = $3 AND
+				"id" = $4 AND
+				"lastStmt" <> 2
+			RETURNING id ;`
+
+	prefixedDeviceId := getPrefixedDeviceId(user.DeviceId)
+
+	args := []interface{}{file.Name, prefixedDeviceId, user.Id, file.Id}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&file.Id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrFileNotFound
+		default:
+			return err
+		}
+	}
+
+	// the history and the modification time are set by the trigger
+	query = `
+		SELECT *
+			FROM "File"
+			WHERE "userId" = $1 AND
+				"id" = $2;`
+
+	args = []interface{}{user.Id, file.Id}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(file.Scan()...)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
 func (r *FileRepository) Trash(user *User, ids string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := r.timeouts.write()
 	defer cancel()
 
+	return retryBusy(ctx, func() error {
+		return r.tryTrash(ctx, user, ids)
+	})
+}
+
+func (r *FileRepository) tryTrash(ctx context.Context, user *User, ids string) error {
 	if len(ids) > 0 {
 		query := `
 		UPDATE "File"
 			SET "lastStmt" = 2,
 				"deviceId" = $1
 			WHERE "userId" = $2 AND
-			"id" IN (SELECT value FROM json_each($3, '$.ids'));`
+			"id" IN (` + jsonIds("$3") + `);`
 
 		prefixedDeviceId := getPrefixedDeviceId(user.DeviceId)
 
@@ -365,16 +703,22 @@ func (r *FileRepository) Trash(user *User, ids string) error {
 }
 
 func (r *FileRepository) Untrash(user *User, ids string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := r.timeouts.write()
 	defer cancel()
 
+	return retryBusy(ctx, func() error {
+		return r.tryUntrash(ctx, user, ids)
+	})
+}
+
+func (r *FileRepository) tryUntrash(ctx context.Context, user *User, ids string) error {
 	if len(ids) > 0 {
 		query := `
 		UPDATE "File"
 			SET "lastStmt" = 0,
 				"deviceId" = $1
 			WHERE "userId" = $2 AND
-			"id" IN (SELECT value FROM json_each($3, '$.ids'));`
+			"id" IN (` + jsonIds("$3") + `);`
 
 		prefixedDeviceId := getPrefixedDeviceId(user.DeviceId)
 
@@ -390,9 +734,20 @@ func (r *FileRepository) Untrash(user *User, ids string) error {
 }
 
 func (r FileRepository) Delete(user *User, ids string) ([]*File, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := r.timeouts.write()
 	defer cancel()
 
+	var deleted []*File
+
+	err := retryBusy(ctx, func() (err error) {
+		deleted, err = r.tryDelete(ctx, user, ids)
+		return err
+	})
+
+	return deleted, err
+}
+
+func (r FileRepository) tryDelete(ctx context.Context, user *User, ids string) ([]*File, error) {
 	files := []*File{}
 
 	if len(ids) > 0 {
@@ -406,7 +761,7 @@ func (r FileRepository) Delete(user *User, ids string) ([]*File, error) {
 		DELETE
 			FROM "File"
 			WHERE "userId" = $1 AND
-			"id" IN (SELECT value FROM json_each($2, '$.ids'))
+			"id" IN (` + jsonIds("$2") + `)
 			RETURNING * ;`
 
 		file := File{}
@@ -427,7 +782,7 @@ func (r FileRepository) Delete(user *User, ids string) ([]*File, error) {
 		UPDATE "FileDeleted"
 			SET "deviceId" = $1
 			WHERE "userId" = $2 AND
-			"id" IN (SELECT value FROM json_each($3, '$.ids'));`
+			"id" IN (` + jsonIds("$3") + `);`
 
 		args = []interface{}{user.DeviceId, user.Id, ids}
 
@@ -445,7 +800,7 @@ func (r FileRepository) Delete(user *User, ids string) ([]*File, error) {
 }
 
 func (r FileRepository) GetById(user *User, id string) (*File, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := r.timeouts.write()
 	defer cancel()
 
 	query := `
@@ -471,7 +826,7 @@ func (r FileRepository) GetById(user *User, id string) (*File, error) {
 }
 
 func (r FileRepository) GetByDigest(user *User, digest string) (*File, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := r.timeouts.write()
 	defer cancel()
 
 	query := `
@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRenameFile(t *testing.T) {
+	repository, _ := newTestRepository(t)
+
+	alice := newTestUser(t, repository, "alice")
+
+	file, err := repository.Files.Create(alice, &File{Digest: "digest", Name: "notes.txt", ContentType: "text/plain"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renamed, err := repository.Files.Update(alice, &File{Id: file.Id, Name: " minutes.txt "})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if renamed.Name != "minutes.txt" || renamed.Digest != file.Digest || renamed.ModifiedAt == nil {
+		t.Errorf("got the file %+v, want the renamed %s", renamed, file.Id)
+	}
+
+	// the other devices sync the rename
+	sync, err := repository.Files.Sync(alice, &History{IgnoreDevice: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sync.FilesUpdated) != 1 || sync.FilesUpdated[0].Name != "minutes.txt" {
+		t.Errorf("got the updated files %v, want %s", sync.FilesUpdated, file.Id)
+	}
+
+	sync, err = repository.Files.Sync(alice, &History{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sync.FilesUpdated) != 0 {
+		t.Errorf("got %d updated files on the renaming device, want 0", len(sync.FilesUpdated))
+	}
+
+	for _, name := range []string{"", "  "} {
+		_, err = repository.Files.Update(alice, &File{Id: file.Id, Name: name})
+		if !errors.Is(err, ErrFileWrongName) {
+			t.Errorf("got %v for the name %q, want %v", err, name, ErrFileWrongName)
+		}
+	}
+
+	// the trashed files can't be renamed
+	err = repository.Files.Trash(alice, `{"ids":["`+file.Id+`"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = repository.Files.Update(alice, &File{Id: file.Id, Name: "agenda.txt"})
+	if !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("got %v for the trashed file, want %v", err, ErrFileNotFound)
+	}
+}
+
+func TestFileTags(t *testing.T) {
+	repository, _ := newTestRepository(t)
+
+	alice := newTestUser(t, repository, "alice")
+	bob := newTestUser(t, repository, "bob")
+
+	invoice, err := repository.Files.Create(alice, &File{Digest: "invoice", Name: "invoice.pdf", ContentType: "application/pdf"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := repository.Files.Create(alice, &File{Digest: "report", Name: "report.pdf", ContentType: "application/pdf"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tag := range []string{"Invoices", "2023"} {
+		_, err = repository.Files.AddTag(alice, invoice.Digest, tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the tags are case-insensitive, the first spelling is kept
+	tags, err := repository.Files.AddTag(alice, report.Digest, " invoices ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tags, err = repository.Files.AddTag(alice, report.Digest, "INVOICES")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tags.Uri != report.Digest || len(tags.Tags) != 1 || tags.Tags[0] != "invoices" {
+		t.Errorf("got the tags %+v, want the invoices of %s", tags, report.Digest)
+	}
+
+	// the ?tag= filters intersect
+	files, err := repository.Files.ListByTag(alice, 0, "Invoices", &ListOptions{Tags: []string{"2023"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files.Files) != 1 || files.Files[0].Id != invoice.Id {
+		t.Errorf("got %d files of the invoices of 2023, want the invoice", len(files.Files))
+	}
+
+	files, err = repository.Files.List(alice, 0, &ListOptions{Tags: []string{"invoices"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files.Files) != 2 {
+		t.Errorf("got %d files of the invoices, want 2", len(files.Files))
+	}
+
+	// the tag change is synced to the other devices as the update of the file
+	sync, err := repository.Files.Sync(alice, &History{Id: invoice.HistoryId, IgnoreDevice: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sync.FilesUpdated) != 2 {
+		t.Errorf("got %d updated files, want 2", len(sync.FilesUpdated))
+	}
+
+	sync, err = repository.Files.Sync(alice, &History{Id: invoice.HistoryId})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sync.FilesUpdated) != 0 {
+		t.Errorf("got %d updated files on the tagging device, want 0", len(sync.FilesUpdated))
+	}
+
+	tags, err = repository.Files.RemoveTag(alice, invoice.Digest, "2023")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tags.Tags) != 1 || tags.Tags[0] != "Invoices" {
+		t.Errorf("got the tags %v, want Invoices", tags.Tags)
+	}
+
+	list, err := repository.Files.ListTags(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list) != 2 {
+		t.Errorf("got the tags of %d uris, want 2", len(list))
+	}
+
+	// the files of the other users and the trashed files can't be tagged
+	_, err = repository.Files.AddTag(bob, invoice.Digest, "stolen")
+	if !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("got %v for the file of the other user, want %v", err, ErrFileNotFound)
+	}
+
+	err = repository.Files.Trash(alice, `{"ids":["`+report.Id+`"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = repository.Files.AddTag(alice, report.Digest, "trashed")
+	if !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("got %v for the trashed file, want %v", err, ErrFileNotFound)
+	}
+
+	for _, tag := range []string{"", strings.Repeat("t", 65), "new\nline"} {
+		_, err = repository.Files.AddTag(alice, invoice.Digest, tag)
+		if !errors.Is(err, ErrMissingTagField) && !errors.Is(err, ErrFileWrongTag) {
+			t.Errorf("got %v for the tag %q, want it rejected", err, tag)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"database/sql"
+)
+
+type UseHealthRepository interface {
+	Check() error
+}
+
+type HealthRepository struct {
+	db       *sql.DB
+	timeouts Timeouts
+}
+
+// Check pings the database and runs a trivial query, i.e. a connection can be had and used.
+func (r *HealthRepository) Check() error {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	err := r.db.PingContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	var one int
+
+	return r.db.QueryRowContext(ctx, "SELECT 1 ;").Scan(&one)
+}
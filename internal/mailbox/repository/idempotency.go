@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type UseIdempotencyRepository interface {
+	Reserve(user *User, key, method, path string, ttl time.Duration) (*IdempotentResponse, error)
+	Complete(user *User, key string, response *IdempotentResponse) error
+	Release(user *User, key string) error
+	Expire(ttl time.Duration) (int64, error)
+}
+
+// IdempotencyRepository keeps the responses of the requests sent with an Idempotency-Key, so a retried
+// request gets the first response instead of creating the resource again.
+type IdempotencyRepository struct {
+	db       *sql.DB
+	timeouts Timeouts
+}
+
+type IdempotentResponse struct {
+	UserId      int64
+	Key         string
+	Method      string
+	Path        string
+	Status      int // zero while the first request is in flight
+	ContentType *string
+	Response    []byte
+	CreatedAt   Timestamp
+}
+
+func (i *IdempotentResponse) Scan() []interface{} {
+	return scanColumns(i)
+}
+
+// Reserve registers the key for the request and returns nil, or the response of the first request with
+// the key. A key older than the ttl is reserved anew.
+func (r *IdempotencyRepository) Reserve(user *User, key, method, path string, ttl time.Duration) (*IdempotentResponse, error) {
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO
+			"IdempotencyKey" ("userId", "key", "method", "path")
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT ("userId", "key") DO UPDATE
+			SET "method" = excluded."method",
+			"path" = excluded."path",
+			"status" = 0,
+			"contentType" = NULL,
+			"response" = NULL,
+			"createdAt" = CURRENT_TIMESTAMP
+			WHERE "IdempotencyKey"."createdAt" <= datetime('now', $5) ;`
+
+	modifier := fmt.Sprintf("-%d seconds", int64(ttl.Seconds()))
+
+	result, err := tx.ExecContext(ctx, query, user.Id, key, method, path, modifier)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if count > 0 {
+		return nil, tx.Commit()
+	}
+
+	query = `
+		SELECT *
+			FROM "IdempotencyKey"
+			WHERE "userId" = $1 AND
+			"key" = $2 ;`
+
+	response := &IdempotentResponse{}
+
+	err = tx.QueryRowContext(ctx, query, user.Id, key).Scan(response.Scan()...)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, tx.Commit()
+}
+
+// Complete stores the response of the request the key was reserved for.
+func (r *IdempotencyRepository) Complete(user *User, key string, response *IdempotentResponse) error {
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	query := `
+		UPDATE "IdempotencyKey"
+			SET "status" = $1,
+			"contentType" = $2,
+			"response" = $3
+			WHERE "userId" = $4 AND
+			"key" = $5 ;`
+
+	_, err := r.db.ExecContext(ctx, query, response.Status, response.ContentType, response.Response, user.Id, key)
+
+	return err
+}
+
+// Release forgets the key of the request with no response to replay, e.g. it failed, so it can be retried.
+func (r *IdempotencyRepository) Release(user *User, key string) error {
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	query := `
+		DELETE
+			FROM "IdempotencyKey"
+			WHERE "userId" = $1 AND
+			"key" = $2 ;`
+
+	_, err := r.db.ExecContext(ctx, query, user.Id, key)
+
+	return err
+}
+
+// Expire forgets the keys older than the ttl and returns their number.
+func (r *IdempotencyRepository) Expire(ttl time.Duration) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	modifier := fmt.Sprintf("-%d seconds", int64(ttl.Seconds()))
+
+	query := `
+		DELETE
+			FROM "IdempotencyKey"
+			WHERE "createdAt" <= datetime('now', $1) ;`
+
+	result, err := r.db.ExecContext(ctx, query, modifier)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,462 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+type UseLabelRepository interface {
+	Create(user *User, label *Label) (*Label, error)
+	List(user *User) (*LabelList, error)
+	Update(user *User, label *Label) (*Label, error)
+	Delete(user *User, id string) error
+	Sync(user *User, history *History) (*LabelSync, error)
+}
+
+// LabelRepository keeps the labels the "labelIds" of the drafts and the messages refer to. The system
+// labels are seeded with the user, they can't be renamed or deleted.
+type LabelRepository struct {
+	db       *sql.DB
+	replica  *sql.DB // the List and Sync, nil = the db
+	timeouts Timeouts
+}
+
+type Label struct {
+	Id         string     `json:"id"`
+	UserId     int64      `json:"-"`
+	Name       string     `json:"name"`
+	Color      *string    `json:"color"`
+	System     bool       `json:"system"`
+	CreatedAt  Timestamp  `json:"createdAt"`
+	ModifiedAt *Timestamp `json:"modifiedAt"`
+	TimelineId int64      `json:"-"`
+	HistoryId  int64      `json:"-"`
+	LastStmt   int        `json:"-"`
+	DeviceId   *string    `json:"-"`
+}
+
+type LabelDeleted struct {
+	Id        string  `json:"id"`
+	UserId    int64   `json:"-"`
+	HistoryId int64   `json:"-"`
+	DeviceId  *string `json:"-"`
+}
+
+type LabelList struct {
+	History int64    `json:"lastHistoryId"`
+	Labels  []*Label `json:"labels"`
+}
+
+type LabelSync struct {
+	History        int64           `json:"lastHistoryId"`
+	NextPollAfter  int             `json:"nextPollAfter"`
+	LabelsInserted []*Label        `json:"inserted"`
+	LabelsUpdated  []*Label        `json:"updated"`
+	LabelsDeleted  []*LabelDeleted `json:"deleted"`
+}
+
+var labelColorRegexp = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+func (l *Label) Scan() []interface{} {
+	return scanColumns(l)
+}
+
+func (l *LabelDeleted) Scan() []interface{} {
+	return scanColumns(l)
+}
+
+// validLabel normalizes the name and the color of the label, the color is optional.
+func validLabel(label *Label) error {
+	label.Name = strings.TrimSpace(label.Name)
+
+	if len(label.Name) == 0 {
+		return ErrMissingNameField
+	}
+
+	if len(label.Name) > 255 {
+		return ErrLabelWrongName
+	}
+
+	if label.Color != nil {
+		if !labelColorRegexp.MatchString(*label.Color) {
+			return ErrLabelWrongColor
+		}
+
+		color := strings.ToLower(*label.Color)
+		label.Color = &color
+	}
+
+	return nil
+}
+
+func getLabel(ctx context.Context, tx *sql.Tx, user *User, id string) (*Label, error) {
+	query := `
+		SELECT *
+			FROM "Label"
+			WHERE "userId" = $1 AND
+			"id" = $2 AND
+			"lastStmt" < 2;`
+
+	label := &Label{}
+
+	err := tx.QueryRowContext(ctx, query, user.Id, id).Scan(label.Scan()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrLabelNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return label, nil
+}
+
+func (r *LabelRepository) Create(user *User, label *Label) (*Label, error) {
+	err := validLabel(label)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT
+			INTO "Label" ("userId", "deviceId", "name", "color")
+			VALUES ($1, $2, $3, $4)
+			RETURNING "id";`
+
+	var id string
+
+	err = tx.QueryRowContext(ctx, query, user.Id, user.DeviceId, label.Name, label.Color).Scan(&id)
+	if err != nil {
+		switch {
+		case strings.HasPrefix(err.Error(), `UNIQUE constraint failed: Label.`):
+			return nil, ErrDuplicateLabel
+		default:
+			return nil, err
+		}
+	}
+
+	// the history is set by the insert trigger
+	label, err = getLabel(ctx, tx, user, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return label, nil
+}
+
+// List lists the labels, the system ones first.
+func (r *LabelRepository) List(user *User) (*LabelList, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	tx, err := reader(r.db, r.replica, user).BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT *
+			FROM "Label"
+			WHERE "userId" = $1 AND
+			"lastStmt" < 2
+			ORDER BY "system" DESC, "name" COLLATE NOCASE;`
+
+	rows, err := tx.QueryContext(ctx, query, user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	labelList := &LabelList{
+		Labels: []*Label{},
+	}
+
+	for rows.Next() {
+		var label Label
+
+		err := rows.Scan(label.Scan()...)
+		if err != nil {
+			return nil, err
+		}
+
+		labelList.Labels = append(labelList.Labels, &label)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// history
+	query = `
+		SELECT "lastHistoryId"
+			FROM "LabelHistorySeq"
+			WHERE "userId" = $1 ;`
+
+	err = tx.QueryRowContext(ctx, query, user.Id).Scan(&labelList.History)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return labelList, nil
+}
+
+// Update replaces the name and the color of the label, only the color of a system label can be changed.
+func (r *LabelRepository) Update(user *User, label *Label) (*Label, error) {
+	err := validLabel(label)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	current, err := getLabel(ctx, tx, user, label.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if current.System && current.Name != label.Name {
+		return nil, ErrSystemLabel
+	}
+
+	query := `
+		UPDATE "Label"
+			SET "name" = $1,
+				"color" = $2,
+				"deviceId" = $3
+			WHERE "userId" = $4 AND
+			"id" = $5;`
+
+	args := []interface{}{label.Name, label.Color, user.DeviceId, user.Id, label.Id}
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		switch {
+		case strings.HasPrefix(err.Error(), `UNIQUE constraint failed: Label.`):
+			return nil, ErrDuplicateLabel
+		default:
+			return nil, err
+		}
+	}
+
+	// the history is set by the update trigger
+	label, err = getLabel(ctx, tx, user, label.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return label, nil
+}
+
+// Delete deletes the label and removes it from the messages, the trashed ones aside. The system labels
+// are kept.
+func (r *LabelRepository) Delete(user *User, id string) error {
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	label, err := getLabel(ctx, tx, user, id)
+	if err != nil {
+		return err
+	}
+
+	if label.System {
+		return ErrSystemLabel
+	}
+
+	query := `
+		DELETE
+			FROM "Label"
+			WHERE "userId" = $1 AND
+			"id" = $2;`
+
+	_, err = tx.ExecContext(ctx, query, user.Id, id)
+	if err != nil {
+		return err
+	}
+
+	query = `
+		UPDATE "LabelDeleted"
+			SET "deviceId" = $1
+			WHERE "userId" = $2 AND
+			"id" = $3;`
+
+	_, err = tx.ExecContext(ctx, query, user.DeviceId, user.Id, id)
+	if err != nil {
+		return err
+	}
+
+	// the history of the messages is set by the update trigger of their labels
+	query = `
+		UPDATE "Message"
+			SET "labelIds" = (SELECT json_group_array(value)
+					FROM json_each("labelIds")
+					WHERE value <> $1),
+			"deviceId" = $2
+			WHERE "userId" = $3 AND
+			"lastStmt" <> 2 AND
+			EXISTS (SELECT 1
+				FROM json_each(iif(json_valid("labelIds"), "labelIds", '[]'))
+				WHERE value = $1);`
+
+	_, err = tx.ExecContext(ctx, query, id, getPrefixedDeviceId(user.DeviceId), user.Id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *LabelRepository) Sync(user *User, history *History) (*LabelSync, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	tx, err := reader(r.db, r.replica, user).BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var deviceId string
+
+	if !history.IgnoreDevice {
+		deviceId = *user.DeviceId
+	}
+
+	labelSync := &LabelSync{
+		LabelsInserted: []*Label{},
+		LabelsUpdated:  []*Label{},
+		LabelsDeleted:  []*LabelDeleted{},
+	}
+
+	// inserted and updated rows
+	query := `
+		SELECT *
+			FROM "Label"
+			WHERE "userId" = $1 AND
+				("deviceId" <> $2 OR "deviceId" IS NULL) AND
+				"historyId" > $3 AND
+				"lastStmt" < 2 AND
+				coalesce("modifiedAt", "createdAt") >= $4
+			ORDER BY "createdAt" DESC;`
+
+	args := []interface{}{user.Id, deviceId, history.Id, history.since()}
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var label Label
+
+		err := rows.Scan(label.Scan()...)
+		if err != nil {
+			return nil, err
+		}
+
+		if label.LastStmt == 0 {
+			labelSync.LabelsInserted = append(labelSync.LabelsInserted, &label)
+		} else {
+			labelSync.LabelsUpdated = append(labelSync.LabelsUpdated, &label)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// deleted rows
+	query = `
+		SELECT *
+			FROM "LabelDeleted"
+			WHERE "userId" = $1 AND
+			("deviceId" <> $2 OR "deviceId" IS NULL) AND
+			"historyId" > $3;`
+
+	args = []interface{}{user.Id, deviceId, history.Id}
+
+	rows, err = tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var labelDeleted LabelDeleted
+
+		err := rows.Scan(labelDeleted.Scan()...)
+		if err != nil {
+			return nil, err
+		}
+
+		labelSync.LabelsDeleted = append(labelSync.LabelsDeleted, &labelDeleted)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// history
+	query = `
+		SELECT "lastHistoryId"
+			FROM "LabelHistorySeq"
+			WHERE "userId" = $1 ;`
+
+	err = tx.QueryRowContext(ctx, query, user.Id).Scan(&labelSync.History)
+	if err != nil {
+		return nil, err
+	}
+
+	labelSync.NextPollAfter, err = nextPollAfter(ctx, tx, "Label", user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return labelSync, nil
+}
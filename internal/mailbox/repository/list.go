@@ -0,0 +1,267 @@
+package repository
+
+import (
+	b64 "encoding/base64"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const MaxListLimit = 1000
+
+// type "/" [subtype | "*"], the tokens as in RFC 2045
+var matchContentTypeFilter = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9!#$&^_.+-]*/([A-Za-z0-9][A-Za-z0-9!#$&^_.+-]*|\*)?$`)
+
+// ListOptions are the paging, sorting and filtering parameters shared by the List queries.
+// The zero value lists all items in the default order.
+type ListOptions struct {
+	Limit   int    // page size, zero means no paging
+	Cursor  string // opaque cursor returned as "nextCursor" by the previous page
+	Sort    string // sort field, validated against the allowlist of the resource
+	Order   string // asc|desc
+	State   string // unread|read|starred|unstarred
+	LabelId string
+
+	ContentType string   // media type, e.g. application/pdf, or its prefix, e.g. image/
+	Tags        []string // the blobs or the files tagged with all the tags
+
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	ModifiedAfter *time.Time
+}
+
+type listCursor struct {
+	Key   string `json:"k"`
+	RowId int64  `json:"r"`
+}
+
+func encodeCursor(key string, rowId int64) string {
+	b, _ := json.Marshal(&listCursor{Key: key, RowId: rowId})
+	return b64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(cursor string) (*listCursor, error) {
+	b, err := b64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c listCursor
+
+	err = json.Unmarshal(b, &c)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &c, nil
+}
+
+// listQuery builds the dynamic part of a List query. The args are numbered after the fixed ones.
+type listQuery struct {
+	where []string
+	args  []interface{}
+}
+
+func newListQuery(args ...interface{}) *listQuery {
+	return &listQuery{args: args}
+}
+
+// arg adds the value to the args and returns its placeholder
+func (q *listQuery) arg(value interface{}) string {
+	q.args = append(q.args, value)
+	return "$" + strconv.Itoa(len(q.args))
+}
+
+func (q *listQuery) and(clause string) {
+	q.where = append(q.where, clause)
+}
+
+func (q *listQuery) whereClause() string {
+	if len(q.where) == 0 {
+		return ""
+	}
+
+	return " AND\n\t\t\t" + strings.Join(q.where, " AND\n\t\t\t")
+}
+
+// sortKey resolves the sort field and order against the allowlist of sort expressions, the "rowid"
+// breaks the ties.
+func (o *ListOptions) sortKey(sortExprs map[string]string, defaultSort, defaultOrder string) (string, bool, error) {
+	sort := defaultSort
+	if len(o.Sort) > 0 {
+		sort = o.Sort
+	}
+
+	expr, ok := sortExprs[sort]
+	if !ok {
+		for name := range sortExprs {
+			if strings.EqualFold(name, sort) {
+				expr, ok = sortExprs[name], true
+				break
+			}
+		}
+		if !ok {
+			return "", false, ErrInvalidSort
+		}
+	}
+
+	order := defaultOrder
+	if len(o.Order) > 0 {
+		order = strings.ToLower(o.Order)
+	}
+
+	switch order {
+	case "asc":
+		return expr, false, nil
+	case "desc":
+		return expr, true, nil
+	default:
+		return "", false, ErrInvalidSort
+	}
+}
+
+// orderBy returns the ORDER BY clause of the List queries without paging
+func (o *ListOptions) orderBy(sortExprs map[string]string, defaultSort, defaultOrder string) (string, error) {
+	key, desc, err := o.sortKey(sortExprs, defaultSort, defaultOrder)
+	if err != nil {
+		return "", err
+	}
+
+	direction := "ASC"
+	if desc {
+		direction = "DESC"
+	}
+
+	return "\n\t\t\tORDER BY " + key + " " + direction + `, "rowid" ` + direction, nil
+}
+
+// page adds the keyset condition of the cursor and returns the ORDER BY and LIMIT clauses.
+// The query selects one row more than the limit to tell whether there is a next page.
+func (o *ListOptions) page(q *listQuery, key string, desc bool) (string, error) {
+	if o.Limit < 0 || o.Limit > MaxListLimit {
+		return "", ErrInvalidLimit
+	}
+
+	direction, comparison := "ASC", ">"
+	if desc {
+		direction, comparison = "DESC", "<"
+	}
+
+	if len(o.Cursor) > 0 {
+		cursor, err := decodeCursor(o.Cursor)
+		if err != nil {
+			return "", err
+		}
+
+		q.and(`(CAST(` + key + ` AS TEXT), "rowid") ` + comparison + ` (` + q.arg(cursor.Key) + `, ` + q.arg(cursor.RowId) + `)`)
+	}
+
+	clause := "\n\t\t\tORDER BY CAST(" + key + " AS TEXT) " + direction + `, "rowid" ` + direction
+
+	if o.Limit > 0 {
+		clause += "\n\t\t\tLIMIT " + q.arg(o.Limit+1)
+	}
+
+	return clause, nil
+}
+
+// state adds the unread/starred filter
+func (o *ListOptions) state(q *listQuery) error {
+	switch strings.ToLower(o.State) {
+	case "":
+	case "unread":
+		q.and(`"unread" = TRUE`)
+	case "read":
+		q.and(`"unread" = FALSE`)
+	case "starred":
+		q.and(`"starred" = TRUE`)
+	case "unstarred":
+		q.and(`"starred" = FALSE`)
+	default:
+		return ErrInvalidState
+	}
+
+	return nil
+}
+
+// label adds the label filter, the "labelIds" column holds a json array
+func (o *ListOptions) label(q *listQuery) {
+	if len(o.LabelId) > 0 {
+		q.and(`EXISTS (SELECT 1 FROM json_each("labelIds") WHERE value = ` + q.arg(o.LabelId) + `)`)
+	}
+}
+
+// tags adds the filter of the rows of the table tagged with all the tags, e.g. of the "Blob" by the
+// "BlobTag". The "tag" column is case-insensitive.
+func (o *ListOptions) tags(q *listQuery, table string) {
+	if len(o.Tags) == 0 {
+		return
+	}
+
+	tags, _ := json.Marshal(o.Tags)
+	arg := q.arg(string(tags))
+
+	// e.g. "blobId"
+	idColumn := strings.ToLower(table[:1]) + table[1:] + "Id"
+
+	q.and(`"id" IN (SELECT "` + idColumn + `"
+				FROM "` + table + `Tag"
+				WHERE "userId" = "` + table + `"."userId" AND
+				"tag" IN (SELECT value FROM json_each(` + arg + `))
+				GROUP BY "` + idColumn + `"
+				HAVING count(*) = (SELECT count(DISTINCT value COLLATE NOCASE) FROM json_each(` + arg + `)))`)
+}
+
+// sqliteTimestamp formats the time like CURRENT_TIMESTAMP does
+func sqliteTimestamp(t *time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
+
+// dateRange adds the createdAt/modifiedAt filters, the bounds are inclusive
+func (o *ListOptions) dateRange(q *listQuery) error {
+	if o.CreatedAfter != nil && o.CreatedBefore != nil && o.CreatedAfter.After(*o.CreatedBefore) {
+		return ErrInvalidDateRange
+	}
+
+	if o.CreatedAfter != nil {
+		q.and(`"createdAt" >= ` + q.arg(sqliteTimestamp(o.CreatedAfter)))
+	}
+
+	if o.CreatedBefore != nil {
+		q.and(`"createdAt" <= ` + q.arg(sqliteTimestamp(o.CreatedBefore)))
+	}
+
+	if o.ModifiedAfter != nil {
+		q.and(`coalesce("modifiedAt", "createdAt") >= ` + q.arg(sqliteTimestamp(o.ModifiedAfter)))
+	}
+
+	return nil
+}
+
+// contentType adds the media type filter, the filter ending with "/" (or "/*") matches the whole type,
+// e.g. image/.
+// The parameters of the stored content type, e.g. charset, are ignored.
+func (o *ListOptions) contentType(q *listQuery) error {
+	if len(o.ContentType) == 0 {
+		return nil
+	}
+
+	if !matchContentTypeFilter.MatchString(o.ContentType) {
+		return ErrInvalidContentType
+	}
+
+	mediaType := `lower(trim(substr("contentType", 1, instr("contentType" || ';', ';') - 1)))`
+
+	contentType := strings.ToLower(strings.TrimSuffix(o.ContentType, "*"))
+
+	if strings.HasSuffix(contentType, "/") {
+		// the "_" is the only LIKE wildcard the filter may contain
+		q.and(mediaType + ` LIKE ` + q.arg(strings.ReplaceAll(contentType, "_", `\_`)+"%") + ` ESCAPE '\'`)
+	} else {
+		q.and(mediaType + ` = ` + q.arg(contentType))
+	}
+
+	return nil
+}
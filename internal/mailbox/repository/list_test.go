@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestListDateRange(t *testing.T) {
+	repository, db := newTestRepository(t)
+
+	alice := newTestUser(t, repository, "alice")
+
+	first := newTestMessage(t, db, alice, "2024-01-01 10:00:00", true, false, "[]")
+	second := newTestMessage(t, db, alice, "2024-01-02 10:00:00", true, false, "[]")
+	third := newTestMessage(t, db, alice, "2024-01-03 10:00:00", true, false, "[]")
+
+	_, err := db.Exec(`UPDATE "Message" SET "modifiedAt" = '2024-02-01 10:00:00' WHERE "id" = $1;`, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := func(value string) *time.Time {
+		t, _ := time.Parse(time.RFC3339, value)
+		return &t
+	}
+
+	tests := []struct {
+		name    string
+		options *ListOptions
+		want    []string
+		err     error
+	}{
+		// the bounds are inclusive
+		{"created after", &ListOptions{CreatedAfter: at("2024-01-02T10:00:00Z")}, []string{second, third}, nil},
+		{"created before", &ListOptions{CreatedBefore: at("2024-01-02T10:00:00Z")}, []string{first, second}, nil},
+		{"created between", &ListOptions{CreatedAfter: at("2024-01-01T12:00:00Z"), CreatedBefore: at("2024-01-02T12:00:00Z")}, []string{second}, nil},
+		{"created in another zone", &ListOptions{CreatedAfter: at("2024-01-03T11:00:00+01:00")}, []string{third}, nil},
+		{"modified after", &ListOptions{ModifiedAfter: at("2024-01-15T00:00:00Z")}, []string{first}, nil},
+		{"modified after as created", &ListOptions{ModifiedAfter: at("2024-01-03T00:00:00Z")}, []string{first, third}, nil},
+		{"after the before", &ListOptions{CreatedAfter: at("2024-01-03T00:00:00Z"), CreatedBefore: at("2024-01-02T00:00:00Z")}, nil, ErrInvalidDateRange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messageList, err := repository.Messages.List(alice, 2, tt.options)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+
+			if err == nil && !equalIds(messageIds(messageList.Messages), tt.want) {
+				t.Errorf("got %v, want %v", messageIds(messageList.Messages), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int64
+		err   error
+	}{
+		{"2024-01-02T10:00:00Z", 1704189600000, nil},
+		{"2024-01-02T11:00:00.250+01:00", 1704189600250, nil},
+		{"1704189600000", 1704189600000, nil},
+		{"2024-01-02", 0, ErrInvalidTimestamp},
+		{"-1", 0, ErrInvalidTimestamp},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseTimestamp(tt.value)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: got %v, want %v", tt.value, err, tt.err)
+			continue
+		}
+
+		if err == nil && got.UnixMilli() != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.value, got.UnixMilli(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type UseLoginAttemptRepository interface {
+	Locked(username, ip string) (time.Duration, error)
+	Fail(username, ip string, maxFailures, maxUserFailures int, lockout time.Duration) (time.Duration, error)
+	Reset(username, ip string) error
+}
+
+// anyIP is the "ip" of the failures of the username from all the client IPs.
+const anyIP = "*"
+
+// LoginAttemptRepository counts the failed logins per username and client IP, so a brute-force attempt
+// locks the username for the attacker's IP only, the owner can still log in from elsewhere. The failures
+// of the username are counted from all the IPs too, with a higher max, so the guesses spread over many
+// IPs lock the username everywhere.
+type LoginAttemptRepository struct {
+	db       *sql.DB
+	timeouts Timeouts
+}
+
+// Locked returns how long the login of the username from the IP is still locked, zero if it is not.
+// The username locked from all the IPs is locked from the IP too.
+func (r *LoginAttemptRepository) Locked(username, ip string) (time.Duration, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	query := `
+		SELECT "lockedUntil"
+			FROM "LoginAttempt"
+			WHERE "username" = $1 AND
+			"ip" IN ($2, $3) AND
+			"lockedUntil" > CURRENT_TIMESTAMP
+			ORDER BY "lockedUntil" DESC
+			LIMIT 1 ;`
+
+	var lockedUntil time.Time
+
+	err := r.db.QueryRowContext(ctx, query, username, ip, anyIP).Scan(&lockedUntil)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	return time.Until(lockedUntil), nil
+}
+
+// Fail counts the failed login from the IP and from all the IPs, the failures within the lockout lock the
+// login for the lockout once they reach the max, maxUserFailures of zero doesn't count the failures from
+// all the IPs. Returns the lockout when this failure locked it.
+func (r *LoginAttemptRepository) Fail(username, ip string, maxFailures, maxUserFailures int, lockout time.Duration) (time.Duration, error) {
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		DELETE
+			FROM "LoginAttempt"
+			WHERE "expiresAt" <= CURRENT_TIMESTAMP ;`
+
+	_, err = tx.ExecContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	expiresAt := time.Now().Add(lockout)
+
+	locked, err := countFailure(ctx, tx, username, ip, maxFailures, expiresAt)
+	if err != nil {
+		return 0, err
+	}
+
+	if maxUserFailures > 0 {
+		lockedUser, err := countFailure(ctx, tx, username, anyIP, maxUserFailures, expiresAt)
+		if err != nil {
+			return 0, err
+		}
+
+		locked = locked || lockedUser
+	}
+
+	if !locked {
+		return 0, tx.Commit()
+	}
+
+	return lockout, tx.Commit()
+}
+
+// countFailure counts the failure of the username from the ip, and locks the login until the expiresAt
+// once the failures reach the max. Tells whether it locked the login.
+func countFailure(ctx context.Context, tx *sql.Tx, username, ip string, maxFailures int, expiresAt time.Time) (bool, error) {
+	query := `
+		INSERT INTO
+			"LoginAttempt" ("username", "ip", "failures", "expiresAt")
+			VALUES ($1, $2, 1, $3)
+			ON CONFLICT ("username", "ip") DO UPDATE
+			SET "failures" = "LoginAttempt"."failures" + 1,
+			"expiresAt" = excluded."expiresAt"
+			RETURNING "failures" ;`
+
+	var failures int
+
+	err := tx.QueryRowContext(ctx, query, username, ip, sqliteTimestamp(&expiresAt)).Scan(&failures)
+	if err != nil {
+		return false, err
+	}
+
+	if failures < maxFailures {
+		return false, nil
+	}
+
+	// the failures start over once the lockout ends
+	query = `
+		UPDATE "LoginAttempt"
+			SET "failures" = 0,
+			"lockedUntil" = $1,
+			"expiresAt" = $1
+			WHERE "username" = $2 AND
+			"ip" = $3 ;`
+
+	_, err = tx.ExecContext(ctx, query, sqliteTimestamp(&expiresAt), username, ip)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Reset forgets the failures from the IP, e.g. on the successful login. The failures from all the IPs are
+// kept until they expire, the login of the owner would let the guesses from the other IPs go on otherwise.
+func (r *LoginAttemptRepository) Reset(username, ip string) error {
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	query := `
+		DELETE
+			FROM "LoginAttempt"
+			WHERE "username" = $1 AND
+			"ip" = $2 ;`
+
+	_, err := r.db.ExecContext(ctx, query, username, ip)
+
+	return err
+}
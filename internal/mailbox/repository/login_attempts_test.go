@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoginAttemptsLockIP(t *testing.T) {
+	repository, _ := newTestRepository(t)
+
+	for i := 1; i <= 3; i++ {
+		wait, err := repository.LoginAttempts.Fail("alice", "192.0.2.1", 3, 0, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if (wait > 0) != (i == 3) {
+			t.Errorf("got the lockout %v after %d failures, want one after 3", wait, i)
+		}
+	}
+
+	// the owner logs in from elsewhere
+	for ip, locked := range map[string]bool{"192.0.2.1": true, "198.51.100.7": false} {
+		wait, err := repository.LoginAttempts.Locked("alice", ip)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if (wait > 0) != locked {
+			t.Errorf("got the lockout %v from %s, want locked %t", wait, ip, locked)
+		}
+	}
+}
+
+func TestLoginAttemptsLockUser(t *testing.T) {
+	repository, _ := newTestRepository(t)
+
+	// the guesses spread over the IPs, none of them reaches the max of an IP
+	ips := []string{"192.0.2.1", "192.0.2.2", "192.0.2.3", "192.0.2.4", "192.0.2.5", "192.0.2.6"}
+
+	for i, ip := range ips {
+		wait, err := repository.LoginAttempts.Fail("alice", ip, 3, len(ips), time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if (wait > 0) != (i == len(ips)-1) {
+			t.Errorf("got the lockout %v after %d failures, want one after %d", wait, i+1, len(ips))
+		}
+	}
+
+	wait, err := repository.LoginAttempts.Locked("alice", "198.51.100.7")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if wait <= 0 {
+		t.Error("the username not locked from the other IPs")
+	}
+
+	// the failures from all the IPs are kept on the login of the owner
+	err = repository.LoginAttempts.Reset("alice", "198.51.100.7")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wait, err = repository.LoginAttempts.Locked("alice", "198.51.100.7")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if wait <= 0 {
+		t.Error("the lockout of the username reset")
+	}
+
+	wait, err = repository.LoginAttempts.Locked("bob", "192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if wait > 0 {
+		t.Error("the other username locked")
+	}
+}
@@ -0,0 +1,94 @@
+package repository
+
+// the pseudo labels of the Counts, the label ids are hex, so they don't collide
+const (
+	InboxCountId = "inbox" // the received messages
+	AllCountId   = "all"   // the drafts and the messages
+)
+
+type LabelCount struct {
+	Total  int64 `json:"total"`
+	Unread int64 `json:"unread"`
+}
+
+// the drafts and the messages not trashed, the drafts are in no folder
+const selectCountedRows = `
+		SELECT "id", "unread", "labelIds", NULL AS "folder"
+			FROM "Draft"
+			WHERE "userId" = $1 AND
+			"lastStmt" < 2
+		UNION ALL
+		SELECT "id", "unread", "labelIds", "folder"
+			FROM "Message"
+			WHERE "userId" = $1 AND
+			"lastStmt" < 2`
+
+// Counts counts the drafts and the messages of each label, and the unread ones, with the inbox and the all
+// pseudo labels. The labels of no draft or message are left out.
+func (r *MessageRepository) Counts(user *User) (map[string]*LabelCount, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	tx, err := reader(r.db, r.replica, user).BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// a label listed twice by a row counts once
+	query := `
+		SELECT "label".value,
+			count(DISTINCT "counted"."id"),
+			count(DISTINCT CASE WHEN "counted"."unread" THEN "counted"."id" END)
+			FROM (` + selectCountedRows + `) AS "counted",
+				json_each(iif(json_valid("counted"."labelIds"), "counted"."labelIds", '[]')) AS "label"
+			GROUP BY "label".value;`
+
+	rows, err := tx.QueryContext(ctx, query, user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	counts := map[string]*LabelCount{}
+
+	for rows.Next() {
+		var labelId string
+		count := &LabelCount{}
+
+		err := rows.Scan(&labelId, &count.Total, &count.Unread)
+		if err != nil {
+			return nil, err
+		}
+
+		counts[labelId] = count
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	query = `
+		SELECT count(*) FILTER (WHERE "folder" = 2),
+			count(*) FILTER (WHERE "folder" = 2 AND "unread"),
+			count(*),
+			count(*) FILTER (WHERE "unread")
+			FROM (` + selectCountedRows + `);`
+
+	inbox, all := &LabelCount{}, &LabelCount{}
+
+	err = tx.QueryRowContext(ctx, query, user.Id).Scan(&inbox.Total, &inbox.Unread, &all.Total, &all.Unread)
+	if err != nil {
+		return nil, err
+	}
+
+	counts[InboxCountId] = inbox
+	counts[AllCountId] = all
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
@@ -1,26 +1,36 @@
 package repository
 
 import (
+	"bytes"
+	"cargomail/internal/shared/config"
 	"context"
 	"database/sql"
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
-	"reflect"
-	"time"
 )
 
 type UseMessageRepository interface {
-	List(user *User, folder int) (*MessageList, error)
+	List(user *User, folder int, options *ListOptions) (*MessageList, error)
+	ListEach(user *User, folder int, options *ListOptions, each func(*Message) error) (*MessageList, error)
+	ListTrashed(user *User) (*MessageList, error)
+	Search(user *User, q string, limit int) ([]*Message, error)
+	GetById(user *User, id string) (*Message, error)
+	RowIds(user *User) (map[string]int64, error)
+	Counts(user *User) (map[string]*LabelCount, error)
 	Sync(user *User, history *History) (*MessageSync, error)
 	Update(user *User, state *State) error
+	SetUnread(user *User, ids string, unread bool) error
+	ModifyLabels(user *User, ids string, addLabelIds, removeLabelIds []string) error
 	Trash(user *User, ids string) error
 	Untrash(user *User, ids string) error
 	Delete(user *User, ids string) error
 }
 
 type MessageRepository struct {
-	db *sql.DB
+	db       *sql.DB
+	replica  *sql.DB // the List, Sync, Counts and Search, nil = the db
+	timeouts Timeouts
 }
 
 type MessagePart struct {
@@ -56,12 +66,19 @@ type MessageDeleted struct {
 }
 
 type MessageList struct {
-	History  int64      `json:"lastHistoryId"`
-	Messages []*Message `json:"messages"`
+	History    int64      `json:"lastHistoryId"`
+	Messages   []*Message `json:"messages"`
+	NextCursor string     `json:"nextCursor,omitempty"`
+}
+
+var messageSortExprs = map[string]string{
+	"createdAt":  `"createdAt"`,
+	"modifiedAt": `coalesce("modifiedAt", "createdAt")`,
 }
 
 type MessageSync struct {
 	History          int64             `json:"lastHistoryId"`
+	NextPollAfter    int               `json:"nextPollAfter"`
 	MessagesInserted []*Message        `json:"inserted"`
 	MessagesUpdated  []*Message        `json:"updated"`
 	MessagesTrashed  []*Message        `json:"trashed"`
@@ -86,46 +103,201 @@ func (v *MessagePart) Scan(value interface{}) error {
 }
 
 func (c *Message) Scan() []interface{} {
-	s := reflect.ValueOf(c).Elem()
-	numCols := s.NumField()
-	columns := make([]interface{}, numCols)
-	for i := 0; i < numCols; i++ {
-		field := s.Field(i)
-		columns[i] = field.Addr().Interface()
+	return scanColumns(c)
+}
+
+// ThreadUid returns the thread of the message, i.e. the X-Thread-ID header the threads are grouped by.
+func (c *Message) ThreadUid() string {
+	if c.Payload == nil {
+		return ""
 	}
-	return columns
+
+	threadUid, _ := c.Payload.Headers["X-Thread-ID"].(string)
+
+	return threadUid
+}
+
+// MarshalJSON adds the snippet generated from the payload and the thread of the message.
+func (c Message) MarshalJSON() ([]byte, error) {
+	type message Message
+
+	return json.Marshal(struct {
+		message
+		Snippet   string `json:"snippet"`
+		ThreadUid string `json:"threadUid,omitempty"`
+	}{
+		message:   message(c),
+		Snippet:   c.Payload.Snippet(config.SnippetHtmlFirst(), config.SnippetLength()),
+		ThreadUid: c.ThreadUid(),
+	})
+}
+
+// UnmarshalJSON accepts the read-only snippet and thread back, the other unknown fields are rejected.
+func (c *Message) UnmarshalJSON(data []byte) error {
+	type message Message
+
+	v := struct {
+		*message
+		Snippet   string `json:"snippet"`
+		ThreadUid string `json:"threadUid"`
+	}{
+		message: (*message)(c),
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	return dec.Decode(&v)
 }
 
 func (c *MessageDeleted) Scan() []interface{} {
-	s := reflect.ValueOf(c).Elem()
-	numCols := s.NumField()
-	columns := make([]interface{}, numCols)
-	for i := 0; i < numCols; i++ {
-		field := s.Field(i)
-		columns[i] = field.Addr().Interface()
+	return scanColumns(c)
+}
+
+func (r *MessageRepository) List(user *User, folder int, options *ListOptions) (*MessageList, error) {
+	messages := []*Message{}
+
+	messageList, err := r.ListEach(user, folder, options, func(message *Message) error {
+		messages = append(messages, message)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return columns
+
+	messageList.Messages = messages
+
+	return messageList, nil
 }
 
-func (r *MessageRepository) List(user *User, folder int) (*MessageList, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+// ListEach lists the messages like the List does, but passes each one to the each as it is read instead of
+// collecting them, the returned list holds the history only.
+func (r *MessageRepository) ListEach(user *User, folder int, options *ListOptions, each func(*Message) error) (*MessageList, error) {
+	ctx, cancel := r.timeouts.read()
 	defer cancel()
 
-	tx, err := r.db.BeginTx(ctx, nil)
+	tx, err := reader(r.db, r.replica, user).BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
 
+	if options == nil {
+		options = &ListOptions{}
+	}
+
+	key, desc, err := options.sortKey(messageSortExprs, "createdAt", "asc")
+	if err != nil {
+		return nil, err
+	}
+
+	q := newListQuery(user.Id, folder)
+
+	err = options.state(q)
+	if err != nil {
+		return nil, err
+	}
+
+	options.label(q)
+
+	err = options.dateRange(q)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := options.page(q, key, desc)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
-		SELECT *
+		SELECT CAST(` + key + ` AS TEXT), "rowid", *
 			FROM "Message"
 			WHERE "userId" = $1 AND
 			CASE WHEN $2 == -1 THEN "folder" > $2 ELSE "folder" == $2 END AND
-			"lastStmt" < 2
-			ORDER BY "createdAt";`
+			"lastStmt" < 2` + q.whereClause() + page + `;`
+
+	rows, err := tx.QueryContext(ctx, query, q.args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
 
-	args := []interface{}{user.Id, folder}
+	messageList := &MessageList{}
+
+	var cursor string
+	var count int
+
+	for rows.Next() {
+		var message Message
+		var sortKey string
+		var rowId int64
+
+		// the extra row tells there is a next page
+		if options.Limit > 0 && count == options.Limit {
+			messageList.NextCursor = cursor
+			break
+		}
+
+		err := rows.Scan(append([]interface{}{&sortKey, &rowId}, message.Scan()...)...)
+
+		if err != nil {
+			return nil, err
+		}
+
+		err = each(&message)
+		if err != nil {
+			return nil, err
+		}
+
+		count++
+		cursor = encodeCursor(sortKey, rowId)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// history
+	query = `
+	SELECT "lastHistoryId"
+	   FROM "MessageHistorySeq"
+	   WHERE "userId" = $1 ;`
+
+	args := []interface{}{user.Id}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&messageList.History)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return messageList, nil
+}
+
+// ListTrashed lists the trashed messages, the recently trashed first.
+func (r *MessageRepository) ListTrashed(user *User) (*MessageList, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	tx, err := reader(r.db, r.replica, user).BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT *
+			FROM "Message"
+			WHERE "userId" = $1 AND
+			"lastStmt" = 2
+			ORDER BY coalesce("modifiedAt", "createdAt") DESC, "rowid" DESC;`
+
+	args := []interface{}{user.Id}
 
 	rows, err := tx.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -142,7 +314,6 @@ func (r *MessageRepository) List(user *User, folder int) (*MessageList, error) {
 		var message Message
 
 		err := rows.Scan(message.Scan()...)
-
 		if err != nil {
 			return nil, err
 		}
@@ -156,11 +327,9 @@ func (r *MessageRepository) List(user *User, folder int) (*MessageList, error) {
 
 	// history
 	query = `
-	SELECT "lastHistoryId"
-	   FROM "MessageHistorySeq"
-	   WHERE "userId" = $1 ;`
-
-	args = []interface{}{user.Id}
+		SELECT "lastHistoryId"
+			FROM "MessageHistorySeq"
+			WHERE "userId" = $1 ;`
 
 	err = tx.QueryRowContext(ctx, query, args...).Scan(&messageList.History)
 	if err != nil {
@@ -174,11 +343,90 @@ func (r *MessageRepository) List(user *User, folder int) (*MessageList, error) {
 	return messageList, nil
 }
 
+func (r *MessageRepository) GetById(user *User, id string) (*Message, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	query := `
+		SELECT *
+			FROM "Message"
+			WHERE "userId" = $1 AND
+			"id" = $2 AND
+			"lastStmt" < 2;`
+
+	message := &Message{}
+
+	args := []interface{}{user.Id, id}
+
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(message.Scan()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrMessageNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return message, nil
+}
+
+// RowIds returns the rowids of the messages by their id. Unlike the timeline, the rowid of a message never
+// changes, and the later messages get the greater ones, e.g. the IMAP UIDs.
+func (r *MessageRepository) RowIds(user *User) (map[string]int64, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	query := `
+		SELECT "id", "rowid"
+			FROM "Message"
+			WHERE "userId" = $1 AND
+			"lastStmt" < 2;`
+
+	rows, err := reader(r.db, r.replica, user).QueryContext(ctx, query, user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	rowIds := map[string]int64{}
+
+	for rows.Next() {
+		var id string
+		var rowId int64
+
+		err := rows.Scan(&id, &rowId)
+		if err != nil {
+			return nil, err
+		}
+
+		rowIds[id] = rowId
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return rowIds, nil
+}
+
 func (r *MessageRepository) Sync(user *User, history *History) (*MessageSync, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := r.timeouts.read()
 	defer cancel()
 
-	tx, err := r.db.BeginTx(ctx, nil)
+	var sync *MessageSync
+
+	err := retryBusy(ctx, func() (err error) {
+		sync, err = r.trySync(ctx, user, history)
+		return err
+	})
+
+	return sync, err
+}
+
+func (r *MessageRepository) trySync(ctx context.Context, user *User, history *History) (*MessageSync, error) {
+	tx, err := reader(r.db, r.replica, user).BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -197,10 +445,11 @@ func (r *MessageRepository) Sync(user *User, history *History) (*MessageSync, er
 			WHERE "userId" = $1 AND
 				"lastStmt" = 0 AND
 				("deviceId" <> $2 OR "deviceId" IS NULL) AND
-				"historyId" > $3
+				"historyId" > $3 AND
+				coalesce("modifiedAt", "createdAt") >= $4
 			ORDER BY "createdAt" DESC;`
 
-	args := []interface{}{user.Id, deviceId, history.Id}
+	args := []interface{}{user.Id, deviceId, history.Id, history.since()}
 
 	rows, err := tx.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -239,10 +488,11 @@ func (r *MessageRepository) Sync(user *User, history *History) (*MessageSync, er
 			WHERE "userId" = $1 AND
 				"lastStmt" = 1 AND
 				("deviceId" <> $2 OR "deviceId" IS NULL) AND
-				"historyId" > $3
+				"historyId" > $3 AND
+				coalesce("modifiedAt", "createdAt") >= $4
 			ORDER BY "createdAt" DESC;`
 
-	args = []interface{}{user.Id, deviceId, history.Id}
+	args = []interface{}{user.Id, deviceId, history.Id, history.since()}
 
 	rows, err = tx.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -274,10 +524,11 @@ func (r *MessageRepository) Sync(user *User, history *History) (*MessageSync, er
 			WHERE "userId" = $1 AND
 				"lastStmt" = 2 AND
 				("deviceId" <> $2 OR "deviceId" IS NULL) AND
-				"historyId" > $3
+				"historyId" > $3 AND
+				coalesce("modifiedAt", "createdAt") >= $4
 			ORDER BY "createdAt" DESC;`
 
-	args = []interface{}{user.Id, deviceId, history.Id}
+	args = []interface{}{user.Id, deviceId, history.Id, history.since()}
 
 	rows, err = tx.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -348,6 +599,11 @@ func (r *MessageRepository) Sync(user *User, history *History) (*MessageSync, er
 		return nil, err
 	}
 
+	messageSync.NextPollAfter, err = nextPollAfter(ctx, tx, "Message", user.Id)
+	if err != nil {
+		return nil, err
+	}
+
 	if err = tx.Commit(); err != nil {
 		return nil, err
 	}
@@ -356,7 +612,7 @@ func (r *MessageRepository) Sync(user *User, history *History) (*MessageSync, er
 }
 
 func (r *MessageRepository) Update(user *User, state *State) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := r.timeouts.write()
 	defer cancel()
 
 	if len(state.Ids) > 0 {
@@ -379,7 +635,7 @@ func (r *MessageRepository) Update(user *User, state *State) error {
 					"starred" = $2,
 					"deviceId" = $3
 				WHERE "userId" = $4 AND
-				"id" IN (SELECT value FROM json_each($5, '$.ids')) AND
+				"id" IN (` + jsonIds("$5") + `) AND
 				"lastStmt" <> 2;`
 			args = []interface{}{state.Unread, state.Starred, prefixedDeviceId, user.Id, idsString}
 		} else if state.Unread == nil && state.Starred != nil {
@@ -388,7 +644,7 @@ func (r *MessageRepository) Update(user *User, state *State) error {
 				SET "starred" = $1,
 					"deviceId" = $2
 				WHERE "userId" = $3 AND
-				"id" IN (SELECT value FROM json_each($4, '$.ids')) AND
+				"id" IN (` + jsonIds("$4") + `) AND
 				"lastStmt" <> 2;`
 			args = []interface{}{state.Starred, prefixedDeviceId, user.Id, idsString}
 		} else if state.Unread != nil && state.Starred == nil {
@@ -397,7 +653,7 @@ func (r *MessageRepository) Update(user *User, state *State) error {
 				SET "unread" = $1,
 					"deviceId" = $2
 				WHERE "userId" = $3 AND
-				"id" IN (SELECT value FROM json_each($4, '$.ids')) AND
+				"id" IN (` + jsonIds("$4") + `) AND
 				"lastStmt" <> 2;`
 			args = []interface{}{state.Unread, prefixedDeviceId, user.Id, idsString}
 		} else {
@@ -413,17 +669,140 @@ func (r *MessageRepository) Update(user *User, state *State) error {
 	return nil
 }
 
+// SetUnread marks the messages of the ids read or unread, the messages already marked so are left as they
+// are, so only the changed ones are synced.
+func (r *MessageRepository) SetUnread(user *User, ids string, unread bool) error {
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	return retryBusy(ctx, func() error {
+		return r.trySetUnread(ctx, user, ids, unread)
+	})
+}
+
+func (r *MessageRepository) trySetUnread(ctx context.Context, user *User, ids string, unread bool) error {
+	if len(ids) > 0 {
+		query := `
+		UPDATE "Message"
+			SET "unread" = $1,
+			"deviceId" = $2
+			WHERE "userId" = $3 AND
+			"id" IN (` + jsonIds("$4") + `) AND
+			"unread" <> $1 AND
+			"lastStmt" <> 2;`
+
+		prefixedDeviceId := getPrefixedDeviceId(user.DeviceId)
+
+		args := []interface{}{unread, prefixedDeviceId, user.Id, ids}
+
+		_, err := r.db.ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ModifyLabels adds the labels to and removes them from the messages of the ids at once. The labels are kept
+// in their order, the added ones after. Only the messages whose labels change are updated, the invalid
+// "labelIds" is taken for none.
+func (r *MessageRepository) ModifyLabels(user *User, ids string, addLabelIds, removeLabelIds []string) error {
+	if len(addLabelIds) == 0 && len(removeLabelIds) == 0 {
+		return ErrMissingLabelIdsField
+	}
+
+	removed := map[string]bool{}
+	for _, id := range removeLabelIds {
+		removed[id] = true
+	}
+
+	added := []string{}
+	seen := map[string]bool{}
+
+	for _, id := range addLabelIds {
+		if removed[id] {
+			return ErrConflictingLabelIds
+		}
+
+		if !seen[id] {
+			seen[id] = true
+			added = append(added, id)
+		}
+	}
+
+	addJson, err := json.Marshal(added)
+	if err != nil {
+		return err
+	}
+
+	removeJson, err := json.Marshal(removeLabelIds)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	return retryBusy(ctx, func() error {
+		return r.tryModifyLabels(ctx, user, ids, string(addJson), string(removeJson))
+	})
+}
+
+func (r *MessageRepository) tryModifyLabels(ctx context.Context, user *User, ids string, addJson, removeJson string) error {
+	if len(ids) > 0 {
+		query := `
+		WITH "Labeled" AS (
+			SELECT "id", iif(json_valid("labelIds"), "labelIds", '[]') AS "labelIds"
+				FROM "Message"
+				WHERE "userId" = $1 AND
+				"id" IN (` + jsonIds("$2") + `) AND
+				"lastStmt" <> 2)
+		UPDATE "Message"
+			SET "labelIds" = (SELECT json_group_array(value)
+					FROM (SELECT value
+							FROM json_each("Labeled"."labelIds")
+							WHERE value NOT IN (SELECT value FROM json_each($3))
+						UNION ALL
+						SELECT value
+							FROM json_each($4)
+							WHERE value NOT IN (SELECT value FROM json_each("Labeled"."labelIds")))),
+			"deviceId" = $5
+			FROM "Labeled"
+			WHERE "Message"."id" = "Labeled"."id" AND
+			(EXISTS (SELECT 1 FROM json_each("Labeled"."labelIds") WHERE value IN (SELECT value FROM json_each($3))) OR
+				EXISTS (SELECT 1 FROM json_each($4) WHERE value NOT IN (SELECT value FROM json_each("Labeled"."labelIds"))));`
+
+		prefixedDeviceId := getPrefixedDeviceId(user.DeviceId)
+
+		args := []interface{}{user.Id, ids, removeJson, addJson, prefixedDeviceId}
+
+		_, err := r.db.ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (r *MessageRepository) Trash(user *User, ids string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := r.timeouts.write()
 	defer cancel()
 
+	return retryBusy(ctx, func() error {
+		return r.tryTrash(ctx, user, ids)
+	})
+}
+
+func (r *MessageRepository) tryTrash(ctx context.Context, user *User, ids string) error {
 	if len(ids) > 0 {
 		query := `
 		UPDATE Message
 			SET "lastStmt" = 2,
 			"deviceId" = $1
 			WHERE "userId" = $2 AND
-			"id" IN (SELECT value FROM json_each($3, '$.ids'));`
+			"id" IN (` + jsonIds("$3") + `);`
 
 		prefixedDeviceId := getPrefixedDeviceId(user.DeviceId)
 
@@ -439,16 +818,22 @@ func (r *MessageRepository) Trash(user *User, ids string) error {
 }
 
 func (r *MessageRepository) Untrash(user *User, ids string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := r.timeouts.write()
 	defer cancel()
 
+	return retryBusy(ctx, func() error {
+		return r.tryUntrash(ctx, user, ids)
+	})
+}
+
+func (r *MessageRepository) tryUntrash(ctx context.Context, user *User, ids string) error {
 	if len(ids) > 0 {
 		query := `
 		UPDATE "Message"
 			SET "lastStmt" = 0,
 			"deviceId" = $1
 			WHERE "userId" = $2 AND
-			"id" IN (SELECT value FROM json_each($3, '$.ids'));`
+			"id" IN (` + jsonIds("$3") + `);`
 
 		prefixedDeviceId := getPrefixedDeviceId(user.DeviceId)
 
@@ -464,9 +849,15 @@ func (r *MessageRepository) Untrash(user *User, ids string) error {
 }
 
 func (r MessageRepository) Delete(user *User, ids string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := r.timeouts.write()
 	defer cancel()
 
+	return retryBusy(ctx, func() error {
+		return r.tryDelete(ctx, user, ids)
+	})
+}
+
+func (r MessageRepository) tryDelete(ctx context.Context, user *User, ids string) error {
 	if len(ids) > 0 {
 		tx, err := r.db.BeginTx(ctx, nil)
 		if err != nil {
@@ -478,7 +869,7 @@ func (r MessageRepository) Delete(user *User, ids string) error {
 		DELETE
 			FROM "Message"
 			WHERE "userId" = $1 AND
-			"id" IN (SELECT value FROM json_each($2, '$.ids'));`
+			"id" IN (` + jsonIds("$2") + `);`
 
 		args := []interface{}{user.Id, ids}
 
@@ -491,7 +882,7 @@ func (r MessageRepository) Delete(user *User, ids string) error {
 		UPDATE "MessageDeleted"
 			SET "deviceId" = $1
 			WHERE "userId" = $2 AND
-			"id" IN (SELECT value FROM json_each($3, '$.ids'));`
+			"id" IN (` + jsonIds("$3") + `);`
 
 		args = []interface{}{user.DeviceId, user.Id, ids}
 
@@ -508,3 +899,61 @@ func (r MessageRepository) Delete(user *User, ids string) error {
 	return nil
 }
 
+// Search finds the messages by the full-text index of their headers, the most relevant first. Zero limit
+// is no limit.
+func (r *MessageRepository) Search(user *User, q string, limit int) ([]*Message, error) {
+	match := matchQuery(q)
+	if len(match) == 0 {
+		return nil, ErrMissingSearchQuery
+	}
+
+	if limit < 0 || limit > MaxListLimit {
+		return nil, ErrInvalidLimit
+	}
+
+	if limit == 0 {
+		limit = -1 // no limit in SQLite
+	}
+
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	query := `
+		SELECT "Message".*
+			FROM "Message"
+			INNER JOIN "MessageSearch"
+			ON "MessageSearch"."docid" = "Message"."rowid"
+			WHERE "MessageSearch"."text" MATCH $1 AND
+			"Message"."userId" = $2 AND
+			"Message"."lastStmt" < 2
+			ORDER BY ` + searchRank("MessageSearch") + ` DESC, "Message"."createdAt" DESC
+			LIMIT $3;`
+
+	args := []interface{}{match, user.Id, limit}
+
+	rows, err := reader(r.db, r.replica, user).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	messages := []*Message{}
+
+	for rows.Next() {
+		var message Message
+
+		err := rows.Scan(message.Scan()...)
+		if err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, &message)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
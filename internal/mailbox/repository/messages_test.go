@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+// newTestMessage inserts the message of the inbox created at the time, e.g. '2024-01-01 10:00:00'.
+func newTestMessage(t *testing.T, db *sql.DB, user *User, createdAt string, unread, starred bool, labelIds string) string {
+	t.Helper()
+
+	query := `
+		INSERT
+			INTO "Message" ("userId", "unread", "starred", "folder", "payload", "labelIds", "createdAt")
+			VALUES ($1, $2, $3, 2, $4, $5, $6)
+			RETURNING "id";`
+
+	var id string
+
+	payload := &MessagePart{Headers: map[string]interface{}{"Subject": "Hello"}}
+
+	err := db.QueryRow(query, user.Id, unread, starred, payload, labelIds, createdAt).Scan(&id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return id
+}
+
+func messageIds(messages []*Message) []string {
+	ids := make([]string, len(messages))
+	for i, message := range messages {
+		ids[i] = message.Id
+	}
+
+	return ids
+}
+
+func equalIds(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestListMessagesPages(t *testing.T) {
+	repository, db := newTestRepository(t)
+
+	alice := newTestUser(t, repository, "alice")
+
+	// the two of the same second are ordered by their rowid
+	var want []string
+
+	for _, createdAt := range []string{"2024-01-01 10:00:00", "2024-01-02 10:00:00", "2024-01-02 10:00:00", "2024-01-03 10:00:00", "2024-01-04 10:00:00"} {
+		want = append(want, newTestMessage(t, db, alice, createdAt, true, false, "[]"))
+	}
+
+	for _, order := range []string{"asc", "desc"} {
+		options := &ListOptions{Limit: 2, Order: order}
+
+		var got []string
+		pages := 0
+
+		for {
+			messageList, err := repository.Messages.List(alice, 2, options)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got = append(got, messageIds(messageList.Messages)...)
+			pages++
+
+			if len(messageList.NextCursor) == 0 {
+				break
+			}
+
+			options.Cursor = messageList.NextCursor
+		}
+
+		expected := want
+		if order == "desc" {
+			expected = make([]string, len(want))
+			for i := range want {
+				expected[i] = want[len(want)-1-i]
+			}
+		}
+
+		if pages != 3 || !equalIds(got, expected) {
+			t.Errorf("%s: got %v in %d pages, want %v in 3", order, got, pages, expected)
+		}
+	}
+}
+
+func TestListMessagesFilters(t *testing.T) {
+	repository, db := newTestRepository(t)
+
+	alice := newTestUser(t, repository, "alice")
+
+	unread := newTestMessage(t, db, alice, "2024-01-01 10:00:00", true, false, "[]")
+	starred := newTestMessage(t, db, alice, "2024-01-02 10:00:00", false, true, `["work"]`)
+	read := newTestMessage(t, db, alice, "2024-01-03 10:00:00", false, false, `["home"]`)
+
+	tests := []struct {
+		name    string
+		options *ListOptions
+		want    []string
+		err     error
+	}{
+		{"unread", &ListOptions{State: "unread"}, []string{unread}, nil},
+		{"read", &ListOptions{State: "read"}, []string{starred, read}, nil},
+		{"starred", &ListOptions{State: "Starred"}, []string{starred}, nil},
+		{"label", &ListOptions{LabelId: "home"}, []string{read}, nil},
+		{"sorted by modifiedAt", &ListOptions{Sort: "modifiedAt", Order: "desc"}, []string{read, starred, unread}, nil},
+		{"unknown state", &ListOptions{State: "snoozed"}, nil, ErrInvalidState},
+		{"unknown sort", &ListOptions{Sort: "subject"}, nil, ErrInvalidSort},
+		{"unknown order", &ListOptions{Order: "up"}, nil, ErrInvalidSort},
+		{"invalid cursor", &ListOptions{Limit: 1, Cursor: "!"}, nil, ErrInvalidCursor},
+		{"limit over the max", &ListOptions{Limit: MaxListLimit + 1}, nil, ErrInvalidLimit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messageList, err := repository.Messages.List(alice, 2, tt.options)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+
+			if err == nil && !equalIds(messageIds(messageList.Messages), tt.want) {
+				t.Errorf("got %v, want %v", messageIds(messageList.Messages), tt.want)
+			}
+		})
+	}
+}
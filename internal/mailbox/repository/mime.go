@@ -0,0 +1,286 @@
+package repository
+
+import (
+	"bytes"
+	b64 "encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"sort"
+	"strings"
+)
+
+// addressHeaders are formatted by the addresses, so the non-ASCII display names are encoded.
+var addressHeaders = map[string]bool{
+	"From":     true,
+	"Sender":   true,
+	"Reply-To": true,
+	"To":       true,
+	"Cc":       true,
+	"Bcc":      true,
+}
+
+// WriteMIME writes the part as a MIME message (RFC 2045) with the CRLF line endings. The placeholder parts,
+// i.e. the message/external-body ones, are replaced by the content the load returns for their digest,
+// encoded in base64. The multipart parts get a boundary of their own.
+func (p *MessagePart) WriteMIME(w io.Writer, load func(digest string) ([]byte, error)) error {
+	buf := &bytes.Buffer{}
+
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	err := p.writeMIME(buf, load)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(buf.Bytes())
+
+	return err
+}
+
+// RenderMIME renders the part like the WriteMIME does, e.g. for the .eml export of a draft. The placeholder
+// parts are rendered by the content the load returns, with no load they fail the rendering.
+func (p *MessagePart) RenderMIME(load func(digest string) ([]byte, error)) ([]byte, error) {
+	if load == nil {
+		load = func(digest string) ([]byte, error) {
+			return nil, fmt.Errorf("%w: %s", ErrUnresolvedPlaceholder, digest)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+
+	err := p.WriteMIME(buf, load)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Resolve returns a copy of the part whose placeholder parts hold the content the load returns for their
+// digest, encoded in base64, e.g. for the instance of a federated recipient, which can't load them.
+func (p *MessagePart) Resolve(load func(digest string) ([]byte, error)) (*MessagePart, error) {
+	resolved := &MessagePart{Headers: make(map[string]interface{}, len(p.Headers)), Body: p.Body}
+
+	for k, v := range p.Headers {
+		resolved.Headers[k] = v
+	}
+
+	if v, ok := p.Headers["Content-Type"].([]interface{}); ok {
+		// the placeholder of a blob or a file
+		if len(v) < 2 {
+			return nil, ErrInvalidContentType
+		}
+
+		realType, ok := v[1].(string)
+		if !ok {
+			return nil, ErrInvalidContentType
+		}
+
+		digest, _ := p.Headers["Content-ID"].(string)
+		digest = strings.TrimSuffix(strings.TrimPrefix(digest, "<"), ">")
+		if len(digest) == 0 {
+			return nil, ErrMissingDigestField
+		}
+
+		data, err := load(digest)
+		if err != nil {
+			return nil, err
+		}
+
+		resolved.Headers["Content-Type"] = realType
+		resolved.Headers["Content-Transfer-Encoding"] = "base64"
+		resolved.Body = &Body{Data: b64.StdEncoding.EncodeToString(data)}
+	}
+
+	for _, part := range p.Parts {
+		resolvedPart, err := part.Resolve(load)
+		if err != nil {
+			return nil, err
+		}
+
+		resolved.Parts = append(resolved.Parts, resolvedPart)
+	}
+
+	return resolved, nil
+}
+
+func (p *MessagePart) writeMIME(buf *bytes.Buffer, load func(digest string) ([]byte, error)) error {
+	headers := make(map[string]string, len(p.Headers))
+
+	var data []byte
+	var err error
+
+	for k, v := range p.Headers {
+		if k == "MIME-Version" {
+			continue
+		}
+
+		switch v := v.(type) {
+		case string:
+			headers[k] = v
+		case []interface{}:
+			// the placeholder of a blob or a file
+			if k != "Content-Type" || len(v) < 2 {
+				return ErrInvalidContentType
+			}
+
+			realType, ok := v[1].(string)
+			if !ok {
+				return ErrInvalidContentType
+			}
+
+			digest, _ := p.Headers["Content-ID"].(string)
+			digest = strings.TrimSuffix(strings.TrimPrefix(digest, "<"), ">")
+			if len(digest) == 0 {
+				return ErrMissingDigestField
+			}
+
+			data, err = load(digest)
+			if err != nil {
+				return err
+			}
+
+			headers[k] = realType
+		}
+	}
+
+	var body string
+
+	if data != nil {
+		headers["Content-Transfer-Encoding"] = "base64"
+	} else if p.Body != nil {
+		body = p.Body.Data
+
+		if strings.EqualFold(headers["Content-Transfer-Encoding"], "base64") {
+			data, err = b64.StdEncoding.DecodeString(body)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	var boundary string
+
+	if len(p.Parts) > 0 {
+		mediaType, params, err := mime.ParseMediaType(headers["Content-Type"])
+		if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+			mediaType, params = "multipart/mixed", map[string]string{}
+		}
+
+		boundary = multipart.NewWriter(io.Discard).Boundary()
+		params["boundary"] = boundary
+
+		headers["Content-Type"] = mime.FormatMediaType(mediaType, params)
+		delete(headers, "Content-Transfer-Encoding")
+	} else if data == nil && len(body) > 0 {
+		if sevenBit(body) {
+			headers["Content-Transfer-Encoding"] = "7bit"
+		} else {
+			headers["Content-Transfer-Encoding"] = "quoted-printable"
+		}
+	}
+
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		buf.WriteString(k + ": " + headerValue(k, headers[k]) + "\r\n")
+	}
+
+	buf.WriteString("\r\n")
+
+	switch {
+	case len(p.Parts) > 0:
+		for _, part := range p.Parts {
+			buf.WriteString("--" + boundary + "\r\n")
+
+			err := part.writeMIME(buf, load)
+			if err != nil {
+				return err
+			}
+
+			buf.WriteString("\r\n")
+		}
+
+		buf.WriteString("--" + boundary + "--\r\n")
+	case data != nil:
+		encoded := b64.StdEncoding.EncodeToString(data)
+
+		for len(encoded) > 76 {
+			buf.WriteString(encoded[:76] + "\r\n")
+			encoded = encoded[76:]
+		}
+
+		if len(encoded) > 0 {
+			buf.WriteString(encoded + "\r\n")
+		}
+	case headers["Content-Transfer-Encoding"] == "quoted-printable":
+		qp := quotedprintable.NewWriter(buf)
+
+		_, err := qp.Write([]byte(crlf(body)))
+		if err != nil {
+			return err
+		}
+
+		err = qp.Close()
+		if err != nil {
+			return err
+		}
+	default:
+		buf.WriteString(crlf(body))
+	}
+
+	return nil
+}
+
+// headerValue folds the value to a single line, and encodes its non-ASCII text as the RFC 2047 words.
+func headerValue(key, value string) string {
+	value = strings.Join(strings.Fields(value), " ")
+
+	if addressHeaders[key] {
+		addresses, err := mail.ParseAddressList(value)
+		if err == nil {
+			formatted := make([]string, len(addresses))
+			for i, address := range addresses {
+				formatted[i] = address.String()
+			}
+
+			return strings.Join(formatted, ", ")
+		}
+	}
+
+	if sevenBit(value) {
+		return value
+	}
+
+	return mime.QEncoding.Encode("utf-8", value)
+}
+
+// sevenBit tells whether the text is ASCII with the lines short enough to be sent as is.
+func sevenBit(text string) bool {
+	for _, line := range strings.Split(text, "\n") {
+		if len(line) > 998 {
+			return false
+		}
+	}
+
+	for i := 0; i < len(text); i++ {
+		if text[i] >= 0x80 || text[i] == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func crlf(text string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\n", "\r\n")
+}
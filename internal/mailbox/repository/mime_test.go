@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func placeholderPart(digest, contentType string) *MessagePart {
+	return &MessagePart{
+		Headers: map[string]interface{}{
+			"Content-Disposition": "inline",
+			"Content-ID":          "<" + digest + ">",
+			"Content-Type":        []interface{}{`message/external-body; access-type="x-content-addressed-uri"`, contentType},
+		},
+	}
+}
+
+func TestRenderMIMELoad(t *testing.T) {
+	part := &MessagePart{
+		Headers: map[string]interface{}{"From": "alice@example.org", "Content-Type": "multipart/related"},
+		Parts: []*MessagePart{
+			{
+				Headers: map[string]interface{}{"Content-Type": "multipart/alternative"},
+				Parts:   []*MessagePart{placeholderPart("plain", "text/plain"), placeholderPart("html", "text/html")},
+			},
+			placeholderPart("logo", "image/png"),
+		},
+	}
+
+	var loaded []string
+
+	rendered, err := part.RenderMIME(func(digest string) ([]byte, error) {
+		loaded = append(loaded, digest)
+		return []byte(digest), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(loaded, " ") != "plain html logo" {
+		t.Errorf("got the digests %v loaded", loaded)
+	}
+
+	if strings.Contains(string(rendered), "message/external-body") {
+		t.Error("the placeholder Content-Type rendered")
+	}
+
+	// the inline image is referred to by its Content-ID
+	if !strings.Contains(string(rendered), "Content-ID: <logo>\r\n") {
+		t.Error("the Content-ID of the inline image not rendered")
+	}
+
+	_, err = part.RenderMIME(nil)
+	if !errors.Is(err, ErrUnresolvedPlaceholder) {
+		t.Errorf("got %v with no load, want %v", err, ErrUnresolvedPlaceholder)
+	}
+}
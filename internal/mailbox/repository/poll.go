@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+)
+
+// The bounds of the "nextPollAfter" hint of the sync responses, in seconds.
+const (
+	MinPollAfter = 15
+	MaxPollAfter = 300
+)
+
+// nextPollAfter suggests the polling interval from the number of the rows changed in the last
+// 15 minutes, so the quiet mailboxes are polled less often and the busy ones more often.
+// The clients connected to the event stream do not need to poll at all.
+func nextPollAfter(ctx context.Context, tx *sql.Tx, table string, userId int64) (int, error) {
+	query := `
+		SELECT count(*)
+			FROM (SELECT 1
+				FROM "` + table + `"
+				WHERE "userId" = $1 AND
+				coalesce("modifiedAt", "createdAt") >= datetime('now', '-15 minutes')
+				LIMIT $2);`
+
+	var changes int
+
+	err := tx.QueryRowContext(ctx, query, userId, MaxPollAfter/MinPollAfter).Scan(&changes)
+	if err != nil {
+		return 0, err
+	}
+
+	interval := MaxPollAfter / (1 + changes)
+	if interval < MinPollAfter {
+		interval = MinPollAfter
+	}
+
+	return interval, nil
+}
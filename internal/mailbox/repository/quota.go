@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"cargomail/internal/shared/config"
+	"context"
+	"database/sql"
+	"math"
+)
+
+// checkQuota rejects the size added to the stored bytes of the user over the quota. The blobs and the files
+// count until they are deleted, the trashed ones included. It runs in the transaction of the insert, after
+// any delete of the same transaction.
+func checkQuota(ctx context.Context, tx *sql.Tx, userId int64, size int64) error {
+	query := `
+		SELECT coalesce("quotaBytes", $1)
+			FROM "User"
+			WHERE "id" = $2 ;`
+
+	var quota int64
+
+	err := tx.QueryRowContext(ctx, query, config.QuotaBytes(), userId).Scan(&quota)
+	if err != nil {
+		return err
+	}
+
+	if quota <= 0 {
+		return nil
+	}
+
+	query = `
+		SELECT (SELECT coalesce(sum("size"), 0) FROM "Blob" WHERE "userId" = $1) +
+			(SELECT coalesce(sum("size"), 0) FROM "File" WHERE "userId" = $1) ;`
+
+	var used int64
+
+	err = tx.QueryRowContext(ctx, query, userId).Scan(&used)
+	if err != nil {
+		return err
+	}
+
+	if used+size > quota {
+		return ErrQuotaExceeded
+	}
+
+	return nil
+}
+
+// StorageUsage is the storage of the user against the quota, it feeds the storage meter.
+type StorageUsage struct {
+	UsedBytes      int64    `json:"usedBytes"`
+	TrashedBytes   int64    `json:"trashedBytes"`
+	QuotaBytes     int64    `json:"quotaBytes"`               // 0 = unlimited
+	RemainingBytes *int64   `json:"remainingBytes,omitempty"` // with a quota only
+	Percent        *float64 `json:"percent,omitempty"`        // of the quota taken
+	Blobs          *Usage   `json:"blobs"`
+	Files          *Usage   `json:"files"`
+}
+
+// NewStorageUsage sums the usage of the blobs and the files, the trashed bytes count against the quota.
+func NewStorageUsage(blobs, files *Usage, quotaBytes int64) *StorageUsage {
+	usage := &StorageUsage{
+		UsedBytes:    blobs.Bytes + files.Bytes,
+		TrashedBytes: blobs.TrashedBytes + files.TrashedBytes,
+		QuotaBytes:   quotaBytes,
+		Blobs:        blobs,
+		Files:        files,
+	}
+
+	if quotaBytes > 0 {
+		taken := usage.UsedBytes + usage.TrashedBytes
+
+		remaining := quotaBytes - taken
+		if remaining < 0 {
+			remaining = 0
+		}
+
+		percent := math.Round(float64(taken)/float64(quotaBytes)*1000) / 10
+
+		usage.RemainingBytes = &remaining
+		usage.Percent = &percent
+	}
+
+	return usage
+}
@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	b64 "encoding/base64"
+	"errors"
+	"strings"
+	"time"
+)
+
+type UseRefreshTokenRepository interface {
+	Create(user *User, deviceId string, ttl time.Duration) (*NewRefreshToken, error)
+	Rotate(token string, ttl time.Duration) (*NewRefreshToken, error)
+	Revoke(user *User, deviceId string) error
+}
+
+// RefreshTokenRepository keeps the long-lived tokens the clients exchange for a new session, the tokens
+// are stored hashed, as the API keys.
+type RefreshTokenRepository struct {
+	db       *sql.DB
+	timeouts Timeouts
+}
+
+const RefreshTokenPrefix = "cmr_"
+
+// NewRefreshToken is the only place the plaintext token is ever returned.
+type NewRefreshToken struct {
+	UserId    int64     `json:"-"`
+	DeviceId  string    `json:"-"`
+	Token     string    `json:"refreshToken"`
+	ExpiresAt time.Time `json:"refreshTokenExpiry"`
+}
+
+func generateRefreshToken() (string, error) {
+	b := make([]byte, 32)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return RefreshTokenPrefix + b64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func insertRefreshToken(ctx context.Context, tx *sql.Tx, userId int64, deviceId string, ttl time.Duration) (*NewRefreshToken, error) {
+	token, err := generateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(ttl)
+
+	query := `
+		INSERT
+			INTO "RefreshToken" ("userId", "tokenHash", "deviceId", "expiresAt")
+			VALUES ($1, $2, $3, $4) ;`
+
+	args := []interface{}{userId, hashApiKey(token), deviceId, sqliteTimestamp(&expiresAt)}
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &NewRefreshToken{UserId: userId, DeviceId: deviceId, Token: token, ExpiresAt: expiresAt}, nil
+}
+
+// Create issues a refresh token of the device, the expired tokens of the user are dropped meanwhile.
+func (r *RefreshTokenRepository) Create(user *User, deviceId string, ttl time.Duration) (*NewRefreshToken, error) {
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		DELETE
+			FROM "RefreshToken"
+			WHERE "userId" = $1 AND
+			"expiresAt" <= CURRENT_TIMESTAMP ;`
+
+	_, err = tx.ExecContext(ctx, query, user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := insertRefreshToken(ctx, tx, user.Id, deviceId, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	return refreshToken, tx.Commit()
+}
+
+// Rotate exchanges the token for a new one of the same device, valid for the full TTL again. A token
+// used twice was likely stolen, so the reuse revokes all the tokens of the device.
+func (r *RefreshTokenRepository) Rotate(token string, ttl time.Duration) (*NewRefreshToken, error) {
+	if !strings.HasPrefix(token, RefreshTokenPrefix) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT "id", "userId", "deviceId", "revokedAt" IS NOT NULL, "expiresAt" <= CURRENT_TIMESTAMP
+			FROM "RefreshToken"
+			WHERE "tokenHash" = $1 ;`
+
+	var id, deviceId string
+	var userId int64
+	var revoked, expired bool
+
+	err = tx.QueryRowContext(ctx, query, hashApiKey(token)).Scan(&id, &userId, &deviceId, &revoked, &expired)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	if expired {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if revoked {
+		err = revokeRefreshTokens(ctx, tx, userId, deviceId)
+		if err != nil {
+			return nil, err
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, ErrInvalidRefreshToken
+	}
+
+	query = `
+		UPDATE "RefreshToken"
+			SET "revokedAt" = CURRENT_TIMESTAMP
+			WHERE "id" = $1 ;`
+
+	_, err = tx.ExecContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := insertRefreshToken(ctx, tx, userId, deviceId, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	return refreshToken, tx.Commit()
+}
+
+// Revoke revokes the tokens of the device, e.g. on logout.
+func (r *RefreshTokenRepository) Revoke(user *User, deviceId string) error {
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = revokeRefreshTokens(ctx, tx, user.Id, deviceId)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// revokeRefreshTokens revokes the tokens of the device.
+func revokeRefreshTokens(ctx context.Context, tx *sql.Tx, userId int64, deviceId string) error {
+	query := `
+		UPDATE "RefreshToken"
+			SET "revokedAt" = CURRENT_TIMESTAMP
+			WHERE "userId" = $1 AND
+			"deviceId" = $2 AND
+			"revokedAt" IS NULL ;`
+
+	_, err := tx.ExecContext(ctx, query, userId, deviceId)
+	return err
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRotateRefreshToken(t *testing.T) {
+	repository, _ := newTestRepository(t)
+
+	alice := newTestUser(t, repository, "alice")
+
+	issued, err := repository.RefreshTokens.Create(alice, *alice.DeviceId, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := repository.RefreshTokens.Rotate(issued.Token, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rotated.Token == issued.Token || rotated.DeviceId != *alice.DeviceId {
+		t.Errorf("got the token %q of the device %q", rotated.Token, rotated.DeviceId)
+	}
+
+	// the reuse of the rotated token revokes the new one too
+	_, err = repository.RefreshTokens.Rotate(issued.Token, time.Hour)
+	if !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("got %v on the reuse, want %v", err, ErrInvalidRefreshToken)
+	}
+
+	_, err = repository.RefreshTokens.Rotate(rotated.Token, time.Hour)
+	if !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("got %v after the reuse, want %v", err, ErrInvalidRefreshToken)
+	}
+}
+
+func TestRemoveAllRevokesRefreshTokens(t *testing.T) {
+	repository, _ := newTestRepository(t)
+
+	alice := newTestUser(t, repository, "alice")
+	bob := newTestUser(t, repository, "bob")
+
+	var tokens []*NewRefreshToken
+
+	for _, deviceId := range []string{*alice.DeviceId, "fedcba9876543210fedcba9876543210"} {
+		token, err := repository.RefreshTokens.Create(alice, deviceId, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tokens = append(tokens, token)
+	}
+
+	other, err := repository.RefreshTokens.Create(bob, *bob.DeviceId, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = repository.Session.RemoveAll(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, token := range tokens {
+		_, err = repository.RefreshTokens.Rotate(token.Token, time.Hour)
+		if !errors.Is(err, ErrInvalidRefreshToken) {
+			t.Errorf("got %v for the token of the device %s, want %v", err, token.DeviceId, ErrInvalidRefreshToken)
+		}
+	}
+
+	_, err = repository.RefreshTokens.Rotate(other.Token, time.Hour)
+	if err != nil {
+		t.Errorf("the token of the other user revoked: %v", err)
+	}
+}
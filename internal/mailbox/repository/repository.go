@@ -1,9 +1,13 @@
 package repository
 
 import (
+	"cargomail/internal/shared/config"
+	"cargomail/internal/shared/database"
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"strconv"
 	"time"
 )
 
@@ -20,40 +24,170 @@ func (e *RecipientsNotFoundError) Error() string {
 	return fmt.Sprintf("recipients %v: err %v", e.Recipients, e.Err)
 }
 
+func (e *RecipientsNotFoundError) Unwrap() error {
+	return e.Err
+}
+
+// DuplicateContactError is the ErrDuplicateContact with the id of the contact holding the email address,
+// so the client may offer to merge the two.
+type DuplicateContactError struct {
+	ContactId string
+}
+
+func (e *DuplicateContactError) Error() string {
+	return ErrDuplicateContact.Error()
+}
+
+func (e *DuplicateContactError) Unwrap() error {
+	return ErrDuplicateContact
+}
+
+func (e *DuplicateContactError) Details() map[string]string {
+	return map[string]string{"contactId": e.ContactId}
+}
+
 var (
 	ErrUsernameAlreadyTaken     = errors.New("username already taken")
 	ErrUsernameNotFound         = errors.New("username not found")
 	ErrInvalidCredentials       = errors.New("invalid authentication credentials")
 	ErrMissingUserContext       = errors.New("missing user context")
+	ErrInternalServer           = errors.New("internal server error")
+	ErrRateLimited              = errors.New("too many requests")
+	ErrServerBusy               = errors.New("too many requests in flight")
+	ErrMissingSessionCookie     = errors.New("cookie not found")
+	ErrUnsupportedEncoding      = errors.New("unsupported content encoding")
+	ErrNotFound                 = errors.New("not found")
 	ErrInvalidOrMissingSession  = errors.New("invalid or missing session")
 	ErrFailedValidationResponse = errors.New("failed validation")
 	ErrContactNotFound          = errors.New("contact not found")
 	ErrDuplicateContact         = errors.New("contact already exists")
 	ErrInvalidEmailAddress      = errors.New("invalid email address")
+	ErrMultiplePrimaryEmails    = errors.New("multiple primary email addresses")
+	ErrPrimaryEmailMismatch     = errors.New("the email address is not the primary one of the email addresses")
+	ErrEmailAddressNotFound     = errors.New("email address not found")
+	ErrLastEmailAddress         = errors.New("the last email address can't be removed")
+	ErrInvalidPhoneNumber       = errors.New("invalid phone number, the E.164 format is expected")
+	ErrContactGroupNotFound     = errors.New("contact group not found")
+	ErrDuplicateContactGroup    = errors.New("contact group already exists")
+	ErrContactGroupWrongName    = errors.New("wrong contact group name")
+	ErrInvalidCSV               = errors.New("invalid csv")
+	ErrMissingEmailColumn       = errors.New("missing email address column")
+	ErrTooManyCSVRows           = errors.New("too many csv rows")
 	ErrBlobNotFound             = errors.New("blob not found")
 	ErrBlobWrongName            = errors.New("wrong blob name")
 	ErrFileNotFound             = errors.New("file not found")
+	ErrFileWrongName            = errors.New("wrong file name")
 	ErrDraftNotFound            = errors.New("draft not found")
+	ErrDraftVersionNotFound     = errors.New("draft version not found")
+	ErrAttachmentNotFound       = errors.New("attachment not found")
+	ErrMissingUriField          = errors.New("missing 'uri' field")
 	ErrMissingSender            = errors.New("missing sender")
 	ErrInvalidSender            = errors.New("invalid sender")
 	ErrMissingRecipients        = errors.New("missing recipient(s)")
 	ErrInvalidRecipients        = errors.New("invalid recipient(s)")
 	ErrRecipientNotFound        = errors.New("recipient(s) not found")
 	ErrMessageNotFound          = errors.New("message not found")
+	ErrThreadNotFound           = errors.New("thread not found")
 	ErrMissingIdsField          = errors.New("missing 'ids' field")
 	ErrMissingIdField           = errors.New("missing 'id' field")
+	ErrMissingDigestField       = errors.New("missing 'digest' field")
 	ErrMissingPayloadField      = errors.New("missing 'payload' field")
 	ErrMissingHeadersField      = errors.New("missing 'headers' field")
 	ErrMissingStateField        = errors.New("missing state field(s)")
 	ErrWrongResourceDigest      = errors.New("wrong resource digest")
+	ErrCorruptContent           = errors.New("stored content does not match its digest")
+	ErrInvalidDigest            = errors.New("invalid digest")
 	ErrEmptyPayload             = errors.New("empty payload")
 	ErrMissingContentType       = errors.New("missing content type")
 	ErrUnknownMessageType       = errors.New("unknown message type")
+	ErrUnknownSearchIndex       = errors.New("unknown search index")
+	ErrUnknownResource          = errors.New("unknown resource")
+	ErrQuotaExceeded            = errors.New("storage quota exceeded")
+	ErrUploadNotFound           = errors.New("upload not found")
+	ErrUploadOffsetMismatch     = errors.New("upload offset mismatch")
+	ErrUploadIncomplete         = errors.New("upload incomplete")
+	ErrUploadTooLarge           = errors.New("upload too large")
+	ErrUploadHashMismatch       = errors.New("upload hash mismatch")
+	ErrMissingHashField         = errors.New("missing 'hash' field")
+	ErrInvalidContentRange      = errors.New("invalid content range")
+	ErrInvalidCursor            = errors.New("invalid cursor")
+	ErrInvalidSort              = errors.New("invalid sort")
+	ErrInvalidState             = errors.New("invalid state")
+	ErrInvalidLimit             = errors.New("invalid limit")
+	ErrInvalidTimestamp         = errors.New("invalid timestamp")
+	ErrInvalidDateRange         = errors.New("invalid date range")
+	ErrInvalidContentType       = errors.New("invalid content type")
+	ErrInvalidImage             = errors.New("invalid image")
+	ErrApiKeyNotFound           = errors.New("api key not found")
+	ErrInvalidApiKey            = errors.New("invalid or expired api key")
+	ErrDeviceNotFound           = errors.New("device not found")
+	ErrInvalidRefreshToken      = errors.New("invalid or expired refresh token")
+	ErrWeakPassword             = errors.New("the password must be 8 to 40 characters long, with letters and digits or symbols, and differ from the username and the current password")
+	ErrInvalidScope             = errors.New("invalid scope")
+	ErrInsufficientScope        = errors.New("insufficient scope")
+	ErrInvalidExpiry            = errors.New("invalid expiry")
+	ErrInvalidSignature         = errors.New("invalid signature")
+	ErrSignatureExpired         = errors.New("signature expired")
+	ErrInvalidIdempotencyKey    = errors.New("invalid idempotency key")
+	ErrIdempotencyKeyInFlight   = errors.New("request with the same idempotency key in progress")
+	ErrIdempotencyKeyReused     = errors.New("idempotency key used for a different request")
+	ErrMissingNameField         = errors.New("missing 'name' field")
+	ErrMissingSearchQuery       = errors.New("missing search query")
+	ErrInvalidAuditAction       = errors.New("invalid audit action")
+	ErrAccountLocked            = errors.New("too many failed login attempts, try again later")
+	ErrInvalidSetting           = errors.New("unknown setting or invalid value")
+	ErrInvalidHistoryId         = errors.New("invalid history id")
+	ErrTooManyStreams           = errors.New("too many event streams")
+	ErrWebhookNotFound          = errors.New("webhook not found")
+	ErrInvalidWebhookUrl        = errors.New("invalid webhook url, an absolute https url is expected")
+	ErrTooManyWebhooks          = errors.New("too many webhooks")
+	ErrSavedSearchNotFound      = errors.New("saved search not found")
+	ErrDuplicateSavedSearch     = errors.New("saved search already exists")
+	ErrSavedSearchWrongName     = errors.New("wrong saved search name")
+	ErrInvalidSearchQuery       = errors.New("invalid search query, up to 500 characters of plain search terms are expected")
+	ErrTooManySavedSearches     = errors.New("too many saved searches")
+	ErrSmtpRelayNotConfigured   = errors.New("smtp relay not configured")
+	ErrSmtpTemporaryFailure     = errors.New("smtp relay temporary failure, try again later")
+	ErrSmtpPermanentFailure     = errors.New("smtp relay rejected the message")
+	ErrUnknownPeer              = errors.New("sender domain is not a federation peer")
+	ErrMissingMessageId         = errors.New("missing Message-ID header")
+	ErrPeerKeyNotFound          = errors.New("federation peer key not found")
+	ErrInvalidPeerKey           = errors.New("invalid federation peer key, a base64 Ed25519 public key is expected")
+	ErrMissingTagField          = errors.New("missing 'tag' field")
+	ErrBlobWrongTag             = errors.New("wrong blob tag")
+	ErrFileWrongTag             = errors.New("wrong file tag")
+	ErrCollectionNotFound       = errors.New("collection not found")
+	ErrDuplicateCollection      = errors.New("collection already exists")
+	ErrCollectionWrongName      = errors.New("wrong collection name")
+	ErrCollectionCycle          = errors.New("collection can't be moved into itself or its descendants")
+	ErrCollectionNotEmpty       = errors.New("collection is not empty")
+	ErrMissingLabelIdsField     = errors.New("missing 'addLabelIds' or 'removeLabelIds' field")
+	ErrConflictingLabelIds      = errors.New("label both added and removed")
+	ErrLabelNotFound            = errors.New("label not found")
+	ErrDuplicateLabel           = errors.New("label already exists")
+	ErrLabelWrongName           = errors.New("wrong label name")
+	ErrLabelWrongColor          = errors.New("wrong label color, e.g. #1a73e8 is expected")
+	ErrSystemLabel              = errors.New("system label can't be renamed or deleted")
+	ErrUnresolvedPlaceholder    = errors.New("placeholder part without its content")
 )
 
+// History is the point the client synced up to. The client without a history id yet may start from a
+// point in time, i.e. it gets the rows created or modified since, and all the deleted ones.
 type History struct {
-	Id           int64 `json:"historyId"`
-	IgnoreDevice bool  `json:"ignoreDevice"`
+	Id           int64      `json:"historyId"`
+	IgnoreDevice bool       `json:"ignoreDevice"`
+	Since        *Timestamp `json:"since,omitempty"`
+}
+
+// since returns the lower bound of the createdAt/modifiedAt of the synced rows, the history id wins over
+// the since timestamp.
+func (h *History) since() string {
+	if h.Id == 0 && h.Since != nil {
+		t := h.Since.Time()
+		return sqliteTimestamp(&t)
+	}
+
+	return "" // sorts before any timestamp
 }
 
 type Id struct {
@@ -64,6 +198,19 @@ type Ids struct {
 	Ids []string `json:"ids"`
 }
 
+type Count struct {
+	Count   int64 `json:"count"`
+	Trashed int64 `json:"trashed"`
+}
+
+// Usage is the storage taken by a resource, the trashed items take it until they are deleted.
+type Usage struct {
+	Count        int64 `json:"count"`
+	Trashed      int64 `json:"trashed"`
+	Bytes        int64 `json:"bytes"`
+	TrashedBytes int64 `json:"trashedBytes"`
+}
+
 type Folder struct {
 	Folder int `json:"folder"`
 }
@@ -75,41 +222,167 @@ type State struct {
 }
 
 type Repository struct {
-	Blobs    UseBlobRepository
-	Files    UseFileRepository
-	Session  UseSessionRepository
-	User     UseUserRepository
-	Contacts UseContactRepository
-	Drafts   UseDraftRepository
-	Messages UseMessageRepository
-	Threads  UseThreadRepository
+	Blobs         UseBlobRepository
+	Files         UseFileRepository
+	Session       UseSessionRepository
+	User          UseUserRepository
+	Contacts      UseContactRepository
+	Drafts        UseDraftRepository
+	Messages      UseMessageRepository
+	Labels        UseLabelRepository
+	Threads       UseThreadRepository
+	Search        UseSearchRepository
+	Trash         UseTrashRepository
+	ApiKeys       UseApiKeyRepository
+	Events        UseEventRepository
+	Contents      UseBlobContentRepository
+	Uploads       UseUploadRepository
+	Idempotency   UseIdempotencyRepository
+	Health        UseHealthRepository
+	Devices       UseDeviceRepository
+	RefreshTokens UseRefreshTokenRepository
+	AuditLog      UseAuditLogRepository
+	LoginAttempts UseLoginAttemptRepository
+	Tombstones    UseTombstoneRepository
+	Webhooks      UseWebhookRepository
+	SavedSearches UseSavedSearchRepository
+	Federation    UseFederationRepository
 }
 
 const SaltSize int = 32
 const KeySize int = 32
 const IvSize int = 16
 
+// Timeouts bound the queries of a repository call, the reads (e.g. List, Sync) and the writes.
+type Timeouts struct {
+	Read  time.Duration
+	Write time.Duration
+}
+
+func (t Timeouts) read() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), t.Read)
+}
+
+func (t Timeouts) write() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), t.Write)
+}
+
+// reader returns the database the read-only query of the user goes to, the replica unless there is
+// none, or the user reads the own writes, which the replica may lag behind.
+func reader(db *sql.DB, replica *sql.DB, user *User) *sql.DB {
+	if replica == nil || user.ReadPrimary {
+		return db
+	}
+
+	return replica
+}
+
 func NewRepository(db *sql.DB) Repository {
+	return NewRepositoryWithReplica(db, nil)
+}
+
+// NewRepositoryWithReplica routes the read-only queries of the lists, the syncs, the counts and the
+// searches to the replica, the rest goes to the primary db.
+func NewRepositoryWithReplica(db *sql.DB, replica *sql.DB) Repository {
+	return newRepository(db, replica, Timeouts{Read: config.ReadTimeout(), Write: config.WriteTimeout()})
+}
+
+func NewRepositoryWithTimeouts(db *sql.DB, timeouts Timeouts) Repository {
+	return newRepository(db, nil, timeouts)
+}
+
+func newRepository(db *sql.DB, replica *sql.DB, timeouts Timeouts) Repository {
 	return Repository{
-		Blobs:    &BlobRepository{db: db},
-		Files:    &FileRepository{db: db},
-		Session:  &SessionRepository{db: db},
-		User:     &UserRepository{db: db},
-		Contacts: &ContactRepository{db: db},
-		Drafts:   &DraftRepository{db: db},
-		Messages: &MessageRepository{db: db},
-		Threads:  &ThreadRepository{db: db},
+		Blobs:         &BlobRepository{db: db, replica: replica, timeouts: timeouts},
+		Files:         &FileRepository{db: db, replica: replica, timeouts: timeouts},
+		Session:       &SessionRepository{db: db, timeouts: timeouts},
+		User:          &UserRepository{db: db, timeouts: timeouts},
+		Contacts:      &ContactRepository{db: db, replica: replica, timeouts: timeouts},
+		Drafts:        &DraftRepository{db: db, replica: replica, timeouts: timeouts},
+		Messages:      &MessageRepository{db: db, replica: replica, timeouts: timeouts},
+		Labels:        &LabelRepository{db: db, replica: replica, timeouts: timeouts},
+		Threads:       &ThreadRepository{db: db, replica: replica, timeouts: timeouts},
+		Search:        &SearchRepository{db: db, timeouts: timeouts},
+		Trash:         &TrashRepository{db: db, timeouts: timeouts},
+		ApiKeys:       &ApiKeyRepository{db: db, timeouts: timeouts},
+		Events:        &EventRepository{db: db, replica: replica, timeouts: timeouts},
+		Contents:      &BlobContentRepository{db: db, timeouts: timeouts},
+		Uploads:       &UploadRepository{db: db, timeouts: timeouts},
+		Idempotency:   &IdempotencyRepository{db: db, timeouts: timeouts},
+		Health:        &HealthRepository{db: db, timeouts: timeouts},
+		Devices:       &DeviceRepository{db: db, timeouts: timeouts},
+		RefreshTokens: &RefreshTokenRepository{db: db, timeouts: timeouts},
+		AuditLog:      &AuditLogRepository{db: db, timeouts: timeouts},
+		LoginAttempts: &LoginAttemptRepository{db: db, timeouts: timeouts},
+		Tombstones:    &TombstoneRepository{db: db, timeouts: timeouts},
+		Webhooks:      &WebhookRepository{db: db, timeouts: timeouts},
+		SavedSearches: &SavedSearchRepository{db: db, timeouts: timeouts},
+		Federation:    &FederationRepository{db: db, timeouts: timeouts},
 	}
 }
 
+// Timestamp is the unix time in milliseconds, serialized as RFC3339 in UTC with millisecond precision.
 type Timestamp uint64
 
+const TimestampLayout = "2006-01-02T15:04:05.000Z07:00"
+
 func (p *Timestamp) Scan(value interface{}) error {
 	t := value.(time.Time).UnixMilli()
 	*p = Timestamp(t)
 	return nil
 }
 
+func (p Timestamp) Time() time.Time {
+	return time.UnixMilli(int64(p)).UTC()
+}
+
+func (p Timestamp) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + p.Time().Format(TimestampLayout) + `"`), nil
+}
+
+// UnmarshalJSON parses the RFC3339 timestamp in any offset, the unix milliseconds of the previous
+// format are accepted too.
+func (p *Timestamp) UnmarshalJSON(data []byte) error {
+	str := string(data)
+	if str == "null" {
+		return nil
+	}
+
+	unquoted, err := strconv.Unquote(str)
+	if err != nil {
+		unquoted = str
+	}
+
+	t, err := ParseTimestamp(unquoted)
+	if err != nil {
+		return err
+	}
+
+	*p = Timestamp(t.UnixMilli())
+
+	return nil
+}
+
+// ParseTimestamp parses the timestamp as RFC3339 or as the unix milliseconds.
+func ParseTimestamp(value string) (time.Time, error) {
+	var t time.Time
+
+	if millis, err := strconv.ParseInt(value, 10, 64); err == nil {
+		t = time.UnixMilli(millis)
+	} else {
+		t, err = time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return time.Time{}, ErrInvalidTimestamp
+		}
+	}
+
+	if t.UnixMilli() < 0 {
+		return time.Time{}, ErrInvalidTimestamp
+	}
+
+	return t.UTC(), nil
+}
+
 func getPrefixedDeviceId(userDeviceId *string) *string {
 	var deviceId string
 
@@ -120,3 +393,9 @@ func getPrefixedDeviceId(userDeviceId *string) *string {
 
 	return nil
 }
+
+// jsonIds selects the ids of the {"ids": [...]} parameter in the dialect of the database, for the IN of
+// the statements of the ids, e.g. of the Trash
+func jsonIds(param string) string {
+	return database.CurrentDialect().JsonIds(param)
+}
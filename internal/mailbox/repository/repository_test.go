@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"cargomail/internal/shared/database"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// newTestRepository returns the repository of a new database, removed after the test.
+func newTestRepository(t *testing.T) (Repository, *sql.DB) {
+	t.Helper()
+
+	db, err := database.Connect(filepath.Join(t.TempDir(), "cargomail.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	database.Init(db)
+
+	return NewRepositoryWithTimeouts(db, Timeouts{Read: 5 * time.Second, Write: 5 * time.Second}), db
+}
+
+// newTestUser creates the user logged in on a device, the password hash is not a bcrypt one.
+func newTestUser(t *testing.T, repository Repository, username string) *User {
+	t.Helper()
+
+	user := &User{Username: username}
+	user.Password.hash = []byte("-")
+
+	err := repository.User.Create(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deviceId := "0123456789abcdef0123456789abcdef"
+	user.DeviceId = &deviceId
+
+	return user
+}
+
+func TestTimestampJSON(t *testing.T) {
+	var scanned Timestamp
+
+	// the time read of the database is in the local zone of the driver
+	err := scanned.Scan(time.Date(2024, 1, 2, 11, 0, 0, 250e6, time.FixedZone("CET", 3600)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(struct {
+		CreatedAt  Timestamp  `json:"createdAt"`
+		ModifiedAt *Timestamp `json:"modifiedAt"`
+	}{scanned, nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"createdAt":"2024-01-02T10:00:00.250Z","modifiedAt":null}`
+	if string(data) != want {
+		t.Errorf("got %s, want %s", data, want)
+	}
+
+	// the offsets and the unix milliseconds of the previous format are accepted
+	for _, value := range []string{`"2024-01-02T10:00:00.250Z"`, `"2024-01-02T11:00:00.250+01:00"`, `1704189600250`} {
+		var parsed Timestamp
+
+		err = json.Unmarshal([]byte(value), &parsed)
+		if err != nil {
+			t.Errorf("%s: %v", value, err)
+			continue
+		}
+
+		if parsed != scanned {
+			t.Errorf("%s: got %d, want %d", value, parsed, scanned)
+		}
+	}
+
+	var invalid Timestamp
+
+	err = json.Unmarshal([]byte(`"2024-01-02"`), &invalid)
+	if !errors.Is(err, ErrInvalidTimestamp) {
+		t.Errorf("got %v, want %v", err, ErrInvalidTimestamp)
+	}
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"cargomail/internal/shared/config"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+const busyBackoff = 10 * time.Millisecond
+
+func isBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+	}
+
+	return false
+}
+
+// retryBusy runs the attempt again when the database is busy or locked, with the exponential backoff,
+// until the attempts run out or the context is done. The attempt must be safe to repeat, i.e. all its
+// statements run in one transaction which is rolled back on the error.
+func retryBusy(ctx context.Context, attempt func() error) error {
+	backoff := busyBackoff
+
+	for i := 1; ; i++ {
+		err := attempt()
+		if err == nil || !isBusy(err) || i >= config.BusyRetries() {
+			return err
+		}
+
+		timer := time.NewTimer(backoff)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		backoff *= 2
+	}
+}
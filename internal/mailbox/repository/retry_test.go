@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"cargomail/internal/shared/config"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+func TestRetryBusy(t *testing.T) {
+	busy := sqlite3.Error{Code: sqlite3.ErrBusy}
+	locked := fmt.Errorf("begin: %w", sqlite3.Error{Code: sqlite3.ErrLocked})
+	other := errors.New("constraint failed")
+
+	tests := []struct {
+		name     string
+		errs     []error // of the attempts in turn, the last one repeats
+		attempts int
+		err      error
+	}{
+		{"success", []error{nil}, 1, nil},
+		{"busy then success", []error{busy, locked, nil}, 3, nil},
+		{"other error", []error{other}, 1, other},
+		{"busy then other error", []error{busy, other}, 2, other},
+		{"always busy", []error{busy}, config.BusyRetries(), busy},
+	}
+
+	for _, tt := range tests {
+		attempts := 0
+
+		err := retryBusy(context.Background(), func() error {
+			err := tt.errs[len(tt.errs)-1]
+			if attempts < len(tt.errs) {
+				err = tt.errs[attempts]
+			}
+
+			attempts++
+			return err
+		})
+
+		if !errors.Is(err, tt.err) || attempts != tt.attempts {
+			t.Errorf("%s: got %v of %d attempts, want %v of %d", tt.name, err, attempts, tt.err, tt.attempts)
+		}
+	}
+}
+
+func TestRetryBusyContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	attempts := 0
+
+	err := retryBusy(ctx, func() error {
+		attempts++
+		return sqlite3.Error{Code: sqlite3.ErrBusy}
+	})
+
+	if !isBusy(err) || attempts != 1 {
+		t.Errorf("got %v of %d attempts, want the busy error of 1", err, attempts)
+	}
+}
@@ -0,0 +1,508 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"unicode"
+)
+
+type UseSavedSearchRepository interface {
+	Create(user *User, search *SavedSearch) (*SavedSearch, error)
+	Get(user *User, id string) (*SavedSearch, error)
+	List(user *User) (*SavedSearchList, error)
+	Update(user *User, search *SavedSearch) (*SavedSearch, error)
+	Delete(user *User, id string) error
+	Sync(user *User, history *History) (*SavedSearchSync, error)
+}
+
+// SavedSearchRepository keeps the searches the user runs again, e.g. "invoice" over the messages. They
+// have their own history, so they are synced across the devices.
+type SavedSearchRepository struct {
+	db       *sql.DB
+	timeouts Timeouts
+}
+
+const (
+	maxSavedSearchesPerUser = 100
+	maxSavedSearchQuery     = 500
+)
+
+// the resources a saved search may run on, the ones of the global search
+var savedSearchResources = map[string]bool{
+	"contacts": true,
+	"messages": true,
+	"drafts":   true,
+	"blobs":    true,
+}
+
+type SavedSearch struct {
+	Id         string       `json:"id"`
+	UserId     int64        `json:"-"`
+	Name       string       `json:"name"`
+	Query      string       `json:"query"`
+	Resources  ResourceList `json:"resources"`
+	CreatedAt  Timestamp    `json:"createdAt"`
+	ModifiedAt *Timestamp   `json:"modifiedAt"`
+	TimelineId int64        `json:"-"`
+	HistoryId  int64        `json:"-"`
+	LastStmt   int          `json:"-"`
+	DeviceId   *string      `json:"-"`
+}
+
+type SavedSearchDeleted struct {
+	Id        string  `json:"id"`
+	UserId    int64   `json:"-"`
+	HistoryId int64   `json:"-"`
+	DeviceId  *string `json:"-"`
+}
+
+type SavedSearchList struct {
+	History  int64          `json:"lastHistoryId"`
+	Searches []*SavedSearch `json:"searches"`
+}
+
+type SavedSearchSync struct {
+	History          int64                 `json:"lastHistoryId"`
+	NextPollAfter    int                   `json:"nextPollAfter"`
+	SearchesInserted []*SavedSearch        `json:"inserted"`
+	SearchesUpdated  []*SavedSearch        `json:"updated"`
+	SearchesDeleted  []*SavedSearchDeleted `json:"deleted"`
+}
+
+func (s *SavedSearch) Scan() []interface{} {
+	return scanColumns(s)
+}
+
+func (s *SavedSearchDeleted) Scan() []interface{} {
+	return scanColumns(s)
+}
+
+// validSavedSearch normalizes the name and the query of the search. The query is kept to the plain terms
+// the global search matches by, i.e. no control characters, and at least one term left once the FTS
+// quotes are dropped. It is only ever bound as a parameter of the search, never run as SQL.
+func validSavedSearch(search *SavedSearch) error {
+	search.Name = strings.TrimSpace(search.Name)
+
+	if len(search.Name) == 0 {
+		return ErrMissingNameField
+	}
+
+	if len(search.Name) > 255 {
+		return ErrSavedSearchWrongName
+	}
+
+	if strings.IndexFunc(search.Query, unicode.IsControl) >= 0 {
+		return ErrInvalidSearchQuery
+	}
+
+	search.Query = strings.Join(strings.Fields(search.Query), " ")
+
+	if len(search.Query) == 0 {
+		return ErrMissingSearchQuery
+	}
+
+	if len(search.Query) > maxSavedSearchQuery || len(matchQuery(search.Query)) == 0 {
+		return ErrInvalidSearchQuery
+	}
+
+	// an empty list of resources would make an useless search
+	if search.Resources != nil && len(search.Resources) == 0 {
+		return ErrUnknownResource
+	}
+
+	for _, resource := range search.Resources {
+		if !savedSearchResources[resource] {
+			return ErrUnknownResource
+		}
+	}
+
+	return nil
+}
+
+func getSavedSearch(ctx context.Context, tx *sql.Tx, user *User, id string) (*SavedSearch, error) {
+	query := `
+		SELECT *
+			FROM "SavedSearch"
+			WHERE "userId" = $1 AND
+			"id" = $2;`
+
+	search := &SavedSearch{}
+
+	err := tx.QueryRowContext(ctx, query, user.Id, id).Scan(search.Scan()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrSavedSearchNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return search, nil
+}
+
+func (r *SavedSearchRepository) Create(user *User, search *SavedSearch) (*SavedSearch, error) {
+	err := validSavedSearch(search)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT count(*)
+			FROM "SavedSearch"
+			WHERE "userId" = $1;`
+
+	var count int
+
+	err = tx.QueryRowContext(ctx, query, user.Id).Scan(&count)
+	if err != nil {
+		return nil, err
+	}
+
+	if count >= maxSavedSearchesPerUser {
+		return nil, ErrTooManySavedSearches
+	}
+
+	query = `
+		INSERT
+			INTO "SavedSearch" ("userId", "deviceId", "name", "query", "resources")
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING "id";`
+
+	args := []interface{}{user.Id, user.DeviceId, search.Name, search.Query, search.Resources}
+
+	var id string
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&id)
+	if err != nil {
+		switch {
+		case strings.HasPrefix(err.Error(), `UNIQUE constraint failed: SavedSearch.`):
+			return nil, ErrDuplicateSavedSearch
+		default:
+			return nil, err
+		}
+	}
+
+	// the history is set by the insert trigger
+	search, err = getSavedSearch(ctx, tx, user, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return search, nil
+}
+
+func (r *SavedSearchRepository) Get(user *User, id string) (*SavedSearch, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	search, err := getSavedSearch(ctx, tx, user, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return search, nil
+}
+
+func (r *SavedSearchRepository) List(user *User) (*SavedSearchList, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT *
+			FROM "SavedSearch"
+			WHERE "userId" = $1
+			ORDER BY "name" COLLATE NOCASE;`
+
+	rows, err := tx.QueryContext(ctx, query, user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	searchList := &SavedSearchList{
+		Searches: []*SavedSearch{},
+	}
+
+	for rows.Next() {
+		var search SavedSearch
+
+		err := rows.Scan(search.Scan()...)
+		if err != nil {
+			return nil, err
+		}
+
+		searchList.Searches = append(searchList.Searches, &search)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// history
+	query = `
+		SELECT "lastHistoryId"
+			FROM "SavedSearchHistorySeq"
+			WHERE "userId" = $1 ;`
+
+	err = tx.QueryRowContext(ctx, query, user.Id).Scan(&searchList.History)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return searchList, nil
+}
+
+// Update replaces the name, the query and the resources of the search.
+func (r *SavedSearchRepository) Update(user *User, search *SavedSearch) (*SavedSearch, error) {
+	err := validSavedSearch(search)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE "SavedSearch"
+			SET "name" = $1,
+				"query" = $2,
+				"resources" = $3,
+				"deviceId" = $4
+			WHERE "userId" = $5 AND
+			"id" = $6;`
+
+	args := []interface{}{search.Name, search.Query, search.Resources, user.DeviceId, user.Id, search.Id}
+
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		switch {
+		case strings.HasPrefix(err.Error(), `UNIQUE constraint failed: SavedSearch.`):
+			return nil, ErrDuplicateSavedSearch
+		default:
+			return nil, err
+		}
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if affected == 0 {
+		return nil, ErrSavedSearchNotFound
+	}
+
+	// the history is set by the update trigger
+	search, err = getSavedSearch(ctx, tx, user, search.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return search, nil
+}
+
+func (r *SavedSearchRepository) Delete(user *User, id string) error {
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		DELETE
+			FROM "SavedSearch"
+			WHERE "userId" = $1 AND
+			"id" = $2;`
+
+	result, err := tx.ExecContext(ctx, query, user.Id, id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrSavedSearchNotFound
+	}
+
+	query = `
+		UPDATE "SavedSearchDeleted"
+			SET "deviceId" = $1
+			WHERE "userId" = $2 AND
+			"id" = $3;`
+
+	_, err = tx.ExecContext(ctx, query, user.DeviceId, user.Id, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *SavedSearchRepository) Sync(user *User, history *History) (*SavedSearchSync, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var deviceId string
+
+	if !history.IgnoreDevice {
+		deviceId = *user.DeviceId
+	}
+
+	searchSync := &SavedSearchSync{
+		SearchesInserted: []*SavedSearch{},
+		SearchesUpdated:  []*SavedSearch{},
+		SearchesDeleted:  []*SavedSearchDeleted{},
+	}
+
+	// inserted and updated rows
+	query := `
+		SELECT *
+			FROM "SavedSearch"
+			WHERE "userId" = $1 AND
+				("deviceId" <> $2 OR "deviceId" IS NULL) AND
+				"historyId" > $3 AND
+				coalesce("modifiedAt", "createdAt") >= $4
+			ORDER BY "createdAt" DESC;`
+
+	args := []interface{}{user.Id, deviceId, history.Id, history.since()}
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var search SavedSearch
+
+		err := rows.Scan(search.Scan()...)
+		if err != nil {
+			return nil, err
+		}
+
+		if search.LastStmt == 0 {
+			searchSync.SearchesInserted = append(searchSync.SearchesInserted, &search)
+		} else {
+			searchSync.SearchesUpdated = append(searchSync.SearchesUpdated, &search)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// deleted rows
+	query = `
+		SELECT *
+			FROM "SavedSearchDeleted"
+			WHERE "userId" = $1 AND
+			("deviceId" <> $2 OR "deviceId" IS NULL) AND
+			"historyId" > $3;`
+
+	args = []interface{}{user.Id, deviceId, history.Id}
+
+	rows, err = tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var searchDeleted SavedSearchDeleted
+
+		err := rows.Scan(searchDeleted.Scan()...)
+		if err != nil {
+			return nil, err
+		}
+
+		searchSync.SearchesDeleted = append(searchSync.SearchesDeleted, &searchDeleted)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// history
+	query = `
+		SELECT "lastHistoryId"
+			FROM "SavedSearchHistorySeq"
+			WHERE "userId" = $1 ;`
+
+	err = tx.QueryRowContext(ctx, query, user.Id).Scan(&searchSync.History)
+	if err != nil {
+		return nil, err
+	}
+
+	searchSync.NextPollAfter, err = nextPollAfter(ctx, tx, "SavedSearch", user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return searchSync, nil
+}
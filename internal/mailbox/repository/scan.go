@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"reflect"
+	"sync"
+	"unsafe"
+)
+
+// scanField is the offset of a struct field and the type word of the pointer to it, so the column
+// address is made without the reflection on every row.
+type scanField struct {
+	ptrType unsafe.Pointer
+	offset  uintptr
+}
+
+// eface is the layout of the empty interface
+type eface struct {
+	typ  unsafe.Pointer
+	data unsafe.Pointer
+}
+
+var scanLayouts sync.Map // reflect.Type -> []scanField
+
+func scanLayout(t reflect.Type) []scanField {
+	if layout, ok := scanLayouts.Load(t); ok {
+		return layout.([]scanField)
+	}
+
+	layout := make([]scanField, t.NumField())
+	for i := range layout {
+		field := t.Field(i)
+		ptr := reflect.Zero(reflect.PointerTo(field.Type)).Interface()
+		layout[i] = scanField{ptrType: (*eface)(unsafe.Pointer(&ptr)).typ, offset: field.Offset}
+	}
+
+	scanLayouts.Store(t, layout)
+
+	return layout
+}
+
+// scanColumns returns the addresses of the fields of the struct v points to, in the order the fields
+// are declared. The layout of the fields is computed once per type.
+func scanColumns(v interface{}) []interface{} {
+	e := (*eface)(unsafe.Pointer(&v))
+	layout := scanLayout(reflect.TypeOf(v).Elem())
+
+	columns := make([]interface{}, len(layout))
+	for i, field := range layout {
+		column := (*eface)(unsafe.Pointer(&columns[i]))
+		column.typ = field.ptrType
+		column.data = unsafe.Add(e.data, field.offset)
+	}
+	return columns
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"reflect"
+	"testing"
+)
+
+// reflectColumns returns the addresses of the fields as the Scan methods did before the layout cache.
+func reflectColumns(v interface{}) []interface{} {
+	s := reflect.ValueOf(v).Elem()
+	columns := make([]interface{}, s.NumField())
+	for i := range columns {
+		columns[i] = s.Field(i).Addr().Interface()
+	}
+	return columns
+}
+
+func TestScanColumns(t *testing.T) {
+	for _, v := range []interface{}{&Draft{}, &Message{}, &Contact{}, &Blob{}, &ApiKey{}, &DraftDeleted{}} {
+		// twice, the second time of the cached layout
+		for i := 0; i < 2; i++ {
+			got := scanColumns(v)
+			want := reflectColumns(v)
+
+			if len(got) != len(want) {
+				t.Fatalf("%T: got %d columns, want %d", v, len(got), len(want))
+			}
+
+			for j := range got {
+				if got[j] != want[j] {
+					t.Errorf("%T: got the column %d of %T at %p, want %T at %p", v, j, got[j], got[j], want[j], want[j])
+				}
+			}
+		}
+	}
+}
+
+func TestScanColumnsWrite(t *testing.T) {
+	var draft Draft
+
+	columns := draft.Scan()
+
+	*columns[0].(*string) = "0123456789abcdef0123456789abcdef"
+	*columns[2].(*bool) = true
+
+	if draft.Id != "0123456789abcdef0123456789abcdef" || !draft.Unread {
+		t.Errorf("got the draft %+v, the columns don't point to its fields", draft)
+	}
+}
+
+func BenchmarkScanColumns(b *testing.B) {
+	var draft Draft
+
+	for i := 0; i < b.N; i++ {
+		scanColumns(&draft)
+	}
+}
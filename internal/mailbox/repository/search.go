@@ -0,0 +1,285 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+type UseSearchRepository interface {
+	Reindex(index string, batchSize int, progress func(done, total int64)) (int64, error)
+	Staleness(index string) (*SearchIndexStaleness, error)
+}
+
+type SearchRepository struct {
+	db       *sql.DB
+	timeouts Timeouts
+}
+
+type SearchIndexStaleness struct {
+	Index    string `json:"index"`
+	Rows     int64  `json:"rows"`
+	Indexed  int64  `json:"indexed"`
+	Missing  int64  `json:"missing"`
+	Orphaned int64  `json:"orphaned"`
+	Outdated int64  `json:"outdated"`
+}
+
+func (s *SearchIndexStaleness) Stale() bool {
+	return s.Missing > 0 || s.Orphaned > 0 || s.Outdated > 0
+}
+
+type searchIndex struct {
+	source string
+	table  string
+	text   string
+}
+
+// the text expressions must match the ones used by the search triggers
+var searchIndexes = map[string]searchIndex{
+	"contacts": {
+		source: "Contact",
+		table:  "ContactSearch",
+		text: `coalesce("emailAddress", '') || ' ' || coalesce("firstName", '') || ' ' || coalesce("lastName", '') || ' ' ||
+			coalesce((SELECT group_concat("value"->>'address', ' ') FROM json_each("emailAddresses")), '') || ' ' ||
+			coalesce("notes", '')`,
+	},
+	"messages": {
+		source: "Message",
+		table:  "MessageSearch",
+		text: `coalesce("payload"->>'$.headers.Subject', '') || ' ' || coalesce("payload"->>'$.headers.From', '') || ' ' ||
+			coalesce("payload"->>'$.headers.To', '') || ' ' || coalesce("payload"->>'$.headers.Cc', '')`,
+	},
+	"drafts": {
+		source: "Draft",
+		table:  "DraftSearch",
+		text: `coalesce("payload"->>'$.headers.Subject', '') || ' ' || coalesce("payload"->>'$.headers.To', '') || ' ' ||
+			coalesce("payload"->>'$.headers.Cc', '') || ' ' || coalesce("searchText", '')`,
+	},
+}
+
+// matchQuery turns the user input into a FTS query of prefix terms, the FTS operators are not passed through
+func matchQuery(q string) string {
+	terms := []string{}
+
+	for _, term := range strings.Fields(q) {
+		term = strings.ReplaceAll(term, `"`, "")
+		if len(term) > 0 {
+			terms = append(terms, `"`+term+`*"`)
+		}
+	}
+
+	return strings.Join(terms, " ")
+}
+
+// searchRank is the relevance of the full-text match of the index row, i.e. the number of the term
+// matches (offsets lists four numbers per match)
+func searchRank(table string) string {
+	return `((length(offsets("` + table + `")) - length(replace(offsets("` + table + `"), ' ', '')) + 1) / 4)`
+}
+
+// likeTerms adds a case-insensitive LIKE condition per term of the user input, each term must be found
+// in one of the columns. It returns false when the input has no terms.
+func likeTerms(q *listQuery, input string, columns ...string) bool {
+	terms := strings.Fields(input)
+
+	for _, term := range terms {
+		term = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(term)
+		placeholder := q.arg("%" + term + "%")
+
+		conditions := []string{}
+		for _, column := range columns {
+			conditions = append(conditions, column+` LIKE `+placeholder+` ESCAPE '\'`)
+		}
+
+		q.and("(" + strings.Join(conditions, " OR ") + ")")
+	}
+
+	return len(terms) > 0
+}
+
+// Reindex rebuilds the search index from the source rows in batches, each in its own short transaction,
+// so the readers are never blocked for long. The index rows are keyed by the "rowid" of the source row
+// (VACUUM may renumber them, which is one of the reasons to reindex).
+func (r *SearchRepository) Reindex(index string, batchSize int, progress func(done, total int64)) (int64, error) {
+	searchIndex, ok := searchIndexes[index]
+	if !ok {
+		return 0, ErrUnknownSearchIndex
+	}
+
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+
+	var total int64
+
+	err := func() error {
+		ctx, cancel := r.timeouts.write()
+		defer cancel()
+
+		query := `SELECT COUNT(*) FROM "` + searchIndex.source + `";`
+
+		return r.db.QueryRowContext(ctx, query).Scan(&total)
+	}()
+	if err != nil {
+		return 0, err
+	}
+
+	var done, lastRowId int64
+
+	for {
+		rows, nextRowId, err := r.reindexBatch(searchIndex, lastRowId, batchSize)
+		if err != nil {
+			return done, err
+		}
+
+		if rows == 0 {
+			break
+		}
+
+		done += rows
+		lastRowId = nextRowId
+
+		if progress != nil {
+			progress(done, total)
+		}
+	}
+
+	// orphaned rows above the last source row
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	query := `
+		DELETE
+			FROM "` + searchIndex.table + `"
+			WHERE "docid" > $1;`
+
+	args := []interface{}{lastRowId}
+
+	_, err = r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return done, err
+	}
+
+	return done, nil
+}
+
+func (r *SearchRepository) reindexBatch(searchIndex searchIndex, lastRowId int64, batchSize int) (int64, int64, error) {
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT COUNT(*), coalesce(MAX("rowid"), 0)
+			FROM (SELECT "rowid"
+				FROM "` + searchIndex.source + `"
+				WHERE "rowid" > $1
+				ORDER BY "rowid"
+				LIMIT $2);`
+
+	args := []interface{}{lastRowId, batchSize}
+
+	var rows, nextRowId int64
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&rows, &nextRowId)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if rows == 0 {
+		return 0, lastRowId, nil
+	}
+
+	query = `
+		DELETE
+			FROM "` + searchIndex.table + `"
+			WHERE "docid" > $1 AND
+			"docid" <= $2;`
+
+	args = []interface{}{lastRowId, nextRowId}
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	query = `
+		INSERT
+			INTO "` + searchIndex.table + `" ("docid", "id", "userId", "text")
+			SELECT "rowid", "id", "userId", ` + searchIndex.text + `
+				FROM "` + searchIndex.source + `"
+				WHERE "rowid" > $1 AND
+				"rowid" <= $2;`
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+
+	return rows, nextRowId, nil
+}
+
+func (r *SearchRepository) Staleness(index string) (*SearchIndexStaleness, error) {
+	searchIndex, ok := searchIndexes[index]
+	if !ok {
+		return nil, ErrUnknownSearchIndex
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	staleness := &SearchIndexStaleness{
+		Index: index,
+	}
+
+	query := `
+		SELECT
+			(SELECT COUNT(*) FROM "` + searchIndex.source + `"),
+			(SELECT COUNT(*) FROM "` + searchIndex.table + `"),
+			(SELECT COUNT(*)
+				FROM "` + searchIndex.source + `" AS "s"
+				WHERE NOT EXISTS (SELECT 1 FROM "` + searchIndex.table + `" WHERE "docid" = "s"."rowid")),
+			(SELECT COUNT(*)
+				FROM "` + searchIndex.table + `"
+				WHERE "docid" NOT IN (SELECT "rowid" FROM "` + searchIndex.source + `")),
+			(SELECT COUNT(*)
+				FROM "` + searchIndex.source + `" AS "s"
+				INNER JOIN "` + searchIndex.table + `" AS "i"
+				ON "i"."docid" = "s"."rowid"
+				WHERE "i"."id" <> "s"."id" OR
+				"i"."userId" <> "s"."userId" OR
+				"i"."text" <> (` + searchIndex.text + `));`
+
+	err = tx.QueryRowContext(ctx, query).Scan(
+		&staleness.Rows,
+		&staleness.Indexed,
+		&staleness.Missing,
+		&staleness.Orphaned,
+		&staleness.Outdated,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return staleness, nil
+}
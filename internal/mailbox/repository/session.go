@@ -1,7 +1,6 @@
 package repository
 
 import (
-	"context"
 	"database/sql"
 	"errors"
 	"time"
@@ -12,6 +11,8 @@ type UseSessionRepository interface {
 	Insert(session *Session) error
 	UpdateIfOlderThan5Minutes(user *User, id string, expiry time.Time) (bool, error)
 	Remove(user *User, id string) error
+	RemoveAll(user *User) error
+	RemoveOthers(user *User, id string) error
 }
 
 const (
@@ -20,7 +21,8 @@ const (
 )
 
 type SessionRepository struct {
-	db *sql.DB
+	db       *sql.DB
+	timeouts Timeouts
 }
 
 type Session struct {
@@ -28,6 +30,10 @@ type Session struct {
 	UserID int64     `json:"-"`
 	Expiry time.Time `json:"expiry"`
 	Scope  string    `json:"-"`
+	// DeviceId is the device logged in with the session, the session is removed when the device is revoked.
+	DeviceId *string `json:"-"`
+	// Resync tells the device to drop its local state and sync from scratch.
+	Resync bool `json:"resync,omitempty"`
 }
 
 func generateSession(userID int64, ttl time.Duration, scope string) (*Session, error) {
@@ -51,15 +57,15 @@ func (r SessionRepository) New(userID int64, ttl time.Duration, scope string) (*
 }
 
 func (r SessionRepository) Insert(session *Session) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := r.timeouts.write()
 	defer cancel()
 
 	query := `
-		INSERT INTO "Session" ("userId", "expiry", "scope")
-			VALUES ($1, $2, $3)
+		INSERT INTO "Session" ("userId", "expiry", "scope", "deviceId")
+			VALUES ($1, $2, $3, $4)
 			RETURNING id ;`
 
-	args := []interface{}{session.UserID, session.Expiry, session.Scope}
+	args := []interface{}{session.UserID, session.Expiry, session.Scope, session.DeviceId}
 
 	err := r.db.QueryRowContext(ctx, query, args...).Scan(&session.Id)
 	if err != nil {
@@ -70,7 +76,7 @@ func (r SessionRepository) Insert(session *Session) error {
 }
 
 func (r SessionRepository) UpdateIfOlderThan5Minutes(user *User, id string, expiry time.Time) (bool, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := r.timeouts.write()
 	defer cancel()
 
 	// 300 = 5 minutes
@@ -98,7 +104,7 @@ func (r SessionRepository) UpdateIfOlderThan5Minutes(user *User, id string, expi
 }
 
 func (r SessionRepository) Remove(user *User, id string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := r.timeouts.write()
 	defer cancel()
 
 	query := `
@@ -111,3 +117,50 @@ func (r SessionRepository) Remove(user *User, id string) error {
 	_, err := r.db.ExecContext(ctx, query, args...)
 	return err
 }
+
+// RemoveAll logs the user out on all the devices, the current one included, the refresh tokens are
+// revoked too.
+func (r SessionRepository) RemoveAll(user *User) error {
+	return r.RemoveOthers(user, "")
+}
+
+// RemoveOthers logs the user out on all the devices but the one of the session, the refresh tokens of
+// the other devices are revoked too.
+func (r SessionRepository) RemoveOthers(user *User, id string) error {
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// no session, as of the RemoveAll, keeps no device, the tokens of all the devices are revoked
+	query := `
+		UPDATE "RefreshToken"
+			SET "revokedAt" = CURRENT_TIMESTAMP
+			WHERE "userId" = $1 AND
+			"revokedAt" IS NULL AND
+			"deviceId" IS NOT (SELECT "deviceId" FROM "Session" WHERE "userId" = $1 AND "id" = $2);`
+
+	_, err = tx.ExecContext(ctx, query, user.Id, id)
+	if err != nil {
+		return err
+	}
+
+	query = `
+		DELETE FROM "Session"
+			WHERE "userId" = $1 AND
+			"scope" = $2 AND
+			"id" != $3;`
+
+	args := []interface{}{user.Id, ScopeAuthentication, id}
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
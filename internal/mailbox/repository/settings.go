@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"unicode/utf8"
+)
+
+// Settings are the UI settings the clients sync, e.g. the theme. The history id grows with each update,
+// so a client tells the settings changed on another device.
+type Settings struct {
+	Settings  map[string]json.RawMessage `json:"settings"`
+	HistoryId int64                      `json:"historyId"`
+}
+
+const maxSignatureLength = 10000
+
+// settingsSchema is the allowlist of the settings keys, with the validation of their values.
+var settingsSchema = map[string]func(value json.RawMessage) bool{
+	"theme":       oneOf("light", "dark", "system"),
+	"listDensity": oneOf("compact", "comfortable", "spacious"),
+	"signature":   stringOfLength(maxSignatureLength),
+	"language":    stringOfLength(35), // BCP 47 tag
+}
+
+func oneOf(values ...string) func(value json.RawMessage) bool {
+	return func(value json.RawMessage) bool {
+		var s string
+
+		if json.Unmarshal(value, &s) != nil {
+			return false
+		}
+
+		for _, v := range values {
+			if s == v {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+func stringOfLength(max int) func(value json.RawMessage) bool {
+	return func(value json.RawMessage) bool {
+		var s string
+
+		if json.Unmarshal(value, &s) != nil {
+			return false
+		}
+
+		return utf8.RuneCountInString(s) <= max
+	}
+}
+
+// validSettingsPatch tells whether the patch sets the known keys to the valid values, the null removes
+// the key.
+func validSettingsPatch(patch map[string]json.RawMessage) bool {
+	for key, value := range patch {
+		valid, ok := settingsSchema[key]
+		if !ok {
+			return false
+		}
+
+		if string(value) != "null" && !valid(value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r UserRepository) GetSettings(user *User) (*Settings, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	query := `
+		SELECT coalesce("settings", '{}'), "lastHistoryId"
+			FROM "User" INNER JOIN "SettingsHistorySeq" ON "SettingsHistorySeq"."userId" = "User"."id"
+			WHERE "id" = $1;`
+
+	var settings string
+
+	result := &Settings{}
+
+	err := r.db.QueryRowContext(ctx, query, user.Id).Scan(&settings, &result.HistoryId)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrUsernameNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	err = json.Unmarshal([]byte(settings), &result.Settings)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// UpdateSettings merges the patch into the settings, as the JSON merge patch does.
+func (r UserRepository) UpdateSettings(user *User, patch map[string]json.RawMessage) (*Settings, error) {
+	if !validSettingsPatch(patch) {
+		return nil, ErrInvalidSetting
+	}
+
+	if len(patch) == 0 {
+		return r.GetSettings(user)
+	}
+
+	b, err := json.Marshal(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE "User"
+			SET "settings" = json_patch(coalesce("settings", '{}'), $1)
+			WHERE "id" = $2
+			RETURNING "settings";`
+
+	var settings string
+
+	err = tx.QueryRowContext(ctx, query, string(b), user.Id).Scan(&settings)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrUsernameNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	result := &Settings{}
+
+	err = json.Unmarshal([]byte(settings), &result.Settings)
+	if err != nil {
+		return nil, err
+	}
+
+	query = `
+		SELECT "lastHistoryId"
+			FROM "SettingsHistorySeq"
+			WHERE "userId" = $1;`
+
+	err = tx.QueryRowContext(ctx, query, user.Id).Scan(&result.HistoryId)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, tx.Commit()
+}
@@ -0,0 +1,199 @@
+package repository
+
+import (
+	b64 "encoding/base64"
+	"html"
+	"mime"
+	"regexp"
+	"strings"
+)
+
+var (
+	matchHtmlComment = regexp.MustCompile(`(?s)<!--.*?-->`)
+	matchHtmlBlock   = regexp.MustCompile(`(?is)<(script|style|head)\b.*?</(script|style|head)\s*>`)
+	matchHtmlTag     = regexp.MustCompile(`(?s)<[^>]*>`)
+)
+
+// Snippet returns a short plain-text preview of the message body. When the body is multipart/alternative,
+// the text/html part is preferred if htmlFirst is set, the text/plain part otherwise; the other one is
+// the fallback.
+func (p *MessagePart) Snippet(htmlFirst bool, length int) string {
+	if p == nil {
+		return ""
+	}
+
+	return snippetOf(p.snippetText(htmlFirst), length)
+}
+
+// ContentSnippet returns a short plain-text preview of the head of a text blob, or nil for the other
+// content types. The head may end in the middle of a character, which is dropped.
+func ContentSnippet(contentType string, head []byte, length int) *string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil
+	}
+
+	text := strings.ToValidUTF8(string(head), "")
+
+	switch {
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		// the head may end in the middle of a tag
+		if i := strings.LastIndex(text, "<"); i > strings.LastIndex(text, ">") {
+			text = text[:i]
+		}
+		text = stripHtml(text)
+	case strings.HasPrefix(mediaType, "text/"):
+	default:
+		return nil
+	}
+
+	snippet := snippetOf(text, length)
+	if len(snippet) == 0 {
+		return nil
+	}
+
+	return &snippet
+}
+
+// snippetOf collapses the whitespace of the text and cuts it to the length.
+func snippetOf(text string, length int) string {
+	text = strings.Join(strings.Fields(text), " ")
+
+	runes := []rune(text)
+	if len(runes) > length {
+		text = strings.TrimSpace(string(runes[:length]))
+	}
+
+	return text
+}
+
+func (p *MessagePart) snippetText(htmlFirst bool) string {
+	mediaType := p.mediaType()
+
+	switch {
+	case mediaType == "multipart/alternative":
+		preferred, fallback := "text/plain", "text/html"
+		if htmlFirst {
+			preferred, fallback = fallback, preferred
+		}
+
+		for _, mediaType := range []string{preferred, fallback} {
+			for _, part := range p.Parts {
+				if text := part.textOf(mediaType); len(text) > 0 {
+					return text
+				}
+			}
+		}
+	case strings.HasPrefix(mediaType, "multipart/"):
+		for _, part := range p.Parts {
+			if text := part.snippetText(htmlFirst); len(text) > 0 {
+				return text
+			}
+		}
+	case mediaType == "text/plain" || mediaType == "text/html":
+		return p.textOf(mediaType)
+	}
+
+	return ""
+}
+
+// Text returns the plain text of the whole body, e.g. for the search index. Of the multipart/alternative
+// parts, only one is taken.
+func (p *MessagePart) Text() string {
+	if p == nil {
+		return ""
+	}
+
+	mediaType := p.mediaType()
+
+	switch {
+	case mediaType == "multipart/alternative":
+		return strings.Join(strings.Fields(p.snippetText(false)), " ")
+	case strings.HasPrefix(mediaType, "multipart/"):
+		texts := []string{}
+		for _, part := range p.Parts {
+			if text := part.Text(); len(text) > 0 {
+				texts = append(texts, text)
+			}
+		}
+		return strings.Join(texts, " ")
+	case mediaType == "text/plain" || mediaType == "text/html":
+		return strings.Join(strings.Fields(p.textOf(mediaType)), " ")
+	}
+
+	return ""
+}
+
+// textOf returns the decoded text of the first part of the given media type.
+func (p *MessagePart) textOf(mediaType string) string {
+	if p == nil {
+		return ""
+	}
+
+	partMediaType := p.mediaType()
+
+	if strings.HasPrefix(partMediaType, "multipart/") {
+		for _, part := range p.Parts {
+			if text := part.textOf(mediaType); len(text) > 0 {
+				return text
+			}
+		}
+		return ""
+	}
+
+	if partMediaType != mediaType || p.Body == nil {
+		return ""
+	}
+
+	// attachments do not make a snippet
+	if contentDisposition, _ := p.Headers["Content-Disposition"].(string); strings.HasPrefix(contentDisposition, "attachment") {
+		return ""
+	}
+
+	data := p.Body.Data
+
+	if contentTransferEncoding, _ := p.Headers["Content-Transfer-Encoding"].(string); strings.EqualFold(contentTransferEncoding, "base64") {
+		decoded, err := b64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return ""
+		}
+		data = string(decoded)
+	}
+
+	if mediaType == "text/html" {
+		data = stripHtml(data)
+	}
+
+	return data
+}
+
+func (p *MessagePart) mediaType() string {
+	contentType, ok := p.Headers["Content-Type"].(string)
+	if !ok {
+		// the placeholders of the external bodies have a list of content types
+		if _, ok := p.Headers["Content-Type"]; ok {
+			return ""
+		}
+
+		if len(p.Parts) > 0 {
+			return "multipart/mixed"
+		}
+
+		return "text/plain"
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+
+	return mediaType
+}
+
+func stripHtml(s string) string {
+	s = matchHtmlComment.ReplaceAllString(s, " ")
+	s = matchHtmlBlock.ReplaceAllString(s, " ")
+	s = matchHtmlTag.ReplaceAllString(s, " ")
+
+	return html.UnescapeString(s)
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"cargomail/internal/shared/config"
+	"encoding/json"
+	"testing"
+	"unicode/utf8"
+)
+
+func textPart(contentType, data string) *MessagePart {
+	return &MessagePart{Headers: map[string]interface{}{"Content-Type": contentType}, Body: &Body{Data: data}}
+}
+
+func multipartOf(contentType string, parts ...*MessagePart) *MessagePart {
+	return &MessagePart{Headers: map[string]interface{}{"Content-Type": contentType}, Parts: parts}
+}
+
+func TestSnippet(t *testing.T) {
+	attachment := textPart("text/plain", "the attached notes")
+	attachment.Headers["Content-Disposition"] = `attachment; filename="notes.txt"`
+
+	alternative := multipartOf("multipart/alternative",
+		textPart("text/html; charset=utf-8", "<p>Hello <b>Bob</b>, the <i>html</i> part</p>"),
+		textPart("text/plain; charset=utf-8", "Hello Bob,\r\n  the plain part"),
+	)
+
+	tests := []struct {
+		name          string
+		payload       *MessagePart
+		snippetSource string
+		snippetLength string
+		snippet       string
+	}{
+		{"text/plain preferred", alternative, "", "", "Hello Bob, the plain part"},
+		{"text/html preferred", alternative, "html-first", "", "Hello Bob , the html part"},
+		{"html only", textPart("text/html", "<html><head><title>Minutes</title><style>p { color: red; }</style></head><body><!-- hidden --><p>Tom &amp; Jerry</p></body></html>"), "", "", "Tom & Jerry"},
+		{"html fallback", multipartOf("multipart/alternative", textPart("text/plain", ""), textPart("text/html", "<div>Only&nbsp;html</div>")), "", "", "Only html"},
+		{"nested multipart", multipartOf("multipart/mixed", attachment, multipartOf("multipart/related", alternative, textPart("image/png", "iVBORw0KGgo="))), "", "", "Hello Bob, the plain part"},
+		{"attachment only", multipartOf("multipart/mixed", attachment), "", "", ""},
+		{"truncated at a rune boundary", textPart("text/plain", "Příliš žluťoučký kůň"), "", "9", "Příliš žl"},
+		{"truncated before the whitespace", textPart("text/plain", "Ahoj   světe"), "", "5", "Ahoj"},
+		{"no payload", nil, "", "", ""},
+	}
+
+	snippetSource, snippetLength := config.Configuration.SnippetSource, config.Configuration.SnippetLength
+	t.Cleanup(func() {
+		config.Configuration.SnippetSource, config.Configuration.SnippetLength = snippetSource, snippetLength
+	})
+
+	for _, tt := range tests {
+		config.Configuration.SnippetSource, config.Configuration.SnippetLength = tt.snippetSource, tt.snippetLength
+
+		// the drafts and the messages are of the same snippet
+		for _, v := range []interface{}{Draft{Payload: tt.payload}, Message{Payload: tt.payload}} {
+			data, err := json.Marshal(v)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var snippet struct {
+				Snippet string `json:"snippet"`
+			}
+
+			err = json.Unmarshal(data, &snippet)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if snippet.Snippet != tt.snippet {
+				t.Errorf("%s: got the %T snippet %q, want %q", tt.name, v, snippet.Snippet, tt.snippet)
+			}
+
+			if !utf8.ValidString(snippet.Snippet) {
+				t.Errorf("%s: got the invalid UTF-8 snippet %q", tt.name, snippet.Snippet)
+			}
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package repository
+
+import ()
+
+// ThreadSummary is one row of the conversation view, i.e. the latest message of the thread with the
+// number of the messages and the drafts in it. The historyId is the last change of the messages of the
+// thread, so the client refetches only the threads that changed.
+type ThreadSummary struct {
+	ThreadUid  string   `json:"threadUid"`
+	Count      int      `json:"count"`
+	DraftCount int      `json:"draftCount"`
+	Unread     bool     `json:"unread"`
+	HistoryId  int64    `json:"historyId"`
+	Latest     *Message `json:"latest"`
+}
+
+type ThreadSummaryList struct {
+	History int64            `json:"lastHistoryId"`
+	Threads []*ThreadSummary `json:"threads"`
+}
+
+// ThreadView holds the messages and the drafts of the thread, both in chronological order.
+type ThreadView struct {
+	ThreadUid string     `json:"threadUid"`
+	History   int64      `json:"lastHistoryId"`
+	Messages  []*Message `json:"messages"`
+	Drafts    []*Draft   `json:"drafts"`
+}
+
+// ListThreads lists one summary per thread, the most recently active thread first.
+func (r *ThreadRepository) ListThreads(user *User) (*ThreadSummaryList, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	tx, err := reader(r.db, r.replica, user).BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT *,
+			(SELECT count(*)
+				FROM "Draft"
+				WHERE "Draft"."userId" = $1 AND
+				"Draft"."payload"->>'$.headers.X-Thread-ID' = "threadUid" AND
+				"Draft"."lastStmt" < 2) AS "draftCount"
+			FROM (SELECT *,
+				"payload"->>'$.headers.X-Thread-ID' AS "threadUid",
+				count(*) OVER "thread" AS "count",
+				max("unread") OVER "thread" AS "threadUnread",
+				max("historyId") OVER "thread" AS "threadHistoryId",
+				row_number() OVER ("thread" ORDER BY coalesce("receivedAt", "sentAt", "createdAt") DESC, "rowid" DESC) AS "rank"
+				FROM "Message"
+				WHERE "userId" = $1 AND
+				"lastStmt" < 2 AND
+				"payload"->>'$.headers.X-Thread-ID' IS NOT NULL
+				WINDOW "thread" AS (PARTITION BY "payload"->>'$.headers.X-Thread-ID'))
+			WHERE "rank" = 1
+			ORDER BY coalesce("receivedAt", "sentAt", "createdAt") DESC, "rowid" DESC;`
+
+	args := []interface{}{user.Id}
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	threadList := &ThreadSummaryList{
+		Threads: []*ThreadSummary{},
+	}
+
+	for rows.Next() {
+		thread := &ThreadSummary{Latest: &Message{}}
+
+		var rank int
+
+		columns := append(thread.Latest.Scan(), &thread.ThreadUid, &thread.Count, &thread.Unread, &thread.HistoryId, &rank, &thread.DraftCount)
+
+		err := rows.Scan(columns...)
+		if err != nil {
+			return nil, err
+		}
+
+		threadList.Threads = append(threadList.Threads, thread)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// history
+	query = `
+		SELECT "lastHistoryId"
+			FROM "MessageHistorySeq"
+			WHERE "userId" = $1 ;`
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&threadList.History)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return threadList, nil
+}
+
+// GetThread returns the messages and the drafts of the thread, the trashed ones are left out.
+func (r *ThreadRepository) GetThread(user *User, threadUid string) (*ThreadView, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT *
+			FROM "Message"
+			WHERE "userId" = $1 AND
+			"payload"->>'$.headers.X-Thread-ID' = $2 AND
+			"lastStmt" < 2
+			ORDER BY coalesce("receivedAt", "sentAt", "createdAt"), "rowid";`
+
+	args := []interface{}{user.Id, threadUid}
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	thread := &ThreadView{
+		ThreadUid: threadUid,
+		Messages:  []*Message{},
+		Drafts:    []*Draft{},
+	}
+
+	for rows.Next() {
+		var message Message
+
+		err := rows.Scan(message.Scan()...)
+		if err != nil {
+			return nil, err
+		}
+
+		thread.Messages = append(thread.Messages, &message)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	query = `
+		SELECT *
+			FROM "Draft"
+			WHERE "userId" = $1 AND
+			"payload"->>'$.headers.X-Thread-ID' = $2 AND
+			"lastStmt" < 2
+			ORDER BY "createdAt", "rowid";`
+
+	rows, err = tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var draft Draft
+
+		err := rows.Scan(draft.Scan()...)
+		if err != nil {
+			return nil, err
+		}
+
+		thread.Drafts = append(thread.Drafts, &draft)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(thread.Messages) == 0 && len(thread.Drafts) == 0 {
+		return nil, ErrThreadNotFound
+	}
+
+	// history
+	query = `
+		SELECT "lastHistoryId"
+			FROM "MessageHistorySeq"
+			WHERE "userId" = $1 ;`
+
+	err = tx.QueryRowContext(ctx, query, user.Id).Scan(&thread.History)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return thread, nil
+}
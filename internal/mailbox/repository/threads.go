@@ -1,24 +1,26 @@
 package repository
 
 import (
-	"context"
 	"database/sql"
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
-	"reflect"
 	"time"
 )
 
 type UseThreadRepository interface {
 	List(user *User, folder int) (*ThreadList, error)
+	ListThreads(user *User) (*ThreadSummaryList, error)
+	GetThread(user *User, threadUid string) (*ThreadView, error)
 	Trash(user *User, ids string) error
 	Untrash(user *User, ids string) error
 	Delete(user *User, ids string) error
 }
 
 type ThreadRepository struct {
-	db *sql.DB
+	db       *sql.DB
+	replica  *sql.DB // the List, Sync, Count and Search, nil = the db
+	timeouts Timeouts
 }
 
 type Messages []Message
@@ -96,22 +98,15 @@ func (m *Messages) Scan(value interface{}) error {
 }
 
 func (c *Thread) Scan() []interface{} {
-	s := reflect.ValueOf(c).Elem()
-	numCols := s.NumField()
-	columns := make([]interface{}, numCols)
-	for i := 0; i < numCols; i++ {
-		field := s.Field(i)
-		columns[i] = field.Addr().Interface()
-	}
-	return columns
+	return scanColumns(c)
 }
 
 // * TODO (this select is incomplete)
 func (r *ThreadRepository) List(user *User, folder int) (*ThreadList, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := r.timeouts.read()
 	defer cancel()
 
-	tx, err := r.db.BeginTx(ctx, nil)
+	tx, err := reader(r.db, r.replica, user).BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -195,7 +190,7 @@ func (r *ThreadRepository) List(user *User, folder int) (*ThreadList, error) {
 }
 
 func (r *ThreadRepository) Trash(user *User, ids string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := r.timeouts.write()
 	defer cancel()
 
 	if len(ids) > 0 {
@@ -210,7 +205,7 @@ func (r *ThreadRepository) Trash(user *User, ids string) error {
 			SET "lastStmt" = 2,
 			"deviceId" = $1
 			WHERE "userId" = $2 AND
-			payload->>'$.headers.X-Thread-ID' IN (SELECT value FROM json_each($3, '$.ids'));`
+			payload->>'$.headers.X-Thread-ID' IN (` + jsonIds("$3") + `);`
 
 		prefixedDeviceId := getPrefixedDeviceId(user.DeviceId)
 
@@ -226,7 +221,7 @@ func (r *ThreadRepository) Trash(user *User, ids string) error {
 			SET "lastStmt" = 2,
 			"deviceId" = $1
 			WHERE "userId" = $2 AND
-			payload->>'$.headers.X-Thread-ID' IN (SELECT value FROM json_each($3, '$.ids'));`
+			payload->>'$.headers.X-Thread-ID' IN (` + jsonIds("$3") + `);`
 
 		args = []interface{}{prefixedDeviceId, user.Id, ids}
 
@@ -244,7 +239,7 @@ func (r *ThreadRepository) Trash(user *User, ids string) error {
 }
 
 func (r *ThreadRepository) Untrash(user *User, ids string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := r.timeouts.write()
 	defer cancel()
 
 	if len(ids) > 0 {
@@ -259,7 +254,7 @@ func (r *ThreadRepository) Untrash(user *User, ids string) error {
 			SET "lastStmt" = 0,
 			"deviceId" = $1
 			WHERE "userId" = $2 AND
-			payload->>'$.headers.X-Thread-ID' IN (SELECT value FROM json_each($3, '$.ids'));`
+			payload->>'$.headers.X-Thread-ID' IN (` + jsonIds("$3") + `);`
 
 		prefixedDeviceId := getPrefixedDeviceId(user.DeviceId)
 
@@ -275,7 +270,7 @@ func (r *ThreadRepository) Untrash(user *User, ids string) error {
 			SET "lastStmt" = 0,
 			"deviceId" = $1
 			WHERE "userId" = $2 AND
-			payload->>'$.headers.X-Thread-ID' IN (SELECT value FROM json_each($3, '$.ids'));`
+			payload->>'$.headers.X-Thread-ID' IN (` + jsonIds("$3") + `);`
 
 		args = []interface{}{prefixedDeviceId, user.Id, ids}
 
@@ -293,7 +288,7 @@ func (r *ThreadRepository) Untrash(user *User, ids string) error {
 }
 
 func (r ThreadRepository) Delete(user *User, ids string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := r.timeouts.write()
 	defer cancel()
 
 	if len(ids) > 0 {
@@ -307,7 +302,7 @@ func (r ThreadRepository) Delete(user *User, ids string) error {
 		DELETE
 			FROM "Message"
 			WHERE "userId" = $1 AND
-			payload->>'$.headers.X-Thread-ID' IN (SELECT value FROM json_each($2, '$.ids'));`
+			payload->>'$.headers.X-Thread-ID' IN (` + jsonIds("$2") + `);`
 
 		args := []interface{}{user.Id, ids}
 
@@ -320,7 +315,7 @@ func (r ThreadRepository) Delete(user *User, ids string) error {
 		UPDATE "MessageDeleted"
 			SET "deviceId" = $1
 			WHERE "userId" = $2 AND
-			"id" IN (SELECT value FROM json_each($3, '$.ids'));`
+			"id" IN (` + jsonIds("$3") + `);`
 
 		args = []interface{}{user.DeviceId, user.Id, ids}
 
@@ -333,7 +328,7 @@ func (r ThreadRepository) Delete(user *User, ids string) error {
 		DELETE
 			FROM "Draft"
 			WHERE "userId" = $1 AND
-			payload->>'$.headers.X-Thread-ID' IN (SELECT value FROM json_each($2, '$.ids'));`
+			payload->>'$.headers.X-Thread-ID' IN (` + jsonIds("$2") + `);`
 
 		args = []interface{}{user.Id, ids}
 
@@ -346,7 +341,7 @@ func (r ThreadRepository) Delete(user *User, ids string) error {
 		UPDATE "DraftDeleted"
 			SET "deviceId" = $1
 			WHERE "userId" = $2 AND
-			"id" IN (SELECT value FROM json_each($3, '$.ids'));`
+			"id" IN (` + jsonIds("$3") + `);`
 
 		args = []interface{}{user.DeviceId, user.Id, ids}
 
@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type UseTombstoneRepository interface {
+	Compact(retention, deviceWindow time.Duration) (*TombstoneCompaction, error)
+}
+
+// TombstoneRepository prunes the "*Deleted" tables, the tombstones the devices sync the deletes by.
+type TombstoneRepository struct {
+	db       *sql.DB
+	timeouts Timeouts
+}
+
+type TombstoneCompaction struct {
+	Tombstones   map[string]int64 `json:"tombstones"` // by resource
+	Marks        int64            `json:"marks"`
+	StaleDevices int64            `json:"staleDevices"`
+}
+
+// the resources with a history, by the prefix of their "*Deleted" and "*HistorySeq" tables
+var tombstoneTables = map[string]string{
+	"blobs":         "Blob",
+	"files":         "File",
+	"drafts":        "Draft",
+	"messages":      "Message",
+	"labels":        "Label",
+	"contacts":      "Contact",
+	"contactGroups": "ContactGroup",
+	"savedSearches": "SavedSearch",
+	"collections":   "Collection",
+}
+
+// Compact drops the tombstones every device has synced. The tombstones don't carry a time, so each run
+// marks the current history ids first, and a tombstone is as old as the first mark at or above its history
+// id. The tombstones older than the retention are dropped, but not the ones after the oldest last seen of
+// the devices of the user. The devices not seen within the device window don't hold the tombstones back,
+// they are told to sync from scratch on their next login instead. The marks older than the one still
+// needed are dropped too. The first run only marks.
+func (r *TombstoneRepository) Compact(retention, deviceWindow time.Duration) (*TombstoneCompaction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	retentionModifier := fmt.Sprintf("-%d seconds", int64(retention.Seconds()))
+	deviceModifier := fmt.Sprintf("-%d seconds", int64(deviceWindow.Seconds()))
+
+	compaction := &TombstoneCompaction{Tombstones: map[string]int64{}}
+
+	query := `
+		UPDATE "Device"
+			SET "resync" = TRUE
+			WHERE NOT "resync" AND
+			"lastSeenAt" <= datetime('now', $1);`
+
+	result, err := tx.ExecContext(ctx, query, deviceModifier)
+	if err != nil {
+		return nil, err
+	}
+
+	compaction.StaleDevices, err = result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	for resource, table := range tombstoneTables {
+		// the history unchanged since the last mark is not marked again, the last mark is as good
+		query = `
+		INSERT
+			INTO "HistoryMark" ("userId", "resource", "historyId")
+			SELECT "userId", $1, "lastHistoryId"
+				FROM "` + table + `HistorySeq"
+				WHERE "lastHistoryId" > coalesce((
+					SELECT max("HistoryMark"."historyId")
+						FROM "HistoryMark"
+						WHERE "HistoryMark"."userId" = "` + table + `HistorySeq"."userId" AND
+						"HistoryMark"."resource" = $1), -1);`
+
+		_, err = tx.ExecContext(ctx, query, resource)
+		if err != nil {
+			return nil, err
+		}
+
+		// the cutoff of the user is the earlier of the retention and the oldest last seen of the devices
+		query = `
+		DELETE
+			FROM "` + table + `Deleted"
+			WHERE "historyId" <= (
+				SELECT max("HistoryMark"."historyId")
+					FROM "HistoryMark"
+					WHERE "HistoryMark"."userId" = "` + table + `Deleted"."userId" AND
+					"HistoryMark"."resource" = $1 AND
+					"HistoryMark"."markedAt" <= min(
+						datetime('now', $2),
+						coalesce((
+							SELECT min("Device"."lastSeenAt")
+								FROM "Device"
+								WHERE "Device"."userId" = "` + table + `Deleted"."userId" AND
+								NOT "Device"."resync"), datetime('now', $2))));`
+
+		result, err = tx.ExecContext(ctx, query, resource, retentionModifier)
+		if err != nil {
+			return nil, err
+		}
+
+		compaction.Tombstones[resource], err = result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// a mark is superseded by a later one past both windows, the cutoff is never earlier
+	marksWindow := retention
+	if deviceWindow > marksWindow {
+		marksWindow = deviceWindow
+	}
+
+	query = `
+		DELETE
+			FROM "HistoryMark"
+			WHERE EXISTS (
+				SELECT 1
+					FROM "HistoryMark" AS "Later"
+					WHERE "Later"."userId" = "HistoryMark"."userId" AND
+					"Later"."resource" = "HistoryMark"."resource" AND
+					"Later"."markedAt" <= datetime('now', $1) AND
+					"Later"."rowid" > "HistoryMark"."rowid");`
+
+	result, err = tx.ExecContext(ctx, query, fmt.Sprintf("-%d seconds", int64(marksWindow.Seconds())))
+	if err != nil {
+		return nil, err
+	}
+
+	compaction.Marks, err = result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return compaction, nil
+}
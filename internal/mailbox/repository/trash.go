@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type UseTrashRepository interface {
+	Purge(retention time.Duration) (*TrashPurge, error)
+}
+
+type TrashRepository struct {
+	db       *sql.DB
+	timeouts Timeouts
+}
+
+type TrashPurge struct {
+	Blobs    int64 `json:"blobs"`
+	Contacts int64 `json:"contacts"`
+	Drafts   int64 `json:"drafts"`
+}
+
+// Purge hard-deletes the items of all users trashed longer than the retention window. The delete triggers
+// record the removals in the "*Deleted" tables with a null device id, so every device syncs them.
+func (r *TrashRepository) Purge(retention time.Duration) (*TrashPurge, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	modifier := fmt.Sprintf("-%d seconds", int64(retention.Seconds()))
+
+	purge := &TrashPurge{}
+
+	for table, deleted := range map[string]*int64{
+		"Blob":    &purge.Blobs,
+		"Contact": &purge.Contacts,
+		"Draft":   &purge.Drafts,
+	} {
+		query := `
+		DELETE
+			FROM "` + table + `"
+			WHERE "lastStmt" = 2 AND
+			coalesce("modifiedAt", "createdAt") <= datetime('now', $1);`
+
+		args := []interface{}{modifier}
+
+		result, err := tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			return nil, err
+		}
+
+		*deleted, err = result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return purge, nil
+}
@@ -0,0 +1,203 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type UseUploadRepository interface {
+	Create(user *User, upload *Upload) (*Upload, error)
+	Get(user *User, id string) (*Upload, error)
+	Advance(user *User, id string, from, to int64) (*Upload, error)
+	Delete(user *User, id string) error
+	Expire(age time.Duration) ([]*Upload, error)
+}
+
+// UploadRepository keeps the state of the resumable uploads, i.e. how many bytes of the declared size
+// were received. The chunks themselves are spooled by the storage until the upload is finalized into
+// a blob or a file.
+type UploadRepository struct {
+	db       *sql.DB
+	timeouts Timeouts
+}
+
+type Upload struct {
+	Id          string        `json:"id"`
+	UserId      int64         `json:"-"`
+	Resource    string        `json:"resource"`
+	Name        string        `json:"name"`
+	ContentType string        `json:"contentType"`
+	Size        int64         `json:"size"`
+	Offset      int64         `json:"offset"`
+	Hash        *string       `json:"hash,omitempty"`
+	Metadata    *BlobMetadata `json:"-"`
+	CreatedAt   Timestamp     `json:"createdAt"`
+	ModifiedAt  *Timestamp    `json:"modifiedAt"`
+}
+
+func (u *Upload) Scan() []interface{} {
+	return scanColumns(u)
+}
+
+// Create registers the upload of the declared size, the quota is checked upfront so the client doesn't
+// send the bytes in vain. It is checked again when the upload is finalized.
+func (r *UploadRepository) Create(user *User, upload *Upload) (*Upload, error) {
+	if _, ok := contentTables[upload.Resource]; !ok {
+		return nil, ErrUnknownResource
+	}
+
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	err = checkQuota(ctx, tx, user.Id, upload.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO
+			"Upload" ("userId", "resource", "name", "contentType", "size", "hash", "metadata")
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING * ;`
+
+	args := []interface{}{user.Id, upload.Resource, upload.Name, upload.ContentType, upload.Size, upload.Hash, upload.Metadata}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(upload.Scan()...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return upload, nil
+}
+
+func (r *UploadRepository) Get(user *User, id string) (*Upload, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	query := `
+		SELECT *
+			FROM "Upload"
+			WHERE "userId" = $1 AND
+			"id" = $2 ;`
+
+	upload := &Upload{}
+
+	err := r.db.QueryRowContext(ctx, query, user.Id, id).Scan(upload.Scan()...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUploadNotFound
+		}
+		return nil, err
+	}
+
+	return upload, nil
+}
+
+// Advance moves the offset of the upload past the received chunk, it fails with ErrUploadOffsetMismatch
+// if the offset is no longer the one the chunk was appended at.
+func (r *UploadRepository) Advance(user *User, id string, from, to int64) (*Upload, error) {
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	query := `
+		UPDATE "Upload"
+			SET "offset" = $1,
+			"modifiedAt" = CURRENT_TIMESTAMP
+			WHERE "userId" = $2 AND
+			"id" = $3 AND
+			"offset" = $4
+			RETURNING * ;`
+
+	upload := &Upload{}
+
+	err := r.db.QueryRowContext(ctx, query, to, user.Id, id, from).Scan(upload.Scan()...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUploadOffsetMismatch
+		}
+		return nil, err
+	}
+
+	return upload, nil
+}
+
+func (r *UploadRepository) Delete(user *User, id string) error {
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	query := `
+		DELETE
+			FROM "Upload"
+			WHERE "userId" = $1 AND
+			"id" = $2 ;`
+
+	result, err := r.db.ExecContext(ctx, query, user.Id, id)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return ErrUploadNotFound
+	}
+
+	return nil
+}
+
+// Expire forgets the uploads with no chunk received for the age and returns them, the caller removes
+// the spooled chunks.
+func (r *UploadRepository) Expire(age time.Duration) ([]*Upload, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	modifier := fmt.Sprintf("-%d seconds", int64(age.Seconds()))
+
+	query := `
+		DELETE
+			FROM "Upload"
+			WHERE coalesce("modifiedAt", "createdAt") <= datetime('now', $1)
+			RETURNING * ;`
+
+	rows, err := r.db.QueryContext(ctx, query, modifier)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	uploads := []*Upload{}
+
+	for rows.Next() {
+		upload := &Upload{}
+
+		err := rows.Scan(upload.Scan()...)
+		if err != nil {
+			return nil, err
+		}
+
+		uploads = append(uploads, upload)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return uploads, nil
+}
@@ -4,8 +4,11 @@ import (
 	"cargomail/internal/shared/config"
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"strings"
 	"time"
+	"unicode"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -13,13 +16,20 @@ import (
 type UseUserRepository interface {
 	Create(user *User) error
 	UpdateProfile(user *User) (*UserProfile, error)
+	PatchProfile(user *User, patch *ProfilePatch) (*UserProfile, error)
 	GetProfile(username string) (*UserProfile, error)
 	GetByUsername(username string) (*User, error)
 	GetBySession(sessionScope, id string) (*User, error)
+	SetQuota(username string, quotaBytes *int64) error
+	ChangePassword(user *User, oldPassword, newPassword string) error
+	DeleteAccount(user *User) ([]*Upload, error)
+	GetSettings(user *User) (*Settings, error)
+	UpdateSettings(user *User, patch map[string]json.RawMessage) (*Settings, error)
 }
 
 type UserRepository struct {
-	db *sql.DB
+	db       *sql.DB
+	timeouts Timeouts
 }
 
 type User struct {
@@ -30,12 +40,22 @@ type User struct {
 	LastName  string    `json:"lastName"`
 	CreatedAt time.Time `json:"createdAt"`
 	DeviceId  *string   `json:"-"`
+	Scopes    []string  `json:"-"` // the scopes of the API key, nil = not restricted
+
+	ReadPrimary bool `json:"-"` // the reads skip the replica, e.g. right after a write
 }
 
 type UserProfile struct {
-	Username  string `json:"username"`
-	FirstName string `json:"firstName"`
-	LastName  string `json:"lastName"`
+	Username   string `json:"username"`
+	FirstName  string `json:"firstName"`
+	LastName   string `json:"lastName"`
+	QuotaBytes int64  `json:"quotaBytes"` // 0 = unlimited
+}
+
+// ProfilePatch holds the profile fields to update, the nil ones are left intact.
+type ProfilePatch struct {
+	FirstName *string `json:"firstName"`
+	LastName  *string `json:"lastName"`
 }
 
 type password struct {
@@ -43,6 +63,21 @@ type password struct {
 	hash      []byte
 }
 
+// HasScope tells whether the user is authorized for the scope, the sessions have all scopes
+func (u User) HasScope(scope string) bool {
+	if u.Scopes == nil {
+		return true
+	}
+
+	for _, s := range u.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (u User) Fullname() string {
 	if len(u.FirstName) > 0 && len(u.LastName) > 0 {
 		return u.FirstName + " " + u.LastName
@@ -62,8 +97,15 @@ func (u User) FullnameAndAddress() string {
 	return address
 }
 
+// passwordCost is the bcrypt cost of the password hashes.
+const passwordCost = 12
+
+// dummyPassword is of a hash of the password cost, no user has it. The login compares the password of an
+// unknown username to it, so the response takes the time of a wrong password.
+var dummyPassword = password{hash: []byte("$2a$12$uGVDEizWYURyDxNrPduKbOGEIqtXVUaeZYirSqxBo2dqcee7VklyC")}
+
 func (p *password) Set(plaintextPassword string) error {
-	hash, err := bcrypt.GenerateFromPassword([]byte(plaintextPassword), 12)
+	hash, err := bcrypt.GenerateFromPassword([]byte(plaintextPassword), passwordCost)
 	if err != nil {
 		return err
 	}
@@ -88,8 +130,49 @@ func (p *password) Matches(plaintextPassword string) (bool, error) {
 	return true, nil
 }
 
+// MismatchPassword compares the password to the dummy one and fails, the login of an unknown username
+// takes the time of the comparison with the password of a user.
+func MismatchPassword(plaintextPassword string) bool {
+	_, _ = dummyPassword.Matches(plaintextPassword)
+
+	return false
+}
+
+const (
+	MinPasswordLength = 8
+	MaxPasswordLength = 40 // as the login accepts
+)
+
+// ValidPassword enforces the password policy: 8 to 40 characters, letters and digits or symbols, not the
+// username.
+func ValidPassword(username, plaintextPassword string) error {
+	if len(plaintextPassword) < MinPasswordLength || len(plaintextPassword) > MaxPasswordLength {
+		return ErrWeakPassword
+	}
+
+	if strings.EqualFold(plaintextPassword, username) {
+		return ErrWeakPassword
+	}
+
+	var letters, others bool
+
+	for _, r := range plaintextPassword {
+		if unicode.IsLetter(r) {
+			letters = true
+		} else {
+			others = true
+		}
+	}
+
+	if !letters || !others {
+		return ErrWeakPassword
+	}
+
+	return nil
+}
+
 func (r UserRepository) Create(user *User) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := r.timeouts.write()
 	defer cancel()
 
 	query := `
@@ -113,7 +196,7 @@ func (r UserRepository) Create(user *User) error {
 }
 
 func (r UserRepository) UpdateProfile(user *User) (*UserProfile, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := r.timeouts.write()
 	defer cancel()
 
 	profile := UserProfile{}
@@ -122,11 +205,12 @@ func (r UserRepository) UpdateProfile(user *User) (*UserProfile, error) {
 		UPDATE "user"
 			SET "firstName" = $1,
 				"lastName" = $2
-			WHERE "username" = $3;`
+			WHERE "username" = $3
+			RETURNING coalesce("quotaBytes", $4);`
 
-	args := []interface{}{user.FirstName, user.LastName, user.Username}
+	args := []interface{}{user.FirstName, user.LastName, user.Username, config.QuotaBytes()}
 
-	_, err := r.db.ExecContext(ctx, query, args...)
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(&profile.QuotaBytes)
 	if err != nil {
 		return &profile, err
 	}
@@ -138,21 +222,57 @@ func (r UserRepository) UpdateProfile(user *User) (*UserProfile, error) {
 	return &profile, err
 }
 
+// PatchProfile merges the provided fields into the profile.
+func (r UserRepository) PatchProfile(user *User, patch *ProfilePatch) (*UserProfile, error) {
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	query := `
+		UPDATE "user"
+			SET "firstName" = coalesce($1, "firstName"),
+				"lastName" = coalesce($2, "lastName")
+			WHERE "id" = $3
+			RETURNING "username", "firstName", "lastName", coalesce("quotaBytes", $4);`
+
+	args := []interface{}{patch.FirstName, patch.LastName, user.Id, config.QuotaBytes()}
+
+	var profile UserProfile
+
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(
+		&profile.Username,
+		&profile.FirstName,
+		&profile.LastName,
+		&profile.QuotaBytes,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrUsernameNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &profile, nil
+}
+
 func (r UserRepository) GetProfile(username string) (*UserProfile, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := r.timeouts.write()
 	defer cancel()
 
 	query := `
-		SELECT "username", "firstName", "lastName"
+		SELECT "username", "firstName", "lastName", coalesce("quotaBytes", $1)
 			FROM "user"
-			WHERE "username" = $1;`
+			WHERE "username" = $2;`
 
 	var profile UserProfile
 
-	err := r.db.QueryRowContext(ctx, query, username).Scan(
+	err := r.db.QueryRowContext(ctx, query, config.QuotaBytes(), username).Scan(
 		&profile.Username,
 		&profile.FirstName,
 		&profile.LastName,
+		&profile.QuotaBytes,
 	)
 
 	if err != nil {
@@ -168,7 +288,7 @@ func (r UserRepository) GetProfile(username string) (*UserProfile, error) {
 }
 
 func (r UserRepository) GetByUsername(username string) (*User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := r.timeouts.write()
 	defer cancel()
 
 	query := `
@@ -200,7 +320,7 @@ func (r UserRepository) GetByUsername(username string) (*User, error) {
 }
 
 func (r UserRepository) GetBySession(sessionScope, id string) (*User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := r.timeouts.write()
 	defer cancel()
 
 	query := `
@@ -236,3 +356,198 @@ func (r UserRepository) GetBySession(sessionScope, id string) (*User, error) {
 
 	return &user, nil
 }
+
+// SetQuota sets the storage quota of the user, nil resets it to the configured one.
+func (r UserRepository) SetQuota(username string, quotaBytes *int64) error {
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	query := `
+		UPDATE "User"
+			SET "quotaBytes" = $1
+			WHERE "username" = $2;`
+
+	result, err := r.db.ExecContext(ctx, query, quotaBytes, username)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return ErrUsernameNotFound
+	}
+
+	return nil
+}
+
+// ChangePassword sets the new password if the old one matches, the new one is hashed as on the registration.
+func (r UserRepository) ChangePassword(user *User, oldPassword, newPassword string) error {
+	err := ValidPassword(user.Username, newPassword)
+	if err != nil {
+		return err
+	}
+
+	if oldPassword == newPassword {
+		return ErrWeakPassword
+	}
+
+	var current password
+
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	query := `
+		SELECT "passwordHash"
+			FROM "User"
+			WHERE "id" = $1;`
+
+	err = r.db.QueryRowContext(ctx, query, user.Id).Scan(&current.hash)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrUsernameNotFound
+		default:
+			return err
+		}
+	}
+
+	match, err := current.Matches(oldPassword)
+	if err != nil {
+		return err
+	}
+
+	if !match {
+		return ErrInvalidCredentials
+	}
+
+	var updated password
+
+	err = updated.Set(newPassword)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel = r.timeouts.write()
+	defer cancel()
+
+	// the password changed meanwhile is not overwritten
+	query = `
+		UPDATE "User"
+			SET "passwordHash" = $1
+			WHERE "id" = $2 AND
+			"passwordHash" = $3;`
+
+	result, err := r.db.ExecContext(ctx, query, updated.hash, user.Id, current.hash)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrInvalidCredentials
+	}
+
+	user.Password = updated
+
+	return nil
+}
+
+// accountTables are deleted explicitly before the user, so their delete triggers run while the user
+// still exists, the rest goes with the user by the cascade of the foreign keys, which the connections of
+// the database.Connect enforce.
+var accountTables = []string{
+	`"Blob"`,
+	`"File"`,
+	`"Draft"`,
+	`"Message"`,
+	`"Label"`,
+	`"ContactGroup"`,
+	`"Contact"`,
+	`"SavedSearch"`,
+	`"Collection"`,
+}
+
+// DeleteAccount removes the user and all the data of the user in one transaction, i.e. the contacts, the
+// drafts, the blobs, the files, the messages, the devices, the sessions and the history. It returns the
+// uploads in progress, whose spooled chunks the caller removes, as the caller releases the stored bytes
+// of the blobs and the files no longer referenced.
+func (r UserRepository) DeleteAccount(user *User) ([]*Upload, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT *
+			FROM "Upload"
+			WHERE "userId" = $1 ;`
+
+	rows, err := tx.QueryContext(ctx, query, user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	uploads := []*Upload{}
+
+	for rows.Next() {
+		upload := &Upload{}
+
+		err := rows.Scan(upload.Scan()...)
+		if err != nil {
+			return nil, err
+		}
+
+		uploads = append(uploads, upload)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, table := range accountTables {
+		query := `
+			DELETE
+				FROM ` + table + `
+				WHERE "userId" = $1 ;`
+
+		_, err = tx.ExecContext(ctx, query, user.Id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	query = `
+		DELETE
+			FROM "User"
+			WHERE "id" = $1 ;`
+
+	result, err := tx.ExecContext(ctx, query, user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if affected == 0 {
+		return nil, ErrUsernameNotFound
+	}
+
+	return uploads, tx.Commit()
+}
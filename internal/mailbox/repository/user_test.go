@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// userRows counts the rows of the user in the tables of a "userId".
+func userRows(t *testing.T, db *sql.DB, userId int64) map[string]int {
+	t.Helper()
+
+	rows, err := db.Query(`
+		SELECT "name"
+			FROM sqlite_master
+			WHERE "type" = 'table' AND
+			"sql" LIKE '%"userId"%INTEGER%';`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tables []string
+
+	for rows.Next() {
+		var table string
+
+		if err := rows.Scan(&table); err != nil {
+			t.Fatal(err)
+		}
+
+		tables = append(tables, table)
+	}
+	rows.Close()
+
+	counts := map[string]int{}
+
+	for _, table := range tables {
+		var n int
+
+		err := db.QueryRow(`SELECT count(*) FROM "`+table+`" WHERE "userId" = $1;`, userId).Scan(&n)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n > 0 {
+			counts[table] = n
+		}
+	}
+
+	return counts
+}
+
+func TestDeleteAccount(t *testing.T) {
+	repository, db := newTestRepository(t)
+
+	// the connections are opened anew, not the one of the Init
+	db.SetMaxIdleConns(0)
+
+	alice := newTestUser(t, repository, "alice")
+	bob := newTestUser(t, repository, "bob")
+
+	newTestContact(t, repository, alice, "carol@example.com")
+
+	_, err := repository.Drafts.Create(alice, &Draft{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = repository.Session.New(alice.Id, time.Hour, ScopeAuthentication)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = repository.Devices.Touch(alice, *alice.DeviceId, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = repository.RefreshTokens.Create(alice, *alice.DeviceId, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = repository.ApiKeys.Create(alice, &ApiKey{Name: "cli"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = repository.User.DeleteAccount(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if left := userRows(t, db, alice.Id); len(left) > 0 {
+		t.Errorf("rows of the deleted user left: %v", left)
+	}
+
+	if len(userRows(t, db, bob.Id)) == 0 {
+		t.Error("rows of the other user deleted")
+	}
+
+	// the id of the deleted user isn't reused
+	carol := newTestUser(t, repository, "carol")
+
+	if carol.Id <= bob.Id {
+		t.Errorf("got the id %d after %d", carol.Id, bob.Id)
+	}
+
+	if rows := userRows(t, db, carol.Id); rows["BlobTimelineSeq"] != 1 {
+		t.Errorf("got %d blob timeline sequences of the new user, want 1", rows["BlobTimelineSeq"])
+	}
+}
+
+func TestPatchProfile(t *testing.T) {
+	repository, _ := newTestRepository(t)
+
+	alice := newTestUser(t, repository, "alice")
+	alice.FirstName = "Alice"
+	alice.LastName = "Liddell"
+
+	_, err := repository.User.UpdateProfile(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the fields absent of the body are left intact, the empty ones are cleared
+	for _, tt := range []struct {
+		body      string
+		firstName string
+		lastName  string
+	}{
+		{`{}`, "Alice", "Liddell"},
+		{`{"lastName":"Pleasance"}`, "Alice", "Pleasance"},
+		{`{"firstName":"","lastName":null}`, "", "Pleasance"},
+	} {
+		var patch ProfilePatch
+
+		err = json.Unmarshal([]byte(tt.body), &patch)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		profile, err := repository.User.PatchProfile(alice, &patch)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if profile.Username != "alice" || profile.FirstName != tt.firstName || profile.LastName != tt.lastName {
+			t.Errorf("%s: got the profile %+v, want %q %q", tt.body, profile, tt.firstName, tt.lastName)
+		}
+	}
+
+	_, err = repository.User.PatchProfile(&User{Id: alice.Id + 1}, &ProfilePatch{})
+	if !errors.Is(err, ErrUsernameNotFound) {
+		t.Errorf("got %v for an unknown user, want %v", err, ErrUsernameNotFound)
+	}
+}
+
+// the login of an unknown username takes the time of a wrong password
+func TestMismatchPassword(t *testing.T) {
+	cost, err := bcrypt.Cost(dummyPassword.hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cost != passwordCost {
+		t.Errorf("got the cost %d of the dummy password, want %d", cost, passwordCost)
+	}
+
+	if MismatchPassword("dummy password") {
+		t.Error("the dummy password matched")
+	}
+}
@@ -0,0 +1,333 @@
+package repository
+
+import (
+	"cargomail/internal/shared/config"
+	"crypto/rand"
+	"database/sql"
+	"database/sql/driver"
+	b64 "encoding/base64"
+	"net/url"
+	"time"
+)
+
+type UseWebhookRepository interface {
+	Create(user *User, webhook *Webhook) (*NewWebhook, error)
+	List(user *User) ([]*Webhook, error)
+	Delete(user *User, id string) error
+	Enqueue(event *Event) error
+	Due(limit int) ([]*WebhookDelivery, error)
+	Retry(id int64, lastError string, delay time.Duration) error
+	Done(id int64) error
+}
+
+// WebhookRepository keeps the URLs the changes of the user are posted to, and the deliveries of the
+// events to them.
+type WebhookRepository struct {
+	db       *sql.DB
+	timeouts Timeouts
+}
+
+const (
+	WebhookSecretPrefix = "whsec_"
+	maxWebhooksPerUser  = 10
+	maxWebhookUrlLength = 2048
+)
+
+type Webhook struct {
+	Id        string       `json:"id"`
+	UserId    int64        `json:"-"`
+	Url       string       `json:"url"`
+	Secret    string       `json:"-"`
+	Resources ResourceList `json:"resources"`
+	CreatedAt Timestamp    `json:"createdAt"`
+}
+
+// NewWebhook is the only place the secret is ever returned, the receiver verifies the signatures by it.
+type NewWebhook struct {
+	*Webhook
+	Secret string `json:"secret"`
+}
+
+// ResourceList are the resources of the events, e.g. contacts, stored as the scopes are.
+type ResourceList []string
+
+func (l ResourceList) Value() (driver.Value, error) {
+	return ScopeList(l).Value()
+}
+
+func (l *ResourceList) Scan(value interface{}) error {
+	return (*ScopeList)(l).Scan(value)
+}
+
+func (w *Webhook) Scan() []interface{} {
+	return scanColumns(w)
+}
+
+// WebhookDelivery is an event to be posted to the webhook.
+type WebhookDelivery struct {
+	Id        int64
+	WebhookId string
+	Url       string
+	Secret    string
+	Attempts  int
+	Event     *Event
+}
+
+func validWebhookUrl(rawUrl string) bool {
+	if len(rawUrl) > maxWebhookUrlLength {
+		return false
+	}
+
+	u, err := url.Parse(rawUrl)
+	if err != nil || len(u.Host) == 0 || u.User != nil {
+		return false
+	}
+
+	// the plain http is for the local development only
+	return u.Scheme == "https" || (u.Scheme == "http" && config.DevStage())
+}
+
+func validResources(resources []string) bool {
+	for _, resource := range resources {
+		if _, ok := tombstoneTables[resource]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+func generateWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return WebhookSecretPrefix + b64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Create registers the webhook of the resources, all of them if none are listed, with a new secret.
+func (r *WebhookRepository) Create(user *User, webhook *Webhook) (*NewWebhook, error) {
+	if !validWebhookUrl(webhook.Url) {
+		return nil, ErrInvalidWebhookUrl
+	}
+
+	if !validResources(webhook.Resources) {
+		return nil, ErrUnknownResource
+	}
+
+	// an empty list of resources would make an useless webhook
+	if webhook.Resources != nil && len(webhook.Resources) == 0 {
+		return nil, ErrUnknownResource
+	}
+
+	secret, err := generateWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT count(*)
+			FROM "Webhook"
+			WHERE "userId" = $1;`
+
+	var count int
+
+	err = tx.QueryRowContext(ctx, query, user.Id).Scan(&count)
+	if err != nil {
+		return nil, err
+	}
+
+	if count >= maxWebhooksPerUser {
+		return nil, ErrTooManyWebhooks
+	}
+
+	query = `
+		INSERT
+			INTO "Webhook" ("userId", "url", "secret", "resources")
+			VALUES ($1, $2, $3, $4)
+			RETURNING * ;`
+
+	args := []interface{}{user.Id, webhook.Url, secret, webhook.Resources}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(webhook.Scan()...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &NewWebhook{Webhook: webhook, Secret: secret}, tx.Commit()
+}
+
+func (r *WebhookRepository) List(user *User) ([]*Webhook, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	query := `
+		SELECT *
+			FROM "Webhook"
+			WHERE "userId" = $1
+			ORDER BY "createdAt" DESC;`
+
+	rows, err := r.db.QueryContext(ctx, query, user.Id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []*Webhook{}
+
+	for rows.Next() {
+		var webhook Webhook
+
+		err := rows.Scan(webhook.Scan()...)
+		if err != nil {
+			return nil, err
+		}
+
+		webhooks = append(webhooks, &webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+// Delete unregisters the webhook, its pending deliveries are dropped.
+func (r *WebhookRepository) Delete(user *User, id string) error {
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	query := `
+		DELETE
+			FROM "Webhook"
+			WHERE "userId" = $1 AND
+			"id" = $2;`
+
+	result, err := r.db.ExecContext(ctx, query, user.Id, id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+// Enqueue schedules the delivery of the event to the webhooks of its user and resource. An event enqueued
+// again, e.g. after a restart, is not delivered twice.
+func (r *WebhookRepository) Enqueue(event *Event) error {
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	query := `
+		INSERT OR IGNORE
+			INTO "WebhookDelivery" ("webhookId", "eventId", "resource", "resourceId", "historyId", "type")
+			SELECT "id", $1, $2, $3, $4, $5
+				FROM "Webhook"
+				WHERE "userId" = $6 AND
+				("resources" IS NULL OR
+				EXISTS (SELECT 1 FROM json_each("Webhook"."resources") WHERE "value" = $2));`
+
+	args := []interface{}{event.Id, event.Resource, event.ResourceId, event.HistoryId, event.Type, event.UserId}
+
+	_, err := r.db.ExecContext(ctx, query, args...)
+
+	return err
+}
+
+// Due returns the deliveries to be attempted now, the oldest first.
+func (r *WebhookRepository) Due(limit int) ([]*WebhookDelivery, error) {
+	ctx, cancel := r.timeouts.read()
+	defer cancel()
+
+	query := `
+		SELECT "WebhookDelivery"."id", "webhookId", "url", "secret", "attempts",
+			"eventId", "resource", "resourceId", "historyId", "type", "WebhookDelivery"."createdAt"
+			FROM "WebhookDelivery" INNER JOIN "Webhook" ON "Webhook"."id" = "WebhookDelivery"."webhookId"
+			WHERE "nextAttemptAt" <= CURRENT_TIMESTAMP
+			ORDER BY "WebhookDelivery"."id"
+			LIMIT $1;`
+
+	rows, err := r.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []*WebhookDelivery{}
+
+	for rows.Next() {
+		delivery := &WebhookDelivery{Event: &Event{}}
+
+		err := rows.Scan(
+			&delivery.Id,
+			&delivery.WebhookId,
+			&delivery.Url,
+			&delivery.Secret,
+			&delivery.Attempts,
+			&delivery.Event.Id,
+			&delivery.Event.Resource,
+			&delivery.Event.ResourceId,
+			&delivery.Event.HistoryId,
+			&delivery.Event.Type,
+			&delivery.Event.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// Retry counts the failed attempt and postpones the next one by the delay.
+func (r *WebhookRepository) Retry(id int64, lastError string, delay time.Duration) error {
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	nextAttemptAt := time.Now().Add(delay)
+
+	query := `
+		UPDATE "WebhookDelivery"
+			SET "attempts" = "attempts" + 1,
+			"lastError" = $1,
+			"nextAttemptAt" = $2
+			WHERE "id" = $3;`
+
+	_, err := r.db.ExecContext(ctx, query, lastError, sqliteTimestamp(&nextAttemptAt), id)
+
+	return err
+}
+
+// Done removes the delivery, either delivered or given up on.
+func (r *WebhookRepository) Done(id int64) error {
+	ctx, cancel := r.timeouts.write()
+	defer cancel()
+
+	query := `
+		DELETE
+			FROM "WebhookDelivery"
+			WHERE "id" = $1;`
+
+	_, err := r.db.ExecContext(ctx, query, id)
+
+	return err
+}
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"cargomail/internal/mailbox/repository"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+)
+
+type UseAccountStorage interface {
+	Delete(user *repository.User) error
+}
+
+type AccountStorage struct {
+	repository repository.Repository
+	blobStore  BlobStore
+	fileStore  BlobStore
+	uploads    *UploadStorage
+}
+
+// Delete removes the account with all its data. The rows go first in one transaction, then the stored
+// bytes no longer referenced. A failure to remove the bytes doesn't fail the deletion, the orphans are
+// logged and left to the gc-blobs command.
+func (s *AccountStorage) Delete(user *repository.User) error {
+	uploads, err := s.repository.User.DeleteAccount(user)
+	if err != nil {
+		return err
+	}
+
+	for _, upload := range uploads {
+		err := os.Remove(s.uploads.path(upload))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("account %d deleted, orphaned upload %s: %v", user.Id, upload.Id, err)
+		}
+	}
+
+	s.release(user, repository.BlobsResource, s.blobStore)
+	s.release(user, repository.FilesResource, s.fileStore)
+
+	return nil
+}
+
+func (s *AccountStorage) release(user *repository.User, resource string, store BlobStore) {
+	digests, err := s.repository.Contents.Release(resource)
+	if err != nil {
+		log.Printf("account %d deleted, %s contents release: %v", user.Id, resource, err)
+		return
+	}
+
+	for _, digest := range digests {
+		err := store.Delete(digest)
+		if err != nil {
+			log.Printf("account %d deleted, orphaned %s object %s: %v", user.Id, resource, digest, err)
+		}
+	}
+}
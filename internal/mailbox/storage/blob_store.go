@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"cargomail/internal/mailbox/repository"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// BlobStore keeps the encrypted bytes of the blobs and the files by their digest, the database rows
+// keep the metadata only. The content-addressed layout lets the equal contents share the bytes.
+type BlobStore interface {
+	Put(digest string, r io.Reader) error
+	Get(digest string) (io.ReadCloser, error)
+	Open(digest string) (io.ReadSeekCloser, error)
+	Delete(digest string) error
+	Stat(digest string) (int64, error)
+	Walk(fn func(digest string, size int64, modTime time.Time) error) error
+}
+
+// LocalBlobStore keeps the bytes in a folder of the local filesystem, one file per digest.
+type LocalBlobStore struct {
+	dir string
+}
+
+func NewLocalBlobStore(dir string) *LocalBlobStore {
+	return &LocalBlobStore{dir: dir}
+}
+
+func (s *LocalBlobStore) path(digest string) (string, error) {
+	if len(digest) == 0 || digest != filepath.Base(digest) || digest == "." || digest == ".." {
+		return "", repository.ErrInvalidDigest
+	}
+
+	return filepath.Join(s.dir, digest), nil
+}
+
+// Put moves the spooled upload into place when it lies in the same folder, any other reader is copied.
+func (s *LocalBlobStore) Put(digest string, r io.Reader) error {
+	path, err := s.path(digest)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(s.dir, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	if f, ok := r.(*os.File); ok && filepath.Dir(f.Name()) == filepath.Clean(s.dir) {
+		return os.Rename(f.Name(), path)
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	_, err = io.Copy(tmp, r)
+	if err != nil {
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *LocalBlobStore) Get(digest string) (io.ReadCloser, error) {
+	path, err := s.path(digest)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.Open(path)
+}
+
+// Open returns the stored bytes for the reads from any offset.
+func (s *LocalBlobStore) Open(digest string) (io.ReadSeekCloser, error) {
+	path, err := s.path(digest)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.Open(path)
+}
+
+// Delete removes the bytes of the digest, a missing digest is not an error.
+func (s *LocalBlobStore) Delete(digest string) error {
+	path, err := s.path(digest)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// Stat returns the size of the stored bytes, fs.ErrNotExist when there are none.
+func (s *LocalBlobStore) Stat(digest string) (int64, error) {
+	path, err := s.path(digest)
+	if err != nil {
+		return 0, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+
+	return info.Size(), nil
+}
+
+// Walk lists the stored digests, the spooled uploads included.
+func (s *LocalBlobStore) Walk(fn func(digest string, size int64, modTime time.Time) error) error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return err
+		}
+
+		err = fn(entry.Name(), info.Size(), info.ModTime())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// spoolDir returns where the uploads are encrypted to before they are put, i.e. the folder of the local
+// store, so the put is a rename, or the temporary folder otherwise.
+func spoolDir(store BlobStore) (string, error) {
+	local, ok := store.(*LocalBlobStore)
+	if !ok {
+		return "", nil
+	}
+
+	return local.dir, os.MkdirAll(local.dir, os.ModePerm)
+}
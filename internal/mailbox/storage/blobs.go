@@ -3,98 +3,53 @@ package storage
 import (
 	"cargomail/internal/mailbox/repository"
 	"cargomail/internal/shared/config"
-	"crypto/aes"
-	"crypto/cipher"
-	"crypto/rand"
-	"crypto/sha256"
-	b64 "encoding/base64"
 	"io"
+	"log"
 	"mime/multipart"
-	"os"
-	"path/filepath"
-
-	"github.com/google/uuid"
 )
 
 type UseBlobStorage interface {
-	Store(user *repository.User, file multipart.File, blobsPath, uuid, filename, contentType string) (*repository.Blob, error)
-	CleanAndStoreMultipart(user *repository.User, draftId string, body *multipart.Reader, blobsPath string) ([]*repository.Blob, error)
-	Load(w io.Writer, blob *repository.Blob, blobPath string) error
+	Store(user *repository.User, file io.Reader, filename, contentType string) (*repository.Blob, error)
+	CleanAndStoreMultipart(user *repository.User, draftId string, body *multipart.Reader) ([]*repository.Blob, error)
+	Load(w io.Writer, blob *repository.Blob) error
+	Open(blob *repository.Blob) (io.ReadSeekCloser, error)
+	Verify(blob *repository.Blob) error
+	Delete(user *repository.User, ids string) error
+	EmptyTrash(user *repository.User) error
 }
 
 type BlobStorage struct {
 	repository repository.Repository
+	store      BlobStore
 }
 
-func (s *BlobStorage) Store(user *repository.User, file multipart.File, blobsPath, uuid, filename, contentType string) (*repository.Blob, error) {
-	f, err := os.OpenFile(filepath.Join(blobsPath, uuid), os.O_WRONLY|os.O_CREATE, 0666)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-
-	salt := make([]byte, repository.SaltSize)
-	_, err = rand.Read(salt)
-	if err != nil {
-		return nil, err
-	}
-
-	key := make([]byte, repository.KeySize)
-	_, err = rand.Read(key)
-	if err != nil {
-		return nil, err
-	}
-
-	iv := make([]byte, repository.IvSize)
-	_, err = rand.Read(iv)
-	if err != nil {
-		return nil, err
-	}
-
-	hash := sha256.New()
-
-	_, err = hash.Write(salt)
-	if err != nil {
-		return nil, err
-	}
-
-	aes, err := aes.NewCipher(key)
+func (s *BlobStorage) Store(user *repository.User, file io.Reader, filename, contentType string) (*repository.Blob, error) {
+	file, contentType, err := detectContentType(file, contentType)
 	if err != nil {
 		return nil, err
 	}
 
-	stream := cipher.NewCTR(aes, iv)
-
-	pipeReader, pipeWriter := io.Pipe()
-	writer := &cipher.StreamWriter{S: stream, W: pipeWriter}
-
-	// do the encryption in a goroutine
-	go func() {
-		_, err := io.Copy(writer, io.TeeReader(file, hash))
+	if config.StripImageMetadata() {
+		stripped, err := stripImageMetadata(file, contentType)
 		if err != nil {
-			pipeWriter.CloseWithError(err)
-			return
+			return nil, err
 		}
-		defer pipeWriter.Close()
-	}()
+		defer stripped.Close()
 
-	written, err := io.Copy(f, pipeReader)
-	if err != nil {
-		return nil, err
+		file = stripped
 	}
 
-	hashSum := hash.Sum(nil)
-	digest := b64.RawURLEncoding.EncodeToString(hashSum)
+	head := &headWriter{max: snippetHeadSize}
 
-	blobMetadata := &repository.BlobMetadata{
-		Salt: b64.RawURLEncoding.EncodeToString(salt),
-		Key:  b64.RawURLEncoding.EncodeToString(key),
-		Iv:   b64.RawURLEncoding.EncodeToString(iv),
+	digest, written, blobMetadata, err := storeEncrypted(s.store, io.TeeReader(file, head))
+	if err != nil {
+		return nil, err
 	}
 
 	uploadedBlob := &repository.Blob{
 		Digest:      digest,
 		Name:        filename,
+		Snippet:     repository.ContentSnippet(contentType, head.head, config.SnippetLength()),
 		Size:        written,
 		Metadata:    blobMetadata,
 		ContentType: contentType,
@@ -102,214 +57,147 @@ func (s *BlobStorage) Store(user *repository.User, file multipart.File, blobsPat
 
 	uploadedBlob, err = s.repository.Blobs.Create(user, uploadedBlob)
 	if err != nil {
+		_ = s.store.Delete(digest)
 		return nil, err
 	}
 
-	os.Rename(filepath.Join(blobsPath, uuid), filepath.Join(blobsPath, digest))
-	if err != nil {
-		return nil, err
+	// the equal content was stored already
+	if uploadedBlob.Digest != digest {
+		_ = s.store.Delete(digest)
 	}
 
 	return uploadedBlob, nil
 }
 
-func (s *BlobStorage) CleanAndStoreMultipart(user *repository.User, draftId string, reader *multipart.Reader, blobsPath string) ([]*repository.Blob, error) {
+func (s *BlobStorage) CleanAndStoreMultipart(user *repository.User, draftId string, reader *multipart.Reader) ([]*repository.Blob, error) {
 	uploadedBlobs := []*repository.Blob{}
 
+	// the parts stored before a failing one are removed
+	discard := func() {
+		for i := range uploadedBlobs {
+			_ = s.store.Delete(uploadedBlobs[i].Digest)
+		}
+	}
+
 	for {
 		part, err := reader.NextPart()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
+			discard()
 			return nil, err
 		}
 
 		header := part.Header
 
-		uuid := uuid.NewString()
-
-		f, err := os.OpenFile(filepath.Join(blobsPath, uuid), os.O_WRONLY|os.O_CREATE, 0666)
-		if err != nil {
-			return nil, err
-		}
-		defer f.Close()
-
-		salt := make([]byte, repository.SaltSize)
-		_, err = rand.Read(salt)
-		if err != nil {
-			return nil, err
-		}
-
-		key := make([]byte, repository.KeySize)
-		_, err = rand.Read(key)
-		if err != nil {
-			return nil, err
-		}
-
-		iv := make([]byte, repository.IvSize)
-		_, err = rand.Read(iv)
-		if err != nil {
-			return nil, err
-		}
-
-		hash := sha256.New()
-
-		_, err = hash.Write(salt)
-		if err != nil {
-			return nil, err
-		}
-
-		aes, err := aes.NewCipher(key)
-		if err != nil {
-			return nil, err
-		}
+		var content io.ReadCloser = io.NopCloser(part)
 
-		stream := cipher.NewCTR(aes, iv)
-
-		pipeReader, pipeWriter := io.Pipe()
-		writer := &cipher.StreamWriter{S: stream, W: pipeWriter}
-
-		// do the encryption in a goroutine
-		go func() {
-			_, err := io.Copy(writer, io.TeeReader(part, hash))
+		if config.StripImageMetadata() {
+			content, err = stripImageMetadata(part, part.Header.Get("Content-Type"))
 			if err != nil {
-				pipeWriter.CloseWithError(err)
-				return
+				discard()
+				return nil, err
 			}
-			defer pipeWriter.Close()
-		}()
+		}
 
-		written, err := io.Copy(f, pipeReader)
+		head := &headWriter{max: snippetHeadSize}
+
+		digest, written, blobMetadata, err := storeEncrypted(s.store, io.TeeReader(content, head))
+		content.Close()
 		if err != nil {
+			discard()
 			return nil, err
 		}
 
-		hashSum := hash.Sum(nil)
-		digest := b64.RawURLEncoding.EncodeToString(hashSum)
-
-		blobMetadata := &repository.BlobMetadata{
-			Salt: b64.RawURLEncoding.EncodeToString(salt),
-			Key:  b64.RawURLEncoding.EncodeToString(key),
-			Iv:   b64.RawURLEncoding.EncodeToString(iv),
-		}
 		contentType := header.Values("Content-Type")
 
 		uploadedBlob := &repository.Blob{
 			DraftId:     &draftId,
 			Digest:      digest,
+			Snippet:     repository.ContentSnippet(contentType[0], head.head, config.SnippetLength()),
 			Size:        written,
 			Metadata:    blobMetadata,
 			ContentType: contentType[0],
 		}
 
 		uploadedBlobs = append(uploadedBlobs, uploadedBlob)
+	}
 
-		os.Rename(filepath.Join(blobsPath, uuid), filepath.Join(blobsPath, digest))
-		if err != nil {
-			return nil, err
-		}
+	digests := make([]string, len(uploadedBlobs))
+	for i := range uploadedBlobs {
+		digests[i] = uploadedBlobs[i].Digest
 	}
 
-	removedBlobs, createdBlobs, err := s.repository.Blobs.CleanAndCreate(user, uploadedBlobs, draftId)
+	_, createdBlobs, err := s.repository.Blobs.CleanAndCreate(user, uploadedBlobs, draftId)
 	if err != nil {
+		for i := range digests {
+			_ = s.store.Delete(digests[i])
+		}
 		return nil, err
 	}
 
-	blobsPath = filepath.Join(config.Configuration.ResourcesPath, config.Configuration.BlobsFolder)
-
-	// remove the old blobs from disk
-	for i := range removedBlobs {
-		_ = os.Remove(filepath.Join(blobsPath, removedBlobs[i].Digest))
+	// the equal contents were stored already
+	for i := range createdBlobs {
+		if createdBlobs[i].Digest != digests[i] {
+			_ = s.store.Delete(digests[i])
+		}
 	}
 
+	// remove the contents of the old blobs no longer referenced
+	s.release()
+
 	return createdBlobs, nil
 }
 
-func (s *BlobStorage) Load(w io.Writer, blob *repository.Blob, blobPath string) error {
-	out, err := os.Open(blobPath)
-	if err != nil {
-		// the resource file not found
-		return err
-	}
-	defer out.Close()
+func (s *BlobStorage) Load(w io.Writer, blob *repository.Blob) error {
+	return loadEncrypted(w, s.store, blob.Digest, blob.Metadata)
+}
 
-	salt, err := b64.RawURLEncoding.DecodeString(blob.Metadata.Salt)
-	if err != nil {
-		return err
-	}
+// Open returns the decrypted content for the range requests, unlike Load the digest isn't checked.
+func (s *BlobStorage) Open(blob *repository.Blob) (io.ReadSeekCloser, error) {
+	return openEncrypted(s.store, blob.Digest, blob.Size, blob.Metadata)
+}
 
-	key, err := b64.RawURLEncoding.DecodeString(blob.Metadata.Key)
-	if err != nil {
-		return err
-	}
+// Verify checks the stored bytes of the blob against its digest and its hash.
+func (s *BlobStorage) Verify(blob *repository.Blob) error {
+	_, err := verifyEncrypted(s.store, blob.Digest, blob.Metadata)
 
-	iv, err := b64.RawURLEncoding.DecodeString(blob.Metadata.Iv)
-	if err != nil {
-		return err
-	}
+	return err
+}
 
-	aes, err := aes.NewCipher(key)
+// Delete deletes the blobs, the stored bytes are removed with the last blob referencing them.
+func (s *BlobStorage) Delete(user *repository.User, ids string) error {
+	_, err := s.repository.Blobs.Delete(user, ids)
 	if err != nil {
 		return err
 	}
 
-	stream := cipher.NewCTR(aes, iv)
-
-	pipeReader, pipeWriter := io.Pipe()
-	writer := &cipher.StreamWriter{S: stream, W: pipeWriter}
-
-	// do the encryption in a goroutine
-	go func() {
-		_, err := io.Copy(writer, out)
-		if err != nil {
-			pipeWriter.CloseWithError(err)
-			return
-		}
-		defer pipeWriter.Close()
-	}()
+	s.release()
 
-	hash := sha256.New()
-
-	_, err = hash.Write(salt)
-	if err != nil {
-		return err
-	}
+	return nil
+}
 
-	_, err = io.Copy(hash, pipeReader)
+// EmptyTrash deletes the trashed blobs, the stored bytes are removed with the last blob referencing them.
+func (s *BlobStorage) EmptyTrash(user *repository.User) error {
+	err := s.repository.Blobs.EmptyTrash(user)
 	if err != nil {
 		return err
 	}
 
-	hashSum := hash.Sum(nil)
-	digest := b64.RawURLEncoding.EncodeToString(hashSum)
-
-	if digest != blob.Digest {
-		return repository.ErrWrongResourceDigest
-	}
-
-	// do it once more (no way to avoid it)
-	out.Seek(0, io.SeekStart)
+	s.release()
 
-	stream2 := cipher.NewCTR(aes, iv)
-
-	pipeReader2, pipeWriter2 := io.Pipe()
-	writer2 := &cipher.StreamWriter{S: stream2, W: pipeWriter2}
-
-	// do the encryption in a goroutine
-	go func() {
-		_, err := io.Copy(writer2, out)
-		if err != nil {
-			pipeWriter2.CloseWithError(err)
-			return
-		}
-		defer pipeWriter2.Close()
-	}()
+	return nil
+}
 
-	_, err = io.Copy(w, pipeReader2)
+func (s *BlobStorage) release() {
+	digests, err := s.repository.Contents.Release(repository.BlobsResource)
 	if err != nil {
-		return err
+		log.Printf("blob contents release: %v", err)
+		return
 	}
 
-	return nil
+	for i := range digests {
+		_ = s.store.Delete(digests[i])
+	}
 }
@@ -0,0 +1,294 @@
+package storage
+
+import (
+	"cargomail/internal/mailbox/repository"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	b64 "encoding/base64"
+	"errors"
+	"io"
+	"os"
+)
+
+// storeEncrypted encrypts the content with a new key and puts it into the store by the digest of the
+// salted content. The content is spooled to a temporary file first, as the digest is known at the end.
+// The metadata carries the hash of the plain content too, the equal uploads share the stored bytes by it.
+func storeEncrypted(store BlobStore, content io.Reader) (string, int64, *repository.BlobMetadata, error) {
+	dir, err := spoolDir(store)
+	if err != nil {
+		return "", 0, nil, err
+	}
+
+	f, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return "", 0, nil, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	salt := make([]byte, repository.SaltSize)
+	_, err = rand.Read(salt)
+	if err != nil {
+		return "", 0, nil, err
+	}
+
+	key := make([]byte, repository.KeySize)
+	_, err = rand.Read(key)
+	if err != nil {
+		return "", 0, nil, err
+	}
+
+	iv := make([]byte, repository.IvSize)
+	_, err = rand.Read(iv)
+	if err != nil {
+		return "", 0, nil, err
+	}
+
+	hash := sha256.New()
+	contentHash := sha256.New()
+
+	_, err = hash.Write(salt)
+	if err != nil {
+		return "", 0, nil, err
+	}
+
+	aes, err := aes.NewCipher(key)
+	if err != nil {
+		return "", 0, nil, err
+	}
+
+	stream := cipher.NewCTR(aes, iv)
+
+	pipeReader, pipeWriter := io.Pipe()
+	writer := &cipher.StreamWriter{S: stream, W: pipeWriter}
+
+	// the failed spooling fails the writes of the goroutine
+	defer pipeReader.Close()
+
+	// do the encryption in a goroutine
+	go func() {
+		_, err := io.Copy(writer, io.TeeReader(content, io.MultiWriter(hash, contentHash)))
+		if err != nil {
+			pipeWriter.CloseWithError(err)
+			return
+		}
+		defer pipeWriter.Close()
+	}()
+
+	written, err := io.Copy(f, pipeReader)
+	if err != nil {
+		return "", 0, nil, err
+	}
+
+	hashSum := hash.Sum(nil)
+	digest := b64.RawURLEncoding.EncodeToString(hashSum)
+
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", 0, nil, err
+	}
+
+	err = store.Put(digest, f)
+	if err != nil {
+		return "", 0, nil, err
+	}
+
+	metadata := &repository.BlobMetadata{
+		Salt: b64.RawURLEncoding.EncodeToString(salt),
+		Key:  b64.RawURLEncoding.EncodeToString(key),
+		Iv:   b64.RawURLEncoding.EncodeToString(iv),
+		Hash: b64.RawURLEncoding.EncodeToString(contentHash.Sum(nil)),
+	}
+
+	return digest, written, metadata, nil
+}
+
+// loadEncrypted checks the digest of the stored content, then writes the decrypted content to w.
+func loadEncrypted(w io.Writer, store BlobStore, digest string, metadata *repository.BlobMetadata) error {
+	stream, err := verifyEncrypted(store, digest, metadata)
+	if err != nil {
+		return err
+	}
+
+	// do it once more (no way to avoid it)
+	return decrypt(w, store, digest, stream())
+}
+
+// verifyEncrypted decrypts the stored content and checks it against the digest, and against the hash of
+// the plain content if it is known, i.e. it detects the stored bytes that rotted or were tampered with.
+// It returns the factory of the keystream for the next pass.
+func verifyEncrypted(store BlobStore, digest string, metadata *repository.BlobMetadata) (func() cipher.Stream, error) {
+	salt, err := b64.RawURLEncoding.DecodeString(metadata.Salt)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := b64.RawURLEncoding.DecodeString(metadata.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	iv, err := b64.RawURLEncoding.DecodeString(metadata.Iv)
+	if err != nil {
+		return nil, err
+	}
+
+	aes, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.New()
+	contentHash := sha256.New()
+
+	_, err = hash.Write(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = decrypt(io.MultiWriter(hash, contentHash), store, digest, cipher.NewCTR(aes, iv))
+	if err != nil {
+		return nil, err
+	}
+
+	if b64.RawURLEncoding.EncodeToString(hash.Sum(nil)) != digest {
+		return nil, repository.ErrCorruptContent
+	}
+
+	if len(metadata.Hash) > 0 && b64.RawURLEncoding.EncodeToString(contentHash.Sum(nil)) != metadata.Hash {
+		return nil, repository.ErrCorruptContent
+	}
+
+	return func() cipher.Stream { return cipher.NewCTR(aes, iv) }, nil
+}
+
+func decrypt(w io.Writer, store BlobStore, digest string, stream cipher.Stream) error {
+	out, err := store.Get(digest)
+	if err != nil {
+		// the resource file not found
+		return err
+	}
+	defer out.Close()
+
+	pipeReader, pipeWriter := io.Pipe()
+	defer pipeReader.Close()
+	writer := &cipher.StreamWriter{S: stream, W: pipeWriter}
+
+	// do the decryption in a goroutine
+	go func() {
+		_, err := io.Copy(writer, out)
+		if err != nil {
+			pipeWriter.CloseWithError(err)
+			return
+		}
+		defer pipeWriter.Close()
+	}()
+
+	_, err = io.Copy(w, pipeReader)
+
+	return err
+}
+
+// openEncrypted returns the decrypted content to be read from any offset, e.g. to serve the ranges of
+// a download. The digest isn't checked, as it covers the whole content.
+func openEncrypted(store BlobStore, digest string, size int64, metadata *repository.BlobMetadata) (io.ReadSeekCloser, error) {
+	key, err := b64.RawURLEncoding.DecodeString(metadata.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	iv, err := b64.RawURLEncoding.DecodeString(metadata.Iv)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(iv) != block.BlockSize() {
+		return nil, repository.ErrWrongResourceDigest
+	}
+
+	object, err := store.Open(digest)
+	if err != nil {
+		return nil, err
+	}
+
+	return &decryptReader{object: object, block: block, iv: iv, size: size}, nil
+}
+
+// decryptReader decrypts the stored bytes from the offset, the CTR keystream is positioned on each seek.
+type decryptReader struct {
+	object io.ReadSeekCloser
+	block  cipher.Block
+	iv     []byte
+	size   int64
+	offset int64
+	stream cipher.Stream // nil until the first read after a seek
+}
+
+func (r *decryptReader) Read(p []byte) (int, error) {
+	if r.stream == nil {
+		_, err := r.object.Seek(r.offset, io.SeekStart)
+		if err != nil {
+			return 0, err
+		}
+
+		r.stream = ctrStreamAt(r.block, r.iv, r.offset)
+	}
+
+	n, err := r.object.Read(p)
+	r.stream.XORKeyStream(p[:n], p[:n])
+	r.offset += int64(n)
+
+	return n, err
+}
+
+func (r *decryptReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	}
+
+	if offset < 0 {
+		return 0, errors.New("negative offset")
+	}
+
+	if offset != r.offset || r.stream == nil {
+		r.offset = offset
+		r.stream = nil
+	}
+
+	return offset, nil
+}
+
+func (r *decryptReader) Close() error {
+	return r.object.Close()
+}
+
+// ctrStreamAt returns the CTR keystream of the iv positioned at the offset, the counter block is the iv
+// incremented by the number of the blocks before, as cipher.NewCTR does.
+func ctrStreamAt(block cipher.Block, iv []byte, offset int64) cipher.Stream {
+	counter := make([]byte, len(iv))
+	copy(counter, iv)
+
+	carry := uint64(offset / int64(block.BlockSize()))
+	for i := len(counter) - 1; i >= 0 && carry > 0; i-- {
+		sum := uint64(counter[i]) + carry&0xff
+		counter[i] = byte(sum)
+		carry = carry>>8 + sum>>8
+	}
+
+	stream := cipher.NewCTR(block, counter)
+
+	skip := make([]byte, offset%int64(block.BlockSize()))
+	stream.XORKeyStream(skip, skip)
+
+	return stream
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// genericContentTypes tell nothing about the content, the sniffed type is stored instead.
+var genericContentTypes = map[string]bool{
+	"":                         true,
+	"application/octet-stream": true,
+	"binary/octet-stream":      true,
+	"application/unknown":      true,
+	"application/x-unknown":    true,
+}
+
+// detectContentType returns the content type of the upload, i.e. the client one if it is specific, or the
+// one sniffed from the first 512 bytes. The returned reader yields the whole content again.
+func detectContentType(content io.Reader, contentType string) (io.Reader, string, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(contentType))
+	}
+
+	if !genericContentTypes[mediaType] {
+		return content, contentType, nil
+	}
+
+	head := make([]byte, 512)
+
+	n, err := io.ReadFull(content, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, "", err
+	}
+
+	head = head[:n]
+
+	// nothing to sniff
+	if n == 0 {
+		return bytes.NewReader(head), contentType, nil
+	}
+
+	return io.MultiReader(bytes.NewReader(head), content), http.DetectContentType(head), nil
+}
+
+// snippetHeadSize is how much of a text blob is kept for its snippet, the markup of an html one included.
+const snippetHeadSize = 64 << 10
+
+// headWriter keeps the first bytes written to it, the rest is discarded.
+type headWriter struct {
+	head []byte
+	max  int
+}
+
+func (w *headWriter) Write(p []byte) (int, error) {
+	if room := w.max - len(w.head); room > 0 {
+		if len(p) < room {
+			room = len(p)
+		}
+		w.head = append(w.head, p[:room]...)
+	}
+
+	return len(p), nil
+}
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDetectContentType(t *testing.T) {
+	pdf := "%PDF-1.4\n" + strings.Repeat("x", 1024)
+
+	tests := []struct {
+		content     string
+		contentType string
+		want        string
+	}{
+		{pdf, "application/pdf", "application/pdf"},
+		{pdf, "text/plain; charset=utf-8", "text/plain; charset=utf-8"},
+		{pdf, "application/octet-stream", "application/pdf"},
+		{pdf, "Application/Octet-Stream; name=report", "application/pdf"},
+		{pdf, "", "application/pdf"},
+		{"Hello Bob", "binary/octet-stream", "text/plain; charset=utf-8"},
+		{"", "application/octet-stream", "application/octet-stream"},
+	}
+
+	for _, tt := range tests {
+		content, contentType, err := detectContentType(strings.NewReader(tt.content), tt.contentType)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if contentType != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.contentType, contentType, tt.want)
+		}
+
+		// the sniffed bytes are read again
+		data, err := io.ReadAll(content)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != tt.content {
+			t.Errorf("%q: got %d bytes of the content, want %d", tt.contentType, len(data), len(tt.content))
+		}
+	}
+}
+
+func TestStoreSniffsContentType(t *testing.T) {
+	storage, _, user := newTestStorage(t)
+
+	blob, err := storage.Blobs.Store(user, strings.NewReader("%PDF-1.4"), "report", "application/octet-stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := storage.Files.Store(user, strings.NewReader("%PDF-1.4"), "report", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if blob.ContentType != "application/pdf" || file.ContentType != "application/pdf" {
+		t.Errorf("got the blob of %q and the file of %q, want %q", blob.ContentType, file.ContentType, "application/pdf")
+	}
+}
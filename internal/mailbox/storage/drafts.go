@@ -6,9 +6,16 @@ import (
 
 type UseDraftStorage interface {
 	Create(user *repository.User, draft *repository.Draft) (*repository.Draft, error)
-	List(user *repository.User) (*repository.DraftList, error)
+	List(user *repository.User, options *repository.ListOptions) (*repository.DraftList, error)
+	ListEach(user *repository.User, options *repository.ListOptions, each func(*repository.Draft) error) (*repository.DraftList, error)
+	Search(user *repository.User, q string) ([]*repository.Draft, error)
+	ListTrashed(user *repository.User) (*repository.DraftList, error)
+	GetById(user *repository.User, id string) (*repository.Draft, error)
 	Sync(user *repository.User, history *repository.History) (*repository.DraftSync, error)
 	Update(user *repository.User, draft *repository.Draft) (*repository.Draft, error)
+	ListVersions(user *repository.User, id string) ([]*repository.DraftVersion, error)
+	RestoreVersion(user *repository.User, id string, versionId string) (*repository.Draft, error)
+	RenderMIME(user *repository.User, draft *repository.Draft) ([]byte, error)
 	// Trash(user *repository.User, ids string) error
 	// Untrash(user *repository.User, ids string) error
 	// Delete(user *repository.User, ids string) error
@@ -18,19 +25,76 @@ type UseDraftStorage interface {
 type DraftStorage struct {
 	repository  repository.Repository
 	blobStorage BlobStorage
+	fileStorage FileStorage
 }
 
 func (s *DraftStorage) Create(user *repository.User, draft *repository.Draft) (*repository.Draft, error) {
+	// the body parts are moved to blobs by the placeholder message
+	searchText := draft.Payload.Text()
+
 	draft, err := ComposePlaceholderMessage(user, s.blobStorage, draft)
 	if err != nil {
 		return nil, err
 	}
 
+	draft.SearchText = &searchText
+
 	return s.repository.Drafts.Create(user, draft)
 }
 
-func (s *DraftStorage) List(user *repository.User) (*repository.DraftList, error) {
-	draftList, err := s.repository.Drafts.List(user)
+func (s *DraftStorage) List(user *repository.User, options *repository.ListOptions) (*repository.DraftList, error) {
+	draftList, err := s.repository.Drafts.List(user, options)
+	if err != nil {
+		return nil, err
+	}
+
+	drafts, err := ParsePlaceholderMessage(user, s.repository, s.blobStorage, draftList.Drafts)
+	if err != nil {
+		return nil, err
+	}
+
+	draftList.Drafts = drafts
+
+	return draftList, err
+}
+
+// ListEach parses the placeholder message of each draft as it is read, see the List of the repository.
+func (s *DraftStorage) ListEach(user *repository.User, options *repository.ListOptions, each func(*repository.Draft) error) (*repository.DraftList, error) {
+	return s.repository.Drafts.ListEach(user, options, func(draft *repository.Draft) error {
+		drafts, err := ParsePlaceholderMessage(user, s.repository, s.blobStorage, []*repository.Draft{draft})
+		if err != nil {
+			return err
+		}
+
+		return each(drafts[0])
+	})
+}
+
+func (s *DraftStorage) Search(user *repository.User, q string) ([]*repository.Draft, error) {
+	drafts, err := s.repository.Drafts.Search(user, q)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParsePlaceholderMessage(user, s.repository, s.blobStorage, drafts)
+}
+
+func (s *DraftStorage) GetById(user *repository.User, id string) (*repository.Draft, error) {
+	draft, err := s.repository.Drafts.GetById(user, id)
+	if err != nil {
+		return nil, err
+	}
+
+	drafts, err := ParsePlaceholderMessage(user, s.repository, s.blobStorage, []*repository.Draft{draft})
+	if err != nil {
+		return nil, err
+	}
+
+	return drafts[0], nil
+}
+
+func (s *DraftStorage) ListTrashed(user *repository.User) (*repository.DraftList, error) {
+	draftList, err := s.repository.Drafts.ListTrashed(user)
 	if err != nil {
 		return nil, err
 	}
@@ -84,10 +148,55 @@ func (s *DraftStorage) Update(user *repository.User, draft *repository.Draft) (*
 		return nil, err
 	}
 
+	// the body parts are moved to blobs by the placeholder message
+	searchText := draft.Payload.Text()
+
 	draft, err = ComposePlaceholderMessage(user, s.blobStorage, draft)
 	if err != nil {
 		return nil, err
 	}
 
+	draft.SearchText = &searchText
+
 	return s.repository.Drafts.Update(user, draft)
 }
+
+func (s *DraftStorage) ListVersions(user *repository.User, id string) ([]*repository.DraftVersion, error) {
+	versions, err := s.repository.Drafts.ListVersions(user, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// the versions hold the placeholder messages like the drafts
+	drafts := make([]*repository.Draft, len(versions))
+	for i, version := range versions {
+		drafts[i] = &repository.Draft{Payload: version.Payload}
+	}
+
+	_, err = ParsePlaceholderMessage(user, s.repository, s.blobStorage, drafts)
+	if err != nil {
+		return nil, err
+	}
+
+	return versions, nil
+}
+
+func (s *DraftStorage) RestoreVersion(user *repository.User, id string, versionId string) (*repository.Draft, error) {
+	draft, err := s.repository.Drafts.RestoreVersion(user, id, versionId)
+	if err != nil {
+		return nil, err
+	}
+
+	drafts, err := ParsePlaceholderMessage(user, s.repository, s.blobStorage, []*repository.Draft{draft})
+	if err != nil {
+		return nil, err
+	}
+
+	return drafts[0], nil
+}
+
+// RenderMIME renders the placeholder message of the draft, as the repository returns it, to the MIME message
+// sent through the relay, the Bcc header is left out.
+func (s *DraftStorage) RenderMIME(user *repository.User, draft *repository.Draft) ([]byte, error) {
+	return renderMIME(user, s.repository, s.blobStorage, s.fileStorage, draft.Payload, "Bcc")
+}
@@ -0,0 +1,373 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"cargomail/internal/mailbox/repository"
+	"cargomail/internal/shared/config"
+	"encoding/binary"
+	"io"
+	"mime"
+)
+
+// stripImageMetadata drops the EXIF (e.g. the GPS position, the camera), XMP and IPTC metadata of the JPEG
+// and the TIFF images, the orientation is preserved. The other content types are returned untouched. The
+// returned reader has to be closed, the JPEG is rewritten by a goroutine until then. The TIFF is read whole,
+// up to the upload limit of its content type.
+func stripImageMetadata(content io.Reader, contentType string) (io.ReadCloser, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return io.NopCloser(content), nil
+	}
+
+	switch mediaType {
+	case "image/jpeg", "image/pjpeg":
+		pipeReader, pipeWriter := io.Pipe()
+
+		// do the rewrite in a goroutine, the closed reader fails its writes
+		go func() {
+			pipeWriter.CloseWithError(stripJpeg(pipeWriter, content))
+		}()
+
+		return pipeReader, nil
+	case "image/tiff":
+		limit := config.MaxUploadBytesFor(contentType)
+
+		data, err := io.ReadAll(io.LimitReader(content, limit+1))
+		if err != nil {
+			return nil, err
+		}
+
+		if int64(len(data)) > limit {
+			return nil, repository.ErrUploadTooLarge
+		}
+
+		err = stripTiff(data)
+		if err != nil {
+			return nil, err
+		}
+
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+
+	return io.NopCloser(content), nil
+}
+
+const (
+	jpegMarkerSOI   = 0xd8
+	jpegMarkerEOI   = 0xd9
+	jpegMarkerSOS   = 0xda
+	jpegMarkerAPP0  = 0xe0
+	jpegMarkerAPP1  = 0xe1 // EXIF, XMP
+	jpegMarkerAPP13 = 0xed // IPTC
+	jpegMarkerCOM   = 0xfe
+)
+
+var exifHeader = []byte("Exif\x00\x00")
+
+type jpegSegment struct {
+	marker  byte
+	payload []byte // nil for the standalone markers
+}
+
+// stripJpeg copies the JPEG dropping the metadata segments before the scan, the entropy-coded data is
+// copied as is. An orientation other than the default one is written back as a minimal EXIF segment.
+func stripJpeg(w io.Writer, content io.Reader) error {
+	r := bufio.NewReader(content)
+
+	soi := make([]byte, 2)
+	_, err := io.ReadFull(r, soi)
+	if err != nil || soi[0] != 0xff || soi[1] != jpegMarkerSOI {
+		return repository.ErrInvalidImage
+	}
+
+	segments := []jpegSegment{}
+	orientation := uint16(1)
+
+	for {
+		marker, err := readJpegMarker(r)
+		if err != nil {
+			return err
+		}
+
+		// the standalone markers
+		if marker == jpegMarkerEOI || marker == 0x01 || (marker >= 0xd0 && marker <= 0xd7) {
+			segments = append(segments, jpegSegment{marker: marker})
+
+			if marker == jpegMarkerEOI {
+				return writeJpeg(w, segments, orientation, nil)
+			}
+			continue
+		}
+
+		length := make([]byte, 2)
+		_, err = io.ReadFull(r, length)
+		if err != nil {
+			return repository.ErrInvalidImage
+		}
+
+		size := int(binary.BigEndian.Uint16(length))
+		if size < 2 {
+			return repository.ErrInvalidImage
+		}
+
+		payload := make([]byte, size-2)
+		_, err = io.ReadFull(r, payload)
+		if err != nil {
+			return repository.ErrInvalidImage
+		}
+
+		switch marker {
+		case jpegMarkerAPP1:
+			if bytes.HasPrefix(payload, exifHeader) {
+				if o := exifOrientation(payload[len(exifHeader):]); o > 1 && o <= 8 {
+					orientation = o
+				}
+			}
+			continue
+		case jpegMarkerAPP13, jpegMarkerCOM:
+			continue
+		}
+
+		segments = append(segments, jpegSegment{marker: marker, payload: payload})
+
+		if marker == jpegMarkerSOS {
+			return writeJpeg(w, segments, orientation, r)
+		}
+	}
+}
+
+func readJpegMarker(r *bufio.Reader) (byte, error) {
+	b, err := r.ReadByte()
+	if err != nil || b != 0xff {
+		return 0, repository.ErrInvalidImage
+	}
+
+	// skip the fill bytes
+	for b == 0xff {
+		b, err = r.ReadByte()
+		if err != nil {
+			return 0, repository.ErrInvalidImage
+		}
+	}
+
+	return b, nil
+}
+
+func writeJpeg(w io.Writer, segments []jpegSegment, orientation uint16, scan io.Reader) error {
+	// the orientation goes after the JFIF segment, which comes first
+	if orientation != 1 {
+		i := 0
+		if len(segments) > 0 && segments[0].marker == jpegMarkerAPP0 {
+			i = 1
+		}
+
+		exif := jpegSegment{marker: jpegMarkerAPP1, payload: orientationExif(orientation)}
+		segments = append(segments[:i], append([]jpegSegment{exif}, segments[i:]...)...)
+	}
+
+	buf := bufio.NewWriter(w)
+
+	buf.Write([]byte{0xff, jpegMarkerSOI})
+
+	for _, segment := range segments {
+		buf.Write([]byte{0xff, segment.marker})
+
+		if segment.payload != nil {
+			length := make([]byte, 2)
+			binary.BigEndian.PutUint16(length, uint16(len(segment.payload)+2))
+			buf.Write(length)
+			buf.Write(segment.payload)
+		}
+	}
+
+	if scan != nil {
+		_, err := io.Copy(buf, scan)
+		if err != nil {
+			return err
+		}
+	}
+
+	return buf.Flush()
+}
+
+// orientationExif returns the EXIF segment payload holding the orientation only.
+func orientationExif(orientation uint16) []byte {
+	payload := append([]byte{}, exifHeader...)
+
+	payload = append(payload, 'M', 'M', 0, 42, 0, 0, 0, 8)  // big endian, IFD0 at 8
+	payload = append(payload, 0, 1)                         // one entry
+	payload = append(payload, 0x01, 0x12, 0, 3, 0, 0, 0, 1) // orientation, SHORT, 1
+	payload = append(payload, byte(orientation>>8), byte(orientation), 0, 0)
+	payload = append(payload, 0, 0, 0, 0) // no next IFD
+
+	return payload
+}
+
+// exifOrientation returns the orientation of the IFD0 of the TIFF structure of the EXIF segment, zero if
+// it has none.
+func exifOrientation(tiff []byte) uint16 {
+	order, ifd, ok := tiffHeader(tiff)
+	if !ok || ifd+2 > len(tiff) {
+		return 0
+	}
+
+	count := int(order.Uint16(tiff[ifd:]))
+
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+
+		if order.Uint16(tiff[entry:]) == 0x0112 && order.Uint16(tiff[entry+2:]) == 3 {
+			return order.Uint16(tiff[entry+8:])
+		}
+	}
+
+	return 0
+}
+
+func tiffHeader(tiff []byte) (binary.ByteOrder, int, bool) {
+	if len(tiff) < 8 {
+		return nil, 0, false
+	}
+
+	var order binary.ByteOrder
+
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, 0, false
+	}
+
+	if order.Uint16(tiff[2:]) != 42 {
+		return nil, 0, false
+	}
+
+	return order, int(order.Uint32(tiff[4:])), true
+}
+
+// the tags of the IFD0 removed from the TIFF images, the EXIF and the GPS ones point to their own IFDs
+var tiffPrivateTags = map[uint16]bool{
+	0x010f: true, // Make
+	0x0110: true, // Model
+	0x0131: true, // Software
+	0x0132: true, // DateTime
+	0x013b: true, // Artist
+	0x02bc: true, // XMP
+	0x8298: true, // Copyright
+	0x83bb: true, // IPTC
+	0x8649: true, // Photoshop
+	0x8769: true, // EXIF IFD
+	0x8825: true, // GPS IFD
+	0x9c9b: true, // XPTitle
+	0x9c9c: true, // XPComment
+	0x9c9d: true, // XPAuthor
+	0x9c9e: true, // XPKeywords
+	0x9c9f: true, // XPSubject
+}
+
+// the IFDs pointed to by the tags, zeroed as a whole
+var tiffSubIfdTags = map[uint16]bool{
+	0x8769: true, // EXIF IFD
+	0x8825: true, // GPS IFD
+	0xa005: true, // Interoperability IFD
+}
+
+var tiffTypeSizes = map[uint16]int{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8, 13: 4}
+
+// stripTiff removes the private tags of the IFDs of the TIFF image in place, the values and the sub-IFDs
+// they point to are zeroed so nothing is left in the bytes. The image data is untouched.
+func stripTiff(tiff []byte) error {
+	order, ifd, ok := tiffHeader(tiff)
+	if !ok {
+		return repository.ErrInvalidImage
+	}
+
+	visited := map[int]bool{}
+
+	for ifd != 0 {
+		if visited[ifd] || ifd+2 > len(tiff) {
+			return repository.ErrInvalidImage
+		}
+		visited[ifd] = true
+
+		count := int(order.Uint16(tiff[ifd:]))
+		end := ifd + 2 + count*12
+		if end+4 > len(tiff) {
+			return repository.ErrInvalidImage
+		}
+
+		next := int(order.Uint32(tiff[end:]))
+
+		kept := make([]byte, 0, count*12)
+
+		for i := 0; i < count; i++ {
+			entry := tiff[ifd+2+i*12 : ifd+2+(i+1)*12]
+			tag := order.Uint16(entry)
+
+			if !tiffPrivateTags[tag] {
+				kept = append(kept, entry...)
+				continue
+			}
+
+			zeroTiffValue(tiff, order, entry, visited)
+		}
+
+		// the kept entries move up, the freed room is zeroed
+		order.PutUint16(tiff[ifd:], uint16(len(kept)/12))
+		copy(tiff[ifd+2:], kept)
+		order.PutUint32(tiff[ifd+2+len(kept):], uint32(next))
+
+		for i := ifd + 2 + len(kept) + 4; i < end+4; i++ {
+			tiff[i] = 0
+		}
+
+		ifd = next
+	}
+
+	return nil
+}
+
+// zeroTiffValue zeroes the value of the entry stored out of it, and the IFD it points to.
+func zeroTiffValue(tiff []byte, order binary.ByteOrder, entry []byte, visited map[int]bool) {
+	tag := order.Uint16(entry)
+	size := tiffTypeSizes[order.Uint16(entry[2:])] * int(order.Uint32(entry[4:]))
+	offset := int(order.Uint32(entry[8:]))
+
+	if tiffSubIfdTags[tag] {
+		zeroTiffIfd(tiff, order, offset, visited)
+		return
+	}
+
+	if size > 4 && offset >= 0 && offset+size <= len(tiff) {
+		for i := offset; i < offset+size; i++ {
+			tiff[i] = 0
+		}
+	}
+}
+
+func zeroTiffIfd(tiff []byte, order binary.ByteOrder, ifd int, visited map[int]bool) {
+	if visited[ifd] || ifd <= 0 || ifd+2 > len(tiff) {
+		return
+	}
+	visited[ifd] = true
+
+	count := int(order.Uint16(tiff[ifd:]))
+	end := ifd + 2 + count*12
+	if end+4 > len(tiff) {
+		return
+	}
+
+	for i := 0; i < count; i++ {
+		zeroTiffValue(tiff, order, tiff[ifd+2+i*12:ifd+2+(i+1)*12], visited)
+	}
+
+	for i := ifd; i < end+4; i++ {
+		tiff[i] = 0
+	}
+}
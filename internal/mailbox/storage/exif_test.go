@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"bytes"
+	"cargomail/internal/mailbox/repository"
+	"cargomail/internal/shared/config"
+	"errors"
+	"io"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// testJpeg returns a JPEG of an EXIF segment and a scan of the size.
+func testJpeg(scan int) []byte {
+	jpeg := []byte{0xff, jpegMarkerSOI}
+
+	exif := append(append([]byte{}, exifHeader...), "MM\x00\x2a\x00\x00\x00\x08\x00\x00"...)
+	jpeg = append(jpeg, 0xff, jpegMarkerAPP1, 0, byte(len(exif)+2))
+	jpeg = append(jpeg, exif...)
+
+	jpeg = append(jpeg, 0xff, jpegMarkerSOS, 0, 2)
+	jpeg = append(jpeg, bytes.Repeat([]byte{0x55}, scan)...)
+
+	return append(jpeg, 0xff, jpegMarkerEOI)
+}
+
+func TestStripImageMetadataJpeg(t *testing.T) {
+	stripped, err := stripImageMetadata(bytes.NewReader(testJpeg(16)), "image/jpeg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stripped.Close()
+
+	data, err := io.ReadAll(stripped)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(data, exifHeader) {
+		t.Error("the EXIF segment kept")
+	}
+}
+
+// the reader closed before the rewrite is read to the end, e.g. on a failed store, stops the goroutine
+func TestStripImageMetadataClose(t *testing.T) {
+	goroutines := runtime.NumGoroutine()
+
+	for i := 0; i < 10; i++ {
+		stripped, err := stripImageMetadata(bytes.NewReader(testJpeg(1<<20)), "image/jpeg")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = io.ReadFull(stripped, make([]byte, 64))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stripped.Close()
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for runtime.NumGoroutine() > goroutines {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d goroutines, want %d", runtime.NumGoroutine(), goroutines)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStripImageMetadataTiffLimit(t *testing.T) {
+	maxUploadBytes := config.Configuration.MaxUploadBytes
+	config.Configuration.MaxUploadBytes = "64"
+	t.Cleanup(func() { config.Configuration.MaxUploadBytes = maxUploadBytes })
+
+	tiff := append([]byte("MM\x00\x2a\x00\x00\x00\x08"), make([]byte, 128)...)
+
+	_, err := stripImageMetadata(bytes.NewReader(tiff), "image/tiff")
+	if !errors.Is(err, repository.ErrUploadTooLarge) {
+		t.Errorf("got %v, want %v", err, repository.ErrUploadTooLarge)
+	}
+}
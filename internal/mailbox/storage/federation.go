@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"cargomail/internal/mailbox/repository"
+)
+
+type UseFederationStorage interface {
+	Envelope(delivery *repository.FederationDelivery) (*repository.FederationEnvelope, error)
+}
+
+type FederationStorage struct {
+	repository  repository.Repository
+	blobStorage BlobStorage
+	fileStorage FileStorage
+}
+
+// Envelope returns the envelope of the delivery as the peer gets it, the placeholder parts hold the content of
+// the blobs and the files of the sender, the peer can't load them. The content is loaded on every attempt,
+// the queued envelope is kept small.
+func (s *FederationStorage) Envelope(delivery *repository.FederationDelivery) (*repository.FederationEnvelope, error) {
+	if delivery.Envelope == nil || delivery.Envelope.Payload == nil {
+		return nil, repository.ErrEmptyPayload
+	}
+
+	user := &repository.User{Id: delivery.UserId}
+
+	payload, err := delivery.Envelope.Payload.Resolve(contentLoader(user, s.repository, s.blobStorage, s.fileStorage))
+	if err != nil {
+		return nil, err
+	}
+
+	return &repository.FederationEnvelope{
+		Sender:     delivery.Envelope.Sender,
+		Recipients: delivery.Envelope.Recipients,
+		Payload:    payload,
+	}, nil
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"bytes"
+	"cargomail/internal/mailbox/repository"
+	b64 "encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestFederationEnvelope(t *testing.T) {
+	storage, _, user := newTestStorage(t)
+
+	draft := newTestDraft(t, storage, user)
+
+	file, err := storage.Files.Store(user, strings.NewReader("%PDF-1.4"), "report.pdf", "application/pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	draft.Payload.Parts = append(draft.Payload.Parts, &repository.MessagePart{
+		Headers: map[string]interface{}{
+			"Content-Disposition": `attachment; filename="report.pdf"`,
+			"Content-ID":          "<" + file.Digest + ">",
+			"Content-Type":        []interface{}{`message/external-body; access-type="x-content-addressed-uri"`, "application/pdf"},
+		},
+	})
+
+	placeholder, err := json.Marshal(draft.Payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	envelope, err := storage.Federation.Envelope(&repository.FederationDelivery{
+		UserId:   user.Id,
+		Domain:   "example.net",
+		Envelope: &repository.FederationEnvelope{Sender: "alice@example.org", Recipients: []string{"bob@example.net"}, Payload: draft.Payload},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var contents []string
+
+	var walk func(part *repository.MessagePart)
+
+	walk = func(part *repository.MessagePart) {
+		if _, ok := part.Headers["Content-Type"].(string); !ok {
+			t.Errorf("part of the placeholder Content-Type %v left", part.Headers["Content-Type"])
+		}
+
+		if part.Body != nil && part.Headers["Content-Transfer-Encoding"] == "base64" {
+			data, err := b64.StdEncoding.DecodeString(part.Body.Data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			contents = append(contents, part.Headers["Content-Type"].(string)+": "+string(data))
+		}
+
+		for _, p := range part.Parts {
+			walk(p)
+		}
+	}
+
+	walk(envelope.Payload)
+
+	want := []string{
+		"text/plain; charset=utf-8: Hello Bob",
+		`text/html; charset=utf-8: <p>Hello Bob <img src="cid:logo"></p>`,
+		"image/png: \x89PNG\r\n\x1a\n",
+		"application/pdf: %PDF-1.4",
+	}
+
+	if strings.Join(contents, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got the contents\n%q\nwant\n%q", contents, want)
+	}
+
+	// the queued placeholder message is left as is
+	unchanged, err := json.Marshal(draft.Payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(placeholder, unchanged) {
+		t.Error("the placeholder message changed")
+	}
+}
+
+func TestFederationEnvelopeFileNotFound(t *testing.T) {
+	storage, _, user := newTestStorage(t)
+
+	_, err := storage.Federation.Envelope(&repository.FederationDelivery{
+		UserId: user.Id,
+		Domain: "example.net",
+		Envelope: &repository.FederationEnvelope{
+			Sender:     "alice@example.org",
+			Recipients: []string{"bob@example.net"},
+			Payload: &repository.MessagePart{
+				Headers: map[string]interface{}{
+					"Content-ID":   "<deleted>",
+					"Content-Type": []interface{}{`message/external-body; access-type="x-content-addressed-uri"`, "application/pdf"},
+				},
+			},
+		},
+	})
+	if !errors.Is(err, repository.ErrFileNotFound) {
+		t.Errorf("got %v, want %v", err, repository.ErrFileNotFound)
+	}
+}
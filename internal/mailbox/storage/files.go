@@ -2,196 +2,106 @@ package storage
 
 import (
 	"cargomail/internal/mailbox/repository"
-	"crypto/aes"
-	"crypto/cipher"
-	"crypto/rand"
-	"crypto/sha256"
-	b64 "encoding/base64"
+	"cargomail/internal/shared/config"
 	"io"
-	"mime/multipart"
-	"net/http"
-	"os"
-	"path/filepath"
+	"log"
 )
 
 type UseFileStorage interface {
-	Store(user *repository.User, file multipart.File, filesPath, uuid, filename, contentType string) (*repository.File, error)
-	Load(w http.ResponseWriter, file *repository.File, filePath string) error
+	Store(user *repository.User, file io.Reader, filename, contentType string) (*repository.File, error)
+	Load(w io.Writer, file *repository.File) error
+	Open(file *repository.File) (io.ReadSeekCloser, error)
+	Verify(file *repository.File) error
+	Delete(user *repository.User, ids string) error
 }
 
 type FileStorage struct {
 	repository repository.Repository
+	store      BlobStore
 }
 
-func (s *FileStorage) Store(user *repository.User, file multipart.File, filesPath, uuid, filename, contentType string) (*repository.File, error) {
-	f, err := os.OpenFile(filepath.Join(filesPath, uuid), os.O_WRONLY|os.O_CREATE, 0666)
+func (s *FileStorage) Store(user *repository.User, file io.Reader, filename, contentType string) (*repository.File, error) {
+	file, contentType, err := detectContentType(file, contentType)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
 
-	salt := make([]byte, repository.SaltSize)
-	_, err = rand.Read(salt)
-	if err != nil {
-		return nil, err
-	}
-
-	key := make([]byte, repository.KeySize)
-	_, err = rand.Read(key)
-	if err != nil {
-		return nil, err
-	}
-
-	iv := make([]byte, repository.IvSize)
-	_, err = rand.Read(iv)
-	if err != nil {
-		return nil, err
-	}
-
-	hash := sha256.New()
-
-	_, err = hash.Write(salt)
-	if err != nil {
-		return nil, err
-	}
-
-	aes, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, err
-	}
-
-	stream := cipher.NewCTR(aes, iv)
-
-	pipeReader, pipeWriter := io.Pipe()
-	writer := &cipher.StreamWriter{S: stream, W: pipeWriter}
-
-	// do the encryption in a goroutine
-	go func() {
-		_, err := io.Copy(writer, io.TeeReader(file, hash))
+	if config.StripImageMetadata() {
+		stripped, err := stripImageMetadata(file, contentType)
 		if err != nil {
-			pipeWriter.CloseWithError(err)
-			return
+			return nil, err
 		}
-		defer pipeWriter.Close()
-	}()
+		defer stripped.Close()
+
+		file = stripped
+	}
 
-	written, err := io.Copy(f, pipeReader)
+	digest, written, metadata, err := storeEncrypted(s.store, file)
 	if err != nil {
 		return nil, err
 	}
 
-	hashSum := hash.Sum(nil)
-	digest := b64.RawURLEncoding.EncodeToString(hashSum)
-
-	fileMetadata := &repository.FileMetadata{
-		Salt: b64.RawURLEncoding.EncodeToString(salt),
-		Key:  b64.RawURLEncoding.EncodeToString(key),
-		Iv:   b64.RawURLEncoding.EncodeToString(iv),
-	}
+	fileMetadata := repository.FileMetadata(*metadata)
 
 	uploadedFile := &repository.File{
 		Digest:      digest,
 		Name:        filename,
 		Size:        written,
-		Metadata:    fileMetadata,
+		Metadata:    &fileMetadata,
 		ContentType: contentType,
 	}
 
 	uploadedFile, err = s.repository.Files.Create(user, uploadedFile)
 	if err != nil {
+		_ = s.store.Delete(digest)
 		return nil, err
 	}
 
-	os.Rename(filepath.Join(filesPath, uuid), filepath.Join(filesPath, digest))
-	if err != nil {
-		return nil, err
+	// the equal content was stored already
+	if uploadedFile.Digest != digest {
+		_ = s.store.Delete(digest)
 	}
 
 	return uploadedFile, nil
 }
 
-func (s *FileStorage) Load(w http.ResponseWriter, file *repository.File, filePath string) error {
-	out, err := os.Open(filePath)
-	if err != nil {
-		return err
-	}
-	defer out.Close()
+func (s *FileStorage) Load(w io.Writer, file *repository.File) error {
+	metadata := repository.BlobMetadata(*file.Metadata)
 
-	salt, err := b64.RawURLEncoding.DecodeString(file.Metadata.Salt)
-	if err != nil {
-		return err
-	}
-
-	key, err := b64.RawURLEncoding.DecodeString(file.Metadata.Key)
-	if err != nil {
-		return err
-	}
+	return loadEncrypted(w, s.store, file.Digest, &metadata)
+}
 
-	iv, err := b64.RawURLEncoding.DecodeString(file.Metadata.Iv)
-	if err != nil {
-		return err
-	}
+// Open returns the decrypted content for the range requests, unlike Load the digest isn't checked.
+func (s *FileStorage) Open(file *repository.File) (io.ReadSeekCloser, error) {
+	metadata := repository.BlobMetadata(*file.Metadata)
 
-	aes, err := aes.NewCipher(key)
-	if err != nil {
-		return err
-	}
+	return openEncrypted(s.store, file.Digest, file.Size, &metadata)
+}
 
-	stream := cipher.NewCTR(aes, iv)
+// Verify checks the stored bytes of the file against its digest and its hash.
+func (s *FileStorage) Verify(file *repository.File) error {
+	metadata := repository.BlobMetadata(*file.Metadata)
 
-	pipeReader, pipeWriter := io.Pipe()
-	writer := &cipher.StreamWriter{S: stream, W: pipeWriter}
+	_, err := verifyEncrypted(s.store, file.Digest, &metadata)
 
-	// do the encryption in a goroutine
-	go func() {
-		_, err := io.Copy(writer, out)
-		if err != nil {
-			pipeWriter.CloseWithError(err)
-			return
-		}
-		defer pipeWriter.Close()
-	}()
-
-	hash := sha256.New()
+	return err
+}
 
-	_, err = hash.Write(salt)
+// Delete deletes the files, the stored bytes are removed with the last file referencing them.
+func (s *FileStorage) Delete(user *repository.User, ids string) error {
+	_, err := s.repository.Files.Delete(user, ids)
 	if err != nil {
 		return err
 	}
 
-	_, err = io.Copy(hash, pipeReader)
+	digests, err := s.repository.Contents.Release(repository.FilesResource)
 	if err != nil {
-		return err
+		log.Printf("file contents release: %v", err)
+		return nil
 	}
 
-	hashSum := hash.Sum(nil)
-	digest := b64.RawURLEncoding.EncodeToString(hashSum)
-
-	if digest != file.Digest {
-		return repository.ErrWrongResourceDigest
-	}
-
-	// do it once more (no way to avoid it)
-	out.Seek(0, io.SeekStart)
-
-	stream2 := cipher.NewCTR(aes, iv)
-
-	pipeReader2, pipeWriter2 := io.Pipe()
-	writer2 := &cipher.StreamWriter{S: stream2, W: pipeWriter2}
-
-	// do the encryption in a goroutine
-	go func() {
-		_, err := io.Copy(writer2, out)
-		if err != nil {
-			pipeWriter2.CloseWithError(err)
-			return
-		}
-		defer pipeWriter2.Close()
-	}()
-
-	_, err = io.Copy(w, pipeReader2)
-	if err != nil {
-		return err
+	for i := range digests {
+		_ = s.store.Delete(digests[i])
 	}
 
 	return nil
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"cargomail/internal/mailbox/repository"
+	"time"
+)
+
+// Garbage is what a collection removed, or would remove on a dry run.
+type Garbage struct {
+	Released int64 // the contents no longer referenced
+	Orphaned int64 // the stored bytes with no row
+	Bytes    int64
+}
+
+// CollectGarbage removes the stored bytes of the resource (blobs|files) no row refers to. The objects
+// younger than the grace period are left alone, as an upload puts its bytes before its row is committed,
+// so it is safe to run it while the server is serving traffic.
+func CollectGarbage(repo repository.Repository, resource string, store BlobStore, grace time.Duration, dryRun bool) (*Garbage, error) {
+	garbage := &Garbage{}
+
+	var released []string
+	var err error
+
+	if dryRun {
+		released, err = repo.Contents.Unreferenced(resource)
+	} else {
+		released, err = repo.Contents.Release(resource)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, digest := range released {
+		size, err := store.Stat(digest)
+		if err != nil {
+			continue
+		}
+
+		if !dryRun {
+			err = store.Delete(digest)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		garbage.Released++
+		garbage.Bytes += size
+	}
+
+	// the referenced digests are read after the release, a content released can't be taken again
+	referenced, err := repo.Contents.Referenced(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, digest := range released {
+		referenced[digest] = true
+	}
+
+	cutoff := time.Now().Add(-grace)
+
+	err = store.Walk(func(digest string, size int64, modTime time.Time) error {
+		if referenced[digest] || modTime.After(cutoff) {
+			return nil
+		}
+
+		if !dryRun {
+			err := store.Delete(digest)
+			if err != nil {
+				return err
+			}
+		}
+
+		garbage.Orphaned++
+		garbage.Bytes += size
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return garbage, nil
+}
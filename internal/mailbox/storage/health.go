@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+type UseHealthStorage interface {
+	Check() error
+}
+
+// HealthStorage checks the blob stores can be written to, the probe object is removed right away.
+type HealthStorage struct {
+	blobStore BlobStore
+	fileStore BlobStore
+}
+
+func (s *HealthStorage) Check() error {
+	err := probe(s.blobStore)
+	if err != nil {
+		return fmt.Errorf("blob store: %w", err)
+	}
+
+	err = probe(s.fileStore)
+	if err != nil {
+		return fmt.Errorf("file store: %w", err)
+	}
+
+	return nil
+}
+
+// probe writes, stats and deletes a small object, named apart from the digests.
+func probe(store BlobStore) error {
+	suffix := make([]byte, 8)
+	_, err := rand.Read(suffix)
+	if err != nil {
+		return err
+	}
+
+	name := ".health-" + hex.EncodeToString(suffix)
+
+	err = store.Put(name, strings.NewReader("ok"))
+	if err != nil {
+		return err
+	}
+
+	_, err = store.Stat(name)
+	if err != nil {
+		return err
+	}
+
+	return store.Delete(name)
+}
@@ -3,17 +3,23 @@ package storage
 import "cargomail/internal/mailbox/repository"
 
 type UseMessageStorage interface {
-	List(user *repository.User, folder int) (*repository.MessageList, error)
+	List(user *repository.User, folder int, options *repository.ListOptions) (*repository.MessageList, error)
+	ListEach(user *repository.User, folder int, options *repository.ListOptions, each func(*repository.Message) error) (*repository.MessageList, error)
+	ListTrashed(user *repository.User) (*repository.MessageList, error)
+	Search(user *repository.User, q string, limit int) ([]*repository.Message, error)
+	GetById(user *repository.User, id string) (*repository.Message, error)
 	Sync(user *repository.User, history *repository.History) (*repository.MessageSync, error)
+	RenderMIME(user *repository.User, message *repository.Message) ([]byte, error)
 }
 
 type MessageStorage struct {
 	repository  repository.Repository
 	blobStorage BlobStorage
+	fileStorage FileStorage
 }
 
-func (s *MessageStorage) List(user *repository.User, folder int) (*repository.MessageList, error) {
-	messageList, err := s.repository.Messages.List(user, folder)
+func (s *MessageStorage) List(user *repository.User, folder int, options *repository.ListOptions) (*repository.MessageList, error) {
+	messageList, err := s.repository.Messages.List(user, folder, options)
 	if err != nil {
 		return nil, err
 	}
@@ -28,6 +34,57 @@ func (s *MessageStorage) List(user *repository.User, folder int) (*repository.Me
 	return messageList, err
 }
 
+// ListEach parses the placeholder message of each message as it is read, see the List of the repository.
+func (s *MessageStorage) ListEach(user *repository.User, folder int, options *repository.ListOptions, each func(*repository.Message) error) (*repository.MessageList, error) {
+	return s.repository.Messages.ListEach(user, folder, options, func(message *repository.Message) error {
+		messages, err := ParsePlaceholderMessage(user, s.repository, s.blobStorage, []*repository.Message{message})
+		if err != nil {
+			return err
+		}
+
+		return each(messages[0])
+	})
+}
+
+func (s *MessageStorage) ListTrashed(user *repository.User) (*repository.MessageList, error) {
+	messageList, err := s.repository.Messages.ListTrashed(user)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := ParsePlaceholderMessage(user, s.repository, s.blobStorage, messageList.Messages)
+	if err != nil {
+		return nil, err
+	}
+
+	messageList.Messages = messages
+
+	return messageList, err
+}
+
+func (s *MessageStorage) Search(user *repository.User, q string, limit int) ([]*repository.Message, error) {
+	messages, err := s.repository.Messages.Search(user, q, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParsePlaceholderMessage(user, s.repository, s.blobStorage, messages)
+}
+
+func (s *MessageStorage) GetById(user *repository.User, id string) (*repository.Message, error) {
+	message, err := s.repository.Messages.GetById(user, id)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := ParsePlaceholderMessage(user, s.repository, s.blobStorage, []*repository.Message{message})
+	if err != nil {
+		return nil, err
+	}
+
+	return messages[0], nil
+}
+
 func (s *MessageStorage) Sync(user *repository.User, history *repository.History) (*repository.MessageSync, error) {
 	messageList, err := s.repository.Messages.Sync(user, history)
 	if err != nil {
@@ -60,3 +117,13 @@ func (s *MessageStorage) Sync(user *repository.User, history *repository.History
 
 	return messageList, err
 }
+
+// RenderMIME renders the placeholder message, as the repository returns it, to a MIME message. The Bcc header
+// is kept in the sent messages only.
+func (s *MessageStorage) RenderMIME(user *repository.User, message *repository.Message) ([]byte, error) {
+	if message.Folder == 1 {
+		return renderMIME(user, s.repository, s.blobStorage, s.fileStorage, message.Payload)
+	}
+
+	return renderMIME(user, s.repository, s.blobStorage, s.fileStorage, message.Payload, "Bcc")
+}
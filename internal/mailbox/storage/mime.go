@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"bytes"
+	"cargomail/internal/mailbox/repository"
+)
+
+// renderMIME renders the placeholder message to a MIME message. The body parts are loaded from the blobs and
+// the attachments from the files, the omitted headers are left out of the message headers.
+func renderMIME(user *repository.User, repo repository.Repository, blobStorage BlobStorage, fileStorage FileStorage, placeholder *repository.MessagePart, omit ...string) ([]byte, error) {
+	if placeholder == nil {
+		return nil, repository.ErrEmptyPayload
+	}
+
+	payload := *placeholder
+	payload.Headers = make(map[string]interface{}, len(placeholder.Headers))
+
+	for k, v := range placeholder.Headers {
+		payload.Headers[k] = v
+	}
+
+	for _, k := range omit {
+		delete(payload.Headers, k)
+	}
+
+	return payload.RenderMIME(contentLoader(user, repo, blobStorage, fileStorage))
+}
+
+// contentLoader returns the load of the content of the placeholder parts of the user, the body parts are
+// loaded from the blobs and the attachments from the files.
+func contentLoader(user *repository.User, repo repository.Repository, blobStorage BlobStorage, fileStorage FileStorage) func(digest string) ([]byte, error) {
+	return func(digest string) ([]byte, error) {
+		buf := new(bytes.Buffer)
+
+		// the not found blob or file is an empty one
+		blob, err := repo.Blobs.GetByDigest(user, digest)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(blob.Id) > 0 {
+			err = blobStorage.Load(buf, blob)
+			return buf.Bytes(), err
+		}
+
+		file, err := repo.Files.GetByDigest(user, digest)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(file.Id) == 0 {
+			return nil, repository.ErrFileNotFound
+		}
+
+		err = fileStorage.Load(buf, file)
+
+		return buf.Bytes(), err
+	}
+}
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"bytes"
+	b64 "encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+// mimeLeaves returns the leaf parts of the MIME entity as "<path> <media type>: <decoded content>", the
+// path is the one of the multipart media types of the parents.
+func mimeLeaves(t *testing.T, header textproto.MIMEHeader, body io.Reader, path string) []string {
+	t.Helper()
+
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		var leaves []string
+
+		reader := multipart.NewReader(body, params["boundary"])
+
+		for {
+			part, err := reader.NextRawPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			leaves = append(leaves, mimeLeaves(t, part.Header, part, path+mediaType+"/")...)
+		}
+
+		return leaves
+	}
+
+	switch header.Get("Content-Transfer-Encoding") {
+	case "base64":
+		body = b64.NewDecoder(b64.StdEncoding, body)
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	}
+
+	content, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return []string{path + mediaType + ": " + strings.TrimSuffix(string(content), "\r\n")}
+}
+
+func TestRenderMIME(t *testing.T) {
+	storage, _, user := newTestStorage(t)
+
+	draft := newTestDraft(t, storage, user)
+	draft.Payload.Headers["Bcc"] = "carol@example.net"
+
+	rendered, err := storage.Drafts.RenderMIME(user, draft)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message, err := mail.ReadMessage(bytes.NewReader(rendered))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(message.Header.Get("Bcc")) > 0 {
+		t.Error("the Bcc header rendered")
+	}
+
+	leaves := mimeLeaves(t, textproto.MIMEHeader(message.Header), message.Body, "")
+
+	want := []string{
+		"multipart/related/multipart/alternative/text/plain: Hello Bob",
+		`multipart/related/multipart/alternative/text/html: <p>Hello Bob <img src="cid:logo"></p>`,
+		"multipart/related/image/png: \x89PNG\r\n\x1a\n",
+	}
+
+	if strings.Join(leaves, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got the parts\n%q\nwant\n%q", leaves, want)
+	}
+}
@@ -0,0 +1,532 @@
+package storage
+
+import (
+	"bytes"
+	"cargomail/internal/mailbox/repository"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	s3PartSize        = 16 << 20 // the objects over the part size are uploaded in parts
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+	s3EmptyPayload    = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+var ErrBlobStoreETag = errors.New("the stored object doesn't match the uploaded content")
+
+// S3BlobStore keeps the bytes as the objects of an S3 compatible bucket (AWS S3, MinIO) under the prefix,
+// e.g. blobs/<digest>. The requests are path-style and signed by the AWS Signature Version 4.
+type S3BlobStore struct {
+	endpoint        *url.URL
+	region          string
+	bucket          string
+	prefix          string
+	accessKeyId     string
+	secretAccessKey string
+	client          *http.Client
+	partSize        int
+}
+
+func NewS3BlobStore(endpoint, region, bucket, prefix, accessKeyId, secretAccessKey string) (*S3BlobStore, error) {
+	if len(endpoint) == 0 {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil || len(u.Host) == 0 {
+		return nil, fmt.Errorf("invalid s3 endpoint %q", endpoint)
+	}
+
+	if len(bucket) == 0 {
+		return nil, errors.New("missing s3 bucket")
+	}
+
+	return &S3BlobStore{
+		endpoint:        u,
+		region:          region,
+		bucket:          bucket,
+		prefix:          strings.Trim(prefix, "/"),
+		accessKeyId:     accessKeyId,
+		secretAccessKey: secretAccessKey,
+		client:          &http.Client{},
+		partSize:        s3PartSize,
+	}, nil
+}
+
+func (s *S3BlobStore) objectUrl(digest string, query url.Values) (string, error) {
+	if len(digest) == 0 || strings.ContainsAny(digest, "/\\") || digest == "." || digest == ".." {
+		return "", repository.ErrInvalidDigest
+	}
+
+	key := digest
+	if len(s.prefix) > 0 {
+		key = s.prefix + "/" + digest
+	}
+
+	u := *s.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.bucket + "/" + key
+	u.RawQuery = canonicalQuery(query)
+
+	return u.String(), nil
+}
+
+// Put uploads the content in one request, or in parts when it's over the part size, and checks the
+// ETag of the stored object against the MD5 of the uploaded bytes.
+func (s *S3BlobStore) Put(digest string, r io.Reader) error {
+	buf := make([]byte, s.partSize)
+
+	n, err := io.ReadFull(r, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		sum := md5.Sum(buf[:n])
+
+		etag, err := s.putPart(digest, nil, buf[:n])
+		if err != nil {
+			return err
+		}
+
+		if etag != hex.EncodeToString(sum[:]) {
+			return ErrBlobStoreETag
+		}
+
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.putMultipart(digest, r, buf)
+}
+
+func (s *S3BlobStore) putPart(digest string, query url.Values, data []byte) (string, error) {
+	resp, err := s.do(http.MethodPut, digest, query, bytes.NewReader(data), int64(len(data)), s3UnsignedPayload)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", s3Error(resp)
+	}
+
+	return strings.Trim(resp.Header.Get("ETag"), `"`), nil
+}
+
+type s3CompletedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+type s3CompleteMultipartUpload struct {
+	XMLName xml.Name          `xml:"CompleteMultipartUpload"`
+	Parts   []s3CompletedPart `xml:"Part"`
+}
+
+// putMultipart uploads the first part read already and the rest of the content, the upload is aborted
+// on any error so the bucket doesn't keep the orphaned parts.
+func (s *S3BlobStore) putMultipart(digest string, r io.Reader, first []byte) error {
+	resp, err := s.do(http.MethodPost, digest, url.Values{"uploads": {""}}, nil, 0, s3EmptyPayload)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return s3Error(resp)
+	}
+
+	var initiated struct {
+		UploadId string `xml:"UploadId"`
+	}
+
+	err = xml.NewDecoder(resp.Body).Decode(&initiated)
+	if err != nil {
+		return err
+	}
+
+	err = s.uploadParts(digest, initiated.UploadId, r, first)
+	if err != nil {
+		abort, abortErr := s.do(http.MethodDelete, digest, url.Values{"uploadId": {initiated.UploadId}}, nil, 0, s3EmptyPayload)
+		if abortErr == nil {
+			abort.Body.Close()
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *S3BlobStore) uploadParts(digest, uploadId string, r io.Reader, buf []byte) error {
+	complete := s3CompleteMultipartUpload{}
+	sums := []byte{}
+
+	data := buf
+	for partNumber := 1; len(data) > 0; partNumber++ {
+		sum := md5.Sum(data)
+		sums = append(sums, sum[:]...)
+
+		query := url.Values{"partNumber": {strconv.Itoa(partNumber)}, "uploadId": {uploadId}}
+
+		etag, err := s.putPart(digest, query, data)
+		if err != nil {
+			return err
+		}
+
+		if etag != hex.EncodeToString(sum[:]) {
+			return ErrBlobStoreETag
+		}
+
+		complete.Parts = append(complete.Parts, s3CompletedPart{PartNumber: partNumber, ETag: etag})
+
+		n, err := io.ReadFull(r, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		data = buf[:n]
+	}
+
+	body, err := xml.Marshal(complete)
+	if err != nil {
+		return err
+	}
+
+	payloadHash := sha256.Sum256(body)
+
+	resp, err := s.do(http.MethodPost, digest, url.Values{"uploadId": {uploadId}}, bytes.NewReader(body), int64(len(body)), hex.EncodeToString(payloadHash[:]))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// the completion may fail with the status 200 and an error document
+	var completed struct {
+		XMLName xml.Name
+		ETag    string `xml:"ETag"`
+	}
+
+	err = xml.NewDecoder(resp.Body).Decode(&completed)
+	if resp.StatusCode != http.StatusOK || err != nil || completed.XMLName.Local != "CompleteMultipartUploadResult" {
+		return fmt.Errorf("s3 complete multipart upload: %s", resp.Status)
+	}
+
+	// the ETag of a multipart object is the MD5 of the MD5s of its parts
+	sum := md5.Sum(sums)
+	if strings.Trim(completed.ETag, `"`) != hex.EncodeToString(sum[:])+"-"+strconv.Itoa(len(complete.Parts)) {
+		return ErrBlobStoreETag
+	}
+
+	return nil
+}
+
+func (s *S3BlobStore) Get(digest string) (io.ReadCloser, error) {
+	resp, err := s.do(http.MethodGet, digest, nil, nil, 0, s3EmptyPayload)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, s3Error(resp)
+	}
+
+	return resp.Body, nil
+}
+
+// Open returns the object for the reads from any offset, each read after a seek is a ranged GET.
+func (s *S3BlobStore) Open(digest string) (io.ReadSeekCloser, error) {
+	_, err := s.objectUrl(digest, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3Object{store: s, digest: digest, size: -1}, nil
+}
+
+type s3Object struct {
+	store  *S3BlobStore
+	digest string
+	size   int64 // -1 until known
+	offset int64
+	body   io.ReadCloser
+}
+
+func (o *s3Object) Read(p []byte) (int, error) {
+	if o.body == nil {
+		if o.size >= 0 && o.offset >= o.size {
+			return 0, io.EOF
+		}
+
+		objectUrl, err := o.store.objectUrl(o.digest, nil)
+		if err != nil {
+			return 0, err
+		}
+
+		req, err := http.NewRequest(http.MethodGet, objectUrl, nil)
+		if err != nil {
+			return 0, err
+		}
+
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(o.offset, 10)+"-")
+
+		signV4(req, s3EmptyPayload, o.store.accessKeyId, o.store.secretAccessKey, o.store.region, time.Now())
+
+		resp, err := o.store.client.Do(req)
+		if err != nil {
+			return 0, err
+		}
+
+		switch resp.StatusCode {
+		case http.StatusOK, http.StatusPartialContent:
+			o.body = resp.Body
+		case http.StatusRequestedRangeNotSatisfiable:
+			resp.Body.Close()
+			return 0, io.EOF
+		default:
+			defer resp.Body.Close()
+			return 0, s3Error(resp)
+		}
+	}
+
+	n, err := o.body.Read(p)
+	o.offset += int64(n)
+
+	return n, err
+}
+
+func (o *s3Object) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += o.offset
+	case io.SeekEnd:
+		if o.size < 0 {
+			size, err := o.store.Stat(o.digest)
+			if err != nil {
+				return 0, err
+			}
+			o.size = size
+		}
+		offset += o.size
+	}
+
+	if offset < 0 {
+		return 0, errors.New("negative offset")
+	}
+
+	if offset != o.offset && o.body != nil {
+		o.body.Close()
+		o.body = nil
+	}
+	o.offset = offset
+
+	return offset, nil
+}
+
+func (o *s3Object) Close() error {
+	if o.body == nil {
+		return nil
+	}
+
+	return o.body.Close()
+}
+
+// Delete removes the object, S3 doesn't report a missing one.
+func (s *S3BlobStore) Delete(digest string) error {
+	resp, err := s.do(http.MethodDelete, digest, nil, nil, 0, s3EmptyPayload)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return s3Error(resp)
+	}
+
+	return nil
+}
+
+func (s *S3BlobStore) Stat(digest string) (int64, error) {
+	resp, err := s.do(http.MethodHead, digest, nil, nil, 0, s3EmptyPayload)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, s3Error(resp)
+	}
+
+	return resp.ContentLength, nil
+}
+
+type s3ListBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// Walk lists the objects under the prefix page by page.
+func (s *S3BlobStore) Walk(fn func(digest string, size int64, modTime time.Time) error) error {
+	prefix := ""
+	if len(s.prefix) > 0 {
+		prefix = s.prefix + "/"
+	}
+
+	query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+
+	for {
+		u := *s.endpoint
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.bucket
+		u.RawQuery = canonicalQuery(query)
+
+		resp, err := s.doUrl(http.MethodGet, u.String(), nil, 0, s3EmptyPayload)
+		if err != nil {
+			return err
+		}
+
+		var list s3ListBucketResult
+
+		if resp.StatusCode != http.StatusOK {
+			err = s3Error(resp)
+		} else {
+			err = xml.NewDecoder(resp.Body).Decode(&list)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		for _, object := range list.Contents {
+			digest := strings.TrimPrefix(object.Key, prefix)
+			if strings.Contains(digest, "/") {
+				continue
+			}
+
+			err = fn(digest, object.Size, object.LastModified)
+			if err != nil {
+				return err
+			}
+		}
+
+		if !list.IsTruncated || len(list.NextContinuationToken) == 0 {
+			return nil
+		}
+
+		query.Set("continuation-token", list.NextContinuationToken)
+	}
+}
+
+func (s *S3BlobStore) do(method, digest string, query url.Values, body io.Reader, contentLength int64, payloadHash string) (*http.Response, error) {
+	objectUrl, err := s.objectUrl(digest, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.doUrl(method, objectUrl, body, contentLength, payloadHash)
+}
+
+func (s *S3BlobStore) doUrl(method, rawUrl string, body io.Reader, contentLength int64, payloadHash string) (*http.Response, error) {
+	req, err := http.NewRequest(method, rawUrl, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.ContentLength = contentLength
+
+	signV4(req, payloadHash, s.accessKeyId, s.secretAccessKey, s.region, time.Now())
+
+	return s.client.Do(req)
+}
+
+func s3Error(resp *http.Response) error {
+	if resp.StatusCode == http.StatusNotFound {
+		return fs.ErrNotExist
+	}
+
+	var s3Err struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+
+	if xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&s3Err) == nil && len(s3Err.Code) > 0 {
+		return fmt.Errorf("s3 %s: %s", s3Err.Code, s3Err.Message)
+	}
+
+	return fmt.Errorf("s3: %s", resp.Status)
+}
+
+// signV4 signs the request by the AWS Signature Version 4, all the headers set so far are signed.
+func signV4(req *http.Request, payloadHash, accessKeyId, secretAccessKey, region string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	canonicalHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	key := hmacSha256([]byte("AWS4"+secretAccessKey), date)
+	key = hmacSha256(key, region)
+	key = hmacSha256(key, "s3")
+	key = hmacSha256(key, "aws4_request")
+
+	signature := hex.EncodeToString(hmacSha256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKeyId+"/"+scope+", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery encodes the query sorted by the key, with the spaces as %20
+func canonicalQuery(query url.Values) string {
+	return strings.ReplaceAll(query.Encode(), "+", "%20")
+}
@@ -5,29 +5,80 @@ import (
 	"cargomail/internal/mailbox/repository"
 	"cargomail/internal/shared/config"
 	b64 "encoding/base64"
-	"errors"
 	"io"
+	"log"
 	"mime/multipart"
 	"net/textproto"
-	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 )
 
 type Storage struct {
-	Blobs    UseBlobStorage
-	Files    UseFileStorage
-	Drafts   UseDraftStorage
-	Messages UseMessageStorage
+	Blobs      UseBlobStorage
+	Files      UseFileStorage
+	Drafts     UseDraftStorage
+	Messages   UseMessageStorage
+	Uploads    UseUploadStorage
+	Health     UseHealthStorage
+	Accounts   UseAccountStorage
+	Federation UseFederationStorage
+	Trash      UseTrashStorage
 }
 
+// NewStorage keeps the bytes of the blobs and the files in their folders of the resources path, or under
+// the same prefixes of the S3 bucket when the s3 blob store is configured.
 func NewStorage(repository repository.Repository) Storage {
+	blobStore, fileStore, err := NewStores()
+	if err != nil {
+		log.Fatal("blob store: ", err)
+	}
+
+	return NewStorageWithStores(repository, blobStore, fileStore)
+}
+
+// NewStores returns the configured stores of the blobs and of the files.
+func NewStores() (BlobStore, BlobStore, error) {
+	c := config.Configuration
+
+	if config.S3BlobStore() {
+		blobStore, err := NewS3BlobStore(c.S3Endpoint, c.S3Region, c.S3Bucket, c.BlobsFolder, c.S3AccessKeyId, c.S3SecretAccessKey)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		fileStore, err := NewS3BlobStore(c.S3Endpoint, c.S3Region, c.S3Bucket, c.FilesFolder, c.S3AccessKeyId, c.S3SecretAccessKey)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return blobStore, fileStore, nil
+	}
+
+	blobStore := NewLocalBlobStore(filepath.Join(c.ResourcesPath, c.BlobsFolder))
+	fileStore := NewLocalBlobStore(filepath.Join(c.ResourcesPath, c.FilesFolder))
+
+	return blobStore, fileStore, nil
+}
+
+func NewStorageWithStores(repository repository.Repository, blobStore, fileStore BlobStore) Storage {
+	blobStorage := BlobStorage{repository, blobStore}
+	fileStorage := FileStorage{repository, fileStore}
+
+	// the chunks are spooled locally whatever the blob store
+	uploadsDir := filepath.Join(config.Configuration.ResourcesPath, config.Configuration.UploadsFolder)
+	uploadStorage := &UploadStorage{repository: repository, blobs: &blobStorage, files: &fileStorage, dir: uploadsDir}
+
 	return Storage{
-		Blobs:    &BlobStorage{repository},
-		Files:    &FileStorage{repository},
-		Drafts:   &DraftStorage{repository, BlobStorage{repository}},
-		Messages: &MessageStorage{repository, BlobStorage{repository}},
+		Blobs:      &blobStorage,
+		Files:      &fileStorage,
+		Drafts:     &DraftStorage{repository, blobStorage, fileStorage},
+		Messages:   &MessageStorage{repository, blobStorage, fileStorage},
+		Uploads:    uploadStorage,
+		Health:     &HealthStorage{blobStore: blobStore, fileStore: fileStore},
+		Accounts:   &AccountStorage{repository: repository, blobStore: blobStore, fileStore: fileStore, uploads: uploadStorage},
+		Federation: &FederationStorage{repository, blobStorage, fileStorage},
+		Trash:      &TrashStorage{repository: repository, blobs: &blobStorage},
 	}
 }
 
@@ -65,12 +116,9 @@ func ParsePlaceholderMessage[M GenericMessage](user *repository.User, repo repos
 							return err
 						}
 
-						blobsPath := filepath.Join(config.Configuration.ResourcesPath, config.Configuration.BlobsFolder)
-						blobPath := filepath.Join(blobsPath, digest)
-
 						buf := new(bytes.Buffer)
 
-						err = blobStorage.Load(buf, blob, blobPath)
+						err = blobStorage.Load(buf, blob)
 						if err != nil {
 							return err
 						}
@@ -180,15 +228,6 @@ func ComposePlaceholderMessage(user *repository.User, blobStorage BlobStorage, d
 		return nil, repository.ErrEmptyPayload
 	}
 
-	blobsPath := filepath.Join(config.Configuration.ResourcesPath, config.Configuration.BlobsFolder)
-
-	if _, err := os.Stat(blobsPath); errors.Is(err, os.ErrNotExist) {
-		err := os.MkdirAll(blobsPath, os.ModePerm)
-		if err != nil {
-			return nil, err
-		}
-	}
-
 	err := parseParts(draft.Payload.Parts)
 	if err != nil {
 		return nil, err
@@ -198,7 +237,7 @@ func ComposePlaceholderMessage(user *repository.User, blobStorage BlobStorage, d
 
 	multipartReader := multipart.NewReader(body, multipartWriter.Boundary())
 
-	uploadedBlobs, err := blobStorage.CleanAndStoreMultipart(user, draft.Id, multipartReader, blobsPath)
+	uploadedBlobs, err := blobStorage.CleanAndStoreMultipart(user, draft.Id, multipartReader)
 	if err != nil {
 		return nil, err
 	}
@@ -207,11 +246,12 @@ func ComposePlaceholderMessage(user *repository.User, blobStorage BlobStorage, d
 
 	var updateParts func(parts []*repository.MessagePart) error
 
+	// the blobs are in the order of the inline parts of the whole tree, the nested ones included
+	j := 0
+
 	updateParts = func(parts []*repository.MessagePart) error {
 		var err error
 
-		j := 0
-
 		for i := range parts {
 			contentDisposition, _ := parts[i].Headers["Content-Disposition"].(string)
 
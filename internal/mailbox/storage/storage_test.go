@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"cargomail/internal/mailbox/repository"
+	"cargomail/internal/shared/database"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// newTestStorage returns the storage of a new database and of the local stores, removed after the test,
+// with a user logged in on a device.
+func newTestStorage(t *testing.T) (Storage, repository.Repository, *repository.User) {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	db, err := database.Connect(filepath.Join(dir, "cargomail.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	database.Init(db)
+
+	repo := repository.NewRepositoryWithTimeouts(db, repository.Timeouts{Read: 5 * time.Second, Write: 5 * time.Second})
+
+	// the password hash is not a bcrypt one
+	user := &repository.User{Username: "alice"}
+
+	err = db.QueryRow(`INSERT INTO "User" ("username", "passwordHash") VALUES ($1, '-') RETURNING "id";`, user.Username).
+		Scan(&user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deviceId := "0123456789abcdef0123456789abcdef"
+	user.DeviceId = &deviceId
+
+	storage := NewStorageWithStores(repo, NewLocalBlobStore(filepath.Join(dir, "blobs")), NewLocalBlobStore(filepath.Join(dir, "files")))
+
+	return storage, repo, user
+}
+
+// newTestDraft creates the draft of the alternative plain and HTML bodies, and an inline image, the body parts
+// are moved to the blobs by the placeholder message.
+func newTestDraft(t *testing.T, storage Storage, user *repository.User) *repository.Draft {
+	t.Helper()
+
+	draft, err := storage.Drafts.Create(user, &repository.Draft{
+		Payload: &repository.MessagePart{
+			Headers: map[string]interface{}{
+				"From":         "alice@example.org",
+				"To":           "bob@example.net",
+				"Subject":      "Hello",
+				"Content-Type": "multipart/related",
+			},
+			Parts: []*repository.MessagePart{
+				{
+					Headers: map[string]interface{}{"Content-Type": "multipart/alternative"},
+					Parts: []*repository.MessagePart{
+						{
+							Headers: map[string]interface{}{"Content-Disposition": "inline", "Content-Type": "text/plain; charset=utf-8"},
+							Body:    &repository.Body{Data: "Hello Bob"},
+						},
+						{
+							Headers: map[string]interface{}{"Content-Disposition": "inline", "Content-Type": "text/html; charset=utf-8"},
+							Body:    &repository.Body{Data: `<p>Hello Bob <img src="cid:logo"></p>`},
+						},
+					},
+				},
+				{
+					Headers: map[string]interface{}{
+						"Content-Disposition":       "inline",
+						"Content-Type":              "image/png",
+						"Content-Transfer-Encoding": "base64",
+					},
+					Body: &repository.Body{Data: "iVBORw0KGgo="},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return draft
+}
@@ -0,0 +1,28 @@
+package storage
+
+import (
+	"cargomail/internal/mailbox/repository"
+	"time"
+)
+
+type UseTrashStorage interface {
+	Purge(retention time.Duration) (*repository.TrashPurge, error)
+}
+
+type TrashStorage struct {
+	repository repository.Repository
+	blobs      *BlobStorage
+}
+
+// Purge hard-deletes the items of all users trashed longer than the retention window, the stored bytes are
+// removed with the last blob referencing them.
+func (s *TrashStorage) Purge(retention time.Duration) (*repository.TrashPurge, error) {
+	purge, err := s.repository.Trash.Purge(retention)
+	if err != nil {
+		return nil, err
+	}
+
+	s.blobs.release()
+
+	return purge, nil
+}
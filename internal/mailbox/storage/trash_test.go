@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"cargomail/internal/mailbox/repository"
+	"strings"
+	"testing"
+)
+
+// newTrashedBlob stores a blob of the content and trashes it, it returns the digest of the stored bytes.
+func newTrashedBlob(t *testing.T, storage Storage, repo repository.Repository, user *repository.User, content string) string {
+	t.Helper()
+
+	blob, err := storage.Blobs.Store(user, strings.NewReader(content), "notes.txt", "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = repo.Blobs.Trash(user, `{"ids":["`+blob.Id+`"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return blob.Digest
+}
+
+func TestEmptyTrashReleasesContent(t *testing.T) {
+	storage, repo, user := newTestStorage(t)
+
+	digest := newTrashedBlob(t, storage, repo, user, "the trashed notes")
+
+	store := storage.Blobs.(*BlobStorage).store
+
+	_, err := store.Stat(digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = storage.Blobs.EmptyTrash(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = store.Stat(digest)
+	if err == nil {
+		t.Error("the stored bytes of the deleted blob kept")
+	}
+}
+
+func TestPurgeTrashReleasesContent(t *testing.T) {
+	storage, repo, user := newTestStorage(t)
+
+	digest := newTrashedBlob(t, storage, repo, user, "the trashed notes")
+
+	// an equal content of a blob not trashed is shared, its bytes are kept
+	shared := newTrashedBlob(t, storage, repo, user, "the shared notes")
+
+	_, err := storage.Blobs.Store(user, strings.NewReader("the shared notes"), "copy.txt", "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	purge, err := storage.Trash.Purge(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if purge.Blobs != 2 {
+		t.Errorf("got %d blobs purged, want 2", purge.Blobs)
+	}
+
+	store := storage.Blobs.(*BlobStorage).store
+
+	_, err = store.Stat(digest)
+	if err == nil {
+		t.Error("the stored bytes of the purged blob kept")
+	}
+
+	_, err = store.Stat(shared)
+	if err != nil {
+		t.Errorf("the shared stored bytes removed: %v", err)
+	}
+}
@@ -0,0 +1,362 @@
+package storage
+
+import (
+	"cargomail/internal/mailbox/repository"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	b64 "encoding/base64"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+type UseUploadStorage interface {
+	Init(user *repository.User, upload *repository.Upload) (*repository.Upload, error)
+	Get(user *repository.User, resource, id string) (*repository.Upload, error)
+	Append(user *repository.User, resource, id string, start, end, size int64, chunk io.Reader) (*repository.Upload, error)
+	FinalizeBlob(user *repository.User, id string, hash *string) (*repository.Blob, error)
+	FinalizeFile(user *repository.User, id string, hash *string) (*repository.File, error)
+	Abort(user *repository.User, resource, id string) error
+	Expire(age time.Duration) (int, error)
+}
+
+// UploadStorage spools the chunks of the resumable uploads in the uploads folder, encrypted with the key
+// of the upload. The finalized upload is stored as the single-shot upload is, so the resulting blob or
+// file is the same.
+type UploadStorage struct {
+	repository repository.Repository
+	blobs      *BlobStorage
+	files      *FileStorage
+	dir        string
+	locks      uploadLocks
+}
+
+// uploadLocks keeps the uploads being appended to or finalized, one request at a time per upload.
+type uploadLocks struct {
+	mu   sync.Mutex
+	held map[string]bool
+}
+
+func (l *uploadLocks) tryLock(id string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.held == nil {
+		l.held = map[string]bool{}
+	}
+
+	if l.held[id] {
+		return false
+	}
+
+	l.held[id] = true
+
+	return true
+}
+
+func (l *uploadLocks) unlock(id string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.held, id)
+}
+
+// Init registers the upload and returns its id, the chunks are encrypted with a new key.
+func (s *UploadStorage) Init(user *repository.User, upload *repository.Upload) (*repository.Upload, error) {
+	key := make([]byte, repository.KeySize)
+	_, err := rand.Read(key)
+	if err != nil {
+		return nil, err
+	}
+
+	iv := make([]byte, repository.IvSize)
+	_, err = rand.Read(iv)
+	if err != nil {
+		return nil, err
+	}
+
+	upload.Metadata = &repository.BlobMetadata{
+		Key: b64.RawURLEncoding.EncodeToString(key),
+		Iv:  b64.RawURLEncoding.EncodeToString(iv),
+	}
+
+	err = os.MkdirAll(s.dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repository.Uploads.Create(user, upload)
+}
+
+// Get returns the upload of the resource, i.e. the offset to resume from.
+func (s *UploadStorage) Get(user *repository.User, resource, id string) (*repository.Upload, error) {
+	upload, err := s.repository.Uploads.Get(user, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if upload.Resource != resource {
+		return nil, repository.ErrUploadNotFound
+	}
+
+	return upload, nil
+}
+
+// Append writes the chunk of the bytes start to end (inclusive) at the offset of the upload, e.g. the
+// client resumes from the offset it gets back. The size is the declared size of the whole upload.
+func (s *UploadStorage) Append(user *repository.User, resource, id string, start, end, size int64, chunk io.Reader) (*repository.Upload, error) {
+	if !s.locks.tryLock(id) {
+		return nil, repository.ErrUploadOffsetMismatch
+	}
+	defer s.locks.unlock(id)
+
+	upload, err := s.Get(user, resource, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if size != upload.Size || start > end || end >= size {
+		return nil, repository.ErrInvalidContentRange
+	}
+
+	if start != upload.Offset {
+		return nil, repository.ErrUploadOffsetMismatch
+	}
+
+	block, iv, err := uploadCipher(upload.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(s.path(upload), os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// drop the bytes of an interrupted chunk
+	err = f.Truncate(start)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = f.Seek(start, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	writer := &cipher.StreamWriter{S: ctrStreamAt(block, iv, start), W: f}
+
+	length := end - start + 1
+
+	// one byte over the range tells the chunk is too large
+	written, copyErr := io.Copy(writer, io.LimitReader(chunk, length+1))
+
+	if written > length {
+		_ = f.Truncate(start)
+		return nil, repository.ErrInvalidContentRange
+	}
+
+	// the bytes received before the connection dropped are kept, the client resumes after them
+	upload, err = s.repository.Uploads.Advance(user, id, start, start+written)
+	if err != nil {
+		return nil, err
+	}
+
+	if copyErr != nil {
+		return nil, copyErr
+	}
+
+	if written < length {
+		return nil, repository.ErrInvalidContentRange
+	}
+
+	return upload, nil
+}
+
+// FinalizeBlob checks the hash of the assembled content and stores it as a blob, the upload is removed.
+func (s *UploadStorage) FinalizeBlob(user *repository.User, id string, hash *string) (*repository.Blob, error) {
+	var blob *repository.Blob
+
+	err := s.finalize(user, id, repository.BlobsResource, hash, func(upload *repository.Upload, content io.Reader) error {
+		var err error
+
+		blob, err = s.blobs.Store(user, content, upload.Name, upload.ContentType)
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return blob, nil
+}
+
+// FinalizeFile checks the hash of the assembled content and stores it as a file, the upload is removed.
+func (s *UploadStorage) FinalizeFile(user *repository.User, id string, hash *string) (*repository.File, error) {
+	var file *repository.File
+
+	err := s.finalize(user, id, repository.FilesResource, hash, func(upload *repository.Upload, content io.Reader) error {
+		var err error
+
+		file, err = s.files.Store(user, content, upload.Name, upload.ContentType)
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
+
+func (s *UploadStorage) finalize(user *repository.User, id, resource string, hash *string, store func(*repository.Upload, io.Reader) error) error {
+	if !s.locks.tryLock(id) {
+		return repository.ErrUploadOffsetMismatch
+	}
+	defer s.locks.unlock(id)
+
+	upload, err := s.Get(user, resource, id)
+	if err != nil {
+		return err
+	}
+
+	if hash == nil {
+		hash = upload.Hash
+	}
+
+	if hash == nil || len(*hash) == 0 {
+		return repository.ErrMissingHashField
+	}
+
+	if upload.Offset != upload.Size {
+		return repository.ErrUploadIncomplete
+	}
+
+	content, err := s.open(upload)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	contentHash := sha256.New()
+
+	_, err = io.Copy(contentHash, io.LimitReader(content, upload.Size))
+	if err != nil {
+		return err
+	}
+
+	if b64.RawURLEncoding.EncodeToString(contentHash.Sum(nil)) != *hash {
+		return repository.ErrUploadHashMismatch
+	}
+
+	_, err = content.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	err = store(upload, io.LimitReader(content, upload.Size))
+	if err != nil {
+		return err
+	}
+
+	return s.remove(user, upload)
+}
+
+// Abort removes the upload and its chunks.
+func (s *UploadStorage) Abort(user *repository.User, resource, id string) error {
+	if !s.locks.tryLock(id) {
+		return repository.ErrUploadOffsetMismatch
+	}
+	defer s.locks.unlock(id)
+
+	upload, err := s.Get(user, resource, id)
+	if err != nil {
+		return err
+	}
+
+	return s.remove(user, upload)
+}
+
+// Expire removes the uploads abandoned for the age and returns their number.
+func (s *UploadStorage) Expire(age time.Duration) (int, error) {
+	uploads, err := s.repository.Uploads.Expire(age)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, upload := range uploads {
+		err := os.Remove(s.path(upload))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return 0, err
+		}
+	}
+
+	return len(uploads), nil
+}
+
+func (s *UploadStorage) remove(user *repository.User, upload *repository.Upload) error {
+	err := s.repository.Uploads.Delete(user, upload.Id)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(s.path(upload))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// open returns the decrypted chunks, the empty upload has none.
+func (s *UploadStorage) open(upload *repository.Upload) (io.ReadSeekCloser, error) {
+	block, iv, err := uploadCipher(upload.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(s.path(upload), os.O_RDONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &decryptReader{object: f, block: block, iv: iv, size: upload.Size}, nil
+}
+
+func (s *UploadStorage) path(upload *repository.Upload) string {
+	return filepath.Join(s.dir, upload.Id)
+}
+
+func uploadCipher(metadata *repository.BlobMetadata) (cipher.Block, []byte, error) {
+	if metadata == nil {
+		return nil, nil, repository.ErrUploadNotFound
+	}
+
+	key, err := b64.RawURLEncoding.DecodeString(metadata.Key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	iv, err := b64.RawURLEncoding.DecodeString(metadata.Iv)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(iv) != block.BlockSize() {
+		return nil, nil, errors.New("invalid iv size")
+	}
+
+	return block, iv, nil
+}
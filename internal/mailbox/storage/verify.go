@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"cargomail/internal/mailbox/repository"
+	"errors"
+	"io/fs"
+)
+
+// Verification is what a scan of the stored contents found.
+type Verification struct {
+	Checked int64
+	Corrupt int64 // the bytes don't match the digest or the hash
+	Missing int64 // no bytes in the store
+	Failed  int64 // e.g. the store unreachable
+}
+
+// VerifyContents re-hashes the stored bytes of every content of the resource (blobs|files), the report
+// gets each content not verified with the reason.
+func VerifyContents(repo repository.Repository, resource string, store BlobStore, report func(content *repository.StoredContent, err error)) (*Verification, error) {
+	contents, err := repo.Contents.Stored(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	verification := &Verification{}
+
+	for _, content := range contents {
+		verification.Checked++
+
+		_, err := verifyEncrypted(store, content.Digest, content.Metadata)
+		if err == nil {
+			continue
+		}
+
+		switch {
+		case errors.Is(err, repository.ErrCorruptContent):
+			verification.Corrupt++
+		case errors.Is(err, fs.ErrNotExist):
+			verification.Missing++
+		default:
+			verification.Failed++
+		}
+
+		report(content, err)
+	}
+
+	return verification, nil
+}
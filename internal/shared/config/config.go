@@ -1,13 +1,19 @@
 package config
 
 import (
+	"crypto/rand"
 	_ "embed"
 	"log"
+	"mime"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"reflect"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"gopkg.in/yaml.v2"
@@ -18,52 +24,136 @@ var defaultConfig []byte
 
 var (
 	Configuration Config
+
+	uploadLimitsOnce sync.Once
+	uploadLimits     map[string]int64
+
+	urlSigningKeyOnce sync.Once
+	urlSigningKey     []byte
+
+	federationPeersOnce sync.Once
+	federationPeers     map[string]string
+
+	trustedProxiesOnce sync.Once
+	trustedProxies     []*net.IPNet
 )
 
 type Config = struct {
-	DomainName        string `yaml:"domainName"`
-	DoHProviderHost   string `yaml:"dohProviderHost"`
-	StoragePath       string `yaml:"storagePath"`
-	DatabasePath      string `yaml:"databasePath"`
-	ResourcesPath     string `yaml:"resources_path"`
-	BlobsFolder       string `yaml:"blobsFolder"`
-	FilesFolder       string `yaml:"filesFolder"`
-	MSSClientCertPath string `yaml:"mssClientCertPath"`
-	MSSClientKeyPath  string `yaml:"mssClientKeyPath"`
-	MSSServerCertPath string `yaml:"mssServerCertPath"`
-	MSSServerKeyPath  string `yaml:"mssServerKeyPath"`
-	MSSBind           string `yaml:"mssBind"`
-	MSSBindTLS        string `yaml:"mssBindTLS"`
-	MHSClientCertPath string `yaml:"mhsClientCertPath"`
-	MHSClientKeyPath  string `yaml:"mhsClientKeyPath"`
-	MHSServerCertPath string `yaml:"mhsServerCertPath"`
-	MHSServerKeyPath  string `yaml:"mhsServerKeyPath"`
-	MHSBind           string `yaml:"mhsBind"`
-	MHSBindTLS        string `yaml:"mhsBindTLS"`
-	MDSClientCertPath string `yaml:"mdsClientCertPath"`
-	MDSClientKeyPath  string `yaml:"mdsClientKeyPath"`
-	MDSServerCertPath string `yaml:"mdsServerCertPath"`
-	MDSServerKeyPath  string `yaml:"mdsServerKeyPath"`
-	MDSBind           string `yaml:"mdsBind"`
-	MDSBindTLS        string `yaml:"mdsBindTLS"`
-	RHSClientCertPath string `yaml:"rhsClientCertPath"`
-	RHSClientKeyPath  string `yaml:"rhsClientKeyPath"`
-	RHSServerCertPath string `yaml:"rhsServerCertPath"`
-	RHSServerKeyPath  string `yaml:"rhsServerKeyPath"`
-	RHSBind           string `yaml:"rhsBind"`
-	RHSBindTLS        string `yaml:"rhsBindTLS"`
-	CookieSameSite    string `yaml:"cookieSameSite"`
-	Stage             string `yaml:"stage"`
-	// SessionTTL       time.Duration
+	DomainName           string `yaml:"domainName"`
+	DoHProviderHost      string `yaml:"dohProviderHost"`
+	StoragePath          string `yaml:"storagePath"`
+	DatabasePath         string `yaml:"databasePath"`
+	ReplicaDatabasePath  string `yaml:"replicaDatabasePath"`
+	DatabaseDialect      string `yaml:"databaseDialect"`
+	ResourcesPath        string `yaml:"resources_path"`
+	BlobsFolder          string `yaml:"blobsFolder"`
+	FilesFolder          string `yaml:"filesFolder"`
+	MSSClientCertPath    string `yaml:"mssClientCertPath"`
+	MSSClientKeyPath     string `yaml:"mssClientKeyPath"`
+	MSSServerCertPath    string `yaml:"mssServerCertPath"`
+	MSSServerKeyPath     string `yaml:"mssServerKeyPath"`
+	MSSBind              string `yaml:"mssBind"`
+	MSSBindTLS           string `yaml:"mssBindTLS"`
+	MHSClientCertPath    string `yaml:"mhsClientCertPath"`
+	MHSClientKeyPath     string `yaml:"mhsClientKeyPath"`
+	MHSServerCertPath    string `yaml:"mhsServerCertPath"`
+	MHSServerKeyPath     string `yaml:"mhsServerKeyPath"`
+	MHSBind              string `yaml:"mhsBind"`
+	MHSBindTLS           string `yaml:"mhsBindTLS"`
+	MDSClientCertPath    string `yaml:"mdsClientCertPath"`
+	MDSClientKeyPath     string `yaml:"mdsClientKeyPath"`
+	MDSServerCertPath    string `yaml:"mdsServerCertPath"`
+	MDSServerKeyPath     string `yaml:"mdsServerKeyPath"`
+	MDSBind              string `yaml:"mdsBind"`
+	MDSBindTLS           string `yaml:"mdsBindTLS"`
+	MDSGrpcBindTLS       string `yaml:"mdsGrpcBindTLS"`
+	MDSImapBindTLS       string `yaml:"mdsImapBindTLS"`
+	RHSClientCertPath    string `yaml:"rhsClientCertPath"`
+	RHSClientKeyPath     string `yaml:"rhsClientKeyPath"`
+	RHSServerCertPath    string `yaml:"rhsServerCertPath"`
+	RHSServerKeyPath     string `yaml:"rhsServerKeyPath"`
+	RHSBind              string `yaml:"rhsBind"`
+	RHSBindTLS           string `yaml:"rhsBindTLS"`
+	CookieSameSite       string `yaml:"cookieSameSite"`
+	Stage                string `yaml:"stage"`
+	TrashRetentionDays   string `yaml:"trashRetentionDays"`
+	SnippetSource        string `yaml:"snippetSource"`
+	SnippetLength        string `yaml:"snippetLength"`
+	MaxInflight          string `yaml:"maxInflight"`
+	InflightLimitMode    string `yaml:"inflightLimitMode"`
+	DraftVersions        string `yaml:"draftVersions"`
+	ReadTimeout          string `yaml:"readTimeout"`
+	WriteTimeout         string `yaml:"writeTimeout"`
+	BusyRetries          string `yaml:"busyRetries"`
+	MaxOpenConns         string `yaml:"maxOpenConns"`
+	MaxIdleConns         string `yaml:"maxIdleConns"`
+	ConnMaxLifetime      string `yaml:"connMaxLifetime"`
+	BlobStore            string `yaml:"blobStore"`
+	S3Endpoint           string `yaml:"s3Endpoint"`
+	S3Region             string `yaml:"s3Region"`
+	S3Bucket             string `yaml:"s3Bucket"`
+	S3AccessKeyId        string `yaml:"s3AccessKeyId"`
+	S3SecretAccessKey    string `yaml:"s3SecretAccessKey"`
+	QuotaBytes           string `yaml:"quotaBytes"`
+	UploadsFolder        string `yaml:"uploadsFolder"`
+	UploadExpiry         string `yaml:"uploadExpiry"`
+	VerifyDownloads      string `yaml:"verifyDownloads"`
+	MaxUploadBytes       string `yaml:"maxUploadBytes"`
+	MaxUploadBytesByType string `yaml:"maxUploadBytesByType"`
+	StripImageMetadata   string `yaml:"stripImageMetadata"`
+	UrlSigningKey        string `yaml:"urlSigningKey"`
+	IdempotencyKeyTTL    string `yaml:"idempotencyKeyTTL"`
+	RateLimit            string `yaml:"rateLimit"`
+	RateLimitByPath      string `yaml:"rateLimitByPath"`
+	UserRateLimit        string `yaml:"userRateLimit"`
+	SessionTTL           string `yaml:"sessionTTL"`
+	RefreshTokenTTL      string `yaml:"refreshTokenTTL"`
+	LoginMaxFailures     string `yaml:"loginMaxFailures"`
+	LoginMaxUserFailures string `yaml:"loginMaxUserFailures"`
+	LoginLockout         string `yaml:"loginLockout"`
+	SmtpRelayHost        string `yaml:"smtpRelayHost"`
+	SmtpRelayPort        string `yaml:"smtpRelayPort"`
+	SmtpRelayUsername    string `yaml:"smtpRelayUsername"`
+	SmtpRelayPassword    string `yaml:"smtpRelayPassword"`
+	SmtpRelayTLS         string `yaml:"smtpRelayTLS"`
+	SmtpRelayTimeout     string `yaml:"smtpRelayTimeout"`
+	FederationPeers      string `yaml:"federationPeers"`
+	TrustedProxies       string `yaml:"trustedProxies"`
 }
 
 const (
 	DefaultBlobsFolder    = "blobs"
 	DefaultFilesFolder    = "files"
+	DefaultUploadsFolder  = "uploads"
 	DefaultCookieSameSite = http.SameSiteStrictMode
 	DefaultSessionTTL     = 24 * time.Hour
 	DefaultMaxUploadSize  = 1024 // MB
 	DefaultMaxBodySize    = 1    // MB
+	DefaultSnippetSource  = "plain-first"
+	DefaultSnippetLength  = 200 // characters
+	DefaultMaxInflight    = 256 // requests
+	DefaultInflightWait   = 10 * time.Second
+	DefaultDraftVersions  = 20 // per draft
+	DefaultReadTimeout    = 3 * time.Second
+	DefaultWriteTimeout   = 5 * time.Second
+	DefaultBusyRetries    = 5 // attempts
+	DefaultDialect        = "sqlite"
+	DefaultMaxOpenConns   = 0 // unlimited
+	DefaultMaxIdleConns   = 2 // the database/sql default
+	DefaultS3Region       = "us-east-1"
+	DefaultQuotaBytes     = 0 // unlimited
+	DefaultUploadExpiry   = 24 * time.Hour
+	DefaultSignedUrlTTL   = time.Hour
+	DefaultIdempotencyTTL = 24 * time.Hour
+	MaxSignedUrlTTL       = 7 * 24 * time.Hour
+	DefaultRateLimitPaths = "/api/v1/auth/=20/m"
+	DefaultRefreshTTL     = 30 * 24 * time.Hour
+	DefaultLoginFailures  = 5  // attempts
+	DefaultUserFailures   = 20 // attempts
+	DefaultLoginLockout   = 15 * time.Minute
+	DefaultSmtpRelayPort  = "587"
+	DefaultSmtpRelayTLS   = "starttls"
+	DefaultSmtpTimeout    = 30 * time.Second
 )
 
 func newConfig() Config {
@@ -80,7 +170,17 @@ func newConfig() Config {
 		c.FilesFolder = DefaultFilesFolder
 	}
 
-	// c.SessionTTL = DefaultSessionTTL
+	if len(c.UploadsFolder) == 0 {
+		c.UploadsFolder = DefaultUploadsFolder
+	}
+
+	if len(c.S3Region) == 0 {
+		c.S3Region = DefaultS3Region
+	}
+
+	if len(c.SnippetSource) == 0 {
+		c.SnippetSource = DefaultSnippetSource
+	}
 
 	return c
 }
@@ -139,6 +239,569 @@ func CookieSameSite() http.SameSite {
 	}
 }
 
+// SnippetHtmlFirst tells whether the snippet of a multipart/alternative body is taken from the text/html part
+// (html-first) rather than from the text/plain one (plain-first).
+func SnippetHtmlFirst() bool {
+	return strings.EqualFold(Configuration.SnippetSource, "html-first")
+}
+
+// SnippetLength returns the length of the snippets of the messages, the drafts and the text blobs in characters.
+func SnippetLength() int {
+	if len(Configuration.SnippetLength) == 0 {
+		return DefaultSnippetLength
+	}
+
+	length, err := strconv.Atoi(Configuration.SnippetLength)
+	if err != nil || length <= 0 {
+		log.Printf("invalid snippetLength %q, using the default of %d characters", Configuration.SnippetLength, DefaultSnippetLength)
+		return DefaultSnippetLength
+	}
+
+	return length
+}
+
+// S3BlobStore tells whether the blobs and the files are kept in an S3 compatible bucket (s3) rather than
+// in the resources path (local).
+func S3BlobStore() bool {
+	return strings.EqualFold(Configuration.BlobStore, "s3")
+}
+
+// QuotaBytes returns how many bytes of blobs and files a user may store unless the user has a quota of
+// its own, zero means unlimited.
+func QuotaBytes() int64 {
+	if len(Configuration.QuotaBytes) == 0 {
+		return DefaultQuotaBytes
+	}
+
+	quota, err := strconv.ParseInt(Configuration.QuotaBytes, 10, 64)
+	if err != nil || quota < 0 {
+		log.Printf("invalid quotaBytes %q, the storage is unlimited", Configuration.QuotaBytes)
+		return DefaultQuotaBytes
+	}
+
+	return quota
+}
+
+// UploadExpiry returns how long a resumable upload is kept without receiving a chunk, e.g. 24h.
+func UploadExpiry() time.Duration {
+	return timeout("uploadExpiry", Configuration.UploadExpiry, DefaultUploadExpiry)
+}
+
+// IdempotencyKeyTTL returns how long the response of a request with an Idempotency-Key is replayed, e.g. 24h.
+func IdempotencyKeyTTL() time.Duration {
+	return timeout("idempotencyKeyTTL", Configuration.IdempotencyKeyTTL, DefaultIdempotencyTTL)
+}
+
+// SessionTTL returns how long a session is valid since its last use, e.g. 24h. A short TTL (e.g. 15m)
+// makes the session a short-lived access token, the clients keep it alive with the refresh token.
+func SessionTTL() time.Duration {
+	return timeout("sessionTTL", Configuration.SessionTTL, DefaultSessionTTL)
+}
+
+// RefreshTokenTTL returns how long a refresh token is valid, each refresh issues a new one, e.g. 720h.
+func RefreshTokenTTL() time.Duration {
+	return timeout("refreshTokenTTL", Configuration.RefreshTokenTTL, DefaultRefreshTTL)
+}
+
+// LoginMaxFailures returns the failed logins of a username from a client IP the account is locked after,
+// zero disables the lockout.
+func LoginMaxFailures() int {
+	if len(Configuration.LoginMaxFailures) == 0 {
+		return DefaultLoginFailures
+	}
+
+	failures, err := strconv.Atoi(Configuration.LoginMaxFailures)
+	if err != nil || failures < 0 {
+		log.Printf("invalid loginMaxFailures %q, using the default of %d attempts", Configuration.LoginMaxFailures, DefaultLoginFailures)
+		return DefaultLoginFailures
+	}
+
+	return failures
+}
+
+// LoginMaxUserFailures returns the failed logins of a username from all the client IPs the account is locked
+// after, so the guesses spread over many IPs are locked too. It locks the owner out as well, hence it is
+// higher than the loginMaxFailures. Zero disables the lockout of the username.
+func LoginMaxUserFailures() int {
+	if len(Configuration.LoginMaxUserFailures) == 0 {
+		return DefaultUserFailures
+	}
+
+	failures, err := strconv.Atoi(Configuration.LoginMaxUserFailures)
+	if err != nil || failures < 0 {
+		log.Printf("invalid loginMaxUserFailures %q, using the default of %d attempts", Configuration.LoginMaxUserFailures, DefaultUserFailures)
+		return DefaultUserFailures
+	}
+
+	return failures
+}
+
+// LoginLockout returns how long the account stays locked, the failures older than that are forgotten.
+func LoginLockout() time.Duration {
+	return timeout("loginLockout", Configuration.LoginLockout, DefaultLoginLockout)
+}
+
+// SmtpRelayAddr returns the host:port of the relay the drafts are sent through, empty when none is configured.
+func SmtpRelayAddr() string {
+	if len(Configuration.SmtpRelayHost) == 0 {
+		return ""
+	}
+
+	port := Configuration.SmtpRelayPort
+	if len(port) == 0 {
+		port = DefaultSmtpRelayPort
+	}
+
+	return net.JoinHostPort(Configuration.SmtpRelayHost, port)
+}
+
+// SmtpRelayTLS returns how the connection to the relay is secured, starttls (the submission port), tls
+// (the implicit TLS of the port 465) or none.
+func SmtpRelayTLS() string {
+	tls := strings.ToLower(Configuration.SmtpRelayTLS)
+
+	switch tls {
+	case "":
+		return DefaultSmtpRelayTLS
+	case "starttls", "tls", "none":
+		return tls
+	default:
+		log.Printf("invalid smtpRelayTLS %q, using %s", Configuration.SmtpRelayTLS, DefaultSmtpRelayTLS)
+		return DefaultSmtpRelayTLS
+	}
+}
+
+// SmtpRelayTimeout returns how long a message submission to the relay may take, e.g. 30s.
+func SmtpRelayTimeout() time.Duration {
+	return timeout("smtpRelayTimeout", Configuration.SmtpRelayTimeout, DefaultSmtpTimeout)
+}
+
+// FederationPeers returns the base URLs of the cargomail instances of the domains, set by federationPeers,
+// e.g. "example.org=https://mds.example.org:2126,example.net=https://example.net". The messages to the
+// recipients of the peer domains are delivered to their instances instead of the SMTP relay.
+func FederationPeers() map[string]string {
+	federationPeersOnce.Do(func() {
+		federationPeers = parseFederationPeers(Configuration.FederationPeers)
+	})
+
+	return federationPeers
+}
+
+func parseFederationPeers(value string) map[string]string {
+	peers := map[string]string{}
+
+	for _, entry := range strings.Split(value, ",") {
+		if len(strings.TrimSpace(entry)) == 0 {
+			continue
+		}
+
+		domain, baseUrl, _ := strings.Cut(entry, "=")
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		baseUrl = strings.TrimRight(strings.TrimSpace(baseUrl), "/")
+
+		u, err := url.Parse(baseUrl)
+		if err != nil || len(domain) == 0 || len(u.Host) == 0 || (u.Scheme != "https" && !(u.Scheme == "http" && DevStage())) {
+			log.Printf("invalid federationPeers entry %q, ignored", entry)
+			continue
+		}
+
+		peers[domain] = baseUrl
+	}
+
+	return peers
+}
+
+// FederationPeer returns the base URL of the instance of the domain, if it is a peer.
+func FederationPeer(domain string) (string, bool) {
+	baseUrl, ok := FederationPeers()[strings.ToLower(domain)]
+	return baseUrl, ok
+}
+
+// TrustedProxies returns the networks of the reverse proxies the X-Forwarded-For header is trusted of, set
+// by trustedProxies, e.g. "127.0.0.1,10.0.0.0/8". None by default, i.e. the client IP is the peer address.
+func TrustedProxies() []*net.IPNet {
+	trustedProxiesOnce.Do(func() {
+		trustedProxies = ParseTrustedProxies(Configuration.TrustedProxies)
+	})
+
+	return trustedProxies
+}
+
+// ParseTrustedProxies parses the comma separated IPs and CIDRs, a single IP is a network of its own.
+func ParseTrustedProxies(value string) []*net.IPNet {
+	var networks []*net.IPNet
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if len(entry) == 0 {
+			continue
+		}
+
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil {
+				bits := 8 * len(ip)
+				if ip.To4() != nil {
+					ip, bits = ip.To4(), 32
+				}
+
+				networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+				continue
+			}
+		}
+
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			log.Printf("invalid trustedProxies entry %q, ignored", entry)
+			continue
+		}
+
+		networks = append(networks, network)
+	}
+
+	return networks
+}
+
+// VerifyDownloads tells whether the HEAD and the range requests of the blobs and the files re-hash the
+// stored bytes first, as the whole downloads do. Otherwise they do on ?verify=1 only.
+func VerifyDownloads() bool {
+	if len(Configuration.VerifyDownloads) == 0 {
+		return false
+	}
+
+	verify, err := strconv.ParseBool(Configuration.VerifyDownloads)
+	if err != nil {
+		log.Printf("invalid verifyDownloads %q, the downloads are verified on request", Configuration.VerifyDownloads)
+		return false
+	}
+
+	return verify
+}
+
+// StripImageMetadata tells whether the EXIF, XMP and IPTC metadata (e.g. the GPS position, the camera) of
+// the uploaded JPEG and TIFF images is dropped before they are stored.
+func StripImageMetadata() bool {
+	if len(Configuration.StripImageMetadata) == 0 {
+		return false
+	}
+
+	strip, err := strconv.ParseBool(Configuration.StripImageMetadata)
+	if err != nil {
+		log.Printf("invalid stripImageMetadata %q, the image metadata is kept", Configuration.StripImageMetadata)
+		return false
+	}
+
+	return strip
+}
+
+// UrlSigningKey returns the secret the download URLs are signed with. Without one configured, a random key
+// is used, so the signed URLs don't outlive the process.
+func UrlSigningKey() []byte {
+	urlSigningKeyOnce.Do(func() {
+		if len(Configuration.UrlSigningKey) > 0 {
+			urlSigningKey = []byte(Configuration.UrlSigningKey)
+			return
+		}
+
+		log.Print("no urlSigningKey configured, the signed URLs are valid until the restart")
+
+		urlSigningKey = make([]byte, 32)
+		_, err := rand.Read(urlSigningKey)
+		if err != nil {
+			log.Fatal(err)
+		}
+	})
+
+	return urlSigningKey
+}
+
+// Rate is the number of requests allowed per period, the zero rate is unlimited.
+type Rate struct {
+	Requests int
+	Per      time.Duration
+}
+
+func (r Rate) Unlimited() bool {
+	return r.Requests <= 0 || r.Per <= 0
+}
+
+// RateLimit returns the rate of the requests of a client IP, e.g. "300/m", unlimited by default.
+func RateLimit() Rate {
+	return rate("rateLimit", Configuration.RateLimit)
+}
+
+// UserRateLimit returns the rate of the authenticated requests of a user, e.g. "600/m", unlimited by
+// default.
+func UserRateLimit() Rate {
+	return rate("userRateLimit", Configuration.UserRateLimit)
+}
+
+// RateLimitsByPath returns the rates of the route groups set by rateLimitByPath, e.g. "/api/v1/auth/=10/m,
+// /api/v1/blobs/upload=60/m", the client IP requests under the path prefix are limited by them instead
+// of the rateLimit. The auth routes are limited to 20 requests a minute by default.
+func RateLimitsByPath() map[string]Rate {
+	value := Configuration.RateLimitByPath
+	if len(value) == 0 {
+		value = DefaultRateLimitPaths
+	}
+
+	rates := map[string]Rate{}
+
+	for _, entry := range strings.Split(value, ",") {
+		if len(strings.TrimSpace(entry)) == 0 {
+			continue
+		}
+
+		path, limit, ok := strings.Cut(entry, "=")
+		if !ok {
+			log.Printf("invalid rateLimitByPath entry %q, ignored", entry)
+			continue
+		}
+
+		rates[strings.TrimSpace(path)] = rate("rateLimitByPath", limit)
+	}
+
+	return rates
+}
+
+// rate parses the "requests/period" rate, the period is s, m or h. Zero is unlimited.
+func rate(name, value string) Rate {
+	value = strings.TrimSpace(value)
+	if len(value) == 0 || value == "0" {
+		return Rate{}
+	}
+
+	count, unit, _ := strings.Cut(value, "/")
+
+	periods := map[string]time.Duration{"s": time.Second, "m": time.Minute, "h": time.Hour}
+
+	requests, err := strconv.Atoi(count)
+	per, ok := periods[unit]
+	if err != nil || requests < 0 || !ok {
+		log.Printf("invalid %s %q, unlimited", name, value)
+		return Rate{}
+	}
+
+	return Rate{Requests: requests, Per: per}
+}
+
+// MaxUploadBytes returns the largest blob or file accepted, the upload request bodies are capped by it.
+func MaxUploadBytes() int64 {
+	if len(Configuration.MaxUploadBytes) == 0 {
+		return DefaultMaxUploadSize << 20
+	}
+
+	max, err := strconv.ParseInt(Configuration.MaxUploadBytes, 10, 64)
+	if err != nil || max <= 0 {
+		log.Printf("invalid maxUploadBytes %q, using the default of %d MB", Configuration.MaxUploadBytes, DefaultMaxUploadSize)
+		return DefaultMaxUploadSize << 20
+	}
+
+	return max
+}
+
+// MaxUploadBytesFor returns the largest upload of the content type, i.e. the limit of the type (e.g.
+// image/png) or of its family (e.g. image/*) set by maxUploadBytesByType, e.g. "image/*=10485760,
+// video/*=1073741824". The limits are capped by maxUploadBytes.
+func MaxUploadBytesFor(contentType string) int64 {
+	max := MaxUploadBytes()
+
+	uploadLimitsOnce.Do(func() {
+		uploadLimits = parseUploadLimits(Configuration.MaxUploadBytesByType)
+	})
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return max
+	}
+
+	limit, ok := uploadLimits[mediaType]
+	if !ok {
+		family, _, _ := strings.Cut(mediaType, "/")
+		limit, ok = uploadLimits[family+"/*"]
+	}
+
+	if ok && limit < max {
+		return limit
+	}
+
+	return max
+}
+
+func parseUploadLimits(value string) map[string]int64 {
+	limits := map[string]int64{}
+
+	for _, entry := range strings.Split(value, ",") {
+		if len(strings.TrimSpace(entry)) == 0 {
+			continue
+		}
+
+		contentType, bytes, _ := strings.Cut(entry, "=")
+
+		limit, err := strconv.ParseInt(strings.TrimSpace(bytes), 10, 64)
+		if err != nil || limit <= 0 {
+			log.Printf("invalid maxUploadBytesByType entry %q, ignored", entry)
+			continue
+		}
+
+		limits[strings.ToLower(strings.TrimSpace(contentType))] = limit
+	}
+
+	return limits
+}
+
+// TrashRetention returns how long the trashed items are kept before they are purged for good. The purge is
+// opt-in, zero (unset) disables it.
+func TrashRetention() time.Duration {
+	if len(Configuration.TrashRetentionDays) == 0 {
+		return 0
+	}
+
+	days, err := strconv.Atoi(Configuration.TrashRetentionDays)
+	if err != nil {
+		log.Printf("invalid trashRetentionDays %q, the trash is not purged", Configuration.TrashRetentionDays)
+		return 0
+	}
+
+	if days <= 0 {
+		return 0
+	}
+
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// MaxInflight returns the maximum number of requests served at once, zero disables the limit.
+func MaxInflight() int {
+	if len(Configuration.MaxInflight) == 0 {
+		return DefaultMaxInflight
+	}
+
+	max, err := strconv.Atoi(Configuration.MaxInflight)
+	if err != nil {
+		log.Printf("invalid maxInflight %q, using the default of %d requests", Configuration.MaxInflight, DefaultMaxInflight)
+		return DefaultMaxInflight
+	}
+
+	if max <= 0 {
+		return 0
+	}
+
+	return max
+}
+
+// InflightQueue tells whether the requests over the in-flight limit wait for a free slot (queue)
+// rather than being rejected right away (reject).
+func InflightQueue() bool {
+	return strings.EqualFold(Configuration.InflightLimitMode, "queue")
+}
+
+// DraftVersions returns how many previous payloads are kept per draft, zero disables the versions.
+func DraftVersions() int {
+	if len(Configuration.DraftVersions) == 0 {
+		return DefaultDraftVersions
+	}
+
+	versions, err := strconv.Atoi(Configuration.DraftVersions)
+	if err != nil {
+		log.Printf("invalid draftVersions %q, using the default of %d versions", Configuration.DraftVersions, DefaultDraftVersions)
+		return DefaultDraftVersions
+	}
+
+	if versions <= 0 {
+		return 0
+	}
+
+	return versions
+}
+
+// ReadTimeout returns how long the database reads of a request may take, e.g. 3s.
+func ReadTimeout() time.Duration {
+	return timeout("readTimeout", Configuration.ReadTimeout, DefaultReadTimeout)
+}
+
+// WriteTimeout returns how long the database writes of a request may take, e.g. 5s.
+func WriteTimeout() time.Duration {
+	return timeout("writeTimeout", Configuration.WriteTimeout, DefaultWriteTimeout)
+}
+
+// BusyRetries returns how many times a transaction is attempted while the database is busy or locked,
+// one disables the retries.
+func BusyRetries() int {
+	if len(Configuration.BusyRetries) == 0 {
+		return DefaultBusyRetries
+	}
+
+	retries, err := strconv.Atoi(Configuration.BusyRetries)
+	if err != nil || retries < 1 {
+		log.Printf("invalid busyRetries %q, using the default of %d attempts", Configuration.BusyRetries, DefaultBusyRetries)
+		return DefaultBusyRetries
+	}
+
+	return retries
+}
+
+// DatabaseDialect returns the SQL dialect of the database, sqlite or postgres.
+func DatabaseDialect() string {
+	if len(Configuration.DatabaseDialect) == 0 {
+		return DefaultDialect
+	}
+
+	return Configuration.DatabaseDialect
+}
+
+// MaxOpenConns returns the size of the database connection pool, zero means unlimited. SQLite allows
+// one writer at a time, so a small pool (e.g. 4) trades the read concurrency for fewer busy errors.
+func MaxOpenConns() int {
+	return connections("maxOpenConns", Configuration.MaxOpenConns, DefaultMaxOpenConns)
+}
+
+// MaxIdleConns returns how many idle database connections are kept open, zero keeps none.
+func MaxIdleConns() int {
+	return connections("maxIdleConns", Configuration.MaxIdleConns, DefaultMaxIdleConns)
+}
+
+// ConnMaxLifetime returns how long a database connection is reused, zero reuses it forever.
+func ConnMaxLifetime() time.Duration {
+	if len(Configuration.ConnMaxLifetime) == 0 {
+		return 0
+	}
+
+	lifetime, err := time.ParseDuration(Configuration.ConnMaxLifetime)
+	if err != nil || lifetime < 0 {
+		log.Printf("invalid connMaxLifetime %q, the connections are reused forever", Configuration.ConnMaxLifetime)
+		return 0
+	}
+
+	return lifetime
+}
+
+func connections(name string, value string, defaultConnections int) int {
+	if len(value) == 0 {
+		return defaultConnections
+	}
+
+	connections, err := strconv.Atoi(value)
+	if err != nil || connections < 0 {
+		log.Printf("invalid %s %q, using the default of %d connections", name, value, defaultConnections)
+		return defaultConnections
+	}
+
+	return connections
+}
+
+func timeout(name string, value string, defaultTimeout time.Duration) time.Duration {
+	if len(value) == 0 {
+		return defaultTimeout
+	}
+
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		log.Printf("invalid %s %q, using the default of %s", name, value, defaultTimeout)
+		return defaultTimeout
+	}
+
+	return timeout
+}
+
 func init() {
 	Configuration = newConfig()
 }
@@ -3,9 +3,11 @@ package database
 import (
 	_ "embed"
 
+	"cargomail/internal/shared/config"
 	"context"
 	"database/sql"
 	"log"
+	"strings"
 	"time"
 )
 
@@ -26,49 +28,166 @@ var (
 	labelTriggers string
 	//go:embed schema/contact_triggers.sql
 	contactTriggers string
+	//go:embed schema/contact_group_triggers.sql
+	contactGroupTriggers string
+	//go:embed schema/saved_search_triggers.sql
+	savedSearchTriggers string
+	//go:embed schema/collection_triggers.sql
+	collectionTriggers string
+	//go:embed schema/event_triggers.sql
+	eventTriggers string
+	//go:embed schema/audit_triggers.sql
+	auditTriggers string
 )
 
+// Connect opens the database of the configured dialect and sizes its connection pool from the
+// configuration. The foreign keys are enforced on every connection of the pool, so the deletes of the
+// users and the resources cascade.
+func Connect(dataSourceName string) (*sql.DB, error) {
+	d, err := dialectOf(config.DatabaseDialect())
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open(d.Driver(), d.DataSourceName(dataSourceName))
+	if err != nil {
+		return nil, err
+	}
+
+	dialect = d
+
+	db.SetMaxOpenConns(config.MaxOpenConns())
+	db.SetMaxIdleConns(config.MaxIdleConns())
+	db.SetConnMaxLifetime(config.ConnMaxLifetime())
+
+	return db, nil
+}
+
+// withForeignKeys adds the _foreign_keys parameter to the data source name, unless it is set already.
+func withForeignKeys(dataSourceName string) string {
+	if strings.Contains(dataSourceName, "_foreign_keys=") || strings.Contains(dataSourceName, "_fk=") {
+		return dataSourceName
+	}
+
+	if strings.Contains(dataSourceName, "?") {
+		return dataSourceName + "&_foreign_keys=1"
+	}
+
+	return dataSourceName + "?_foreign_keys=1"
+}
+
+// Init creates the tables and the triggers, the tables of an existing database are migrated. The schema
+// is the one of SQLite, the databases of the other dialects are to be created by their operators.
 func Init(db *sql.DB) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if _, ok := dialect.(sqliteDialect); !ok {
+		log.Fatal("sql schema: no schema of the ", dialect.Name(), " dialect")
+	}
+
+	// the migrations of a large database take a while
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
 	defer cancel()
 
-	_, err := db.ExecContext(ctx, tables)
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		log.Fatal("sql conn: ", err)
+	}
+	defer conn.Close()
+
+	// the rows of a rebuilt table are not to be deleted by the cascade of its drop, the pragma is a no-op
+	// within the transaction
+	_, err = conn.ExecContext(ctx, `PRAGMA foreign_keys=OFF;`)
+	if err != nil {
+		log.Fatal("sql foreign keys: ", err)
+	}
+
+	// the schema is changed at once, or not at all
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		log.Fatal("sql begin: ", err)
+	}
+	defer tx.Rollback()
+
+	err = dropTriggers(ctx, tx)
+	if err != nil {
+		log.Fatal("sql drop triggers: ", err)
+	}
+
+	err = migrate(ctx, tx)
+	if err != nil {
+		log.Fatal("sql migrate: ", err)
+	}
+
+	_, err = tx.ExecContext(ctx, tables)
 	if err != nil {
 		log.Fatal("sql tables: ", err)
 	}
 
-	_, err = db.ExecContext(ctx, userTriggers)
+	_, err = tx.ExecContext(ctx, userTriggers)
 	if err != nil {
 		log.Fatal("sql user triggers: ", err)
 	}
 
-	_, err = db.ExecContext(ctx, blobTriggers)
+	_, err = tx.ExecContext(ctx, blobTriggers)
 	if err != nil {
 		log.Fatal("sql blob triggers: ", err)
 	}
 
-	_, err = db.ExecContext(ctx, fileTriggers)
+	_, err = tx.ExecContext(ctx, fileTriggers)
 	if err != nil {
 		log.Fatal("sql file triggers: ", err)
 	}
 
-	_, err = db.ExecContext(ctx, draftTriggers)
+	_, err = tx.ExecContext(ctx, draftTriggers)
 	if err != nil {
 		log.Fatal("sql draft triggers: ", err)
 	}
 
-	_, err = db.ExecContext(ctx, messageTriggers)
+	_, err = tx.ExecContext(ctx, messageTriggers)
 	if err != nil {
 		log.Fatal("sql message triggers: ", err)
 	}
 
-	_, err = db.ExecContext(ctx, labelTriggers)
+	_, err = tx.ExecContext(ctx, labelTriggers)
 	if err != nil {
 		log.Fatal("sql label triggers: ", err)
 	}
 
-	_, err = db.ExecContext(ctx, contactTriggers)
+	_, err = tx.ExecContext(ctx, contactTriggers)
 	if err != nil {
 		log.Fatal("sql contact triggers: ", err)
 	}
+
+	_, err = tx.ExecContext(ctx, contactGroupTriggers)
+	if err != nil {
+		log.Fatal("sql contact group triggers: ", err)
+	}
+
+	_, err = tx.ExecContext(ctx, savedSearchTriggers)
+	if err != nil {
+		log.Fatal("sql saved search triggers: ", err)
+	}
+
+	_, err = tx.ExecContext(ctx, collectionTriggers)
+	if err != nil {
+		log.Fatal("sql collection triggers: ", err)
+	}
+
+	_, err = tx.ExecContext(ctx, eventTriggers)
+	if err != nil {
+		log.Fatal("sql event triggers: ", err)
+	}
+
+	_, err = tx.ExecContext(ctx, auditTriggers)
+	if err != nil {
+		log.Fatal("sql audit triggers: ", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Fatal("sql commit: ", err)
+	}
+
+	_, err = conn.ExecContext(ctx, `PRAGMA foreign_keys=ON;`)
+	if err != nil {
+		log.Fatal("sql foreign keys: ", err)
+	}
 }
@@ -0,0 +1,297 @@
+package database
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// openBaseline opens a database of the baseline schema, with a user and a label of the user, and the rows
+// left by a deleted user. It is opened without the foreign keys, as the baseline did.
+func openBaseline(t *testing.T) *sql.DB {
+	t.Helper()
+
+	baseline, err := os.ReadFile(filepath.Join("testdata", "baseline.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "baseline.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	// the pragmas are of the connection
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(string(baseline))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.Exec(`INSERT INTO "User" ("username", "passwordHash") VALUES ('alice', 'x'), ('bob', 'x');`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.Exec(`PRAGMA foreign_keys=OFF; DELETE FROM "User" WHERE "username" = 'bob';`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.Exec(`INSERT INTO "Label" ("userId", "name") VALUES (1, 'Work');`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.Exec(`INSERT INTO "Contact" ("userId", "emailAddress") VALUES (1, 'carol@example.com');`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestInitMigratesBaseline(t *testing.T) {
+	db := openBaseline(t)
+
+	Init(db)
+
+	for _, c := range addedColumns {
+		var n int
+
+		err := db.QueryRow(`SELECT count(*) FROM pragma_table_info($1) WHERE "name" = $2;`, c.table, c.name).Scan(&n)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n != 1 {
+			t.Errorf("column %s.%s not added", c.table, c.name)
+		}
+	}
+
+	var system, historyIds int
+
+	err := db.QueryRow(`SELECT count(*), count(DISTINCT "historyId") FROM "Label" WHERE "userId" = 1 AND "system";`).
+		Scan(&system, &historyIds)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if system != 3 || historyIds != 3 {
+		t.Errorf("got %d system labels of %d history ids, want 3 of 3", system, historyIds)
+	}
+
+	// the label triggers of the baseline don't know the color
+	_, err = db.Exec(`UPDATE "Label" SET "color" = '#1a73e8' WHERE "name" = 'Work';`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var lastStmt int
+
+	err = db.QueryRow(`SELECT "lastStmt" FROM "Label" WHERE "name" = 'Work';`).Scan(&lastStmt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lastStmt != 1 {
+		t.Errorf("got lastStmt %d after the color update, want 1", lastStmt)
+	}
+}
+
+// columnsOf returns the columns of the tables, in their order, with their types and constraints.
+func columnsOf(t *testing.T, db *sql.DB) map[string][]string {
+	t.Helper()
+
+	rows, err := db.Query(`
+		SELECT "table"."name", "column"."name", "column"."type", "column"."notnull", coalesce("column"."dflt_value", ''), "column"."pk"
+			FROM sqlite_master AS "table", pragma_table_info("table"."name") AS "column"
+			WHERE "table"."type" = 'table'
+			ORDER BY "table"."name", "column"."cid";`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rows.Close()
+
+	columns := map[string][]string{}
+
+	for rows.Next() {
+		var table, name, kind, defaultValue string
+		var notNull, pk int
+
+		err := rows.Scan(&table, &name, &kind, &notNull, &defaultValue, &pk)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		columns[table] = append(columns[table], strings.Join([]string{name, kind, strconv.Itoa(notNull), defaultValue, strconv.Itoa(pk)}, " "))
+	}
+
+	if err = rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return columns
+}
+
+// the repositories scan the SELECT * by the column order
+func TestInitMatchesNewDatabase(t *testing.T) {
+	db := openBaseline(t)
+
+	Init(db)
+
+	created, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "created.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { created.Close() })
+
+	Init(created)
+
+	want := columnsOf(t, created)
+
+	for table, columns := range columnsOf(t, db) {
+		if strings.Join(columns, ", ") != strings.Join(want[table], ", ") {
+			t.Errorf("table %s of the columns\n%v\nwant\n%v", table, columns, want[table])
+		}
+	}
+}
+
+func TestInitRebuildsUser(t *testing.T) {
+	db := openBaseline(t)
+
+	Init(db)
+
+	var autoincrement bool
+
+	err := db.QueryRow(`SELECT instr("sql", 'AUTOINCREMENT') > 0 FROM sqlite_master WHERE "name" = 'User';`).Scan(&autoincrement)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !autoincrement {
+		t.Error("the ids of the users are reused")
+	}
+
+	// the rows of the user are kept, the ones of the deleted user are not
+	var labels, orphans int
+
+	err = db.QueryRow(`SELECT count(*) FROM "Label" WHERE "userId" = 1 AND "name" = 'Work';`).Scan(&labels)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.QueryRow(`SELECT count(*) FROM "BlobTimelineSeq" WHERE "userId" = 2;`).Scan(&orphans)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if labels != 1 || orphans != 0 {
+		t.Errorf("got %d labels of the user and %d rows of the deleted user, want 1 and 0", labels, orphans)
+	}
+
+	_, err = db.Exec(`INSERT INTO "User" ("username", "passwordHash") VALUES ('carol', 'x');`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the id of the user deleted before the rebuild is free again, its rows are gone
+	var id int64
+
+	err = db.QueryRow(`SELECT "id" FROM "User" WHERE "username" = 'carol';`).Scan(&id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id != 2 {
+		t.Errorf("got the id %d of the new user, want 2", id)
+	}
+}
+
+func TestInitRebuildsContact(t *testing.T) {
+	db := openBaseline(t)
+
+	var rowid int64
+
+	err := db.QueryRow(`SELECT "rowid" FROM "Contact" WHERE "emailAddress" = 'carol@example.com';`).Scan(&rowid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Init(db)
+
+	// the full-text search index refers to the rowid
+	var migrated int64
+
+	err = db.QueryRow(`SELECT "rowid" FROM "Contact" WHERE "emailAddress" = 'carol@example.com';`).Scan(&migrated)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if migrated != rowid {
+		t.Errorf("got the rowid %d of the contact, want %d", migrated, rowid)
+	}
+
+	// a contact of a name only, and an address the baseline check rejected
+	_, err = db.Exec(`
+		INSERT INTO "Contact" ("userId", "emailAddress", "firstName")
+			VALUES (1, NULL, 'Dave'), (1, 'dave+news@example.com', 'Dave');`)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// the trash trigger of the baseline kept the "modifiedAt", the retention starts at it now
+func TestInitReplacesTriggers(t *testing.T) {
+	db := openBaseline(t)
+
+	Init(db)
+
+	_, err := db.Exec(`UPDATE "Contact" SET "lastStmt" = 2 WHERE "emailAddress" = 'carol@example.com';`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var trashed bool
+
+	err = db.QueryRow(`SELECT "modifiedAt" IS NOT NULL FROM "Contact" WHERE "emailAddress" = 'carol@example.com';`).Scan(&trashed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !trashed {
+		t.Error("the trash time of the contact not set")
+	}
+}
+
+func TestInitTwice(t *testing.T) {
+	db := openBaseline(t)
+
+	Init(db)
+	Init(db)
+
+	var triggers int
+
+	err := db.QueryRow(`SELECT count(*) FROM sqlite_master WHERE "type" = 'trigger';`).Scan(&triggers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, script := range []string{userTriggers, blobTriggers, fileTriggers, draftTriggers, messageTriggers,
+		labelTriggers, contactTriggers, contactGroupTriggers, savedSearchTriggers, collectionTriggers,
+		eventTriggers, auditTriggers} {
+		triggers -= strings.Count(script, "CREATE TRIGGER")
+	}
+
+	if triggers != 0 {
+		t.Errorf("got %d triggers more than the scripts create", triggers)
+	}
+}
@@ -0,0 +1,82 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrUnknownDialect = errors.New("unknown database dialect")
+
+// Dialect renders the fragments of the SQL the databases differ in, the rest of the queries of the
+// repositories is common to them.
+type Dialect interface {
+	Name() string
+	// Driver is the name of the database/sql driver of the dialect, the driver is registered by its import.
+	Driver() string
+	// DataSourceName adds the connection parameters the repositories rely on.
+	DataSourceName(dataSourceName string) string
+	// JsonIds selects the ids of the "ids" array of the JSON object of the parameter, e.g. of the
+	// {"ids": ["a", "b"]} of the Trash, for the IN.
+	JsonIds(param string) string
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string {
+	return "sqlite"
+}
+
+func (sqliteDialect) Driver() string {
+	return "sqlite3"
+}
+
+// DataSourceName adds the _foreign_keys parameter, unless it is set already.
+func (sqliteDialect) DataSourceName(dataSourceName string) string {
+	return withForeignKeys(dataSourceName)
+}
+
+func (sqliteDialect) JsonIds(param string) string {
+	return `SELECT value FROM json_each(` + param + `, '$.ids')`
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string {
+	return "postgres"
+}
+
+func (postgresDialect) Driver() string {
+	return "postgres"
+}
+
+// DataSourceName keeps the data source name, Postgres enforces the foreign keys always.
+func (postgresDialect) DataSourceName(dataSourceName string) string {
+	return dataSourceName
+}
+
+func (postgresDialect) JsonIds(param string) string {
+	return `SELECT jsonb_array_elements_text((` + param + `)::jsonb -> 'ids')`
+}
+
+var dialects = map[string]Dialect{
+	"sqlite":   sqliteDialect{},
+	"postgres": postgresDialect{},
+}
+
+// dialect is the one of the database the Connect opened last, the primary and its replica are of the same.
+var dialect Dialect = sqliteDialect{}
+
+// CurrentDialect returns the dialect of the database, SQLite unless the Connect chose another one.
+func CurrentDialect() Dialect {
+	return dialect
+}
+
+func dialectOf(name string) (Dialect, error) {
+	d, ok := dialects[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownDialect, name)
+	}
+
+	return d, nil
+}
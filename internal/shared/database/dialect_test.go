@@ -0,0 +1,83 @@
+package database
+
+import (
+	"cargomail/internal/shared/config"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestConnectDialect(t *testing.T) {
+	databaseDialect := config.Configuration.DatabaseDialect
+	t.Cleanup(func() {
+		config.Configuration.DatabaseDialect = databaseDialect
+		dialect = sqliteDialect{}
+	})
+
+	config.Configuration.DatabaseDialect = "oracle"
+
+	_, err := Connect(filepath.Join(t.TempDir(), "cargomail.db"))
+	if !errors.Is(err, ErrUnknownDialect) {
+		t.Errorf("got %v, want %v", err, ErrUnknownDialect)
+	}
+
+	// no Postgres driver is linked to the tests, the dialect is kept
+	config.Configuration.DatabaseDialect = " Postgres "
+
+	_, err = Connect("postgres://localhost/cargomail")
+	if err == nil {
+		t.Error("the database of no driver connected")
+	}
+
+	if CurrentDialect().Name() != "sqlite" {
+		t.Errorf("got the dialect %s, want sqlite", CurrentDialect().Name())
+	}
+
+	postgres, err := dialectOf(config.DatabaseDialect())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := postgres.DataSourceName("postgres://localhost/cargomail"); got != "postgres://localhost/cargomail" {
+		t.Errorf("got the data source name %s", got)
+	}
+
+	if got, want := postgres.JsonIds("$3"), `SELECT jsonb_array_elements_text(($3)::jsonb -> 'ids')`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestSqliteJsonIds(t *testing.T) {
+	db, err := Connect(filepath.Join(t.TempDir(), "cargomail.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	rows, err := db.Query(CurrentDialect().JsonIds("$1"), `{"ids":["a","b"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	ids := []string{}
+
+	for rows.Next() {
+		var id string
+
+		err := rows.Scan(&id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids = append(ids, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ids) != 2 || ids[0] != "a" || ids[1] != "b" {
+		t.Errorf("got the ids %v, want [a b]", ids)
+	}
+}
@@ -0,0 +1,275 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// column is a column added to a table of tables.sql after the table was released. The CREATE TABLE IF
+// NOT EXISTS doesn't add it to the table of an existing database, so it is added by the migrate.
+type column struct {
+	table      string
+	name       string
+	definition string
+}
+
+// addedColumns are the added columns in the order they were added, the definitions are the ones of
+// tables.sql. The ALTER TABLE adds a column last, as the SELECT * of the repositories scans the columns
+// in their order, a column added before the last one of tables.sql takes a rebuiltTable.
+var addedColumns = []column{
+	{"Draft", "searchText", `TEXT`},
+	{"Contact", "emailAddresses", `TEXT`},
+	{"Contact", "phoneNumbers", `TEXT`},
+	{"Contact", "notes", `TEXT`},
+	{"User", "quotaBytes", `INTEGER`},
+	{"Session", "deviceId", `VARCHAR(32)`},
+}
+
+// rebuiltTable is a table whose constraints or column order changed after the table was released, which
+// the ALTER TABLE can't change. The table is created anew of tables.sql and its rows are copied over, while
+// the pending condition on the "sql" of the table in sqlite_master holds.
+type rebuiltTable struct {
+	name    string
+	pending string
+	after   func(ctx context.Context, tx *sql.Tx) error // run once the table is rebuilt, nil = none
+}
+
+var rebuiltTables = []rebuiltTable{
+	{"User", `instr("sql", 'AUTOINCREMENT') = 0`, deleteOrphans},
+	{"Contact", `instr("sql", '"emailAddress"  VARCHAR(255) NOT NULL') > 0`, nil},
+	{"Label", `instr("sql", '"system"') = 0`, nil},
+}
+
+// migrate brings the tables of an existing database to the ones of tables.sql. The tables not created
+// yet are left to tables.sql. The foreign keys are expected to be off.
+func migrate(ctx context.Context, tx *sql.Tx) error {
+	err := addColumns(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	for _, table := range rebuiltTables {
+		var pending bool
+
+		query := `
+			SELECT count(*) > 0
+				FROM sqlite_master
+				WHERE "type" = 'table' AND
+				"name" = $1 AND
+				` + table.pending + `;`
+
+		err := tx.QueryRowContext(ctx, query, table.name).Scan(&pending)
+		if err != nil {
+			return err
+		}
+
+		if !pending {
+			continue
+		}
+
+		err = rebuildTable(ctx, tx, table.name)
+		if err != nil {
+			return fmt.Errorf("rebuild table %s: %w", table.name, err)
+		}
+
+		if table.after != nil {
+			err = table.after(ctx, tx)
+			if err != nil {
+				return fmt.Errorf("rebuild table %s: %w", table.name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func addColumns(ctx context.Context, tx *sql.Tx) error {
+	for _, c := range addedColumns {
+		columns, err := tableColumns(ctx, tx, c.table)
+		if err != nil {
+			return err
+		}
+
+		if len(columns) == 0 || columns[c.name] {
+			continue
+		}
+
+		query := `ALTER TABLE "` + c.table + `" ADD COLUMN "` + c.name + `" ` + c.definition + `;`
+
+		_, err = tx.ExecContext(ctx, query)
+		if err != nil {
+			return fmt.Errorf("add column %s.%s: %w", c.table, c.name, err)
+		}
+	}
+
+	return nil
+}
+
+// rebuildTable creates the table of tables.sql as a new one, copies the rows with their rowid, which the
+// full-text search indexes refer to, drops the old table and renames the new one. The indexes of the table
+// are created by tables.sql again.
+func rebuildTable(ctx context.Context, tx *sql.Tx, table string) error {
+	create := `CREATE TABLE IF NOT EXISTS "` + table + `"`
+
+	start := strings.Index(tables, create)
+	if start < 0 {
+		return fmt.Errorf("table %s not in tables.sql", table)
+	}
+
+	end := strings.Index(tables[start:], "\n);")
+	if end < 0 {
+		return fmt.Errorf("table %s not terminated in tables.sql", table)
+	}
+
+	replacement := `"` + table + `Rebuilt"`
+
+	_, err := tx.ExecContext(ctx, `CREATE TABLE `+replacement+tables[start+len(create):start+end]+"\n);")
+	if err != nil {
+		return err
+	}
+
+	columns, err := tableColumns(ctx, tx, table)
+	if err != nil {
+		return err
+	}
+
+	newColumns, err := tableColumns(ctx, tx, table+"Rebuilt")
+	if err != nil {
+		return err
+	}
+
+	copied := []string{"rowid"}
+
+	for name := range newColumns {
+		if columns[name] {
+			copied = append(copied, `"`+name+`"`)
+		}
+	}
+
+	list := strings.Join(copied, ", ")
+
+	statements := []string{
+		`INSERT INTO ` + replacement + ` (` + list + `) SELECT ` + list + ` FROM "` + table + `";`,
+		`DROP TABLE "` + table + `";`,
+		`ALTER TABLE ` + replacement + ` RENAME TO "` + table + `";`,
+	}
+
+	for _, statement := range statements {
+		_, err = tx.ExecContext(ctx, statement)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deleteOrphans deletes the rows left by the users deleted before the foreign keys were enforced, their
+// ids would be taken by the new users otherwise.
+func deleteOrphans(ctx context.Context, tx *sql.Tx) error {
+	query := `
+		SELECT "table", "rowid"
+			FROM pragma_foreign_key_check
+			WHERE lower("parent") = 'user' AND
+			"rowid" IS NOT NULL;`
+
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	orphans := map[string][]interface{}{}
+
+	for rows.Next() {
+		var table string
+		var rowid int64
+
+		err := rows.Scan(&table, &rowid)
+		if err != nil {
+			return err
+		}
+
+		orphans[table] = append(orphans[table], rowid)
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for table, rowids := range orphans {
+		for _, rowid := range rowids {
+			_, err = tx.ExecContext(ctx, `DELETE FROM "`+table+`" WHERE "rowid" = $1;`, rowid)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// tableColumns returns the column names of the table, none if the table doesn't exist.
+func tableColumns(ctx context.Context, tx *sql.Tx, table string) (map[string]bool, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT "name" FROM pragma_table_info($1);`, table)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	columns := map[string]bool{}
+
+	for rows.Next() {
+		var name string
+
+		err := rows.Scan(&name)
+		if err != nil {
+			return nil, err
+		}
+
+		columns[name] = true
+	}
+
+	return columns, rows.Err()
+}
+
+// dropTriggers drops all the triggers, they are created again by the trigger scripts. The CREATE TRIGGER
+// IF NOT EXISTS would keep the triggers of the previous version.
+func dropTriggers(ctx context.Context, tx *sql.Tx) error {
+	rows, err := tx.QueryContext(ctx, `SELECT "name" FROM sqlite_master WHERE "type" = 'trigger';`)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	var triggers []string
+
+	for rows.Next() {
+		var name string
+
+		err := rows.Scan(&name)
+		if err != nil {
+			return err
+		}
+
+		triggers = append(triggers, name)
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, name := range triggers {
+		_, err = tx.ExecContext(ctx, `DROP TRIGGER IF EXISTS "`+name+`";`)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
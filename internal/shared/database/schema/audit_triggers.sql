@@ -0,0 +1,388 @@
+-- The audit log is append-only, its entries are written in the transaction of the change itself, as the
+-- events are. A history change of a row with the "lastStmt" of 0 is the insert when the row had no
+-- history yet, the untrash otherwise. The device of a delete is known only once the tombstone is
+-- stamped with it, later in the same transaction.
+
+-- Blob
+CREATE TRIGGER IF NOT EXISTS "BlobAuditAfterHistory"
+    AFTER UPDATE OF
+        "historyId"
+    ON "Blob"
+    FOR EACH ROW
+    WHEN new."historyId" <> old."historyId"
+BEGIN
+    INSERT INTO "AuditLog" ("userId", "deviceId", "action", "resource", "resourceId", "historyId")
+      VALUES (new."userId",
+              iif(substr(new."deviceId", 1, 7) = 'device:', substr(new."deviceId", 8, 32), new."deviceId"),
+              CASE
+                  WHEN new."lastStmt" = 2 THEN 'trash'
+                  WHEN new."lastStmt" = 1 THEN 'update'
+                  WHEN old."historyId" = 0 THEN 'create'
+                  ELSE 'untrash'
+              END,
+              'blobs',
+              new."id",
+              new."historyId");
+END;
+
+CREATE TRIGGER IF NOT EXISTS "BlobAuditAfterDelete"
+    AFTER INSERT
+    ON "BlobDeleted"
+    FOR EACH ROW
+BEGIN
+    INSERT INTO "AuditLog" ("userId", "deviceId", "action", "resource", "resourceId", "historyId")
+      VALUES (new."userId", new."deviceId", 'delete', 'blobs', new."id", new."historyId");
+END;
+
+CREATE TRIGGER IF NOT EXISTS "BlobAuditAfterDeleteDevice"
+    AFTER UPDATE OF
+        "deviceId"
+    ON "BlobDeleted"
+    FOR EACH ROW
+    WHEN new."deviceId" IS NOT NULL
+BEGIN
+    UPDATE "AuditLog"
+    SET "deviceId" = new."deviceId"
+    WHERE "userId" = new."userId" AND
+          "resource" = 'blobs' AND
+          "resourceId" = new."id" AND
+          "historyId" = new."historyId" AND
+          "action" = 'delete' AND
+          "deviceId" IS NULL;
+END;
+
+-- File
+CREATE TRIGGER IF NOT EXISTS "FileAuditAfterHistory"
+    AFTER UPDATE OF
+        "historyId"
+    ON "File"
+    FOR EACH ROW
+    WHEN new."historyId" <> old."historyId"
+BEGIN
+    INSERT INTO "AuditLog" ("userId", "deviceId", "action", "resource", "resourceId", "historyId")
+      VALUES (new."userId",
+              iif(substr(new."deviceId", 1, 7) = 'device:', substr(new."deviceId", 8, 32), new."deviceId"),
+              CASE
+                  WHEN new."lastStmt" = 2 THEN 'trash'
+                  WHEN new."lastStmt" = 1 THEN 'update'
+                  WHEN old."historyId" = 0 THEN 'create'
+                  ELSE 'untrash'
+              END,
+              'files',
+              new."id",
+              new."historyId");
+END;
+
+CREATE TRIGGER IF NOT EXISTS "FileAuditAfterDelete"
+    AFTER INSERT
+    ON "FileDeleted"
+    FOR EACH ROW
+BEGIN
+    INSERT INTO "AuditLog" ("userId", "deviceId", "action", "resource", "resourceId", "historyId")
+      VALUES (new."userId", new."deviceId", 'delete', 'files', new."id", new."historyId");
+END;
+
+CREATE TRIGGER IF NOT EXISTS "FileAuditAfterDeleteDevice"
+    AFTER UPDATE OF
+        "deviceId"
+    ON "FileDeleted"
+    FOR EACH ROW
+    WHEN new."deviceId" IS NOT NULL
+BEGIN
+    UPDATE "AuditLog"
+    SET "deviceId" = new."deviceId"
+    WHERE "userId" = new."userId" AND
+          "resource" = 'files' AND
+          "resourceId" = new."id" AND
+          "historyId" = new."historyId" AND
+          "action" = 'delete' AND
+          "deviceId" IS NULL;
+END;
+
+-- Draft
+CREATE TRIGGER IF NOT EXISTS "DraftAuditAfterHistory"
+    AFTER UPDATE OF
+        "historyId"
+    ON "Draft"
+    FOR EACH ROW
+    WHEN new."historyId" <> old."historyId"
+BEGIN
+    INSERT INTO "AuditLog" ("userId", "deviceId", "action", "resource", "resourceId", "historyId")
+      VALUES (new."userId",
+              iif(substr(new."deviceId", 1, 7) = 'device:', substr(new."deviceId", 8, 32), new."deviceId"),
+              CASE
+                  WHEN new."lastStmt" = 2 THEN 'trash'
+                  WHEN new."lastStmt" = 1 THEN 'update'
+                  WHEN old."historyId" = 0 THEN 'create'
+                  ELSE 'untrash'
+              END,
+              'drafts',
+              new."id",
+              new."historyId");
+END;
+
+CREATE TRIGGER IF NOT EXISTS "DraftAuditAfterDelete"
+    AFTER INSERT
+    ON "DraftDeleted"
+    FOR EACH ROW
+BEGIN
+    INSERT INTO "AuditLog" ("userId", "deviceId", "action", "resource", "resourceId", "historyId")
+      VALUES (new."userId", new."deviceId", 'delete', 'drafts', new."id", new."historyId");
+END;
+
+CREATE TRIGGER IF NOT EXISTS "DraftAuditAfterDeleteDevice"
+    AFTER UPDATE OF
+        "deviceId"
+    ON "DraftDeleted"
+    FOR EACH ROW
+    WHEN new."deviceId" IS NOT NULL
+BEGIN
+    UPDATE "AuditLog"
+    SET "deviceId" = new."deviceId"
+    WHERE "userId" = new."userId" AND
+          "resource" = 'drafts' AND
+          "resourceId" = new."id" AND
+          "historyId" = new."historyId" AND
+          "action" = 'delete' AND
+          "deviceId" IS NULL;
+END;
+
+-- Message
+CREATE TRIGGER IF NOT EXISTS "MessageAuditAfterHistory"
+    AFTER UPDATE OF
+        "historyId"
+    ON "Message"
+    FOR EACH ROW
+    WHEN new."historyId" <> old."historyId"
+BEGIN
+    INSERT INTO "AuditLog" ("userId", "deviceId", "action", "resource", "resourceId", "historyId")
+      VALUES (new."userId",
+              iif(substr(new."deviceId", 1, 7) = 'device:', substr(new."deviceId", 8, 32), new."deviceId"),
+              CASE
+                  WHEN new."lastStmt" = 2 THEN 'trash'
+                  WHEN new."lastStmt" = 1 THEN 'update'
+                  WHEN old."historyId" = 0 THEN 'create'
+                  ELSE 'untrash'
+              END,
+              'messages',
+              new."id",
+              new."historyId");
+END;
+
+CREATE TRIGGER IF NOT EXISTS "MessageAuditAfterDelete"
+    AFTER INSERT
+    ON "MessageDeleted"
+    FOR EACH ROW
+BEGIN
+    INSERT INTO "AuditLog" ("userId", "deviceId", "action", "resource", "resourceId", "historyId")
+      VALUES (new."userId", new."deviceId", 'delete', 'messages', new."id", new."historyId");
+END;
+
+CREATE TRIGGER IF NOT EXISTS "MessageAuditAfterDeleteDevice"
+    AFTER UPDATE OF
+        "deviceId"
+    ON "MessageDeleted"
+    FOR EACH ROW
+    WHEN new."deviceId" IS NOT NULL
+BEGIN
+    UPDATE "AuditLog"
+    SET "deviceId" = new."deviceId"
+    WHERE "userId" = new."userId" AND
+          "resource" = 'messages' AND
+          "resourceId" = new."id" AND
+          "historyId" = new."historyId" AND
+          "action" = 'delete' AND
+          "deviceId" IS NULL;
+END;
+
+-- Label
+CREATE TRIGGER IF NOT EXISTS "LabelAuditAfterHistory"
+    AFTER UPDATE OF
+        "historyId"
+    ON "Label"
+    FOR EACH ROW
+    WHEN new."historyId" <> old."historyId"
+BEGIN
+    INSERT INTO "AuditLog" ("userId", "deviceId", "action", "resource", "resourceId", "historyId")
+      VALUES (new."userId",
+              iif(substr(new."deviceId", 1, 7) = 'device:', substr(new."deviceId", 8, 32), new."deviceId"),
+              CASE
+                  WHEN new."lastStmt" = 2 THEN 'trash'
+                  WHEN new."lastStmt" = 1 THEN 'update'
+                  WHEN old."historyId" = 0 THEN 'create'
+                  ELSE 'untrash'
+              END,
+              'labels',
+              new."id",
+              new."historyId");
+END;
+
+CREATE TRIGGER IF NOT EXISTS "LabelAuditAfterDelete"
+    AFTER INSERT
+    ON "LabelDeleted"
+    FOR EACH ROW
+BEGIN
+    INSERT INTO "AuditLog" ("userId", "deviceId", "action", "resource", "resourceId", "historyId")
+      VALUES (new."userId", new."deviceId", 'delete', 'labels', new."id", new."historyId");
+END;
+
+CREATE TRIGGER IF NOT EXISTS "LabelAuditAfterDeleteDevice"
+    AFTER UPDATE OF
+        "deviceId"
+    ON "LabelDeleted"
+    FOR EACH ROW
+    WHEN new."deviceId" IS NOT NULL
+BEGIN
+    UPDATE "AuditLog"
+    SET "deviceId" = new."deviceId"
+    WHERE "userId" = new."userId" AND
+          "resource" = 'labels' AND
+          "resourceId" = new."id" AND
+          "historyId" = new."historyId" AND
+          "action" = 'delete' AND
+          "deviceId" IS NULL;
+END;
+
+-- Contact
+CREATE TRIGGER IF NOT EXISTS "ContactAuditAfterHistory"
+    AFTER UPDATE OF
+        "historyId"
+    ON "Contact"
+    FOR EACH ROW
+    WHEN new."historyId" <> old."historyId"
+BEGIN
+    INSERT INTO "AuditLog" ("userId", "deviceId", "action", "resource", "resourceId", "historyId")
+      VALUES (new."userId",
+              iif(substr(new."deviceId", 1, 7) = 'device:', substr(new."deviceId", 8, 32), new."deviceId"),
+              CASE
+                  WHEN new."lastStmt" = 2 THEN 'trash'
+                  WHEN new."lastStmt" = 1 THEN 'update'
+                  WHEN old."historyId" = 0 THEN 'create'
+                  ELSE 'untrash'
+              END,
+              'contacts',
+              new."id",
+              new."historyId");
+END;
+
+CREATE TRIGGER IF NOT EXISTS "ContactAuditAfterDelete"
+    AFTER INSERT
+    ON "ContactDeleted"
+    FOR EACH ROW
+BEGIN
+    INSERT INTO "AuditLog" ("userId", "deviceId", "action", "resource", "resourceId", "historyId")
+      VALUES (new."userId", new."deviceId", 'delete', 'contacts', new."id", new."historyId");
+END;
+
+CREATE TRIGGER IF NOT EXISTS "ContactAuditAfterDeleteDevice"
+    AFTER UPDATE OF
+        "deviceId"
+    ON "ContactDeleted"
+    FOR EACH ROW
+    WHEN new."deviceId" IS NOT NULL
+BEGIN
+    UPDATE "AuditLog"
+    SET "deviceId" = new."deviceId"
+    WHERE "userId" = new."userId" AND
+          "resource" = 'contacts' AND
+          "resourceId" = new."id" AND
+          "historyId" = new."historyId" AND
+          "action" = 'delete' AND
+          "deviceId" IS NULL;
+END;
+
+-- ContactGroup
+CREATE TRIGGER IF NOT EXISTS "ContactGroupAuditAfterHistory"
+    AFTER UPDATE OF
+        "historyId"
+    ON "ContactGroup"
+    FOR EACH ROW
+    WHEN new."historyId" <> old."historyId"
+BEGIN
+    INSERT INTO "AuditLog" ("userId", "deviceId", "action", "resource", "resourceId", "historyId")
+      VALUES (new."userId",
+              iif(substr(new."deviceId", 1, 7) = 'device:', substr(new."deviceId", 8, 32), new."deviceId"),
+              CASE
+                  WHEN new."lastStmt" = 2 THEN 'trash'
+                  WHEN new."lastStmt" = 1 THEN 'update'
+                  WHEN old."historyId" = 0 THEN 'create'
+                  ELSE 'untrash'
+              END,
+              'contactGroups',
+              new."id",
+              new."historyId");
+END;
+
+CREATE TRIGGER IF NOT EXISTS "ContactGroupAuditAfterDelete"
+    AFTER INSERT
+    ON "ContactGroupDeleted"
+    FOR EACH ROW
+BEGIN
+    INSERT INTO "AuditLog" ("userId", "deviceId", "action", "resource", "resourceId", "historyId")
+      VALUES (new."userId", new."deviceId", 'delete', 'contactGroups', new."id", new."historyId");
+END;
+
+CREATE TRIGGER IF NOT EXISTS "ContactGroupAuditAfterDeleteDevice"
+    AFTER UPDATE OF
+        "deviceId"
+    ON "ContactGroupDeleted"
+    FOR EACH ROW
+    WHEN new."deviceId" IS NOT NULL
+BEGIN
+    UPDATE "AuditLog"
+    SET "deviceId" = new."deviceId"
+    WHERE "userId" = new."userId" AND
+          "resource" = 'contactGroups' AND
+          "resourceId" = new."id" AND
+          "historyId" = new."historyId" AND
+          "action" = 'delete' AND
+          "deviceId" IS NULL;
+END;
+
+-- Collection
+CREATE TRIGGER IF NOT EXISTS "CollectionAuditAfterHistory"
+    AFTER UPDATE OF
+        "historyId"
+    ON "Collection"
+    FOR EACH ROW
+    WHEN new."historyId" <> old."historyId"
+BEGIN
+    INSERT INTO "AuditLog" ("userId", "deviceId", "action", "resource", "resourceId", "historyId")
+      VALUES (new."userId",
+              iif(substr(new."deviceId", 1, 7) = 'device:', substr(new."deviceId", 8, 32), new."deviceId"),
+              CASE
+                  WHEN new."lastStmt" = 2 THEN 'trash'
+                  WHEN new."lastStmt" = 1 THEN 'update'
+                  WHEN old."historyId" = 0 THEN 'create'
+                  ELSE 'untrash'
+              END,
+              'collections',
+              new."id",
+              new."historyId");
+END;
+
+CREATE TRIGGER IF NOT EXISTS "CollectionAuditAfterDelete"
+    AFTER INSERT
+    ON "CollectionDeleted"
+    FOR EACH ROW
+BEGIN
+    INSERT INTO "AuditLog" ("userId", "deviceId", "action", "resource", "resourceId", "historyId")
+      VALUES (new."userId", new."deviceId", 'delete', 'collections', new."id", new."historyId");
+END;
+
+CREATE TRIGGER IF NOT EXISTS "CollectionAuditAfterDeleteDevice"
+    AFTER UPDATE OF
+        "deviceId"
+    ON "CollectionDeleted"
+    FOR EACH ROW
+    WHEN new."deviceId" IS NOT NULL
+BEGIN
+    UPDATE "AuditLog"
+    SET "deviceId" = new."deviceId"
+    WHERE "userId" = new."userId" AND
+          "resource" = 'collections' AND
+          "resourceId" = new."id" AND
+          "historyId" = new."historyId" AND
+          "action" = 'delete' AND
+          "deviceId" IS NULL;
+END;
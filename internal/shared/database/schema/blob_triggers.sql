@@ -85,6 +85,7 @@ BEGIN
     UPDATE "BlobHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = old."userId";
     UPDATE "Blob"
     SET "historyId"  = (SELECT "lastHistoryId" FROM "BlobHistorySeq" WHERE "userId" = old."userId"),
+        "modifiedAt" = CURRENT_TIMESTAMP, -- the trash retention starts here
         "deviceId" = iif(length(new."deviceId") = 39 AND substr(new."deviceId", 1, 7) = 'device:', substr(new."deviceId", 8, 32), NULL)
     WHERE "id" = old."id";
 END;
@@ -99,4 +100,63 @@ BEGIN
       VALUES (old."id",
               old."userId",
               (SELECT "lastHistoryId" FROM "BlobHistorySeq" WHERE "userId" = old."userId"));
-END;
\ No newline at end of file
+END;
+
+CREATE TRIGGER IF NOT EXISTS "BlobAfterInsertContent"
+AFTER INSERT
+ON "Blob"
+FOR EACH ROW
+BEGIN
+    UPDATE "BlobContent" SET "refcount" = ("refcount" + 1) WHERE "resource" = 'blobs' AND "digest" = new."digest";
+END;
+
+CREATE TRIGGER IF NOT EXISTS "BlobAfterUpdateContent"
+AFTER UPDATE OF
+    "digest"
+ON "Blob"
+FOR EACH ROW
+WHEN new."digest" <> old."digest"
+BEGIN
+    UPDATE "BlobContent" SET "refcount" = ("refcount" - 1) WHERE "resource" = 'blobs' AND "digest" = old."digest";
+    UPDATE "BlobContent" SET "refcount" = ("refcount" + 1) WHERE "resource" = 'blobs' AND "digest" = new."digest";
+END;
+
+CREATE TRIGGER IF NOT EXISTS "BlobAfterDeleteContent"
+AFTER DELETE
+ON "Blob"
+FOR EACH ROW
+BEGIN
+    UPDATE "BlobContent" SET "refcount" = ("refcount" - 1) WHERE "resource" = 'blobs' AND "digest" = old."digest";
+END;
+
+-- Tags, the blob is updated unless it is being deleted, the trashed blobs can't be tagged. The "deviceId"
+-- of the blob is set by the caller.
+CREATE TRIGGER IF NOT EXISTS "BlobTagAfterInsert"
+    AFTER INSERT
+    ON "BlobTag"
+    FOR EACH ROW
+BEGIN
+    UPDATE "BlobHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = new."userId";
+    UPDATE "Blob"
+    SET "historyId"  = (SELECT "lastHistoryId" FROM "BlobHistorySeq" WHERE "userId" = new."userId"),
+        "lastStmt"   = 1,
+        "modifiedAt" = CURRENT_TIMESTAMP,
+        "deviceId"   = NULL
+    WHERE "id" = new."blobId" AND
+        "lastStmt" <> 2;
+END;
+
+CREATE TRIGGER IF NOT EXISTS "BlobTagAfterDelete"
+    AFTER DELETE
+    ON "BlobTag"
+    FOR EACH ROW
+    WHEN EXISTS (SELECT 1 FROM "Blob" WHERE "id" = old."blobId" AND "lastStmt" <> 2)
+BEGIN
+    UPDATE "BlobHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = old."userId";
+    UPDATE "Blob"
+    SET "historyId"  = (SELECT "lastHistoryId" FROM "BlobHistorySeq" WHERE "userId" = old."userId"),
+        "lastStmt"   = 1,
+        "modifiedAt" = CURRENT_TIMESTAMP,
+        "deviceId"   = NULL
+    WHERE "id" = old."blobId";
+END;
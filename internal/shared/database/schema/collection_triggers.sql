@@ -0,0 +1,99 @@
+CREATE TRIGGER IF NOT EXISTS "CollectionAfterInsert"
+    AFTER INSERT
+    ON "Collection"
+    FOR EACH ROW
+BEGIN
+    UPDATE "CollectionTimelineSeq" SET "lastTimelineId" = ("lastTimelineId" + 1) WHERE "userId" = new."userId";
+    UPDATE "CollectionHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = new."userId";
+    UPDATE "Collection"
+    SET "timelineId" = (SELECT "lastTimelineId" FROM "CollectionTimelineSeq" WHERE "userId" = new."userId"),
+        "historyId"  = (SELECT "lastHistoryId" FROM "CollectionHistorySeq" WHERE "userId" = new."userId"),
+        "lastStmt"   = 0
+    WHERE "id" = new."id";
+END;
+
+CREATE TRIGGER IF NOT EXISTS "CollectionBeforeUpdate"
+    BEFORE UPDATE OF
+        "id",
+        "userId"
+    ON "Collection"
+    FOR EACH ROW
+BEGIN
+    SELECT RAISE(ABORT, 'Update not allowed');
+END;
+
+CREATE TRIGGER IF NOT EXISTS "CollectionAfterUpdate"
+    AFTER UPDATE OF
+        "name",
+        "parentId"
+    ON "Collection"
+    FOR EACH ROW
+BEGIN
+    UPDATE "CollectionTimelineSeq" SET "lastTimelineId" = ("lastTimelineId" + 1) WHERE "userId" = old."userId";
+    UPDATE "CollectionHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = old."userId";
+    UPDATE "Collection"
+    SET "timelineId" = (SELECT "lastTimelineId" FROM "CollectionTimelineSeq" WHERE "userId" = old."userId"),
+        "historyId"  = (SELECT "lastHistoryId" FROM "CollectionHistorySeq" WHERE "userId" = old."userId"),
+        "lastStmt"   = 1,
+        "modifiedAt" = CURRENT_TIMESTAMP
+    WHERE "id" = old."id";
+END;
+
+CREATE TRIGGER IF NOT EXISTS "CollectionAfterDelete"
+AFTER DELETE
+ON "Collection"
+FOR EACH ROW
+BEGIN
+    UPDATE "CollectionHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = old."userId";
+    INSERT INTO "CollectionDeleted" ("id", "userId", "historyId")
+      VALUES (old."id",
+              old."userId",
+              (SELECT "lastHistoryId" FROM "CollectionHistorySeq" WHERE "userId" = old."userId"));
+END;
+
+-- Blobs, the collection is updated unless it is being deleted, a move updates both the collections. The
+-- "deviceId" of the collection is not prefixed, the collections can't be trashed.
+CREATE TRIGGER IF NOT EXISTS "CollectionBlobAfterInsert"
+    AFTER INSERT
+    ON "CollectionBlob"
+    FOR EACH ROW
+BEGIN
+    UPDATE "CollectionHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = new."userId";
+    UPDATE "Collection"
+    SET "historyId"  = (SELECT "lastHistoryId" FROM "CollectionHistorySeq" WHERE "userId" = new."userId"),
+        "lastStmt"   = 1,
+        "modifiedAt" = CURRENT_TIMESTAMP,
+        "deviceId"   = NULL -- set by the caller
+    WHERE "id" = new."collectionId";
+END;
+
+CREATE TRIGGER IF NOT EXISTS "CollectionBlobAfterUpdate"
+    AFTER UPDATE OF
+        "collectionId"
+    ON "CollectionBlob"
+    FOR EACH ROW
+    WHEN new."collectionId" <> old."collectionId"
+BEGIN
+    UPDATE "CollectionHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = old."userId";
+    UPDATE "Collection"
+    SET "historyId"  = (SELECT "lastHistoryId" FROM "CollectionHistorySeq" WHERE "userId" = old."userId"),
+        "lastStmt"   = 1,
+        "modifiedAt" = CURRENT_TIMESTAMP,
+        "deviceId"   = NULL -- set by the caller
+    WHERE "id" IN (old."collectionId", new."collectionId");
+END;
+
+CREATE TRIGGER IF NOT EXISTS "CollectionBlobAfterDelete"
+    AFTER DELETE
+    ON "CollectionBlob"
+    FOR EACH ROW
+    WHEN EXISTS (SELECT 1 FROM "Collection" WHERE "id" = old."collectionId")
+BEGIN
+    UPDATE "CollectionHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = old."userId";
+    UPDATE "Collection"
+    SET "historyId"  = (SELECT "lastHistoryId" FROM "CollectionHistorySeq" WHERE "userId" = old."userId"),
+        "lastStmt"   = 1,
+        "modifiedAt" = CURRENT_TIMESTAMP,
+        "deviceId"   = NULL -- set by the caller
+    WHERE "id" = old."collectionId";
+END;
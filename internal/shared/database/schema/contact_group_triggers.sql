@@ -0,0 +1,82 @@
+CREATE TRIGGER IF NOT EXISTS "ContactGroupAfterInsert"
+    AFTER INSERT
+    ON "ContactGroup"
+    FOR EACH ROW
+BEGIN
+    UPDATE "ContactGroupTimelineSeq" SET "lastTimelineId" = ("lastTimelineId" + 1) WHERE "userId" = new."userId";
+    UPDATE "ContactGroupHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = new."userId";
+    UPDATE "ContactGroup"
+    SET "timelineId" = (SELECT "lastTimelineId" FROM "ContactGroupTimelineSeq" WHERE "userId" = new."userId"),
+        "historyId"  = (SELECT "lastHistoryId" FROM "ContactGroupHistorySeq" WHERE "userId" = new."userId"),
+        "lastStmt"   = 0
+    WHERE "id" = new."id";
+END;
+
+CREATE TRIGGER IF NOT EXISTS "ContactGroupBeforeUpdate"
+    BEFORE UPDATE OF
+        "id",
+        "userId"
+    ON "ContactGroup"
+    FOR EACH ROW
+BEGIN
+    SELECT RAISE(ABORT, 'Update not allowed');
+END;
+
+CREATE TRIGGER IF NOT EXISTS "ContactGroupAfterUpdate"
+    AFTER UPDATE OF
+        "name"
+    ON "ContactGroup"
+    FOR EACH ROW
+BEGIN
+    UPDATE "ContactGroupTimelineSeq" SET "lastTimelineId" = ("lastTimelineId" + 1) WHERE "userId" = old."userId";
+    UPDATE "ContactGroupHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = old."userId";
+    UPDATE "ContactGroup"
+    SET "timelineId" = (SELECT "lastTimelineId" FROM "ContactGroupTimelineSeq" WHERE "userId" = old."userId"),
+        "historyId"  = (SELECT "lastHistoryId" FROM "ContactGroupHistorySeq" WHERE "userId" = old."userId"),
+        "lastStmt"   = 1,
+        "modifiedAt" = CURRENT_TIMESTAMP
+    WHERE "id" = old."id";
+END;
+
+CREATE TRIGGER IF NOT EXISTS "ContactGroupAfterDelete"
+AFTER DELETE
+ON "ContactGroup"
+FOR EACH ROW
+BEGIN
+    UPDATE "ContactGroupHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = old."userId";
+    INSERT INTO "ContactGroupDeleted" ("id", "userId", "historyId")
+      VALUES (old."id",
+              old."userId",
+              (SELECT "lastHistoryId" FROM "ContactGroupHistorySeq" WHERE "userId" = old."userId"));
+END;
+
+-- Members, the group is updated unless it is being deleted. The "deviceId" of the group is not
+-- prefixed, the contact groups can't be trashed.
+CREATE TRIGGER IF NOT EXISTS "ContactGroupMemberAfterInsert"
+    AFTER INSERT
+    ON "ContactGroupMember"
+    FOR EACH ROW
+BEGIN
+    UPDATE "ContactGroupHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = new."userId";
+    UPDATE "ContactGroup"
+    SET "historyId"  = (SELECT "lastHistoryId" FROM "ContactGroupHistorySeq" WHERE "userId" = new."userId"),
+        "lastStmt"   = 1,
+        "modifiedAt" = CURRENT_TIMESTAMP,
+        "deviceId"   = NULL -- set by the caller
+    WHERE "id" = new."groupId";
+END;
+
+CREATE TRIGGER IF NOT EXISTS "ContactGroupMemberAfterDelete"
+    AFTER DELETE
+    ON "ContactGroupMember"
+    FOR EACH ROW
+    WHEN EXISTS (SELECT 1 FROM "ContactGroup" WHERE "id" = old."groupId")
+BEGIN
+    UPDATE "ContactGroupHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = old."userId";
+    UPDATE "ContactGroup"
+    SET "historyId"  = (SELECT "lastHistoryId" FROM "ContactGroupHistorySeq" WHERE "userId" = old."userId"),
+        "lastStmt"   = 1,
+        "modifiedAt" = CURRENT_TIMESTAMP,
+        "deviceId"   = NULL -- set by the caller
+    WHERE "id" = old."groupId";
+END;
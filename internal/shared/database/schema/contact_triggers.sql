@@ -26,7 +26,10 @@ CREATE TRIGGER IF NOT EXISTS "ContactAfterUpdate"
     AFTER UPDATE OF
         "emailAddress",
         "firstName",
-        "lastName"
+        "lastName",
+        "emailAddresses",
+        "phoneNumbers",
+        "notes"
     ON "Contact"
     FOR EACH ROW
 BEGIN
@@ -66,6 +69,7 @@ BEGIN
     UPDATE "ContactHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = old."userId";
     UPDATE "Contact"
     SET "historyId"  = (SELECT "lastHistoryId" FROM "ContactHistorySeq" WHERE "userId" = old."userId"),
+        "modifiedAt" = CURRENT_TIMESTAMP, -- the trash retention starts here
         "deviceId" = iif(length(new."deviceId") = 39 AND substr(new."deviceId", 1, 7) = 'device:', substr(new."deviceId", 8, 32), NULL) 
     WHERE "id" = old."id";
 END;
@@ -80,4 +84,47 @@ BEGIN
       VALUES (old."id",
               old."userId",
               (SELECT "lastHistoryId" FROM "ContactHistorySeq" WHERE "userId" = old."userId"));
-END;
\ No newline at end of file
+END;
+
+-- Search index
+CREATE TRIGGER IF NOT EXISTS "ContactSearchAfterInsert"
+    AFTER INSERT
+    ON "Contact"
+    FOR EACH ROW
+BEGIN
+    INSERT INTO "ContactSearch" ("docid", "id", "userId", "text")
+      VALUES (new."rowid",
+              new."id",
+              new."userId",
+              coalesce(new."emailAddress", '') || ' ' || coalesce(new."firstName", '') || ' ' || coalesce(new."lastName", '') || ' ' ||
+              coalesce((SELECT group_concat("value"->>'address', ' ') FROM json_each(new."emailAddresses")), '') || ' ' ||
+              coalesce(new."notes", ''));
+END;
+
+CREATE TRIGGER IF NOT EXISTS "ContactSearchAfterUpdate"
+    AFTER UPDATE OF
+        "emailAddress",
+        "firstName",
+        "lastName",
+        "emailAddresses",
+        "notes"
+    ON "Contact"
+    FOR EACH ROW
+BEGIN
+    DELETE FROM "ContactSearch" WHERE "docid" = old."rowid";
+    INSERT INTO "ContactSearch" ("docid", "id", "userId", "text")
+      VALUES (new."rowid",
+              new."id",
+              new."userId",
+              coalesce(new."emailAddress", '') || ' ' || coalesce(new."firstName", '') || ' ' || coalesce(new."lastName", '') || ' ' ||
+              coalesce((SELECT group_concat("value"->>'address', ' ') FROM json_each(new."emailAddresses")), '') || ' ' ||
+              coalesce(new."notes", ''));
+END;
+
+CREATE TRIGGER IF NOT EXISTS "ContactSearchAfterDelete"
+AFTER DELETE
+ON "Contact"
+FOR EACH ROW
+BEGIN
+    DELETE FROM "ContactSearch" WHERE "docid" = old."rowid";
+END;
@@ -28,7 +28,9 @@ END;
 
 CREATE TRIGGER IF NOT EXISTS "DraftAfterUpdate"
     AFTER UPDATE OF
-        "payload"
+        "payload",
+        "unread",
+        "starred"
     ON "Draft"
     FOR EACH ROW
 BEGIN
@@ -68,6 +70,7 @@ BEGIN
     UPDATE "DraftHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = old."userId";
     UPDATE "Draft"
     SET "historyId"  = (SELECT "lastHistoryId" FROM "DraftHistorySeq" WHERE "userId" = old."userId"),
+        "modifiedAt" = CURRENT_TIMESTAMP, -- the trash retention starts here
         "deviceId" = iif(length(new."deviceId") = 39 AND substr(new."deviceId", 1, 7) = 'device:', substr(new."deviceId", 8, 32), NULL) 
     WHERE "id" = old."id";
 END;
@@ -82,4 +85,42 @@ BEGIN
       VALUES (old."id",
               old."userId",
               (SELECT "lastHistoryId" FROM "DraftHistorySeq" WHERE "userId" = old."userId"));
+END;
+
+-- Search index (subject, recipients and the body text, the body parts are stored as blobs)
+CREATE TRIGGER IF NOT EXISTS "DraftSearchAfterInsert"
+    AFTER INSERT
+    ON "Draft"
+    FOR EACH ROW
+BEGIN
+    INSERT INTO "DraftSearch" ("docid", "id", "userId", "text")
+      VALUES (new."rowid",
+              new."id",
+              new."userId",
+              coalesce(new."payload"->>'$.headers.Subject', '') || ' ' || coalesce(new."payload"->>'$.headers.To', '') || ' ' ||
+              coalesce(new."payload"->>'$.headers.Cc', '') || ' ' || coalesce(new."searchText", ''));
+END;
+
+CREATE TRIGGER IF NOT EXISTS "DraftSearchAfterUpdate"
+    AFTER UPDATE OF
+        "payload",
+        "searchText"
+    ON "Draft"
+    FOR EACH ROW
+BEGIN
+    DELETE FROM "DraftSearch" WHERE "docid" = old."rowid";
+    INSERT INTO "DraftSearch" ("docid", "id", "userId", "text")
+      VALUES (new."rowid",
+              new."id",
+              new."userId",
+              coalesce(new."payload"->>'$.headers.Subject', '') || ' ' || coalesce(new."payload"->>'$.headers.To', '') || ' ' ||
+              coalesce(new."payload"->>'$.headers.Cc', '') || ' ' || coalesce(new."searchText", ''));
+END;
+
+CREATE TRIGGER IF NOT EXISTS "DraftSearchAfterDelete"
+AFTER DELETE
+ON "Draft"
+FOR EACH ROW
+BEGIN
+    DELETE FROM "DraftSearch" WHERE "docid" = old."rowid";
 END;
\ No newline at end of file
@@ -0,0 +1,228 @@
+-- The change notifications are written to the "Event" outbox in the transaction of the change itself,
+-- i.e. every history change of a row and every tombstone, so no change is lost when the process stops
+-- before the notification is dispatched.
+
+-- Blob
+CREATE TRIGGER IF NOT EXISTS "BlobEventAfterHistory"
+    AFTER UPDATE OF
+        "historyId"
+    ON "Blob"
+    FOR EACH ROW
+    WHEN new."historyId" <> old."historyId"
+BEGIN
+    INSERT INTO "Event" ("userId", "resource", "resourceId", "historyId", "type")
+      VALUES (new."userId",
+              'blobs',
+              new."id",
+              new."historyId",
+              CASE new."lastStmt" WHEN 2 THEN 'trashed' WHEN 1 THEN 'updated' ELSE 'inserted' END);
+END;
+
+CREATE TRIGGER IF NOT EXISTS "BlobEventAfterDelete"
+    AFTER INSERT
+    ON "BlobDeleted"
+    FOR EACH ROW
+BEGIN
+    INSERT INTO "Event" ("userId", "resource", "resourceId", "historyId", "type")
+      VALUES (new."userId", 'blobs', new."id", new."historyId", 'deleted');
+END;
+
+-- File
+CREATE TRIGGER IF NOT EXISTS "FileEventAfterHistory"
+    AFTER UPDATE OF
+        "historyId"
+    ON "File"
+    FOR EACH ROW
+    WHEN new."historyId" <> old."historyId"
+BEGIN
+    INSERT INTO "Event" ("userId", "resource", "resourceId", "historyId", "type")
+      VALUES (new."userId",
+              'files',
+              new."id",
+              new."historyId",
+              CASE new."lastStmt" WHEN 2 THEN 'trashed' WHEN 1 THEN 'updated' ELSE 'inserted' END);
+END;
+
+CREATE TRIGGER IF NOT EXISTS "FileEventAfterDelete"
+    AFTER INSERT
+    ON "FileDeleted"
+    FOR EACH ROW
+BEGIN
+    INSERT INTO "Event" ("userId", "resource", "resourceId", "historyId", "type")
+      VALUES (new."userId", 'files', new."id", new."historyId", 'deleted');
+END;
+
+-- Draft
+CREATE TRIGGER IF NOT EXISTS "DraftEventAfterHistory"
+    AFTER UPDATE OF
+        "historyId"
+    ON "Draft"
+    FOR EACH ROW
+    WHEN new."historyId" <> old."historyId"
+BEGIN
+    INSERT INTO "Event" ("userId", "resource", "resourceId", "historyId", "type")
+      VALUES (new."userId",
+              'drafts',
+              new."id",
+              new."historyId",
+              CASE new."lastStmt" WHEN 2 THEN 'trashed' WHEN 1 THEN 'updated' ELSE 'inserted' END);
+END;
+
+CREATE TRIGGER IF NOT EXISTS "DraftEventAfterDelete"
+    AFTER INSERT
+    ON "DraftDeleted"
+    FOR EACH ROW
+BEGIN
+    INSERT INTO "Event" ("userId", "resource", "resourceId", "historyId", "type")
+      VALUES (new."userId", 'drafts', new."id", new."historyId", 'deleted');
+END;
+
+-- Message
+CREATE TRIGGER IF NOT EXISTS "MessageEventAfterHistory"
+    AFTER UPDATE OF
+        "historyId"
+    ON "Message"
+    FOR EACH ROW
+    WHEN new."historyId" <> old."historyId"
+BEGIN
+    INSERT INTO "Event" ("userId", "resource", "resourceId", "historyId", "type")
+      VALUES (new."userId",
+              'messages',
+              new."id",
+              new."historyId",
+              CASE new."lastStmt" WHEN 2 THEN 'trashed' WHEN 1 THEN 'updated' ELSE 'inserted' END);
+END;
+
+CREATE TRIGGER IF NOT EXISTS "MessageEventAfterDelete"
+    AFTER INSERT
+    ON "MessageDeleted"
+    FOR EACH ROW
+BEGIN
+    INSERT INTO "Event" ("userId", "resource", "resourceId", "historyId", "type")
+      VALUES (new."userId", 'messages', new."id", new."historyId", 'deleted');
+END;
+
+-- Label
+CREATE TRIGGER IF NOT EXISTS "LabelEventAfterHistory"
+    AFTER UPDATE OF
+        "historyId"
+    ON "Label"
+    FOR EACH ROW
+    WHEN new."historyId" <> old."historyId"
+BEGIN
+    INSERT INTO "Event" ("userId", "resource", "resourceId", "historyId", "type")
+      VALUES (new."userId",
+              'labels',
+              new."id",
+              new."historyId",
+              CASE new."lastStmt" WHEN 2 THEN 'trashed' WHEN 1 THEN 'updated' ELSE 'inserted' END);
+END;
+
+CREATE TRIGGER IF NOT EXISTS "LabelEventAfterDelete"
+    AFTER INSERT
+    ON "LabelDeleted"
+    FOR EACH ROW
+BEGIN
+    INSERT INTO "Event" ("userId", "resource", "resourceId", "historyId", "type")
+      VALUES (new."userId", 'labels', new."id", new."historyId", 'deleted');
+END;
+
+-- Contact
+CREATE TRIGGER IF NOT EXISTS "ContactEventAfterHistory"
+    AFTER UPDATE OF
+        "historyId"
+    ON "Contact"
+    FOR EACH ROW
+    WHEN new."historyId" <> old."historyId"
+BEGIN
+    INSERT INTO "Event" ("userId", "resource", "resourceId", "historyId", "type")
+      VALUES (new."userId",
+              'contacts',
+              new."id",
+              new."historyId",
+              CASE new."lastStmt" WHEN 2 THEN 'trashed' WHEN 1 THEN 'updated' ELSE 'inserted' END);
+END;
+
+CREATE TRIGGER IF NOT EXISTS "ContactEventAfterDelete"
+    AFTER INSERT
+    ON "ContactDeleted"
+    FOR EACH ROW
+BEGIN
+    INSERT INTO "Event" ("userId", "resource", "resourceId", "historyId", "type")
+      VALUES (new."userId", 'contacts', new."id", new."historyId", 'deleted');
+END;
+
+-- ContactGroup
+CREATE TRIGGER IF NOT EXISTS "ContactGroupEventAfterHistory"
+    AFTER UPDATE OF
+        "historyId"
+    ON "ContactGroup"
+    FOR EACH ROW
+    WHEN new."historyId" <> old."historyId"
+BEGIN
+    INSERT INTO "Event" ("userId", "resource", "resourceId", "historyId", "type")
+      VALUES (new."userId",
+              'contactGroups',
+              new."id",
+              new."historyId",
+              CASE new."lastStmt" WHEN 1 THEN 'updated' ELSE 'inserted' END);
+END;
+
+CREATE TRIGGER IF NOT EXISTS "ContactGroupEventAfterDelete"
+    AFTER INSERT
+    ON "ContactGroupDeleted"
+    FOR EACH ROW
+BEGIN
+    INSERT INTO "Event" ("userId", "resource", "resourceId", "historyId", "type")
+      VALUES (new."userId", 'contactGroups', new."id", new."historyId", 'deleted');
+END;
+
+-- SavedSearch
+CREATE TRIGGER IF NOT EXISTS "SavedSearchEventAfterHistory"
+    AFTER UPDATE OF
+        "historyId"
+    ON "SavedSearch"
+    FOR EACH ROW
+    WHEN new."historyId" <> old."historyId"
+BEGIN
+    INSERT INTO "Event" ("userId", "resource", "resourceId", "historyId", "type")
+      VALUES (new."userId",
+              'savedSearches',
+              new."id",
+              new."historyId",
+              CASE new."lastStmt" WHEN 1 THEN 'updated' ELSE 'inserted' END);
+END;
+
+CREATE TRIGGER IF NOT EXISTS "SavedSearchEventAfterDelete"
+    AFTER INSERT
+    ON "SavedSearchDeleted"
+    FOR EACH ROW
+BEGIN
+    INSERT INTO "Event" ("userId", "resource", "resourceId", "historyId", "type")
+      VALUES (new."userId", 'savedSearches', new."id", new."historyId", 'deleted');
+END;
+
+-- Collection
+CREATE TRIGGER IF NOT EXISTS "CollectionEventAfterHistory"
+    AFTER UPDATE OF
+        "historyId"
+    ON "Collection"
+    FOR EACH ROW
+    WHEN new."historyId" <> old."historyId"
+BEGIN
+    INSERT INTO "Event" ("userId", "resource", "resourceId", "historyId", "type")
+      VALUES (new."userId",
+              'collections',
+              new."id",
+              new."historyId",
+              CASE new."lastStmt" WHEN 1 THEN 'updated' ELSE 'inserted' END);
+END;
+
+CREATE TRIGGER IF NOT EXISTS "CollectionEventAfterDelete"
+    AFTER INSERT
+    ON "CollectionDeleted"
+    FOR EACH ROW
+BEGIN
+    INSERT INTO "Event" ("userId", "resource", "resourceId", "historyId", "type")
+      VALUES (new."userId", 'collections', new."id", new."historyId", 'deleted');
+END;
@@ -18,7 +18,7 @@ CREATE TRIGGER IF NOT EXISTS "FileBeforeUpdate"
         "userId",
         "folder",
         "digest",
-        "name",
+        -- "name",
         "path",
         "size",
         "metadata",
@@ -29,6 +29,22 @@ BEGIN
     SELECT RAISE(ABORT, 'Update not allowed');
 END;
 
+CREATE TRIGGER IF NOT EXISTS "FileAfterUpdate"
+    AFTER UPDATE OF
+        "name"
+    ON "File"
+    FOR EACH ROW
+BEGIN
+    UPDATE "FileTimelineSeq" SET "lastTimelineId" = ("lastTimelineId" + 1) WHERE "userId" = old."userId";
+    UPDATE "FileHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = old."userId";
+    UPDATE "File"
+    SET "timelineId" = (SELECT "lastTimelineId" FROM "FileTimelineSeq" WHERE "userId" = old."userId"),
+        "historyId"  = (SELECT "lastHistoryId" FROM "FileHistorySeq" WHERE "userId" = old."userId"),
+        "lastStmt"   = 1,
+        "modifiedAt" = CURRENT_TIMESTAMP
+    WHERE "id" = old."id";
+END;
+
 -- Trashed
 CREATE TRIGGER IF NOT EXISTS "FileBeforeTrash"
     BEFORE UPDATE OF
@@ -37,7 +53,8 @@ CREATE TRIGGER IF NOT EXISTS "FileBeforeTrash"
     FOR EACH ROW
 BEGIN
     SELECT RAISE(ABORT, 'Update "lastStmt" not allowed')
-    WHERE NOT (new."lastStmt" == 0 OR new."lastStmt" == 2); -- Untrash = trashed (2) -> inserted (0)
+    WHERE NOT (new."lastStmt" == 0 OR new."lastStmt" == 1 OR new."lastStmt" == 2)
+        OR (old."lastStmt" = 2 AND new."lastStmt" = 1); -- Untrash = trashed (2) -> inserted (0)
   	UPDATE "File" 
 	SET "deviceId" = iif(length(new."deviceId") = 39 AND substr(new."deviceId", 1, 7) = 'device:', substr(new."deviceId", 8, 32), NULL)
 	WHERE "id" = new."id";
@@ -68,4 +85,52 @@ BEGIN
       VALUES (old."id",
               old."userId",
               (SELECT "lastHistoryId" FROM "FileHistorySeq" WHERE "userId" = old."userId"));
-END;
\ No newline at end of file
+END;
+
+CREATE TRIGGER IF NOT EXISTS "FileAfterInsertContent"
+AFTER INSERT
+ON "File"
+FOR EACH ROW
+BEGIN
+    UPDATE "BlobContent" SET "refcount" = ("refcount" + 1) WHERE "resource" = 'files' AND "digest" = new."digest";
+END;
+
+CREATE TRIGGER IF NOT EXISTS "FileAfterDeleteContent"
+AFTER DELETE
+ON "File"
+FOR EACH ROW
+BEGIN
+    UPDATE "BlobContent" SET "refcount" = ("refcount" - 1) WHERE "resource" = 'files' AND "digest" = old."digest";
+END;
+
+-- Tags, the file is updated unless it is being deleted, the trashed files can't be tagged. The "deviceId"
+-- of the file is set by the caller.
+CREATE TRIGGER IF NOT EXISTS "FileTagAfterInsert"
+    AFTER INSERT
+    ON "FileTag"
+    FOR EACH ROW
+BEGIN
+    UPDATE "FileHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = new."userId";
+    UPDATE "File"
+    SET "historyId"  = (SELECT "lastHistoryId" FROM "FileHistorySeq" WHERE "userId" = new."userId"),
+        "lastStmt"   = 1,
+        "modifiedAt" = CURRENT_TIMESTAMP,
+        "deviceId"   = NULL
+    WHERE "id" = new."fileId" AND
+        "lastStmt" <> 2;
+END;
+
+CREATE TRIGGER IF NOT EXISTS "FileTagAfterDelete"
+    AFTER DELETE
+    ON "FileTag"
+    FOR EACH ROW
+    WHEN EXISTS (SELECT 1 FROM "File" WHERE "id" = old."fileId" AND "lastStmt" <> 2)
+BEGIN
+    UPDATE "FileHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = old."userId";
+    UPDATE "File"
+    SET "historyId"  = (SELECT "lastHistoryId" FROM "FileHistorySeq" WHERE "userId" = old."userId"),
+        "lastStmt"   = 1,
+        "modifiedAt" = CURRENT_TIMESTAMP,
+        "deviceId"   = NULL
+    WHERE "id" = old."fileId";
+END;
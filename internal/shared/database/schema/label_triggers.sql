@@ -15,7 +15,8 @@ END;
 CREATE TRIGGER IF NOT EXISTS "LabelBeforeUpdate"
     BEFORE UPDATE OF
         "id",
-        "userId"
+        "userId",
+        "system"
     ON "Label"
     FOR EACH ROW
 BEGIN
@@ -24,7 +25,8 @@ END;
 
 CREATE TRIGGER IF NOT EXISTS "LabelAfterUpdate"
     AFTER UPDATE OF
-        name
+        "name",
+        "color"
     ON "Label"
     FOR EACH ROW
 BEGIN
@@ -78,4 +80,11 @@ BEGIN
       VALUES (old."id",
               old."userId",
               (SELECT "lastHistoryId" FROM "LabelHistorySeq" WHERE "userId" = old."userId"));
-END;
\ No newline at end of file
+END;
+
+-- the system labels of the users created before the labels were seeded, after the triggers to set their
+-- history, a label of the user of the same name is kept
+INSERT OR IGNORE INTO "Label" ("userId", "name", "system")
+    SELECT "User"."id", "SystemLabel"."name", TRUE
+        FROM "User", (SELECT 'Inbox' AS "name" UNION ALL SELECT 'Sent' UNION ALL SELECT 'Trash') AS "SystemLabel"
+        WHERE NOT EXISTS (SELECT 1 FROM "Label" WHERE "userId" = "User"."id" AND "system" AND "name" = "SystemLabel"."name");
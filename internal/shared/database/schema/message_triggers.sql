@@ -47,6 +47,23 @@ BEGIN
     WHERE "id" = old."id";
 END;
 
+-- Labels, the caller updates only the messages whose labels change
+CREATE TRIGGER IF NOT EXISTS "MessageAfterUpdateLabels"
+    AFTER UPDATE OF
+    "labelIds"
+    ON "Message"
+    FOR EACH ROW
+BEGIN
+    UPDATE "MessageTimelineSeq" SET "lastTimelineId" = ("lastTimelineId" + 1) WHERE "userId" = old."userId";
+    UPDATE "MessageHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = old."userId";
+    UPDATE "Message"
+    SET "timelineId" = (SELECT "lastTimelineId" FROM "MessageTimelineSeq" WHERE "userId" = old."userId"),
+        "historyId"  = (SELECT "lastHistoryId" FROM "MessageHistorySeq" WHERE "userId" = old."userId"),
+        "lastStmt"   = 1,
+        "modifiedAt" = CURRENT_TIMESTAMP
+    WHERE "id" = old."id";
+END;
+
 -- Trashed
 CREATE TRIGGER IF NOT EXISTS "MessageBeforeTrash"
     BEFORE UPDATE OF
@@ -87,4 +104,26 @@ BEGIN
       VALUES (old."id",
               old."userId",
               (SELECT "lastHistoryId" FROM "MessageHistorySeq" WHERE "userId" = old."userId"));
-END;
\ No newline at end of file
+END;
+
+-- Search index
+CREATE TRIGGER IF NOT EXISTS "MessageSearchAfterInsert"
+    AFTER INSERT
+    ON "Message"
+    FOR EACH ROW
+BEGIN
+    INSERT INTO "MessageSearch" ("docid", "id", "userId", "text")
+      VALUES (new."rowid",
+              new."id",
+              new."userId",
+              coalesce(new."payload"->>'$.headers.Subject', '') || ' ' || coalesce(new."payload"->>'$.headers.From', '') || ' ' ||
+              coalesce(new."payload"->>'$.headers.To', '') || ' ' || coalesce(new."payload"->>'$.headers.Cc', ''));
+END;
+
+CREATE TRIGGER IF NOT EXISTS "MessageSearchAfterDelete"
+AFTER DELETE
+ON "Message"
+FOR EACH ROW
+BEGIN
+    DELETE FROM "MessageSearch" WHERE "docid" = old."rowid";
+END;
@@ -0,0 +1,53 @@
+CREATE TRIGGER IF NOT EXISTS "SavedSearchAfterInsert"
+    AFTER INSERT
+    ON "SavedSearch"
+    FOR EACH ROW
+BEGIN
+    UPDATE "SavedSearchTimelineSeq" SET "lastTimelineId" = ("lastTimelineId" + 1) WHERE "userId" = new."userId";
+    UPDATE "SavedSearchHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = new."userId";
+    UPDATE "SavedSearch"
+    SET "timelineId" = (SELECT "lastTimelineId" FROM "SavedSearchTimelineSeq" WHERE "userId" = new."userId"),
+        "historyId"  = (SELECT "lastHistoryId" FROM "SavedSearchHistorySeq" WHERE "userId" = new."userId"),
+        "lastStmt"   = 0
+    WHERE "id" = new."id";
+END;
+
+CREATE TRIGGER IF NOT EXISTS "SavedSearchBeforeUpdate"
+    BEFORE UPDATE OF
+        "id",
+        "userId"
+    ON "SavedSearch"
+    FOR EACH ROW
+BEGIN
+    SELECT RAISE(ABORT, 'Update not allowed');
+END;
+
+CREATE TRIGGER IF NOT EXISTS "SavedSearchAfterUpdate"
+    AFTER UPDATE OF
+        "name",
+        "query",
+        "resources"
+    ON "SavedSearch"
+    FOR EACH ROW
+BEGIN
+    UPDATE "SavedSearchTimelineSeq" SET "lastTimelineId" = ("lastTimelineId" + 1) WHERE "userId" = old."userId";
+    UPDATE "SavedSearchHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = old."userId";
+    UPDATE "SavedSearch"
+    SET "timelineId" = (SELECT "lastTimelineId" FROM "SavedSearchTimelineSeq" WHERE "userId" = old."userId"),
+        "historyId"  = (SELECT "lastHistoryId" FROM "SavedSearchHistorySeq" WHERE "userId" = old."userId"),
+        "lastStmt"   = 1,
+        "modifiedAt" = CURRENT_TIMESTAMP
+    WHERE "id" = old."id";
+END;
+
+CREATE TRIGGER IF NOT EXISTS "SavedSearchAfterDelete"
+AFTER DELETE
+ON "SavedSearch"
+FOR EACH ROW
+BEGIN
+    UPDATE "SavedSearchHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = old."userId";
+    INSERT INTO "SavedSearchDeleted" ("id", "userId", "historyId")
+      VALUES (old."id",
+              old."userId",
+              (SELECT "lastHistoryId" FROM "SavedSearchHistorySeq" WHERE "userId" = old."userId"));
+END;
@@ -1,22 +1,65 @@
-PRAGMA foreign_keys=ON;
-
 ------------------------------tables-----------------------------
 
+-- the ids are never reused, so a row left by a deleted user is never taken for one of a new user
 CREATE TABLE IF NOT EXISTS "User" (
-    "id"			INTEGER PRIMARY KEY,
+    "id"			INTEGER PRIMARY KEY AUTOINCREMENT,
     "username"		TEXT NOT NULL UNIQUE,
     "passwordHash"	TEXT NOT NULL,
     "firstName"		TEXT DEFAULT "",
     "lastName"		TEXT DEFAULT "",
     "settings"      TEXT,                 -- json object
-    "createdAt"		TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+    "createdAt"		TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "quotaBytes"    INTEGER               -- NULL = the configured quota, 0 = unlimited
 );
 
 CREATE TABLE IF NOT EXISTS "Session" (
     "id" 			VARCHAR(32) NOT NULL DEFAULT (lower(hex(randomblob(16)))) PRIMARY KEY,
     "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
     "expiry" 		TIMESTAMP NOT NULL,
-    "scope" 		TEXT NOT NULL
+    "scope" 		TEXT NOT NULL,
+    "deviceId"      VARCHAR(32)  -- the device logged in with the session
+);
+
+CREATE TABLE IF NOT EXISTS "Device" (
+    "userId"        INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "id"            VARCHAR(32) NOT NULL,
+    "userAgent"     TEXT,
+    "resync"        BOOLEAN NOT NULL DEFAULT FALSE,  -- told to sync from scratch on the next login
+    "createdAt"     TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "lastSeenAt"    TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("userId", "id")
+);
+
+CREATE TABLE IF NOT EXISTS "RefreshToken" (
+    "id"            VARCHAR(32) NOT NULL DEFAULT (lower(hex(randomblob(16)))) PRIMARY KEY,
+    "userId"        INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "tokenHash"     VARCHAR(64) NOT NULL UNIQUE,
+    "deviceId"      VARCHAR(32) NOT NULL,
+    "expiresAt"     TIMESTAMP NOT NULL,
+    "revokedAt"     TIMESTAMP,  -- rotated or revoked, kept until expired to detect the reuse
+    "createdAt"     TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- not bound to the User, the failures of the unknown usernames are counted too
+CREATE TABLE IF NOT EXISTS "LoginAttempt" (
+    "username"      VARCHAR(40) NOT NULL,
+    "ip"            VARCHAR(45) NOT NULL,
+    "failures"      INTEGER NOT NULL DEFAULT 0,
+    "lockedUntil"   TIMESTAMP,
+    "expiresAt"     TIMESTAMP NOT NULL,  -- the failures are forgotten then
+    PRIMARY KEY ("username", "ip")
+);
+
+CREATE TABLE IF NOT EXISTS "ApiKey" (
+    "id" 			VARCHAR(32) NOT NULL DEFAULT (lower(hex(randomblob(16)))) PRIMARY KEY,
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "name" 		    TEXT NOT NULL,
+    "keyHash" 		VARCHAR(64) NOT NULL UNIQUE,
+    "scopes" 		TEXT,  -- json array, NULL = all scopes
+    "deviceId" 		VARCHAR(32) NOT NULL DEFAULT (lower(hex(randomblob(16)))),
+    "expiresAt" 	TIMESTAMP,
+    "lastUsedAt" 	TIMESTAMP,
+    "createdAt" 	TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
 
 CREATE TABLE IF NOT EXISTS "Blob" (
@@ -39,6 +82,45 @@ CREATE TABLE IF NOT EXISTS "Blob" (
     "deviceId"      VARCHAR(32)
 );
 
+-- the tags of the blobs, case-insensitive, the tag changes are synced as the updates of the blob
+CREATE TABLE IF NOT EXISTS "BlobTag" (
+    "blobId"		VARCHAR(32) NOT NULL REFERENCES "Blob" ON DELETE CASCADE,
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "tag"           VARCHAR(64) NOT NULL COLLATE NOCASE,
+    "createdAt"		TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("blobId", "tag")
+);
+
+CREATE TABLE IF NOT EXISTS "FileTag" (
+    "fileId"		VARCHAR(32) NOT NULL REFERENCES "File" ON DELETE CASCADE,
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "tag"           VARCHAR(64) NOT NULL COLLATE NOCASE,
+    "createdAt"		TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("fileId", "tag")
+);
+
+-- the folders of the blobs, the root collections have no parent
+CREATE TABLE IF NOT EXISTS "Collection" (
+    "id"			VARCHAR(32) NOT NULL DEFAULT (lower(hex(randomblob(16)))) PRIMARY KEY,
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "parentId"		VARCHAR(32) REFERENCES "Collection" ON DELETE CASCADE,
+    "name"          VARCHAR(255) NOT NULL,
+    "createdAt"		TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "modifiedAt"	TIMESTAMP,
+    "timelineId"	INTEGER(8) NOT NULL DEFAULT 0,
+    "historyId" 	INTEGER(8) NOT NULL DEFAULT 0,
+    "lastStmt"  	INTEGER(2) NOT NULL DEFAULT 0, -- 0-inserted, 1-updated
+    "deviceId"      VARCHAR(32)
+);
+
+-- a blob is in one collection at most, the moves are synced as the updates of the collections
+CREATE TABLE IF NOT EXISTS "CollectionBlob" (
+    "blobId"		VARCHAR(32) NOT NULL PRIMARY KEY REFERENCES "Blob" ON DELETE CASCADE,
+    "collectionId"	VARCHAR(32) NOT NULL REFERENCES "Collection" ON DELETE CASCADE,
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "createdAt"		TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
 CREATE TABLE IF NOT EXISTS "File" (
     "id"			VARCHAR(32) NOT NULL DEFAULT (lower(hex(randomblob(16)))) PRIMARY KEY,
     "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
@@ -57,6 +139,42 @@ CREATE TABLE IF NOT EXISTS "File" (
     "deviceId"      VARCHAR(32)
 );
 
+CREATE TABLE IF NOT EXISTS "BlobContent" (
+    "resource"      VARCHAR(8) NOT NULL,  -- blobs, files
+    "hash"          VARCHAR(64) NOT NULL, -- sha256 of the plain content
+    "digest"     	VARCHAR(64) NOT NULL, -- the stored bytes
+    "metadata"      TEXT,                 -- json object
+    "refcount"      INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY ("resource", "hash")
+);
+
+-- the resumable uploads in progress, the chunks are spooled until the upload is finalized
+CREATE TABLE IF NOT EXISTS "Upload" (
+    "id"            VARCHAR(32) NOT NULL DEFAULT (lower(hex(randomblob(16)))) PRIMARY KEY,
+    "userId"        INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "resource"      VARCHAR(8) NOT NULL,  -- blobs, files
+    "name"          TEXT NOT NULL,
+    "contentType"   TEXT NOT NULL,
+    "size"          INTEGER NOT NULL,
+    "offset"        INTEGER NOT NULL DEFAULT 0,
+    "hash"          VARCHAR(64),          -- sha256 of the plain content, checked on finalize
+    "metadata"      TEXT,                 -- json object, the key of the spooled chunks
+    "createdAt"     TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "modifiedAt"    TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS "IdempotencyKey" (
+    "userId"        INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "key"           VARCHAR(255) NOT NULL,
+    "method"        VARCHAR(8) NOT NULL,
+    "path"          TEXT NOT NULL,
+    "status"        INTEGER NOT NULL DEFAULT 0,  -- 0 while the first request is in flight
+    "contentType"   TEXT,
+    "response"      BLOB,
+    "createdAt"     TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("userId", "key")
+);
+
 CREATE TABLE IF NOT EXISTS "Draft"
 (
     "id"           VARCHAR(32) NOT NULL DEFAULT (lower(hex(randomblob(16)))) PRIMARY KEY,
@@ -70,7 +188,31 @@ CREATE TABLE IF NOT EXISTS "Draft"
     "timelineId"    INTEGER(8) NOT NULL DEFAULT 0,
     "historyId"     INTEGER(8) NOT NULL DEFAULT 0,
     "lastStmt"      INTEGER(2) NOT NULL DEFAULT 0, -- 0-inserted, 1-updated, 2-trashed
-    "deviceId"      VARCHAR(32)
+    "deviceId"      VARCHAR(32),
+    "searchText"    TEXT                  -- body text for the search index
+);
+
+-- the previous payloads of the draft, append-only
+CREATE TABLE IF NOT EXISTS "DraftVersion"
+(
+    "id"           VARCHAR(32) NOT NULL DEFAULT (lower(hex(randomblob(16)))) PRIMARY KEY,
+    "draftId"      VARCHAR(32) NOT NULL REFERENCES "Draft" ON DELETE CASCADE,
+    "userId" 	    INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "payload"       TEXT,                 -- json 'MessagePart' object
+    "searchText"    TEXT,
+    "createdAt"		TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- the blobs and files attached to the draft, by their content-addressed uri (digest)
+CREATE TABLE IF NOT EXISTS "DraftAttachment"
+(
+    "id"           VARCHAR(32) NOT NULL DEFAULT (lower(hex(randomblob(16)))) PRIMARY KEY,
+    "draftId"      VARCHAR(32) NOT NULL REFERENCES "Draft" ON DELETE CASCADE,
+    "userId" 	    INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "uri"           VARCHAR(64) NOT NULL,
+    "resource"      VARCHAR(8) NOT NULL,  -- blobs, files
+    "resourceId"    VARCHAR(32) NOT NULL,
+    "createdAt"		TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
 
 CREATE TABLE IF NOT EXISTS "Message"
@@ -97,6 +239,8 @@ CREATE TABLE IF NOT EXISTS "Label" (
     "id"			VARCHAR(32) NOT NULL DEFAULT (lower(hex(randomblob(16)))) PRIMARY KEY,
     "userId" 		INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
     "name"          VARCHAR(255) NOT NULL,
+    "color"         VARCHAR(7),           -- e.g. '#1a73e8'
+    "system"        BOOLEAN NOT NULL DEFAULT FALSE, -- the Inbox, the Sent and the Trash, seeded per user
     "createdAt"		TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
     "modifiedAt"	TIMESTAMP,
     "timelineId"	INTEGER(8) NOT NULL DEFAULT 0,
@@ -108,11 +252,9 @@ CREATE TABLE IF NOT EXISTS "Label" (
 CREATE TABLE IF NOT EXISTS "Contact" (
     "id"			VARCHAR(32) NOT NULL DEFAULT (lower(hex(randomblob(16)))) PRIMARY KEY,
     "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
-    "emailAddress"  VARCHAR(255) NOT NULL CHECK (
+    "emailAddress"  VARCHAR(255) CHECK (
         "emailAddress" LIKE '%_@_%._%' AND
-        LENGTH("emailAddress") - LENGTH(REPLACE("emailAddress", '@', '')) = 1 AND
-        SUBSTR(LOWER("emailAddress"), 1, INSTR("emailAddress", '.') - 1) NOT GLOB '*[^@0-9a-z]*' AND
-        SUBSTR(LOWER("emailAddress"), INSTR("emailAddress", '.') + 1) NOT GLOB '*[^a-z]*'),
+        LENGTH("emailAddress") - LENGTH(REPLACE("emailAddress", '@', '')) = 1), -- NULL = a contact of a name only
     "firstName"		VARCHAR(255),
     "lastName"		VARCHAR(255),
     "createdAt"		TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
@@ -120,9 +262,76 @@ CREATE TABLE IF NOT EXISTS "Contact" (
     "timelineId"	INTEGER(8) NOT NULL DEFAULT 0,
     "historyId" 	INTEGER(8) NOT NULL DEFAULT 0,
     "lastStmt"  	INTEGER(2) NOT NULL DEFAULT 0, -- 0-inserted, 1-updated, 2-trashed
+    "deviceId"      VARCHAR(32),
+    "emailAddresses" TEXT,                -- json 'ContactEmail' array, the primary one is the "emailAddress"
+    "phoneNumbers"  TEXT,                 -- json 'ContactPhone' array, E.164 numbers
+    "notes"         TEXT
+);
+
+CREATE TABLE IF NOT EXISTS "ContactGroup" (
+    "id"			VARCHAR(32) NOT NULL DEFAULT (lower(hex(randomblob(16)))) PRIMARY KEY,
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "name"          VARCHAR(255) NOT NULL,
+    "createdAt"		TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "modifiedAt"	TIMESTAMP,
+    "timelineId"	INTEGER(8) NOT NULL DEFAULT 0,
+    "historyId" 	INTEGER(8) NOT NULL DEFAULT 0,
+    "lastStmt"  	INTEGER(2) NOT NULL DEFAULT 0, -- 0-inserted, 1-updated
     "deviceId"      VARCHAR(32)
 );
 
+-- the membership changes are synced as the updates of the group
+CREATE TABLE IF NOT EXISTS "ContactGroupMember" (
+    "groupId"		VARCHAR(32) NOT NULL REFERENCES "ContactGroup" ON DELETE CASCADE,
+    "contactId"		VARCHAR(32) NOT NULL REFERENCES "Contact" ON DELETE CASCADE,
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "createdAt"		TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("groupId", "contactId")
+);
+
+-- the queries are the terms of the global search, they are never run as SQL
+CREATE TABLE IF NOT EXISTS "SavedSearch" (
+    "id"			VARCHAR(32) NOT NULL DEFAULT (lower(hex(randomblob(16)))) PRIMARY KEY,
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "name"          VARCHAR(255) NOT NULL,
+    "query"         VARCHAR(500) NOT NULL,
+    "resources"     TEXT,                 -- json array of the searched resources, NULL = all of them
+    "createdAt"		TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "modifiedAt"	TIMESTAMP,
+    "timelineId"	INTEGER(8) NOT NULL DEFAULT 0,
+    "historyId" 	INTEGER(8) NOT NULL DEFAULT 0,
+    "lastStmt"  	INTEGER(2) NOT NULL DEFAULT 0, -- 0-inserted, 1-updated
+    "deviceId"      VARCHAR(32)
+);
+
+-- full-text search indexes ("docid" mirrors the "rowid" of the source row)
+CREATE VIRTUAL TABLE IF NOT EXISTS "ContactSearch" USING fts4 (
+    "id",
+    "userId",
+    "text",
+    notindexed="id",
+    notindexed="userId",
+    tokenize=unicode61
+);
+
+CREATE VIRTUAL TABLE IF NOT EXISTS "MessageSearch" USING fts4 (
+    "id",
+    "userId",
+    "text",
+    notindexed="id",
+    notindexed="userId",
+    tokenize=unicode61
+);
+
+CREATE VIRTUAL TABLE IF NOT EXISTS "DraftSearch" USING fts4 (
+    "id",
+    "userId",
+    "text",
+    notindexed="id",
+    notindexed="userId",
+    tokenize=unicode61
+);
+
 -- push layer: sending a placeholder message from a sender to recipients
 CREATE TABLE IF NOT EXISTS "MessageQueue" (
     "id"            VARCHAR(32) NOT NULL DEFAULT (lower(hex(randomblob(16)))) PRIMARY KEY, 
@@ -185,6 +394,108 @@ CREATE TABLE IF NOT EXISTS "ContactDeleted" (
     "deviceId"      VARCHAR(32)
 );
 
+CREATE TABLE IF NOT EXISTS "ContactGroupDeleted" (
+    "id"			VARCHAR(32) NOT NULL PRIMARY KEY,
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "historyId" 	INTEGER(8) NOT NULL DEFAULT 0,
+    "deviceId"      VARCHAR(32)
+);
+
+CREATE TABLE IF NOT EXISTS "CollectionDeleted" (
+    "id"			VARCHAR(32) NOT NULL PRIMARY KEY,
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "historyId" 	INTEGER(8) NOT NULL DEFAULT 0,
+    "deviceId"      VARCHAR(32)
+);
+
+CREATE TABLE IF NOT EXISTS "SavedSearchDeleted" (
+    "id"			VARCHAR(32) NOT NULL PRIMARY KEY,
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "historyId" 	INTEGER(8) NOT NULL DEFAULT 0,
+    "deviceId"      VARCHAR(32)
+);
+
+CREATE TABLE IF NOT EXISTS "Event" (
+    "id"			INTEGER PRIMARY KEY AUTOINCREMENT,
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "resource"      VARCHAR(16) NOT NULL,  -- blobs, files, drafts, messages, labels, contacts, contactGroups
+    "resourceId"    VARCHAR(32) NOT NULL,
+    "historyId" 	INTEGER(8) NOT NULL,
+    "type"          VARCHAR(8) NOT NULL,   -- inserted, updated, trashed, deleted
+    "createdAt"		TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "sentAt"        TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS "Webhook" (
+    "id" 			VARCHAR(32) NOT NULL DEFAULT (lower(hex(randomblob(16)))) PRIMARY KEY,
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "url" 		    TEXT NOT NULL,
+    "secret" 		VARCHAR(64) NOT NULL,
+    "resources" 	TEXT,  -- json array, NULL = all resources
+    "createdAt" 	TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- the events the webhooks are yet to be notified of, the event is copied as the "Event" rows are purged
+CREATE TABLE IF NOT EXISTS "WebhookDelivery" (
+    "id"			INTEGER PRIMARY KEY AUTOINCREMENT,
+    "webhookId" 	VARCHAR(32) NOT NULL REFERENCES "Webhook" ON DELETE CASCADE,
+    "eventId" 		INTEGER NOT NULL,
+    "resource"      VARCHAR(16) NOT NULL,
+    "resourceId"    VARCHAR(32) NOT NULL,
+    "historyId" 	INTEGER(8) NOT NULL,
+    "type"          VARCHAR(8) NOT NULL,
+    "attempts" 		INTEGER NOT NULL DEFAULT 0,
+    "nextAttemptAt" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "lastError" 	TEXT,
+    "createdAt"		TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- the sent messages the peer instances are yet to be delivered, one row a peer domain
+CREATE TABLE IF NOT EXISTS "FederationDelivery" (
+    "id"			INTEGER PRIMARY KEY AUTOINCREMENT,
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "domain"        VARCHAR(255) NOT NULL,
+    "envelope"      TEXT NOT NULL,  -- json 'FederationEnvelope' object
+    "attempts" 		INTEGER NOT NULL DEFAULT 0,
+    "nextAttemptAt" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "lastError" 	TEXT,
+    "createdAt"		TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- the Ed25519 key the instance signs the federated messages with, a single row created on the first use
+CREATE TABLE IF NOT EXISTS "FederationInstanceKey" (
+    "id"			INTEGER NOT NULL PRIMARY KEY CHECK ("id" = 1),
+    "privateKey"    TEXT NOT NULL,  -- base64 seed
+    "createdAt"		TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- the public keys the messages of the peer instances are verified by, set by the operator
+CREATE TABLE IF NOT EXISTS "FederationPeerKey" (
+    "domain"        VARCHAR(255) NOT NULL PRIMARY KEY,
+    "publicKey"     TEXT NOT NULL,  -- base64
+    "createdAt"		TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "modifiedAt"    TIMESTAMP
+);
+
+-- the history ids of the resources at the points in time, so the age of a tombstone is told by its history id
+CREATE TABLE IF NOT EXISTS "HistoryMark" (
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "resource"      VARCHAR(16) NOT NULL,  -- blobs, files, drafts, messages, labels, contacts, contactGroups
+    "historyId" 	INTEGER(8) NOT NULL,
+    "markedAt"		TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS "AuditLog" (
+    "id"			INTEGER PRIMARY KEY AUTOINCREMENT,
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "deviceId"      VARCHAR(32),           -- the device of the change, NULL when unknown
+    "action"        VARCHAR(8) NOT NULL,   -- create, update, trash, untrash, delete
+    "resource"      VARCHAR(16) NOT NULL,  -- blobs, files, drafts, messages, labels, contacts, contactGroups
+    "resourceId"    VARCHAR(32) NOT NULL,
+    "historyId" 	INTEGER(8) NOT NULL,
+    "createdAt"		TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
 CREATE TABLE IF NOT EXISTS "BlobTimelineSeq" (
     "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
     "lastTimelineId" INTEGER(8) NOT NULL
@@ -245,21 +556,80 @@ CREATE TABLE IF NOT EXISTS "ContactHistorySeq" (
     "lastHistoryId" INTEGER(8) NOT NULL
 );
 
+CREATE TABLE IF NOT EXISTS "ContactGroupTimelineSeq" (
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "lastTimelineId" INTEGER(8) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS "ContactGroupHistorySeq" (
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "lastHistoryId" INTEGER(8) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS "CollectionTimelineSeq" (
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "lastTimelineId" INTEGER(8) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS "CollectionHistorySeq" (
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "lastHistoryId" INTEGER(8) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS "SavedSearchTimelineSeq" (
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "lastTimelineId" INTEGER(8) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS "SavedSearchHistorySeq" (
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "lastHistoryId" INTEGER(8) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS "SettingsHistorySeq" (
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "lastHistoryId" INTEGER(8) NOT NULL
+);
+
 ------------------------------indexes----------------------------
 
+CREATE INDEX IF NOT EXISTS "IdxApiKeyUserId" ON "ApiKey" ("userId");
+CREATE INDEX IF NOT EXISTS "IdxRefreshTokenUserId" ON "RefreshToken" ("userId", "deviceId");
+
+CREATE INDEX IF NOT EXISTS "IdxEventPending" ON "Event" ("id") WHERE "sentAt" IS NULL;
+CREATE INDEX IF NOT EXISTS "IdxWebhookUserId" ON "Webhook" ("userId");
+CREATE UNIQUE INDEX IF NOT EXISTS "IdxWebhookDeliveryEventId" ON "WebhookDelivery" ("webhookId", "eventId");
+CREATE INDEX IF NOT EXISTS "IdxWebhookDeliveryNextAttemptAt" ON "WebhookDelivery" ("nextAttemptAt");
+CREATE INDEX IF NOT EXISTS "IdxFederationDeliveryNextAttemptAt" ON "FederationDelivery" ("nextAttemptAt");
+CREATE INDEX IF NOT EXISTS "IdxAuditLogUserId" ON "AuditLog" ("userId", "createdAt");
+CREATE INDEX IF NOT EXISTS "IdxHistoryMarkUserId" ON "HistoryMark" ("userId", "resource", "markedAt");
+
 CREATE INDEX IF NOT EXISTS "IdxBlobDigest" ON "Blob" ("digest");
 CREATE INDEX IF NOT EXISTS "IdxBlobTimelineId" ON "Blob" ("timelineId");
 CREATE INDEX IF NOT EXISTS "IdxBlobHistoryId" ON "Blob" ("historyId");
 CREATE INDEX IF NOT EXISTS "IdxBlobLastStmt" ON "Blob" ("lastStmt");
+CREATE INDEX IF NOT EXISTS "IdxBlobTagTag" ON "BlobTag" ("userId", "tag");
+
+CREATE UNIQUE INDEX IF NOT EXISTS "IdxCollectionName" ON "Collection" ("userId", coalesce("parentId", ''), "name" COLLATE NOCASE);
+CREATE INDEX IF NOT EXISTS "IdxCollectionParentId" ON "Collection" ("parentId");
+CREATE INDEX IF NOT EXISTS "IdxCollectionHistoryId" ON "Collection" ("historyId");
+CREATE INDEX IF NOT EXISTS "IdxCollectionBlobCollectionId" ON "CollectionBlob" ("collectionId");
 
 CREATE INDEX IF NOT EXISTS "IdxFileDigest" ON "File" ("digest");
 CREATE INDEX IF NOT EXISTS "IdxFileTimelineId" ON "File" ("timelineId");
 CREATE INDEX IF NOT EXISTS "IdxFileHistoryId" ON "File" ("historyId");
 CREATE INDEX IF NOT EXISTS "IdxFileLastStmt" ON "File" ("lastStmt");
+CREATE INDEX IF NOT EXISTS "IdxFileTagTag" ON "FileTag" ("userId", "tag");
+
+CREATE INDEX IF NOT EXISTS "IdxBlobContentDigest" ON "BlobContent" ("resource", "digest");
+CREATE INDEX IF NOT EXISTS "IdxUploadUserId" ON "Upload" ("userId");
 
 CREATE INDEX IF NOT EXISTS "IdxDraftTimelineId" ON "Draft" ("timelineId");
 CREATE INDEX IF NOT EXISTS "IdxDraftHistoryId" ON "Draft" ("historyId");
 CREATE INDEX IF NOT EXISTS "IdxDraftLastStmt" ON "Draft" ("lastStmt");
+CREATE INDEX IF NOT EXISTS "IdxDraftVersionDraftId" ON "DraftVersion" ("draftId");
+CREATE UNIQUE INDEX IF NOT EXISTS "IdxDraftAttachment" ON "DraftAttachment" ("draftId", "uri");
+CREATE INDEX IF NOT EXISTS "IdxDraftAttachmentUri" ON "DraftAttachment" ("userId", "uri");
 
 CREATE INDEX IF NOT EXISTS "IdxMessageTimelineId" ON "Message" ("timelineId");
 CREATE INDEX IF NOT EXISTS "IdxMessageHistoryId" ON "Message" ("historyId");
@@ -275,6 +645,15 @@ CREATE INDEX IF NOT EXISTS "IdxContactTimelineId" ON "Contact" ("timelineId");
 CREATE INDEX IF NOT EXISTS "IdxContactHistoryId" ON "Contact" ("historyId");
 CREATE INDEX IF NOT EXISTS "IdxContactLastStmt" ON "Contact" ("lastStmt");
 
+CREATE UNIQUE INDEX IF NOT EXISTS "IdxContactGroupName" ON "ContactGroup" ("userId", "name");
+CREATE INDEX IF NOT EXISTS "IdxContactGroupTimelineId" ON "ContactGroup" ("timelineId");
+CREATE INDEX IF NOT EXISTS "IdxContactGroupHistoryId" ON "ContactGroup" ("historyId");
+CREATE INDEX IF NOT EXISTS "IdxContactGroupMemberContactId" ON "ContactGroupMember" ("contactId");
+
+CREATE UNIQUE INDEX IF NOT EXISTS "IdxSavedSearchName" ON "SavedSearch" ("userId", "name");
+CREATE INDEX IF NOT EXISTS "IdxSavedSearchTimelineId" ON "SavedSearch" ("timelineId");
+CREATE INDEX IF NOT EXISTS "IdxSavedSearchHistoryId" ON "SavedSearch" ("historyId");
+
 CREATE UNIQUE INDEX IF NOT EXISTS "IdxBlobTimelineSeq" ON "BlobTimelineSeq" ("userId");
 CREATE UNIQUE INDEX IF NOT EXISTS "IdxBlobHistorySeq" ON "BlobHistorySeq" ("userId");
 
@@ -291,4 +670,37 @@ CREATE UNIQUE INDEX IF NOT EXISTS "IdxLabelTimelineSeq" ON "LabelTimelineSeq" ("
 CREATE UNIQUE INDEX IF NOT EXISTS "idxLabelHistorySeq" ON "LabelHistorySeq" ("userId");
 
 CREATE UNIQUE INDEX IF NOT EXISTS "IdxContactTimelineSeq" ON "ContactTimelineSeq" ("userId");
-CREATE UNIQUE INDEX IF NOT EXISTS "IdxContactHistorySeq" ON "ContactHistorySeq" ("userId");
\ No newline at end of file
+CREATE UNIQUE INDEX IF NOT EXISTS "IdxContactHistorySeq" ON "ContactHistorySeq" ("userId");
+
+CREATE UNIQUE INDEX IF NOT EXISTS "IdxContactGroupTimelineSeq" ON "ContactGroupTimelineSeq" ("userId");
+CREATE UNIQUE INDEX IF NOT EXISTS "IdxContactGroupHistorySeq" ON "ContactGroupHistorySeq" ("userId");
+
+-- the sequences of the users created before the contact groups were introduced
+INSERT INTO "ContactGroupTimelineSeq" ("userId", "lastTimelineId")
+    SELECT "id", 0 FROM "User" WHERE "id" NOT IN (SELECT "userId" FROM "ContactGroupTimelineSeq");
+INSERT INTO "ContactGroupHistorySeq" ("userId", "lastHistoryId")
+    SELECT "id", 0 FROM "User" WHERE "id" NOT IN (SELECT "userId" FROM "ContactGroupHistorySeq");
+
+CREATE UNIQUE INDEX IF NOT EXISTS "IdxSettingsHistorySeq" ON "SettingsHistorySeq" ("userId");
+
+-- the sequences of the users created before the settings were introduced
+INSERT INTO "SettingsHistorySeq" ("userId", "lastHistoryId")
+    SELECT "id", 0 FROM "User" WHERE "id" NOT IN (SELECT "userId" FROM "SettingsHistorySeq");
+
+CREATE UNIQUE INDEX IF NOT EXISTS "IdxSavedSearchTimelineSeq" ON "SavedSearchTimelineSeq" ("userId");
+CREATE UNIQUE INDEX IF NOT EXISTS "IdxSavedSearchHistorySeq" ON "SavedSearchHistorySeq" ("userId");
+
+-- the sequences of the users created before the saved searches were introduced
+INSERT INTO "SavedSearchTimelineSeq" ("userId", "lastTimelineId")
+    SELECT "id", 0 FROM "User" WHERE "id" NOT IN (SELECT "userId" FROM "SavedSearchTimelineSeq");
+INSERT INTO "SavedSearchHistorySeq" ("userId", "lastHistoryId")
+    SELECT "id", 0 FROM "User" WHERE "id" NOT IN (SELECT "userId" FROM "SavedSearchHistorySeq");
+
+CREATE UNIQUE INDEX IF NOT EXISTS "IdxCollectionTimelineSeq" ON "CollectionTimelineSeq" ("userId");
+CREATE UNIQUE INDEX IF NOT EXISTS "IdxCollectionHistorySeq" ON "CollectionHistorySeq" ("userId");
+
+-- the sequences of the users created before the collections were introduced
+INSERT INTO "CollectionTimelineSeq" ("userId", "lastTimelineId")
+    SELECT "id", 0 FROM "User" WHERE "id" NOT IN (SELECT "userId" FROM "CollectionTimelineSeq");
+INSERT INTO "CollectionHistorySeq" ("userId", "lastHistoryId")
+    SELECT "id", 0 FROM "User" WHERE "id" NOT IN (SELECT "userId" FROM "CollectionHistorySeq");
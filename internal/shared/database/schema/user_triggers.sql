@@ -45,4 +45,45 @@ BEGIN
     INSERT
         INTO "ContactHistorySeq" ("userId", "lastHistoryId")
         VALUES (new."id", 0);
+
+    INSERT
+        INTO "ContactGroupTimelineSeq" ("userId", "lastTimelineId")
+        VALUES (new."id", 0);
+    INSERT
+        INTO "ContactGroupHistorySeq" ("userId", "lastHistoryId")
+        VALUES (new."id", 0);
+
+    INSERT
+        INTO "SettingsHistorySeq" ("userId", "lastHistoryId")
+        VALUES (new."id", 0);
+
+    INSERT
+        INTO "SavedSearchTimelineSeq" ("userId", "lastTimelineId")
+        VALUES (new."id", 0);
+    INSERT
+        INTO "SavedSearchHistorySeq" ("userId", "lastHistoryId")
+        VALUES (new."id", 0);
+
+    INSERT
+        INTO "CollectionTimelineSeq" ("userId", "lastTimelineId")
+        VALUES (new."id", 0);
+    INSERT
+        INTO "CollectionHistorySeq" ("userId", "lastHistoryId")
+        VALUES (new."id", 0);
+
+    -- the system labels, after their sequences
+    INSERT
+        INTO "Label" ("userId", "name", "system")
+        VALUES (new."id", 'Inbox', TRUE),
+               (new."id", 'Sent', TRUE),
+               (new."id", 'Trash', TRUE);
+END;
+
+CREATE TRIGGER IF NOT EXISTS "UserAfterUpdateSettings"
+    AFTER UPDATE OF
+        "settings"
+    ON "User"
+    FOR EACH ROW
+BEGIN
+    UPDATE "SettingsHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = new."id";
 END;	
\ No newline at end of file
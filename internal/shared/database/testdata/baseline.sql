@@ -0,0 +1,856 @@
+-- the schema of the baseline release, the existing databases the migrate upgrades
+
+PRAGMA foreign_keys=ON;
+
+------------------------------tables-----------------------------
+
+CREATE TABLE IF NOT EXISTS "User" (
+    "id"			INTEGER PRIMARY KEY,
+    "username"		TEXT NOT NULL UNIQUE,
+    "passwordHash"	TEXT NOT NULL,
+    "firstName"		TEXT DEFAULT "",
+    "lastName"		TEXT DEFAULT "",
+    "settings"      TEXT,                 -- json object
+    "createdAt"		TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS "Session" (
+    "id" 			VARCHAR(32) NOT NULL DEFAULT (lower(hex(randomblob(16)))) PRIMARY KEY,
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "expiry" 		TIMESTAMP NOT NULL,
+    "scope" 		TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS "Blob" (
+    "id"			VARCHAR(32) NOT NULL DEFAULT (lower(hex(randomblob(16)))) PRIMARY KEY,
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "draftId" 	    INTEGER REFERENCES "Draft" ON DELETE CASCADE,
+    "folder"        INTEGER(2) NOT NULL,  -- 0-draft, 1-sent, 2-inbox, 3-in-progress
+    "digest"     	VARCHAR(32) NOT NULL,
+    "name"          VARCHAR(255),
+    "snippet"       VARCHAR(255),
+    "path"			TEXT NOT NULL,
+    "size"			INTEGER NOT NULL,
+    "metadata"      TEXT,                 -- json object
+    "contentType"	TEXT NOT NULL,
+    "createdAt"		TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "modifiedAt"	TIMESTAMP,
+    "timelineId"	INTEGER(8) NOT NULL DEFAULT 0,
+    "historyId" 	INTEGER(8) NOT NULL DEFAULT 0,
+    "lastStmt"  	INTEGER(2) NOT NULL DEFAULT 0, -- 0-inserted, 1-updated, 2-trashed
+    "deviceId"      VARCHAR(32)
+);
+
+CREATE TABLE IF NOT EXISTS "File" (
+    "id"			VARCHAR(32) NOT NULL DEFAULT (lower(hex(randomblob(16)))) PRIMARY KEY,
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "folder"        INTEGER(2) NOT NULL,  -- 0-draft, 1-sent, 2-inbox, 3-in-progress
+    "digest"     	VARCHAR(32) NOT NULL,
+    "name"			TEXT NOT NULL,
+    "path"			TEXT NOT NULL,
+    "size"			INTEGER NOT NULL,
+    "metadata"      TEXT,                 -- json object
+    "contentType"	TEXT NOT NULL,
+    "createdAt"		TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "modifiedAt"	TIMESTAMP,
+    "timelineId"	INTEGER(8) NOT NULL DEFAULT 0,
+    "historyId" 	INTEGER(8) NOT NULL DEFAULT 0,
+    "lastStmt"  	INTEGER(2) NOT NULL DEFAULT 0, -- 0-inserted, 1-updated, 2-trashed
+    "deviceId"      VARCHAR(32)
+);
+
+CREATE TABLE IF NOT EXISTS "Draft"
+(
+    "id"           VARCHAR(32) NOT NULL DEFAULT (lower(hex(randomblob(16)))) PRIMARY KEY,
+    "userId" 	    INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "unread"        BOOLEAN NOT NULL DEFAULT TRUE, 
+    "starred"       BOOLEAN NOT NULL DEFAULT FALSE,
+    "payload"       TEXT,                 -- json 'MessagePart' object
+    "labelIds"      TEXT,                 -- json 'labelIds' array
+    "createdAt"		TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "modifiedAt"    TIMESTAMP,
+    "timelineId"    INTEGER(8) NOT NULL DEFAULT 0,
+    "historyId"     INTEGER(8) NOT NULL DEFAULT 0,
+    "lastStmt"      INTEGER(2) NOT NULL DEFAULT 0, -- 0-inserted, 1-updated, 2-trashed
+    "deviceId"      VARCHAR(32)
+);
+
+CREATE TABLE IF NOT EXISTS "Message"
+(
+    "id"           VARCHAR(32) NOT NULL DEFAULT (lower(hex(randomblob(16)))) PRIMARY KEY,
+    "userId" 	    INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,  
+    "unread"        BOOLEAN NOT NULL DEFAULT TRUE, 
+    "starred"       BOOLEAN NOT NULL DEFAULT FALSE,
+    "folder"        INTEGER(2) NOT NULL,  -- 0-draft (reserved, not used), 1-sent, 2-inbox, 3-in-progress
+    "payload"       TEXT,                 -- json 'MessagePart' object
+    "labelIds"      TEXT,                 -- json 'labelIds' array
+    "sentAt"        TIMESTAMP,
+    "receivedAt"    TIMESTAMP,
+    "snoozedAt"     TIMESTAMP,
+    "createdAt"		TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "modifiedAt"    TIMESTAMP,
+    "timelineId"    INTEGER(8) NOT NULL DEFAULT 0,
+    "historyId"     INTEGER(8) NOT NULL DEFAULT 0,
+    "lastStmt"      INTEGER(2) NOT NULL DEFAULT 0, -- 0-inserted, 1-updated, 2-trashed
+    "deviceId"      VARCHAR(32)
+);
+
+CREATE TABLE IF NOT EXISTS "Label" (
+    "id"			VARCHAR(32) NOT NULL DEFAULT (lower(hex(randomblob(16)))) PRIMARY KEY,
+    "userId" 		INTEGER NOT NULL REFERENCES user ON DELETE CASCADE,
+    "name"          VARCHAR(255) NOT NULL,
+    "createdAt"		TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "modifiedAt"	TIMESTAMP,
+    "timelineId"	INTEGER(8) NOT NULL DEFAULT 0,
+    "historyId" 	INTEGER(8) NOT NULL DEFAULT 0,
+    "lastStmt"  	INTEGER(2) NOT NULL DEFAULT 0, -- 0-inserted, 1-updated, 2-trashed
+    "deviceId"      VARCHAR(32)
+);
+
+CREATE TABLE IF NOT EXISTS "Contact" (
+    "id"			VARCHAR(32) NOT NULL DEFAULT (lower(hex(randomblob(16)))) PRIMARY KEY,
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "emailAddress"  VARCHAR(255) NOT NULL CHECK (
+        "emailAddress" LIKE '%_@_%._%' AND
+        LENGTH("emailAddress") - LENGTH(REPLACE("emailAddress", '@', '')) = 1 AND
+        SUBSTR(LOWER("emailAddress"), 1, INSTR("emailAddress", '.') - 1) NOT GLOB '*[^@0-9a-z]*' AND
+        SUBSTR(LOWER("emailAddress"), INSTR("emailAddress", '.') + 1) NOT GLOB '*[^a-z]*'),
+    "firstName"		VARCHAR(255),
+    "lastName"		VARCHAR(255),
+    "createdAt"		TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "modifiedAt"	TIMESTAMP,
+    "timelineId"	INTEGER(8) NOT NULL DEFAULT 0,
+    "historyId" 	INTEGER(8) NOT NULL DEFAULT 0,
+    "lastStmt"  	INTEGER(2) NOT NULL DEFAULT 0, -- 0-inserted, 1-updated, 2-trashed
+    "deviceId"      VARCHAR(32)
+);
+
+-- push layer: sending a placeholder message from a sender to recipients
+CREATE TABLE IF NOT EXISTS "MessageQueue" (
+    "id"            VARCHAR(32) NOT NULL DEFAULT (lower(hex(randomblob(16)))) PRIMARY KEY, 
+    "message"       TEXT,                 -- json 'Message' object
+    "sender"        TEXT,
+    "destination"   TEXT,
+    "snoozedAt"     TIMESTAMP,
+    "retriesNum"	INTEGER(4) NOT NULL DEFAULT 0
+);
+
+-- pull layer: resource (identified by the digest) retrieval from the sender by the recipient
+/*CREATE TABLE IF NOT EXISTS "ResourceQueue" (
+    "id"            VARCHAR(32) NOT NULL DEFAULT (lower(hex(randomblob(16)))) PRIMARY KEY, 
+    "digest"        TEXT,
+    "recipient"     TEXT,                
+    "origin"        TEXT,
+    "snoozedAt"     TIMESTAMP,
+    "retriesNum"	INTEGER(4) NOT NULL DEFAULT 0
+);*/
+
+CREATE TABLE IF NOT EXISTS "BlobDeleted" (
+    "id"			VARCHAR(32) NOT NULL PRIMARY KEY,
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "historyId" 	INTEGER(8) NOT NULL DEFAULT 0,
+    "deviceId"      VARCHAR(32)
+);
+
+CREATE TABLE IF NOT EXISTS "FileDeleted" (
+    "id"			VARCHAR(32) NOT NULL PRIMARY KEY,
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "historyId" 	INTEGER(8) NOT NULL DEFAULT 0,
+    "deviceId"      VARCHAR(32)
+);
+
+CREATE TABLE IF NOT EXISTS "DraftDeleted" (
+    "id"			VARCHAR(32) NOT NULL PRIMARY KEY,
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "historyId" 	INTEGER(8) NOT NULL DEFAULT 0,
+    "deviceId"      VARCHAR(32)
+);
+
+CREATE TABLE IF NOT EXISTS "MessageDeleted" (
+    "id"			VARCHAR(32) NOT NULL PRIMARY KEY,
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "historyId" 	INTEGER(8) NOT NULL DEFAULT 0,
+    "deviceId"      VARCHAR(32)
+);
+
+CREATE TABLE IF NOT EXISTS "LabelDeleted" (
+    "id"			VARCHAR(32) NOT NULL PRIMARY KEY,
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "historyId" 	INTEGER(8) NOT NULL DEFAULT 0,
+    "deviceId"      VARCHAR(32)
+);
+
+CREATE TABLE IF NOT EXISTS "ContactDeleted" (
+    "id"			VARCHAR(32) NOT NULL PRIMARY KEY,
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "historyId" 	INTEGER(8) NOT NULL DEFAULT 0,
+    "deviceId"      VARCHAR(32)
+);
+
+CREATE TABLE IF NOT EXISTS "BlobTimelineSeq" (
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "lastTimelineId" INTEGER(8) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS "BlobHistorySeq" (
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "lastHistoryId" INTEGER(8) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS "FileTimelineSeq" (
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "lastTimelineId" INTEGER(8) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS "FileHistorySeq" (
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "lastHistoryId" INTEGER(8) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS "DraftTimelineSeq" (
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "lastTimelineId" INTEGER(8) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS "DraftHistorySeq" (
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "lastHistoryId" INTEGER(8) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS "MessageTimelineSeq" (
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "lastTimelineId" INTEGER(8) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS "MessageHistorySeq" (
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "lastHistoryId" INTEGER(8) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS "LabelTimelineSeq" (
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "lastTimelineId" INTEGER(8) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS "LabelHistorySeq" (
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "lastHistoryId" INTEGER(8) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS "ContactTimelineSeq" (
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "lastTimelineId" INTEGER(8) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS "ContactHistorySeq" (
+    "userId" 		INTEGER NOT NULL REFERENCES "User" ON DELETE CASCADE,
+    "lastHistoryId" INTEGER(8) NOT NULL
+);
+
+------------------------------indexes----------------------------
+
+CREATE INDEX IF NOT EXISTS "IdxBlobDigest" ON "Blob" ("digest");
+CREATE INDEX IF NOT EXISTS "IdxBlobTimelineId" ON "Blob" ("timelineId");
+CREATE INDEX IF NOT EXISTS "IdxBlobHistoryId" ON "Blob" ("historyId");
+CREATE INDEX IF NOT EXISTS "IdxBlobLastStmt" ON "Blob" ("lastStmt");
+
+CREATE INDEX IF NOT EXISTS "IdxFileDigest" ON "File" ("digest");
+CREATE INDEX IF NOT EXISTS "IdxFileTimelineId" ON "File" ("timelineId");
+CREATE INDEX IF NOT EXISTS "IdxFileHistoryId" ON "File" ("historyId");
+CREATE INDEX IF NOT EXISTS "IdxFileLastStmt" ON "File" ("lastStmt");
+
+CREATE INDEX IF NOT EXISTS "IdxDraftTimelineId" ON "Draft" ("timelineId");
+CREATE INDEX IF NOT EXISTS "IdxDraftHistoryId" ON "Draft" ("historyId");
+CREATE INDEX IF NOT EXISTS "IdxDraftLastStmt" ON "Draft" ("lastStmt");
+
+CREATE INDEX IF NOT EXISTS "IdxMessageTimelineId" ON "Message" ("timelineId");
+CREATE INDEX IF NOT EXISTS "IdxMessageHistoryId" ON "Message" ("historyId");
+CREATE INDEX IF NOT EXISTS "IdxMessageLastStmt" ON "Message" ("lastStmt");
+
+CREATE UNIQUE INDEX IF NOT EXISTS "IdxLabelName" ON "Label" ("userId", "name") WHERE "lastStmt" < 2;
+CREATE INDEX IF NOT EXISTS "IdxLabelTimelineId" ON "Label" ("timelineId");
+CREATE INDEX IF NOT EXISTS "IdxLabelHistoryId" ON "Label" ("historyId");
+CREATE INDEX IF NOT EXISTS "IdxLabelLastStmt" ON "Label" ("lastStmt");
+
+CREATE UNIQUE INDEX IF NOT EXISTS "IdxContact" ON "Contact"("userId", "emailAddress") WHERE "lastStmt" < 2;
+CREATE INDEX IF NOT EXISTS "IdxContactTimelineId" ON "Contact" ("timelineId");
+CREATE INDEX IF NOT EXISTS "IdxContactHistoryId" ON "Contact" ("historyId");
+CREATE INDEX IF NOT EXISTS "IdxContactLastStmt" ON "Contact" ("lastStmt");
+
+CREATE UNIQUE INDEX IF NOT EXISTS "IdxBlobTimelineSeq" ON "BlobTimelineSeq" ("userId");
+CREATE UNIQUE INDEX IF NOT EXISTS "IdxBlobHistorySeq" ON "BlobHistorySeq" ("userId");
+
+CREATE UNIQUE INDEX IF NOT EXISTS "IdxFileTimelineSeq" ON "FileTimelineSeq" ("userId");
+CREATE UNIQUE INDEX IF NOT EXISTS "IdxFileHistorySeq" ON "FileHistorySeq" ("userId");
+
+CREATE UNIQUE INDEX IF NOT EXISTS "IdxDraftTimelineSeq" ON "DraftTimelineSeq" ("userId");
+CREATE UNIQUE INDEX IF NOT EXISTS "IdxDraftHistorySeq" ON "DraftHistorySeq" ("userId");
+
+CREATE UNIQUE INDEX IF NOT EXISTS "IdxMessageTimelineSeq" ON "MessageTimelineSeq" ("userId");
+CREATE UNIQUE INDEX IF NOT EXISTS "IdxMessageHistorySeq" ON "MessageHistorySeq" ("userId");
+
+CREATE UNIQUE INDEX IF NOT EXISTS "IdxLabelTimelineSeq" ON "LabelTimelineSeq" ("userId");
+CREATE UNIQUE INDEX IF NOT EXISTS "idxLabelHistorySeq" ON "LabelHistorySeq" ("userId");
+
+CREATE UNIQUE INDEX IF NOT EXISTS "IdxContactTimelineSeq" ON "ContactTimelineSeq" ("userId");
+CREATE UNIQUE INDEX IF NOT EXISTS "IdxContactHistorySeq" ON "ContactHistorySeq" ("userId");
+CREATE TRIGGER IF NOT EXISTS "UserAfterInsert"
+    AFTER INSERT
+    ON "User"
+    FOR EACH ROW
+BEGIN
+
+    INSERT
+        INTO "BlobTimelineSeq" ("userId", "lastTimelineId")
+        VALUES (new."id", 0);
+    INSERT
+        INTO "BlobHistorySeq" ("userId", "lastHistoryId")
+        VALUES (new."id", 0);
+
+    INSERT
+        INTO "FileTimelineSeq" ("userId", "lastTimelineId")
+        VALUES (new."id", 0);
+    INSERT
+        INTO "FileHistorySeq" ("userId", "lastHistoryId")
+        VALUES (new."id", 0);
+
+    INSERT
+        INTO "DraftTimelineSeq" ("userId", "lastTimelineId")
+        VALUES (new."id", 0);
+    INSERT
+        INTO "DraftHistorySeq" ("userId", "lastHistoryId")
+        VALUES (new."id", 0);        
+
+    INSERT
+        INTO "MessageTimelineSeq" ("userId", "lastTimelineId")
+        VALUES (new."id", 0);
+    INSERT
+        INTO "MessageHistorySeq" ("userId", "lastHistoryId")
+        VALUES (new."id", 0);
+
+    INSERT
+        INTO "LabelTimelineSeq" ("userId", "lastTimelineId")
+        VALUES (new."id", 0);
+    INSERT
+        INTO "LabelHistorySeq" ("userId", "lastHistoryId")
+        VALUES (new."id", 0);  
+
+    INSERT
+        INTO "ContactTimelineSeq" ("userId", "lastTimelineId")
+        VALUES (new."id", 0);
+    INSERT
+        INTO "ContactHistorySeq" ("userId", "lastHistoryId")
+        VALUES (new."id", 0);
+END;	
+CREATE TRIGGER IF NOT EXISTS "BlobAfterInsert"
+    AFTER INSERT
+    ON "Blob"
+    FOR EACH ROW
+BEGIN
+    UPDATE "BlobTimelineSeq" SET "lastTimelineId" = ("lastTimelineId" + 1) WHERE "userId" = new."userId";
+    UPDATE "BlobHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = new."userId";
+    UPDATE "Blob"
+    SET "timelineId" = (SELECT "lastTimelineId" FROM "BlobTimelineSeq" WHERE "userId" = new."userId"),
+        "historyId"  = (SELECT "lastHistoryId" FROM "BlobHistorySeq" WHERE "userId" = new."userId"),
+        "lastStmt"   = 0
+    WHERE "id" = new."id";
+END;
+
+CREATE TRIGGER IF NOT EXISTS "BlobBeforeUpdate"
+    BEFORE UPDATE OF
+        "id",
+        "userId",
+        -- "folder",
+        -- "digest",
+        -- "name",
+        -- "snippet",
+        "path",
+        -- "size",
+        "metadata",
+        "contentType"
+    ON "Blob"
+    FOR EACH ROW
+BEGIN
+    SELECT RAISE(ABORT, 'Update not allowed');
+END;
+
+CREATE TRIGGER IF NOT EXISTS "BlobBeforeUpdateDraftId"
+    BEFORE UPDATE OF
+        "draftId"
+    ON "Blob"
+    FOR EACH ROW
+    WHEN new."draftId" IS NOT NULL
+BEGIN
+    SELECT RAISE(ABORT, 'Update of "draftId" (except to NULL) is not allowed');
+END;
+
+CREATE TRIGGER IF NOT EXISTS "BlobAfterUpdate"
+    AFTER UPDATE OF
+        "digest",
+        "name",
+        "snippet",
+        "size"
+    ON "Blob"
+    FOR EACH ROW
+BEGIN
+    UPDATE "BlobTimelineSeq" SET "lastTimelineId" = ("lastTimelineId" + 1) WHERE "userId" = old."userId";
+    UPDATE "BlobHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = old."userId";
+    UPDATE "Blob"
+    SET "timelineId" = (SELECT "lastTimelineId" FROM "BlobTimelineSeq" WHERE "userId" = old."userId"),
+        "historyId"  = (SELECT "lastHistoryId" FROM "BlobHistorySeq" WHERE "userId" = old."userId"),
+        "lastStmt"   = 1,
+        "modifiedAt" = CURRENT_TIMESTAMP
+    WHERE "id" = old."id";
+END;
+
+-- Trashed
+CREATE TRIGGER IF NOT EXISTS "BlobBeforeTrash"
+    BEFORE UPDATE OF
+        "lastStmt"
+    ON "Blob"
+    FOR EACH ROW
+BEGIN
+    SELECT RAISE(ABORT, 'Update "lastStmt" not allowed')
+    WHERE NOT (new."lastStmt" == 0 OR new."lastStmt" == 1 OR new."lastStmt" == 2)
+        OR (old."lastStmt" = 2 AND new."lastStmt" = 1); -- Untrash = trashed (2) -> inserted (0)
+  	UPDATE "Blob" 
+	SET "deviceId" = iif(length(new."deviceId") = 39 AND substr(new."deviceId", 1, 7) = 'device:', substr(new."deviceId", 8, 32), NULL)
+	WHERE "id" = new."id";
+END;
+
+CREATE TRIGGER IF NOT EXISTS "BlobAfterTrash"
+    AFTER UPDATE OF
+        "lastStmt"
+    ON "Blob"
+    FOR EACH ROW
+    WHEN (new."lastStmt" <> old."lastStmt" AND old."lastStmt" = 2) OR
+         (new."lastStmt" <> old."lastStmt" AND new."lastStmt" = 2)
+BEGIN
+    UPDATE "BlobHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = old."userId";
+    UPDATE "Blob"
+    SET "historyId"  = (SELECT "lastHistoryId" FROM "BlobHistorySeq" WHERE "userId" = old."userId"),
+        "deviceId" = iif(length(new."deviceId") = 39 AND substr(new."deviceId", 1, 7) = 'device:', substr(new."deviceId", 8, 32), NULL)
+    WHERE "id" = old."id";
+END;
+
+CREATE TRIGGER IF NOT EXISTS "BlobAfterDelete"
+AFTER DELETE
+ON "Blob"
+FOR EACH ROW
+BEGIN
+    UPDATE "BlobHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = old."userId";
+    INSERT INTO "BlobDeleted" ("id", "userId", "historyId")
+      VALUES (old."id",
+              old."userId",
+              (SELECT "lastHistoryId" FROM "BlobHistorySeq" WHERE "userId" = old."userId"));
+END;
+CREATE TRIGGER IF NOT EXISTS "FileAfterInsert"
+    AFTER INSERT
+    ON "File"
+    FOR EACH ROW
+BEGIN
+    UPDATE "FileTimelineSeq" SET "lastTimelineId" = ("lastTimelineId" + 1) WHERE "userId" = new."userId";
+    UPDATE "FileHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = new."userId";
+    UPDATE "File"
+    SET "timelineId" = (SELECT "lastTimelineId" FROM "FileTimelineSeq" WHERE "userId" = new."userId"),
+        "historyId"  = (SELECT "lastHistoryId" FROM "FileHistorySeq" WHERE "userId" = new."userId"),
+        "lastStmt"   = 0
+    WHERE "id" = new."id";
+END;
+
+CREATE TRIGGER IF NOT EXISTS "FileBeforeUpdate"
+    BEFORE UPDATE OF
+        "id",
+        "userId",
+        "folder",
+        "digest",
+        "name",
+        "path",
+        "size",
+        "metadata",
+        "contentType"
+    ON "File"
+    FOR EACH ROW
+BEGIN
+    SELECT RAISE(ABORT, 'Update not allowed');
+END;
+
+-- Trashed
+CREATE TRIGGER IF NOT EXISTS "FileBeforeTrash"
+    BEFORE UPDATE OF
+        "lastStmt"
+    ON "File"
+    FOR EACH ROW
+BEGIN
+    SELECT RAISE(ABORT, 'Update "lastStmt" not allowed')
+    WHERE NOT (new."lastStmt" == 0 OR new."lastStmt" == 2); -- Untrash = trashed (2) -> inserted (0)
+  	UPDATE "File" 
+	SET "deviceId" = iif(length(new."deviceId") = 39 AND substr(new."deviceId", 1, 7) = 'device:', substr(new."deviceId", 8, 32), NULL)
+	WHERE "id" = new."id";
+END;
+
+CREATE TRIGGER IF NOT EXISTS "FileAfterTrash"
+    AFTER UPDATE OF
+        "lastStmt"
+    ON "File"
+    FOR EACH ROW
+    WHEN (new."lastStmt" <> old."lastStmt" AND old."lastStmt" = 2) OR
+         (new."lastStmt" <> old."lastStmt" AND new."lastStmt" = 2)
+BEGIN
+    UPDATE "FileHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = old."userId";
+    UPDATE "File"
+    SET "historyId"  = (SELECT "lastHistoryId" FROM "FileHistorySeq" WHERE "userId" = old."userId"),
+        "deviceId" = iif(length(new."deviceId") = 39 AND substr(new."deviceId", 1, 7) = 'device:', substr(new."deviceId", 8, 32), NULL)
+    WHERE "id" = old."id";
+END;
+
+CREATE TRIGGER IF NOT EXISTS "FileAfterDelete"
+AFTER DELETE
+ON "File"
+FOR EACH ROW
+BEGIN
+    UPDATE "FileHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = old."userId";
+    INSERT INTO "FileDeleted" ("id", "userId", "historyId")
+      VALUES (old."id",
+              old."userId",
+              (SELECT "lastHistoryId" FROM "FileHistorySeq" WHERE "userId" = old."userId"));
+END;
+CREATE TRIGGER IF NOT EXISTS "DraftAfterInsert"
+    AFTER INSERT
+    ON "Draft"
+    FOR EACH ROW
+BEGIN
+    UPDATE "DraftTimelineSeq" SET "lastTimelineId" = ("lastTimelineId" + 1) WHERE "userId" = new."userId";
+    UPDATE "DraftHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = new."userId";
+    UPDATE "Draft"
+    SET "timelineId" = (SELECT "lastTimelineId" FROM "DraftTimelineSeq" WHERE "userId" = new."userId"),
+        "historyId"  = (SELECT "lastHistoryId" FROM "DraftHistorySeq" WHERE "userId" = new."userId"),
+        "lastStmt"   = 0
+    WHERE "id" = new."id";
+END;
+
+CREATE TRIGGER IF NOT EXISTS "DraftBeforeUpdate"
+    BEFORE UPDATE OF
+    "id",
+    "userId"
+    -- "unread", 
+    -- "starred", 
+    -- "payload",
+    -- "labelIds"
+    ON "Draft"
+    FOR EACH ROW
+BEGIN
+    SELECT RAISE(ABORT, 'Update not allowed');
+END;
+
+CREATE TRIGGER IF NOT EXISTS "DraftAfterUpdate"
+    AFTER UPDATE OF
+        "payload"
+    ON "Draft"
+    FOR EACH ROW
+BEGIN
+    UPDATE "DraftTimelineSeq" SET "lastTimelineId" = ("lastTimelineId" + 1) WHERE "userId" = old."userId";
+    UPDATE "DraftHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = old."userId";
+    UPDATE "Draft"
+    SET "timelineId" = (SELECT "lastTimelineId" FROM "DraftTimelineSeq" WHERE "userId" = old."userId"),
+        "historyId"  = (SELECT "lastHistoryId" FROM "DraftHistorySeq" WHERE "userId" = old."userId"),
+        "lastStmt"   = 1,
+        "modifiedAt" = CURRENT_TIMESTAMP
+    WHERE "id" = old."id";
+END;
+
+-- Trashed
+CREATE TRIGGER IF NOT EXISTS "DraftBeforeTrash"
+    BEFORE UPDATE OF
+        "lastStmt"
+    ON "Draft"
+    FOR EACH ROW
+BEGIN
+    SELECT RAISE(ABORT, 'Update "lastStmt" not allowed')
+    WHERE NOT (new."lastStmt" == 0 OR new."lastStmt" == 1 OR new."lastStmt" == 2)
+        OR (old."lastStmt" = 2 AND new."lastStmt" = 1); -- Untrash = trashed (2) -> inserted (0)
+    UPDATE "Draft" 
+	SET "deviceId" = iif(length(new."deviceId") = 39 AND substr(new."deviceId", 1, 7) = 'device:', substr(new."deviceId", 8, 32), NULL)
+	WHERE "id" = new."id";
+END;
+
+CREATE TRIGGER IF NOT EXISTS "DraftAfterTrash"
+    AFTER UPDATE OF
+        "lastStmt"
+    ON "Draft"
+    FOR EACH ROW
+    WHEN (new."lastStmt" <> old."lastStmt" AND old."lastStmt" = 2) OR
+            (new."lastStmt" <> old."lastStmt" AND new."lastStmt" = 2)
+BEGIN
+    UPDATE "DraftHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = old."userId";
+    UPDATE "Draft"
+    SET "historyId"  = (SELECT "lastHistoryId" FROM "DraftHistorySeq" WHERE "userId" = old."userId"),
+        "deviceId" = iif(length(new."deviceId") = 39 AND substr(new."deviceId", 1, 7) = 'device:', substr(new."deviceId", 8, 32), NULL) 
+    WHERE "id" = old."id";
+END;
+
+CREATE TRIGGER IF NOT EXISTS "DraftAfterDelete"
+AFTER DELETE
+ON "Draft"
+FOR EACH ROW
+BEGIN
+    UPDATE "DraftHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = old."userId";
+    INSERT INTO "DraftDeleted" ("id", "userId", "historyId")
+      VALUES (old."id",
+              old."userId",
+              (SELECT "lastHistoryId" FROM "DraftHistorySeq" WHERE "userId" = old."userId"));
+END;
+CREATE TRIGGER IF NOT EXISTS "MessageAfterInsert"
+    AFTER INSERT
+    ON "Message"
+    FOR EACH ROW
+BEGIN
+    UPDATE "MessageTimelineSeq" SET "lastTimelineId" = ("lastTimelineId" + 1) WHERE "userId" = new."userId";
+    UPDATE "MessageHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = new."userId";
+    UPDATE "Message"
+    SET "timelineId" = (SELECT "lastTimelineId" FROM "MessageTimelineSeq" WHERE "userId" = new."userId"),
+        "historyId"  = (SELECT "lastHistoryId" FROM "MessageHistorySeq" WHERE "userId" = new."userId"),
+        "lastStmt"   = 0
+    WHERE "id" = new."id";
+END;
+
+CREATE TRIGGER IF NOT EXISTS "MessageBeforeUpdate"
+    BEFORE UPDATE OF
+    "id",
+    "userId",
+    -- "unread", 
+    -- "starred", 
+    "folder",
+    "payload",
+    -- "labelIds",
+    "sentAt",
+    "receivedAt"
+    -- "snoozedAt"
+    ON "Message"
+    FOR EACH ROW
+BEGIN
+    SELECT RAISE(ABORT, 'Update not allowed');
+END;
+
+CREATE TRIGGER IF NOT EXISTS "MessageAfterUpdate"
+    AFTER UPDATE OF
+    "unread", 
+    "starred"
+    ON "Message"
+    FOR EACH ROW
+BEGIN
+    UPDATE "MessageTimelineSeq" SET "lastTimelineId" = ("lastTimelineId" + 1) WHERE "userId" = old."userId";
+    UPDATE "MessageHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = old."userId";
+    UPDATE "Message"
+    SET "timelineId" = (SELECT "lastTimelineId" FROM "MessageTimelineSeq" WHERE "userId" = old."userId"), -- ???
+        "historyId"  = (SELECT "lastHistoryId" FROM "MessageHistorySeq" WHERE "userId" = old."userId"),
+        "lastStmt"   = 1,
+        "modifiedAt" = CURRENT_TIMESTAMP
+    WHERE "id" = old."id";
+END;
+
+-- Trashed
+CREATE TRIGGER IF NOT EXISTS "MessageBeforeTrash"
+    BEFORE UPDATE OF
+        "lastStmt"
+    ON "Message"
+    FOR EACH ROW
+BEGIN
+    SELECT RAISE(ABORT, 'Update "lastStmt" not allowed')
+    WHERE NOT (new."lastStmt" == 0 OR new."lastStmt" == 1 OR new."lastStmt" == 2)
+        OR (old."lastStmt" = 2 AND new."lastStmt" = 1); -- Untrash = trashed (2) -> inserted (0)
+    UPDATE "Message" 
+	SET "deviceId" = iif(length(new."deviceId") = 39 AND substr(new."deviceId", 1, 7) = 'device:', substr(new."deviceId", 8, 32), NULL)
+	WHERE "id" = new."id";
+END;
+
+CREATE TRIGGER IF NOT EXISTS "MessageAfterTrash"
+    AFTER UPDATE OF
+        "lastStmt"
+    ON "Message"
+    FOR EACH ROW
+    WHEN (new."lastStmt" <> old."lastStmt" AND old."lastStmt" = 2) OR
+            (new."lastStmt" <> old."lastStmt" AND new."lastStmt" = 2)
+BEGIN
+    UPDATE "MessageHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = old."userId";
+    UPDATE "Message"
+    SET "historyId"  = (SELECT "lastHistoryId" FROM "MessageHistorySeq" WHERE "userId" = old."userId"),
+        "deviceId" = iif(length(new."deviceId") = 39 AND substr(new."deviceId", 1, 7) = 'device:', substr(new."deviceId", 8, 32), NULL) 
+    WHERE "id" = old."id";
+END;
+
+CREATE TRIGGER IF NOT EXISTS "MessageAfterDelete"
+AFTER DELETE
+ON "Message"
+FOR EACH ROW
+BEGIN
+    UPDATE "MessageHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = old."userId";
+    INSERT INTO "MessageDeleted" ("id", "userId", "historyId")
+      VALUES (old."id",
+              old."userId",
+              (SELECT "lastHistoryId" FROM "MessageHistorySeq" WHERE "userId" = old."userId"));
+END;
+CREATE TRIGGER IF NOT EXISTS "LabelAfterInsert"
+    AFTER INSERT
+    ON "Label"
+    FOR EACH ROW
+BEGIN
+    UPDATE "LabelTimelineSeq" SET "lastTimelineId" = ("lastTimelineId" + 1) WHERE "userId" = new."userId";
+    UPDATE "LabelHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = new."userId";
+    UPDATE "Label"
+    SET "timelineId" = (SELECT "lastTimelineId" FROM "LabelTimelineSeq" WHERE "userId" = new."userId"),
+        "historyId"  = (SELECT "lastHistoryId" FROM "LabelHistorySeq" WHERE "userId" = new."userId"),
+        "lastStmt"   = 0
+    WHERE "id" = new."id";
+END;
+
+CREATE TRIGGER IF NOT EXISTS "LabelBeforeUpdate"
+    BEFORE UPDATE OF
+        "id",
+        "userId"
+    ON "Label"
+    FOR EACH ROW
+BEGIN
+    SELECT RAISE(ABORT, 'Update not allowed');
+END;
+
+CREATE TRIGGER IF NOT EXISTS "LabelAfterUpdate"
+    AFTER UPDATE OF
+        name
+    ON "Label"
+    FOR EACH ROW
+BEGIN
+    UPDATE "LabelTimelineSeq" SET "lastTimelineId" = ("lastTimelineId" + 1) WHERE "userId" = old."userId";
+    UPDATE "LabelHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = old."userId";
+    UPDATE "Label"
+    SET "timelineId" = (SELECT "lastTimelineId" FROM "LabelTimelineSeq" WHERE "userId" = old."userId"),
+        "historyId"  = (SELECT "lastHistoryId" FROM "LabelHistorySeq" WHERE "userId" = old."userId"),
+        "lastStmt"   = 1,
+        "modifiedAt" = CURRENT_TIMESTAMP
+    WHERE "id" = old."id";
+END;
+
+-- Trashed
+CREATE TRIGGER IF NOT EXISTS "LabelBeforeTrash"
+    BEFORE UPDATE OF
+        "lastStmt"
+    ON "Label"
+    FOR EACH ROW
+BEGIN
+    SELECT RAISE(ABORT, 'Update "lastStmt" not allowed')
+    WHERE NOT (new."lastStmt" == 0 OR new."lastStmt" == 1 OR new."lastStmt" == 2)
+        OR (old."lastStmt" = 2 AND new."lastStmt" = 1); -- Untrash = trashed (2) -> inserted (0)
+    UPDATE "Label" 
+	SET "deviceId" = iif(length(new."deviceId") = 39 AND substr(new."deviceId", 1, 7) = 'device:', substr(new."deviceId", 8, 32), NULL)
+	WHERE "id" = new."id";
+END;
+
+CREATE TRIGGER IF NOT EXISTS "LabelAfterTrash"
+    AFTER UPDATE OF
+        "lastStmt"
+    ON "Label"
+    FOR EACH ROW
+    WHEN (new."lastStmt" <> old."lastStmt" AND old."lastStmt" = 2) OR
+            (new."lastStmt" <> old."lastStmt" AND new."lastStmt" = 2)
+BEGIN
+    UPDATE "LabelHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = old."userId";
+    UPDATE "Label"
+    SET "historyId"  = (SELECT "lastHistoryId" FROM "LabelHistorySeq" WHERE "userId" = old."userId"),
+        "deviceId" = iif(length(new."deviceId") = 39 AND substr(new."deviceId", 1, 7) = 'device:', substr(new."deviceId", 8, 32), NULL) 
+    WHERE "id" = old."id";
+END;
+
+CREATE TRIGGER IF NOT EXISTS "LabelAfterDelete"
+AFTER DELETE
+ON "Label"
+FOR EACH ROW
+BEGIN
+    UPDATE "LabelHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = old."userId";
+    INSERT INTO "LabelDeleted" ("id", "userId", "historyId")
+      VALUES (old."id",
+              old."userId",
+              (SELECT "lastHistoryId" FROM "LabelHistorySeq" WHERE "userId" = old."userId"));
+END;
+CREATE TRIGGER IF NOT EXISTS "ContactAfterInsert"
+    AFTER INSERT
+    ON "Contact"
+    FOR EACH ROW
+BEGIN
+    UPDATE "ContactTimelineSeq" SET "lastTimelineId" = ("lastTimelineId" + 1) WHERE "userId" = new."userId";
+    UPDATE "ContactHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = new."userId";
+    UPDATE "Contact"
+    SET "timelineId" = (SELECT "lastTimelineId" FROM "ContactTimelineSeq" WHERE "userId" = new."userId"),
+        "historyId"  = (SELECT "lastHistoryId" FROM "ContactHistorySeq" WHERE "userId" = new."userId"),
+        "lastStmt"   = 0
+    WHERE "id" = new."id";
+END;
+
+CREATE TRIGGER IF NOT EXISTS "ContactBeforeUpdate"
+    BEFORE UPDATE OF
+        "id",
+        "userId"
+    ON "Contact"
+    FOR EACH ROW
+BEGIN
+    SELECT RAISE(ABORT, 'Update not allowed');
+END;
+
+CREATE TRIGGER IF NOT EXISTS "ContactAfterUpdate"
+    AFTER UPDATE OF
+        "emailAddress",
+        "firstName",
+        "lastName"
+    ON "Contact"
+    FOR EACH ROW
+BEGIN
+    UPDATE "ContactTimelineSeq" SET "lastTimelineId" = ("lastTimelineId" + 1) WHERE "userId" = old."userId";
+    UPDATE "ContactHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = old."userId";
+    UPDATE "Contact"
+    SET "timelineId" = (SELECT "lastTimelineId" FROM "ContactTimelineSeq" WHERE "userId" = old."userId"),
+        "historyId"  = (SELECT "lastHistoryId" FROM "ContactHistorySeq" WHERE "userId" = old."userId"),
+        "lastStmt"   = 1,
+        "modifiedAt" = CURRENT_TIMESTAMP
+    WHERE "id" = old."id";
+END;
+
+-- Trashed
+CREATE TRIGGER IF NOT EXISTS "ContactBeforeTrash"
+    BEFORE UPDATE OF
+        "lastStmt"
+    ON "Contact"
+    FOR EACH ROW
+BEGIN
+    SELECT RAISE(ABORT, 'Update "lastStmt" not allowed')
+    WHERE NOT (new."lastStmt" == 0 OR new."lastStmt" == 1 OR new."lastStmt" == 2)
+        OR (old."lastStmt" = 2 AND new."lastStmt" = 1); -- Untrash = trashed (2) -> inserted (0)
+    UPDATE "Contact" 
+	SET "deviceId" = iif(length(new."deviceId") = 39 AND substr(new."deviceId", 1, 7) = 'device:', substr(new."deviceId", 8, 32), NULL)
+	WHERE "id" = new."id";
+END;
+
+CREATE TRIGGER IF NOT EXISTS "ContactAfterTrash"
+    AFTER UPDATE OF
+        "lastStmt"
+    ON "Contact"
+    FOR EACH ROW
+    WHEN (new."lastStmt" <> old."lastStmt" AND old."lastStmt" = 2) OR
+            (new."lastStmt" <> old."lastStmt" AND new."lastStmt" = 2)
+BEGIN
+    UPDATE "ContactHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = old."userId";
+    UPDATE "Contact"
+    SET "historyId"  = (SELECT "lastHistoryId" FROM "ContactHistorySeq" WHERE "userId" = old."userId"),
+        "deviceId" = iif(length(new."deviceId") = 39 AND substr(new."deviceId", 1, 7) = 'device:', substr(new."deviceId", 8, 32), NULL) 
+    WHERE "id" = old."id";
+END;
+
+CREATE TRIGGER IF NOT EXISTS "ContactAfterDelete"
+AFTER DELETE
+ON "Contact"
+FOR EACH ROW
+BEGIN
+    UPDATE "ContactHistorySeq" SET "lastHistoryId" = ("lastHistoryId" + 1) WHERE "userId" = old."userId";
+    INSERT INTO "ContactDeleted" ("id", "userId", "historyId")
+      VALUES (old."id",
+              old."userId",
+              (SELECT "lastHistoryId" FROM "ContactHistorySeq" WHERE "userId" = old."userId"));
+END;
\ No newline at end of file
@@ -0,0 +1,101 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+type contextKey string
+
+const requestIDContextKey = contextKey("requestId")
+
+// RequestIDHeader carries the correlation id of the request, the one sent by the client is kept.
+const RequestIDHeader = "X-Request-Id"
+
+const maxRequestIDLength = 128
+
+// RequestID returns the correlation id of the request of the context, empty if it has none.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey).(string)
+	return id
+}
+
+// statusWriter keeps the status of the response, the server errors are logged with the request id.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the flusher of the underlying writer.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *statusWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// WithRequestID returns the request carrying the inbound X-Request-Id, or a new UUID if it has none
+// or an unusable one. The id is echoed in the response header.
+func WithRequestID(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, *http.Request) {
+	id := r.Header.Get(RequestIDHeader)
+	if !validRequestID(id) {
+		id = uuid.NewString()
+	}
+
+	w.Header().Set(RequestIDHeader, id)
+
+	return &statusWriter{ResponseWriter: w}, r.WithContext(context.WithValue(r.Context(), requestIDContextKey, id))
+}
+
+// LogServerError logs the request answered with a server error, i.e. the one the client reports.
+func LogServerError(w http.ResponseWriter, r *http.Request) {
+	if sw, ok := w.(*statusWriter); ok && sw.status >= http.StatusInternalServerError {
+		Logf(r.Context(), "%s %s %d", r.Method, r.URL.Path, sw.status)
+	}
+}
+
+// Logf logs the message with the correlation id of the context, e.g. "request_id=… message".
+func Logf(ctx context.Context, format string, v ...interface{}) {
+	if id := RequestID(ctx); len(id) > 0 {
+		log.Printf("request_id=%s %s", id, fmt.Sprintf(format, v...))
+		return
+	}
+
+	log.Printf(format, v...)
+}
+
+// validRequestID accepts the ids of the printable ASCII characters only, so the id can't forge a log line.
+func validRequestID(id string) bool {
+	if len(id) == 0 || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+
+	return true
+}
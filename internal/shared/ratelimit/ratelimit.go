@@ -0,0 +1,223 @@
+package ratelimit
+
+import (
+	"cargomail/internal/shared/config"
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const sweepInterval = time.Minute
+
+// Limiter is a token bucket per key, e.g. a client IP. A bucket holds up to the requests of the rate and
+// is refilled at the rate, so the bursts up to the rate are let through. The buckets live in memory.
+type Limiter struct {
+	mu      sync.Mutex
+	rate    config.Rate
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter returns the limiter of the rate, nil for the unlimited rate.
+func NewLimiter(rate config.Rate) *Limiter {
+	if rate.Unlimited() {
+		return nil
+	}
+
+	return &Limiter{rate: rate, buckets: map[string]*bucket{}}
+}
+
+// Allow takes a token of the key, or returns how long until one is available.
+func (l *Limiter) Allow(key string, now time.Time) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	capacity := float64(l.rate.Requests)
+	refill := capacity / float64(l.rate.Per)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(capacity, b.tokens+float64(now.Sub(b.last))*refill)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	return false, time.Duration(math.Ceil((1 - b.tokens) / refill))
+}
+
+// sweep drops the buckets refilled since, i.e. the keys idle long enough to start over.
+func (l *Limiter) sweep(now time.Time) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= l.rate.Per {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Sweep drops the idle buckets periodically until the context is cancelled.
+func (l *Limiter) Sweep(ctx context.Context) error {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			l.sweep(now)
+		}
+	}
+}
+
+// Limits are the limiters of the client IPs, per route group. The longest path prefix of a group
+// matching the request wins, the other requests share the default limiter.
+type Limits struct {
+	fallback *Limiter
+	groups   []group
+}
+
+type group struct {
+	prefix  string
+	limiter *Limiter
+}
+
+func NewLimits(fallback config.Rate, byPath map[string]config.Rate) *Limits {
+	l := &Limits{fallback: NewLimiter(fallback)}
+
+	for prefix, rate := range byPath {
+		l.groups = append(l.groups, group{prefix: prefix, limiter: NewLimiter(rate)})
+	}
+
+	sort.Slice(l.groups, func(i, j int) bool {
+		return len(l.groups[i].prefix) > len(l.groups[j].prefix)
+	})
+
+	return l
+}
+
+// NewConfiguredLimits returns the limits of the rateLimit and rateLimitByPath options.
+func NewConfiguredLimits() *Limits {
+	return NewLimits(config.RateLimit(), config.RateLimitsByPath())
+}
+
+// Allow takes a token of the client IP of the request in its route group.
+func (l *Limits) Allow(r *http.Request) (bool, time.Duration) {
+	return l.limiter(r.URL.Path).Allow(ClientIP(r), time.Now())
+}
+
+func (l *Limits) limiter(path string) *Limiter {
+	for _, g := range l.groups {
+		if strings.HasPrefix(path, g.prefix) {
+			return g.limiter
+		}
+	}
+
+	return l.fallback
+}
+
+// Sweep drops the idle buckets of the limiters periodically until the context is cancelled.
+func (l *Limits) Sweep(ctx context.Context) error {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			l.fallback.sweep(now)
+
+			for _, g := range l.groups {
+				g.limiter.sweep(now)
+			}
+		}
+	}
+}
+
+// RetryAfter returns the Retry-After header value of the wait, in whole seconds.
+func RetryAfter(wait time.Duration) string {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	return strconv.Itoa(seconds)
+}
+
+// ClientIP returns the IP the request came from. The X-Forwarded-For is trusted of the configured proxies only,
+// the client is the rightmost address not of a trusted proxy, as the addresses left of it may be forged.
+func ClientIP(r *http.Request) string {
+	return clientIP(r, config.TrustedProxies())
+}
+
+func clientIP(r *http.Request, proxies []*net.IPNet) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	if !trusted(host, proxies) {
+		return host
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ip := strings.TrimSpace(forwarded[i])
+		if net.ParseIP(ip) == nil {
+			// a proxy forwarded the garbage, the client is unknown beyond it
+			return host
+		}
+
+		if !trusted(ip, proxies) {
+			return ip
+		}
+
+		host = ip
+	}
+
+	return host
+}
+
+func trusted(ip string, proxies []*net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+
+	for _, network := range proxies {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+
+	return false
+}
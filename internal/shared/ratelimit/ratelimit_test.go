@@ -0,0 +1,45 @@
+package ratelimit
+
+import (
+	"cargomail/internal/shared/config"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	proxies := config.ParseTrustedProxies("10.0.0.1, 192.168.0.0/16, ::1, invalid")
+
+	if len(proxies) != 3 {
+		t.Fatalf("got %d trusted proxies, want 3", len(proxies))
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		want       string
+	}{
+		{"direct", "203.0.113.9:4321", nil, "203.0.113.9"},
+		{"forged by the client", "203.0.113.9:4321", []string{"198.51.100.7"}, "203.0.113.9"},
+		{"trusted proxy", "10.0.0.1:4321", []string{"198.51.100.7"}, "198.51.100.7"},
+		{"trusted proxy of ipv6", "[::1]:4321", []string{"2001:db8::7"}, "2001:db8::7"},
+		{"forged behind the proxy", "10.0.0.1:4321", []string{"198.51.100.1, 198.51.100.7"}, "198.51.100.7"},
+		{"chain of the proxies", "10.0.0.1:4321", []string{"198.51.100.7, 192.168.1.2", "192.168.3.4"}, "198.51.100.7"},
+		{"proxy without the header", "10.0.0.1:4321", nil, "10.0.0.1"},
+		{"garbage of the proxy", "10.0.0.1:4321", []string{"198.51.100.7, unknown"}, "10.0.0.1"},
+		{"all of the proxies", "10.0.0.1:4321", []string{"192.168.1.2"}, "192.168.1.2"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/api/v1/auth/login", nil)
+		r.RemoteAddr = tt.remoteAddr
+
+		for _, value := range tt.forwarded {
+			r.Header.Add("X-Forwarded-For", value)
+		}
+
+		if got := clientIP(r, proxies); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -3,9 +3,18 @@ package main
 import (
 	cargomail "cargomail/cmd"
 	"log"
+	"os"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		err := cargomail.Exec(os.Args[1], os.Args[2:])
+		if err != nil {
+			log.Fatalf("cargomail %s error: %v", os.Args[1], err)
+		}
+		return
+	}
+
 	err := cargomail.Start()
 	if err != nil {
 		log.Fatalf("cargomail error: %v", err)